@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/agent"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+const compareUsage = "Usage: john compare --a <model> --b <model> [--a-settings <file>] [--b-settings <file>] [--json] [--output <file>] [--keep] <prompt>"
+
+func handleCompare(args []string) {
+	var opts agent.CompareOptions
+	var output string
+	var asJSON bool
+	var prompt []string
+	for i := 0; i < len(args); i++ {
+		value := func() string {
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, compareUsage)
+				os.Exit(1)
+			}
+			i++
+			return args[i]
+		}
+		switch args[i] {
+		case "--a", "-a":
+			opts.Models[0] = value()
+		case "--b", "-b":
+			opts.Models[1] = value()
+		case "--a-settings":
+			opts.Settings[0] = value()
+		case "--b-settings":
+			opts.Settings[1] = value()
+		case "--output", "-o":
+			output = value()
+		case "--json":
+			asJSON = true
+		case "--keep":
+			opts.Keep = true
+		default:
+			prompt = append(prompt, args[i])
+		}
+	}
+	opts.Prompt = strings.Join(prompt, " ")
+	if opts.Prompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Prompt = string(data)
+	}
+	if strings.TrimSpace(opts.Prompt) == "" || opts.Models == [2]string{} && opts.Settings == [2]string{} {
+		fmt.Fprintln(os.Stderr, compareUsage)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	// Progress goes to stderr so the report can be piped
+	comparison, err := agent.RunCompare(cfg, ui.NewScripted(os.Stdin, os.Stderr), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(comparison)
+	} else {
+		_, err = io.WriteString(w, comparison.Markdown())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
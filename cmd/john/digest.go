@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/agent"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+const digestUsage = "Usage: john digest [day|week] [--since <24h|7d|YYYY-MM-DD>] [--until <...>] [--project <dir>] [--json]"
+
+func handleDigest(args []string) {
+	settings, settingsErr := config.LoadSettings()
+	if settingsErr == nil {
+		applyDisplaySettings(settings)
+	}
+	// Days are calendar days in the configured zone: day is yesterday and
+	// today, week the seven days before today and today
+	now := time.Now()
+	today := i18n.Day(now)
+	since := today.AddDate(0, 0, -1)
+	until := now
+	var project string
+	asJSON := false
+
+	value := func(i int) string {
+		if i+1 >= len(args) {
+			fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", args[i])
+			os.Exit(1)
+		}
+		return args[i+1]
+	}
+	for i := 0; i < len(args); i++ {
+		var err error
+		switch args[i] {
+		case "day", "daily":
+			since = today.AddDate(0, 0, -1)
+		case "week", "weekly":
+			since = today.AddDate(0, 0, -7)
+		case "--since":
+			since, err = parseDigestTime(value(i), now)
+			i++
+		case "--until":
+			until, err = parseDigestTime(value(i), now)
+			i++
+		case "--project":
+			project, err = filepath.Abs(value(i))
+			i++
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintln(os.Stderr, digestUsage)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	root, err := history.DefaultRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if settingsErr == nil {
+		if err := agent.UnlockSessions(settings, ui.New()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	digests, err := history.BuildDigest(root, since, until)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading sessions: %v\n", err)
+		os.Exit(1)
+	}
+	if project != "" {
+		var kept []history.ProjectDigest
+		for _, d := range digests {
+			if d.CWD == project {
+				kept = append(kept, d)
+			}
+		}
+		digests = kept
+	}
+
+	if asJSON {
+		for i := range digests {
+			digests[i].First = digests[i].First.In(i18n.Location())
+			digests[i].Last = digests[i].Last.In(i18n.Location())
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(digests); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Print(formatDigest(digests, since, until))
+}
+
+// parseDigestTime reads a date (YYYY-MM-DD, in the configured time zone),
+// an RFC 3339 time, or a duration before now such as 36h or 7d
+func parseDigestTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, i18n.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use a date like 2025-03-10 or a duration like 24h or 7d", s)
+}
+
+// formatDigest renders digests as Markdown for pasting into a standup
+func formatDigest(digests []history.ProjectDigest, since, until time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Work digest: %s to %s\n", i18n.FormatTime(since), i18n.FormatTime(until))
+	if len(digests) == 0 {
+		sb.WriteString("\nNo sessions in this period.\n")
+		return sb.String()
+	}
+
+	home, _ := os.UserHomeDir()
+	for _, d := range digests {
+		name := d.CWD
+		if home != "" && strings.HasPrefix(name, home+string(filepath.Separator)) {
+			name = "~" + strings.TrimPrefix(name, home)
+		}
+		sessions := "sessions"
+		if d.Sessions == 1 {
+			sessions = "session"
+		}
+		fmt.Fprintf(&sb, "\n### %s\n%d %s, %d tool calls, last active %s\n", name, d.Sessions, sessions, d.ToolCalls, i18n.FormatTime(d.Last))
+
+		section := func(title string, items []string) {
+			if len(items) == 0 {
+				return
+			}
+			fmt.Fprintf(&sb, "\n**%s**\n", title)
+			for _, item := range items {
+				fmt.Fprintf(&sb, "- %s\n", item)
+			}
+		}
+		section("Done", d.Completed)
+		section("In progress", d.Open)
+		section("Asked for", d.Tasks)
+		if len(d.FilesChanged) > 10 {
+			more := len(d.FilesChanged) - 10
+			section("Files changed", append(d.FilesChanged[:10:10], fmt.Sprintf("...and %d more", more)))
+		} else {
+			section("Files changed", d.FilesChanged)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jbdamask/john-code/pkg/agent"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+func handleHooksCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: john hooks <install|uninstall|run> [pre-commit|pre-push]")
+		os.Exit(1)
+	}
+
+	// Hooks to act on: the ones named, or all of them
+	var hooks []string
+	force := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--force", "-f":
+			force = true
+		case "pre-commit", "pre-push":
+			hooks = append(hooks, arg)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown hook or option: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+	if len(hooks) == 0 {
+		hooks = agent.GitHooks
+	}
+
+	switch args[0] {
+	case "install":
+		for _, hook := range hooks {
+			path, err := agent.InstallGitHook(hook, force)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error installing %s: %v\n", hook, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Installed %s hook at %s\n", hook, path)
+		}
+		fmt.Println("Configure the checks under \"gitHooks\" in .john/settings.json")
+	case "uninstall":
+		for _, hook := range hooks {
+			removed, err := agent.UninstallGitHook(hook)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", hook, err)
+				os.Exit(1)
+			}
+			if removed {
+				fmt.Printf("Removed %s hook\n", hook)
+			}
+		}
+	case "run":
+		if len(hooks) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: john hooks run <pre-commit|pre-push>")
+			os.Exit(1)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			// Without a config the check cannot run; don't block git for it
+			fmt.Fprintf(os.Stderr, "john %s: skipped (%v)\n", hooks[0], err)
+			return
+		}
+		passed, err := agent.RunGitHook(cfg, ui.New(), hooks[0], os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "john %s: check could not run: %v\n", hooks[0], err)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown hooks command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jbdamask/john-code/pkg/agent"
+	"github.com/jbdamask/john-code/pkg/commands"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// initSettings is the project settings.json written by john init. The
+// permission rules keep secrets out of context and confirm pushes.
+const initSettings = `{
+  "permissions": {
+    "allow": [],
+    "ask": [
+      "Bash(git push:*)",
+      "Bash(npm publish:*)"
+    ],
+    "deny": [
+      "Read(.env)",
+      "Read(.env.*)",
+      "Bash(git push --force:*)",
+      "Bash(rm -rf /:*)"
+    ]
+  }
+}
+`
+
+const initExampleCommand = `---
+description: Review the current changes for bugs and style issues
+---
+Review the uncommitted changes in this repository (use git diff). $ARGUMENTS
+
+Report bugs, missing error handling and deviations from the conventions in AGENTS.md.
+List findings by file with line numbers, most severe first. Do not modify any files.
+`
+
+const initExampleAgent = `---
+name: test-runner
+description: Runs the test suite and summarizes failures with likely causes
+---
+You run this project's tests and report on them. Find the test command from
+AGENTS.md, the README or the build files, run it, and for each failure give the
+test name, the error and the most likely cause. Do not modify any files.
+`
+
+// handleInit creates the .john/ scaffolding in the current directory
+func handleInit(args []string) {
+	force := false
+	agentsMD := false
+	for _, arg := range args {
+		switch arg {
+		case "--force", "-f":
+			force = true
+		case "--agents-md":
+			agentsMD = true
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown option: %s\n", arg)
+			fmt.Println("Usage: john init [--force] [--agents-md]")
+			os.Exit(1)
+		}
+	}
+
+	dir, err := config.ProjectDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	files := []struct {
+		path    string
+		content string
+	}{
+		{filepath.Join(dir, "settings.json"), initSettings},
+		{filepath.Join(dir, "commands", "review.md"), initExampleCommand},
+		{filepath.Join(dir, "agents", "test-runner.md"), initExampleAgent},
+	}
+	for _, f := range files {
+		rel, _ := filepath.Rel(filepath.Dir(dir), f.path)
+		if _, err := os.Stat(f.path); err == nil && !force {
+			fmt.Printf("  exists   %s\n", rel)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(f.path, []byte(f.content), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("  created  %s\n", rel)
+	}
+
+	// Setting up a project is an explicit decision to trust it
+	if err := config.SetWorkspaceTrust(filepath.Dir(dir), true); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to mark the workspace trusted: %v\n", err)
+	}
+
+	if agentsMD {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		_, instructions, _ := commands.NewInitCommand().Execute()
+		fmt.Println("\nGenerating AGENTS.md...")
+		if err := agent.New(cfg, ui.New()).RunPrompt(instructions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating AGENTS.md: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println("\nProject initialized. Custom commands in .john/commands are available as /<name>;")
+	fmt.Println("agents in .john/agents can be used by the Task tool.")
+	if !agentsMD {
+		fmt.Println("Run 'john init --agents-md' or /init in a session to generate AGENTS.md.")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+
+	"github.com/jbdamask/john-code/pkg/auth"
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+const loginUsage = "Usage: john login <anthropic|openai> [--device|--browser]"
+
+// handleLogin signs in to a provider with OAuth and saves the tokens, so
+// the provider works without an API key
+func handleLogin(args []string) {
+	var provider, flow string
+	for _, arg := range args {
+		switch arg {
+		case "--device", "--browser":
+			flow = arg
+		default:
+			if provider != "" {
+				fmt.Fprintln(os.Stderr, loginUsage)
+				os.Exit(1)
+			}
+			provider = arg
+		}
+	}
+	if provider == "" {
+		fmt.Fprintln(os.Stderr, loginUsage)
+		os.Exit(1)
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := config.ConfigureHTTP(settings); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid network settings: %v\n", err)
+		os.Exit(1)
+	}
+	client, err := auth.Client(settings, provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if flow == "" {
+		flow = "--browser"
+		if client.DeviceAuthorizationURL != "" {
+			flow = "--device"
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var cred *config.Credential
+	switch {
+	case flow == "--device" && client.DeviceAuthorizationURL != "":
+		cred, err = auth.DeviceLogin(ctx, client, func(verificationURL, userCode string) {
+			fmt.Printf("Open %s and enter the code %s\nWaiting for approval (Ctrl+C cancels)...\n", verificationURL, userCode)
+		})
+	case flow == "--browser" && client.AuthorizationURL != "":
+		cred, err = auth.BrowserLogin(ctx, client, func(authorizationURL string) {
+			fmt.Printf("Opening your browser to log in. If it does not open, visit:\n%s\nWaiting for the browser (Ctrl+C cancels)...\n", authorizationURL)
+			openBrowser(authorizationURL)
+		})
+	default:
+		err = fmt.Errorf("oauth.%s does not set the URL for %s", provider, flow)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := config.SaveCredential(provider, cred); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to save the login: %v\n", err)
+		os.Exit(1)
+	}
+	path, _ := config.CredentialsFilePath()
+	fmt.Printf("Logged in to %s. The tokens are saved in %s and refreshed as needed; %s still wins when it is set.\n", provider, path, auth.Providers[provider])
+}
+
+// handleLogout removes a provider's saved login
+func handleLogout(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: john logout <anthropic|openai>")
+		os.Exit(1)
+	}
+	if _, ok := config.LoadCredential(args[0]); !ok {
+		fmt.Printf("Not logged in to %s\n", args[0])
+		return
+	}
+	if err := config.SaveCredential(args[0], nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Logged out of %s\n", args[0])
+}
+
+// openBrowser opens url in the default browser, if there is one
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
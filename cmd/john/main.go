@@ -1,71 +1,350 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"runtime/debug"
 	"strings"
+	"syscall"
 
 	"github.com/jbdamask/john-code/pkg/agent"
 	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/i18n"
 	"github.com/jbdamask/john-code/pkg/mcp"
+	"github.com/jbdamask/john-code/pkg/review"
+	"github.com/jbdamask/john-code/pkg/telemetry"
 	"github.com/jbdamask/john-code/pkg/ui"
 )
 
+const version = "0.1.0"
+
 func main() {
+	defer startTracing()()
+
 	// Check for subcommands
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "mcp":
 			handleMCPCommand(os.Args[2:])
 			return
+		case "init":
+			handleInit(os.Args[2:])
+			return
+		case "hooks":
+			handleHooksCommand(os.Args[2:])
+			return
+		case "sessions":
+			handleSessionsCommand(os.Args[2:])
+			return
+		case "target":
+			handleTargetCommand(os.Args[2:])
+			return
+		case "login":
+			handleLogin(os.Args[2:])
+			return
+		case "logout":
+			handleLogout(os.Args[2:])
+			return
+		case "digest":
+			handleDigest(os.Args[2:])
+			return
+		case "slack":
+			handleSlack()
+			return
+		case "explain":
+			handleExplain(os.Args[2:])
+			return
+		case "review":
+			handleReview(os.Args[2:])
+			return
+		case "compare":
+			handleCompare(os.Args[2:])
+			return
+		case "doctor":
+			handleDoctor()
+			return
+		case "tools":
+			handleToolsCommand(os.Args[2:])
+			return
+		case "selftest":
+			if err := agent.RunSelfTest(ui.New()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		case "help", "--help", "-h":
 			printHelp()
 			return
 		case "version", "--version", "-v":
-			fmt.Println("John Code v0.1.0")
+			fmt.Println("John Code v" + version)
 			return
 		}
 	}
 
-	// Default: run interactive agent
-	fmt.Println("Starting John Code...")
+	safeMode, printMode := false, false
+	for _, arg := range os.Args[1:] {
+		switch arg {
+		case "--safe-mode":
+			safeMode = true
+		case "-p", "--print":
+			printMode = true
+		}
+	}
 
-	cfg, err := config.Load()
+	// Default: run interactive agent
+	if !printMode {
+		fmt.Println("Starting John Code...")
+	}
+	load := config.Load
+	if safeMode {
+		load = config.LoadSafe
+	}
+	cfg, err := load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		if !safeMode && !errors.Is(err, config.ErrNoAPIKey) {
+			fmt.Fprintln(os.Stderr, "Start with john --safe-mode to run with defaults and find the broken file.")
+		}
 		os.Exit(1)
 	}
 
 	ui := ui.New()
 	ag := agent.New(cfg, ui)
 
+	inputFormat := "text"
+	var promptWords []string
+	for i := 1; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--continue", "-c", "--resume", "-r", "--load-dump":
+			if safeMode {
+				fmt.Fprintf(os.Stderr, "Error: %s cannot be used with --safe-mode, which starts a fresh session\n", os.Args[i])
+				os.Exit(1)
+			}
+			if printMode {
+				fmt.Fprintf(os.Stderr, "Error: %s cannot be used with -p; pass the conversation with --input-format json instead\n", os.Args[i])
+				os.Exit(1)
+			}
+		}
+		switch os.Args[i] {
+		case "--continue", "-c":
+			ag.ResumeOnStart("")
+		case "--resume", "-r":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "Error: --resume requires a session ID")
+				os.Exit(1)
+			}
+			i++
+			ag.ResumeOnStart(os.Args[i])
+		case "--model", "-m":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "Error: --model requires a model name, e.g. sonnet, opus, gpt-5 or flash")
+				os.Exit(1)
+			}
+			i++
+			if err := ag.UseModel(os.Args[i]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "--load-dump":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "Error: --load-dump requires a file written by /dump")
+				os.Exit(1)
+			}
+			i++
+			ag.LoadDumpOnStart(os.Args[i])
+		case "--deterministic":
+			ag.EnableDeterministic()
+		case "--ephemeral":
+			ag.EnableEphemeral()
+		case "--dangerously-skip-permissions":
+			if safeMode {
+				fmt.Fprintln(os.Stderr, "Error: --dangerously-skip-permissions cannot be used with --safe-mode, which asks before every change")
+				os.Exit(1)
+			}
+			if err := ag.EnableSkipPermissions(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "--input-format":
+			if i+1 >= len(os.Args) || (os.Args[i+1] != "text" && os.Args[i+1] != "json") {
+				fmt.Fprintln(os.Stderr, "Error: --input-format requires text or json")
+				os.Exit(1)
+			}
+			i++
+			inputFormat = os.Args[i]
+		default:
+			if printMode && !strings.HasPrefix(os.Args[i], "-") {
+				promptWords = append(promptWords, os.Args[i])
+			}
+		}
+	}
+
+	if printMode {
+		handlePrint(ag, inputFormat, strings.Join(promptWords, " "))
+		return
+	}
+	if inputFormat != "text" {
+		fmt.Fprintln(os.Stderr, "Error: --input-format is only used with -p")
+		os.Exit(1)
+	}
+
+	if !safeMode {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(os.Stderr, "john crashed: %v\n\n%s\nIf this happens on every start, run john --safe-mode to start with defaults and check the config files.\n", r, debug.Stack())
+				os.Exit(2)
+			}
+		}()
+	}
 	if err := ag.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// handlePrint runs john -p: one prompt, or with --input-format json a whole
+// conversation, read from stdin, answered without the interactive loop
+func handlePrint(ag *agent.Agent, inputFormat, prompt string) {
+	var in *agent.HeadlessInput
+	switch {
+	case inputFormat == "json":
+		if prompt != "" {
+			fmt.Fprintln(os.Stderr, "Error: with --input-format json the conversation is read from stdin, not the command line")
+			os.Exit(1)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if in, err = agent.ParseHeadlessInput(data); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		if prompt == "" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			prompt = strings.TrimSpace(string(data))
+		}
+		if prompt == "" {
+			fmt.Fprintln(os.Stderr, "Usage: john -p <prompt>, or pipe the prompt to john -p")
+			os.Exit(1)
+		}
+		in = &agent.HeadlessInput{Messages: []agent.HeadlessMessage{{Role: "user", Content: prompt}}}
+	}
+	if err := ag.RunHeadless(in); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// startTracing exports OpenTelemetry traces when the OTEL_* variables ask
+// for it, and returns the function that flushes them before exit
+func startTracing() func() {
+	shutdown, err := telemetry.Init("john-code", version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return func() {
+		if err := shutdown(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// applyDisplaySettings sets the language and time presentation for
+// commands that print without starting an agent
+func applyDisplaySettings(settings *config.Settings) {
+	i18n.SetLanguage(settings.Language)
+	if err := i18n.SetTime(settings.Time.Zone, settings.Time.Format); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: time in settings: %v\n", err)
+	}
+}
+
 func printHelp() {
-	fmt.Println(`John Code - AI Coding Assistant
+	if settings, err := config.LoadSettings(); err == nil {
+		i18n.SetLanguage(settings.Language)
+	}
+	fmt.Printf(`%s
 
-Usage:
+%s
   john                    Start interactive session
+  john --continue         Continue the most recent session in this project
+  john --resume <id>      Resume a specific session, by ID or /rename name
+  john sessions list      List this project's sessions with their names and tags
+                          (--tag <tag>, --favorites, --json)
+  john sessions merge <a> <b>
+                          Start a new session from a summary of two sessions,
+                          with their todo lists merged
+  john sessions prune     Remove and compress old sessions per the retention
+                          settings (--dry-run lists what would change)
+  john digest [day|week]  Summarize the work in your sessions for a standup
+                          (--since/--until 24h, 7d or YYYY-MM-DD, --project,
+                          --json)
+  john --model <name>     Start with a model: an ID or alias like sonnet, opus,
+                          haiku, gpt5-mini, flash, default or fast
+  john --load-dump <file> Restore the state saved by /dump (for reproducing bugs)
+  john --deterministic    Use temperature 0 and a fixed seed where supported, and
+                          record request hashes in the session log
+  john --ephemeral        Don't save this session, tool stats or debug logs
+  john -p <prompt>        Answer one prompt without the interactive session; the
+                          prompt may also come on stdin. --input-format json reads
+                          a conversation to continue from stdin (see README)
+  john --safe-mode        Start with defaults only (no settings, commands, agents,
+                          memory or MCP servers) and report broken config files
+  john --dangerously-skip-permissions
+                          Run tools without asking (deny rules and the policy
+                          still apply), for disposable containers and CI
   john mcp <command>      Manage MCP servers
+  john init [--agents-md] Create .john/ settings, example commands and agents
+                          (--agents-md also generates AGENTS.md; --force overwrites)
+  john target add <url>   Run Bash and the file tools on ssh://[user@]host[:port]/path
+                          or in a devcontainer (--key <file> for ssh)
+  john target show|remove Show or remove this project's target
+  john login <provider>   Log in to anthropic or openai with OAuth instead of an
+                          API key (--device or --browser picks the flow)
+  john logout <provider>  Remove the saved login
+  john hooks install      Install pre-commit/pre-push hooks that review changes
+  john hooks uninstall    Remove the hooks
+  john explain <target>   Explain a file, directory or symbol using read-only
+                          tools (--model picks the model)
+  john review             Review uncommitted changes, or those since --base <ref>,
+                          as text, SARIF or checkstyle (--format, --output,
+                          --fail-on error|warning|note)
+  john compare --a <model> --b <model> <prompt>
+                          Run a prompt with two models (or --a-settings/--b-settings
+                          files) in separate git worktrees and compare responses,
+                          tool calls, changed files and cost (--json, --output)
+  john slack              Answer Slack mentions and DMs in threads, with the
+                          tools in slack.tools (see README)
+  john selftest           Run an offline end-to-end check of the agent and tools
+  john doctor             Check settings and probe each provider's health and latency
+  john tools export       Write the definitions of the built-in, exec and MCP tools
+                          as JSON (--format openapi, --output, --no-mcp)
   john help               Show this help message
   john version            Show version
 
-MCP Commands:
+%s
   john mcp add <name> <command> [args...]   Add an MCP server
   john mcp add <name> --json '<config>'     Add server from JSON config
   john mcp remove <name>                    Remove an MCP server
   john mcp list                             List configured servers
 
-Examples:
+%s
   john mcp add playwright npx @anthropic-ai/mcp-playwright
   john mcp add filesystem npx -y @anthropic-ai/mcp-filesystem /path/to/dir
   john mcp list
-  john mcp remove playwright`)
+  john mcp remove playwright
+`, i18n.T("John Code - AI Coding Assistant"), i18n.T("Usage:"), i18n.T("MCP Commands:"), i18n.T("Examples:"))
 }
 
 func handleMCPCommand(args []string) {
@@ -191,3 +470,136 @@ func handleMCPList() {
 		fmt.Println()
 	}
 }
+
+func handleSlack() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := agent.RunSlack(ctx, cfg, ui.New()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleExplain(args []string) {
+	var target, model string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--model", "-m":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "Error: --model requires a model name")
+				os.Exit(1)
+			}
+			i++
+			model = args[i]
+		default:
+			if target != "" {
+				fmt.Fprintln(os.Stderr, "Usage: john explain [--model <name>] <path|symbol>")
+				os.Exit(1)
+			}
+			target = args[i]
+		}
+	}
+	if target == "" {
+		fmt.Fprintln(os.Stderr, "Usage: john explain [--model <name>] <path|symbol>")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := agent.RunExplain(cfg, ui.New(), target, model); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+const reviewUsage = "Usage: john review [--base <ref>] [--format text|sarif|checkstyle] [--output <file>] [--fail-on error|warning|note] [--model <name>]"
+
+func handleReview(args []string) {
+	var base, format, output, failOn, model string
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			fmt.Fprintln(os.Stderr, reviewUsage)
+			os.Exit(1)
+		}
+		switch args[i] {
+		case "--base":
+			base = args[i+1]
+		case "--format", "-f":
+			format = args[i+1]
+		case "--output", "-o":
+			output = args[i+1]
+		case "--fail-on":
+			failOn = args[i+1]
+		case "--model", "-m":
+			model = args[i+1]
+		default:
+			fmt.Fprintln(os.Stderr, reviewUsage)
+			os.Exit(1)
+		}
+		i++
+	}
+	switch failOn {
+	case "", review.SeverityError, review.SeverityWarning, review.SeverityNote:
+	default:
+		fmt.Fprintln(os.Stderr, reviewUsage)
+		os.Exit(1)
+	}
+	// Check the format before spending a model call on it
+	if err := review.Write(io.Discard, format, nil, review.Tool{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	findings, err := agent.RunReview(cfg, ui.New(), base, model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	tool := review.Tool{Name: "john-code", Version: version, URI: "https://github.com/jbdamask/john-code"}
+	if err := review.Write(w, format, findings, tool); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if failOn != "" && review.Count(findings, failOn) > 0 {
+		w.Close()
+		os.Exit(1)
+	}
+}
+
+// handleDoctor probes the providers john has credentials for, exiting 1
+// when one of them fails
+func handleDoctor() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := agent.RunDoctor(cfg, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/agent"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+const sessionsListUsage = "Usage: john sessions list [--tag <tag>] [--favorites] [--json]"
+
+func handleSessionsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: john sessions list|merge|prune")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		handleSessionsList(args[1:])
+	case "prune":
+		handleSessionsPrune(args[1:])
+	case "merge":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: john sessions merge <session-a> <session-b>")
+			os.Exit(1)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		id, err := agent.MergeSessions(cfg, ui.New(), args[1], args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Merged into session %s\nContinue it with: john --resume %s\n", id, id)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown sessions command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleSessionsList prints the sessions of the current project, newest
+// first, optionally only those with a tag or marked favorite
+func handleSessionsList(args []string) {
+	var tags []string
+	favorites, asJSON := false, false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--tag", "-t":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, sessionsListUsage)
+				os.Exit(1)
+			}
+			i++
+			tags = append(tags, args[i])
+		case "--favorites", "--favorite", "-f":
+			favorites = true
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintln(os.Stderr, sessionsListUsage)
+			os.Exit(1)
+		}
+	}
+
+	root, err := history.DefaultRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if settings, err := config.LoadSettings(); err == nil {
+		applyDisplaySettings(settings)
+		if err := agent.UnlockSessions(settings, ui.New()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	sessions, err := history.ListSessions(root, cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	type listed struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name,omitempty"`
+		Tags        []string `json:"tags,omitempty"`
+		Favorite    bool     `json:"favorite,omitempty"`
+		Modified    string   `json:"modified"`
+		FirstPrompt string   `json:"firstPrompt,omitempty"`
+	}
+	list := []listed{}
+	var kept []history.SessionInfo
+	for _, s := range sessions {
+		if favorites && !s.Meta.Favorite {
+			continue
+		}
+		matches := true
+		for _, tag := range tags {
+			matches = matches && s.Meta.HasTag(tag)
+		}
+		if !matches {
+			continue
+		}
+		kept = append(kept, s)
+		list = append(list, listed{s.ID, s.Meta.Name, s.Meta.Tags, s.Meta.Favorite, s.ModTime.UTC().Format("2006-01-02T15:04:05Z"), s.FirstPrompt})
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(kept) == 0 {
+		fmt.Println("No matching sessions in this project")
+		return
+	}
+	for _, s := range kept {
+		fmt.Printf("%s  %s  %s\n", history.ShortID(s.ID), i18n.FormatTime(s.ModTime), s.Title())
+	}
+}
+
+// handleSessionsPrune applies the retention settings to the sessions of
+// every project now, or with --dry-run shows what that would do
+func handleSessionsPrune(args []string) {
+	dryRun := false
+	for _, arg := range args {
+		if arg != "--dry-run" && arg != "-n" {
+			fmt.Fprintln(os.Stderr, "Usage: john sessions prune [--dry-run]")
+			os.Exit(1)
+		}
+		dryRun = true
+	}
+	root, err := history.DefaultRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report, err := history.PruneSessions(root, history.RetentionFromSettings(settings.Retention), time.Now(), dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if dryRun {
+		for _, path := range report.Removed {
+			fmt.Println("  remove   " + path)
+		}
+		for _, path := range report.Compressed {
+			fmt.Println("  compress " + path)
+		}
+		fmt.Printf("Would remove %d sessions and %d leftover tool-result directories and compress %d sessions, freeing at least %.1f MB.\n",
+			len(report.Removed), report.Crumbs, len(report.Compressed), float64(report.Freed)/(1<<20))
+		return
+	}
+	fmt.Printf("Removed %d sessions and %d leftover tool-result directories and compressed %d sessions, freeing %.1f MB. Kept %d sessions, %.1f MB.\n",
+		len(report.Removed), report.Crumbs, len(report.Compressed), float64(report.Freed)/(1<<20), report.Kept, float64(report.KeptBytes)/(1<<20))
+}
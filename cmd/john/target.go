@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/target"
+)
+
+func handleTargetCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: john target <add|show|remove> [ssh://[user@]host[:port]/path | devcontainer[://container[/path]] | local] [--key <file>] [--no-check]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		var raw, key string
+		check := true
+		for i := 1; i < len(args); i++ {
+			switch arg := args[i]; arg {
+			case "--key", "-i":
+				if i+1 >= len(args) {
+					fmt.Fprintln(os.Stderr, "--key needs a file")
+					os.Exit(1)
+				}
+				i++
+				key = args[i]
+			case "--no-check":
+				check = false
+			default:
+				if raw != "" {
+					fmt.Fprintf(os.Stderr, "Unexpected argument: %s\n", arg)
+					os.Exit(1)
+				}
+				raw = arg
+			}
+		}
+		settings, err := target.ParseURL(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if key != "" {
+			if settings.Type != "ssh" {
+				fmt.Fprintln(os.Stderr, "--key applies to ssh targets only")
+				os.Exit(1)
+			}
+			settings.IdentityFile = key
+		}
+
+		// Check the host now rather than at the next start
+		if settings.Type == "ssh" && check {
+			cwd, _ := os.Getwd()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			latency, err := target.NewSSH(settings, cwd).Connect(ctx)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\nSet up key-based login (ssh-copy-id, or --key <file>), or save anyway with --no-check.\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Connected to %s (round trip %s)\n", settings.Host, latency.Round(time.Millisecond))
+		}
+
+		path, err := config.SaveProjectTarget(&settings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved the target to %s\n", path)
+		if !config.ProjectTrusted() {
+			fmt.Println("This workspace is not trusted yet, so john ignores its settings; run /trust in a session to use the target.")
+		}
+	case "show":
+		settings, err := config.LoadSettings()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(describeTarget(settings.Target))
+	case "remove", "rm":
+		path, err := config.SaveProjectTarget(nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed the target from %s; tools work on this machine\n", path)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown target command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func describeTarget(t config.TargetSettings) string {
+	switch t.Type {
+	case "ssh":
+		s := fmt.Sprintf("ssh %s in %s", t.Host, t.Dir)
+		if t.Port != 0 {
+			s += fmt.Sprintf(", port %d", t.Port)
+		}
+		if t.IdentityFile != "" {
+			s += ", key " + t.IdentityFile
+		}
+		return s
+	case "devcontainer":
+		container := t.Container
+		if container == "" {
+			container = "(the one started for this folder)"
+		}
+		dir := t.Dir
+		if dir == "" {
+			dir = "/workspaces/<folder>"
+		}
+		return fmt.Sprintf("devcontainer %s in %s", container, dir)
+	}
+	return "local (this machine)"
+}
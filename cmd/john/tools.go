@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/agent"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+const toolsExportUsage = "Usage: john tools export [--format json|openapi] [--output <file>] [--no-mcp]"
+
+func handleToolsCommand(args []string) {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, toolsExportUsage)
+		os.Exit(1)
+	}
+	var format, output string
+	withMCP := true
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--no-mcp" {
+			withMCP = false
+			continue
+		}
+		if i+1 >= len(args) {
+			fmt.Fprintln(os.Stderr, toolsExportUsage)
+			os.Exit(1)
+		}
+		switch args[i] {
+		case "--format", "-f":
+			format = args[i+1]
+		case "--output", "-o":
+			output = args[i+1]
+		default:
+			fmt.Fprintln(os.Stderr, toolsExportUsage)
+			os.Exit(1)
+		}
+		i++
+	}
+	// Check the format before starting MCP servers
+	if err := tools.WriteBundle(io.Discard, format, version, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	exported, pending := agent.ExportTools(cfg, withMCP)
+	if len(pending) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: left out the tools of MCP servers still connecting: %s\n", strings.Join(pending, ", "))
+	}
+
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := tools.WriteBundle(w, format, version, exported); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d tool definitions to %s\n", len(exported), output)
+	}
+}
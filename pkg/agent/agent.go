@@ -2,16 +2,24 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/jbdamask/john-code/pkg/auth"
 	"github.com/jbdamask/john-code/pkg/commands"
 	"github.com/jbdamask/john-code/pkg/config"
 	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/i18n"
 	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/telemetry"
 	"github.com/jbdamask/john-code/pkg/mcp"
+	"github.com/jbdamask/john-code/pkg/project"
 	"github.com/jbdamask/john-code/pkg/tools"
 	"github.com/jbdamask/john-code/pkg/ui"
 )
@@ -26,24 +34,70 @@ type Agent struct {
 	currentModel string
 	history      []llm.Message
 	session      *history.SessionManager
+	finalAnswer  *tools.FinalAnswerTool // Set for sub-agents and headless runs
+	resume       *string                // Session to resume on start ("" = most recent)
+	loadedMemory map[string]bool        // Directories whose nested AGENTS.md was injected
+	editorConfig map[string]bool        // .editorconfig rules already shown, by extension and rules
+	memoryCache  map[string]*memoryFile // Instruction files by path, summarized if large
+	pins         []pin                  // Content kept verbatim through compaction
+	dumpPath     string                 // State dump to restore on start
+	timeline     []timelineEntry        // Prompts, model turns and tool calls of this run
+	timelineMu   sync.Mutex             // Guards timeline for webhook reports
+	loadedFiles  []string               // Files brought in by /context load
+	readOnly     bool                   // Untrusted workspace: only read-only tools run
+	planMode     bool                   // Switched on by the user: only read-only tools run
+	mcpStarted   bool                   // MCP servers were connected at startup
+	tempDir      string                 // Per-session scratch directory, removed on exit
+	unregister   func()                 // Removes this instance from the workspace registry
+	errorLog     []errorRecord          // Recent provider and tool errors for /errors
+	mcpDisabled  map[string]bool        // MCP tools hidden from the model for this session
+	statsRoot    string                 // Where tool stats are recorded; empty to not record
+	attached     []attachedFile         // Files queued by /add for the next prompt
+	headless     bool                   // No one at the terminal: ask rules deny instead of prompting
+	textSink     func(string)           // Receives streamed text instead of the terminal (john slack)
+	tooling      project.Tooling        // Package managers detected in the workspace
+	heldBack     map[string]bool        // Bash commands checkTooling stopped once
+	failures     map[string]retryStreak // Consecutive identical errors per tool
+	clock        func() time.Time       // Time source for turn timing and the watchdog
+	maxTurns     int                    // Model calls allowed in one turn
+	toolChoice   llm.ToolChoice         // Constrains the next model request only
+	commandScope *commandScope          // Tools and model to restore after a custom command's turn
+	meter        contextMeter           // Context window usage, for alerts before it runs out
+	costs        *costLedger            // Tokens by agent and kind of call, shared with sub-agents
+	costScope    []string               // Labels of this sub-agent and its parents in costs
+	injections   injections             // System reminders the model has seen, and their tokens
+	offline      *offlineState          // Set while the provider cannot be reached
+	confineTo    string                 // File tools only work below this directory, if set (john slack)
 }
 
+// defaultMaxTurns bounds the model calls of one turn to stop endless loops
+const defaultMaxTurns = 50
+
+// ErrMaxTurns is returned when a single turn exceeds the tool interaction budget.
+var ErrMaxTurns = errors.New("max turns reached")
+
 func New(cfg *config.Config, ui *ui.UI) *Agent {
     registry := tools.NewRegistry()
     registry.Register(tools.NewBashTool())
     registry.Register(&tools.ReadTool{})
     registry.Register(&tools.WriteTool{})
-    registry.Register(&tools.EditTool{})
+    edit := &tools.EditTool{}
+    registry.Register(edit)
+    registry.Register(tools.NewRenameSymbolTool())
     registry.Register(&tools.GlobTool{})
     registry.Register(tools.NewTodoWriteTool())
     registry.Register(&tools.GrepTool{})
+    registry.Register(&tools.CalcTool{})
     
     registry.Register(tools.NewWebSearchTool())
     registry.Register(tools.NewWebFetchTool())
+    registry.Register(tools.NewDocsTool())
+    registry.Register(tools.NewDepsAuditTool())
     registry.Register(tools.NewAskUserQuestionTool(ui))
     registry.Register(&tools.NotebookEditTool{})
     registry.Register(&tools.BashOutputTool{})
     registry.Register(&tools.KillShellTool{})
+    registry.Register(tools.NewPythonTool())
 
     // Task Tool - Recursive Agent
     // We need to define the runner closure
@@ -61,6 +115,7 @@ func New(cfg *config.Config, ui *ui.UI) *Agent {
     // Let's solve this by passing the factory function to New? 
     // Or just creating the tool with a closure that refers to a function we define here.
     
+    var agent *Agent
     taskRunner := func(ctx context.Context, task string) (string, error) {
         // Create a new agent instance for the subtask
         // We need to use the same config and UI (maybe indented UI?)
@@ -70,12 +125,17 @@ func New(cfg *config.Config, ui *ui.UI) *Agent {
         // Go allows recursive calls.
         
         subAgent := New(cfg, ui)
+        defer subAgent.closePython()
+        subAgent.costs = agent.costs
+        subAgent.costScope = append(append([]string(nil), agent.costScope...), subAgentLabel(ctx, task))
         
         // Override history to start with the task
         subAgent.history = []llm.Message{
             {
                 Role: llm.RoleSystem,
-                Content: "You are a sub-agent working on a specific task: " + task,
+                Content: "You are a sub-agent working on a specific task: " + task +
+                    "\n\nWhen the task is complete, call the FinalAnswer tool with your complete result. " +
+                    "Only the FinalAnswer content is returned to the caller.",
             },
             {
                 Role: llm.RoleUser,
@@ -90,19 +150,26 @@ func New(cfg *config.Config, ui *ui.UI) *Agent {
         return subAgent.RunTask(ctx)
     }
     
-	registry.Register(tools.NewTaskTool(taskRunner))
+	taskTool := tools.NewTaskTool(taskRunner)
+	if !cfg.SafeMode {
+		taskTool.SetAgents(loadSubAgents())
+	}
+	registry.Register(taskTool)
 
 	// Initialize MCP manager
 	mcpManager := mcp.NewManager()
 
 	// Create the agent first (client will be set after)
-	agent := &Agent{
+	agent = &Agent{
 		cfg:          cfg,
 		ui:           ui,
 		tools:        registry,
 		mcpManager:   mcpManager,
 		currentModel: llm.DefaultModelID,
 		session:      nil, // Will init in Run
+		clock:        time.Now,
+		maxTurns:     defaultMaxTurns,
+		costs:        &costLedger{},
 		history: []llm.Message{
 			{
 				Role:    llm.RoleSystem,
@@ -110,23 +177,73 @@ func New(cfg *config.Config, ui *ui.UI) *Agent {
 			},
 		},
 	}
+	edit.Resolve = agent.resolveEditConflict
 
-	// Initialize the client for the default model
-	agent.client = agent.createClientForModel(llm.DefaultModelID)
+	// Initialize the client for the default model, which a project can change
+	if cfg.Settings != nil && cfg.Settings.Models.Default != "" {
+		if m, err := agent.resolveModel(cfg.Settings.Models.Default); err == nil {
+			agent.currentModel = m.ID
+		} else {
+			ui.Print(fmt.Sprintf("Warning: models.default in settings: %v", err))
+		}
+	}
+	agent.currentModel = agent.allowedModel(agent.currentModel)
+	agent.client = agent.createClientForModel(agent.currentModel)
+	agent.setLanguage()
+	agent.setupKeys()
+	agent.registerExecTools()
 
 	// Initialize slash commands (model command needs reference to agent)
 	cmdRegistry := commands.NewRegistry()
 	cmdRegistry.Register(commands.NewInitCommand())
 	cmdRegistry.Register(commands.NewMCPCommand(mcpManager))
 	cmdRegistry.Register(commands.NewModelCommand(agent.currentModel, agent.switchModel))
+	cmdRegistry.Register(commands.NewLocal("resume", "Resume a previous conversation", agent.pickAndResume))
+	cmdRegistry.Register(commands.NewLocal("rename", "Name this session, to find it in /resume and john sessions list", agent.handleRename))
+	cmdRegistry.Register(commands.NewLocal("tag", "Tag this session (-tag removes one, favorite stars it)", agent.handleTag))
+	cmdRegistry.Register(commands.NewLocal("branch", "Experiment on a scratch git branch; /branch done merges or discards it", agent.handleBranch))
+	cmdRegistry.Register(commands.NewLocal("todos", "Expand or collapse the todo panel", func(string) error { return agent.toggleTodos() }))
+	cmdRegistry.Register(commands.NewLocal("memory", "Show active memory files and their size", func(string) error { return agent.showMemory() }))
+	cmdRegistry.Register(commands.NewLocal("pin", "Pin the last response (or a note) so compaction keeps it verbatim", agent.handlePin))
+	cmdRegistry.Register(commands.NewLocal("compact", "Summarize older messages to free up context (pinned content is kept)", agent.compact))
+	cmdRegistry.Register(commands.NewLocal("dump", "Save the full agent state to a JSON file for bug reports", agent.dumpState))
+	cmdRegistry.Register(commands.NewLocal("timeline", "Show tool calls, durations and token counts for this session", func(string) error { return agent.showTimeline() }))
+	cmdRegistry.Register(commands.NewLocal("cost", "Show the estimated cost of this session by agent and kind of call", func(string) error { return agent.showCost() }))
+	cmdRegistry.Register(commands.NewLocal("context", "Save or load a named context (save|load|delete|list <name>)", agent.handleContext))
+	cmdRegistry.Register(commands.NewLocal("trust", "Trust this workspace (or /trust revoke): enables editing and project config", agent.handleTrust))
+	cmdRegistry.Register(commands.NewLocal("errors", "Show recent provider and tool errors with suggested fixes", agent.showErrors))
+	cmdRegistry.Register(commands.NewLocal("status", "Show the model and session, and probe the provider's health and latency", func(string) error { return agent.showStatus() }))
+	cmdRegistry.Register(commands.NewLocal("keys", "Show the prompt key bindings and how to change them", func(string) error { return agent.showKeys() }))
+	cmdRegistry.Register(commands.NewLocal("open", "View a file with syntax highlighting (not sent to the model)", agent.openFile))
+	cmdRegistry.Register(commands.NewLocal("stats", "Show tool usage and failure rates, or model latency (models), across sessions", agent.showStats))
+	cmdRegistry.Register(commands.NewLocal("add", "Attach files matching a glob to the next message", agent.addFiles))
+	cmdRegistry.Register(commands.NewLocal("env", "Set environment variables for this session only", agent.handleEnv))
+	cmdRegistry.Register(commands.NewLocal("servers", "List background dev servers and their ports, or stop them", agent.handleServers))
+	cmdRegistry.Register(commands.NewLocal("follow", "Watch a log file or background shell for new lines, like tail -f", agent.handleFollow))
+	cmdRegistry.Register(commands.NewLocal("diff", "Show uncommitted changes (/diff <path> for the full diff of a file)", agent.handleDiff))
+	cmdRegistry.Register(commands.NewLocal("build", "Run the project's build command and share the result", func(args string) error { return agent.runProjectCommand("build", args) }))
+	cmdRegistry.Register(commands.NewLocal("test", "Run the project's tests and share the result (/test setup sets the command)", func(args string) error { return agent.runProjectCommand("test", args) }))
+	cmdRegistry.Register(commands.NewReleaseNotesCommand(releaseChanges))
+	agent.registerCustomCommands(cmdRegistry)
 
 	agent.commands = cmdRegistry
 
 	return agent
 }
 
-// createClientForModel creates an LLM client for the specified model
+// createClientForModel creates an LLM client for the specified model, with
+// sampling pinned in deterministic mode
 func (a *Agent) createClientForModel(modelID string) llm.Client {
+	client := a.newProviderClient(modelID)
+	if sc, ok := client.(llm.SamplingClient); ok && a.cfg.Deterministic {
+		sc.SetSampling(llm.DeterministicSampling())
+	}
+	return client
+}
+
+// newProviderClient creates the provider's client for a model, falling back
+// to the mock client when the model or its API key is missing
+func (a *Agent) newProviderClient(modelID string) llm.Client {
 	model := llm.GetModelByID(modelID)
 	if model == nil {
 		// Fallback to mock if model not found
@@ -136,15 +253,28 @@ func (a *Agent) createClientForModel(modelID string) llm.Client {
 	switch model.Provider {
 	case llm.ProviderAnthropic:
 		apiKey := a.cfg.APIKey
-		if apiKey == "" || apiKey == "dummy" {
+		tokens, loggedIn := auth.NewTokenSource(a.cfg.Settings, "anthropic")
+		if apiKey == "dummy" || (apiKey == "" && !loggedIn) {
 			return llm.NewMockClient()
 		}
-		return llm.NewAnthropicClient(apiKey, a.cfg.BaseURL, model.APIModel)
+		client := llm.NewAnthropicClient(apiKey, a.cfg.BaseURL, model.APIModel)
+		if apiKey == "" {
+			client.UseOAuth(tokens.Token)
+		}
+		if a.cfg.Settings != nil {
+			client.EnableServerTools(a.cfg.Settings.ServerTools)
+		}
+		return client
 
 	case llm.ProviderOpenAI:
 		apiKey := os.Getenv("OPENAI_API_KEY")
 		if apiKey == "" {
-			a.ui.Print("Warning: OPENAI_API_KEY not set, using mock client")
+			if tokens, ok := auth.NewTokenSource(a.cfg.Settings, "openai"); ok {
+				client := llm.NewOpenAIClient("", model.APIModel)
+				client.UseOAuth(tokens.Token)
+				return client
+			}
+			a.ui.Print("Warning: OPENAI_API_KEY not set and not logged in (john login openai), using mock client")
 			return llm.NewMockClient()
 		}
 		return llm.NewOpenAIClient(apiKey, model.APIModel)
@@ -162,22 +292,62 @@ func (a *Agent) createClientForModel(modelID string) llm.Client {
 	}
 }
 
-// switchModel changes the current model
-func (a *Agent) switchModel(modelID string) error {
-	model := llm.GetModelByID(modelID)
-	if model == nil {
-		return fmt.Errorf("unknown model: %s", modelID)
+// modelAliases returns the model aliases from settings, with "default" and
+// "fast" always defined
+func (a *Agent) modelAliases() map[string]string {
+	aliases := map[string]string{
+		"default": llm.DefaultModelID,
+		"fast":    "claude-haiku-4.5",
 	}
+	if a.cfg.Settings == nil {
+		return aliases
+	}
+	models := a.cfg.Settings.Models
+	for alias, target := range models.Aliases {
+		aliases[alias] = target
+	}
+	if models.Default != "" {
+		aliases["default"] = models.Default
+	}
+	if models.Fast != "" {
+		aliases["fast"] = models.Fast
+	}
+	return aliases
+}
+
+// EnableDeterministic pins temperature 0, top_p 1 and a fixed seed where the
+// provider supports them and records a hash of every request in the session
+// log (--deterministic). Sub-agents inherit the mode through the config.
+func (a *Agent) EnableDeterministic() {
+	a.cfg.Deterministic = true
+	a.client = a.createClientForModel(a.currentModel)
+}
+
+// UseModel selects the model to start with, by ID or alias (--model)
+func (a *Agent) UseModel(name string) error {
+	return a.switchModel(name)
+}
+
+// switchModel changes the current model. name may be an ID or any alias
+// llm.ResolveModel accepts.
+func (a *Agent) switchModel(name string) error {
+	model, err := a.resolveModel(name)
+	if err != nil {
+		return err
+	}
+	modelID := model.ID
 
 	a.client = a.createClientForModel(modelID)
 	a.currentModel = modelID
+	a.offline.stop()
+	a.offline = nil // A chosen model replaces the local fallback
 
 	// Update session manager if present
 	if a.session != nil {
 		a.session.SetModel(model.APIModel)
 	}
 
-	a.ui.Print(fmt.Sprintf("Switched to %s", model.Name))
+	a.ui.Print(i18n.Tf("Switched to %s", model.Name))
 	return nil
 }
 
@@ -191,48 +361,120 @@ func (a *Agent) CurrentModelName() string {
 }
 
 func (a *Agent) Run() error {
+	if !a.safeMode() {
+		a.ui.SetTips(a.startupTips())
+	}
 	a.ui.DrawBanner(a.CurrentModelName())
-	a.ui.Print("Type 'exit' or 'quit' to stop.")
+	a.ui.Print(i18n.T("Type 'exit' or 'quit' to stop."))
+	if a.skipPermissions() {
+		a.ui.Print("Permission prompts are off (--dangerously-skip-permissions): tools run without asking, except where deny rules or the policy block them.")
+	}
+
+	a.setupPrivacy()
+	if a.resume != nil {
+		if err := a.resumeSession(*a.resume); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to resume session: %v", err))
+		}
+	}
+	if a.dumpPath != "" {
+		if err := a.loadDump(a.dumpPath); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to load dump: %v", err))
+		}
+	}
 
 	cwd, err := os.Getwd()
-	if err == nil {
+	if err == nil && a.session == nil && !a.ephemeral() {
 		sm, err := history.NewSessionManager(cwd)
 		if err != nil {
 			a.ui.Print(fmt.Sprintf("Warning: Failed to initialize session manager: %v", err))
 		} else {
 			a.session = sm
-			a.ui.Print(fmt.Sprintf("Session ID: %s", sm.SessionID))
+			a.ui.Print(i18n.Tf("Session ID: %s", sm.SessionID))
 		}
 	}
+	if root, err := history.DefaultRoot(); err == nil && !a.ephemeral() {
+		a.statsRoot = root
+		if docs, ok := a.tools.Get("Docs"); ok {
+			docs.(*tools.DocsTool).SetCacheDir(filepath.Join(root, "docs-cache"))
+		}
+	}
+	if err == nil {
+		a.startInstance(cwd)
+		defer a.stopInstance()
+	}
+	a.startRetention()
 
-	// Load and connect to MCP servers
-	ctx := context.Background()
-	if err := a.mcpManager.LoadAndConnect(ctx); err != nil {
-		a.ui.Print(fmt.Sprintf("Warning: Failed to load MCP servers: %v", err))
+	if a.safeMode() {
+		a.applyPolicy()
+		a.startSafeMode()
+	} else {
+		a.checkWorkspaceTrust()
+		a.applyPolicy()
+		a.setupTarget()
+		a.setupProjectCommands()
+		a.setupTooling()
+		a.setupRepoScan()
+		a.setupHyperlinks()
+		a.setupEditorConfig()
+		a.setupScratchBranch()
+		a.setupServers()
+
+		// Load and connect to MCP servers. Servers that miss the startup budget
+		// register their tools when they come online.
+		ctx := context.Background()
+		a.mcpManager.OnConnect = a.registerServerTools
+		a.mcpManager.ExtraEnv = tools.SessionEnv.Pairs
+		if err := a.mcpManager.LoadAndConnect(ctx); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to load MCP servers: %v", err))
+		}
+		a.mcpStarted = true
+
+		// Register MCP tools
+		a.registerMCPTools()
+		a.mcpTip()
 	}
 
-	// Register MCP tools
-	a.registerMCPTools()
+	// Idle and scheduled session reports for configured webhooks
+	stopWebhooks := a.startWebhooks()
 
 	for {
-		input := a.ui.Prompt("> ")
+		a.contextAlert()
+		input := a.ui.Prompt(a.promptLabel())
+		switch input {
+		case ui.PaletteInput:
+			input = a.runPalette()
+		case ui.PlanModeInput:
+			a.togglePlanMode()
+			continue
+		case ui.CopyInput:
+			a.copyLastResponse()
+			continue
+		}
 		if input == "exit" || input == "quit" {
 			break
 		}
 		if input == "" {
+			if a.offline != nil && a.offline.client == nil && a.offline.queued > 0 {
+				if err := a.sendQueued(); err != nil && !errors.Is(err, ErrInterrupted) {
+					a.ui.Print(i18n.Tf("Error: %v", err))
+				}
+			}
 			continue
 		}
+		// Tips stay until the first message to the model, so several can be followed
+		if !strings.HasPrefix(input, "/") {
+			a.ui.SetTips(nil)
+		}
 
 		// Check for slash command trigger
 		if strings.HasPrefix(input, "/") {
-			cmdName := strings.TrimPrefix(input, "/")
-			cmdName = strings.TrimSpace(cmdName)
+			cmdName, cmdArgs := commands.ParseInput(input)
 
 			// If just "/", show picker
 			if cmdName == "" {
 				cmdList := a.commands.List()
 				if len(cmdList) == 0 {
-					a.ui.Print("No commands available")
+					a.ui.Print(i18n.T("No commands available"))
 					continue
 				}
 
@@ -241,7 +483,7 @@ func (a *Agent) Run() error {
 				for i, cmd := range cmdList {
 					cmdInfos[i] = ui.CommandInfo{
 						Name:        cmd.Name(),
-						Description: cmd.Description(),
+						Description: i18n.T(cmd.Description()),
 					}
 				}
 
@@ -252,21 +494,39 @@ func (a *Agent) Run() error {
 				cmdName = selected
 			}
 
+			if cmdName == "mcp" && cmdArgs == "tools" {
+				if err := a.browseMCPTools(); err != nil {
+					a.ui.Print(i18n.Tf("Error executing command: %v", err))
+				}
+				continue
+			}
+
+			// "/model <name>" switches directly, accepting aliases
+			if cmdName == "model" && cmdArgs != "" {
+				if err := a.switchModel(cmdArgs); err != nil {
+					a.ui.Print(fmt.Sprintf("Error switching model: %v", err))
+				}
+				continue
+			}
+
 			// Handle /model specially - show model picker
 			if cmdName == "model" {
 				modelCmd, ok := a.commands.Get("model")
 				if ok {
 					if mc, ok := modelCmd.(*commands.ModelCommand); ok {
 						models := mc.GetModels()
-						modelInfos := make([]ui.ModelInfo, len(models))
-						for i, m := range models {
-							modelInfos[i] = ui.ModelInfo{
+						var modelInfos []ui.ModelInfo
+						for _, m := range models {
+							if !a.policy().AllowsProvider(m.Provider) {
+								continue
+							}
+							modelInfos = append(modelInfos, ui.ModelInfo{
 								ID:          m.ID,
 								Name:        m.Name,
 								Provider:    m.Provider,
 								Description: m.Description,
 								IsCurrent:   m.ID == a.currentModel,
-							}
+							})
 						}
 
 						selected := a.ui.PickModel(modelInfos)
@@ -283,16 +543,39 @@ func (a *Agent) Run() error {
 			// Execute the command by name
 			cmd, ok := a.commands.Get(cmdName)
 			if !ok {
-				a.ui.Print(fmt.Sprintf("Unknown command: /%s", cmdName))
+				a.ui.Print(i18n.Tf("Unknown command: /%s", cmdName))
 				continue
 			}
 
-			commandMessage, instructions, err := cmd.Execute()
+			// Local commands act on the session and never reach the model
+			if lc, ok := cmd.(commands.LocalCommand); ok {
+				if err := lc.Run(cmdArgs); err != nil {
+					a.ui.Print(i18n.Tf("Error executing command: %v", err))
+				}
+				continue
+			}
+
+			var commandMessage, instructions string
+			if ac, ok := cmd.(commands.ArgsCommand); ok {
+				commandMessage, instructions, err = ac.ExecuteArgs(cmdArgs)
+			} else {
+				commandMessage, instructions, err = cmd.Execute()
+			}
 			if err != nil {
-				a.ui.Print(fmt.Sprintf("Error executing command: %v", err))
+				a.ui.Print(i18n.Tf("Error executing command: %v", err))
 				continue
 			}
 
+			if sc, ok := cmd.(commands.ScopedCommand); ok {
+				if err := a.beginCommandScope(sc); err != nil {
+					a.ui.Print(i18n.Tf("Error executing command: %v", err))
+					continue
+				}
+			}
+			if tc, ok := cmd.(commands.ToolChoiceCommand); ok {
+				a.toolChoice = tc.ToolChoice()
+			}
+
 			// Use the command output as the input
 			input = commandMessage + "\n" + instructions
 		}
@@ -326,35 +609,18 @@ func (a *Agent) Run() error {
 		// Construct full content with reminders
 		fullContent := cleanInput
         
-        // 1. Inject Todo Status
-        todoTool, ok := a.tools.Get("TodoWrite")
-        if ok {
-            if tt, ok := todoTool.(*tools.TodoWriteTool); ok {
-                if len(tt.Todos) == 0 {
-                    fullContent += "\n<system-reminder>\nThis is a reminder that your todo list is currently empty. DO NOT mention this to the user explicitly because they are already aware. If you are working on tasks that would benefit from a todo list please use the TodoWrite tool to create one. If not, please feel free to ignore. Again do not mention this message to the user.\n</system-reminder>"
-                } else {
-                    // Maybe inject current todos? Claude Code likely does.
-                    // For now, let's just stick to the "empty" reminder pattern seen in logs.
-                }
-            }
-        }
-        
-        // 2. Inject CLAUDE.md / AGENTS.md
-        projectFiles := []string{"CLAUDE.md", "AGENTS.md", ".claude.md"}
-        for _, fname := range projectFiles {
-            if _, err := os.Stat(fname); err == nil {
-                content, err := ioutil.ReadFile(fname)
-                if err == nil {
-                    fullContent += fmt.Sprintf("\n<system-reminder>\nAs you answer the user's questions, you can use the following context:\n# claudeMd\nCodebase and user instructions are shown below. Be sure to adhere to these instructions. IMPORTANT: These instructions OVERRIDE any default behavior and you MUST follow them exactly as written.\n\nContents of %s (project instructions, checked into the codebase):\n\n%s\n</system-reminder>", fname, string(content))
-                    break // Only use the first one found
-                }
-            }
-        }
-        
-        // 3. Inject Git Status (inferred from logs)
-        // For MVP, let's skip git status injection to avoid heavy shell calls every turn, 
-        // unless we implement a caching mechanism.
+        // Pasted errors and stack traces get the referenced source attached
+        fullContent += a.errorContext(cleanInput)
+
+        // Linked pages on allowed domains are fetched and attached
+        fullContent += a.urlContext(cleanInput)
+
+        // Files queued with /add go with this prompt
+        fullContent += a.attachmentContext()
         
+        // Instructions and the todo reminder, when the model has not seen them lately
+        fullContent += a.promptReminders()
+
 		// Add user message to history
         userMsg := llm.Message{
 			Role:    llm.RoleUser,
@@ -362,6 +628,7 @@ func (a *Agent) Run() error {
             Images:  images,
 		}
 		a.history = append(a.history, userMsg)
+        a.recordPrompt(input)
         
         if a.session != nil {
             if err := a.session.Append(llm.RoleUser, userMsg); err != nil {
@@ -370,17 +637,251 @@ func (a *Agent) Run() error {
         }
 
 		// Run the LLM loop (handling tool calls)
-		if err := a.processTurn(); err != nil {
-			a.ui.Print(fmt.Sprintf("Error: %v", err))
+		if err := a.sendPrompt(); err != nil && !errors.Is(err, ErrInterrupted) {
+			a.ui.Print(i18n.Tf("Error: %v", err))
+		}
+		a.endCommandScope()
+		if summary := a.turnSummary(); summary != "" {
+			a.ui.Print(summary)
 		}
 	}
 
+	// Report the session to webhooks before tearing anything down
+	stopWebhooks()
+	a.offline.stop()
+	a.reportSessionEnd()
+
 	// Cleanup MCP connections
 	a.mcpManager.Close()
 
+	// Stop background shells and everything they started
+	tools.GlobalShellManager.KillAll()
+
+	a.closePython()
+
+	return nil
+}
+
+// closePython stops the persistent Python interpreter, if one was started.
+// Every agent has its own, sub-agents and Slack threads included.
+func (a *Agent) closePython() {
+	if pyTool, ok := a.tools.Get("Python"); ok {
+		if pt, ok := pyTool.(*tools.PythonTool); ok {
+			pt.Close()
+		}
+	}
+}
+
+// RunPrompt sends a single prompt and runs the agent loop until the model
+// stops, without the interactive input loop. Used by headless subcommands.
+func (a *Agent) RunPrompt(prompt string) error {
+	a.history = append(a.history, llm.Message{Role: llm.RoleUser, Content: prompt})
+	return a.processTurn()
+}
+
+// ResumeOnStart makes Run continue a previous session of this project
+// instead of starting a new one. An empty ID selects the most recent session.
+func (a *Agent) ResumeOnStart(sessionID string) {
+	a.resume = &sessionID
+}
+
+// pickAndResume handles /resume: resume the given session, or let the user
+// pick one of this project's previous sessions.
+func (a *Agent) pickAndResume(sessionID string) error {
+	if sessionID == "" {
+		root, err := history.DefaultRoot()
+		if err != nil {
+			return err
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		sessions, err := history.ListSessions(root, cwd)
+		if err != nil {
+			return err
+		}
+
+		var infos []ui.SessionInfo
+		for _, s := range sessions {
+			if a.session != nil && s.ID == a.session.SessionID {
+				continue
+			}
+			infos = append(infos, ui.SessionInfo{ID: s.ID, FirstPrompt: s.Title(), ModTime: s.ModTime})
+		}
+		if len(infos) == 0 {
+			a.ui.Print("No previous sessions in this project")
+			return nil
+		}
+
+		sessionID = a.ui.PickSession(infos)
+		if sessionID == "" {
+			return nil // User canceled
+		}
+	}
+	return a.resumeSession(sessionID)
+}
+
+// resumeSession replaces the conversation with a stored session and keeps
+// logging to that session's file. An empty ID selects the most recent session.
+func (a *Agent) resumeSession(sessionID string) error {
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var info *history.SessionInfo
+	if sessionID == "" {
+		sessions, err := history.ListSessions(root, cwd)
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return fmt.Errorf("no previous sessions in this project")
+		}
+		info = &sessions[0]
+	} else {
+		info, err = history.FindSession(root, cwd, sessionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	sm, transcript, err := history.ResumeSession(*info, cwd)
+	if err != nil {
+		return err
+	}
+	if model := llm.GetModelByID(a.currentModel); model != nil {
+		sm.SetModel(model.APIModel)
+	}
+
+	// A session killed while a tool ran has a call without a result, which
+	// providers reject
+	messages, fixes := llm.RepairToolPairs(transcript.Messages)
+	if fixes > 0 {
+		a.ui.Print(fmt.Sprintf("Repaired %s left by interrupted tool calls", plural(fixes, "message")))
+	}
+
+	if !a.ephemeral() {
+		a.session = sm
+	}
+	a.history = append([]llm.Message{a.history[0]}, messages...)
+	a.meter = contextMeter{}
+	a.loadedMemory = nil
+	a.editorConfig = nil
+	a.pins = nil
+	a.injections.forget()
+	if transcript.Pins != nil {
+		var pins []savedPin
+		if err := json.Unmarshal(transcript.Pins, &pins); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to restore pins: %v", err))
+		}
+		a.restorePins(pins)
+	}
+
+	if tt := a.todoTool(); tt != nil {
+		tt.Todos = []tools.TodoItem{}
+		if transcript.Todos != nil {
+			if err := json.Unmarshal(transcript.Todos, &tt.Todos); err != nil {
+				a.ui.Print(fmt.Sprintf("Warning: Failed to restore todos: %v", err))
+			}
+		}
+	}
+
+	a.ui.Print(fmt.Sprintf("Resumed session %s (%d messages)", sm.SessionID, len(messages)))
+	a.showTodos()
+	return nil
+}
+
+// todoTool returns the registered TodoWrite tool, if any
+func (a *Agent) todoTool() *tools.TodoWriteTool {
+	if t, ok := a.tools.Get("TodoWrite"); ok {
+		if tt, ok := t.(*tools.TodoWriteTool); ok {
+			return tt
+		}
+	}
+	return nil
+}
+
+// todosChanged persists the todo list to the session and refreshes the panel
+func (a *Agent) todosChanged() {
+	tt := a.todoTool()
+	if tt == nil {
+		return
+	}
+	if a.session != nil {
+		if err := a.session.AppendTodos(tt.Todos); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to log todos: %v", err))
+		}
+	}
+	a.showTodos()
+}
+
+func (a *Agent) showTodos() {
+	tt := a.todoTool()
+	if tt == nil {
+		return
+	}
+	infos := make([]ui.TodoInfo, len(tt.Todos))
+	for i, t := range tt.Todos {
+		infos[i] = ui.TodoInfo{Content: t.Content, ActiveForm: t.ActiveForm, Status: string(t.Status)}
+	}
+	a.ui.ShowTodos(infos)
+}
+
+// toggleTodos handles /todos
+func (a *Agent) toggleTodos() error {
+	a.ui.ToggleTodos()
+	if tt := a.todoTool(); tt == nil || len(tt.Todos) == 0 {
+		a.ui.Print("No todos yet")
+		return nil
+	}
+	a.showTodos()
 	return nil
 }
 
+// registerCustomCommands adds the markdown commands from the user and project
+// command directories. Project commands override user ones; neither can
+// replace a built-in command.
+func (a *Agent) registerCustomCommands(registry *commands.Registry) {
+	if a.safeMode() {
+		return
+	}
+	builtin := make(map[string]bool)
+	for _, cmd := range registry.List() {
+		if _, custom := cmd.(*commands.CustomCommand); !custom {
+			builtin[cmd.Name()] = true
+		}
+	}
+
+	for _, scope := range []struct {
+		dirFn  func() (string, error)
+		source string
+	}{{config.UserDir, "user"}, {config.ProjectDir, "project"}} {
+		if scope.source == "project" && !config.ProjectTrusted() {
+			continue
+		}
+		dir, err := scope.dirFn()
+		if err != nil {
+			continue
+		}
+		cmds, err := commands.LoadCustomCommands(filepath.Join(dir, "commands"), scope.source)
+		if err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to load %s commands: %v", scope.source, err))
+			continue
+		}
+		for _, cmd := range cmds {
+			if !builtin[cmd.Name()] {
+				registry.Register(cmd)
+			}
+		}
+	}
+}
+
 // registerMCPTools registers all tools from connected MCP servers
 func (a *Agent) registerMCPTools() {
 	mcpTools := a.mcpManager.GetAllTools()
@@ -391,63 +892,193 @@ func (a *Agent) registerMCPTools() {
 	if len(mcpTools) > 0 {
 		a.ui.Print(fmt.Sprintf("Registered %d MCP tools", len(mcpTools)))
 	}
+	if pending := a.mcpManager.Pending(); len(pending) > 0 {
+		a.ui.Print(fmt.Sprintf("Still connecting to %s (%s); tools are added when ready, see /mcp",
+			plural(len(pending), "MCP server"), strings.Join(pending, ", ")))
+	}
 }
 
+// registerServerTools registers one server's tools once it has connected in
+// the background. It prints nothing, as the prompt may be active.
+func (a *Agent) registerServerTools(server string) {
+	for _, toolDef := range a.mcpManager.GetAllTools() {
+		if toolDef.ServerName == server {
+			a.tools.Register(tools.NewMCPTool(a.mcpManager, toolDef))
+		}
+	}
+}
+
+// RunTask runs the agent non-interactively until it reports a result via the
+// FinalAnswer tool. A model that stops with plain text is taken at its word;
+// one that stops with nothing is nudged once. If the turn budget runs out, a
+// summary of the progress made so far is returned instead of an error.
 func (a *Agent) RunTask(ctx context.Context) (string, error) {
-    // Run the agent loop non-interactively until it produces a final answer or finishes.
-    // For the agent to "finish", it needs to decide it is done. 
-    // Standard tool-use agents usually stop when they output text without tool calls?
-    // Or we can give it a "TaskDone" tool?
-    // For now, let's say if it outputs text without tool calls, that's the result.
-    
-    // We'll run up to N turns.
-    
-    // But wait, processTurn runs up to 10 tool interactions in a loop.
-    // If processTurn returns nil (no tool calls), it means it has produced a final response text.
-    
-    err := a.processTurn()
-    if err != nil {
-        return "", err
-    }
-    
-    // The last message in history (from Assistant) is the result
-    if len(a.history) > 0 {
-        last := a.history[len(a.history)-1]
-        if last.Role == llm.RoleAssistant {
+    a.finalAnswer = tools.NewFinalAnswerTool()
+    a.tools.Register(a.finalAnswer)
+
+    for attempt := 0; attempt < 2; attempt++ {
+        err := a.processTurn()
+        if a.finalAnswer.Done {
+            return a.finalAnswer.Answer, nil
+        }
+        if errors.Is(err, ErrMaxTurns) || errors.Is(err, ErrTurnStopped) {
+            return a.partialProgress(), nil
+        }
+        if err != nil {
+            return "", err
+        }
+
+        if last := a.history[len(a.history)-1]; last.Role == llm.RoleAssistant && strings.TrimSpace(last.Content) != "" {
             return last.Content, nil
         }
+
+        a.history = append(a.history, llm.Message{
+            Role:    llm.RoleUser,
+            Content: "You stopped without reporting a result. Call the FinalAnswer tool now with your complete answer for the task.",
+        })
+        a.toolChoice = llm.ForceTool("FinalAnswer")
+    }
+
+    return a.partialProgress(), nil
+}
+
+// partialProgress summarizes what a sub-agent did before running out of budget,
+// so the caller can decide how to continue instead of receiving nothing.
+func (a *Agent) partialProgress() string {
+    var sb strings.Builder
+    sb.WriteString("The sub-agent did not finish the task. Progress so far:\n")
+
+    counts := make(map[string]int)
+    var order []string
+    var lastText string
+    for _, msg := range a.history {
+        if msg.Role != llm.RoleAssistant {
+            continue
+        }
+        if strings.TrimSpace(msg.Content) != "" {
+            lastText = msg.Content
+        }
+        for _, tc := range msg.ToolCalls {
+            if counts[tc.Name] == 0 {
+                order = append(order, tc.Name)
+            }
+            counts[tc.Name]++
+        }
+    }
+
+    if len(order) == 0 {
+        sb.WriteString("- No tools were called\n")
+    } else {
+        sb.WriteString("- Tools called: ")
+        for i, name := range order {
+            if i > 0 {
+                sb.WriteString(", ")
+            }
+            sb.WriteString(fmt.Sprintf("%s x%d", name, counts[name]))
+        }
+        sb.WriteString("\n")
+    }
+    if lastText != "" {
+        sb.WriteString("- Last message from the sub-agent:\n")
+        sb.WriteString(lastText)
+        sb.WriteString("\n")
     }
-    return "Task completed with no output", nil
+    return sb.String()
 }
 
-func (a *Agent) processTurn() error {
-    ctx := context.Background()
+// displayStream shows streamed text in the terminal, or hands it to the
+// text sink, and returns the text
+func (a *Agent) displayStream(ch <-chan string) string {
+    if a.textSink == nil {
+        return a.ui.DisplayStream(ch)
+    }
+    var sb strings.Builder
+    for token := range ch {
+        a.textSink(token)
+        sb.WriteString(token)
+    }
+    // Keep the text of the next model turn on its own line
+    if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+        a.textSink("\n")
+    }
+    return sb.String()
+}
+
+func (a *Agent) processTurn() (err error) {
+    ctx, span := telemetry.Start(context.Background(), "invoke_agent john", telemetry.KindInternal,
+        telemetry.String("gen_ai.operation.name", "invoke_agent"), telemetry.String("gen_ai.request.model", a.currentModel))
+    defer func() { span.End(err) }()
+    watch := a.newTurnWatch()
     
     // Max turns to prevent infinite loops
-    for i := 0; i < 50; i++ {
+    for i := 0; i < a.maxTurns; i++ {
         // Prepare tools for the API
         var apiTools []interface{}
+        serverTools, _ := a.client.(llm.ServerToolClient)
         for _, t := range a.tools.List() {
+             // Skip local tools the provider runs itself
+             if serverTools != nil && serverTools.ReplacesTool(t.Name) {
+                 continue
+             }
+             if a.mcpDisabled[t.Name] || !a.policy().AllowsTool(t.Name) || !a.commandScope.allowsTool(t.Name) {
+                 continue
+             }
              apiTools = append(apiTools, t)
         }
 
         ch := make(chan string)
+        stream := make(chan string)
         type result struct {
             resp *llm.Message
             err  error
         }
         resultCh := make(chan result, 1)
         
+        if err := a.checkContextFits(); err != nil {
+            return err
+        }
+
+        start := a.clock()
+        reqCtx, llmSpan := a.startModelSpan(a.takeToolChoice(ctx, apiTools))
+        genCtx, stopInterrupt := interruptible(reqCtx)
         go func() {
-            defer close(ch)
-            r, err := a.client.GenerateStream(ctx, a.history, apiTools, ch)
+            defer close(stream)
+            r, err := a.client.GenerateStream(genCtx, a.history, apiTools, stream)
             resultCh <- result{resp: r, err: err}
         }()
+        // Note when the first token arrives; ch is closed before it is read
+        var firstToken time.Time
+        go func() {
+            defer close(ch)
+            for token := range stream {
+                if firstToken.IsZero() {
+                    firstToken = a.clock()
+                }
+                ch <- token
+            }
+        }()
 
-        a.ui.DisplayStream(ch)
+        streamed := a.displayStream(ch)
         
         res := <-resultCh
+        if stopInterrupt() {
+            // The user stopped it, so it is not a failed call
+            a.recordModelTurn(start, firstToken, res.resp, nil)
+            endModelSpan(llmSpan, start, firstToken, res.resp, nil)
+            if a.keepPartialResponse(streamed, res.resp) {
+                a.ui.PrintDim("[interrupted; the partial response is kept in the conversation]")
+            } else {
+                a.ui.PrintDim("[interrupted]")
+            }
+            return ErrInterrupted
+        }
+        a.recordModelTurn(start, firstToken, res.resp, res.err)
+        endModelSpan(llmSpan, start, firstToken, res.resp, res.err)
         if res.err != nil {
+            a.recordProviderError(res.err)
+            if a.keepPartialResponse(streamed, nil) {
+                a.ui.PrintDim("[response cut off by the error below; the partial response is kept in the conversation]")
+            }
             return res.err
         }
         if res.resp == nil {
@@ -455,7 +1086,22 @@ func (a *Agent) processTurn() error {
         }
         resp := res.resp
 
+        // Clients that don't stream text (or stream only part of it) leave
+        // the rest unseen; print just that, never the streamed part again
+        if strings.HasPrefix(resp.Content, streamed) {
+            if unseen := resp.Content[len(streamed):]; strings.TrimSpace(unseen) != "" {
+                if a.textSink != nil {
+                    a.textSink(unseen)
+                } else {
+                    a.ui.Print(unseen)
+                }
+            }
+        }
+
+        a.saveArtifacts(ctx, resp)
         a.history = append(a.history, *resp)
+        a.meter.measured(resp.Usage, len(a.history))
+        watch.addResponse(a.currentModel, resp)
         if a.session != nil {
             if err := a.session.Append(llm.RoleAssistant, *resp); err != nil {
                 a.ui.Print(fmt.Sprintf("Warning: Failed to log assistant message: %v", err))
@@ -468,41 +1114,98 @@ func (a *Agent) processTurn() error {
         }
 
         // Handle tool calls
+        releasePrefetch := a.prefetchReads(resp.ToolCalls)
         for _, tc := range resp.ToolCalls {
-            a.ui.Print(fmt.Sprintf("Running tool: %s", tc.Name))
+            // Nothing runs after FinalAnswer, but every call still gets a
+            // result so calls and results stay paired
+            if a.finalAnswer != nil && a.finalAnswer.Done {
+                a.appendToolResult(llm.ToolResult{ToolCallID: tc.ID, ToolName: tc.Name, Content: "Not run: the task already finished with FinalAnswer.", IsError: true})
+                continue
+            }
+            a.ui.Print(i18n.Tf("Running tool: %s", tc.Name))
             
             tool, found := a.tools.Get(tc.Name)
+            if a.mcpDisabled[tc.Name] {
+                found = false
+            }
             var result string
             var err error
+            denied := false
+            toolStart := a.clock()
+            toolCtx, toolSpan := telemetry.Start(ctx, "execute_tool "+tc.Name, telemetry.KindInternal,
+                telemetry.String("gen_ai.operation.name", "execute_tool"), telemetry.String("gen_ai.tool.name", tc.Name),
+                telemetry.String("gen_ai.tool.call.id", tc.ID))
             
             if !found {
                 result = fmt.Sprintf("Error: Tool %s not found", tc.Name)
+                a.recordToolError(tc.Name, result)
+            } else if tc.ArgsError != "" {
+                result = a.malformedArgs(tc)
+                a.recordToolError(tc.Name, result)
+            } else if denial, ok := a.checkPermission(tc); !ok {
+                result, denied = denial, true
+            } else if args, denial, ok := a.checkSecrets(tc, tool); !ok {
+                result, denied = denial, true
             } else {
-                result, err = tool.Execute(ctx, tc.Args)
+                // {{secret:NAME}} placeholders become session variables here,
+                // only for local tools, so the values never appear in the
+                // logged call
+                result, err = tool.Execute(toolCtx, args)
                 if err != nil {
                     result = fmt.Sprintf("Error executing tool: %v", err)
+                    a.recordToolError(tc.Name, err.Error())
+                } else if tc.Name == "TodoWrite" {
+                    a.todosChanged()
+                } else if !a.safeMode() {
+                    result = a.injectNestedMemory(tc, result)
+                    result = a.injectEditorConfig(tc, result)
                 }
             }
-            
-            // Append tool result to history
-            toolMsg := llm.Message{
-                Role: llm.RoleTool,
-                ToolResult: &llm.ToolResult{
-                    ToolCallID: tc.ID,
-                    ToolName:   tc.Name,
-                    Content:    result,
-                },
-            }
-            a.history = append(a.history, toolMsg)
-            
-            if a.session != nil {
-                if err := a.session.Append(llm.RoleTool, toolMsg); err != nil {
-                    a.ui.Print(fmt.Sprintf("Warning: Failed to log tool result: %v", err))
+            // Output that echoes a secret keeps it out of history and logs
+            result = tools.SessionEnv.Redact(result)
+            if tc.Name == "Bash" || tc.Name == "BashOutput" {
+                if hint := a.serverConflictHint(result); hint != "" {
+                    result += "\n\n" + hint
                 }
             }
+            if found && tc.ArgsError == "" && err == nil {
+                result = a.summarizeToolResult(ctx, tc, result)
+            }
+            
+            failed := !found || tc.ArgsError != "" || err != nil
+            a.recordToolCall(toolStart, tc, result, failed)
+            endToolSpan(toolSpan, result, failed)
+            result += a.retryReminder(tc, result, failed)
+            
+            a.appendToolResult(llm.ToolResult{
+                ToolCallID: tc.ID,
+                ToolName:   tc.Name,
+                Content:    result,
+                IsError:    failed || denied,
+            })
+        }
+        releasePrefetch()
+
+        // A sub-agent that reported its result is finished
+        if a.finalAnswer != nil && a.finalAnswer.Done {
+            return nil
+        }
+        if err := a.checkWatchdog(watch); err != nil {
+            return err
         }
         // Loop continues to send tool results back to LLM
     }
     
-    return fmt.Errorf("max turns reached")
+    return ErrMaxTurns
+}
+
+// appendToolResult adds a tool result to the history and the session log
+func (a *Agent) appendToolResult(result llm.ToolResult) {
+    toolMsg := llm.Message{Role: llm.RoleTool, ToolResult: &result}
+    a.history = append(a.history, toolMsg)
+    if a.session != nil {
+        if err := a.session.Append(llm.RoleTool, toolMsg); err != nil {
+            a.ui.Print(fmt.Sprintf("Warning: Failed to log tool result: %v", err))
+        }
+    }
 }
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// artifactDownloadTimeout bounds fetching one file a server tool created
+const artifactDownloadTimeout = 60 * time.Second
+
+// artifactExts name saved artifacts by media type
+var artifactExts = map[string]string{
+	"image/png":        ".png",
+	"image/jpeg":       ".jpg",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+	"image/svg+xml":    ".svg",
+	"application/pdf":  ".pdf",
+	"application/json": ".json",
+	"text/csv":         ".csv",
+	"text/plain":       ".txt",
+	"text/html":        ".html",
+}
+
+// artifactsDir is where images and files from the model are saved: next to
+// the session log, or in the temp directory without a session or when
+// sessions are encrypted
+func (a *Agent) artifactsDir() string {
+	if a.session != nil && !history.SessionsEncrypted() {
+		return filepath.Join(strings.TrimSuffix(a.session.FilePath, ".jsonl"), "artifacts")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("john-artifacts-%d", os.Getpid()))
+}
+
+// saveArtifacts writes the images and files of a response to the artifacts
+// directory, downloading those the provider kept, and sets their paths so
+// the session log points at them. An artifact that cannot be saved keeps
+// its file ID and is reported.
+func (a *Agent) saveArtifacts(ctx context.Context, resp *llm.Message) {
+	if len(resp.Artifacts) == 0 {
+		return
+	}
+	dir := a.artifactsDir()
+	for i := range resp.Artifacts {
+		art := &resp.Artifacts[i]
+		if art.Data == nil && art.FileID != "" {
+			files, ok := a.client.(llm.FileClient)
+			if !ok {
+				continue
+			}
+			dlCtx, cancel := context.WithTimeout(ctx, artifactDownloadTimeout)
+			name, mediaType, data, err := files.DownloadFile(dlCtx, art.FileID)
+			cancel()
+			if err != nil {
+				a.ui.Print(fmt.Sprintf("Warning: cannot download file %s from the model: %v", art.FileID, err))
+				continue
+			}
+			art.Name, art.MediaType, art.Data = name, mediaType, data
+		}
+		if art.Data == nil {
+			continue
+		}
+		path, err := writeArtifact(dir, art)
+		if err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: cannot save %s from the model: %v", artifactKind(art), err))
+			continue
+		}
+		art.Path, art.Data = path, nil
+		a.ui.Print(fmt.Sprintf("Saved %s from the model: %s", artifactKind(art), path))
+	}
+}
+
+// writeArtifact saves an artifact under dir by its own name, or as
+// image-N/file-N, never replacing a file already there
+func writeArtifact(dir string, art *llm.Artifact) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	ext := artifactExts[art.MediaType]
+	name := unsafeFileChars.ReplaceAllString(filepath.Base(art.Name), "_")
+	if art.Name == "" || name == "." || name == ".." {
+		if ext == "" {
+			ext = ".bin"
+		}
+		entries, _ := os.ReadDir(dir)
+		name = fmt.Sprintf("%s-%d%s", artifactKind(art), len(entries)+1, ext)
+	}
+	stem, ext := strings.TrimSuffix(name, filepath.Ext(name)), filepath.Ext(name)
+	for n := 2; ; n++ {
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			name = fmt.Sprintf("%s-%d%s", stem, n, ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(art.Data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
+
+func artifactKind(art *llm.Artifact) string {
+	if strings.HasPrefix(art.MediaType, "image/") {
+		return "image"
+	}
+	return "file"
+}
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// attachTokenBudget caps what one /add queues for the next prompt
+	attachTokenBudget = 40000
+	// attachFileMaxBytes is the size above which a file is skipped
+	attachFileMaxBytes = 100 * 1024
+)
+
+// attachedFile is a file queued by /add for the next prompt
+type attachedFile struct {
+	path    string
+	content string
+}
+
+// skipAttachDirs are never searched when the project is not a git
+// repository, where .gitignore decides instead
+var skipAttachDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, ".john": true}
+
+// addFiles handles /add: queue the files matching each glob to go with the
+// next prompt. "/add" alone lists the queue and "/add clear" empties it.
+func (a *Agent) addFiles(args string) error {
+	patterns := strings.Fields(args)
+	switch {
+	case len(patterns) == 0:
+		if len(a.attached) == 0 {
+			a.ui.Print("No files queued. Usage: /add <glob> [glob...], e.g. /add 'src/auth/**/*.go'")
+			return nil
+		}
+		var sb strings.Builder
+		total := 0
+		for _, f := range a.attached {
+			total += estimateTokens(f.content)
+			sb.WriteString(fmt.Sprintf("\n  %s (~%s tokens)", f.path, formatTokens(estimateTokens(f.content))))
+		}
+		a.ui.Print(fmt.Sprintf("Queued for the next message, ~%s tokens:%s", formatTokens(total), sb.String()))
+		return nil
+	case len(patterns) == 1 && patterns[0] == "clear":
+		a.attached = nil
+		a.ui.Print("Cleared the queued files.")
+		return nil
+	}
+
+	candidates, err := projectFiles()
+	if err != nil {
+		return err
+	}
+	var matches []string
+	seen := make(map[string]bool)
+	for _, f := range a.attached {
+		seen[f.path] = true
+	}
+	for _, pattern := range patterns {
+		pattern = path.Clean(strings.TrimPrefix(filepath.ToSlash(strings.Trim(pattern, `"'`)), "./"))
+		found := false
+		for _, file := range candidates {
+			if matchGlob(pattern, file) {
+				found = true
+				if !seen[file] {
+					seen[file] = true
+					matches = append(matches, file)
+				}
+			}
+		}
+		if !found {
+			a.ui.Print(fmt.Sprintf("No files match %s", pattern))
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	used := 0
+	for _, f := range a.attached {
+		used += estimateTokens(f.content)
+	}
+	var added, skipped []string
+	for _, file := range matches {
+		info, err := os.Stat(file)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", file, err))
+			continue
+		}
+		if info.Size() > attachFileMaxBytes {
+			skipped = append(skipped, fmt.Sprintf("%s (%s, over the %s limit; use Read for it)", file,
+				formatBytes(int(info.Size())), formatBytes(attachFileMaxBytes)))
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", file, err))
+			continue
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			skipped = append(skipped, fmt.Sprintf("%s (binary)", file))
+			continue
+		}
+		tokens := estimateTokens(string(data))
+		if used+tokens > attachTokenBudget {
+			skipped = append(skipped, fmt.Sprintf("%s (~%s tokens, over the ~%s token budget)", file,
+				formatTokens(tokens), formatTokens(attachTokenBudget)))
+			continue
+		}
+		used += tokens
+		a.attached = append(a.attached, attachedFile{path: file, content: string(data)})
+		added = append(added, fmt.Sprintf("%s (~%s tokens)", file, formatTokens(tokens)))
+	}
+
+	if len(added) > 0 {
+		a.ui.Print(fmt.Sprintf("Attached %s to the next message, ~%s tokens queued in all:\n  %s",
+			plural(len(added), "file"), formatTokens(used), strings.Join(added, "\n  ")))
+	}
+	if len(skipped) > 0 {
+		a.ui.Print(fmt.Sprintf("Skipped %s:\n  %s", plural(len(skipped), "file"), strings.Join(skipped, "\n  ")))
+	}
+	return nil
+}
+
+// attachmentContext returns the files queued by /add as a reminder for the
+// prompt being sent, and empties the queue
+func (a *Agent) attachmentContext() string {
+	if len(a.attached) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n<system-reminder>\nThe user attached these files to the message:\n")
+	for _, f := range a.attached {
+		sb.WriteString(fmt.Sprintf("\n<file path=%q>\n%s\n</file>\n", f.path, strings.TrimRight(f.content, "\n")))
+		// /context save keeps them
+		a.loadedFiles = append(a.loadedFiles, f.path)
+	}
+	sb.WriteString("</system-reminder>")
+	a.attached = nil
+	return sb.String()
+}
+
+// projectFiles lists the files of the project in the working directory as
+// slash-separated relative paths. In a git repository this is what git
+// tracks plus untracked files it does not ignore; elsewhere it is every
+// file outside skipAttachDirs.
+func projectFiles() ([]string, error) {
+	out, err := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard").Output()
+	if err == nil {
+		var files []string
+		for _, line := range strings.Split(string(out), "\n") {
+			if line != "" {
+				files = append(files, line)
+			}
+		}
+		return files, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != "." && skipAttachDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, filepath.ToSlash(p))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// matchGlob matches a slash-separated path against a glob in which "**"
+// spans any number of directories. "src/**.go" is read as "src/**/*.go".
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		seg := pattern[0]
+		if strings.HasPrefix(seg, "**") {
+			rest := pattern[1:]
+			if tail := strings.TrimPrefix(seg, "**"); tail != "" {
+				// "**.go": any depth, then a file matching "*.go"
+				rest = append([]string{"*" + tail}, rest...)
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(seg, name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
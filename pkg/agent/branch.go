@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// scratchConfigKey is the git config variable, under branch.<name>, holding
+// the branch a scratch branch was started from. Keeping it in the
+// repository lets /branch done work after john restarts.
+const scratchConfigKey = "johnScratchFrom"
+
+// handleBranch handles /branch: start a scratch branch for risky changes,
+// show it, or finish it by merging, squashing, keeping or discarding it
+func (a *Agent) handleBranch(args string) error {
+	if _, err := gitOutput("rev-parse", "--git-dir"); err != nil {
+		return fmt.Errorf("/branch needs a git repository")
+	}
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+	switch sub {
+	case "":
+		return a.showScratchBranch()
+	case "done":
+		return a.finishScratchBranch(rest)
+	default:
+		if rest != "" {
+			return fmt.Errorf("usage: /branch <name> | done [merge|squash|keep|discard]")
+		}
+		return a.startScratchBranch(sub)
+	}
+}
+
+// currentBranch returns the checked out branch, or "" when HEAD is detached
+func currentBranch() string {
+	out, err := gitOutput("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// scratchOrigin returns the branch the scratch branch was started from, or
+// "" when branch is not a scratch branch
+func scratchOrigin(branch string) string {
+	if branch == "" {
+		return ""
+	}
+	out, _ := gitOutput("config", "--get", "branch."+branch+"."+scratchConfigKey)
+	return strings.TrimSpace(out)
+}
+
+// runGit runs a git command that changes the repository, with its output
+// in the error when it fails
+func runGit(args ...string) error {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return nil
+}
+
+// setupScratchBranch picks up a scratch branch left checked out by an
+// earlier session
+func (a *Agent) setupScratchBranch() {
+	branch := currentBranch()
+	if original := scratchOrigin(branch); original != "" {
+		a.setScratchBranchNotice(branch, original)
+		a.ui.Print(fmt.Sprintf("On scratch branch %s (from %s); /branch done merges or discards it.", branch, original))
+	}
+}
+
+func (a *Agent) startScratchBranch(name string) error {
+	original := currentBranch()
+	if original == "" {
+		return fmt.Errorf("HEAD is detached; check out a branch first so /branch done knows where to return")
+	}
+	if from := scratchOrigin(original); from != "" {
+		return fmt.Errorf("already on scratch branch %s (from %s); finish it with /branch done first", original, from)
+	}
+	if err := runGit("check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("%q is not a valid branch name", name)
+	}
+	// A clean start means discarding later removes only the experiment
+	if status, _ := gitOutput("status", "--porcelain"); strings.TrimSpace(status) != "" {
+		return fmt.Errorf("the working tree has uncommitted or untracked changes; commit or stash them (git stash -u) first, so discarding the scratch branch cannot lose them")
+	}
+
+	if err := runGit("switch", "-c", name); err != nil {
+		return err
+	}
+	if err := runGit("config", "branch."+name+"."+scratchConfigKey, original); err != nil {
+		return err
+	}
+	a.setScratchBranchNotice(name, original)
+	a.ui.Print(fmt.Sprintf("Switched to scratch branch %s (from %s). Experiment freely; /branch done merges, squashes, keeps or discards it.", name, original))
+	return nil
+}
+
+func (a *Agent) showScratchBranch() error {
+	branch := currentBranch()
+	original := scratchOrigin(branch)
+	if original == "" {
+		a.ui.Print("Not on a scratch branch. Start one before risky changes with /branch <name>")
+		return nil
+	}
+	a.ui.Print(fmt.Sprintf("On scratch branch %s (from %s): %s", branch, original, scratchSummary(original)))
+	return nil
+}
+
+// scratchSummary describes the commits and uncommitted changes made since
+// the scratch branch left original
+func scratchSummary(original string) string {
+	commits, _ := gitOutput("rev-list", "--count", original+"..HEAD")
+	dirty, _ := gitOutput("status", "--porcelain")
+	n := 0
+	fmt.Sscan(strings.TrimSpace(commits), &n)
+	files := 0
+	if d := strings.TrimSpace(dirty); d != "" {
+		files = len(strings.Split(d, "\n"))
+	}
+	summary := plural(n, "commit") + ", " + plural(files, "uncommitted file")
+	if stat, _ := gitOutput("diff", "--shortstat", original); strings.TrimSpace(stat) != "" {
+		summary += " (" + strings.TrimSpace(stat) + " in all)"
+	}
+	return summary
+}
+
+func (a *Agent) finishScratchBranch(action string) error {
+	branch := currentBranch()
+	original := scratchOrigin(branch)
+	if original == "" {
+		return fmt.Errorf("not on a scratch branch; /branch done finishes one started with /branch <name>")
+	}
+	if action == "" {
+		a.ui.Print(fmt.Sprintf("Scratch branch %s (from %s): %s", branch, original, scratchSummary(original)))
+		answer := strings.ToLower(strings.TrimSpace(a.ui.Prompt(fmt.Sprintf("[m]erge into %s, [s]quash into %s, [k]eep for later, [d]iscard, or cancel? ", original, original))))
+		action = map[string]string{"m": "merge", "s": "squash", "k": "keep", "d": "discard"}[answer]
+		if action == "" {
+			action = answer
+		}
+	}
+
+	switch action {
+	case "merge", "squash", "keep":
+		// Uncommitted work goes with the branch
+		if dirty, _ := gitOutput("status", "--porcelain"); strings.TrimSpace(dirty) != "" {
+			if err := runGit("add", "-A"); err != nil {
+				return err
+			}
+			if err := runGit("commit", "-q", "-m", "Work on scratch branch "+branch); err != nil {
+				return err
+			}
+		}
+		if err := runGit("switch", original); err != nil {
+			return err
+		}
+		switch action {
+		case "merge":
+			if err := runGit("merge", "--no-edit", branch); err != nil {
+				return fmt.Errorf("%w; resolve the conflicts on %s, then delete %s with git branch -d", err, original, branch)
+			}
+			runGit("branch", "-d", branch)
+			a.ui.Print(fmt.Sprintf("Merged %s into %s and deleted it.", branch, original))
+		case "squash":
+			if err := runGit("merge", "--squash", branch); err != nil {
+				return fmt.Errorf("%w; resolve the conflicts on %s, then delete %s with git branch -D", err, original, branch)
+			}
+			runGit("branch", "-D", branch)
+			a.ui.Print(fmt.Sprintf("Squashed %s into %s as staged changes, ready to commit, and deleted it.", branch, original))
+		case "keep":
+			a.ui.Print(fmt.Sprintf("Back on %s. %s is kept; git switch %s to return to it.", original, branch, branch))
+		}
+	case "discard":
+		if err := runGit("reset", "-q", "--hard"); err != nil {
+			return err
+		}
+		if err := runGit("clean", "-q", "-fd"); err != nil {
+			return err
+		}
+		if err := runGit("switch", original); err != nil {
+			return err
+		}
+		if err := runGit("branch", "-D", branch); err != nil {
+			return err
+		}
+		a.ui.Print(fmt.Sprintf("Discarded %s and its changes; back on %s.", branch, original))
+	case "", "c", "cancel":
+		a.ui.Print("Still on " + branch)
+		return nil
+	default:
+		return fmt.Errorf("usage: /branch done [merge|squash|keep|discard]")
+	}
+	a.setScratchBranchNotice("", "")
+	return nil
+}
+
+// setScratchBranchNotice tells the model it is working on a scratch branch,
+// or removes the notice when name is empty
+func (a *Agent) setScratchBranchNotice(name, original string) {
+	if len(a.history) == 0 {
+		return
+	}
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<scratch-branch>"); i >= 0 {
+		end := strings.Index(system[i:], "</scratch-branch>")
+		system = system[:i] + system[i+end+len("</scratch-branch>"):]
+	}
+	if name != "" {
+		system += "\n\n<scratch-branch>\nThe user switched to the scratch git branch " + name + " (from " + original + ") to let you experiment. " +
+			"Changes here can be thrown away, so try the riskier approach when it is the better one, but do not merge, rebase or switch branches yourself; the user finishes the branch with /branch done.\n</scratch-branch>"
+	}
+	a.history[0].Content = system
+}
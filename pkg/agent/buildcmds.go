@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// projectCommandTimeout bounds /build and /test
+const projectCommandTimeout = 15 * time.Minute
+
+// maxProjectOutput is how much of a /build or /test run reaches the model;
+// the end of the output, where failures are summarized, is kept
+const maxProjectOutput = 20000
+
+// npmDefaultTest is the test script npm init writes
+const npmDefaultTest = `echo "Error: no test specified" && exit 1`
+
+var makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*:([^=]|$)`)
+
+// detectProjectCommands guesses the build and test commands from the files
+// in dir. Makefile build and test targets win over language defaults.
+func detectProjectCommands(dir string) config.ProjectSettings {
+	var p config.ProjectSettings
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	hasMakefile := false
+	for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		if targets := makeTargets(filepath.Join(dir, name)); targets != nil {
+			hasMakefile = true
+			if targets["build"] {
+				p.BuildCommand = "make build"
+			}
+			if targets["test"] {
+				p.TestCommand = "make test"
+			}
+		}
+	}
+
+	set := func(build, test string) {
+		if p.BuildCommand == "" {
+			p.BuildCommand = build
+		}
+		if p.TestCommand == "" {
+			p.TestCommand = test
+		}
+	}
+	switch {
+	case exists("go.mod"):
+		set("go build ./...", "go test ./...")
+	case exists("Cargo.toml"):
+		set("cargo build", "cargo test")
+	case exists("package.json"):
+		set(npmScripts(filepath.Join(dir, "package.json")))
+	case exists("pom.xml"):
+		set("mvn -q compile", "mvn -q test")
+	case exists("build.gradle") || exists("build.gradle.kts"):
+		gradle := "gradle"
+		if exists("gradlew") {
+			gradle = "./gradlew"
+		}
+		set(gradle+" build", gradle+" test")
+	case exists("pytest.ini") || exists("tox.ini") || exists("conftest.py") ||
+		((exists("pyproject.toml") || exists("setup.py") || exists("setup.cfg")) && exists("tests")):
+		set("", "pytest")
+	}
+
+	if p.BuildCommand == "" && hasMakefile {
+		p.BuildCommand = "make"
+	}
+	return p
+}
+
+// makeTargets lists the targets defined in a Makefile, or nil if there is none
+func makeTargets(path string) map[string]bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	targets := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if m := makeTargetPattern.FindStringSubmatch(scanner.Text()); m != nil {
+			targets[m[1]] = true
+		}
+	}
+	return targets
+}
+
+// npmScripts returns the npm commands for the build and test scripts that
+// package.json defines
+func npmScripts(path string) (build, test string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return "", ""
+	}
+	if pkg.Scripts["build"] != "" {
+		build = "npm run build"
+	}
+	if t := pkg.Scripts["test"]; t != "" && t != npmDefaultTest {
+		test = "npm test"
+	}
+	return build, test
+}
+
+// projectCommands returns the configured build and test commands
+func (a *Agent) projectCommands() config.ProjectSettings {
+	if a.cfg == nil || a.cfg.Settings == nil {
+		return config.ProjectSettings{}
+	}
+	return a.cfg.Settings.Project
+}
+
+// setupProjectCommands fills in missing build and test commands from the
+// project's files, saves them to the project settings and tells the model
+// about them. Untrusted workspaces are left alone.
+func (a *Agent) setupProjectCommands() {
+	if a.readOnly || a.cfg == nil {
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+
+	current := a.projectCommands()
+	detected := detectProjectCommands(cwd)
+	updated := current
+	if updated.BuildCommand == "" {
+		updated.BuildCommand = detected.BuildCommand
+	}
+	if updated.TestCommand == "" {
+		updated.TestCommand = detected.TestCommand
+	}
+
+	if updated != current {
+		if path, err := config.SaveProjectSettings(updated); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to save detected build and test commands: %v", err))
+		} else {
+			a.ui.Print(fmt.Sprintf("Detected build command %q and test command %q (saved to %s; /build and /test run them)",
+				updated.BuildCommand, updated.TestCommand, path))
+		}
+		if a.cfg.Settings == nil {
+			a.cfg.Settings = &config.Settings{}
+		}
+		a.cfg.Settings.Project = updated
+	}
+	a.setProjectCommandsNotice(updated)
+}
+
+// setProjectCommandsNotice tells the model which commands build and test the
+// project, replacing any earlier notice in the system prompt
+func (a *Agent) setProjectCommandsNotice(p config.ProjectSettings) {
+	if len(a.history) == 0 {
+		return
+	}
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<project-commands>"); i >= 0 {
+		end := strings.Index(system[i:], "</project-commands>")
+		system = system[:i] + system[i+end+len("</project-commands>"):]
+	}
+	if p.BuildCommand != "" || p.TestCommand != "" {
+		system += "\n\n<project-commands>\nUse exactly these commands to build and test this project; do not guess others."
+		if p.BuildCommand != "" {
+			system += "\nBuild: " + p.BuildCommand
+		}
+		if p.TestCommand != "" {
+			system += "\nTest: " + p.TestCommand
+		}
+		system += "\n</project-commands>"
+	}
+	a.history[0].Content = system
+}
+
+// setupProjectCommand handles /build setup and /test setup: ask for the
+// command, save it to the project settings and tell the model
+func (a *Agent) setupProjectCommand(kind string) error {
+	p := a.projectCommands()
+	current := p.BuildCommand
+	if kind == "test" {
+		current = p.TestCommand
+	}
+	prompt := fmt.Sprintf("Command that runs this project's %ss", kind)
+	if kind == "build" {
+		prompt = "Command that builds this project"
+	}
+	if current != "" {
+		prompt += fmt.Sprintf(" (now %q)", current)
+	}
+	command := a.ui.Prompt(prompt + ": ")
+	if command == "" || command == "exit" {
+		a.ui.Print("Unchanged")
+		return nil
+	}
+	if kind == "test" {
+		p.TestCommand = command
+	} else {
+		p.BuildCommand = command
+	}
+	path, err := config.SaveProjectSettings(p)
+	if err != nil {
+		return err
+	}
+	if a.cfg.Settings == nil {
+		a.cfg.Settings = &config.Settings{}
+	}
+	a.cfg.Settings.Project = p
+	a.setProjectCommandsNotice(p)
+	a.ui.Print(fmt.Sprintf("Saved %s command %q to %s; /%s runs it", kind, command, path, kind))
+	return nil
+}
+
+// runProjectCommand handles /build and /test: run the project's command with
+// any extra arguments, stream its output and add the result to the
+// conversation so the model can act on it
+func (a *Agent) runProjectCommand(kind, args string) error {
+	if a.readOnly {
+		return fmt.Errorf("this workspace is not trusted; run /trust first")
+	}
+	if strings.TrimSpace(args) == "setup" {
+		return a.setupProjectCommand(kind)
+	}
+	p := a.projectCommands()
+	command := p.BuildCommand
+	if kind == "test" {
+		command = p.TestCommand
+	}
+	if command == "" {
+		return fmt.Errorf("no %s command found; set one with /%s setup", kind, kind)
+	}
+	if args = strings.TrimSpace(args); args != "" {
+		command += " " + args
+	}
+
+	a.ui.Print(fmt.Sprintf("$ %s", command))
+	ctx, cancel := context.WithTimeout(context.Background(), projectCommandTimeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
+	cmd.Stderr = cmd.Stdout
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := formatDuration(time.Since(start))
+
+	status := "passed"
+	if err != nil {
+		status = fmt.Sprintf("failed (%v)", err)
+		if ctx.Err() == context.DeadlineExceeded {
+			status = fmt.Sprintf("timed out after %s", projectCommandTimeout)
+		}
+	}
+	a.ui.Print(fmt.Sprintf("%s %s in %s", strings.ToUpper(kind[:1])+kind[1:], status, elapsed))
+
+	out := output.String()
+	if len(out) > maxProjectOutput {
+		out = "...[earlier output truncated]...\n" + out[len(out)-maxProjectOutput:]
+	}
+	a.appendExchange(
+		fmt.Sprintf("<%s-result>\nI ran the project's %s command: %s\nResult: %s in %s\n\n%s\n</%s-result>", kind, kind, command, status, elapsed, out, kind),
+		fmt.Sprintf("Noted the %s result.", kind),
+	)
+	return nil
+}
+
+// appendExchange adds a user message and a short assistant reply to the
+// conversation, so the next prompt still follows an assistant turn
+func (a *Agent) appendExchange(user, assistant string) {
+	msgs := []llm.Message{
+		{Role: llm.RoleUser, Content: user},
+		{Role: llm.RoleAssistant, Content: assistant},
+	}
+	for _, msg := range msgs {
+		a.history = append(a.history, msg)
+		if a.session != nil {
+			if err := a.session.Append(msg.Role, msg); err != nil {
+				a.ui.Print(fmt.Sprintf("Warning: Failed to log message: %v", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// Overridden by tests
+var (
+	geteuid     = os.Geteuid
+	inContainer = config.InContainer
+)
+
+// EnableSkipPermissions turns off permission prompts for this session
+// (--dangerously-skip-permissions), for disposable containers and CI. Deny
+// rules and the managed policy still apply. It refuses to run as root outside
+// a container unless $JOHN_ALLOW_ROOT is 1, and the first time it is used the
+// user has to confirm it.
+func (a *Agent) EnableSkipPermissions() error {
+	policy := a.policy()
+	if policy.DisableSkipPermissions {
+		return fmt.Errorf("--dangerously-skip-permissions is disabled by the managed policy")
+	}
+	if policy.PermissionMode != "" {
+		return fmt.Errorf("--dangerously-skip-permissions cannot be used while the managed policy sets permissionMode %q", policy.PermissionMode)
+	}
+	if geteuid() == 0 && !inContainer() && os.Getenv(config.AllowRootEnv) != "1" {
+		return fmt.Errorf("--dangerously-skip-permissions refuses to run as root outside a container, where the agent could change anything on the machine; run it in a container or as another user, or set %s=1 to force it", config.AllowRootEnv)
+	}
+
+	if !config.SkipPermissionsAccepted() {
+		if os.Getenv(config.SkipPermissionsAcceptEnv) != "1" {
+			a.ui.Print("\nWARNING: --dangerously-skip-permissions runs every command and edit the model asks for without asking you first.")
+			a.ui.Print("Only use it in a container or VM you can throw away, with no credentials or network access you would not give the model.")
+			answer := a.ui.Prompt("Type 'yes' to accept this, now and on later runs: ")
+			if strings.ToLower(strings.TrimSpace(answer)) != "yes" {
+				return fmt.Errorf("--dangerously-skip-permissions was not accepted")
+			}
+		}
+		if err := config.AcceptSkipPermissions(); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to save the acceptance: %v", err))
+		}
+	}
+	a.cfg.SkipPermissions = true
+	return nil
+}
+
+// skipPermissions reports whether permission prompts are off
+func (a *Agent) skipPermissions() bool {
+	return a.cfg != nil && a.cfg.SkipPermissions
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/commands"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// commandScope holds what a custom command's frontmatter changed for one
+// turn: the tools it allows and the model it runs with
+type commandScope struct {
+	command    string
+	tools      []string // Names, or prefixes ending in *; empty allows every tool
+	prevModel  string   // Model and client to restore, when the command switched
+	prevClient llm.Client
+}
+
+// allowsTool reports whether the turn's command lets the model use a tool.
+// Without a scope every tool is allowed.
+func (s *commandScope) allowsTool(name string) bool {
+	if s == nil || len(s.tools) == 0 {
+		return true
+	}
+	for _, pattern := range s.tools {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// beginCommandScope applies a command's allowed-tools and model until
+// endCommandScope. Unknown tools and models are refused before the turn
+// starts so a typo does not silently run with everything.
+func (a *Agent) beginCommandScope(sc commands.ScopedCommand) error {
+	scope := &commandScope{command: sc.Name()}
+	for _, name := range sc.AllowedTools() {
+		if _, ok := a.tools.Get(name); !ok && !strings.HasSuffix(name, "*") {
+			return fmt.Errorf("/%s allows the unknown tool %q", sc.Name(), name)
+		}
+		scope.tools = append(scope.tools, name)
+	}
+	if name := sc.Model(); name != "" {
+		model, err := a.resolveModel(name)
+		if err != nil {
+			return fmt.Errorf("/%s: %w", sc.Name(), err)
+		}
+		if model.ID != a.currentModel {
+			scope.prevModel, scope.prevClient = a.currentModel, a.client
+			a.currentModel = model.ID
+			a.client = a.createClientForModel(model.ID)
+		}
+	}
+	if len(scope.tools) > 0 || scope.prevClient != nil {
+		a.commandScope = scope
+	}
+	return nil
+}
+
+// endCommandScope restores the tools and model from before the command
+func (a *Agent) endCommandScope() {
+	scope := a.commandScope
+	if scope == nil {
+		return
+	}
+	a.commandScope = nil
+	if scope.prevClient != nil {
+		a.currentModel, a.client = scope.prevModel, scope.prevClient
+	}
+}
+
+// checkCommandScope blocks tools outside the running command's
+// allowed-tools. They are not offered, but the model may still ask.
+func (a *Agent) checkCommandScope(toolName string) (string, bool) {
+	if a.commandScope.allowsTool(toolName) {
+		return "", true
+	}
+	return fmt.Sprintf("Error: /%s only allows %s. Do not retry %s; work with those tools or tell the user what else is needed.",
+		a.commandScope.command, strings.Join(a.commandScope.tools, ", "), toolName), false
+}
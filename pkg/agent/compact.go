@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// compactKeepRecent is the number of most recent messages compaction leaves
+// untouched, so the current line of work keeps its full detail.
+const compactKeepRecent = 6
+
+// pin is content that compaction must carry over verbatim
+type pin struct {
+	source  string // Where the content came from, shown in /pin list
+	content string
+}
+
+// savedPin is a pin as dumps, saved contexts and session logs store it
+type savedPin struct {
+	Source  string `json:"source"`
+	Content string `json:"content"`
+}
+
+// pinContent protects content from compaction. Pinning the same content twice
+// is a no-op.
+func (a *Agent) pinContent(source, content string) {
+	for _, p := range a.pins {
+		if p.content == content {
+			return
+		}
+	}
+	a.pins = append(a.pins, pin{source: source, content: content})
+	a.pinsChanged()
+}
+
+// pinsChanged records the pins in the session, so resuming it keeps them
+func (a *Agent) pinsChanged() {
+	if a.session == nil {
+		return
+	}
+	if err := a.session.AppendPins(a.savedPins()); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: Failed to log pins: %v", err))
+	}
+}
+
+func (a *Agent) savedPins() []savedPin {
+	pins := make([]savedPin, 0, len(a.pins))
+	for _, p := range a.pins {
+		pins = append(pins, savedPin{Source: p.source, Content: p.content})
+	}
+	return pins
+}
+
+// restorePins replaces the pins with saved ones
+func (a *Agent) restorePins(saved []savedPin) {
+	a.pins = nil
+	for _, p := range saved {
+		a.pins = append(a.pins, pin{source: p.Source, content: p.Content})
+	}
+}
+
+// handlePin handles /pin
+func (a *Agent) handlePin(args string) error {
+	switch {
+	case args == "":
+		for i := len(a.history) - 1; i > 0; i-- {
+			if msg := a.history[i]; msg.Role == llm.RoleAssistant && strings.TrimSpace(msg.Content) != "" {
+				a.pinContent("assistant response", msg.Content)
+				a.ui.Print(fmt.Sprintf("Pinned the latest response (%d pins)", len(a.pins)))
+				return nil
+			}
+		}
+		return fmt.Errorf("no assistant response to pin yet; use /pin <note> to pin text")
+
+	case args == "list":
+		if len(a.pins) == 0 {
+			a.ui.Print("Nothing is pinned")
+			return nil
+		}
+		a.ui.Print("Pinned content:")
+		for i, p := range a.pins {
+			a.ui.Print(fmt.Sprintf("  %d. [%s] %s", i+1, p.source, truncate(strings.ReplaceAll(p.content, "\n", " "), 100)))
+		}
+		return nil
+
+	case strings.HasPrefix(args, "remove "):
+		n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(args, "remove ")))
+		if err != nil || n < 1 || n > len(a.pins) {
+			return fmt.Errorf("usage: /pin remove <n>, where n is a number from /pin list")
+		}
+		a.pins = append(a.pins[:n-1], a.pins[n:]...)
+		a.pinsChanged()
+		a.ui.Print(fmt.Sprintf("Unpinned #%d", n))
+		return nil
+
+	default:
+		a.pinContent("note", args)
+		a.ui.Print(fmt.Sprintf("Pinned note (%d pins)", len(a.pins)))
+		return nil
+	}
+}
+
+// compact replaces all but the most recent messages with a model-written
+// summary. Pinned content is appended to the summary verbatim, so it survives
+// any number of compactions.
+func (a *Agent) compact(instructions string) error {
+	boundary := a.compactBoundary()
+	if boundary <= 1 {
+		a.ui.Print("Nothing to compact yet")
+		return nil
+	}
+
+	var transcript strings.Builder
+	for _, msg := range a.history[1:boundary] {
+		writeCompactTranscript(&transcript, msg)
+	}
+
+	prompt := "Summarize the conversation below so the work can continue without it. Keep the user's goals and " +
+		"requirements, decisions made, files created or changed, commands that matter, errors and how they were " +
+		"resolved, and any open tasks. Be concise and factual."
+	if instructions != "" {
+		prompt += "\n\nAdditional instructions: " + instructions
+	}
+
+	a.ui.Print(fmt.Sprintf("Compacting %d messages...", boundary-1))
+	resp, err := a.client.Generate(context.Background(), []llm.Message{
+		{Role: llm.RoleSystem, Content: "You write handoff summaries of coding sessions."},
+		{Role: llm.RoleUser, Content: prompt + "\n\n<conversation>\n" + transcript.String() + "</conversation>"},
+	}, nil)
+	a.recordCost(costCompaction, a.currentModel, resp)
+	if err != nil {
+		return fmt.Errorf("compaction failed: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return fmt.Errorf("compaction failed: empty summary")
+	}
+
+	var content strings.Builder
+	content.WriteString("This session continues an earlier conversation that was compacted. Summary of the earlier conversation:\n\n")
+	content.WriteString(summary)
+	if len(a.pins) > 0 {
+		content.WriteString("\n\n" + pinnedMarker + "\nThe following was pinned and is reproduced verbatim:\n")
+		for _, p := range a.pins {
+			content.WriteString(fmt.Sprintf("\n--- %s ---\n%s\n", p.source, p.content))
+		}
+	}
+
+	compacted := []llm.Message{
+		a.history[0],
+		{Role: llm.RoleUser, Content: content.String()},
+		{Role: llm.RoleAssistant, Content: "Understood. I'll continue from this summary."},
+	}
+	kept := len(a.history) - boundary
+	a.history = append(compacted, a.history[boundary:]...)
+	if a.session != nil {
+		c := history.Compaction{Summary: compacted[1].Content, Reply: compacted[2].Content, Kept: kept}
+		if err := a.session.AppendCompaction(c, a.savedPins()); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to log compaction: %v", err))
+		}
+	}
+	a.meter.at = 0 // The provider's count is for the old history
+	a.injections.forget()
+
+	a.ui.Print(fmt.Sprintf("Compacted %d messages into a summary (%d pinned items kept)", boundary-1, len(a.pins)))
+	return nil
+}
+
+// pinnedMarker separates the summary from the pinned content in a compaction
+// message, so a later compaction does not summarize the pins a second time.
+const pinnedMarker = "<pinned>"
+
+// compactBoundary returns the index of the first message to keep. It keeps at
+// least compactKeepRecent messages and starts at a user prompt, so no tool
+// result is separated from the call that produced it.
+func (a *Agent) compactBoundary() int {
+	for i := len(a.history) - compactKeepRecent; i > 1; i-- {
+		if msg := a.history[i]; msg.Role == llm.RoleUser && msg.ToolResult == nil {
+			return i
+		}
+	}
+	return 0
+}
+
+func writeCompactTranscript(sb *strings.Builder, msg llm.Message) {
+	switch msg.Role {
+	case llm.RoleUser:
+		content := msg.Content
+		if i := strings.Index(content, pinnedMarker); i >= 0 {
+			content = content[:i] // Pins are re-added verbatim
+		}
+		sb.WriteString("[user]\n" + content + "\n\n")
+	case llm.RoleAssistant:
+		if msg.Content != "" {
+			sb.WriteString("[assistant]\n" + msg.Content + "\n\n")
+		}
+		for _, tc := range msg.ToolCalls {
+			sb.WriteString(fmt.Sprintf("[tool call] %s %v\n\n", tc.Name, tc.Args))
+		}
+	case llm.RoleTool:
+		if msg.ToolResult != nil {
+			sb.WriteString(fmt.Sprintf("[tool result: %s]\n%s\n\n", msg.ToolResult.ToolName, truncate(msg.ToolResult.Content, 2000)))
+		}
+	}
+}
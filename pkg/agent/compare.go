@@ -0,0 +1,312 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// CompareOptions configures john compare. Each run has a model (empty for
+// the configured one) and a settings file merged over the usual ones.
+type CompareOptions struct {
+	Prompt   string
+	Models   [2]string
+	Settings [2]string
+	Keep     bool // Leave the worktrees in place to inspect the changes
+}
+
+// Comparison is the outcome of running one prompt twice. The JSON output of
+// john compare is this as is.
+type Comparison struct {
+	Prompt string       `json:"prompt"`
+	Base   string       `json:"base"` // Commit both runs started from
+	Runs   []CompareRun `json:"runs"`
+}
+
+// CompareRun is what one run of the prompt did
+type CompareRun struct {
+	Label        string         `json:"label"` // "A" or "B"
+	Model        string         `json:"model"`
+	Settings     string         `json:"settings,omitempty"`
+	Response     string         `json:"response"`
+	Error        string         `json:"error,omitempty"`
+	Duration     string         `json:"duration"`
+	ModelTurns   int            `json:"modelTurns"`
+	ToolCalls    int            `json:"toolCalls"`
+	ToolErrors   int            `json:"toolErrors"`
+	Tools        map[string]int `json:"tools"` // Calls per tool
+	InputTokens  int            `json:"inputTokens"`
+	OutputTokens int            `json:"outputTokens"`
+	CostUSD      float64        `json:"costUsd"`
+	FilesChanged []string       `json:"filesChanged"` // git status letter and path, e.g. "M main.go"
+	DiffStat     string         `json:"diffStat,omitempty"`
+	Worktree     string         `json:"worktree,omitempty"` // Set when kept
+}
+
+// RunCompare runs the prompt headless once per model or settings file, each
+// in its own git worktree of the current state of the repository, so the
+// runs cannot see each other's changes, and returns what each did. The runs
+// go one after the other. Progress goes to u.
+func RunCompare(cfg *config.Config, u *ui.UI, opts CompareOptions) (*Comparison, error) {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("john compare needs a git repository, to give each run its own copy")
+	}
+	root = strings.TrimSpace(root)
+	prefix, _ := gitOutput("rev-parse", "--show-prefix")
+	prefix = strings.TrimSpace(prefix)
+
+	// git stash create records uncommitted changes without touching the tree
+	base, _ := gitOutput("stash", "create")
+	if base = strings.TrimSpace(base); base == "" {
+		if dirty, _ := gitOutput("status", "--porcelain", "--untracked-files=no"); dirty != "" {
+			u.Print("Note: could not record the uncommitted changes; both runs start from HEAD.")
+		}
+		if base, err = gitOutput("rev-parse", "HEAD"); err != nil {
+			return nil, fmt.Errorf("john compare needs at least one commit")
+		}
+		base = strings.TrimSpace(base)
+	}
+	if untracked, _ := gitOutput("ls-files", "--others", "--exclude-standard", "--", root); strings.TrimSpace(untracked) != "" {
+		u.Print("Note: untracked files are not copied into the runs' worktrees; commit or git add them to include them.")
+	}
+
+	// Settings are read here, where the project's settings and trust apply
+	settings := make([]*config.Settings, 2)
+	for i, file := range opts.Settings {
+		if settings[i], err = config.LoadSettingsWith(file); err != nil {
+			return nil, fmt.Errorf("settings for run %s: %w", compareLabels[i], err)
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "john-compare-")
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	var worktrees []string
+	defer func() {
+		os.Chdir(cwd)
+		if opts.Keep {
+			return
+		}
+		for _, dir := range worktrees {
+			exec.Command("git", "-C", root, "worktree", "remove", "--force", dir).Run()
+		}
+		os.RemoveAll(tmp)
+	}()
+
+	c := &Comparison{Prompt: opts.Prompt, Base: base}
+	for i, label := range compareLabels {
+		dir := filepath.Join(tmp, strings.ToLower(label))
+		if out, err := exec.Command("git", "-C", root, "worktree", "add", "--detach", dir, base).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("git worktree add failed: %s", strings.TrimSpace(string(out)))
+		}
+		worktrees = append(worktrees, dir)
+
+		runCfg := *cfg
+		runCfg.Settings = settings[i]
+		runCfg.Ephemeral = true
+		if err := config.ConfigureHTTP(runCfg.Settings); err != nil {
+			return nil, fmt.Errorf("settings for run %s: %w", label, err)
+		}
+		if err := os.Chdir(filepath.Join(dir, prefix)); err != nil {
+			return nil, err
+		}
+
+		a := New(&runCfg, ui.NewScripted(strings.NewReader(""), &bytes.Buffer{}))
+		if opts.Models[i] != "" {
+			if err := a.switchModel(opts.Models[i]); err != nil {
+				return nil, fmt.Errorf("model for run %s: %w", label, err)
+			}
+		}
+		if _, isMock := a.client.(*llm.MockClient); isMock {
+			return nil, fmt.Errorf("no API key for %s", a.currentModel)
+		}
+		a.headless = true
+
+		u.Print(fmt.Sprintf("Running %s with %s...", label, a.CurrentModelName()))
+		run := a.runComparison(label, opts.Prompt, dir)
+		run.Settings = opts.Settings[i]
+		if opts.Keep {
+			run.Worktree = dir
+		}
+		u.Print(fmt.Sprintf("%s finished in %s: %s, %s, ~$%.2f", label, run.Duration, plural(run.ToolCalls, "tool call"), plural(len(run.FilesChanged), "file"), run.CostUSD))
+		c.Runs = append(c.Runs, run)
+	}
+	return c, nil
+}
+
+var compareLabels = [2]string{"A", "B"}
+
+// runComparison sends the prompt and describes what the run did, with the
+// changes it left in the git worktree at dir
+func (a *Agent) runComparison(label, prompt, dir string) CompareRun {
+	start := time.Now()
+	err := a.RunPrompt(prompt)
+
+	r := a.buildReport("")
+	run := CompareRun{
+		Label:        label,
+		Model:        a.CurrentModelName(),
+		Duration:     formatDuration(time.Since(start)),
+		ModelTurns:   r.ModelTurns,
+		ToolCalls:    r.ToolCalls,
+		ToolErrors:   r.ToolErrors,
+		Tools:        make(map[string]int),
+		InputTokens:  r.InputTokens,
+		OutputTokens: r.OutputTokens,
+		CostUSD:      r.CostUSD,
+		FilesChanged: []string{},
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	for _, e := range a.timeline {
+		if e.kind == timelineTool {
+			run.Tools[e.label]++
+		}
+	}
+	for i := len(a.history) - 1; i >= 0; i-- {
+		if msg := a.history[i]; msg.Role == llm.RoleAssistant && strings.TrimSpace(msg.Content) != "" {
+			run.Response = strings.TrimSpace(msg.Content)
+			break
+		}
+	}
+
+	// Staging in the throwaway worktree shows new files as well
+	if exec.Command("git", "-C", dir, "add", "-A").Run() == nil {
+		if out, err := exec.Command("git", "-C", dir, "diff", "--cached", "--name-status", "--no-renames").Output(); err == nil {
+			for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+				if status, file, ok := strings.Cut(line, "\t"); ok {
+					run.FilesChanged = append(run.FilesChanged, status+" "+file)
+				}
+			}
+		}
+		if out, err := exec.Command("git", "-C", dir, "diff", "--cached", "--shortstat").Output(); err == nil {
+			run.DiffStat = strings.TrimSpace(string(out))
+		}
+	}
+	return run
+}
+
+// Markdown renders the comparison as a report with the two runs side by side
+func (c *Comparison) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# Comparison\n\n")
+	fmt.Fprintf(&b, "Prompt: %s\n\nBoth runs started from %s.\n\n", firstLineOf(c.Prompt, 200), shortHash(c.Base))
+
+	row := func(name string, value func(r CompareRun) string) {
+		b.WriteString("| " + name)
+		for _, r := range c.Runs {
+			b.WriteString(" | " + strings.ReplaceAll(value(r), "|", `\|`))
+		}
+		b.WriteString(" |\n")
+	}
+	b.WriteString("|")
+	for _, r := range c.Runs {
+		b.WriteString(" | " + r.Label)
+	}
+	b.WriteString(" |\n|---")
+	for range c.Runs {
+		b.WriteString("|---")
+	}
+	b.WriteString("|\n")
+	row("Model", func(r CompareRun) string { return r.Model })
+	row("Settings", func(r CompareRun) string { return orDash(r.Settings) })
+	row("Result", func(r CompareRun) string {
+		if r.Error != "" {
+			return "failed: " + firstLineOf(r.Error, 80)
+		}
+		return "finished"
+	})
+	row("Duration", func(r CompareRun) string { return r.Duration })
+	row("Model turns", func(r CompareRun) string { return fmt.Sprint(r.ModelTurns) })
+	row("Tool calls", func(r CompareRun) string {
+		if r.ToolErrors > 0 {
+			return fmt.Sprintf("%d (%d failed)", r.ToolCalls, r.ToolErrors)
+		}
+		return fmt.Sprint(r.ToolCalls)
+	})
+	row("Tokens", func(r CompareRun) string {
+		return formatTokens(r.InputTokens) + " in / " + formatTokens(r.OutputTokens) + " out"
+	})
+	row("Cost", func(r CompareRun) string { return fmt.Sprintf("~$%.2f", r.CostUSD) })
+	row("Files changed", func(r CompareRun) string { return fmt.Sprint(len(r.FilesChanged)) })
+	row("Diff", func(r CompareRun) string { return orDash(r.DiffStat) })
+
+	names := make(map[string]bool)
+	for _, r := range c.Runs {
+		for name := range r.Tools {
+			names[name] = true
+		}
+	}
+	if len(names) > 0 {
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		b.WriteString("\n## Tool calls\n\n| Tool")
+		for _, r := range c.Runs {
+			b.WriteString(" | " + r.Label)
+		}
+		b.WriteString(" |\n|---")
+		for range c.Runs {
+			b.WriteString("|---")
+		}
+		b.WriteString("|\n")
+		for _, name := range sorted {
+			row(name, func(r CompareRun) string { return fmt.Sprint(r.Tools[name]) })
+		}
+	}
+
+	for _, r := range c.Runs {
+		fmt.Fprintf(&b, "\n## %s: %s\n\n", r.Label, r.Model)
+		if len(r.FilesChanged) > 0 {
+			b.WriteString("Files changed:\n\n")
+			for _, f := range r.FilesChanged {
+				b.WriteString("- `" + f + "`\n")
+			}
+			b.WriteString("\n")
+		}
+		if r.Worktree != "" {
+			fmt.Fprintf(&b, "Worktree: `%s`\n\n", r.Worktree)
+		}
+		if r.Error != "" {
+			fmt.Fprintf(&b, "Error: %s\n\n", r.Error)
+		}
+		if r.Response != "" {
+			b.WriteString(r.Response + "\n")
+		} else {
+			b.WriteString("(no response)\n")
+		}
+	}
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// confinedPathArgs are the path arguments of the file tools that a confined
+// agent checks. Glob's pattern is checked up to its first wildcard.
+var confinedPathArgs = map[string]string{
+	"Read":         "file_path",
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"NotebookEdit": "notebook_path",
+	"Grep":         "path",
+	"Glob":         "pattern",
+}
+
+// checkConfined keeps the file tools of an agent confined to a directory,
+// as in john slack, inside it: paths outside, or symlinks leading out, are
+// refused
+func (a *Agent) checkConfined(tc llm.ToolCall) (string, bool) {
+	key, ok := confinedPathArgs[tc.Name]
+	if a.confineTo == "" || !ok {
+		return "", true
+	}
+	path, _ := tc.Args[key].(string)
+	if tc.Name == "Glob" {
+		path = globBase(path)
+	}
+	if path == "" || withinDir(a.confineTo, path) {
+		return "", true
+	}
+	return fmt.Sprintf("Error: %s is outside the project directory, and only files in it can be used here. Do not retry with other paths outside it.", path), false
+}
+
+// globBase returns the directory part of a glob pattern before its first
+// wildcard, e.g. "src" for "src/**/*.go"
+func globBase(pattern string) string {
+	i := strings.IndexAny(pattern, "*?[{")
+	if i < 0 {
+		return pattern
+	}
+	return filepath.Dir(pattern[:i] + "x")
+}
+
+// withinDir reports whether path, relative to root unless absolute, is root
+// or below it once symlinks are resolved. For paths that do not exist yet
+// the deepest existing parent is resolved.
+func withinDir(root, path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(resolvedRoot, resolveExisting(filepath.Clean(path)))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveExisting resolves the symlinks of the longest existing prefix of
+// path and appends the rest
+func resolveExisting(path string) string {
+	rest := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// resolveEditConflict asks what to do with an Edit whose file changed on
+// disk near the edited text since the model read it. It shows the three
+// sides: the file as the model saw it, what changed on disk since, and the
+// model's edit. Headless runs keep the file, and the model is told.
+func (a *Agent) resolveEditConflict(ctx context.Context, c *tools.EditConflict) tools.ConflictChoice {
+	if a.headless {
+		return tools.ConflictKeep
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s changed on disk since the model last read it, where it wants to edit.\n\n", c.Path)
+	fmt.Fprintf(&sb, "Changed on disk since the model read it:\n%s\n", strings.TrimRight(c.DiskChanges(), "\n"))
+	fmt.Fprintf(&sb, "The model's edit, of the file as it read it:\n%s\n", strings.TrimRight(c.Proposed(), "\n"))
+	if c.Applied != "" {
+		sb.WriteString("  a  Apply the edit to the file as it is now, keeping both changes\n")
+	}
+	sb.WriteString("  o  Overwrite with the model's version, losing the changes on disk\n")
+	sb.WriteString("  k  Keep the file as it is; the model is told to read it again")
+	a.ui.Print(sb.String())
+
+	for {
+		if ctx.Err() != nil {
+			return tools.ConflictKeep
+		}
+		switch strings.ToLower(strings.TrimSpace(a.ui.Prompt(i18n.T("Resolve the conflict? [a/o/K] ")))) {
+		case "a", "apply":
+			if c.Applied != "" {
+				return tools.ConflictApply
+			}
+			a.ui.Print("The edited text is no longer in the file once; choose o or k.")
+		case "o", "overwrite":
+			return tools.ConflictOverwrite
+		case "", "k", "keep", "exit":
+			return tools.ConflictKeep
+		default:
+			a.ui.Print("Answer a, o or k.")
+		}
+	}
+}
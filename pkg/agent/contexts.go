@@ -0,0 +1,248 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/i18n"
+)
+
+// contextFileMaxBytes caps each file loaded from a saved context
+const contextFileMaxBytes = 32 * 1024
+
+var contextNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// savedContext is the curated context of a session, stored by /context save.
+// Memory and files are stored as paths and re-read on load, so a context
+// always brings in their current contents.
+type savedContext struct {
+	Name    string     `json:"name"`
+	Created time.Time  `json:"created"`
+	CWD     string     `json:"cwd"`
+	Pins    []savedPin `json:"pins,omitempty"`
+	Memory  []string   `json:"memory,omitempty"` // Directories with nested instruction files
+	Files   []string   `json:"files,omitempty"`  // Files read or attached in the session
+}
+
+// contextsDir is where named contexts are stored; they are shared by all
+// projects
+func contextsDir() (string, error) {
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "contexts"), nil
+}
+
+func contextPath(name string) (string, error) {
+	if !contextNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid context name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	dir, err := contextsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// handleContext handles /context save|load|list|delete
+func (a *Agent) handleContext(args string) error {
+	sub, name, _ := strings.Cut(strings.TrimSpace(args), " ")
+	name = strings.TrimSpace(name)
+	switch sub {
+	case "save":
+		return a.saveContext(name)
+	case "load":
+		return a.loadContext(name)
+	case "delete":
+		path, err := contextPath(name)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("no saved context named %q", name)
+			}
+			return err
+		}
+		a.ui.Print(fmt.Sprintf("Deleted context %q", name))
+		return nil
+	case "", "list":
+		return a.listContexts()
+	default:
+		return fmt.Errorf("usage: /context save <name> | load <name> | delete <name> | list")
+	}
+}
+
+func (a *Agent) saveContext(name string) error {
+	path, err := contextPath(name)
+	if err != nil {
+		return err
+	}
+
+	cwd, _ := os.Getwd()
+	ctx := savedContext{Name: name, Created: time.Now(), CWD: cwd, Files: a.contextFiles()}
+	for _, p := range a.pins {
+		// Nested instruction files are saved as directories below
+		if filepath.IsAbs(p.source) {
+			continue
+		}
+		ctx.Pins = append(ctx.Pins, savedPin{Source: p.source, Content: p.content})
+	}
+	for dir := range a.loadedMemory {
+		ctx.Memory = append(ctx.Memory, dir)
+	}
+	sort.Strings(ctx.Memory)
+
+	if len(ctx.Pins)+len(ctx.Memory)+len(ctx.Files) == 0 {
+		return fmt.Errorf("nothing to save yet: pin content with /pin or read some files first")
+	}
+
+	data, err := json.MarshalIndent(ctx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create contexts dir: %w", err)
+	}
+	if err := config.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save context: %w", err)
+	}
+	a.ui.Print(fmt.Sprintf("Saved context %q: %d pins, %d instruction dirs, %d files", name, len(ctx.Pins), len(ctx.Memory), len(ctx.Files)))
+	return nil
+}
+
+// contextFiles lists the files read or attached so far, in first-use order
+func (a *Agent) contextFiles() []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	for _, msg := range a.history {
+		for _, img := range msg.Images {
+			add(img)
+		}
+		for _, tc := range msg.ToolCalls {
+			if tc.Name == "Read" {
+				path, _ := tc.Args["file_path"].(string)
+				add(path)
+			}
+		}
+	}
+	for _, f := range a.loadedFiles {
+		add(f)
+	}
+	return files
+}
+
+func (a *Agent) loadContext(name string) error {
+	path, err := contextPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no saved context named %q (see /context list)", name)
+		}
+		return err
+	}
+	var ctx savedContext
+	if err := json.Unmarshal(data, &ctx); err != nil {
+		return fmt.Errorf("failed to parse context %q: %w", name, err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<system-reminder>\nThe user loaded the saved context %q. Use it as background for the work that follows.\n", name))
+	for _, p := range ctx.Pins {
+		a.pinContent(p.Source, p.Content)
+		sb.WriteString(fmt.Sprintf("\n<pinned source=%q>\n%s\n</pinned>\n", p.Source, p.Content))
+	}
+	for _, dir := range ctx.Memory {
+		file := findMemoryFile(dir)
+		if file == "" {
+			continue
+		}
+		mem, err := a.loadMemory(file)
+		if err != nil {
+			continue
+		}
+		if a.loadedMemory == nil {
+			a.loadedMemory = make(map[string]bool)
+		}
+		a.loadedMemory[dir] = true
+		reminder := fmt.Sprintf("Contents of %s (directory instructions for work in %s/):\n\n%s", file, dir, strings.TrimSpace(mem.content))
+		a.pinContent(file, reminder)
+		sb.WriteString("\n" + reminder + "\n")
+	}
+	var missing []string
+	loaded := 0
+	for _, file := range ctx.Files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			missing = append(missing, file)
+			continue
+		}
+		text := string(content)
+		if len(text) > contextFileMaxBytes {
+			text = text[:contextFileMaxBytes] + "\n... (truncated; use Read for the rest)"
+		}
+		sb.WriteString(fmt.Sprintf("\n<file path=%q>\n%s\n</file>\n", file, text))
+		a.loadedFiles = append(a.loadedFiles, file)
+		loaded++
+	}
+	sb.WriteString("</system-reminder>")
+
+	a.appendExchange(sb.String(), fmt.Sprintf("Loaded the %q context.", name))
+
+	a.ui.Print(fmt.Sprintf("Loaded context %q: %d pins, %d instruction dirs, %d files (~%d tokens)",
+		name, len(ctx.Pins), len(ctx.Memory), loaded, estimateTokens(sb.String())))
+	if len(missing) > 0 {
+		a.ui.Print(fmt.Sprintf("Skipped files that no longer exist: %s", strings.Join(missing, ", ")))
+	}
+	return nil
+}
+
+func (a *Agent) listContexts() error {
+	dir, err := contextsDir()
+	if err != nil {
+		return err
+	}
+	entries, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(entries) == 0 {
+		a.ui.Print("No saved contexts. Save one with /context save <name>")
+		return nil
+	}
+	a.ui.Print("Saved contexts:")
+	for _, path := range entries {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var ctx savedContext
+		if err := json.Unmarshal(data, &ctx); err != nil {
+			continue
+		}
+		a.ui.Print(fmt.Sprintf("  %-20s %d pins, %d instruction dirs, %d files  (saved %s in %s)",
+			ctx.Name, len(ctx.Pins), len(ctx.Memory), len(ctx.Files), i18n.FormatDate(ctx.Created), ctx.CWD))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// contextAlertLevels are the shares of the model's context window, in
+// percent, at which the user is warned before the next prompt. At the last
+// level the agent offers to compact.
+var contextAlertLevels = []int{70, 90}
+
+// compactSummaryTokens is roughly what a compaction summary costs, deducted
+// from the tokens it removes when estimating the savings
+const compactSummaryTokens = 2000
+
+// imageTokens is a rough cost of an attached image
+const imageTokens = 1600
+
+// contextMeter tracks how much of the context window the conversation uses
+type contextMeter struct {
+	tokens  int // Tokens the provider counted for history[:at]
+	at      int // History length tokens covers; 0 when not measured
+	alerted int // Highest alert level shown since usage was last below it
+}
+
+// measured records the provider's token count for a request and its
+// response, which together are the first n messages of the history
+func (m *contextMeter) measured(usage *llm.Usage, n int) {
+	if usage == nil || usage.InputTokens == 0 {
+		return
+	}
+	m.tokens = usage.InputTokens + usage.OutputTokens
+	m.at = n
+}
+
+// contextTokens estimates the tokens the next request will send: the
+// provider's count for the last request plus an estimate of the messages
+// added since. Without a usable count the whole history is estimated.
+func (a *Agent) contextTokens() int {
+	m := a.meter
+	if m.at == 0 || m.at > len(a.history) {
+		return estimateMessages(a.history)
+	}
+	return m.tokens + estimateMessages(a.history[m.at:])
+}
+
+// contextWindow returns the current model's context window, or 0 if unknown
+func (a *Agent) contextWindow() int {
+	if m := llm.GetModelByID(a.currentModel); m != nil {
+		return m.Context
+	}
+	return 0
+}
+
+// compactSavings estimates the tokens /compact would free now
+func (a *Agent) compactSavings() int {
+	boundary := a.compactBoundary()
+	if boundary <= 1 {
+		return 0
+	}
+	saved := estimateMessages(a.history[1:boundary]) - compactSummaryTokens
+	for _, p := range a.pins {
+		saved -= estimateTokens(p.content)
+	}
+	if saved < 0 {
+		return 0
+	}
+	return saved
+}
+
+// contextAlert warns once when usage crosses each alert level, and at the
+// highest level offers to compact. It runs before each prompt.
+func (a *Agent) contextAlert() {
+	window := a.contextWindow()
+	if window == 0 {
+		return
+	}
+	used := a.contextTokens()
+	percent := used * 100 / window
+
+	level := 0
+	for _, l := range contextAlertLevels {
+		if percent >= l {
+			level = l
+		}
+	}
+	if level <= a.meter.alerted {
+		if level < a.meter.alerted {
+			a.meter.alerted = level // Compacted or cleared; alert again later
+		}
+		return
+	}
+	a.meter.alerted = level
+
+	status := i18n.Tf("Context %d%% full (%s of %s tokens).", percent, formatTokens(used), formatTokens(window))
+	saved := a.compactSavings()
+	if saved == 0 {
+		a.ui.Print(status)
+		return
+	}
+	if level < contextAlertLevels[len(contextAlertLevels)-1] || a.headless {
+		a.ui.Print(status + " " + i18n.Tf("/compact would free about %s tokens.", formatTokens(saved)))
+		return
+	}
+	answer := a.ui.Prompt(status + " " + i18n.Tf("Compact now to free about %s tokens? [y/N] ", formatTokens(saved)))
+	if !i18n.IsYes(answer) {
+		return
+	}
+	if err := a.compact(""); err != nil {
+		a.ui.Print(i18n.Tf("Error: %v", err))
+	}
+}
+
+// checkContextFits refuses a request that cannot fit the model's context
+// window, instead of sending it and getting the provider's overflow error
+func (a *Agent) checkContextFits() error {
+	window := a.contextWindow()
+	if window == 0 {
+		return nil
+	}
+	used := a.contextTokens()
+	if used <= window {
+		return nil
+	}
+	advice := "start a new session"
+	if saved := a.compactSavings(); saved > 0 {
+		advice = fmt.Sprintf("run /compact to free about %s tokens", formatTokens(saved))
+	}
+	return fmt.Errorf("the conversation is about %s tokens, more than the %s-token context of %s; %s",
+		formatTokens(used), formatTokens(window), a.CurrentModelName(), advice)
+}
+
+// estimateMessages gives a rough token count of messages
+func estimateMessages(messages []llm.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateTokens(msg.Content) + len(msg.Images)*imageTokens
+		for _, tc := range msg.ToolCalls {
+			args, _ := json.Marshal(tc.Args)
+			total += estimateTokens(tc.Name) + estimateTokens(string(args))
+		}
+		if msg.ToolResult != nil {
+			total += estimateTokens(msg.ToolResult.Content)
+		}
+	}
+	return total
+}
@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// Kinds of model calls the cost ledger tells apart. Tool-result summaries
+// are named after their tool, e.g. "WebFetch result summaries".
+const (
+	costTurns       = "Model turns"
+	costCompaction  = "Compaction"
+	costPageSummary = "Linked-page summaries"
+	costMemory      = "Memory file summaries"
+)
+
+// costLedger records the tokens of every model call of a session by who made
+// it: the main agent, each Task sub-agent, and the auxiliary calls either of
+// them made. Sub-agents write to their parent's ledger, from the goroutine of
+// the Task call.
+type costLedger struct {
+	mu      sync.Mutex
+	entries []costEntry
+}
+
+type costEntry struct {
+	scope []string // Sub-agent labels from the main agent down; empty for the main agent
+	kind  string
+	usage llm.Usage
+	cost  float64
+}
+
+// recordCost adds a model call's usage to the ledger under this agent's
+// scope. Calls whose provider reported no usage are skipped.
+func (a *Agent) recordCost(kind, modelID string, resp *llm.Message) {
+	if a.costs == nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	e := costEntry{scope: a.costScope, kind: kind, usage: *resp.Usage}
+	if m := llm.GetModelByID(modelID); m != nil {
+		e.cost = m.Cost(*resp.Usage)
+	}
+	a.costs.mu.Lock()
+	defer a.costs.mu.Unlock()
+	a.costs.entries = append(a.costs.entries, e)
+}
+
+// subAgentLabel names a Task sub-agent in /cost: the specialized agent's
+// name, or the start of its task
+func subAgentLabel(ctx context.Context, task string) string {
+	if name := tools.SubAgentName(ctx); name != "" {
+		return "Task (" + name + ")"
+	}
+	return fmt.Sprintf("Task %q", firstLineOf(task, 40))
+}
+
+// costTotal sums the calls of a node or kind
+type costTotal struct {
+	calls   int
+	in, out int
+	cost    float64
+}
+
+func (t *costTotal) add(e costEntry) {
+	t.calls++
+	t.in += e.usage.InputTokens
+	t.out += e.usage.OutputTokens
+	t.cost += e.cost
+}
+
+// costNode is the main agent or a sub-agent in the /cost tree. Its total
+// includes the sub-agents below it.
+type costNode struct {
+	label    string
+	total    costTotal
+	kinds    []string
+	byKind   map[string]*costTotal
+	children []*costNode
+}
+
+func newCostNode(label string) *costNode {
+	return &costNode{label: label, byKind: make(map[string]*costTotal)}
+}
+
+func (n *costNode) child(label string) *costNode {
+	for _, c := range n.children {
+		if c.label == label {
+			return c
+		}
+	}
+	c := newCostNode(label)
+	n.children = append(n.children, c)
+	return c
+}
+
+// write prints the node, its kinds of calls, then its sub-agents, each level
+// indented further
+func (n *costNode) write(sb *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+	sb.WriteString(formatCostLine(indent, n.label, n.total))
+	for _, kind := range n.kinds {
+		t := n.byKind[kind]
+		sb.WriteString(formatCostLine(indent+"  ", fmt.Sprintf("%s (%d)", kind, t.calls), *t))
+	}
+	for _, c := range n.children {
+		c.write(sb, depth+1)
+	}
+}
+
+func formatCostLine(indent, label string, t costTotal) string {
+	label = indent + firstLineOf(label, 44-len(indent))
+	return fmt.Sprintf("%-44s ~$%-7.2f %s in / %s out\n", label, t.cost, formatTokens(t.in), formatTokens(t.out))
+}
+
+// costTree arranges the ledger under the main agent
+func (l *costLedger) costTree() *costNode {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	root := newCostNode("Main agent")
+	for _, e := range l.entries {
+		n := root
+		n.total.add(e)
+		for _, label := range e.scope {
+			n = n.child(label)
+			n.total.add(e)
+		}
+		t := n.byKind[e.kind]
+		if t == nil {
+			t = &costTotal{}
+			n.byKind[e.kind] = t
+			n.kinds = append(n.kinds, e.kind)
+		}
+		t.add(e)
+	}
+	return root
+}
+
+// showCost handles /cost: the estimated spend of this session, split
+// between the main agent, its sub-agents and their auxiliary calls
+func (a *Agent) showCost() error {
+	if a.costs == nil {
+		return nil
+	}
+	root := a.costs.costTree()
+	if root.total.calls == 0 {
+		a.ui.Print("No model calls yet. /cost covers the calls made since john started.")
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Session cost: ~$%.2f over %s (%s tokens in, %s out)\n\n",
+		root.total.cost, plural(root.total.calls, "model call"), formatTokens(root.total.in), formatTokens(root.total.out)))
+	root.write(&sb, 0)
+	a.injections.writeInjections(&sb)
+	sb.WriteString("\nEstimates from list prices; a sub-agent's line includes everything below it.")
+	a.ui.Print(sb.String())
+	return nil
+}
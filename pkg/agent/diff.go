@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// handleDiff handles /diff: list the files with uncommitted changes and how
+// much changed, or with paths, show their full diff
+func (a *Agent) handleDiff(args string) error {
+	if _, err := gitOutput("rev-parse", "--git-dir"); err != nil {
+		return fmt.Errorf("/diff needs a git repository")
+	}
+	if paths := strings.Fields(args); len(paths) > 0 {
+		out, err := gitOutput(append([]string{"diff", "HEAD", "--"}, paths...)...)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(out) == "" {
+			a.ui.Print("No uncommitted changes to " + strings.Join(paths, ", "))
+			return nil
+		}
+		a.ui.Print(strings.TrimRight(out, "\n"))
+		return nil
+	}
+
+	status, err := gitOutput("status", "--short")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(status) == "" {
+		a.ui.Print("No uncommitted changes")
+		return nil
+	}
+	a.ui.Print(strings.TrimRight(status, "\n"))
+	if stat, _ := gitOutput("diff", "HEAD", "--shortstat"); strings.TrimSpace(stat) != "" {
+		a.ui.Print(strings.TrimSpace(stat) + ". /diff <path> shows a file's changes.")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/mcp"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// dumpVersion is bumped when the dump format changes incompatibly
+const dumpVersion = 1
+
+// stateDump is the complete in-memory agent state written by /dump
+type stateDump struct {
+	Version      int                `json:"version"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	CWD          string             `json:"cwd"`
+	Model        string             `json:"model"`
+	SessionID    string             `json:"sessionId,omitempty"`
+	History      []llm.Message      `json:"history"`
+	Tools        []string           `json:"tools"`
+	Todos        []tools.TodoItem   `json:"todos"`
+	Pins         []savedPin         `json:"pins,omitempty"`
+	LoadedMemory []string           `json:"loadedMemory,omitempty"`
+	Config       dumpConfig         `json:"config"`
+	MCP          []mcp.ServerStatus `json:"mcp"`
+}
+
+// dumpConfig is the config with credentials removed
+type dumpConfig struct {
+	APIKeySet bool             `json:"apiKeySet"`
+	BaseURL   string           `json:"baseUrl,omitempty"`
+	Settings  *config.Settings `json:"settings,omitempty"`
+}
+
+// dumpState handles /dump: write the agent state to a JSON file for bug reports
+func (a *Agent) dumpState(path string) error {
+	if path == "" {
+		path = fmt.Sprintf("john-dump-%s.json", time.Now().Format("20060102-150405"))
+	}
+
+	cwd, _ := os.Getwd()
+	dump := stateDump{
+		Version:   dumpVersion,
+		CreatedAt: time.Now(),
+		CWD:       cwd,
+		Model:     a.currentModel,
+		History:   a.history,
+		MCP:       a.mcpManager.ListServers(),
+	}
+	if a.session != nil {
+		dump.SessionID = a.session.SessionID
+	}
+	for _, def := range a.tools.List() {
+		dump.Tools = append(dump.Tools, def.Name)
+	}
+	sort.Strings(dump.Tools)
+	if tt := a.todoTool(); tt != nil {
+		dump.Todos = tt.Todos
+	}
+	dump.Pins = a.savedPins()
+	for dir := range a.loadedMemory {
+		dump.LoadedMemory = append(dump.LoadedMemory, dir)
+	}
+	sort.Strings(dump.LoadedMemory)
+	if a.cfg != nil {
+		dump.Config = dumpConfig{
+			APIKeySet: a.cfg.APIKey != "",
+			BaseURL:   redactURL(a.cfg.BaseURL),
+			Settings:  redactSettings(a.cfg.Settings),
+		}
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write dump: %w", err)
+	}
+
+	abs, _ := filepath.Abs(path)
+	a.ui.Print(fmt.Sprintf("Wrote agent state to %s (%d messages)", abs, len(a.history)))
+	a.ui.Print("The dump contains the full conversation; review it before sharing. API keys and tokens are not included.")
+	return nil
+}
+
+// LoadDumpOnStart makes Run restore the state from a /dump file instead of
+// starting fresh. Meant for reproducing bug reports.
+func (a *Agent) LoadDumpOnStart(path string) {
+	a.dumpPath = path
+}
+
+// loadDump restores the conversation, todos, pins and model from a dump
+func (a *Agent) loadDump(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	var dump stateDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return fmt.Errorf("failed to parse dump: %w", err)
+	}
+	if dump.Version != dumpVersion {
+		return fmt.Errorf("unsupported dump version %d (expected %d)", dump.Version, dumpVersion)
+	}
+	if len(dump.History) == 0 || dump.History[0].Role != llm.RoleSystem {
+		return fmt.Errorf("dump has no system prompt; is it a john dump file?")
+	}
+
+	if dump.Model != "" && dump.Model != a.currentModel {
+		if err := a.switchModel(dump.Model); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: %v; keeping %s", err, a.CurrentModelName()))
+		}
+	}
+	history, fixes := llm.RepairToolPairs(dump.History)
+	if fixes > 0 {
+		a.ui.Print(fmt.Sprintf("Repaired %s left by interrupted tool calls", plural(fixes, "message")))
+	}
+	a.history = history
+	a.meter = contextMeter{}
+	a.injections.forget()
+	if tt := a.todoTool(); tt != nil {
+		tt.Todos = dump.Todos
+	}
+	a.restorePins(dump.Pins)
+	a.loadedMemory = make(map[string]bool)
+	for _, dir := range dump.LoadedMemory {
+		a.loadedMemory[dir] = true
+	}
+
+	a.ui.Print(fmt.Sprintf("Loaded dump from %s (%d messages, taken %s in %s)",
+		path, len(dump.History), i18n.FormatTime(dump.CreatedAt), dump.CWD))
+	if missing := missingTools(dump.Tools, a.tools.List()); len(missing) > 0 {
+		a.ui.Print(fmt.Sprintf("Warning: tools from the dump are not available here: %v", missing))
+	}
+	a.showTodos()
+	return nil
+}
+
+func missingTools(names []string, defs []tools.ToolDefinition) []string {
+	have := make(map[string]bool)
+	for _, d := range defs {
+		have[d.Name] = true
+	}
+	var missing []string
+	for _, n := range names {
+		if !have[n] {
+			missing = append(missing, n)
+		}
+	}
+	return missing
+}
+
+// redactSettings returns a copy of settings without proxy credentials,
+// Slack tokens, or webhook URLs and headers, which often carry tokens
+func redactSettings(s *config.Settings) *config.Settings {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Network.HTTPProxy = redactURL(c.Network.HTTPProxy)
+	c.Network.HTTPSProxy = redactURL(c.Network.HTTPSProxy)
+	c.Slack.AppToken = ""
+	c.Slack.BotToken = ""
+	c.Webhooks = nil
+	for _, w := range s.Webhooks {
+		w.URL = redactWebhookURL(w.URL)
+		if len(w.Headers) > 0 {
+			headers := make(map[string]string, len(w.Headers))
+			for k := range w.Headers {
+				headers[k] = "REDACTED"
+			}
+			w.Headers = headers
+		}
+		c.Webhooks = append(c.Webhooks, w)
+	}
+	return &c
+}
+
+// redactWebhookURL keeps only the scheme and host of a webhook URL, since
+// incoming webhooks such as Slack's put the secret in the path
+func redactWebhookURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "REDACTED"
+	}
+	return u.Scheme + "://" + u.Host + "/REDACTED"
+}
+
+// redactURL removes the user info (credentials) from a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User("REDACTED")
+	return u.String()
+}
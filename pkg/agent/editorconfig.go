@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/editorconfig"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// setupEditorConfig sets how Write and Edit apply the project's
+// .editorconfig, from files.editorConfig
+func (a *Agent) setupEditorConfig() {
+	mode := ""
+	if a.cfg != nil && a.cfg.Settings != nil {
+		mode = a.cfg.Settings.Files.EditorConfig
+	}
+	switch mode {
+	case "":
+		tools.EditorConfigMode = tools.EditorConfigFix
+	case tools.EditorConfigFix, tools.EditorConfigCheck, tools.EditorConfigOff:
+		tools.EditorConfigMode = mode
+	default:
+		tools.EditorConfigMode = tools.EditorConfigFix
+		a.ui.Print(fmt.Sprintf("Warning: files.editorConfig %q is not fix, check or off; using fix", mode))
+	}
+}
+
+// injectEditorConfig tells the model the .editorconfig rules for a file a
+// tool touched, the first time a kind of file with those rules comes up
+func (a *Agent) injectEditorConfig(tc llm.ToolCall, result string) string {
+	key, ok := fileTools[tc.Name]
+	if !ok || tools.EditorConfigMode == "" || tools.EditorConfigMode == tools.EditorConfigOff {
+		return result
+	}
+	path, _ := tc.Args[key].(string)
+	if path == "" {
+		return result
+	}
+	path = tools.Target.Path(path)
+	if !filepath.IsAbs(path) {
+		path, _ = filepath.Abs(path)
+	}
+	props, err := editorconfig.Resolve(path, func(name string) ([]byte, error) {
+		return tools.Target.ReadFile(context.Background(), name)
+	})
+	rules := describeEditorConfig(props)
+	if err != nil || rules == "" {
+		return result
+	}
+
+	ext := filepath.Ext(path)
+	if ext == "" {
+		ext = filepath.Base(path)
+	}
+	if a.editorConfig[ext+"\x00"+rules] {
+		return result
+	}
+	if a.editorConfig == nil {
+		a.editorConfig = make(map[string]bool)
+	}
+	a.editorConfig[ext+"\x00"+rules] = true
+
+	name := "*" + ext
+	if filepath.Ext(path) == "" {
+		name = ext
+	}
+	return result + fmt.Sprintf("\n<system-reminder>\nThe project's .editorconfig applies to %s files like %s: %s. Write and edit them that way.\n</system-reminder>", name, tc.Args[key], rules)
+}
+
+// describeEditorConfig puts the rules in props in words, or "" when there
+// are none that matter for writing code
+func describeEditorConfig(props editorconfig.Properties) string {
+	var rules []string
+	switch props.IndentStyle() {
+	case "tab":
+		if w := props.TabWidth(); w > 0 {
+			rules = append(rules, fmt.Sprintf("indent with tabs (%d columns wide)", w))
+		} else {
+			rules = append(rules, "indent with tabs")
+		}
+	case "space":
+		if n := props.IndentSize(); n > 0 {
+			rules = append(rules, fmt.Sprintf("indent with %d spaces", n))
+		} else {
+			rules = append(rules, "indent with spaces")
+		}
+	}
+	if n := props.MaxLineLength(); n > 0 {
+		rules = append(rules, fmt.Sprintf("keep lines within %d columns", n))
+	}
+	if props.TrimTrailingWhitespace() {
+		rules = append(rules, "no trailing whitespace")
+	}
+	if want, set := props.InsertFinalNewline(); set {
+		if want {
+			rules = append(rules, "end files with a newline")
+		} else {
+			rules = append(rules, "no newline at the end of files")
+		}
+	}
+	switch props.EndOfLine() {
+	case "lf":
+		rules = append(rules, "LF line endings")
+	case "crlf":
+		rules = append(rules, "CRLF line endings")
+	}
+	return strings.Join(rules, ", ")
+}
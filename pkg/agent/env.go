@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// handleEnv handles /env: set, unset and list variables that Bash commands
+// and newly started MCP servers get for this session only. Values are not
+// shown to the model or written to the session log.
+func (a *Agent) handleEnv(args string) error {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+	switch sub {
+	case "set":
+		name, value, ok := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("usage: /env set KEY=value")
+		}
+		if err := tools.SessionEnv.Set(name, unquote(value)); err != nil {
+			return err
+		}
+		a.ui.Print(fmt.Sprintf("Set %s for this session. Bash commands get it now; running MCP servers only after a restart.", name))
+	case "unset":
+		if rest == "" {
+			return fmt.Errorf("usage: /env unset KEY")
+		}
+		for _, name := range strings.Fields(rest) {
+			if !tools.SessionEnv.Unset(name) {
+				return fmt.Errorf("%s is not set in this session", name)
+			}
+			a.ui.Print(fmt.Sprintf("Unset %s", name))
+		}
+	case "", "list":
+		names := tools.SessionEnv.Names()
+		if len(names) == 0 {
+			a.ui.Print("No session variables. Add one with /env set KEY=value")
+			return nil
+		}
+		var sb strings.Builder
+		sb.WriteString("Session variables (values hidden):")
+		for _, name := range names {
+			value, _ := tools.SessionEnv.Get(name)
+			kind := ""
+			if tools.SessionEnv.Secret(name) {
+				kind = "secret, "
+			}
+			sb.WriteString(fmt.Sprintf("\n  %s (%s%s)", name, kind, plural(len(value), "char")))
+		}
+		a.ui.Print(sb.String())
+		return nil
+	default:
+		return fmt.Errorf("usage: /env set KEY=value | unset KEY | list")
+	}
+	a.setSessionEnvNotice()
+	return nil
+}
+
+// unquote strips one pair of matching quotes around a value
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// setSessionEnvNotice tells the model which session variables and secrets
+// exist, by name only, so it can use them in commands instead of asking for
+// values
+func (a *Agent) setSessionEnvNotice() {
+	if len(a.history) == 0 {
+		return
+	}
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<session-env>"); i >= 0 {
+		end := strings.Index(system[i:], "</session-env>")
+		system = system[:i] + system[i+end+len("</session-env>"):]
+	}
+	var vars, secrets []string
+	for _, name := range tools.SessionEnv.Names() {
+		if tools.SessionEnv.Secret(name) {
+			secrets = append(secrets, name)
+		} else {
+			vars = append(vars, name)
+		}
+	}
+	var notice []string
+	if len(vars) > 0 {
+		notice = append(notice, "The user set these environment variables for Bash commands in this session. "+
+			"Refer to them by name (e.g. \"$"+vars[0]+"\"); never print their values.\n"+strings.Join(vars, "\n"))
+	}
+	if len(secrets) > 0 {
+		notice = append(notice, "These secrets are not in the environment. Write {{secret:NAME}} (e.g. {{secret:"+secrets[0]+"}}) "+
+			"where a Bash, Write, Edit or exec tool argument needs one; never print their values.\n"+strings.Join(secrets, "\n"))
+	}
+	if len(notice) > 0 {
+		system += "\n\n<session-env>\n" + strings.Join(notice, "\n\n") + "\n</session-env>"
+	}
+	a.history[0].Content = system
+}
+
+// secretTools run on this machine, so {{secret:NAME}} placeholders in their
+// arguments are filled in. Other tools, such as WebFetch and MCP tools, get
+// the placeholder as written, so a secret cannot leave in a URL or request.
+var secretTools = map[string]bool{
+	"Bash":  true,
+	"Write": true,
+	"Edit":  true,
+}
+
+// expandsSecrets reports whether a tool's arguments get session secrets
+func expandsSecrets(tool tools.Tool) bool {
+	if _, ok := tool.(*tools.ExecTool); ok {
+		return true
+	}
+	return secretTools[tool.Definition().Name]
+}
+
+// checkSecrets asks before a call that uses session secrets, whatever the
+// permission rules allow, since a prompt injection could put a placeholder
+// in a command that sends the value elsewhere. It returns the arguments to
+// run the tool with.
+func (a *Agent) checkSecrets(tc llm.ToolCall, tool tools.Tool) (map[string]interface{}, string, bool) {
+	if !expandsSecrets(tool) {
+		return tc.Args, "", true
+	}
+	names := tools.SessionEnv.Placeholders(tc.Args)
+	if len(names) == 0 {
+		return tc.Args, "", true
+	}
+	if a.headless {
+		return nil, fmt.Sprintf("Error: %s uses the session secrets %s, which needs approval, and no one can approve it in this session. Do not retry it.", tc.Name, strings.Join(names, ", ")), false
+	}
+	answer := a.ui.Prompt(i18n.Tf("Allow %s with the secrets %s filled in? [y/N] ", describeToolCall(tc), strings.Join(names, ", ")))
+	if !i18n.IsYes(answer) {
+		return nil, fmt.Sprintf("Error: the user declined to run %s with the secrets %s. Ask the user how to proceed.", tc.Name, strings.Join(names, ", ")), false
+	}
+	return tools.SessionEnv.Expand(tc.Args), "", true
+}
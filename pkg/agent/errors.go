@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// maxErrorRecords is how many recent errors /errors keeps
+const maxErrorRecords = 50
+
+// errorRecord is a provider or tool failure kept for /errors
+type errorRecord struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"` // "provider" or "tool"
+	Name       string    `json:"name"`   // Provider or tool name
+	Model      string    `json:"model,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	RequestID  string    `json:"requestId,omitempty"`
+	Message    string    `json:"message"`
+}
+
+// recordProviderError keeps a failed model request in the ring buffer and
+// the session log
+func (a *Agent) recordProviderError(err error) {
+	rec := errorRecord{Time: time.Now(), Source: "provider", Model: a.currentModel, Message: err.Error()}
+	if m := llm.GetModelByID(a.currentModel); m != nil {
+		rec.Name = string(m.Provider)
+	}
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		rec.Name = apiErr.Provider
+		rec.StatusCode = apiErr.StatusCode
+		rec.Type = apiErr.Type
+		rec.RequestID = apiErr.RequestID
+		rec.Message = apiErr.Message
+	}
+	a.recordError(rec)
+}
+
+// recordToolError keeps a failed tool call in the ring buffer and the
+// session log, with the ID of the model request that made the call
+func (a *Agent) recordToolError(tool string, message string) {
+	rec := errorRecord{Time: time.Now(), Source: "tool", Name: tool, Message: tools.SessionEnv.Redact(message)}
+	for i := len(a.history) - 1; i > 0; i-- {
+		if a.history[i].Role == llm.RoleAssistant {
+			rec.RequestID = a.history[i].RequestID
+			break
+		}
+	}
+	a.recordError(rec)
+}
+
+func (a *Agent) recordError(rec errorRecord) {
+	a.errorLog = append(a.errorLog, rec)
+	if len(a.errorLog) > maxErrorRecords {
+		a.errorLog = a.errorLog[len(a.errorLog)-maxErrorRecords:]
+	}
+	if a.session != nil {
+		a.session.AppendError(rec)
+	}
+}
+
+// showErrors handles /errors and /errors clear
+func (a *Agent) showErrors(args string) error {
+	switch strings.TrimSpace(args) {
+	case "clear":
+		a.errorLog = nil
+		a.ui.Print("Cleared the error list")
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("usage: /errors [clear]")
+	}
+
+	// The latest request may have succeeded yet produced a bad response,
+	// which is worth escalating too
+	latest := ""
+	for i := len(a.history) - 1; i > 0; i-- {
+		if a.history[i].Role == llm.RoleAssistant && a.history[i].RequestID != "" {
+			latest = "Latest model request: " + a.history[i].RequestID
+			break
+		}
+	}
+
+	if len(a.errorLog) == 0 {
+		a.ui.Print(strings.TrimSpace("No provider or tool errors in this session\n" + latest))
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Recent errors (%d, oldest first):\n", len(a.errorLog)))
+	for _, rec := range a.errorLog {
+		header := fmt.Sprintf("\n[%s] %s %s", i18n.FormatClock(rec.Time), rec.Source, rec.Name)
+		if rec.Model != "" {
+			header += " (" + rec.Model + ")"
+		}
+		if rec.StatusCode != 0 {
+			header += fmt.Sprintf(" status %d", rec.StatusCode)
+		}
+		if rec.Type != "" {
+			header += " " + rec.Type
+		}
+		if rec.RequestID != "" && rec.Source == "tool" {
+			header += " (called by request " + rec.RequestID + ")"
+		} else if rec.RequestID != "" {
+			header += " request " + rec.RequestID
+		}
+		sb.WriteString(header + "\n")
+		sb.WriteString("  " + truncate(strings.Join(strings.Fields(rec.Message), " "), 300) + "\n")
+		if fix := remediation(rec); fix != "" {
+			sb.WriteString("  -> " + fix + "\n")
+		}
+	}
+	if latest != "" {
+		sb.WriteString("\n" + latest + "\n")
+	}
+	a.ui.Print(strings.TrimRight(sb.String(), "\n"))
+	return nil
+}
+
+// remediation suggests a fix for common failures
+func remediation(rec errorRecord) string {
+	msg := strings.ToLower(rec.Message)
+	if rec.Source == "tool" {
+		switch {
+		case strings.Contains(msg, "not found") && strings.Contains(msg, "tool"):
+			return "The model called a tool that is not registered; check /mcp if it is an MCP tool."
+		case strings.Contains(msg, "could not be parsed as json"):
+			return "The model sent malformed arguments; it was asked to retry. Raw arguments are in ~/.johncode/logs."
+		case strings.Contains(msg, "permission denied"):
+			return "Check file permissions, or whether the path is outside the project."
+		case strings.Contains(msg, "timed out") || strings.Contains(msg, "timeout"):
+			return "Run long commands in the background (run_in_background) or raise the tool's timeout."
+		}
+		return ""
+	}
+
+	envVar := map[string]string{"anthropic": "ANTHROPIC_API_KEY", "openai": "OPENAI_API_KEY", "gemini": "GEMINI_API_KEY", "google": "GEMINI_API_KEY"}[rec.Name]
+	switch {
+	case rec.StatusCode == 401:
+		return fmt.Sprintf("The API key was rejected; check %s.", envVar)
+	case rec.StatusCode == 403:
+		return "The key lacks access to this model or feature; try another model with /model."
+	case rec.StatusCode == 404:
+		return "Unknown model or endpoint; check the model with /model and any ANTHROPIC_BASE_URL override."
+	case rec.StatusCode == 413 || strings.Contains(msg, "maxrequestbytes"):
+		return "The request is too large; run /compact, or raise providers." + rec.Name + ".maxRequestBytes in settings."
+	case strings.Contains(msg, "prompt is too long") || strings.Contains(msg, "context length") || strings.Contains(msg, "context window"):
+		return "The conversation no longer fits the model's context; run /compact."
+	case rec.StatusCode == 429 || strings.Contains(msg, "rate limit"):
+		return "Rate limited; wait a minute, or switch to another provider with /model."
+	case rec.StatusCode == 529 || rec.Type == "overloaded_error" || rec.StatusCode >= 500:
+		return "The provider is having trouble; retry shortly or switch models with /model."
+	case rec.StatusCode == 400:
+		return "The provider rejected the request; /dump the state and include the request ID in a bug report."
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "The request timed out; raise providers." + rec.Name + ".timeout or responseHeaderTimeout in settings."
+	case strings.Contains(msg, "certificate") || strings.Contains(msg, "x509"):
+		return "TLS verification failed; set network.caCertFile in settings if you are behind a corporate proxy."
+	case strings.Contains(msg, "proxy") || strings.Contains(msg, "connection refused") || strings.Contains(msg, "no such host"):
+		return "The provider could not be reached; check your connection and network.httpsProxy in settings."
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// execToolName is what providers accept as a tool name
+var execToolName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// registerExecTools adds the command-backed tools declared under execTools
+// in settings. Declarations with a bad name, no command, or the name of a
+// built-in tool are skipped with a warning.
+func (a *Agent) registerExecTools() {
+	if a.cfg == nil || a.cfg.Settings == nil || a.cfg.SafeMode {
+		return
+	}
+	declared := a.cfg.Settings.ExecTools
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ts := declared[name]
+		if !execToolName.MatchString(name) {
+			a.ui.Print(fmt.Sprintf("Warning: execTools.%s: tool names must be letters, digits, _ and -, starting with a letter", name))
+			continue
+		}
+		if strings.TrimSpace(ts.Command) == "" {
+			a.ui.Print(fmt.Sprintf("Warning: execTools.%s has no command", name))
+			continue
+		}
+		if existing, ok := a.tools.Get(name); ok {
+			if _, isExec := existing.(*tools.ExecTool); !isExec {
+				a.ui.Print(fmt.Sprintf("Warning: execTools.%s: a built-in tool has that name", name))
+				continue
+			}
+		}
+		description := ts.Description
+		if description == "" {
+			description = "Runs " + ts.Command
+		}
+		a.tools.Register(tools.NewExecTool(name, description, ts.Command, ts.Schema, time.Duration(ts.Timeout)))
+	}
+}
@@ -0,0 +1,232 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/lang"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// explainTools are the only tools john explain offers: it reads, never writes
+var explainTools = []string{"Read", "Glob", "Grep"}
+
+const (
+	maxRepoMap     = 12000 // Characters of repository map sent with the prompt
+	maxExplainFile = 60000 // Characters of the target file
+	maxMapFileSize = 512 * 1024
+	maxDefsPerFile = 12
+	maxSymbolDefs  = 5
+	maxSymbolRefs  = 20
+	symbolSnippet  = 80 // Lines shown from each definition
+)
+
+// definitionPattern finds top-level definitions in the common languages:
+// Go funcs, methods and types, Python defs and classes, JS/TS functions
+// and classes, Rust fns, structs and traits, and the like
+var definitionPattern = regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:pub(?:\([a-z]+\))?\s+)?(?:public\s+|private\s+|protected\s+|static\s+|abstract\s+|final\s+)*(?:async\s+)?(?:func|type|class|def|interface|struct|enum|trait|fn|function|module|object)\s+(?:\([^)]*\)\s*)?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// isMapSource reports whether the repository map lists a file's definitions
+func isMapSource(path string) bool {
+	l := lang.FromPath(path)
+	return l != nil && l.Source
+}
+
+// RunExplain explains a file, directory or symbol for someone new to the
+// code and prints the explanation. The model gets a map of the repository
+// and the target up front, and only read-only tools to look further, so
+// nothing in the workspace can change.
+func RunExplain(cfg *config.Config, u *ui.UI, target, model string) error {
+	files, err := projectFiles()
+	if err != nil {
+		return err
+	}
+	targetContext, err := explainTarget(files, target)
+	if err != nil {
+		return err
+	}
+
+	a := New(cfg, u)
+	if model != "" {
+		if err := a.switchModel(model); err != nil {
+			return err
+		}
+	}
+	a.headless = true
+	a.restrictTools(explainTools)
+	a.setupHyperlinks()
+
+	prompt := "<repo-map>\nFiles in this repository, with the names each source file defines:\n" +
+		repoMap(files, maxRepoMap) + "\n</repo-map>\n\n" + targetContext + "\n\n" +
+		"Explain " + target + " to a developer who is new to this codebase: what it is for, how it works, " +
+		"how it fits into the rest of the project (who uses it and what it depends on), and anything surprising " +
+		"or easy to get wrong. Use the tools to read related code where that helps. Cite code as path:line " +
+		"references. Do not suggest or make changes."
+	return a.RunPrompt(prompt)
+}
+
+// explainTarget returns the content john explain starts from: the file, the
+// directory listing, or the definitions and uses of a symbol
+func explainTarget(files []string, target string) (string, error) {
+	if info, err := os.Stat(target); err == nil {
+		if info.IsDir() {
+			prefix := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(target)), "/") + "/"
+			var listing []string
+			for _, f := range files {
+				if prefix == "./" || strings.HasPrefix(f, prefix) {
+					listing = append(listing, f)
+				}
+			}
+			return fmt.Sprintf("<directory path=%q>\n%s\n</directory>", target, repoMap(listing, maxExplainFile)), nil
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", err
+		}
+		content := string(data)
+		if len(content) > maxExplainFile {
+			content = content[:maxExplainFile] + "\n...[truncated; use Read for the rest]..."
+		}
+		return fmt.Sprintf("<file path=%q>\n%s\n</file>", target, numberLines(content, 1)), nil
+	}
+
+	if strings.ContainsAny(target, `/\`) {
+		return "", fmt.Errorf("%s: no such file or directory", target)
+	}
+
+	// Not a path: a symbol, optionally qualified as Type.Method
+	symbol := target
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		symbol = symbol[i+1:]
+	}
+	if !regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`).MatchString(symbol) {
+		return "", fmt.Errorf("%s is neither a file, a directory nor a symbol name", target)
+	}
+	defs, refs := findSymbol(files, symbol)
+	if len(defs) == 0 && len(refs) == 0 {
+		return "", fmt.Errorf("no file or symbol named %s found", target)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<symbol name=%q>\n", target)
+	for _, d := range defs {
+		fmt.Fprintf(&sb, "Defined at %s:%d:\n%s\n\n", d.file, d.line, d.snippet)
+	}
+	if len(refs) > 0 {
+		sb.WriteString("Used at:\n")
+		for _, r := range refs {
+			sb.WriteString(r + "\n")
+		}
+	}
+	sb.WriteString("</symbol>")
+	return sb.String(), nil
+}
+
+type symbolDef struct {
+	file    string
+	line    int
+	snippet string
+}
+
+// findSymbol returns where symbol is defined, with the code around each
+// definition, and up to maxSymbolRefs other lines that mention it
+func findSymbol(files []string, symbol string) ([]symbolDef, []string) {
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(symbol) + `\b`)
+	var defs []symbolDef
+	var refs []string
+	for _, f := range files {
+		if !isMapSource(f) {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil || len(data) > maxMapFileSize || !word.Match(data) {
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		for i, line := range lines {
+			if !word.MatchString(line) {
+				continue
+			}
+			if m := definitionPattern.FindStringSubmatch(line); m != nil && m[1] == symbol && len(defs) < maxSymbolDefs {
+				// Up to the next top-level definition, within symbolSnippet lines
+				end := i + 1
+				for end < len(lines) && end < i+symbolSnippet {
+					if next := lines[end]; next != "" && next[0] != ' ' && next[0] != '\t' && definitionPattern.MatchString(next) {
+						break
+					}
+					end++
+				}
+				defs = append(defs, symbolDef{file: f, line: i + 1, snippet: numberLines(strings.Join(lines[i:end], "\n"), i+1)})
+				continue
+			}
+			if len(refs) < maxSymbolRefs {
+				refs = append(refs, fmt.Sprintf("%s:%d: %s", f, i+1, firstLineOf(strings.TrimSpace(line), 120)))
+			}
+		}
+	}
+	return defs, refs
+}
+
+// repoMap lists files one per line with the names defined in source files,
+// stopping at max characters
+func repoMap(files []string, max int) string {
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+
+	var sb strings.Builder
+	for i, f := range sorted {
+		line := f
+		if isMapSource(f) {
+			if names := definedNames(f); len(names) > 0 {
+				line += ": " + strings.Join(names, ", ")
+			}
+		}
+		if sb.Len()+len(line) > max {
+			fmt.Fprintf(&sb, "... and %s more\n", plural(len(sorted)-i, "file"))
+			break
+		}
+		sb.WriteString(line + "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// definedNames returns the first names a source file defines
+func definedNames(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() > maxMapFileSize {
+		return nil
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if m := definitionPattern.FindStringSubmatch(scanner.Text()); m != nil {
+			if len(names) == maxDefsPerFile {
+				names = append(names, "…")
+				break
+			}
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// numberLines prefixes each line with its number, starting at first
+func numberLines(s string, first int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf("%6d\t%s", first+i, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// defaultFollow is how long /follow watches when no time is given
+const defaultFollow = 30 * time.Second
+
+// handleFollow handles /follow <file|shell-id> [seconds] [pattern]: print
+// the new lines of a log file or background shell as they arrive, until the
+// time is up, a line matches pattern or Ctrl+C
+func (a *Agent) handleFollow(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return fmt.Errorf("usage: /follow <file|shell-id> [seconds] [pattern]")
+	}
+	name, rest := fields[0], fields[1:]
+	d := defaultFollow
+	if len(rest) > 0 {
+		if secs, err := strconv.ParseFloat(rest[0], 64); err == nil && secs > 0 {
+			d = time.Duration(secs * float64(time.Second))
+			rest = rest[1:]
+		}
+	}
+	var until *regexp.Regexp
+	if len(rest) > 0 {
+		re, err := regexp.Compile(strings.Join(rest, " "))
+		if err != nil {
+			return fmt.Errorf("bad pattern: %w", err)
+		}
+		until = re
+	}
+
+	var source tools.FollowSource
+	what := name
+	if tools.GlobalShellManager.Has(name) {
+		// Only output from now on, as with a file
+		out, _, _ := tools.GlobalShellManager.GetOutput(name)
+		source = tools.FollowShell(name, len(out))
+		what = "shell " + name
+	} else {
+		if tools.Target.Remote() {
+			return fmt.Errorf("/follow watches files on this machine; on %s, follow a background shell running tail -f", tools.Target.Name())
+		}
+		var err error
+		if source, err = tools.FollowFile(name); err != nil {
+			return err
+		}
+	}
+
+	a.ui.PrintDim(fmt.Sprintf("Following %s for %s; Ctrl+C stops", what, d))
+	ctx, stop := interruptible(context.Background())
+	followed, err := tools.Follow(ctx, source, d, until, func(lines []string) {
+		a.ui.Print(strings.Join(lines, "\n"))
+	})
+	stop()
+	if err != nil {
+		return err
+	}
+	a.ui.PrintDim("Followed " + what + " and " + followed.Summary())
+	return nil
+}
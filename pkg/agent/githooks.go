@@ -0,0 +1,244 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// GitHooks are the hooks john hooks install can manage
+var GitHooks = []string{"pre-commit", "pre-push"}
+
+// gitHookMarker identifies hook scripts written by InstallGitHook
+const gitHookMarker = "# Installed by john hooks install"
+
+const defaultGitHookModel = "claude-haiku-4.5"
+
+// defaultGitHookPrompt is used when settings give no prompt for a hook
+const defaultGitHookPrompt = "Review this diff for committed secrets (API keys, passwords, private keys, tokens) " +
+	"and obvious bugs (syntax errors, debug leftovers, merge conflict markers, clearly broken logic). " +
+	"Ignore style and anything you are unsure about."
+
+// maxGitHookDiff bounds the diff sent to the model
+const maxGitHookDiff = 100000
+
+// InstallGitHook writes a git hook that runs john hooks run <hook>. An existing
+// hook not written by john is only replaced when force is set.
+func InstallGitHook(hook string, force bool) (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository")
+	}
+	path := filepath.Join(strings.TrimSpace(string(out)), hook)
+
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), gitHookMarker) && !force {
+		return "", fmt.Errorf("%s already exists and was not installed by john (use --force to replace it)", path)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "john"
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s\n# Skip once with: git %s --no-verify\nexec %q hooks run %s\n",
+		gitHookMarker, strings.TrimPrefix(hook, "pre-"), exe, hook)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// UninstallGitHook removes a hook written by InstallGitHook
+func UninstallGitHook(hook string) (bool, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return false, fmt.Errorf("not a git repository")
+	}
+	path := filepath.Join(strings.TrimSpace(string(out)), hook)
+
+	existing, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(existing), gitHookMarker) {
+		return false, nil
+	}
+	return true, os.Remove(path)
+}
+
+// RunGitHook reviews the changes a git hook is about to accept with a single
+// model call. It returns false when the commit or push should be blocked.
+// stdin is the hook's standard input, which lists the refs for pre-push.
+func RunGitHook(cfg *config.Config, u *ui.UI, hook string, stdin io.Reader) (bool, error) {
+	return runGitHook(cfg, u, hook, stdin, nil)
+}
+
+// runGitHook is RunGitHook with the model's client; nil creates it from
+// gitHooks.model
+func runGitHook(cfg *config.Config, u *ui.UI, hook string, stdin io.Reader, client llm.Client) (bool, error) {
+	settings := config.GitHookSettings{}
+	if cfg.Settings != nil {
+		settings = cfg.Settings.GitHooks
+	}
+	hookCfg := settings.PreCommit
+	if hook == "pre-push" {
+		hookCfg = settings.PrePush
+	}
+	blockOnError := hookCfg.OnError == "block"
+
+	diff, err := gitHookDiff(hook, stdin)
+	if err != nil {
+		return !blockOnError, err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return true, nil
+	}
+	if len(diff) > maxGitHookDiff {
+		diff = diff[:maxGitHookDiff] + "\n...[diff truncated]..."
+	}
+
+	if client == nil {
+		modelID := settings.Model
+		if modelID == "" {
+			modelID = defaultGitHookModel
+		}
+		a := New(cfg, u)
+		model, err := a.resolveModel(modelID)
+		if err != nil {
+			return !blockOnError, fmt.Errorf("gitHooks.model: %w", err)
+		}
+		client = a.createClientForModel(model.ID)
+		if _, isMock := client.(*llm.MockClient); isMock {
+			return !blockOnError, fmt.Errorf("no API key for %s", model.ID)
+		}
+	}
+
+	prompt := hookCfg.Prompt
+	if prompt == "" {
+		prompt = defaultGitHookPrompt
+	}
+	resp, err := client.Generate(context.Background(), []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: "You are a git " + hook + " check. List each problem found as a short bullet with the file name. " +
+				"End your reply with a final line that is exactly VERDICT: PASS or VERDICT: FAIL.",
+		},
+		{Role: llm.RoleUser, Content: prompt + "\n\n<diff>\n" + diff + "\n</diff>"},
+	}, nil)
+	if err != nil {
+		return !blockOnError, err
+	}
+
+	report := strings.TrimSpace(resp.Content)
+	passed := !strings.Contains(report, "VERDICT: FAIL")
+	if passed {
+		u.Print(fmt.Sprintf("john %s: no problems found", hook))
+		return true, nil
+	}
+
+	u.Print(fmt.Sprintf("john %s check found problems:\n\n%s\n", hook, strings.TrimSuffix(report, "VERDICT: FAIL")))
+	if hookCfg.Policy == "warn" {
+		u.Print("(gitHooks policy is warn; continuing)")
+		return true, nil
+	}
+	u.Print("Blocked by john. Fix the problems, or skip the check with --no-verify.")
+	return false, nil
+}
+
+// emptyTree is git's empty tree, to diff a root commit against
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// gitHookDiff returns the changes the hook guards: staged changes for
+// pre-commit, the pushed commits for pre-push. git gives pre-push a line
+// "<local ref> <local sha> <remote ref> <remote sha>" per pushed ref.
+func gitHookDiff(hook string, stdin io.Reader) (string, error) {
+	switch hook {
+	case "pre-commit":
+		return gitDiff("diff", "--cached", "--no-color")
+	case "pre-push":
+	default:
+		return "", fmt.Errorf("unsupported hook %q", hook)
+	}
+
+	var diffs strings.Builder
+	pushed := false
+	if stdin != nil {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 4 {
+				continue
+			}
+			pushed = true
+			local, remote := fields[1], fields[3]
+			if isZeroSHA(local) {
+				continue // A deleted ref pushes no changes
+			}
+			base := remote
+			if isZeroSHA(remote) || !gitHasCommit(remote) {
+				base = newBranchBase(local)
+			}
+			diff, err := gitDiff("diff", "--no-color", base, local)
+			if err != nil {
+				return "", err
+			}
+			diffs.WriteString(diff)
+		}
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("failed to read the pushed refs: %w", err)
+		}
+	}
+	if pushed {
+		return diffs.String(), nil
+	}
+
+	// Run by hand, without refs: the unpushed commits of this branch
+	base := "@{upstream}"
+	if !gitHasCommit(base) {
+		base = newBranchBase("HEAD")
+	}
+	return gitDiff("diff", "--no-color", base, "HEAD")
+}
+
+// newBranchBase returns what a commit not on the remote yet is compared
+// with: its merge-base with the remote's default branch, or the empty tree
+// when there is none, as for the first push of a repository
+func newBranchBase(commit string) string {
+	var branches []string
+	if out, err := exec.Command("git", "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD").Output(); err == nil {
+		branches = append(branches, strings.TrimSpace(string(out)))
+	}
+	branches = append(branches, "origin/main", "origin/master")
+	for _, branch := range branches {
+		if out, err := exec.Command("git", "merge-base", commit, branch).Output(); err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return emptyTree
+}
+
+func isZeroSHA(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
+
+// gitHasCommit reports whether rev names a commit in this repository
+func gitHasCommit(rev string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Run() == nil
+}
+
+func gitDiff(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/lang"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// HeadlessInput is the conversation john -p --input-format json reads from
+// stdin: extra system instructions and the turns so far, ending with the
+// message the model should answer
+type HeadlessInput struct {
+	System   string            `json:"system,omitempty"`
+	Messages []HeadlessMessage `json:"messages"`
+}
+
+// HeadlessMessage is one turn of a seeded conversation. Assistant turns may
+// carry tool calls, each answered by a following tool message with the same
+// tool_call_id.
+type HeadlessMessage struct {
+	Role        string         `json:"role"`
+	Content     string         `json:"content"`
+	Attachments []string       `json:"attachments,omitempty"` // Files: images go as images, text files inline
+	ToolCalls   []llm.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID  string         `json:"tool_call_id,omitempty"`
+	ToolName    string         `json:"tool_name,omitempty"`
+	IsError     bool           `json:"is_error,omitempty"` // Tool messages: the call failed
+}
+
+// ParseHeadlessInput reads a HeadlessInput, or a bare array of messages
+func ParseHeadlessInput(data []byte) (*HeadlessInput, error) {
+	data = bytes.TrimSpace(data)
+	var in HeadlessInput
+	var err error
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &in.Messages)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&in)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+	return &in, nil
+}
+
+// seedHistory checks a seeded conversation and adds it to the history.
+// It must end with a user or tool message for the model to answer, and
+// every tool call must be answered exactly once, as providers require.
+func (a *Agent) seedHistory(in *HeadlessInput) error {
+	var messages []llm.Message
+	system := strings.TrimSpace(in.System)
+	pending := make(map[string]string) // Unanswered tool call IDs to tool names
+	for i, m := range in.Messages {
+		n := i + 1
+		if len(pending) > 0 && m.Role != string(llm.RoleTool) {
+			return fmt.Errorf("message %d: tool calls %s have no tool message answering them", n, strings.Join(sortedKeys(pending), ", "))
+		}
+		switch llm.Role(m.Role) {
+		case llm.RoleSystem:
+			// Folded into the system prompt; providers take one
+			system = strings.TrimSpace(system + "\n\n" + m.Content)
+		case llm.RoleUser:
+			msg := llm.Message{Role: llm.RoleUser, Content: m.Content}
+			for _, path := range m.Attachments {
+				if lang.IsImage(path) {
+					if _, err := os.Stat(path); err != nil {
+						return fmt.Errorf("message %d: %w", n, err)
+					}
+					msg.Images = append(msg.Images, path)
+					continue
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("message %d: %w", n, err)
+				}
+				if bytes.IndexByte(data, 0) >= 0 {
+					return fmt.Errorf("message %d: %s is binary; only text files and images can be attached", n, path)
+				}
+				a.attached = append(a.attached, attachedFile{path: path, content: string(data)})
+			}
+			msg.Content += a.attachmentContext()
+			if strings.TrimSpace(msg.Content) == "" && len(msg.Images) == 0 {
+				return fmt.Errorf("message %d: user message is empty", n)
+			}
+			messages = append(messages, msg)
+		case llm.RoleAssistant:
+			if len(m.Attachments) > 0 {
+				return fmt.Errorf("message %d: only user messages can have attachments", n)
+			}
+			for _, tc := range m.ToolCalls {
+				if tc.ID == "" || tc.Name == "" {
+					return fmt.Errorf("message %d: tool calls need an id and a name", n)
+				}
+				pending[tc.ID] = tc.Name
+			}
+			messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: m.Content, ToolCalls: m.ToolCalls})
+		case llm.RoleTool:
+			name, ok := pending[m.ToolCallID]
+			if !ok {
+				return fmt.Errorf("message %d: tool_call_id %q does not answer a tool call of the assistant message before it", n, m.ToolCallID)
+			}
+			delete(pending, m.ToolCallID)
+			if m.ToolName != "" {
+				name = m.ToolName
+			}
+			messages = append(messages, llm.Message{Role: llm.RoleTool, ToolResult: &llm.ToolResult{ToolCallID: m.ToolCallID, ToolName: name, Content: m.Content, IsError: m.IsError}})
+		default:
+			return fmt.Errorf("message %d: unknown role %q; use system, user, assistant or tool", n, m.Role)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("tool calls %s have no tool message answering them", strings.Join(sortedKeys(pending), ", "))
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role == llm.RoleAssistant {
+		return fmt.Errorf("the conversation must end with a user or tool message for the model to answer")
+	}
+
+	if system != "" {
+		a.history[0].Content += "\n\n" + system
+	}
+	a.history = append(a.history, messages...)
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RunHeadless runs john -p: it seeds the conversation, then runs the agent
+// loop until the model stops. No one is at the terminal, so ask rules deny,
+// and a workspace that was never trusted is read-only.
+func (a *Agent) RunHeadless(in *HeadlessInput) error {
+	a.headless = true
+	if cwd, err := os.Getwd(); err == nil {
+		if trusted, _ := config.WorkspaceTrust(cwd); !trusted && !a.skipPermissions() {
+			a.setReadOnly(true)
+		}
+	}
+	a.applyPolicy()
+	if err := a.seedHistory(in); err != nil {
+		return err
+	}
+	return a.processTurn()
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// probeTimeout bounds one provider health probe
+const probeTimeout = 15 * time.Second
+
+// Thresholds past which a probe phase counts as slow
+const (
+	slowNetwork = time.Second
+	slowServer  = 2 * time.Second
+)
+
+// probeProvider probes the provider behind client, or returns nil when the
+// client cannot be probed, such as the mock client
+func probeProvider(ctx context.Context, client llm.Client) *llm.Probe {
+	hc, ok := client.(llm.HealthClient)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	return hc.Probe(ctx)
+}
+
+// formatProbe shows a probe on one line with its phases
+func formatProbe(p *llm.Probe) string {
+	var sb strings.Builder
+	if p.OK() {
+		sb.WriteString("ok")
+	} else {
+		fmt.Fprintf(&sb, "failed (%v)", p.Err)
+	}
+	if p.Total == 0 {
+		return sb.String()
+	}
+	if p.Reused {
+		sb.WriteString(", network: open connection")
+	} else {
+		fmt.Fprintf(&sb, ", network %s (DNS %s, connect %s, TLS %s)", formatDuration(p.Network()),
+			formatDuration(p.DNS), formatDuration(p.Connect), formatDuration(p.TLS))
+	}
+	if p.Status != 0 {
+		fmt.Fprintf(&sb, ", server %s", formatDuration(p.Server))
+	}
+	fmt.Fprintf(&sb, ", total %s", formatDuration(p.Total))
+	return sb.String()
+}
+
+// diagnoseProbe says where slowness or failure lies: the network, the
+// provider, or neither. typical is the model's usual time to first token,
+// 0 if unknown; when the probe is quick but requests are slow, the time
+// goes to generating long prompts and answers, or to john itself.
+func diagnoseProbe(p *llm.Probe, typical time.Duration) string {
+	switch {
+	case p.Status == 0 && p.Err != nil:
+		return "The provider could not be reached: check your network connection, proxy settings and firewall."
+	case p.Status == 401 || p.Status == 403:
+		return "The provider refused the credentials: check the API key or run john login again."
+	case p.Status == 429:
+		return "The provider is rate limiting this key; requests will be retried more slowly."
+	case p.Status >= 500:
+		return "The provider is having problems; check its status page."
+	case p.Err != nil:
+		return "The provider answered with an error."
+	case !p.Reused && p.Network() > slowNetwork:
+		return "Your network is slow to reach the provider."
+	case p.Server > slowServer:
+		return "The provider is slow to answer."
+	case typical > 10*time.Second:
+		return fmt.Sprintf("The network and provider answer quickly, but first tokens take %s on average: "+
+			"large contexts, long outputs or busy models, rather than the connection, are what is slow. /compact can help.", formatDuration(typical))
+	}
+	return "The network and provider are answering normally."
+}
+
+// showStatus prints the model and session and probes the provider
+func (a *Agent) showStatus() error {
+	var sb strings.Builder
+	model := a.currentModel
+	if m := llm.GetModelByID(a.currentModel); m != nil {
+		model = fmt.Sprintf("%s (%s)", m.Name, m.ID)
+	}
+	fmt.Fprintf(&sb, "Model:     %s\n", model)
+	if a.session != nil {
+		fmt.Fprintf(&sb, "Session:   %s\n", a.session.SessionID)
+	} else {
+		sb.WriteString("Session:   not saved\n")
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		fmt.Fprintf(&sb, "Directory: %s\n", cwd)
+	}
+
+	// Time to first token of this session's model turns, for comparison
+	var turns, streamed int
+	var ttft time.Duration
+	for _, e := range a.timeline {
+		if e.kind == timelineModel {
+			turns++
+			if e.ttft > 0 {
+				streamed++
+				ttft += e.ttft
+			}
+		}
+	}
+	var typical time.Duration
+	if streamed > 0 {
+		typical = ttft / time.Duration(streamed)
+		fmt.Fprintf(&sb, "Requests:  %s this session, first token after %s on average\n", plural(turns, "request"), formatDuration(typical))
+	}
+
+	a.ui.Print(strings.TrimRight(sb.String(), "\n"))
+	p := probeProvider(context.Background(), a.client)
+	if p == nil {
+		a.ui.Print("Provider:  no API key, so the mock client is answering; nothing to probe")
+		return nil
+	}
+	a.ui.Print(fmt.Sprintf("Provider:  %s %s\n\n%s", p.Provider, formatProbe(p), diagnoseProbe(p, typical)))
+	return nil
+}
+
+// RunDoctor checks the settings and probes every provider john has
+// credentials for, for john doctor. It returns an error when a configured
+// provider fails its probe.
+func RunDoctor(cfg *config.Config, w io.Writer) error {
+	if _, err := config.LoadSettings(); err != nil {
+		fmt.Fprintf(w, "Settings:  %v\n", err)
+	} else {
+		fmt.Fprintln(w, "Settings:  ok")
+	}
+
+	// Built quietly: missing keys are reported below rather than as warnings
+	a := New(cfg, ui.NewScripted(strings.NewReader(""), io.Discard))
+	var stats *history.Stats
+	if root, err := history.DefaultRoot(); err == nil {
+		stats, _ = history.LoadStats(root)
+	}
+	fmt.Fprintf(w, "Model:     %s\n\n", a.currentModel)
+
+	failed := 0
+	for _, provider := range []llm.Provider{llm.ProviderAnthropic, llm.ProviderOpenAI, llm.ProviderGoogle} {
+		modelID := ""
+		if m := llm.GetModelByID(a.currentModel); m != nil && m.Provider == provider {
+			modelID = m.ID
+		} else {
+			for _, m := range llm.SupportedModels {
+				if m.Provider == provider {
+					modelID = m.ID
+					break
+				}
+			}
+		}
+		p := probeProvider(context.Background(), a.newProviderClient(modelID))
+		if p == nil {
+			fmt.Fprintf(w, "%-10s not configured\n", provider)
+			continue
+		}
+		var typical time.Duration
+		if stats != nil && stats.Models[modelID] != nil {
+			typical = stats.Models[modelID].AverageTTFT()
+		}
+		if !p.OK() {
+			failed++
+		}
+		fmt.Fprintf(w, "%-10s %s\n           %s\n", provider, formatProbe(p), diagnoseProbe(p, typical))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s failed", plural(failed, "provider probe"))
+	}
+	return nil
+}
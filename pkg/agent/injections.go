@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reminderRefresh is how many prompts an unchanged reminder is left out
+// before it is sent again, so it does not fade from a long conversation
+const reminderRefresh = 10
+
+// Kinds of system reminder added to prompts, as /cost names them
+const (
+	reminderMemory = "Project instructions"
+	reminderTodos  = "Empty todo list"
+)
+
+// injections tracks the system reminders added to user prompts. The model
+// keeps what it saw in earlier messages, so a reminder goes out when it is
+// new or has changed, and again every reminderRefresh prompts; in between
+// it is held back. Compaction and resuming drop what the model saw.
+type injections struct {
+	prompt int                         // Prompts sent so far
+	seen   map[string]injectedReminder // Last sent, by kind
+	kinds  []string                    // In order of first use, for /cost
+	sent   map[string]int              // Estimated tokens sent, by kind
+	held   map[string]int              // Estimated tokens held back, by kind
+}
+
+type injectedReminder struct {
+	content string
+	prompt  int
+}
+
+// nextPrompt starts the reminders of a new prompt
+func (in *injections) nextPrompt() {
+	in.prompt++
+}
+
+// remind decides whether a reminder of a kind goes with this prompt.
+// changed is true when the model saw an earlier version of it.
+func (in *injections) remind(kind, content string) (send, changed bool) {
+	if in.seen == nil {
+		in.seen = make(map[string]injectedReminder)
+	}
+	if in.sent == nil {
+		in.sent, in.held = make(map[string]int), make(map[string]int)
+	}
+	if _, ok := in.sent[kind]; !ok {
+		in.kinds = append(in.kinds, kind)
+		in.sent[kind] = 0
+	}
+	last, ok := in.seen[kind]
+	if ok && last.content == content && in.prompt-last.prompt < reminderRefresh {
+		in.held[kind] += estimateTokens(content)
+		return false, false
+	}
+	in.seen[kind] = injectedReminder{content: content, prompt: in.prompt}
+	in.sent[kind] += estimateTokens(content)
+	return true, ok && last.content != content
+}
+
+// forget makes every reminder go out again with the next prompt, after
+// the messages that carried them were compacted or replaced
+func (in *injections) forget() {
+	in.seen = nil
+}
+
+// promptReminders returns the system reminders to append to a user prompt:
+// the project instructions and the empty todo list reminder, each only when
+// the model has not seen it recently
+func (a *Agent) promptReminders() string {
+	a.injections.nextPrompt()
+	var sb strings.Builder
+
+	if tt := a.todoTool(); tt != nil && len(tt.Todos) == 0 {
+		reminder := "This is a reminder that your todo list is currently empty. DO NOT mention this to the user explicitly because they are already aware. If you are working on tasks that would benefit from a todo list please use the TodoWrite tool to create one. If not, please feel free to ignore. Again do not mention this message to the user."
+		if send, _ := a.injections.remind(reminderTodos, reminder); send {
+			sb.WriteString("\n<system-reminder>\n" + reminder + "\n</system-reminder>")
+		}
+	}
+
+	if fname := findMemoryFile("."); fname != "" && !a.safeMode() {
+		if mem, err := a.loadMemory(fname); err == nil {
+			if send, changed := a.injections.remind(reminderMemory, fname+"\n"+mem.content); send {
+				note := ""
+				if changed {
+					note = fmt.Sprintf("\n%s changed since you last saw it. The current version below replaces the earlier one.\n", fname)
+				}
+				sb.WriteString(fmt.Sprintf("\n<system-reminder>\nAs you answer the user's questions, you can use the following context:\n# claudeMd\nCodebase and user instructions are shown below. Be sure to adhere to these instructions. IMPORTANT: These instructions OVERRIDE any default behavior and you MUST follow them exactly as written.\n%s\nContents of %s (project instructions, checked into the codebase):\n\n%s\n</system-reminder>", note, fname, mem.content))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// writeInjections adds the reminder tokens of this session to /cost
+func (in *injections) writeInjections(sb *strings.Builder) {
+	if len(in.kinds) == 0 {
+		return
+	}
+	total, held := 0, 0
+	for _, kind := range in.kinds {
+		total += in.sent[kind]
+		held += in.held[kind]
+	}
+	sb.WriteString(fmt.Sprintf("\nSystem reminders: ~%s tokens sent with prompts, ~%s held back as already seen\n",
+		formatTokens(total), formatTokens(held)))
+	for _, kind := range in.kinds {
+		sb.WriteString(fmt.Sprintf("  %-42s ~%s sent / ~%s held back\n", kind, formatTokens(in.sent[kind]), formatTokens(in.held[kind])))
+	}
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/i18n"
+)
+
+// startInstance registers this process in the workspace, warns if other john
+// instances are active there, and gives the session its own temp directory
+// so commands from different instances don't share scratch files.
+func (a *Agent) startInstance(cwd string) {
+	if root, err := history.DefaultRoot(); err == nil && a.session != nil {
+		others, release, err := history.RegisterInstance(root, cwd, a.session.SessionID)
+		if err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: %v", err))
+		} else {
+			a.unregister = release
+		}
+		for _, o := range others {
+			a.ui.Print(fmt.Sprintf("Warning: another john instance (pid %d, session %s) has been active in this workspace since %s.",
+				o.PID, history.ShortID(o.SessionID), i18n.FormatTime(o.Started)))
+		}
+		if len(others) > 0 {
+			a.ui.Print("File edits and background shells are not coordinated between instances; avoid working on the same files.")
+		}
+	}
+
+	prefix := "john-"
+	if a.session != nil {
+		prefix += history.ShortID(a.session.SessionID) + "-"
+	}
+	dir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: Failed to create session temp dir: %v", err))
+		return
+	}
+	a.tempDir = dir
+	// Inherited by tool commands, and used for clipboard images
+	os.Setenv("TMPDIR", dir)
+	os.Setenv("JOHN_SESSION_TMP", dir)
+}
+
+// stopInstance undoes startInstance on exit
+func (a *Agent) stopInstance() {
+	if a.unregister != nil {
+		a.unregister()
+	}
+	if a.tempDir != "" && strings.Contains(a.tempDir, "john-") {
+		os.RemoveAll(a.tempDir)
+	}
+}
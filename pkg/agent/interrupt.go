@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// ErrInterrupted is returned when the user stops a response with Ctrl+C
+var ErrInterrupted = errors.New("response interrupted")
+
+// interruptible returns a context for one model request that Ctrl+C
+// cancels, so the user can stop a response without quitting john. stop
+// restores the default Ctrl+C and reports whether it was pressed.
+func interruptible(ctx context.Context) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	done := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+			interrupted <- true
+		case <-done:
+			interrupted <- false
+		}
+	}()
+	return ctx, func() bool {
+		signal.Stop(sig)
+		close(done)
+		cancel()
+		return <-interrupted
+	}
+}
+
+// keepPartialResponse adds the text streamed before a request was cancelled
+// or failed to history as an interrupted assistant message, so what the user
+// saw is not lost and the model knows it was cut off. It reports whether
+// there was any text to keep.
+func (a *Agent) keepPartialResponse(streamed string, resp *llm.Message) bool {
+	if strings.TrimSpace(streamed) == "" {
+		return false
+	}
+	msg := llm.Message{Role: llm.RoleAssistant, Content: streamed, Interrupted: true}
+	// Tool calls of a cut-off response were never run, so they are dropped
+	if resp != nil {
+		msg.Usage = resp.Usage
+		msg.RequestID = resp.RequestID
+	}
+	a.history = append(a.history, msg)
+	if a.session != nil {
+		if err := a.session.Append(llm.RoleAssistant, msg); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to log assistant message: %v", err))
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+const planModeNotice = "\n\n<plan-mode>\nThe user switched on plan mode: you can read and search files but cannot edit them, run commands or use MCP tools. Investigate and propose a plan; the user will switch plan mode off when they want it carried out.\n</plan-mode>"
+
+// setupKeys applies the key bindings in settings, warning about invalid
+// ones, which keep their defaults
+func (a *Agent) setupKeys() {
+	var keys map[string]string
+	if a.cfg != nil && a.cfg.Settings != nil {
+		keys = a.cfg.Settings.Keys
+	}
+	if err := a.ui.SetKeys(keys); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: %v", strings.ReplaceAll(err.Error(), "\n", "; ")))
+	}
+}
+
+// showKeys lists the prompt's key bindings for /keys
+func (a *Agent) showKeys() error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-12s %-11s %s\n", "Key", "Action", "Does")
+	for _, action := range ui.KeyActions {
+		key := a.ui.KeyFor(action.Name)
+		if key == "" {
+			key = "(none)"
+		}
+		fmt.Fprintf(&sb, "%-12s %-11s %s\n", key, action.Name, action.Description)
+	}
+	fmt.Fprintf(&sb, "%-12s %-11s %s\n", "Ctrl+C", "", "Always cancels the prompt, and stops a response")
+	sb.WriteString("\nChange them under \"keys\" in settings.json, e.g. {\"keys\": {\"palette\": \"ctrl+p\"}}; \"none\" unbinds an action.")
+	a.ui.Print(sb.String())
+	return nil
+}
+
+// togglePlanMode switches plan mode on or off. In plan mode, as in an
+// untrusted workspace, only read-only tools run and the model is asked for
+// a plan.
+func (a *Agent) togglePlanMode() {
+	if a.readOnly {
+		a.ui.Print("This workspace is read-only until you trust it with /trust.")
+		return
+	}
+	a.planMode = !a.planMode
+	if len(a.history) > 0 {
+		system := strings.ReplaceAll(a.history[0].Content, planModeNotice, "")
+		if a.planMode {
+			system += planModeNotice
+		}
+		a.history[0].Content = system
+	}
+	key := a.ui.KeyFor(ui.KeyPlanMode)
+	if a.planMode {
+		a.ui.Print(fmt.Sprintf("Plan mode on: only read-only tools run. Press %s to switch it off.", key))
+	} else {
+		a.ui.Print("Plan mode off: tools can edit files and run commands again.")
+	}
+}
+
+// copyLastResponse puts the model's last response on the clipboard
+func (a *Agent) copyLastResponse() {
+	text := ""
+	for i := len(a.history) - 1; i > 0 && text == ""; i-- {
+		if a.history[i].Role == llm.RoleAssistant {
+			text = strings.TrimSpace(a.history[i].Content)
+		}
+	}
+	if text == "" {
+		a.ui.Print("There is no response to copy yet.")
+		return
+	}
+	via, err := a.ui.CopyToClipboard(text)
+	if err != nil {
+		a.ui.Print(fmt.Sprintf("Failed to copy the response: %v", err))
+		return
+	}
+	if via == "terminal" {
+		a.ui.Print(fmt.Sprintf("Asked the terminal to copy the last response (%d characters); install a clipboard tool if nothing arrives.", len(text)))
+		return
+	}
+	a.ui.Print(fmt.Sprintf("Copied the last response (%d characters).", len(text)))
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/i18n"
+)
+
+// setLanguage applies the language and time settings: john's messages are
+// translated where the catalog has them, times are shown in the configured
+// zone and format, and the system prompt asks the model to answer in the
+// language
+func (a *Agent) setLanguage() {
+	language := ""
+	var timeSettings config.TimeSettings
+	if a.cfg != nil && a.cfg.Settings != nil {
+		language = strings.TrimSpace(a.cfg.Settings.Language)
+		timeSettings = a.cfg.Settings.Time
+	}
+	i18n.SetLanguage(language)
+	if err := i18n.SetTime(timeSettings.Zone, timeSettings.Format); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: time in settings: %v", err))
+	}
+	if len(a.history) == 0 {
+		return
+	}
+
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<language>"); i >= 0 {
+		end := strings.Index(system[i:], "</language>")
+		system = system[:i] + system[i+end+len("</language>"):]
+	}
+	if l, ok := i18n.Lookup(language); ok {
+		language = fmt.Sprintf("%s (%s)", l.Name, l.Native)
+		if l.Code == "en" {
+			language = "" // The default
+		}
+	}
+	if language != "" {
+		system += "\n\n<language>\nThe user's language is " + language + ". Always respond in it, including plans, " +
+			"questions and summaries, even when the code, tool output or earlier messages are in another language. " +
+			"Keep code, identifiers, commands, file paths and quoted output as they are.\n</language>"
+	}
+	a.history[0].Content = system
+}
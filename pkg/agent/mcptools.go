@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// mcpToolRunTimeout bounds a manual tool run from the browser
+const mcpToolRunTimeout = 2 * time.Minute
+
+// browseMCPTools handles /mcp tools: list every MCP tool by server, show its
+// schema, enable or disable it for this session, or run it by hand
+func (a *Agent) browseMCPTools() error {
+	for {
+		defs := a.mcpManager.GetAllTools()
+		if len(defs) == 0 {
+			a.ui.Print("No MCP tools are registered. Use /mcp to check server status.")
+			return nil
+		}
+		sort.Slice(defs, func(i, j int) bool {
+			if defs[i].ServerName != defs[j].ServerName {
+				return defs[i].ServerName < defs[j].ServerName
+			}
+			return defs[i].OriginalName < defs[j].OriginalName
+		})
+
+		infos := make([]ui.MCPToolInfo, len(defs))
+		byName := make(map[string]int)
+		for i, d := range defs {
+			infos[i] = ui.MCPToolInfo{
+				Name:        d.Name,
+				Server:      d.ServerName,
+				Tool:        d.OriginalName,
+				Description: d.Description,
+				Enabled:     !a.mcpDisabled[d.Name],
+			}
+			byName[d.Name] = i
+		}
+
+		selected := a.ui.PickMCPTool(infos)
+		if selected == "" {
+			return nil
+		}
+		info, def := infos[byName[selected]], defs[byName[selected]]
+
+		switch a.ui.PickMCPToolAction(info) {
+		case "schema":
+			var schema bytes.Buffer
+			if err := json.Indent(&schema, def.InputSchema, "", "  "); err != nil {
+				schema.Reset()
+				schema.Write(def.InputSchema)
+			}
+			a.ui.Print(fmt.Sprintf("\n%s (%s)\n%s\n\nInput schema:\n%s\n", info.Tool, info.Name, info.Description, schema.String()))
+			a.ui.Prompt("Press enter to go back ")
+		case "toggle":
+			if a.mcpDisabled == nil {
+				a.mcpDisabled = make(map[string]bool)
+			}
+			if info.Enabled {
+				a.mcpDisabled[info.Name] = true
+				a.ui.Print(fmt.Sprintf("Disabled %s for this session", info.Name))
+			} else {
+				delete(a.mcpDisabled, info.Name)
+				a.ui.Print(fmt.Sprintf("Enabled %s", info.Name))
+			}
+		case "run":
+			a.runMCPTool(info)
+		}
+	}
+}
+
+// runMCPTool calls a tool with JSON arguments typed by the user and prints
+// the result. Nothing is added to the conversation.
+func (a *Agent) runMCPTool(info ui.MCPToolInfo) {
+	input := strings.TrimSpace(a.ui.Prompt(fmt.Sprintf("%s arguments (JSON, empty for {}): ", info.Tool)))
+	if input == "exit" {
+		return // Esc cancels the prompt
+	}
+	if input == "" {
+		input = "{}"
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		a.ui.Print(fmt.Sprintf("Invalid JSON object: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mcpToolRunTimeout)
+	defer cancel()
+	start := time.Now()
+	result, err := a.mcpManager.CallTool(ctx, info.Server, info.Tool, json.RawMessage(input))
+	if err != nil {
+		a.ui.Print(fmt.Sprintf("%s failed after %s: %v", info.Tool, formatDuration(time.Since(start)), err))
+		return
+	}
+	a.ui.Print(fmt.Sprintf("%s returned %s in %s:\n%s\n", info.Tool, formatBytes(len(result)), formatDuration(time.Since(start)), result))
+}
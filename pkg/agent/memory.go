@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// memoryFileNames are the project instruction files, in order of preference.
+// Only the first one found in a directory is used.
+var memoryFileNames = []string{"CLAUDE.md", "AGENTS.md", ".claude.md"}
+
+// memoryMaxTokens is the size above which an instruction file is summarized
+// before injection instead of being sent verbatim with every message.
+const memoryMaxTokens = 4000
+
+// memoryFile is an instruction file as it is injected into the conversation.
+type memoryFile struct {
+	path       string
+	modTime    time.Time
+	size       int64
+	content    string // What is injected: the file itself or its summary
+	tokens     int    // Estimated tokens of the original file
+	summarized bool
+}
+
+// estimateTokens gives a rough token count (about four characters per token).
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// fileTools are the tools whose file_path / notebook_path arguments trigger
+// discovery of nested instruction files.
+var fileTools = map[string]string{
+	"Read":         "file_path",
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// findMemoryFile returns the instruction file in dir, or "" if there is none.
+func findMemoryFile(dir string) string {
+	for _, name := range memoryFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// nestedMemoryFor returns the instruction files that apply to path but have not
+// been injected yet, ordered from the outermost directory inwards. Only
+// directories below the working directory are considered; the root file is
+// injected with every user message already.
+func (a *Agent) nestedMemoryFor(cwd, path string) []string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+
+	rel, err := filepath.Rel(cwd, filepath.Dir(filepath.Clean(path)))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+
+	var files []string
+	dir := cwd
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		if a.loadedMemory[dir] {
+			continue
+		}
+		if file := findMemoryFile(dir); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// injectNestedMemory appends the instructions of any AGENTS.md / CLAUDE.md
+// found between the working directory and the file a tool touched, so that
+// directory-specific conventions are in view while working there. Each
+// directory is injected at most once per conversation.
+func (a *Agent) injectNestedMemory(tc llm.ToolCall, result string) string {
+	key, ok := fileTools[tc.Name]
+	if !ok {
+		return result
+	}
+	path, _ := tc.Args[key].(string)
+	cwd, err := os.Getwd()
+	if path == "" || err != nil {
+		return result
+	}
+
+	for _, file := range a.nestedMemoryFor(cwd, path) {
+		mem, err := a.loadMemory(file)
+		if err != nil {
+			continue
+		}
+		if a.loadedMemory == nil {
+			a.loadedMemory = make(map[string]bool)
+		}
+		a.loadedMemory[filepath.Dir(file)] = true
+
+		dir, _ := filepath.Rel(cwd, filepath.Dir(file))
+		reminder := fmt.Sprintf("\n<system-reminder>\nContents of %s (directory instructions for work in %s/):\n\n%s\n\nThese instructions apply to files in %s/ and OVERRIDE the project-level instructions there.\n</system-reminder>", file, dir, strings.TrimSpace(mem.content), dir)
+		result += reminder
+		// Injected once per conversation, so it must survive compaction
+		a.pinContent(file, strings.TrimSpace(reminder))
+	}
+	return result
+}
+
+// loadMemory returns the content to inject for an instruction file. Files over
+// memoryMaxTokens are summarized by the model once; the summary is cached on
+// disk under the hash of the file's content, so it is reused across sessions
+// and regenerated as soon as the file changes.
+func (a *Agent) loadMemory(path string) (*memoryFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if mem, ok := a.memoryCache[path]; ok && mem.modTime.Equal(info.ModTime()) && mem.size == info.Size() {
+		return mem, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mem := &memoryFile{
+		path:    path,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		content: string(data),
+		tokens:  estimateTokens(string(data)),
+	}
+
+	if mem.tokens > memoryMaxTokens {
+		summary, err := a.summarizeMemory(path, string(data))
+		if err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to summarize %s, truncating instead: %v", path, err))
+			mem.content = string(data[:memoryMaxTokens*4]) + "\n\n[Truncated: file exceeds the memory size limit]"
+		} else {
+			mem.content = summary
+		}
+		mem.summarized = true
+	}
+
+	if a.memoryCache == nil {
+		a.memoryCache = make(map[string]*memoryFile)
+	}
+	a.memoryCache[path] = mem
+	return mem, nil
+}
+
+// summarizeMemory condenses a large instruction file, using the on-disk cache
+// when the same content has been summarized before.
+func (a *Agent) summarizeMemory(path, content string) (string, error) {
+	sum := sha256.Sum256([]byte(content))
+	var cacheFile string
+	if root, err := history.DefaultRoot(); err == nil {
+		cacheFile = filepath.Join(root, "memory-cache", hex.EncodeToString(sum[:])+".md")
+		if cached, err := os.ReadFile(cacheFile); err == nil {
+			return string(cached), nil
+		}
+	}
+
+	a.ui.Print(fmt.Sprintf("Summarizing %s (~%d tokens)...", path, estimateTokens(content)))
+	resp, err := a.client.Generate(context.Background(), []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: "You condense project instruction files for a coding agent. Keep every rule, command, " +
+				"path, naming convention and prohibition. Drop prose, examples and repetition. Use terse markdown bullets.",
+		},
+		{
+			Role:    llm.RoleUser,
+			Content: fmt.Sprintf("Condense these instructions to under %d words:\n\n%s", memoryMaxTokens/2, content),
+		},
+	}, nil)
+	a.recordCost(costMemory, a.currentModel, resp)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	summary = fmt.Sprintf("[Summary of %s, which is too large to include in full. Read the file for details.]\n\n%s", filepath.Base(path), summary)
+
+	if cacheFile != "" {
+		if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err == nil {
+			os.WriteFile(cacheFile, []byte(summary), 0644)
+		}
+	}
+	return summary, nil
+}
+
+// activeMemory lists the instruction files currently in effect: the project
+// file in the working directory plus any nested files injected so far.
+func (a *Agent) activeMemory() []*memoryFile {
+	var files []*memoryFile
+	if root := findMemoryFile("."); root != "" {
+		if mem, err := a.loadMemory(root); err == nil {
+			files = append(files, mem)
+		}
+	}
+
+	var dirs []string
+	for dir := range a.loadedMemory {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if file := findMemoryFile(dir); file != "" {
+			if mem, err := a.loadMemory(file); err == nil {
+				files = append(files, mem)
+			}
+		}
+	}
+	return files
+}
+
+// showMemory handles /memory
+func (a *Agent) showMemory() error {
+	files := a.activeMemory()
+	if len(files) == 0 {
+		a.ui.Print("No memory files are active (add an AGENTS.md or CLAUDE.md to the project)")
+		return nil
+	}
+
+	a.ui.Print("Active memory files:")
+	total := 0
+	for _, mem := range files {
+		injected := estimateTokens(mem.content)
+		total += injected
+		line := fmt.Sprintf("  %s  ~%d tokens", mem.path, injected)
+		if mem.summarized {
+			line += fmt.Sprintf(" (summarized from ~%d)", mem.tokens)
+		}
+		a.ui.Print(line)
+	}
+	a.ui.Print(fmt.Sprintf("Total: ~%d tokens. Files over ~%d tokens are summarized.", total, memoryMaxTokens))
+	return nil
+}
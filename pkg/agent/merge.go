@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// maxMergeTranscript bounds each session's transcript sent for summarizing.
+// Longer sessions keep their most recent part.
+const maxMergeTranscript = 150000
+
+// MergeSessions writes a new session of this project whose context is a
+// summary of sessions a and b, with their todo lists merged, and returns its
+// ID. Resuming it continues both lines of work as one thread.
+func MergeSessions(cfg *config.Config, u *ui.UI, idA, idB string) (string, error) {
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return "", err
+	}
+	if err := UnlockSessions(cfg.Settings, u); err != nil {
+		return "", err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	var infos [2]*history.SessionInfo
+	var transcripts [2]*history.Transcript
+	for i, id := range []string{idA, idB} {
+		if infos[i], err = history.FindSession(root, cwd, id); err != nil {
+			return "", err
+		}
+		if transcripts[i], err = history.LoadTranscript(infos[i].FilePath); err != nil {
+			return "", err
+		}
+	}
+	if infos[0].ID == infos[1].ID {
+		return "", fmt.Errorf("cannot merge session %s with itself", infos[0].ID)
+	}
+
+	a := New(cfg, u)
+	client := a.client
+	if _, isMock := client.(*llm.MockClient); isMock {
+		return "", fmt.Errorf("no API key for %s", a.currentModel)
+	}
+
+	var todos [2][]tools.TodoItem
+	for i, t := range transcripts {
+		if t.Todos != nil {
+			if err := json.Unmarshal(t.Todos, &todos[i]); err != nil {
+				return "", fmt.Errorf("session %s: invalid todo list: %w", infos[i].ID, err)
+			}
+		}
+	}
+	merged, notes := tools.MergeTodos(todos[0], todos[1])
+
+	var prompt strings.Builder
+	prompt.WriteString("Below are two coding sessions on the same project. The user explored in one and worked in the other, " +
+		"and wants to continue both as a single thread. Write one handoff summary that covers both: the user's goals and " +
+		"requirements, decisions made, files created or changed, commands that matter, errors and how they were resolved, " +
+		"and open tasks. Where the sessions disagree (different decisions, or changes to the same files), say so and say " +
+		"which session is more recent. Be concise and factual.\n")
+	for i, t := range transcripts {
+		var sb strings.Builder
+		for _, msg := range t.Messages {
+			writeCompactTranscript(&sb, msg)
+		}
+		text := sb.String()
+		if len(text) > maxMergeTranscript {
+			text = "...[earlier part omitted]...\n" + text[len(text)-maxMergeTranscript:]
+		}
+		fmt.Fprintf(&prompt, "\n<session id=%q last-active=%q>\n%s</session>\n", infos[i].ID, infos[i].ModTime.Format("2006-01-02 15:04"), text)
+	}
+
+	u.Print(fmt.Sprintf("Summarizing %d and %d messages...", len(transcripts[0].Messages), len(transcripts[1].Messages)))
+	resp, err := client.Generate(context.Background(), []llm.Message{
+		{Role: llm.RoleSystem, Content: "You write handoff summaries of coding sessions."},
+		{Role: llm.RoleUser, Content: prompt.String()},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("summarizing failed: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("summarizing failed: empty summary")
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "This session merges two earlier sessions, %s and %s. Summary of both:\n\n%s", infos[0].ID, infos[1].ID, summary)
+	if len(notes) > 0 {
+		content.WriteString("\n\nTheir todo lists were merged with these conflicts resolved:\n- " + strings.Join(notes, "\n- "))
+	}
+
+	sm, err := history.NewSessionManagerInRoot(root, cwd)
+	if err != nil {
+		return "", err
+	}
+	if model := llm.GetModelByID(a.currentModel); model != nil {
+		sm.SetModel(model.APIModel)
+	}
+	for _, msg := range []llm.Message{
+		{Role: llm.RoleUser, Content: content.String()},
+		{Role: llm.RoleAssistant, Content: "Understood. I'll continue from this summary of both sessions."},
+	} {
+		if err := sm.Append(msg.Role, msg); err != nil {
+			return "", err
+		}
+	}
+	if len(merged) > 0 {
+		if err := sm.AppendTodos(merged); err != nil {
+			return "", err
+		}
+	}
+
+	for _, note := range notes {
+		u.Print("  " + note)
+	}
+	return sm.SessionID, nil
+}
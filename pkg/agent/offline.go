@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// While offline, the connection is checked after offlineFirstRetry, then
+// twice as long each time up to offlineMaxRetry, for offline.maxWait in all
+const (
+	offlineFirstRetry  = 2 * time.Second
+	offlineMaxRetry    = time.Minute
+	defaultOfflineWait = 10 * time.Minute
+)
+
+// offlineProbeTimeout bounds the check for whether the provider is back
+const offlineProbeTimeout = 5 * time.Second
+
+// offlineState is set while the provider cannot be reached
+type offlineState struct {
+	since  time.Time
+	reason string // e.g. "cannot resolve api.anthropic.com"
+	queued int    // Prompts sent while offline, waiting in the conversation
+	// The provider's client and model while the local fallback stands in
+	client llm.Client
+	model  string
+	// Closed by the background check once the provider can be reached
+	back      chan struct{}
+	stopCheck context.CancelFunc
+}
+
+// stop ends the background connection check, if any
+func (st *offlineState) stop() {
+	if st != nil && st.stopCheck != nil {
+		st.stopCheck()
+	}
+}
+
+// offlineWait waits out a check delay, or until ctx is done, and reports
+// whether it waited the whole delay
+var offlineWait = func(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// sendPrompt runs the turn of the prompt just added to the conversation. When
+// the provider cannot be reached, the prompt stays queued in the
+// conversation while john falls back to the local model, or returns to the
+// prompt and checks the connection in the background, instead of failing
+// with the network error.
+func (a *Agent) sendPrompt() error {
+	a.checkBackOnline()
+	err := a.processTurn()
+	if llm.IsOffline(err) {
+		return a.goOffline(err)
+	}
+	if err == nil && a.offline != nil && a.offline.client == nil {
+		a.backOnline()
+	}
+	return err
+}
+
+// goOffline handles a turn that failed to reach the provider
+func (a *Agent) goOffline(err error) error {
+	if a.offline == nil {
+		a.offline = &offlineState{since: a.clock()}
+	}
+	a.offline.reason = llm.OfflineReason(err)
+	a.offline.queued++
+	if a.offline.client == nil && a.useLocalModel() {
+		return a.processTurn()
+	}
+	a.ui.Print(fmt.Sprintf("Offline: %s. Your prompt is queued (%s in all) and goes with your next message; once the connection is back, Enter alone sends it.",
+		a.offline.reason, plural(a.offline.queued, "queued prompt")))
+	a.checkConnection()
+	return nil
+}
+
+// checkConnection checks in the background, with backoff, whether the
+// provider can be reached again, for offline.maxWait. It only closes
+// offline.back: the queued prompts are sent from the prompt loop.
+func (a *Agent) checkConnection() {
+	st := a.offline
+	maxWait := defaultOfflineWait
+	if a.cfg.Settings != nil && a.cfg.Settings.Offline.MaxWait > 0 {
+		maxWait = time.Duration(a.cfg.Settings.Offline.MaxWait)
+	}
+	st.stop() // A failed send starts the checks over
+	ctx, stop := context.WithCancel(context.Background())
+	back := make(chan struct{})
+	st.back, st.stopCheck = back, stop
+	client := a.client
+
+	go func() {
+		waited := time.Duration(0)
+		for delay := offlineFirstRetry; waited+delay <= maxWait; delay = min(delay*2, offlineMaxRetry) {
+			if !offlineWait(ctx, delay) {
+				return
+			}
+			waited += delay
+			if reachable(client) {
+				close(back)
+				return
+			}
+		}
+	}()
+}
+
+// sendQueued sends the prompts queued while offline, on Enter at an empty
+// prompt, if the provider can be reached
+func (a *Agent) sendQueued() error {
+	select {
+	case <-a.offline.back:
+	default:
+		if !reachable(a.client) {
+			a.ui.Print(fmt.Sprintf("Still offline (%s); %s queued.", a.offline.reason, plural(a.offline.queued, "prompt")))
+			return nil
+		}
+	}
+	err := a.processTurn()
+	if llm.IsOffline(err) {
+		a.offline.reason = llm.OfflineReason(err)
+		a.ui.Print(fmt.Sprintf("Still offline (%s); %s queued.", a.offline.reason, plural(a.offline.queued, "prompt")))
+		a.checkConnection()
+		return nil
+	}
+	a.backOnline()
+	return err
+}
+
+// promptLabel is the input prompt, which shows the queued prompts while
+// offline
+func (a *Agent) promptLabel() string {
+	if a.offline == nil || a.offline.client != nil || a.offline.queued == 0 {
+		return "> "
+	}
+	select {
+	case <-a.offline.back:
+		return fmt.Sprintf("[back online, %d queued; Enter sends] > ", a.offline.queued)
+	default:
+		return fmt.Sprintf("[offline, %d queued] > ", a.offline.queued)
+	}
+}
+
+// reachable checks whether the provider behind client answers at all,
+// cheaply where the client can be probed
+func reachable(client llm.Client) bool {
+	p := probeProvider(context.Background(), client)
+	return p == nil || !llm.IsOffline(p.Err)
+}
+
+// useLocalModel switches to the offline.ollamaModel fallback, if one is set
+// and Ollama is running, and reports whether it did
+func (a *Agent) useLocalModel() bool {
+	if a.cfg.Settings == nil || a.cfg.Settings.Offline.OllamaModel == "" {
+		return false
+	}
+	local := llm.NewOllamaClient(a.cfg.Settings.Offline.OllamaURL, a.cfg.Settings.Offline.OllamaModel)
+	ctx, cancel := context.WithTimeout(context.Background(), offlineProbeTimeout)
+	defer cancel()
+	if p := local.Probe(ctx); !p.OK() {
+		a.ui.Print(fmt.Sprintf("The local fallback model is not available (%s: %v)", p.Endpoint, p.Err))
+		return false
+	}
+	if a.cfg.Deterministic {
+		local.SetSampling(llm.DeterministicSampling())
+	}
+	a.offline.client, a.offline.model = a.client, a.currentModel
+	a.client, a.currentModel = local, "ollama/"+local.Model()
+	a.ui.Print(fmt.Sprintf("Offline: %s. Answering with the local model %s until the connection is back.", a.offline.reason, local.Model()))
+	return true
+}
+
+// checkBackOnline goes back to the provider before a prompt, when the local
+// fallback stands in and the provider can be reached again
+func (a *Agent) checkBackOnline() {
+	if a.offline == nil || a.offline.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), offlineProbeTimeout)
+	defer cancel()
+	if p := probeProvider(ctx, a.offline.client); p != nil && llm.IsOffline(p.Err) {
+		return
+	}
+	a.client, a.currentModel = a.offline.client, a.offline.model
+	a.offline.client = nil
+	a.backOnline()
+}
+
+// backOnline ends the offline state
+func (a *Agent) backOnline() {
+	a.offline.stop()
+	a.ui.Print(fmt.Sprintf("Back online after %s; using %s again.", formatDuration(a.clock().Sub(a.offline.since)), a.CurrentModelName()))
+	a.offline = nil
+}
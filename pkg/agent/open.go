@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// maxOpenSize bounds the files /open will page through
+const maxOpenSize = 10 * 1024 * 1024
+
+// openFile handles /open <path>[:line]: show a file in the pager. Nothing is
+// added to the conversation, so it costs no tokens.
+func (a *Agent) openFile(args string) error {
+	path := strings.TrimSpace(args)
+	if path == "" {
+		return fmt.Errorf("usage: /open <path>[:line]")
+	}
+
+	line := 0
+	if i := strings.LastIndex(path, ":"); i > 0 {
+		if n, err := strconv.Atoi(path[i+1:]); err == nil {
+			if _, statErr := os.Stat(path); statErr != nil {
+				path, line = path[:i], n
+			}
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxOpenSize {
+		return fmt.Errorf("%s is too large to view (%s)", path, formatBytes(int(info.Size())))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return fmt.Errorf("%s looks like a binary file", path)
+	}
+
+	return a.ui.ViewFile(path, string(data), line)
+}
+
+// setupHyperlinks links path:line references in output to the editor named
+// in the hyperlinks settings
+func (a *Agent) setupHyperlinks() {
+	settings := config.HyperlinkSettings{}
+	if a.cfg != nil && a.cfg.Settings != nil {
+		settings = a.cfg.Settings.Hyperlinks
+	}
+	template, err := settings.Template()
+	if err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: %v", err))
+	}
+	a.ui.SetHyperlinks(template)
+}
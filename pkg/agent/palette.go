@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// paletteAction is an entry of the Ctrl+K palette. Choosing it runs input
+// as if the user typed it, after the answer to ask when it needs one.
+type paletteAction struct {
+	title       string
+	description string
+	input       string
+	ask         string
+}
+
+// paletteActions are the things users do often enough to want them a few
+// keystrokes away, phrased as tasks rather than command names
+var paletteActions = []paletteAction{
+	{title: "Switch model", description: "Pick the model for the next turns", input: "/model"},
+	{title: "Resume a session", description: "Continue an earlier conversation in this project", input: "/resume"},
+	{title: "Show or hide todos", description: "Expand or collapse the todo panel", input: "/todos"},
+	{title: "Compact the conversation", description: "Summarize older messages to free up context", input: "/compact"},
+	{title: "Pin the last response", description: "Keep it verbatim through compaction", input: "/pin"},
+	{title: "Attach files", description: "Add files matching a glob to the next message", input: "/add ", ask: "Files (glob): "},
+	{title: "Rename this session", description: "Name it, to find it in /resume", input: "/rename ", ask: "Session name: "},
+	{title: "Tag this session", description: "Add tags (-tag removes one, favorite stars it)", input: "/tag ", ask: "Tags: "},
+	{title: "Show uncommitted changes", description: "Files changed since the last commit", input: "/diff"},
+	{title: "Start a scratch branch", description: "Experiment on a git branch you can merge or discard", input: "/branch ", ask: "Branch name: "},
+	{title: "Finish the scratch branch", description: "Merge, squash, keep or discard it", input: "/branch done"},
+	{title: "Show background shells", description: "Dev servers and watchers the agent started, and their ports", input: "/servers"},
+	{title: "Follow a log or shell", description: "Watch for new lines, like tail -f", input: "/follow ", ask: "File or shell ID, then seconds and a pattern: "},
+	{title: "Show session cost", description: "Spend by main agent, sub-agent and kind of call", input: "/cost"},
+	{title: "Show the timeline", description: "Tool calls, durations and tokens of this session", input: "/timeline"},
+	{title: "Show recent errors", description: "Provider and tool errors with suggested fixes", input: "/errors"},
+}
+
+// runPalette shows the Ctrl+K palette and returns the input for the chosen
+// action, or "" when the user cancels
+func (a *Agent) runPalette() string {
+	var infos []ui.ActionInfo
+	for i, action := range paletteActions {
+		// Skip actions whose command this session does not have
+		name, _, _ := strings.Cut(strings.TrimPrefix(action.input, "/"), " ")
+		if _, ok := a.commands.Get(name); !ok {
+			continue
+		}
+		infos = append(infos, ui.ActionInfo{ID: strconv.Itoa(i), Title: action.title, Description: action.description})
+	}
+	i, err := strconv.Atoi(a.ui.PickAction(infos))
+	if err != nil {
+		return ""
+	}
+	action := paletteActions[i]
+	if action.ask == "" {
+		return action.input
+	}
+	answer := a.ui.Prompt(action.ask)
+	if answer == "" || answer == "exit" {
+		return ""
+	}
+	return action.input + answer
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/lang"
+)
+
+const (
+	pasteMaxRefs      = 5 // Locations attached per prompt
+	pasteContextLines = 6 // Lines shown either side of a location
+)
+
+// errorLocationPatterns find file:line references in compiler errors and
+// stack traces. The first group is the path, the second the line.
+var errorLocationPatterns = []*regexp.Regexp{
+	// Python: File "app/main.py", line 12, in handler
+	regexp.MustCompile(`File "([^"]+)", line (\d+)`),
+	// Java/Kotlin: at com.x.Foo.bar(Foo.java:42)
+	regexp.MustCompile(`\(([\w./-]+\.(?:java|kt|scala)):(\d+)\)`),
+	// Go, TypeScript, Rust, gcc, Node stacks: path/to/file.go:12:5
+	regexp.MustCompile(`([\w./@~-]*[\w-]\.[A-Za-z]{1,6}):(\d+)(?::\d+)?`),
+}
+
+// errorMarkers distinguish pasted error output from a prompt that merely
+// mentions a file:line
+var errorMarkers = []string{
+	"error", "Error", "panic:", "Traceback", "Exception", "FAIL", "undefined", "warning:", "goroutine ", "    at ",
+}
+
+// errorRef is a source location referenced by pasted error output
+type errorRef struct {
+	path string
+	line int
+}
+
+// findErrorRefs returns the existing files referenced by text, if the text
+// looks like error output
+func findErrorRefs(text string) []errorRef {
+	if !strings.Contains(strings.TrimSpace(text), "\n") {
+		return nil
+	}
+	marked := false
+	for _, m := range errorMarkers {
+		if strings.Contains(text, m) {
+			marked = true
+			break
+		}
+	}
+	if !marked {
+		return nil
+	}
+
+	seen := make(map[errorRef]bool)
+	var refs []errorRef
+	for _, line := range strings.Split(text, "\n") {
+		for _, re := range errorLocationPatterns {
+			matches := re.FindAllStringSubmatch(line, -1)
+			for _, m := range matches {
+				n, err := strconv.Atoi(m[2])
+				if err != nil || n < 1 {
+					continue
+				}
+				ref := errorRef{path: filepath.Clean(m[1]), line: n}
+				if info, err := os.Stat(ref.path); err == nil && !info.IsDir() && !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+				if len(refs) == pasteMaxRefs {
+					return refs
+				}
+			}
+			if len(matches) > 0 {
+				break // The most specific pattern that matches wins
+			}
+		}
+	}
+	return refs
+}
+
+// errorContext builds a reminder with the source around each location in
+// pasted error output, fenced with its language, so "fix this" works without the model reading the
+// files first. It returns "" if input isn't error output.
+func (a *Agent) errorContext(input string) string {
+	refs := findErrorRefs(input)
+	if len(refs) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n<system-reminder>\nThe user pasted error output. Source at the referenced locations:\n")
+	for _, ref := range refs {
+		snippet, err := sourceSnippet(ref.path, ref.line)
+		if err != nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s:%d\n%s\n", ref.path, ref.line, lang.Fence(ref.path, snippet)))
+		a.ui.Print(fmt.Sprintf("Attached %s:%d", ref.path, ref.line))
+	}
+	sb.WriteString("\nRead more of these files if the snippets are not enough.\n</system-reminder>")
+	return sb.String()
+}
+
+// sourceSnippet returns the lines around line, numbered like the Read tool,
+// with the referenced line marked
+func sourceSnippet(path string, line int) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return "", fmt.Errorf("%s has only %d lines", path, len(lines))
+	}
+	start := max(1, line-pasteContextLines)
+	end := min(len(lines), line+pasteContextLines)
+
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		sb.WriteString(fmt.Sprintf("%s%6d\t%s\n", marker, i, lines[i-1]))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/project"
+)
+
+// checkPermission applies the permission rules from settings to a tool call.
+// It returns an error message for the model when the call must not run.
+func (a *Agent) checkPermission(tc llm.ToolCall) (string, bool) {
+	if denial, ok := a.checkPolicy(tc.Name); !ok {
+		return denial, false
+	}
+	if denial, ok := a.checkCommandScope(tc.Name); !ok {
+		return denial, false
+	}
+	if denial, ok := a.checkReadOnly(tc.Name); !ok {
+		if a.planMode && !a.readOnly {
+			// Investigating is done once the model reaches for a change, so
+			// the next response is the plan, without tool calls
+			a.toolChoice = llm.ToolChoice{Mode: llm.ToolChoiceNone}
+		}
+		return denial, false
+	}
+	if denial, ok := a.checkTarget(tc.Name); !ok {
+		return denial, false
+	}
+	if denial, ok := a.checkConfined(tc); !ok {
+		return denial, false
+	}
+	if warning, ok := a.checkTooling(tc); !ok {
+		return warning, false
+	}
+	if a.cfg == nil || a.cfg.Settings == nil {
+		return a.checkPublish(tc, config.DecisionAsk)
+	}
+
+	decision, rule := a.cfg.Settings.Permissions.Check(tc.Name, tc.Args)
+	if decision == config.DecisionAllow && a.policy().PermissionMode == config.PermissionModeAsk && !readOnlyTools[tc.Name] {
+		decision, rule = config.DecisionAsk, "policy.permissionMode ask"
+	}
+	if decision == config.DecisionAllow && a.safeMode() && !readOnlyTools[tc.Name] {
+		decision, rule = config.DecisionAsk, "safe mode"
+	}
+	switch decision {
+	case config.DecisionDeny:
+		return fmt.Sprintf("Error: %s was denied by the permission rule %q in settings. Do not retry it; find another way or ask the user.", tc.Name, rule), false
+	case config.DecisionAsk:
+		if a.skipPermissions() {
+			break
+		}
+		if a.headless {
+			return fmt.Sprintf("Error: %s needs approval under the permission rule %q, and no one can approve it in this session. Do not retry it; find another way or explain what you would run.", tc.Name, rule), false
+		}
+		answer := a.ui.Prompt(i18n.Tf("Allow %s (rule %q)? [y/N] ", describeToolCall(tc), rule))
+		if i18n.IsYes(answer) {
+			return "", true
+		}
+		return fmt.Sprintf("Error: the user declined to run %s. Ask the user how to proceed.", tc.Name), false
+	}
+	return a.checkPublish(tc, a.cfg.Settings.Permissions.PublishDecision())
+}
+
+// checkPublish confirms a Bash command that publishes or deploys, whatever
+// the permission rules or --dangerously-skip-permissions allow, unless
+// permissions.publish says otherwise
+func (a *Agent) checkPublish(tc llm.ToolCall, decision config.Decision) (string, bool) {
+	if tc.Name != "Bash" || decision == config.DecisionAllow {
+		return "", true
+	}
+	command, _ := tc.Args["command"].(string)
+	action := project.PublishCommand(command)
+	if action == "" {
+		return "", true
+	}
+	if decision == config.DecisionDeny {
+		return fmt.Sprintf("Error: `%s` publishes or deploys, which permissions.publish denies in settings. Do not retry it; tell the user what you would run.", action), false
+	}
+	if a.headless {
+		return fmt.Sprintf("Error: `%s` publishes or deploys and needs the user's confirmation, which no one can give in this session. Do not retry it; tell the user what you would run, or set permissions.publish to \"allow\" for unattended runs.", action), false
+	}
+	answer := a.ui.Prompt(i18n.Tf("`%s` publishes or deploys. Run it? [y/N] ", action))
+	if i18n.IsYes(answer) {
+		return "", true
+	}
+	return fmt.Sprintf("Error: the user declined to run `%s`. Ask the user how to proceed.", action), false
+}
+
+// describeToolCall gives a one-line summary of a tool call for prompts
+func describeToolCall(tc llm.ToolCall) string {
+	for _, key := range []string{"command", "file_path", "notebook_path", "url", "pattern"} {
+		if v, ok := tc.Args[key].(string); ok && v != "" {
+			return fmt.Sprintf("%s(%s)", tc.Name, truncate(v, 80))
+		}
+	}
+	return tc.Name
+}
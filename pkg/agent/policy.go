@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// policy returns the organization's policy from the managed settings file
+func (a *Agent) policy() config.PolicySettings {
+	if a.cfg == nil || a.cfg.Settings == nil {
+		return config.PolicySettings{}
+	}
+	return a.cfg.Settings.Policy
+}
+
+// resolveModel resolves a model ID or alias like llm.ResolveModel, refusing
+// models of providers the policy does not allow
+func (a *Agent) resolveModel(name string) (*llm.ModelInfo, error) {
+	model, err := llm.ResolveModel(name, a.modelAliases())
+	if err != nil {
+		return nil, err
+	}
+	if !a.policy().AllowsProvider(string(model.Provider)) {
+		return nil, fmt.Errorf("%s models are not allowed by your organization's policy", model.Provider)
+	}
+	return model, nil
+}
+
+// allowedModel returns modelID if the policy allows its provider, else the
+// first supported model that is allowed
+func (a *Agent) allowedModel(modelID string) string {
+	policy := a.policy()
+	if m := llm.GetModelByID(modelID); m == nil || policy.AllowsProvider(string(m.Provider)) {
+		return modelID
+	}
+	for _, m := range llm.SupportedModels {
+		if policy.AllowsProvider(string(m.Provider)) {
+			return m.ID
+		}
+	}
+	return modelID
+}
+
+// checkPolicy blocks the tools the policy disallows. They are not offered
+// to the model, but a sub-agent or a stale conversation may still ask.
+func (a *Agent) checkPolicy(toolName string) (string, bool) {
+	if a.policy().AllowsTool(toolName) {
+		return "", true
+	}
+	return fmt.Sprintf("Error: %s is disabled by your organization's policy. Do not retry it; find another way or ask the user.", toolName), false
+}
+
+// applyPolicy puts the session in the permission mode the policy forces
+func (a *Agent) applyPolicy() {
+	switch a.policy().PermissionMode {
+	case config.PermissionModeReadOnly:
+		if !a.readOnly {
+			a.setReadOnly(true)
+		}
+		a.ui.Print("Your organization's policy keeps john in read-only mode: it can read and search but not edit files or run commands.")
+	case config.PermissionModeAsk:
+		a.ui.Print("Your organization's policy requires your approval for every tool that can change something.")
+	}
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/project"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// setupRepoScan measures the repository at startup. In a large one the model
+// is told its size and layout and to search rather than read broadly, and
+// Grep skips vendored directories unless asked to look inside them.
+func (a *Agent) setupRepoScan() {
+	tools.SearchIgnores = nil
+	if len(a.history) == 0 {
+		return
+	}
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<repo-scan>"); i >= 0 {
+		end := strings.Index(system[i:], "</repo-scan>")
+		system = system[:i] + system[i+end+len("</repo-scan>"):]
+	}
+	a.history[0].Content = system
+
+	files, err := projectFiles()
+	if err != nil {
+		return
+	}
+	scan := project.ScanFiles(files)
+	if !scan.Large() {
+		return
+	}
+
+	ignores := scan.IgnorePatterns()
+	tools.SearchIgnores = ignores
+	notice := "\n\n<repo-scan>\nThis is a large repository: " + scan.Summary() + "\n" +
+		"Reading broadly would fill the context window. Find code with Grep and Glob and read only the files, " +
+		"or the parts of files (offset and limit), that matter. Do not list or search the whole tree with Bash " +
+		"(ls -R, find ., grep -r), and do not read binary files."
+	if len(ignores) > 0 {
+		notice += " Grep skips the third-party and generated directories above unless its path points inside one."
+	}
+	a.history[0].Content = system + notice + "\n</repo-scan>"
+
+	message := fmt.Sprintf("Large repository (%d files); the model is told to search rather than read broadly", scan.Files)
+	if len(ignores) > 0 {
+		var dirs []string
+		for _, d := range scan.Vendored {
+			dirs = append(dirs, d.Path+"/")
+		}
+		message += ", and Grep skips " + strings.Join(dirs, ", ")
+	}
+	a.ui.Print(message)
+}
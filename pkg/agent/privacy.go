@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// EnableEphemeral keeps this session off disk (--ephemeral)
+func (a *Agent) EnableEphemeral() {
+	a.cfg.Ephemeral = true
+}
+
+// ephemeral reports whether the session, tool stats and debug logs are kept
+// off disk
+func (a *Agent) ephemeral() bool {
+	return a.cfg.Ephemeral || (a.cfg.Settings != nil && a.cfg.Settings.Privacy.Ephemeral)
+}
+
+// setupPrivacy runs before any session is read or written. Without the
+// passphrase for encrypted sessions the session runs ephemeral, rather than
+// being written in plain text.
+func (a *Agent) setupPrivacy() {
+	if a.ephemeral() {
+		a.ui.Print("Ephemeral mode: this session will not be saved")
+		return
+	}
+	if err := UnlockSessions(a.cfg.Settings, a.ui); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: %v; this session will not be saved", err))
+		a.cfg.Ephemeral = true
+	}
+}
+
+// UnlockSessions derives the session key when privacy.encryptSessions is
+// set, from $JOHN_SESSION_PASSPHRASE or by asking for the passphrase
+func UnlockSessions(settings *config.Settings, u *ui.UI) error {
+	if settings == nil || !settings.Privacy.EncryptSessions || history.SessionsEncrypted() {
+		return nil
+	}
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return err
+	}
+	passphrase := os.Getenv(history.PassphraseEnv)
+	if passphrase == "" {
+		passphrase = u.PromptSecret("Session passphrase: ")
+	}
+	return history.UnlockSessions(root, passphrase)
+}
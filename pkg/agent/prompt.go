@@ -105,6 +105,7 @@ Create and manage structured task lists.
 - Mark complete IMMEDIATELY after finishing (don't batch)
 - Exactly ONE task must be in_progress at any time
 - Complete current tasks before starting new ones
+- Optionally record ordering with dependsOn (ids that must be completed first) and deadlines with due (YYYY-MM-DD)
 
 ## **WebSearch**
 Search the web for up-to-date information.
@@ -121,6 +122,28 @@ Fetches content from URL and processes with AI model.
 - Results may be summarized if very large
 - When URL redirects to different host, make new WebFetch request with redirect URL
 
+## **Docs**
+Fetches the documentation of a dependency, condensed.
+**Key Instructions:**
+- Go: go doc for modules in the build, otherwise pkg.go.dev; npm: the registry README; PyPI: the project description
+- Use before calling an API you are unsure of, instead of guessing signatures
+- Pass symbol to keep only the sections about one function, type or option
+
+## **DepsAudit**
+Audits the dependencies in go.mod, package.json and requirements.txt.
+**Key Instructions:**
+- Start "update dependencies" and security tasks with it: it lists, most urgent first, known vulnerabilities (OSV) with their fixed versions, deprecations, major and minor upgrades, and unpinned versions
+- Major upgrades may break callers: check the changelog or Docs before taking one, and prefer the smallest version that fixes a vulnerability
+- After changing a manifest, update the lock file (go mod tidy, npm install, pip-compile) and run the build and tests
+
+## **RenameSymbol**
+Renames a symbol everywhere it is used across the project.
+**Key Instructions:**
+- Prefer this over a series of Edit calls when renaming a variable, function, type or field
+- Call without apply first to see the diff, then again with apply: true; all files are written at once
+- Go and TypeScript/JavaScript renames are semantic when gopls or tsserver is installed
+- Other files use a whole-word text match: check the preview for comments, strings and unrelated symbols before applying
+
 ## **NotebookEdit**
 Completely replaces contents of specific cell in Jupyter notebook.
 **Key Instructions:**
@@ -143,13 +166,28 @@ Retrieve output from running/completed background bash shell.
 - Takes shell_id parameter
 - Always returns only new output since last check
 - Supports optional regex filtering
-- Shell IDs found using /tasks command
+- Pass until (a regex for the ready line) to wait for a server to boot instead of sleeping; follow waits a number of seconds
+- Shell IDs found using /servers command
 
 ## **KillShell**
 Kills running background bash shell by ID.
 **Key Instructions:**
 - Returns success/failure status
-- Shell IDs found using /tasks command
+- Shell IDs found using /servers command
+
+## **Python**
+Execute Python code in a persistent interpreter session.
+**Key Instructions:**
+- State (variables, imports, loaded data) persists across calls, like a Jupyter kernel
+- Prefer this over one-shot python scripts via Bash when exploring data iteratively
+- The value of a trailing expression is returned
+- Use action=inspect to list variables and action=reset to start fresh
+
+## **Calc**
+Evaluate arithmetic exactly, with byte and time units.
+**Key Instructions:**
+- Use it instead of mental arithmetic for byte sizes, chunk counts, offsets, durations and percentages
+- Units: KB/MB/GB (powers of 1000), KiB/MiB/GiB (powers of 1024), ms/s/min/h/d; convert with "to", e.g. "3 GiB to MB"
 
 ## **AskUserQuestion**
 Ask user questions during execution.
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxReleaseLog and maxReleaseDiffs bound the commit log and the diffs sent
+// for /release-notes
+const (
+	maxReleaseLog   = 60000
+	maxReleaseDiffs = 40000
+)
+
+// releaseNoise matches files whose diffs say little about a release
+var releaseNoise = []string{"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "CHANGELOG.md", "vendor/", "node_modules/", "testdata/"}
+
+// releaseChanges gathers what /release-notes summarizes: the commits of
+// from..to with their messages, the diff stat, and the diffs of the most
+// changed files that are not tests, lock files or vendored code.
+func releaseChanges(from, to string) (string, error) {
+	for _, ref := range []string{from, to} {
+		if err := exec.Command("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run(); err != nil {
+			return "", fmt.Errorf("unknown git ref %q", ref)
+		}
+	}
+	span := from + ".." + to
+
+	log, err := gitOutput("log", "--no-color", "--format=commit %h%nAuthor: %an%n%n%B", span)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(log) == "" {
+		return "", fmt.Errorf("no commits in %s", span)
+	}
+	if len(log) > maxReleaseLog {
+		log = log[:maxReleaseLog] + "\n...[log truncated]..."
+	}
+	stat, err := gitOutput("diff", "--no-color", "--stat=120", span)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<commits>\n" + log + "</commits>\n\n<diffstat>\n" + stat + "</diffstat>\n")
+
+	files, err := notableFiles(span)
+	if err != nil {
+		return "", err
+	}
+	budget := maxReleaseDiffs
+	for _, file := range files {
+		diff, err := gitOutput("diff", "--no-color", span, "--", ":(top)"+file) // Paths are from the repository root
+		if err != nil || len(diff) > budget {
+			continue
+		}
+		budget -= len(diff)
+		sb.WriteString("\n<diff file=\"" + file + "\">\n" + diff + "</diff>\n")
+	}
+	return sb.String(), nil
+}
+
+// notableFiles returns the files changed in span, most changed lines first,
+// without tests, lock files and vendored code
+func notableFiles(span string) ([]string, error) {
+	out, err := gitOutput("diff", "--numstat", span)
+	if err != nil {
+		return nil, err
+	}
+	type change struct {
+		file  string
+		lines int
+	}
+	var changes []change
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		added, err1 := strconv.Atoi(fields[0])
+		removed, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil || releaseNoiseFile(fields[2]) {
+			continue // Binary files have "-" counts
+		}
+		changes = append(changes, change{fields[2], added + removed})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].lines > changes[j].lines })
+
+	files := make([]string, len(changes))
+	for i, c := range changes {
+		files[i] = c.file
+	}
+	return files, nil
+}
+
+func releaseNoiseFile(path string) bool {
+	if strings.Contains(path, "=>") || strings.HasSuffix(path, "_test.go") || strings.Contains(path, ".test.") || strings.Contains(path, ".spec.") {
+		return true // Renames and tests
+	}
+	for _, noise := range releaseNoise {
+		if path == noise || strings.HasSuffix(path, "/"+noise) || strings.HasPrefix(path, noise) || strings.Contains(path, "/"+noise) {
+			return true
+		}
+	}
+	return false
+}
+
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
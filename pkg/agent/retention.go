@@ -0,0 +1,21 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/history"
+)
+
+// startRetention prunes old sessions in the background, at most once a day,
+// unless retention.manual leaves that to john sessions prune
+func (a *Agent) startRetention() {
+	if a.ephemeral() || a.cfg == nil || a.cfg.Settings == nil || a.cfg.Settings.Retention.Manual {
+		return
+	}
+	root, err := history.DefaultRoot()
+	if err != nil || !history.PruneDue(root, time.Now()) {
+		return
+	}
+	policy := history.RetentionFromSettings(a.cfg.Settings.Retention)
+	go history.PruneSessions(root, policy, time.Now(), false)
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// retryStreak counts the consecutive failures of a tool with the same error
+type retryStreak struct {
+	signature string
+	count     int
+}
+
+// Failures in a row after which the reminder escalates
+const (
+	retryWarnAt = 2
+	retryStopAt = 3
+	retryQuitAt = 5
+)
+
+var digitRuns = regexp.MustCompile(`[0-9]+`)
+
+// retryAdvice is what to try instead of repeating a failed call, per tool
+var retryAdvice = map[string]string{
+	"Edit":         "Read the file again to get its current contents, copy old_string exactly as it appears there (indentation and line endings included), and include a few more surrounding lines so it matches once. For a large change, Write the whole file instead.",
+	"Write":        "Check that the directory exists and that you Read the file first if it already exists.",
+	"Read":         "Check the path: use Glob to find the file, and use an absolute path.",
+	"NotebookEdit": "Read the notebook again to get its current cells and their numbers.",
+	"RenameSymbol": "Read the file to confirm the line and the exact symbol name, or fall back to Edit.",
+	"Grep":         "Simplify the pattern, escape regex characters, or search a broader path.",
+	"Glob":         "Broaden the pattern or check the base path with Bash ls.",
+	"WebFetch":     "The page may be unavailable; try another source or WebSearch.",
+	"Docs":         "Check the package name and ecosystem, drop the version, or WebFetch the project's documentation site.",
+	"DepsAudit":    "Pass the directory holding go.mod, package.json or requirements.txt; dependencies that fail are reported with an error and the rest still count.",
+	"Bash":         "Read the error output, check the command's syntax, paths and working directory, and try a different command.",
+}
+
+// retryReminder tracks failures of tc's tool and returns a system reminder
+// to append to its result when the same error has come back more than once,
+// so the model changes approach instead of repeating the call until it runs
+// out of turns. A success clears the tool's streak.
+func (a *Agent) retryReminder(tc llm.ToolCall, result string, failed bool) string {
+	if !failed {
+		delete(a.failures, tc.Name)
+		return ""
+	}
+	if a.failures == nil {
+		a.failures = make(map[string]retryStreak)
+	}
+	// Line numbers and counts in the message don't make it a different error
+	signature := digitRuns.ReplaceAllString(firstLineOf(result, 300), "#")
+	streak := a.failures[tc.Name]
+	if streak.signature == signature {
+		streak.count++
+	} else {
+		streak = retryStreak{signature: signature, count: 1}
+	}
+	a.failures[tc.Name] = streak
+
+	advice := retryAdvice[tc.Name]
+	if advice == "" {
+		advice = "Re-read the tool's description, check every argument against the current state of the workspace, and change what you send."
+	}
+	var reminder string
+	switch {
+	case streak.count >= retryQuitAt:
+		reminder = fmt.Sprintf("%s has now failed %d times in a row with the same error. Stop calling it this way. Explain to the user what you were trying to do and what keeps failing, and ask how to proceed.", tc.Name, streak.count)
+	case streak.count >= retryStopAt:
+		reminder = fmt.Sprintf("%s has failed %d times in a row with the same error, so repeating or slightly tweaking the call will not work. Change strategy: %s", tc.Name, streak.count, advice)
+	case streak.count >= retryWarnAt:
+		reminder = fmt.Sprintf("%s failed again with the same error. Do not repeat the call unchanged. %s", tc.Name, advice)
+	default:
+		return ""
+	}
+	return "\n<system-reminder>\n" + reminder + "\n</system-reminder>"
+}
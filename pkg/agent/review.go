@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/review"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// reviewPrompt asks for findings as JSON so they can be written as SARIF or
+// checkstyle
+const reviewPrompt = "Review this diff for bugs, security problems (including committed secrets), " +
+	"error handling mistakes and clearly broken logic. Ignore style and anything you are unsure about.\n\n" +
+	"Lines in the diff are prefixed with their line number in the new version of the file. " +
+	"Reply with only a JSON array, one object per problem:\n" +
+	`[{"file": "path/from/repo/root", "line": 12, "severity": "error|warning|note", "rule": "short-kebab-case-category", "message": "what is wrong and how to fix it"}]` +
+	"\nUse error for bugs that will break something, warning for likely problems, and note for minor concerns. " +
+	"Reply with [] if there are none."
+
+// RunReview reviews the changes since base (or the uncommitted changes when
+// base is empty) with a single model call and returns the findings. It
+// prints nothing, so the findings can be written to stdout as SARIF or XML.
+func RunReview(cfg *config.Config, u *ui.UI, base, modelID string) ([]review.Finding, error) {
+	diff, err := reviewDiff(base)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return []review.Finding{}, nil
+	}
+	diff = review.NumberDiff(diff)
+	if len(diff) > maxGitHookDiff {
+		diff = diff[:maxGitHookDiff] + "\n...[diff truncated]..."
+	}
+
+	a := New(cfg, u)
+	if modelID == "" {
+		modelID = a.currentModel
+	}
+	model, err := a.resolveModel(modelID)
+	if err != nil {
+		return nil, err
+	}
+	client := a.createClientForModel(model.ID)
+	if _, isMock := client.(*llm.MockClient); isMock {
+		return nil, fmt.Errorf("no API key for %s", model.ID)
+	}
+
+	resp, err := client.Generate(context.Background(), []llm.Message{
+		{Role: llm.RoleSystem, Content: "You are a code reviewer running in CI. Your reply is parsed as JSON."},
+		{Role: llm.RoleUser, Content: reviewPrompt + "\n\n<diff>\n" + diff + "\n</diff>"},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return review.Parse(resp.Content)
+}
+
+// reviewDiff returns the working tree's changes since the merge base of base
+// and HEAD, which is what a pull request against base shows, or since HEAD
+// when base is empty
+func reviewDiff(base string) (string, error) {
+	from := "HEAD"
+	if base != "" {
+		out, err := exec.Command("git", "merge-base", base, "HEAD").Output()
+		if err != nil {
+			return "", fmt.Errorf("no merge base with %s", base)
+		}
+		from = strings.TrimSpace(string(out))
+	}
+	out, err := exec.Command("git", "diff", "--no-color", from).Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff %s failed: %w", from, err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/commands"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/mcp"
+)
+
+const safeModeNotice = "\n\n<safe-mode>\njohn was started in safe mode to recover from a broken configuration: user and project settings, custom commands, agents, project memory files and MCP servers are not loaded, and every tool that changes something asks the user first. If the user asks for help, look at the problems reported at startup and help fix the files named there.\n</safe-mode>"
+
+// safeMode reports whether john was started with --safe-mode
+func (a *Agent) safeMode() bool {
+	return a.cfg != nil && a.cfg.SafeMode
+}
+
+// startSafeMode replaces the usual startup of settings, commands and
+// servers: it says what is off and checks the files that normally load
+func (a *Agent) startSafeMode() {
+	if len(a.history) > 0 && !strings.Contains(a.history[0].Content, safeModeNotice) {
+		a.history[0].Content += safeModeNotice
+	}
+	a.ui.Print("Safe mode: started with default settings. User and project settings, custom commands, agents, project memory, exec tools and MCP servers are off, nothing is saved, and tools that change anything ask first.")
+
+	problems := configProblems()
+	if len(problems) == 0 {
+		a.ui.Print("No problems found in the settings, trust, MCP, command or stats files. If normal startup still fails, run john from another directory to tell a project problem from a user one.")
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("Problems found in files john loads at startup:\n")
+	for _, p := range problems {
+		sb.WriteString("  " + p + "\n")
+	}
+	sb.WriteString("Fix or move these files, then start john without --safe-mode.")
+	a.ui.Print(sb.String())
+}
+
+// configProblems checks every file normal startup reads and describes the
+// ones that fail, as "path:line:col: problem" where the position is known
+func configProblems() []string {
+	var problems []string
+	check := func(path string, err error) {
+		if err != nil {
+			problems = append(problems, path+": "+err.Error())
+		}
+	}
+	checkJSON := func(pathFn func() (string, error), v func() interface{}) {
+		path, err := pathFn()
+		if err != nil {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if err != nil {
+			check(path, err)
+			return
+		}
+		if err := json.Unmarshal(data, v()); err != nil {
+			problems = append(problems, jsonProblem(path, data, err))
+		}
+	}
+
+	settingsFiles := []func() (string, error){
+		config.UserSettingsPath,
+		config.ProjectSettingsPath,
+		func() (string, error) { return config.ManagedSettingsPath(), nil },
+	}
+	for _, pathFn := range settingsFiles {
+		var settings config.Settings
+		checkJSON(pathFn, func() interface{} { return &settings })
+		if path, err := pathFn(); err == nil && settings.Models.Default != "" {
+			if _, err := llm.ResolveModel(settings.Models.Default, settings.Models.Aliases); err != nil {
+				check(path, fmt.Errorf("models.default: %w", err))
+			}
+		}
+	}
+	checkJSON(config.TrustFilePath, func() interface{} { return &map[string]interface{}{} })
+	for _, scope := range []mcp.Scope{mcp.ScopeUser, mcp.ScopeProject} {
+		checkJSON(func() (string, error) { return mcp.GetConfigPath(scope) }, func() interface{} { return &mcp.MCPConfig{} })
+	}
+	if root, err := history.DefaultRoot(); err == nil {
+		checkJSON(func() (string, error) { return filepath.Join(root, "stats.json"), nil }, func() interface{} { return &history.Stats{} })
+		checkJSON(func() (string, error) { return filepath.Join(root, "session-key.json"), nil }, func() interface{} { return &map[string]interface{}{} })
+	}
+	for _, dirFn := range []func() (string, error){config.UserDir, config.ProjectDir} {
+		if dir, err := dirFn(); err == nil {
+			if _, err := commands.LoadCustomCommands(filepath.Join(dir, "commands"), "check"); err != nil {
+				check(filepath.Join(dir, "commands"), err)
+			}
+		}
+	}
+	return problems
+}
+
+// jsonProblem describes a decoding error in a JSON file with the line and
+// column it happened at
+func jsonProblem(path string, data []byte, err error) string {
+	offset := int64(-1)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return path + ": " + err.Error()
+	}
+	// The offset is just past the byte the decoder stopped at
+	if offset > 0 {
+		offset--
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("%s:%d:%d: %v", path, line, col, err)
+}
@@ -0,0 +1,320 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// selfTestStep is one scripted tool call and the check applied to its result.
+type selfTestStep struct {
+	tool  string
+	args  func(results []string) map[string]interface{}
+	check func(result string) error
+}
+
+// selfTestPrompter answers AskUserQuestion without blocking on stdin.
+type selfTestPrompter struct {
+	ui *ui.UI
+}
+
+func (p *selfTestPrompter) Print(msg string) {
+	p.ui.Print(msg)
+}
+
+func (p *selfTestPrompter) Prompt(string) string {
+	return "selftest answer"
+}
+
+// RunSelfTest drives the agent loop end-to-end with a scripted client that
+// calls every built-in tool inside a temporary directory, then verifies the
+// tool results, the files on disk and the session log. No provider is called.
+func RunSelfTest(u *ui.UI) error {
+	tmpDir, err := os.MkdirTemp("", "john-selftest")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpDir, _ = filepath.EvalSymlinks(tmpDir)
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		return fmt.Errorf("failed to enter temp dir: %w", err)
+	}
+	defer os.Chdir(origDir)
+
+	// Local server standing in for Brave Search and arbitrary web pages
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"web":{"results":[{"title":"Selftest Result","url":"https://example.com","description":"ok"}]}}`)
+			return
+		}
+		if r.URL.Path == "/npm/selftest-dep" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"dist-tags":{"latest":"2.0.0"},"versions":{"1.0.0":{},"2.0.0":{}}}`)
+			return
+		}
+		if r.URL.Path == "/osv/querybatch" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"results":[{"vulns":[{"id":"SELFTEST-1"}]}]}`)
+			return
+		}
+		if r.URL.Path == "/npm/selftest-docs" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"dist-tags":{"latest":"1.0.0"},"readme":"# Selftest Docs"}`)
+			return
+		}
+		fmt.Fprint(w, "<html><body><h1>Selftest Page</h1></body></html>")
+	}))
+	defer server.Close()
+
+	ag := New(&config.Config{APIKey: "dummy"}, u)
+	ag.tools.Register(tools.NewAskUserQuestionTool(&selfTestPrompter{ui: u}))
+	ag.tools.Register(tools.NewWebSearchToolWithEndpoint("selftest", server.URL+"/search"))
+	ag.tools.Register(tools.NewDocsToolWithEndpoints(server.URL+"/go", server.URL+"/npm", server.URL+"/pypi"))
+	ag.tools.Register(tools.NewDepsAuditToolWithEndpoints(server.URL+"/go", server.URL+"/npm", server.URL+"/pypi", server.URL+"/osv"))
+	defer ag.closePython()
+
+	sm, err := history.NewSessionManagerInRoot(filepath.Join(tmpDir, ".johncode"), tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to create session manager: %w", err)
+	}
+	ag.session = sm
+
+	textFile := filepath.Join(tmpDir, "hello.txt")
+	nbFile := filepath.Join(tmpDir, "notes.ipynb")
+	if err := os.MkdirAll(filepath.Join(tmpDir, "deps"), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "deps", "package.json"), []byte(`{"dependencies":{"selftest-dep":"1.0.0"}}`), 0644); err != nil {
+		return err
+	}
+	steps, skipped := selfTestSteps(textFile, nbFile, server.URL)
+
+	// Build the script: one assistant message per tool call, then a final answer
+	var script []llm.ScriptStep
+	for i, step := range steps {
+		i, step := i, step
+		script = append(script, func(messages []llm.Message) *llm.Message {
+			return &llm.Message{
+				Content: fmt.Sprintf("Step %d: calling %s.", i+1, step.tool),
+				ToolCalls: []llm.ToolCall{{
+					ID:   fmt.Sprintf("selftest_%d", i+1),
+					Name: step.tool,
+					Args: step.args(toolResults(messages)),
+				}},
+			}
+		})
+	}
+	script = append(script, func([]llm.Message) *llm.Message {
+		return &llm.Message{Content: "Self-test scenario complete."}
+	})
+	ag.client = llm.NewScriptedClientFromSteps(script...)
+
+	userMsg := llm.Message{Role: llm.RoleUser, Content: "Run the self-test scenario."}
+	ag.history = append(ag.history, userMsg)
+	if err := sm.Append(llm.RoleUser, userMsg); err != nil {
+		return fmt.Errorf("failed to log user message: %w", err)
+	}
+
+	if err := ag.processTurn(); err != nil {
+		return fmt.Errorf("agent loop failed: %w", err)
+	}
+
+	// Verify each tool result
+	results := toolResults(ag.history)
+	var failures []string
+	report := func(status, name, detail string) {
+		line := fmt.Sprintf("  %-4s %s", status, name)
+		if detail != "" {
+			line += " - " + detail
+		}
+		u.Print(line)
+	}
+
+	u.Print("\nSelf-test results:")
+	for i, step := range steps {
+		if i >= len(results) {
+			report("FAIL", step.tool, "tool was never executed")
+			failures = append(failures, step.tool)
+			continue
+		}
+		if err := step.check(results[i]); err != nil {
+			report("FAIL", step.tool, err.Error())
+			failures = append(failures, step.tool)
+			continue
+		}
+		report("PASS", step.tool, "")
+	}
+	for _, name := range skipped {
+		report("SKIP", name, "prerequisite not installed")
+	}
+
+	// Verify the agent loop and session log plumbing
+	if content, err := os.ReadFile(textFile); err != nil || string(content) != "Goodbye, self-test!\n" {
+		report("FAIL", "filesystem", fmt.Sprintf("unexpected contents of %s", textFile))
+		failures = append(failures, "filesystem")
+	} else {
+		report("PASS", "filesystem", "")
+	}
+
+	last := ag.history[len(ag.history)-1]
+	if last.Role != llm.RoleAssistant || last.Content != "Self-test scenario complete." {
+		report("FAIL", "agent loop", "final assistant message missing")
+		failures = append(failures, "agent loop")
+	} else {
+		report("PASS", "agent loop", fmt.Sprintf("%d model requests", len(script)))
+	}
+
+	events, err := countMessageEvents(sm.FilePath)
+	if want := len(ag.history) - 1; err != nil || events != want {
+		report("FAIL", "session log", fmt.Sprintf("expected %d events, found %d (%v)", want, events, err))
+		failures = append(failures, "session log")
+	} else {
+		report("PASS", "session log", fmt.Sprintf("%d events", events))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("self-test failed: %s", strings.Join(failures, ", "))
+	}
+	u.Print("\nAll checks passed.")
+	return nil
+}
+
+// selfTestSteps returns the scripted tool calls, plus the names of tools that
+// were left out because their external prerequisites are missing.
+func selfTestSteps(textFile, nbFile, serverURL string) ([]selfTestStep, []string) {
+	static := func(args map[string]interface{}) func([]string) map[string]interface{} {
+		return func([]string) map[string]interface{} { return args }
+	}
+	contains := func(want string) func(string) error {
+		return func(result string) error {
+			if !strings.Contains(result, want) {
+				return fmt.Errorf("expected %q in result, got %q", want, truncate(result, 200))
+			}
+			return nil
+		}
+	}
+
+	steps := []selfTestStep{
+		{"TodoWrite", static(map[string]interface{}{"todos": []interface{}{
+			map[string]interface{}{"id": "1", "content": "Run self-test", "activeForm": "Running self-test", "status": "in_progress", "priority": "high"},
+		}}), contains("Run self-test")},
+		{"Write", static(map[string]interface{}{"file_path": textFile, "content": "Hello, world!\n"}), contains("Successfully wrote")},
+		{"Read", static(map[string]interface{}{"file_path": textFile}), contains("Hello, world!")},
+		{"Edit", static(map[string]interface{}{"file_path": textFile, "old_string": "world", "new_string": "self-test"}), contains("Successfully edited")},
+		{"RenameSymbol", static(map[string]interface{}{"file_path": textFile, "line": float64(1), "symbol": "Hello", "new_name": "Goodbye"}), contains("Preview of 1 occurrence")},
+		{"RenameSymbol", static(map[string]interface{}{"file_path": textFile, "line": float64(1), "symbol": "Hello", "new_name": "Goodbye", "apply": true}), contains("Renamed Hello to Goodbye")},
+		{"Glob", static(map[string]interface{}{"pattern": filepath.Join(filepath.Dir(textFile), "*.txt")}), contains("hello.txt")},
+		{"Calc", static(map[string]interface{}{"expression": "1.5 GiB / 64 MiB"}), contains("24")},
+	}
+
+	var skipped []string
+	if _, err := exec.LookPath("rg"); err == nil {
+		steps = append(steps, selfTestStep{"Grep", static(map[string]interface{}{"pattern": "self-test", "path": filepath.Dir(textFile)}), contains("hello.txt")})
+	} else {
+		skipped = append(skipped, "Grep")
+	}
+
+	steps = append(steps,
+		selfTestStep{"Bash", static(map[string]interface{}{"command": "echo selftest-bash"}), contains("selftest-bash")},
+		selfTestStep{"Bash", static(map[string]interface{}{"command": "echo selftest-bg; sleep 30", "run_in_background": true}), contains("Started background process")},
+		selfTestStep{"BashOutput", func(results []string) map[string]interface{} {
+			return map[string]interface{}{"shell_id": backgroundShellID(results)}
+		}, contains("Shell ID:")},
+		selfTestStep{"KillShell", func(results []string) map[string]interface{} {
+			return map[string]interface{}{"shell_id": backgroundShellID(results)}
+		}, contains("Successfully killed")},
+		selfTestStep{"Write", static(map[string]interface{}{"file_path": nbFile, "content": `{"cells": [], "metadata": {}, "nbformat": 4, "nbformat_minor": 5}`}), contains("Successfully wrote")},
+		selfTestStep{"NotebookEdit", static(map[string]interface{}{"notebook_path": nbFile, "cell_number": float64(0), "new_source": "print('hi')", "edit_mode": "insert"}), contains("Notebook updated")},
+		selfTestStep{"WebSearch", static(map[string]interface{}{"query": "selftest"}), contains("Selftest Result")},
+		selfTestStep{"WebFetch", static(map[string]interface{}{"url": serverURL + "/page"}), contains("Selftest Page")},
+		selfTestStep{"Docs", static(map[string]interface{}{"package": "selftest-docs", "ecosystem": "npm"}), contains("Selftest Docs")},
+		selfTestStep{"DepsAudit", static(map[string]interface{}{"path": "deps"}), contains("SELFTEST-1")},
+		selfTestStep{"AskUserQuestion", static(map[string]interface{}{"question": "Continue?"}), contains("selftest answer")},
+		selfTestStep{"Task", static(map[string]interface{}{"task": "Say hello"}), func(result string) error {
+			if result == "" || strings.HasPrefix(result, "Error") {
+				return fmt.Errorf("sub-agent returned %q", truncate(result, 200))
+			}
+			return nil
+		}},
+	)
+
+	if _, err := exec.LookPath("python3"); err == nil {
+		steps = append(steps, selfTestStep{"Python", static(map[string]interface{}{"code": "6 * 7"}), contains("42")})
+	} else {
+		skipped = append(skipped, "Python")
+	}
+
+	return steps, skipped
+}
+
+// toolResults returns the content of every tool result in the conversation.
+func toolResults(messages []llm.Message) []string {
+	var results []string
+	for _, msg := range messages {
+		if msg.Role == llm.RoleTool && msg.ToolResult != nil {
+			results = append(results, msg.ToolResult.Content)
+		}
+	}
+	return results
+}
+
+var backgroundIDPattern = regexp.MustCompile(`background process with ID (\S+?)\.`)
+
+// backgroundShellID finds the shell ID reported by the most recent background Bash call.
+func backgroundShellID(results []string) string {
+	for i := len(results) - 1; i >= 0; i-- {
+		if m := backgroundIDPattern.FindStringSubmatch(results[i]); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// countMessageEvents counts the conversation events in a session log,
+// skipping side events such as todo snapshots and errors.
+func countMessageEvents(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &event) == nil && (event.Type == history.EventTypeUser || event.Type == history.EventTypeAssistant) {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
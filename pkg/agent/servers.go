@@ -0,0 +1,200 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// addressInUse matches the errors servers print when their port is taken
+var addressInUse = regexp.MustCompile(`(?i)address already in use|EADDRINUSE|port \d+ is (?:already )?in use|port is already (?:in use|allocated)`)
+
+// portNumber finds the port numbers mentioned in such an error
+var portNumber = regexp.MustCompile(`(?i)(?::|port\s+)(\d{2,5})\b`)
+
+// serversDir is where background shells are recorded so a later john can
+// find the ones a crashed session left running
+func (a *Agent) serversDir() string {
+	if a.statsRoot == "" {
+		return ""
+	}
+	return filepath.Join(a.statsRoot, "servers")
+}
+
+// setupServers records background shells for this session and reports dev
+// servers that an earlier session left running, which would otherwise make
+// the next start fail with "address already in use"
+func (a *Agent) setupServers() {
+	dir := a.serversDir()
+	if dir == "" {
+		return
+	}
+	if err := tools.GlobalShellManager.SetStateDir(dir); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: cannot record background shells: %v", err))
+		return
+	}
+	stale := tools.StaleServers(dir)
+	if len(stale) == 0 {
+		return
+	}
+	var lines []string
+	for _, s := range stale {
+		lines = append(lines, "  "+describeServer(s))
+	}
+	a.ui.Print(fmt.Sprintf("%s left running by an earlier session:\n%s\nStop them with /servers kill stale.",
+		plural(len(stale), "background shell"), strings.Join(lines, "\n")))
+}
+
+// handleServers handles /servers: list the background shells of this
+// session and stale ones of earlier sessions, or stop them with
+// "kill <id>", "kill stale" or "kill all"
+func (a *Agent) handleServers(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return a.listServers()
+	}
+	if fields[0] != "kill" || len(fields) != 2 {
+		return fmt.Errorf("usage: /servers [kill <id> | kill stale | kill all]")
+	}
+
+	target := fields[1]
+	var stale []tools.ServerInfo
+	if dir := a.serversDir(); dir != "" {
+		stale = tools.StaleServers(dir)
+	}
+	killed := 0
+	if target == "all" {
+		for _, s := range tools.GlobalShellManager.Servers() {
+			if s.Running {
+				killed++
+			}
+		}
+		tools.GlobalShellManager.KillAll()
+	}
+	if target == "all" || target == "stale" {
+		for _, s := range stale {
+			if err := tools.KillServer(s); err != nil {
+				return fmt.Errorf("stopping %s: %w", s.ID, err)
+			}
+			killed++
+		}
+		a.ui.Print(fmt.Sprintf("Stopped %s", plural(killed, "background shell")))
+		return nil
+	}
+
+	for _, s := range stale {
+		if s.ID == target {
+			if err := tools.KillServer(s); err != nil {
+				return err
+			}
+			a.ui.Print(fmt.Sprintf("Stopped %s (pid %d)", s.ID, s.PID))
+			return nil
+		}
+	}
+	if err := tools.GlobalShellManager.Kill(target); err != nil {
+		return err
+	}
+	a.ui.Print(fmt.Sprintf("Stopped background shell %s", target))
+	return nil
+}
+
+func (a *Agent) listServers() error {
+	current := tools.GlobalShellManager.Servers()
+	var stale []tools.ServerInfo
+	if dir := a.serversDir(); dir != "" {
+		stale = tools.StaleServers(dir)
+	}
+	if len(current) == 0 && len(stale) == 0 {
+		a.ui.Print("No background shells. Commands run with run_in_background appear here with the ports they listen on.")
+		return nil
+	}
+
+	var sb strings.Builder
+	if len(current) > 0 {
+		sb.WriteString("Background shells:\n")
+		for _, s := range current {
+			sb.WriteString("  " + describeServer(s) + "\n")
+		}
+	}
+	if len(stale) > 0 {
+		sb.WriteString("Left running by an earlier session:\n")
+		for _, s := range stale {
+			sb.WriteString("  " + describeServer(s) + "\n")
+		}
+	}
+	sb.WriteString("Stop one with /servers kill <id>, or use /servers kill stale or /servers kill all.")
+	a.ui.Print(sb.String())
+	return nil
+}
+
+// describeServer formats a shell for /servers: ID, state, ports, command
+func describeServer(s tools.ServerInfo) string {
+	state := "exited"
+	if s.Running {
+		state = "up " + formatDuration(time.Since(s.Started).Round(time.Second))
+	}
+	if s.Stale {
+		state = fmt.Sprintf("pid %d, %s", s.PID, state)
+	}
+	ports := ""
+	if s.Running {
+		ports = "no ports"
+	}
+	if len(s.Ports) > 0 {
+		var list []string
+		for _, p := range s.Ports {
+			list = append(list, ":"+strconv.Itoa(p))
+		}
+		ports = strings.Join(list, " ")
+	}
+	return fmt.Sprintf("%-4s %-22s %-12s %s", s.ID, state, ports, firstLineOf(s.Command, 60))
+}
+
+// portConflictHint explains a failed server start when the port it wanted
+// is held by one of the background shells, so the model reuses or stops
+// that server instead of retrying on the same port
+func portConflictHint(result string, servers []tools.ServerInfo) string {
+	if !addressInUse.MatchString(result) {
+		return ""
+	}
+	wanted := make(map[int]bool)
+	for _, m := range portNumber.FindAllStringSubmatch(result, -1) {
+		if port, err := strconv.Atoi(m[1]); err == nil {
+			wanted[port] = true
+		}
+	}
+	var hints []string
+	for _, s := range servers {
+		for _, port := range s.Ports {
+			if !wanted[port] {
+				continue
+			}
+			if s.Stale {
+				hints = append(hints, fmt.Sprintf("Port %d is held by `%s` (pid %d), left running by an earlier session. Ask the user to stop it with /servers kill %s, or use another port.",
+					port, firstLineOf(s.Command, 80), s.PID, s.ID))
+			} else {
+				hints = append(hints, fmt.Sprintf("Port %d is held by background shell %s (`%s`). Use that server, or stop it with KillShell first.",
+					port, s.ID, firstLineOf(s.Command, 80)))
+			}
+		}
+	}
+	return strings.Join(hints, "\n")
+}
+
+// serverConflictHint checks a Bash result for a port conflict with a known
+// background shell
+func (a *Agent) serverConflictHint(result string) string {
+	if !addressInUse.MatchString(result) {
+		return ""
+	}
+	servers := tools.GlobalShellManager.Servers()
+	if dir := a.serversDir(); dir != "" {
+		servers = append(servers, tools.StaleServers(dir)...)
+	}
+	return portConflictHint(result, servers)
+}
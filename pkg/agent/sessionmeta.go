@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/history"
+)
+
+// updateSessionMeta changes the name, tags or favorite mark of the current
+// session in the session index
+func (a *Agent) updateSessionMeta(update func(meta *history.SessionMeta)) (history.SessionMeta, error) {
+	if a.session == nil {
+		return history.SessionMeta{}, fmt.Errorf("this session is not saved, so it cannot be named or tagged")
+	}
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return history.SessionMeta{}, err
+	}
+	return history.UpdateSessionMeta(root, a.session.CWD, a.session.SessionID, update)
+}
+
+// handleRename runs /rename: a name to set, "-" to remove it, or nothing to
+// show it
+func (a *Agent) handleRename(args string) error {
+	name := strings.TrimSpace(args)
+	meta, err := a.updateSessionMeta(func(meta *history.SessionMeta) {
+		switch name {
+		case "":
+		case "-":
+			meta.Name = ""
+		default:
+			meta.Name = name
+		}
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case name == "-":
+		a.ui.Print("Session name removed")
+	case name != "":
+		a.ui.Print(fmt.Sprintf("Session renamed to %q. Resume it with: john --resume %q", meta.Name, meta.Name))
+	case meta.Name == "":
+		a.ui.Print("This session has no name. Usage: /rename <name> (/rename - removes it)")
+	default:
+		a.ui.Print(fmt.Sprintf("This session is named %q", meta.Name))
+	}
+	return nil
+}
+
+// handleTag runs /tag: each word adds a tag, -word removes one, and
+// favorite/-favorite set the favorite mark. Without words it lists them.
+func (a *Agent) handleTag(args string) error {
+	meta, err := a.updateSessionMeta(func(meta *history.SessionMeta) {
+		for _, word := range strings.Fields(args) {
+			tag, remove := strings.CutPrefix(word, "-")
+			tag = strings.TrimPrefix(tag, "#")
+			switch {
+			case strings.EqualFold(tag, "favorite"):
+				meta.Favorite = !remove
+			case remove:
+				kept := meta.Tags[:0]
+				for _, t := range meta.Tags {
+					if !strings.EqualFold(t, tag) {
+						kept = append(kept, t)
+					}
+				}
+				meta.Tags = kept
+			default:
+				meta.Tags = append(meta.Tags, tag)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(meta.Tags) == 0 && !meta.Favorite {
+		a.ui.Print("This session has no tags. Usage: /tag <tag>... (-<tag> removes one, favorite stars the session)")
+		return nil
+	}
+	var parts []string
+	if meta.Favorite {
+		parts = append(parts, "★ favorite")
+	}
+	for _, t := range meta.Tags {
+		parts = append(parts, "#"+t)
+	}
+	a.ui.Print("Session tags: " + strings.Join(parts, " "))
+	return nil
+}
@@ -0,0 +1,324 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/slack"
+	"github.com/jbdamask/john-code/pkg/tools"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// slackUpdateInterval is how often a streaming reply is edited in Slack,
+// which rate limits chat.update to about one call per second
+var slackUpdateInterval = 1500 * time.Millisecond
+
+const slackNotice = "\n\n<slack>\nYou are answering messages in a Slack thread for a team, not a terminal user. Keep replies short and use Slack formatting (*bold*, `code`, ``` blocks). You cannot ask follow-up questions interactively; if something is unclear, say what you assumed.\n</slack>"
+
+// slackBridge answers Slack messages, one agent per thread
+type slackBridge struct {
+	cfg      *config.Config
+	ui       *ui.UI
+	settings config.SlackSettings
+	client   *slack.Client
+	botUser  string // The bot's user ID, as it appears in mentions
+	modelID  string
+	readOnly bool // Untrusted workspace
+
+	mu      sync.Mutex
+	threads map[string]*slackThread // By channel and thread timestamp
+}
+
+// slackThread is one Slack thread's conversation
+type slackThread struct {
+	mu    sync.Mutex // Held while a prompt runs, so replies stay in order
+	agent *Agent
+}
+
+// RunSlack answers Slack messages until ctx is done. Mentions of the bot and
+// direct messages start a thread with its own session; later messages in
+// the thread continue it without a mention. Only the listed slack.channels
+// and slack.users are answered, only the tools in slack.tools are
+// available, file tools stay in the working directory, and ask rules in
+// permissions deny, as no one is at the terminal to answer.
+func RunSlack(ctx context.Context, cfg *config.Config, u *ui.UI) error {
+	settings := config.SlackSettings{}
+	if cfg.Settings != nil {
+		settings = cfg.Settings.Slack
+	}
+	settings = settings.WithDefaults()
+	if settings.AppToken == "" || settings.BotToken == "" {
+		return fmt.Errorf("set slack.appToken and slack.botToken in settings, or SLACK_APP_TOKEN and SLACK_BOT_TOKEN")
+	}
+	if !settings.Restricted() {
+		// Anyone in the workspace could otherwise read files through it
+		return fmt.Errorf("set slack.channels or slack.users in settings to choose who john answers in Slack")
+	}
+
+	b := &slackBridge{
+		cfg:      cfg,
+		ui:       u,
+		settings: settings,
+		client:   slack.NewClient(settings.AppToken, settings.BotToken),
+		threads:  make(map[string]*slackThread),
+	}
+
+	// Check the model once rather than failing in every thread
+	probe := New(cfg, u)
+	b.modelID = probe.currentModel
+	if settings.Model != "" {
+		model, err := probe.resolveModel(settings.Model)
+		if err != nil {
+			return fmt.Errorf("slack.model: %w", err)
+		}
+		b.modelID = model.ID
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if trusted, _ := config.WorkspaceTrust(cwd); !trusted {
+			b.readOnly = true
+			u.Print("This workspace is not trusted: only read-only tools will run. Run john here and use /trust to change that.")
+		}
+	}
+
+	botUser, err := b.client.AuthTest(ctx)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	b.botUser = botUser
+
+	u.Print(fmt.Sprintf("Answering Slack messages to <@%s> with %s and tools %s. Press Ctrl+C to stop.",
+		botUser, b.modelID, strings.Join(settings.Tools, ", ")))
+	err = b.client.Listen(ctx, b.handle)
+
+	tools.GlobalShellManager.KillAll()
+	b.closeThreads()
+	return err
+}
+
+// handle decides whether an event is a prompt for john and starts answering
+// it in the background
+func (b *slackBridge) handle(e slack.Event) {
+	if e.BotID != "" || e.Subtype != "" || e.User == "" || e.User == b.botUser {
+		return // Edits, joins, and bot messages including our own replies
+	}
+	if !b.settings.Allows(e.Channel, e.User) {
+		return
+	}
+
+	thread := e.ThreadTS
+	if thread == "" {
+		thread = e.TS
+	}
+	key := e.Channel + "/" + thread
+	mention := "<@" + b.botUser + ">"
+	switch {
+	case e.Type == "app_mention":
+	case e.Type == "message" && e.ChannelType == "im":
+	case e.Type == "message" && e.ThreadTS != "" && !strings.Contains(e.Text, mention) && b.hasThread(key):
+		// A follow-up in a thread john is already in; mentions arrive as
+		// app_mention as well, so they are answered there
+	default:
+		return
+	}
+
+	prompt := strings.TrimSpace(strings.ReplaceAll(e.Text, mention, ""))
+	if prompt == "" {
+		return
+	}
+	go b.answer(key, e.Channel, thread, prompt)
+}
+
+func (b *slackBridge) hasThread(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.threads[key] != nil
+}
+
+// thread returns the conversation for a thread, starting one if needed
+func (b *slackBridge) thread(key string) *slackThread {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t, ok := b.threads[key]; ok {
+		return t
+	}
+	t := &slackThread{agent: b.newAgent()}
+	b.threads[key] = t
+	return t
+}
+
+// closeThreads stops the Python interpreters the threads' agents started
+func (b *slackBridge) closeThreads() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, t := range b.threads {
+		t.agent.closePython()
+	}
+}
+
+// newAgent returns an agent limited to the configured tools that cannot
+// prompt at the terminal
+func (b *slackBridge) newAgent() *Agent {
+	a := New(b.cfg, b.ui)
+	a.headless = true
+	a.currentModel = b.modelID
+	a.client = a.createClientForModel(b.modelID)
+
+	a.restrictTools(b.settings.Tools)
+	if cwd, err := os.Getwd(); err == nil {
+		a.confineTo = cwd
+	}
+	a.history[0].Content += slackNotice
+	a.setupTooling()
+	if b.readOnly {
+		a.setReadOnly(true)
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		if sm, err := history.NewSessionManager(cwd); err == nil {
+			a.session = sm
+		}
+	}
+	return a
+}
+
+// answer runs a prompt in its thread's session and streams the reply into
+// the thread
+func (b *slackBridge) answer(key, channel, thread, prompt string) {
+	t := b.thread(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b.ui.Print(fmt.Sprintf("[%s] %s", key, firstLineOf(prompt, 100)))
+	reply, err := newSlackReply(b.client, channel, thread)
+	if err != nil {
+		b.ui.Print(fmt.Sprintf("Warning: Slack reply failed: %v", err))
+		return
+	}
+	t.agent.textSink = reply.write
+	err = t.agent.RunPrompt(prompt)
+	t.agent.textSink = nil
+	reply.finish(err)
+	if err != nil {
+		b.ui.Print(fmt.Sprintf("[%s] Error: %v", key, err))
+	}
+}
+
+// slackReply is a reply being streamed into a thread. Text is buffered and
+// the message edited every slackUpdateInterval; text too long for one
+// message continues in a new one.
+type slackReply struct {
+	client  *slack.Client
+	channel string
+	thread  string
+
+	mu    sync.Mutex
+	ts    string // Message being written
+	text  string // Unformatted text of that message
+	dirty bool
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newSlackReply(client *slack.Client, channel, thread string) (*slackReply, error) {
+	ts, err := client.PostMessage(context.Background(), channel, thread, "_Thinking…_")
+	if err != nil {
+		return nil, err
+	}
+	r := &slackReply{client: client, channel: channel, thread: thread, ts: ts, done: make(chan struct{})}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(slackUpdateInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				r.flush("\n_…_")
+			}
+		}
+	}()
+	return r, nil
+}
+
+func (r *slackReply) write(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.text += s
+	r.dirty = true
+}
+
+// finish writes the complete reply, noting err if the turn failed
+func (r *slackReply) finish(err error) {
+	close(r.done)
+	r.wg.Wait()
+
+	r.mu.Lock()
+	if err != nil {
+		r.text = strings.TrimRight(r.text, "\n") + fmt.Sprintf("\n\n:warning: %v", err)
+	} else if strings.TrimSpace(r.text) == "" {
+		r.text = "_Done, with nothing to report._"
+	}
+	r.dirty = true
+	r.mu.Unlock()
+	r.flush("")
+}
+
+// flush edits the message to show the text so far, followed by suffix
+func (r *slackReply) flush(suffix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return
+	}
+	r.dirty = false
+	ctx := context.Background()
+
+	for len(r.text) > slack.MaxMessageLength {
+		cut := strings.LastIndex(r.text[:slack.MaxMessageLength], "\n")
+		if cut <= 0 {
+			cut = slack.MaxMessageLength
+		}
+		if err := r.client.UpdateMessage(ctx, r.channel, r.ts, slackMarkdown(r.text[:cut])); err != nil {
+			return
+		}
+		ts, err := r.client.PostMessage(ctx, r.channel, r.thread, "_…_")
+		if err != nil {
+			return
+		}
+		r.ts = ts
+		r.text = strings.TrimLeft(r.text[cut:], "\n")
+	}
+	text := strings.TrimRight(slackMarkdown(r.text), "\n ")
+	if text == "" {
+		text = "_Thinking…_"
+	}
+	if err := r.client.UpdateMessage(ctx, r.channel, r.ts, text+suffix); err != nil {
+		r.dirty = true // Try again on the next tick
+	}
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	markdownBold    = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	markdownLink    = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^)\s]+)\)`)
+	codeFenceLang   = regexp.MustCompile("(?m)^```[A-Za-z0-9_+-]+$")
+)
+
+// slackMarkdown converts the Markdown models write to Slack's mrkdwn. The
+// result is never longer than the input, so long replies can be split
+// before converting.
+func slackMarkdown(s string) string {
+	s = codeFenceLang.ReplaceAllString(s, "```")
+	s = markdownHeading.ReplaceAllString(s, "*$1*")
+	s = markdownBold.ReplaceAllString(s, "*$1*")
+	return markdownLink.ReplaceAllString(s, "<$2|$1>")
+}
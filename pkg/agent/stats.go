@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// Tools failing at least this often, over enough calls to mean something,
+// are flagged by /stats tools
+const (
+	flakyFailureRate = 0.2
+	flakyMinCalls    = 5
+)
+
+// recordToolStats adds a finished tool call to the stats store. Only
+// interactive sessions record, so self-tests and sub-agents leave the
+// user's numbers alone.
+func (a *Agent) recordToolStats(tc llm.ToolCall, duration time.Duration, result string, failed bool) {
+	if a.statsRoot == "" {
+		return
+	}
+	errMsg := ""
+	if failed {
+		errMsg = firstLineOf(strings.TrimPrefix(result, "Error: "), 200)
+	}
+	if err := history.RecordToolCall(a.statsRoot, tc.Name, duration, failed, errMsg); err != nil {
+		a.recordToolError("stats", err.Error())
+	}
+}
+
+// showStats handles /stats: "tools" (the default) lists call counts,
+// failure rates and durations per tool across all sessions, "models" the
+// request latency per model, and "reset" after either clears them
+func (a *Agent) showStats(args string) error {
+	root := a.statsRoot
+	if root == "" {
+		var err error
+		if root, err = history.DefaultRoot(); err != nil {
+			return err
+		}
+	}
+
+	switch strings.Join(strings.Fields(args), " ") {
+	case "", "tools":
+	case "tools reset":
+		if err := history.ResetToolStats(root); err != nil {
+			return fmt.Errorf("failed to reset tool stats: %w", err)
+		}
+		a.ui.Print("Tool stats cleared.")
+		return nil
+	case "models":
+		return a.showModelStats(root)
+	case "models reset":
+		if err := history.ResetModelStats(root); err != nil {
+			return fmt.Errorf("failed to reset model stats: %w", err)
+		}
+		a.ui.Print("Model stats cleared.")
+		return nil
+	default:
+		return fmt.Errorf("usage: /stats tools|models [reset]")
+	}
+
+	stats, err := history.LoadStats(root)
+	if err != nil {
+		return err
+	}
+	if len(stats.Tools) == 0 {
+		a.ui.Print("No tool calls recorded yet.")
+		return nil
+	}
+
+	// Calls and failures of this session, from the timeline
+	type count struct{ calls, failed int }
+	session := make(map[string]*count)
+	for _, e := range a.timeline {
+		if e.kind != timelineTool {
+			continue
+		}
+		c := session[e.label]
+		if c == nil {
+			c = &count{}
+			session[e.label] = c
+		}
+		c.calls++
+		if e.failed {
+			c.failed++
+		}
+	}
+
+	names := make([]string, 0, len(stats.Tools))
+	for name := range stats.Tools {
+		names = append(names, name)
+	}
+	// Most used first
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := stats.Tools[names[i]].Calls, stats.Tools[names[j]].Calls
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+
+	width := len("Tool")
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	var sb strings.Builder
+	var flaky []string
+	now := time.Now()
+	sb.WriteString(fmt.Sprintf("%-*s  %6s  %6s  %6s  %8s  %8s  %5s  %s\n", width, "Tool", "Calls", "Failed", "Rate", "Avg", "Max", "Today", "Session"))
+	for _, name := range names {
+		s := stats.Tools[name]
+		mark := ""
+		if s.Calls >= flakyMinCalls && s.FailureRate() >= flakyFailureRate {
+			mark = "  !"
+			flaky = append(flaky, name)
+		}
+		this := "-"
+		if c := session[name]; c != nil {
+			this = fmt.Sprintf("%d/%d", c.failed, c.calls)
+		}
+		line := fmt.Sprintf("%-*s  %6d  %6d  %5.0f%%  %8s  %8s  %5d  %-7s%s", width, name, s.Calls, s.Failures,
+			s.FailureRate()*100, formatDuration(s.Average()), formatDuration(s.Max), s.On(now), this, mark)
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	if len(flaky) > 0 {
+		sb.WriteString(fmt.Sprintf("\n! fails %.0f%% of the time or more. Last errors:\n", flakyFailureRate*100))
+		for _, name := range flaky {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", name, firstLineOf(stats.Tools[name].LastError, 100)))
+		}
+	}
+	sb.WriteString("\nToday counts calls since midnight in your time zone; Session is failed/calls since john started. /stats tools reset clears the totals.")
+
+	a.ui.Print("Tool stats across all sessions:\n\n" + sb.String())
+	return nil
+}
+
+// showModelStats lists the requests, failures, time to first token and
+// request durations per model across all sessions
+func (a *Agent) showModelStats(root string) error {
+	stats, err := history.LoadStats(root)
+	if err != nil {
+		return err
+	}
+	if len(stats.Models) == 0 {
+		a.ui.Print("No model requests recorded yet.")
+		return nil
+	}
+
+	names := make([]string, 0, len(stats.Models))
+	width := len("Model")
+	for name := range stats.Models {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	// Most used first
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := stats.Models[names[i]].Calls, stats.Models[names[j]].Calls
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-*s  %6s  %6s  %11s  %8s  %8s\n", width, "Model", "Calls", "Failed", "First token", "Avg", "Max"))
+	for _, name := range names {
+		s := stats.Models[name]
+		ttft := "-"
+		if s.Streamed > 0 {
+			ttft = formatDuration(s.AverageTTFT())
+		}
+		sb.WriteString(fmt.Sprintf("%-*s  %6d  %6d  %11s  %8s  %8s\n", width, name, s.Calls, s.Failures,
+			ttft, formatDuration(s.Average()), formatDuration(s.Max)))
+	}
+	sb.WriteString("\nFirst token is the average wait for streamed text; turns that only call tools stream none. /stats models reset clears the totals.")
+
+	a.ui.Print("Model latency across all sessions:\n\n" + sb.String())
+	return nil
+}
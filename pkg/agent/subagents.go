@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/commands"
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// loadSubAgents reads the markdown agent definitions in the user and project
+// agents directories. Each file's frontmatter may set name and description;
+// the body becomes the sub-agent's instructions. Project agents replace user
+// agents of the same name. Project agents need a trusted workspace.
+func loadSubAgents() []tools.SubAgent {
+	byName := make(map[string]tools.SubAgent)
+	dirs := []func() (string, error){config.UserDir}
+	if config.ProjectTrusted() {
+		dirs = append(dirs, config.ProjectDir)
+	}
+	for _, dirFn := range dirs {
+		dir, err := dirFn()
+		if err != nil {
+			continue
+		}
+		paths, _ := filepath.Glob(filepath.Join(dir, "agents", "*.md"))
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			meta, body := commands.ParseFrontmatter(string(data))
+			name := meta["name"]
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(path), ".md")
+			}
+			description := meta["description"]
+			if description == "" {
+				description = "Custom agent defined in " + path
+			}
+			byName[name] = tools.SubAgent{Name: name, Description: description, Prompt: strings.TrimSpace(body)}
+		}
+	}
+
+	agents := make([]tools.SubAgent, 0, len(byName))
+	for _, a := range byName {
+		agents = append(agents, a)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	return agents
+}
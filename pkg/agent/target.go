@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/target"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// targetConnectTimeout bounds the connection check of a remote target at start
+const targetConnectTimeout = 15 * time.Second
+
+// setupTarget points Bash and the file tools at the target in the settings
+// and tells the model where it is working. A target that cannot be set up
+// leaves the tools on this machine.
+func (a *Agent) setupTarget() {
+	if a.cfg == nil || a.cfg.Settings == nil || len(a.history) == 0 {
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	t, err := target.FromSettings(a.cfg.Settings.Target, cwd)
+	var latency time.Duration
+	if c, ok := t.(target.Connector); ok && err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), targetConnectTimeout)
+		latency, err = c.Connect(ctx)
+		cancel()
+	}
+	if err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: %v; working on this machine", err))
+		t = target.Local{}
+	}
+	tools.Target = t
+
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<execution-target>"); i >= 0 {
+		end := strings.Index(system[i:], "</execution-target>")
+		system = system[:i] + system[i+end+len("</execution-target>"):]
+	}
+	if t.Remote() {
+		system += fmt.Sprintf("\n\n<execution-target>\nBash, Read, Write, Edit, Grep and Glob work on %s, where the project is in %s. "+
+			"Use paths there; paths in the local copy of the project are mapped to it. %s cannot be used.\n</execution-target>",
+			t.Name(), t.Dir(), localOnlyList())
+		status := fmt.Sprintf("Working on %s in %s", t.Name(), t.Dir())
+		if latency > 0 {
+			status += fmt.Sprintf(" (round trip %s)", latency.Round(time.Millisecond))
+		}
+		a.ui.Print(status)
+	}
+	a.history[0].Content = system
+}
+
+// prefetchReads fetches the files of the Read calls in a response together,
+// on targets where that saves round trips. The copies are dropped by the
+// returned function once the calls have run.
+func (a *Agent) prefetchReads(calls []llm.ToolCall) func() {
+	p, ok := tools.Target.(target.Prefetcher)
+	if !ok {
+		return func() {}
+	}
+	var paths []string
+	for _, tc := range calls {
+		if path, _ := tc.Args["file_path"].(string); tc.Name == "Read" && path != "" {
+			paths = append(paths, tools.Target.Path(path))
+		}
+	}
+	return p.Prefetch(context.Background(), paths)
+}
+
+// checkTarget blocks the tools that only work on this machine while the
+// tools work on a remote target
+func (a *Agent) checkTarget(toolName string) (string, bool) {
+	if !tools.Target.Remote() || !tools.LocalOnlyTools[toolName] {
+		return "", true
+	}
+	return fmt.Sprintf("Error: %s works only on this machine, and this project runs on %s. Use Bash, Read, Write or Edit instead.", toolName, tools.Target.Name()), false
+}
+
+func localOnlyList() string {
+	var names []string
+	for name := range tools.LocalOnlyTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
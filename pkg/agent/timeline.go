@@ -0,0 +1,272 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// Kinds of timeline entries
+const (
+	timelinePrompt = "prompt"
+	timelineModel  = "model"
+	timelineTool   = "tool"
+)
+
+// timelineEntry is one step of the session, recorded as it happens
+type timelineEntry struct {
+	kind     string
+	start    time.Time
+	duration time.Duration
+	label    string        // Prompt text or tool name
+	detail   string        // Tool call with its main argument
+	size     int           // Tool result size in bytes
+	calls    int           // Tool calls requested by a model turn
+	ttft     time.Duration // Time to the first streamed token of a model turn; 0 if none streamed
+	usage    *llm.Usage    // Tokens of a model turn, if the provider reported them
+	cost     float64       // Estimated USD of a model turn
+	file     string        // File written by a successful tool call
+	failed   bool
+}
+
+// fileWriteTools are the tools whose file_path or notebook_path a report
+// lists as changed
+var fileWriteTools = map[string]bool{"Write": true, "Edit": true, "MultiEdit": true, "NotebookEdit": true}
+
+// addTimeline appends an entry. The lock lets webhook reports read the
+// timeline from their own goroutine.
+func (a *Agent) addTimeline(e timelineEntry) {
+	a.timelineMu.Lock()
+	defer a.timelineMu.Unlock()
+	a.timeline = append(a.timeline, e)
+}
+
+func (a *Agent) recordPrompt(input string) {
+	a.addTimeline(timelineEntry{kind: timelinePrompt, start: a.clock(), label: input})
+}
+
+// recordModelTurn records a model request that started at start and
+// streamed its first token at firstToken (zero if it streamed none)
+func (a *Agent) recordModelTurn(start, firstToken time.Time, resp *llm.Message, err error) {
+	e := timelineEntry{kind: timelineModel, start: start, duration: a.clock().Sub(start), failed: err != nil}
+	if !firstToken.IsZero() {
+		e.ttft = firstToken.Sub(start)
+	}
+	if resp != nil {
+		e.calls = len(resp.ToolCalls)
+		e.usage = resp.Usage
+		if m := llm.GetModelByID(a.currentModel); m != nil && resp.Usage != nil {
+			e.cost = m.Cost(*resp.Usage)
+		}
+		a.recordCost(costTurns, a.currentModel, resp)
+	}
+	a.addTimeline(e)
+	if a.statsRoot != "" {
+		if err := history.RecordModelCall(a.statsRoot, a.currentModel, e.ttft, e.duration, e.failed); err != nil {
+			a.recordToolError("stats", err.Error())
+		}
+	}
+}
+
+func (a *Agent) recordToolCall(start time.Time, tc llm.ToolCall, result string, failed bool) {
+	e := timelineEntry{
+		kind:     timelineTool,
+		start:    start,
+		duration: a.clock().Sub(start),
+		label:    tc.Name,
+		detail:   describeToolCall(tc),
+		size:     len(result),
+		failed:   failed,
+	}
+	if fileWriteTools[tc.Name] && !failed && !strings.HasPrefix(result, "Error") {
+		for _, key := range []string{"file_path", "notebook_path"} {
+			if path, ok := tc.Args[key].(string); ok && path != "" {
+				e.file = path
+			}
+		}
+	}
+	a.addTimeline(e)
+	a.recordToolStats(tc, a.clock().Sub(start), result, failed)
+}
+
+// showTimeline handles /timeline: every prompt, model turn and tool call of
+// this run with durations, time to first token, result sizes and token counts
+func (a *Agent) showTimeline() error {
+	if len(a.timeline) == 0 {
+		a.ui.Print("Nothing to show yet. The timeline covers prompts sent since john started.")
+		return nil
+	}
+
+	origin := a.timeline[0].start
+	var sb strings.Builder
+	var prompts, turns, streamed, toolCalls, in, out int
+	var modelTime, ttft time.Duration
+	type toolTotal struct {
+		count    int
+		duration time.Duration
+	}
+	totals := make(map[string]*toolTotal)
+
+	for _, e := range a.timeline {
+		offset := formatOffset(e.start.Sub(origin))
+		switch e.kind {
+		case timelinePrompt:
+			prompts++
+			sb.WriteString(fmt.Sprintf("%s  > %s  %s\n", offset, i18n.FormatClock(e.start), firstLineOf(e.label, 70)))
+		case timelineModel:
+			turns++
+			modelTime += e.duration
+			line := fmt.Sprintf("%s    model  %s", offset, formatDuration(e.duration))
+			if e.ttft > 0 {
+				streamed++
+				ttft += e.ttft
+				line += "  first token " + formatDuration(e.ttft)
+			}
+			if e.usage != nil {
+				in += e.usage.InputTokens
+				out += e.usage.OutputTokens
+				line += fmt.Sprintf("  %s in / %s out", formatTokens(e.usage.InputTokens), formatTokens(e.usage.OutputTokens))
+			}
+			switch {
+			case e.failed:
+				line += "  failed"
+			case e.calls > 0:
+				line += "  " + plural(e.calls, "tool call")
+			}
+			sb.WriteString(line + "\n")
+		case timelineTool:
+			toolCalls++
+			t := totals[e.label]
+			if t == nil {
+				t = &toolTotal{}
+				totals[e.label] = t
+			}
+			t.count++
+			t.duration += e.duration
+			status := ""
+			if e.failed {
+				status = "  error"
+			}
+			sb.WriteString(fmt.Sprintf("%s      %s  %s  %s%s\n", offset, firstLineOf(e.detail, 60),
+				formatDuration(e.duration), formatBytes(e.size), status))
+		}
+	}
+
+	last := a.timeline[len(a.timeline)-1]
+	elapsed := last.start.Add(last.duration).Sub(origin)
+	header := fmt.Sprintf("Timeline: %s, %s, %s in %s since %s %s", plural(prompts, "prompt"), plural(turns, "model turn"),
+		plural(toolCalls, "tool call"), formatDuration(elapsed), i18n.FormatTime(origin), origin.In(i18n.Location()).Format("MST"))
+	if in+out > 0 {
+		header += fmt.Sprintf(" (%s tokens in, %s out)", formatTokens(in), formatTokens(out))
+	}
+
+	if turns > 0 {
+		sb.WriteString(fmt.Sprintf("\nModel: %s in %s", formatDuration(modelTime), plural(turns, "turn")))
+		if streamed > 0 {
+			sb.WriteString(fmt.Sprintf(", first token after %s on average", formatDuration(ttft/time.Duration(streamed))))
+		}
+		sb.WriteString("\n")
+	}
+	if len(totals) > 0 {
+		names := make([]string, 0, len(totals))
+		for name := range totals {
+			names = append(names, name)
+		}
+		// Busiest tools first
+		sort.Slice(names, func(i, j int) bool {
+			if totals[names[i]].count != totals[names[j]].count {
+				return totals[names[i]].count > totals[names[j]].count
+			}
+			return names[i] < names[j]
+		})
+		sb.WriteString("\nBy tool:\n")
+		for _, name := range names {
+			t := totals[name]
+			sb.WriteString(fmt.Sprintf("  %-12s %-10s %s\n", name, plural(t.count, "call"), formatDuration(t.duration)))
+		}
+	}
+
+	a.ui.Print(header + "\n\n" + strings.TrimRight(sb.String(), "\n"))
+	return nil
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func formatOffset(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+func formatBytes(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.1fKB", float64(n)/1024)
+}
+
+func formatTokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+// firstLineOf returns the first line of s, cut to max runes
+func firstLineOf(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " ..."
+	}
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max-3]) + "..."
+	}
+	return s
+}
+
+// turnSummary describes the time the last prompt took, split between the
+// model and the tools, e.g. "Turn took 12.3s (llm 8.1s, tools 4.2s)". It is
+// empty until a model turn has run.
+func (a *Agent) turnSummary() string {
+	a.timelineMu.Lock()
+	defer a.timelineMu.Unlock()
+
+	first := len(a.timeline)
+	for first > 0 && a.timeline[first-1].kind != timelinePrompt {
+		first--
+	}
+	if first == 0 || first == len(a.timeline) {
+		return ""
+	}
+	var llmTime, toolTime time.Duration
+	for _, e := range a.timeline[first:] {
+		switch e.kind {
+		case timelineModel:
+			llmTime += e.duration
+		case timelineTool:
+			toolTime += e.duration
+		}
+	}
+	last := a.timeline[len(a.timeline)-1]
+	total := last.start.Add(last.duration).Sub(a.timeline[first-1].start)
+	if toolTime == 0 {
+		return fmt.Sprintf("Turn took %s (llm %s)", formatDuration(total), formatDuration(llmTime))
+	}
+	return fmt.Sprintf("Turn took %s (llm %s, tools %s)", formatDuration(total), formatDuration(llmTime), formatDuration(toolTime))
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// startupTips checks how ready the project is for the agent and suggests
+// what to do about each gap, for the banner
+func (a *Agent) startupTips() []ui.Tip {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	var tips []ui.Tip
+
+	hasMemory := false
+	for _, name := range memoryFileNames {
+		if _, err := os.Stat(filepath.Join(cwd, name)); err == nil {
+			hasMemory = true
+			break
+		}
+	}
+	if !hasMemory {
+		tips = append(tips, ui.Tip{Text: "No AGENTS.md: /init writes one with this project's conventions", Input: "/init"})
+	}
+
+	if status, err := gitOutput("status", "--porcelain"); err == nil {
+		if lines := strings.TrimSpace(status); lines != "" {
+			n := len(strings.Split(lines, "\n"))
+			tips = append(tips, ui.Tip{Text: fmt.Sprintf("%s uncommitted: /diff shows them before the agent adds more", plural(n, "file")), Input: "/diff"})
+		}
+	}
+
+	if a.projectCommands().TestCommand == "" && detectProjectCommands(cwd).TestCommand == "" {
+		tips = append(tips, ui.Tip{Text: "No test command: /test setup sets one so the agent can check its work", Input: "/test setup"})
+	}
+	return tips
+}
+
+// mcpTip suggests /mcp when configured MCP servers did not connect
+func (a *Agent) mcpTip() {
+	var down []string
+	for _, s := range a.mcpManager.ListServers() {
+		if s.State == "failed" || s.State == "disconnected" {
+			down = append(down, s.Name)
+		}
+	}
+	if len(down) == 0 {
+		return
+	}
+	a.ui.AddTip(ui.Tip{
+		Text:  fmt.Sprintf("%s not connected (%s): /mcp shows why", plural(len(down), "MCP server"), strings.Join(down, ", ")),
+		Input: "/mcp",
+	})
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// malformedArgs handles a tool call whose streamed arguments did not parse.
+// The tool is not run: running it with empty arguments only fails with a
+// misleading "x is required". The raw buffer is logged for debugging and the
+// model is asked to re-issue the call.
+func (a *Agent) malformedArgs(tc llm.ToolCall) string {
+	a.ui.Print(fmt.Sprintf("Warning: %s was called with malformed arguments (%s); asking the model to retry", tc.Name, tc.ArgsError))
+	// Arguments can hold file contents, which ephemeral mode keeps off disk
+	if !a.ephemeral() {
+		if path, err := logMalformedArgs(a.currentModel, tc); err == nil {
+			a.ui.Print(fmt.Sprintf("Raw arguments logged to %s", path))
+		}
+	}
+	return fmt.Sprintf("Error: the arguments for %s could not be parsed as JSON (%s), so the tool was not run. Call %s again with the complete arguments as a single JSON object.",
+		tc.Name, tc.ArgsError, tc.Name)
+}
+
+// logMalformedArgs appends the raw argument buffer to the debug log
+func logMalformedArgs(model string, tc llm.ToolCall) (string, error) {
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "logs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "malformed-tool-args.log")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s model=%s tool=%s id=%s error=%q\n%s\n\n",
+		time.Now().Format(time.RFC3339), model, tc.Name, tc.ID, tc.ArgsError, tc.RawArgs)
+	return path, err
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// takeToolChoice returns the context for the next model request, carrying
+// the pending tool choice, and clears it so later requests are unconstrained.
+// A choice that forces a tool not offered in this request is dropped, since
+// providers reject it.
+func (a *Agent) takeToolChoice(ctx context.Context, apiTools []interface{}) context.Context {
+	choice := a.toolChoice
+	a.toolChoice = llm.ToolChoice{}
+	switch choice.Mode {
+	case llm.ToolChoiceAuto:
+		return ctx
+	case llm.ToolChoiceTool:
+		if !offersTool(apiTools, choice.Name) {
+			return ctx
+		}
+	case llm.ToolChoiceAny:
+		if len(apiTools) == 0 {
+			return ctx
+		}
+	}
+	return llm.WithToolChoice(ctx, choice)
+}
+
+func offersTool(apiTools []interface{}, name string) bool {
+	for _, t := range apiTools {
+		if def, ok := t.(tools.ToolDefinition); ok && def.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/tools"
+	"github.com/jbdamask/john-code/pkg/ui"
+)
+
+// ExportTools returns the definitions of every tool a session here would
+// offer the model, for john tools export: the built-in tools, exec tools
+// from settings and, with withMCP, the tools of the MCP servers, which are
+// connected for the purpose. It also returns the servers that did not
+// connect in time and are left out.
+func ExportTools(cfg *config.Config, withMCP bool) ([]tools.ExportedTool, []string) {
+	// Built quietly: the export goes to stdout
+	a := New(cfg, ui.NewScripted(strings.NewReader(""), io.Discard))
+	var pending []string
+	if withMCP && !a.safeMode() {
+		a.mcpManager.ExtraEnv = tools.SessionEnv.Pairs
+		if err := a.mcpManager.LoadAndConnect(context.Background()); err == nil {
+			a.registerMCPTools()
+			pending = a.mcpManager.Pending()
+		}
+		defer a.mcpManager.Close()
+	}
+	return tools.Export(a.tools), pending
+}
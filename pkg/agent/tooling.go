@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/project"
+)
+
+// setupTooling detects the project's package managers and tells the model
+// to use them
+func (a *Agent) setupTooling() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	a.tooling = project.Detect(cwd)
+	a.heldBack = make(map[string]bool)
+
+	if len(a.history) == 0 {
+		return
+	}
+	system := a.history[0].Content
+	if i := strings.Index(system, "\n\n<project-tooling>"); i >= 0 {
+		end := strings.Index(system[i:], "</project-tooling>")
+		system = system[:i] + system[i+end+len("</project-tooling>"):]
+	}
+	if summary := a.tooling.Summary(); summary != "" {
+		system += "\n\n<project-tooling>\nThis project uses these package managers. Use them, not alternatives, " +
+			"so dependencies stay in the project's lock files:\n" + summary + "\n</project-tooling>"
+	}
+	a.history[0].Content = system
+}
+
+// checkTooling stops the first Bash command that would change dependencies
+// with a package manager the project does not use. Running the same command
+// again goes ahead, for when the model really means it.
+func (a *Agent) checkTooling(tc llm.ToolCall) (string, bool) {
+	if tc.Name != "Bash" {
+		return "", true
+	}
+	command, _ := tc.Args["command"].(string)
+	warning := a.tooling.Check(command)
+	if warning == "" || a.heldBack[command] {
+		return "", true
+	}
+	if a.heldBack == nil {
+		a.heldBack = make(map[string]bool)
+	}
+	a.heldBack[command] = true
+	a.ui.Print(fmt.Sprintf("Held back: %s", firstLineOf(warning, 200)))
+	return "Error: the command was not run. " + warning + " If you really mean to use it, run the exact same command again.", false
+}
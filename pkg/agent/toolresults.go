@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+	"github.com/jbdamask/john-code/pkg/lang"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// toolSummaryTimeout bounds summarizing one tool result
+const toolSummaryTimeout = 60 * time.Second
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// overflowDir is where the full output of summarized tool results is kept:
+// next to the session log, or in the temp directory without a session or
+// when sessions are encrypted, so no plain-text output sits beside them
+func (a *Agent) overflowDir() string {
+	if a.session != nil && !history.SessionsEncrypted() {
+		return filepath.Join(strings.TrimSuffix(a.session.FilePath, ".jsonl"), "tool-results")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("john-tool-results-%d", os.Getpid()))
+}
+
+// summarizeToolResult condenses a result over its tool's threshold with the
+// summary model. The full output is saved to the overflow directory and the
+// summary points there, so the model can Read or Grep it for details. On
+// any failure the result is returned unchanged.
+func (a *Agent) summarizeToolResult(ctx context.Context, tc llm.ToolCall, result string) string {
+	var settings config.ToolResultSettings
+	if a.cfg != nil && a.cfg.Settings != nil {
+		settings = a.cfg.Settings.ToolResults
+	}
+	limit := settings.Threshold(tc.Name)
+	if limit == 0 || len(result) <= limit {
+		return result
+	}
+	client, modelID := a.summaryClient(settings.Model)
+	if client == nil {
+		return result
+	}
+
+	dir := a.overflowDir()
+	path := filepath.Join(dir, unsafeFileChars.ReplaceAllString(tc.Name+"-"+tc.ID, "_")+".txt")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: cannot save the full %s output: %v", tc.Name, err))
+		return result
+	}
+	if err := os.WriteFile(path, []byte(result), 0644); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: cannot save the full %s output: %v", tc.Name, err))
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolSummaryTimeout)
+	defer cancel()
+	args, _ := json.Marshal(tc.Args)
+	about := ""
+	if path, _ := tc.Args["file_path"].(string); tc.Name == "Read" && path != "" {
+		// Numbered lines hide the content heuristics, so the name decides
+		if l := lang.FromPath(path); l != nil {
+			about = fmt.Sprintf(" The file is %s; keep its definitions and their line numbers.", l.Name)
+		}
+	}
+	resp, err := client.Generate(ctx, []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: "You condense tool output for a coding agent. Keep file paths, line numbers, identifiers, " +
+				"error messages, counts and short code excerpts verbatim. Group similar entries and say how many " +
+				"were merged. Drop repetition and boilerplate. Use terse markdown.",
+		},
+		{
+			Role: llm.RoleUser,
+			Content: fmt.Sprintf("The agent's task: %s\n\nIt called %s with %s.%s Condense the output to what the task needs, in under 600 words:\n\n%s",
+				truncate(a.lastPrompt(), 1000), tc.Name, truncate(string(args), 500), about, result),
+		},
+	}, nil)
+	a.recordCost(tc.Name+" result summaries", modelID, resp)
+	summary := ""
+	if err == nil {
+		summary = strings.TrimSpace(resp.Content)
+	}
+	if summary == "" {
+		if err == nil {
+			err = fmt.Errorf("empty summary")
+		}
+		a.ui.Print(fmt.Sprintf("Warning: failed to summarize %s output, keeping it in full: %v", tc.Name, err))
+		return result
+	}
+
+	summary = fmt.Sprintf("[Summary of %s of %s output. The full output is in %s; Read it with offset and limit, or Grep it, for details the summary leaves out.]\n\n%s",
+		formatBytes(len(result)), tc.Name, path, summary)
+	a.ui.Print(fmt.Sprintf("Summarized %s output (%s to %s)", tc.Name, formatBytes(len(result)), formatBytes(len(summary))))
+	return summary
+}
+
+// summaryClient returns a client for toolResults.model, by default the fast
+// model, and the ID of its model. Without a key for it the current model
+// summarizes instead; with no usable model at all it returns nil.
+func (a *Agent) summaryClient(name string) (llm.Client, string) {
+	if name == "" {
+		name = "fast"
+	}
+	if model, err := a.resolveModel(name); err == nil {
+		client := a.createClientForModel(model.ID)
+		if _, isMock := client.(*llm.MockClient); !isMock {
+			return client, model.ID
+		}
+	}
+	if _, isMock := a.client.(*llm.MockClient); isMock {
+		return nil, ""
+	}
+	return a.client, a.currentModel
+}
+
+// lastPrompt returns the user's latest typed message
+func (a *Agent) lastPrompt() string {
+	for i := len(a.history) - 1; i > 0; i-- {
+		if a.history[i].Role == llm.RoleUser && a.history[i].Content != "" {
+			return strings.TrimSpace(a.history[i].Content)
+		}
+	}
+	return ""
+}
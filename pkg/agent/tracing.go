@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/telemetry"
+)
+
+// startModelSpan starts the span of a model request, named and described
+// after the OpenTelemetry GenAI conventions
+func (a *Agent) startModelSpan(ctx context.Context) (context.Context, *telemetry.Span) {
+	attrs := []telemetry.Attr{telemetry.String("gen_ai.operation.name", "chat")}
+	if m := llm.GetModelByID(a.currentModel); m != nil {
+		attrs = append(attrs, telemetry.String("gen_ai.system", string(m.Provider)), telemetry.String("gen_ai.request.model", m.APIModel))
+	} else {
+		attrs = append(attrs, telemetry.String("gen_ai.request.model", a.currentModel))
+	}
+	return telemetry.Start(ctx, "chat "+a.currentModel, telemetry.KindClient, attrs...)
+}
+
+// endModelSpan ends a model request's span with its tokens, time to first
+// token and the tool calls it asked for
+func endModelSpan(span *telemetry.Span, start, firstToken time.Time, resp *llm.Message, err error) {
+	if resp != nil {
+		span.SetAttributes(telemetry.Int("john.tool_calls", len(resp.ToolCalls)))
+		if resp.Usage != nil {
+			span.SetAttributes(telemetry.Int("gen_ai.usage.input_tokens", resp.Usage.InputTokens),
+				telemetry.Int("gen_ai.usage.output_tokens", resp.Usage.OutputTokens))
+		}
+	}
+	if !firstToken.IsZero() {
+		span.SetAttributes(telemetry.Duration("john.time_to_first_token", firstToken.Sub(start)))
+	}
+	span.End(err)
+}
+
+// endToolSpan ends a tool call's span, failed when the call was
+func endToolSpan(span *telemetry.Span, result string, failed bool) {
+	span.SetAttributes(telemetry.Int("john.result_bytes", len(result)))
+	if failed {
+		span.End(errors.New(firstLineOf(result, 200)))
+		return
+	}
+	span.End(nil)
+}
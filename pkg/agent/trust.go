@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/i18n"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// readOnlyTools are the tools allowed in an untrusted workspace
+var readOnlyTools = map[string]bool{
+	"Read":            true,
+	"Glob":            true,
+	"Grep":            true,
+	"WebFetch":        true,
+	"WebSearch":       true,
+	"Docs":            true,
+	"DepsAudit":       true,
+	"TodoWrite":       true,
+	"AskUserQuestion": true,
+	"BashOutput":      true,
+	"Calc":            true,
+}
+
+const readOnlyNotice = "\n\n<workspace-trust>\nThe user has not trusted this workspace, so you are in read-only plan mode: you can read and search files but cannot edit them, run commands or use MCP tools. Investigate and propose a plan; if changes are needed, tell the user to run /trust first.\n</workspace-trust>"
+
+// checkWorkspaceTrust asks whether to trust a workspace the first time john
+// runs in it. Until it is trusted, project settings, commands, agents and MCP
+// servers are ignored and only read-only tools may run.
+func (a *Agent) checkWorkspaceTrust() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	trusted, decided := config.WorkspaceTrust(cwd)
+	if !decided && a.skipPermissions() {
+		// No one may be there to answer. Tools run, but the project's own
+		// settings, commands and servers wait until it is trusted.
+		a.ui.Print(i18n.T("This workspace is not trusted yet: project settings, commands, hooks and MCP servers are disabled. Use /trust to change that."))
+		return
+	}
+	if !decided {
+		a.ui.Print("\n" + i18n.Tf("Do you trust the files in %s?", cwd))
+		a.ui.Print(i18n.T("Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers."))
+		trusted = i18n.IsYes(a.ui.Prompt(i18n.T("Trust this workspace? [y/N] ")))
+		if err := config.SetWorkspaceTrust(cwd, trusted); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to save trust decision: %v", err))
+		}
+		if trusted {
+			a.loadProjectConfig()
+			return
+		}
+	}
+	if !trusted {
+		a.setReadOnly(true)
+		a.ui.Print(i18n.T("This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that."))
+	}
+}
+
+// setReadOnly switches the plan-only mode of untrusted workspaces on or off
+func (a *Agent) setReadOnly(readOnly bool) {
+	readOnly = readOnly || a.policy().PermissionMode == config.PermissionModeReadOnly
+	a.readOnly = readOnly
+	if len(a.history) == 0 {
+		return
+	}
+	system := strings.ReplaceAll(a.history[0].Content, readOnlyNotice, "")
+	if readOnly {
+		system += readOnlyNotice
+	}
+	a.history[0].Content = system
+}
+
+// restrictTools leaves the agent only the named tools, for headless modes.
+// Task is never kept, as sub-agents would get every tool again.
+func (a *Agent) restrictTools(names []string) {
+	restricted := tools.NewRegistry()
+	for _, name := range names {
+		if t, ok := a.tools.Get(name); ok && name != "Task" {
+			restricted.Register(t)
+		}
+	}
+	a.tools = restricted
+}
+
+// checkReadOnly blocks tools that change anything while the workspace is
+// untrusted or plan mode is on
+func (a *Agent) checkReadOnly(toolName string) (string, bool) {
+	if readOnlyTools[toolName] {
+		return "", true
+	}
+	if a.planMode && !a.readOnly {
+		return fmt.Sprintf("Error: %s is not available in plan mode. Do not retry; write your plan now, including this change, and the user will switch plan mode off to carry it out.", toolName), false
+	}
+	if !a.readOnly {
+		return "", true
+	}
+	return fmt.Sprintf("Error: %s is not available because the user has not trusted this workspace (read-only mode). Do not retry; propose the change instead and tell the user to run /trust to allow it.", toolName), false
+}
+
+// handleTrust handles /trust and /trust revoke
+func (a *Agent) handleTrust(args string) error {
+	if a.safeMode() {
+		return fmt.Errorf("trust cannot change in safe mode; restart john without --safe-mode")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	switch strings.TrimSpace(args) {
+	case "":
+		if err := config.SetWorkspaceTrust(cwd, true); err != nil {
+			return fmt.Errorf("failed to save trust decision: %w", err)
+		}
+		a.loadProjectConfig()
+		a.setReadOnly(false)
+		a.setupProjectCommands()
+		a.ui.Print(fmt.Sprintf("Trusted %s. Project settings, commands, agents and MCP servers are enabled.", cwd))
+	case "revoke":
+		if err := config.SetWorkspaceTrust(cwd, false); err != nil {
+			return fmt.Errorf("failed to save trust decision: %w", err)
+		}
+		a.setReadOnly(true)
+		a.ui.Print(fmt.Sprintf("Revoked trust for %s. Read-only mode is on; restart john to unload project commands and MCP servers.", cwd))
+	default:
+		return fmt.Errorf("usage: /trust [revoke]")
+	}
+	return nil
+}
+
+// loadProjectConfig loads what a trusted workspace adds: project settings,
+// commands, agents and MCP servers
+func (a *Agent) loadProjectConfig() {
+	if settings, err := config.LoadSettings(); err != nil {
+		a.ui.Print(fmt.Sprintf("Warning: Failed to load project settings: %v", err))
+	} else {
+		if err := config.ConfigureHTTP(settings); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: invalid network settings: %v", err))
+		}
+		if a.cfg != nil {
+			a.cfg.Settings = settings
+		}
+		a.setupHyperlinks()
+		a.setupKeys()
+		a.setupEditorConfig()
+		a.setLanguage()
+		a.setupTarget()
+		a.registerExecTools()
+	}
+	if a.commands != nil {
+		a.registerCustomCommands(a.commands)
+	}
+	if t, ok := a.tools.Get("Task"); ok {
+		if tt, ok := t.(*tools.TaskTool); ok {
+			tt.SetAgents(loadSubAgents())
+		}
+	}
+	if a.mcpStarted {
+		if err := a.mcpManager.LoadAndConnect(context.Background()); err != nil {
+			a.ui.Print(fmt.Sprintf("Warning: Failed to load MCP servers: %v", err))
+		}
+		a.registerMCPTools()
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+	"github.com/jbdamask/john-code/pkg/tools"
+)
+
+// urlFetchTimeout bounds fetching and summarizing the links of one prompt
+const urlFetchTimeout = 45 * time.Second
+
+var promptURL = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// findURLs returns the distinct http(s) links in text, without trailing
+// punctuation, in order of appearance
+func findURLs(text string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, raw := range promptURL.FindAllString(text, -1) {
+		raw = strings.TrimRight(raw, ".,;:!?)]}")
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" || seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		urls = append(urls, raw)
+	}
+	return urls
+}
+
+// urlContext fetches the allowed links in a prompt and returns a reminder
+// carrying their content, so the model does not need a WebFetch round trip
+// first. Long pages are summarized with the prompt in mind. It returns ""
+// when auto-fetch is off or nothing could be fetched.
+func (a *Agent) urlContext(input string) string {
+	if a.cfg == nil || a.cfg.Settings == nil || !a.cfg.Settings.URLFetch.Enabled {
+		return ""
+	}
+	settings := a.cfg.Settings.URLFetch.WithDefaults()
+
+	var urls []string
+	for _, u := range findURLs(input) {
+		parsed, _ := url.Parse(u)
+		if !settings.Allows(parsed.Hostname()) {
+			continue
+		}
+		// Permission rules for WebFetch apply; ask rules need a tool call
+		decision, _ := a.cfg.Settings.Permissions.Check("WebFetch", map[string]interface{}{"url": u})
+		if decision == config.DecisionDeny || decision == config.DecisionAsk {
+			continue
+		}
+		urls = append(urls, u)
+		if len(urls) == settings.MaxURLs {
+			break
+		}
+	}
+	if len(urls) == 0 {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), urlFetchTimeout)
+	defer cancel()
+
+	a.ui.Print(fmt.Sprintf("Fetching %s...", plural(len(urls), "linked page")))
+	pages := make([]string, len(urls))
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	fetcher := tools.NewWebFetchTool()
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			pages[i], errs[i] = fetcher.Execute(ctx, map[string]interface{}{"url": u})
+		}(i, u)
+	}
+	wg.Wait()
+
+	var sb strings.Builder
+	for i, u := range urls {
+		page := pages[i]
+		if errs[i] != nil || !strings.HasPrefix(page, "Content of ") {
+			reason := page
+			if errs[i] != nil {
+				reason = errs[i].Error()
+			}
+			a.ui.Print(fmt.Sprintf("Could not fetch %s: %s", u, firstLineOf(reason, 80)))
+			continue
+		}
+		page = strings.TrimSpace(strings.TrimPrefix(page, fmt.Sprintf("Content of %s:", u)))
+
+		note := ""
+		if len(page) > settings.MaxChars {
+			summary, err := a.summarizePage(ctx, u, page, input)
+			if err != nil {
+				page = page[:settings.MaxChars] + "\n...[Truncated]..."
+				note = ", truncated"
+			} else {
+				page = summary
+				note = ", summarized"
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\n<linked-page url=%q>\n%s\n</linked-page>\n", u, page))
+		a.ui.Print(fmt.Sprintf("Attached %s (%s%s)", u, formatBytes(len(page)), note))
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\n<system-reminder>\nThe user's message links to these pages, fetched automatically:\n" + sb.String() +
+		"Summaries may leave out details; use WebFetch on a link if you need the full page.\n</system-reminder>"
+}
+
+// summarizePage condenses a fetched page to what matters for the prompt
+// that linked it
+func (a *Agent) summarizePage(ctx context.Context, pageURL, page, prompt string) (string, error) {
+	resp, err := a.client.Generate(ctx, []llm.Message{
+		{
+			Role: llm.RoleSystem,
+			Content: "You condense web pages for a coding agent. Keep API endpoints, function signatures, parameters, " +
+				"types, configuration keys, error codes and short code examples verbatim. Drop navigation, marketing " +
+				"and repetition. Use terse markdown.",
+		},
+		{
+			Role: llm.RoleUser,
+			Content: fmt.Sprintf("The agent's task: %s\n\nCondense this page (%s) to what the task needs, in under 800 words:\n\n%s",
+				truncate(strings.TrimSpace(prompt), 1000), pageURL, page),
+		},
+	}, nil)
+	a.recordCost(costPageSummary, a.currentModel, resp)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return "[Summary of the page]\n\n" + summary, nil
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// ErrTurnStopped is returned when the user stops a turn the watchdog paused
+var ErrTurnStopped = errors.New("turn stopped at the watchdog limit")
+
+// turnWatch tracks the time, tokens and spend of one turn so a runaway
+// chain of tool calls can be paused before it gets expensive
+type turnWatch struct {
+	limits config.WatchdogSettings
+	now    func() time.Time
+	start  time.Time
+	usage  llm.Usage
+	cost   float64
+	calls  int            // Model calls so far
+	tools  map[string]int // Tool calls by name
+	last   string         // Latest assistant text, for the summary
+
+	// Thresholds for the next pause; raised each time the user continues
+	nextDuration time.Duration
+	nextTokens   int
+	nextCost     float64
+}
+
+func (a *Agent) newTurnWatch() *turnWatch {
+	limits := config.WatchdogSettings{}
+	if a.cfg.Settings != nil {
+		limits = a.cfg.Settings.Watchdog
+	}
+	limits = limits.WithDefaults()
+	return &turnWatch{
+		limits:       limits,
+		now:          a.clock,
+		start:        a.clock(),
+		tools:        make(map[string]int),
+		nextDuration: time.Duration(limits.MaxDuration),
+		nextTokens:   limits.MaxTokens,
+		nextCost:     limits.MaxCost,
+	}
+}
+
+// addResponse counts a model call and its tool calls
+func (w *turnWatch) addResponse(modelID string, resp *llm.Message) {
+	w.calls++
+	if resp.Usage != nil {
+		w.usage.InputTokens += resp.Usage.InputTokens
+		w.usage.OutputTokens += resp.Usage.OutputTokens
+		if m := llm.GetModelByID(modelID); m != nil {
+			w.cost += m.Cost(*resp.Usage)
+		}
+	}
+	for _, tc := range resp.ToolCalls {
+		w.tools[tc.Name]++
+	}
+	if text := strings.TrimSpace(resp.Content); text != "" {
+		w.last = text
+	}
+}
+
+// exceeded names the limits the turn has passed, if any
+func (w *turnWatch) exceeded() []string {
+	if w.limits.Disabled {
+		return nil
+	}
+	var over []string
+	if elapsed := w.now().Sub(w.start); elapsed >= w.nextDuration {
+		over = append(over, fmt.Sprintf("%s elapsed (limit %s)", formatDuration(elapsed), formatDuration(w.nextDuration)))
+	}
+	if total := w.usage.InputTokens + w.usage.OutputTokens; total >= w.nextTokens {
+		over = append(over, fmt.Sprintf("%s tokens used (limit %s)", formatTokens(total), formatTokens(w.nextTokens)))
+	}
+	if w.cost >= w.nextCost {
+		over = append(over, fmt.Sprintf("~$%.2f spent (limit $%.2f)", w.cost, w.nextCost))
+	}
+	return over
+}
+
+// summary describes what the turn has done so far
+func (w *turnWatch) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "So far: %s, %s, %s in, %s out, ~$%.2f\n",
+		formatDuration(w.now().Sub(w.start)), plural(w.calls, "model call"),
+		formatTokens(w.usage.InputTokens), formatTokens(w.usage.OutputTokens), w.cost)
+
+	names := make([]string, 0, len(w.tools))
+	for name := range w.tools {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return w.tools[names[i]] > w.tools[names[j]] })
+	var counts []string
+	for _, name := range names {
+		counts = append(counts, fmt.Sprintf("%s ×%d", name, w.tools[name]))
+	}
+	if len(counts) > 0 {
+		fmt.Fprintf(&b, "Tools: %s\n", strings.Join(counts, ", "))
+	}
+	if w.last != "" {
+		fmt.Fprintf(&b, "Latest: %s\n", firstLineOf(w.last, 120))
+	}
+	return b.String()
+}
+
+// checkWatchdog pauses a turn that passed a limit and asks whether to go on.
+// Continuing allows the same amount again before the next pause.
+func (a *Agent) checkWatchdog(w *turnWatch) error {
+	over := w.exceeded()
+	if len(over) == 0 {
+		return nil
+	}
+
+	a.ui.Print(fmt.Sprintf("\nWatchdog: this turn has run long: %s.\n%s", strings.Join(over, "; "), w.summary()))
+	answer := strings.ToLower(strings.TrimSpace(a.ui.Prompt("Continue this turn? [y/N] ")))
+	if answer != "y" && answer != "yes" {
+		return ErrTurnStopped
+	}
+
+	for w.now().Sub(w.start) >= w.nextDuration {
+		w.nextDuration += time.Duration(w.limits.MaxDuration)
+	}
+	for w.usage.InputTokens+w.usage.OutputTokens >= w.nextTokens {
+		w.nextTokens += w.limits.MaxTokens
+	}
+	for w.cost >= w.nextCost {
+		w.nextCost += w.limits.MaxCost
+	}
+	return nil
+}
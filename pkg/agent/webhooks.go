@@ -0,0 +1,308 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// webhookTimeout bounds one webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// webhookCheckInterval is how often idle and scheduled reports are checked
+var webhookCheckInterval = time.Minute
+
+// sessionReport summarizes the session for webhooks. The JSON format posts
+// it as is.
+type sessionReport struct {
+	Event        string    `json:"event"`
+	SessionID    string    `json:"sessionId,omitempty"`
+	Project      string    `json:"project"`
+	Model        string    `json:"model"`
+	Started      time.Time `json:"started"`
+	Duration     string    `json:"duration"`
+	Prompts      int       `json:"prompts"`
+	ModelTurns   int       `json:"modelTurns"`
+	ToolCalls    int       `json:"toolCalls"`
+	ToolErrors   int       `json:"toolErrors"`
+	InputTokens  int       `json:"inputTokens"`
+	OutputTokens int       `json:"outputTokens"`
+	CostUSD      float64   `json:"costUsd"`
+	FilesChanged []string  `json:"filesChanged"`
+	DiffStat     string    `json:"diffStat,omitempty"` // git diff --shortstat of the working tree
+	LastPrompt   string    `json:"lastPrompt,omitempty"`
+}
+
+// webhooks returns the configured webhooks with defaults applied
+func (a *Agent) webhooks() []config.WebhookSettings {
+	if a.cfg == nil || a.cfg.Settings == nil {
+		return nil
+	}
+	var hooks []config.WebhookSettings
+	for _, w := range a.cfg.Settings.Webhooks {
+		if w.URL != "" {
+			hooks = append(hooks, w.WithDefaults())
+		}
+	}
+	return hooks
+}
+
+// buildReport summarizes the timeline since john started
+func (a *Agent) buildReport(event string) sessionReport {
+	a.timelineMu.Lock()
+	defer a.timelineMu.Unlock()
+
+	cwd, _ := os.Getwd()
+	r := sessionReport{
+		Event:        event,
+		Project:      filepath.Base(cwd),
+		Model:        a.currentModel,
+		FilesChanged: []string{},
+	}
+	if a.session != nil {
+		r.SessionID = a.session.SessionID
+	}
+	if len(a.timeline) == 0 {
+		r.Started = time.Now()
+		r.Duration = "0s"
+		return r
+	}
+	r.Started = a.timeline[0].start
+	r.Duration = formatDuration(time.Since(r.Started))
+
+	files := make(map[string]bool)
+	for _, e := range a.timeline {
+		switch e.kind {
+		case timelinePrompt:
+			r.Prompts++
+			r.LastPrompt = firstLineOf(e.label, 200)
+		case timelineModel:
+			r.ModelTurns++
+			r.CostUSD += e.cost
+			if e.usage != nil {
+				r.InputTokens += e.usage.InputTokens
+				r.OutputTokens += e.usage.OutputTokens
+			}
+		case timelineTool:
+			r.ToolCalls++
+			if e.failed {
+				r.ToolErrors++
+			}
+			if e.file != "" {
+				if rel, err := filepath.Rel(cwd, e.file); err == nil && !strings.HasPrefix(rel, "..") {
+					files[rel] = true
+				} else {
+					files[e.file] = true
+				}
+			}
+		}
+	}
+	for f := range files {
+		r.FilesChanged = append(r.FilesChanged, f)
+	}
+	sort.Strings(r.FilesChanged)
+	return r
+}
+
+// lastActivity returns when the last prompt, model turn or tool call ended
+func (a *Agent) lastActivity() time.Time {
+	a.timelineMu.Lock()
+	defer a.timelineMu.Unlock()
+	if len(a.timeline) == 0 {
+		return time.Time{}
+	}
+	last := a.timeline[len(a.timeline)-1]
+	return last.start.Add(last.duration)
+}
+
+// slackText renders a report as Slack mrkdwn
+func (r sessionReport) slackText() string {
+	title := map[string]string{
+		config.WebhookSessionEnd: "session ended",
+		config.WebhookIdle:       "session idle",
+		config.WebhookScheduled:  "session update",
+	}[r.Event]
+	var b strings.Builder
+	fmt.Fprintf(&b, "*john %s* in `%s` (%s)\n", title, r.Project, r.Model)
+	fmt.Fprintf(&b, "%s · %s · %s", r.Duration, plural(r.Prompts, "prompt"), plural(r.ToolCalls, "tool call"))
+	if r.ToolErrors > 0 {
+		fmt.Fprintf(&b, " (%d failed)", r.ToolErrors)
+	}
+	fmt.Fprintf(&b, " · %s in / %s out · ~$%.2f\n", formatTokens(r.InputTokens), formatTokens(r.OutputTokens), r.CostUSD)
+	if len(r.FilesChanged) > 0 {
+		files := r.FilesChanged
+		more := ""
+		if len(files) > 10 {
+			files, more = files[:10], fmt.Sprintf(" and %d more", len(r.FilesChanged)-10)
+		}
+		fmt.Fprintf(&b, "Changed: %s%s\n", strings.Join(files, ", "), more)
+	}
+	if r.DiffStat != "" {
+		fmt.Fprintf(&b, "Working tree: %s\n", r.DiffStat)
+	}
+	if r.LastPrompt != "" {
+		fmt.Fprintf(&b, "Last prompt: %s\n", r.LastPrompt)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// postReport delivers a report to one webhook
+func postReport(ctx context.Context, hook config.WebhookSettings, r sessionReport) error {
+	var payload interface{} = r
+	if hook.Format == "slack" {
+		payload = map[string]string{"text": r.slackText()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "JohnCode/1.0")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := config.NewHTTPClient(webhookTimeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", hook.URL, resp.Status)
+	}
+	return nil
+}
+
+// sendReports posts a report for event to every webhook that wants it and
+// returns the delivery errors
+func (a *Agent) sendReports(event string, hooks []config.WebhookSettings) []error {
+	var wanted []config.WebhookSettings
+	for _, hook := range hooks {
+		if hook.On(event) {
+			wanted = append(wanted, hook)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	r := a.buildReport(event)
+	if out, err := exec.Command("git", "diff", "--shortstat").Output(); err == nil {
+		r.DiffStat = strings.TrimSpace(string(out))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	var errs []error
+	for _, hook := range wanted {
+		if err := postReport(ctx, hook, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// webhookTimer decides when the idle and scheduled reports of the webhooks
+// are due
+type webhookTimer struct {
+	hooks       []config.WebhookSettings
+	lastSent    []time.Time // Last scheduled report per hook
+	idleSentFor []time.Time // Activity an idle report was sent for
+}
+
+func newWebhookTimer(hooks []config.WebhookSettings, started time.Time) *webhookTimer {
+	t := &webhookTimer{
+		hooks:       hooks,
+		lastSent:    make([]time.Time, len(hooks)),
+		idleSentFor: make([]time.Time, len(hooks)),
+	}
+	for i := range t.lastSent {
+		t.lastSent[i] = started
+	}
+	return t
+}
+
+// dueReport is a report to send to one webhook
+type dueReport struct {
+	event string
+	hook  config.WebhookSettings
+}
+
+// due returns the reports due at now, given the time of the last activity.
+// A scheduled report goes out every Every if anything happened since the
+// last one; an idle report once per quiet spell of IdleAfter.
+func (t *webhookTimer) due(now, activity time.Time) []dueReport {
+	var reports []dueReport
+	for i, hook := range t.hooks {
+		if hook.On(config.WebhookScheduled) && now.Sub(t.lastSent[i]) >= time.Duration(hook.Every) {
+			if activity.After(t.lastSent[i]) {
+				reports = append(reports, dueReport{config.WebhookScheduled, hook})
+			}
+			t.lastSent[i] = now
+		}
+		if hook.On(config.WebhookIdle) && !activity.IsZero() && !activity.Equal(t.idleSentFor[i]) &&
+			now.Sub(activity) >= time.Duration(hook.IdleAfter) {
+			reports = append(reports, dueReport{config.WebhookIdle, hook})
+			t.idleSentFor[i] = activity
+		}
+	}
+	return reports
+}
+
+// startWebhooks sends idle and scheduled reports in the background until
+// the returned stop function is called. Failures there are dropped, as the
+// prompt may be active; the session end report prints them.
+func (a *Agent) startWebhooks() (stop func()) {
+	hooks := a.webhooks()
+	var timed []config.WebhookSettings
+	for _, hook := range hooks {
+		if hook.On(config.WebhookIdle) || hook.On(config.WebhookScheduled) {
+			timed = append(timed, hook)
+		}
+	}
+	if len(timed) == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(webhookCheckInterval)
+		defer ticker.Stop()
+		timer := newWebhookTimer(timed, time.Now())
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				for _, r := range timer.due(now, a.lastActivity()) {
+					a.sendReports(r.event, []config.WebhookSettings{r.hook})
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// reportSessionEnd posts the final report when the session exits
+func (a *Agent) reportSessionEnd() {
+	hooks := a.webhooks()
+	if len(hooks) == 0 {
+		return
+	}
+	for _, err := range a.sendReports(config.WebhookSessionEnd, hooks) {
+		a.ui.Print(fmt.Sprintf("Warning: webhook failed: %v", err))
+	}
+}
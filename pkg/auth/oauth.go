@@ -0,0 +1,336 @@
+// Package auth signs in to LLM providers with OAuth, for users on plans
+// that come with a login rather than an API key, and keeps the saved tokens
+// fresh.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// Providers are the providers john login can sign in to, with the variable
+// holding their API key otherwise
+var Providers = map[string]string{
+	"anthropic": "ANTHROPIC_API_KEY",
+	"openai":    "OPENAI_API_KEY",
+}
+
+// refreshMargin is how long before expiry a token is refreshed, so a request
+// never starts with a token about to lapse
+const refreshMargin = time.Minute
+
+// pollInterval is how often the device flow polls when the provider does
+// not say. Overridden by tests.
+var pollInterval = 5 * time.Second
+
+// Client returns the OAuth client configured for a provider in settings
+func Client(settings *config.Settings, provider string) (config.OAuthSettings, error) {
+	keyEnv, ok := Providers[provider]
+	if !ok {
+		return config.OAuthSettings{}, fmt.Errorf("unknown provider %q; john login supports anthropic and openai", provider)
+	}
+	var client config.OAuthSettings
+	if settings != nil {
+		client = settings.OAuth[provider]
+	}
+	if client.ClientID == "" || client.TokenURL == "" || (client.AuthorizationURL == "" && client.DeviceAuthorizationURL == "") {
+		return client, fmt.Errorf("no OAuth client is configured for %s: set oauth.%s (clientId, tokenUrl and authorizationUrl or deviceAuthorizationUrl) in settings, or set %s", provider, provider, keyEnv)
+	}
+	return client, nil
+}
+
+// tokenResponse is an OAuth token endpoint's answer, successful or not
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (r tokenResponse) err() error {
+	if r.ErrorDescription != "" {
+		return fmt.Errorf("%s: %s", r.Error, r.ErrorDescription)
+	}
+	return fmt.Errorf("%s", r.Error)
+}
+
+func (r tokenResponse) credential() *config.Credential {
+	cred := &config.Credential{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken, Scope: r.Scope}
+	if r.ExpiresIn > 0 {
+		cred.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return cred
+}
+
+// postForm posts form to endpoint and decodes the JSON answer into out. An
+// OAuth error in the body is left for the caller to read.
+func postForm(ctx context.Context, endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := config.NewHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// requestToken calls the token endpoint and returns the new credential
+func requestToken(ctx context.Context, client config.OAuthSettings, form url.Values) (*config.Credential, error) {
+	form.Set("client_id", client.ClientID)
+	var resp tokenResponse
+	if err := postForm(ctx, client.TokenURL, form, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, resp.err()
+	}
+	if resp.AccessToken == "" {
+		return nil, fmt.Errorf("%s returned no access token", client.TokenURL)
+	}
+	return resp.credential(), nil
+}
+
+// DeviceLogin runs the device authorization flow (RFC 8628): show gets the
+// page to visit and the code to enter there, and DeviceLogin polls until the
+// user approves, declines or the code expires
+func DeviceLogin(ctx context.Context, client config.OAuthSettings, show func(verificationURL, userCode string)) (*config.Credential, error) {
+	var device struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+		Error                   string `json:"error"`
+	}
+	form := url.Values{"client_id": {client.ClientID}, "scope": {strings.Join(client.Scopes, " ")}}
+	if err := postForm(ctx, client.DeviceAuthorizationURL, form, &device); err != nil {
+		return nil, err
+	}
+	if device.Error != "" || device.DeviceCode == "" {
+		return nil, fmt.Errorf("device authorization failed: %s", device.Error)
+	}
+	verification := device.VerificationURIComplete
+	if verification == "" {
+		verification = device.VerificationURI
+	}
+	show(verification, device.UserCode)
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = pollInterval
+	}
+	expires := 15 * time.Minute
+	if device.ExpiresIn > 0 {
+		expires = time.Duration(device.ExpiresIn) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, expires)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the login was not approved in time")
+		case <-time.After(interval):
+		}
+		var resp tokenResponse
+		form := url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {device.DeviceCode},
+			"client_id":   {client.ClientID},
+		}
+		if err := postForm(ctx, client.TokenURL, form, &resp); err != nil {
+			return nil, err
+		}
+		switch resp.Error {
+		case "":
+			if resp.AccessToken == "" {
+				return nil, fmt.Errorf("%s returned no access token", client.TokenURL)
+			}
+			return resp.credential(), nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, resp.err()
+		}
+	}
+}
+
+// BrowserLogin runs the authorization code flow with PKCE: open gets the
+// authorization URL, and BrowserLogin waits for the browser to come back to
+// a listener on localhost with the code
+func BrowserLogin(ctx context.Context, client config.OAuthSettings, open func(authorizationURL string)) (*config.Credential, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+	redirect := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	verifier, state := randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {redirect},
+		"scope":                 {strings.Join(client.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(client.AuthorizationURL, "?") {
+		sep = "&"
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		res := result{code: q.Get("code")}
+		switch {
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("the login failed: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("state") != state:
+			res.err = fmt.Errorf("the login answer does not match this request")
+		case res.code == "":
+			res.err = fmt.Errorf("the login answer has no code")
+		}
+		if res.err != nil {
+			fmt.Fprintf(w, "Login failed: %v. You can close this tab.", res.err)
+		} else {
+			fmt.Fprint(w, "Logged in to john. You can close this tab.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	open(client.AuthorizationURL + sep + query.Encode())
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return requestToken(ctx, client, url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {res.code},
+			"redirect_uri":  {redirect},
+			"code_verifier": {verifier},
+		})
+	}
+}
+
+// Refresh trades a credential's refresh token for a new access token. The
+// refresh token is kept when the provider does not rotate it.
+func Refresh(ctx context.Context, client config.OAuthSettings, cred config.Credential) (*config.Credential, error) {
+	if cred.RefreshToken == "" {
+		return nil, fmt.Errorf("the login has expired and cannot be refreshed")
+	}
+	fresh, err := requestToken(ctx, client, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {cred.RefreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fresh.RefreshToken == "" {
+		fresh.RefreshToken = cred.RefreshToken
+	}
+	return fresh, nil
+}
+
+// randomString returns 32 random bytes, base64url encoded, for PKCE
+// verifiers and state values
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// TokenSource hands out a provider's saved access token, refreshing and
+// saving it when it is about to expire
+type TokenSource struct {
+	provider string
+	client   config.OAuthSettings
+
+	mu sync.Mutex
+}
+
+// NewTokenSource returns the token source for a provider, or false when
+// there is no saved login for it
+func NewTokenSource(settings *config.Settings, provider string) (*TokenSource, bool) {
+	if _, ok := config.LoadCredential(provider); !ok {
+		return nil, false
+	}
+	var client config.OAuthSettings
+	if settings != nil {
+		client = settings.OAuth[provider]
+	}
+	return &TokenSource{provider: provider, client: client}, true
+}
+
+// Token returns an access token valid for at least refreshMargin. The saved
+// credential is read each time, so a refresh by another john is picked up.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cred, ok := config.LoadCredential(s.provider)
+	if !ok {
+		return "", fmt.Errorf("not logged in to %s; run john login %s", s.provider, s.provider)
+	}
+	if !cred.Expired(refreshMargin) {
+		return cred.AccessToken, nil
+	}
+	if s.client.TokenURL == "" {
+		return "", fmt.Errorf("the %s login has expired and oauth.%s.tokenUrl is not set to refresh it; run john login %s", s.provider, s.provider, s.provider)
+	}
+	fresh, err := Refresh(ctx, s.client, cred)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh the %s login (%v); run john login %s", s.provider, err, s.provider)
+	}
+	if err := config.SaveCredential(s.provider, fresh); err != nil {
+		return "", err
+	}
+	return fresh.AccessToken, nil
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// fakeProvider is an OAuth server that approves a device code on the second
+// poll and accepts any authorization code whose PKCE verifier matches
+func fakeProvider(t *testing.T) (*httptest.Server, config.OAuthSettings) {
+	polls := 0
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code": "dev-1", "user_code": "ABCD-1234", "verification_uri": "https://example.com/device", "interval": 0,
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		challenge = r.URL.Query().Get("code_challenge")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		reply := map[string]interface{}{}
+		switch r.Form.Get("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if polls++; polls < 2 {
+				reply["error"] = "authorization_pending"
+				break
+			}
+			reply = map[string]interface{}{"access_token": "device-token", "refresh_token": "refresh-1", "expires_in": 3600}
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "code-1" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				reply["error"] = "invalid_grant"
+				break
+			}
+			reply = map[string]interface{}{"access_token": "browser-token", "expires_in": 3600}
+		case "refresh_token":
+			reply = map[string]interface{}{"access_token": "refreshed-token", "expires_in": 3600}
+		}
+		json.NewEncoder(w).Encode(reply)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, config.OAuthSettings{
+		ClientID:               "john",
+		AuthorizationURL:       server.URL + "/authorize",
+		DeviceAuthorizationURL: server.URL + "/device",
+		TokenURL:               server.URL + "/token",
+	}
+}
+
+func TestDeviceLoginPollsUntilApproved(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+	_, client := fakeProvider(t)
+	var code string
+	cred, err := DeviceLogin(context.Background(), client, func(_, userCode string) { code = userCode })
+	if err != nil {
+		t.Fatalf("DeviceLogin: %v", err)
+	}
+	if code != "ABCD-1234" || cred.AccessToken != "device-token" || cred.RefreshToken != "refresh-1" || cred.Expired(time.Minute) {
+		t.Errorf("code %q, credential %+v", code, cred)
+	}
+}
+
+func TestBrowserLoginExchangesTheCodeWithPKCE(t *testing.T) {
+	_, client := fakeProvider(t)
+	cred, err := BrowserLogin(context.Background(), client, func(authorizationURL string) {
+		http.Get(authorizationURL)
+		u, _ := url.Parse(authorizationURL)
+		q := u.Query()
+		go http.Get(q.Get("redirect_uri") + "?code=code-1&state=" + url.QueryEscape(q.Get("state")))
+	})
+	if err != nil {
+		t.Fatalf("BrowserLogin: %v", err)
+	}
+	if cred.AccessToken != "browser-token" {
+		t.Errorf("credential %+v", cred)
+	}
+}
+
+func TestTokenSourceRefreshesExpiredLogins(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	_, client := fakeProvider(t)
+	config.SaveCredential("openai", &config.Credential{AccessToken: "old", RefreshToken: "refresh-1", Expiry: time.Now().Add(10 * time.Second)})
+
+	tokens, ok := NewTokenSource(&config.Settings{OAuth: map[string]config.OAuthSettings{"openai": client}}, "openai")
+	if !ok {
+		t.Fatal("no token source for a saved login")
+	}
+	token, err := tokens.Token(context.Background())
+	if err != nil || token != "refreshed-token" {
+		t.Fatalf("Token = %q, %v", token, err)
+	}
+	saved, _ := config.LoadCredential("openai")
+	if saved.AccessToken != "refreshed-token" || saved.RefreshToken != "refresh-1" {
+		t.Errorf("saved %+v", saved)
+	}
+	if _, ok := NewTokenSource(nil, "anthropic"); ok {
+		t.Error("token source for a provider without a login")
+	}
+}
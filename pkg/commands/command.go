@@ -1,5 +1,11 @@
 package commands
 
+import (
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
 // Command represents a slash command that can be executed
 type Command interface {
 	// Name returns the command name (without the leading slash)
@@ -14,6 +20,64 @@ type Command interface {
 	Execute() (commandMessage string, instructions string, err error)
 }
 
+// LocalCommand is implemented by commands that act on the session directly
+// (showing state, changing settings) instead of sending a prompt to the model.
+// The agent calls Run in place of Execute for these commands.
+type LocalCommand interface {
+	Command
+
+	// Run executes the command with the raw text following the command name
+	Run(args string) error
+}
+
+// localCommand is a LocalCommand that hands its arguments to a function
+type localCommand struct {
+	name        string
+	description string
+	run         func(args string) error
+}
+
+// NewLocal creates a command that runs locally, calling run with the text
+// following the command name
+func NewLocal(name, description string, run func(args string) error) LocalCommand {
+	return &localCommand{name: name, description: description, run: run}
+}
+
+// Name returns the command name
+func (c *localCommand) Name() string {
+	return c.name
+}
+
+// Description returns a short description shown in the command picker
+func (c *localCommand) Description() string {
+	return c.description
+}
+
+// Execute is not used for local commands - they run with Run
+func (c *localCommand) Execute() (commandMessage string, instructions string, err error) {
+	return "", "", nil
+}
+
+// Run calls the command's function with its arguments
+func (c *localCommand) Run(args string) error {
+	return c.run(args)
+}
+
+// ToolChoiceCommand is implemented by prompt commands that constrain the
+// tool calls of the first model request they cause
+type ToolChoiceCommand interface {
+	Command
+
+	ToolChoice() llm.ToolChoice
+}
+
+// ParseInput splits "/name rest of line" into the command name and its arguments
+func ParseInput(input string) (name string, args string) {
+	input = strings.TrimSpace(strings.TrimPrefix(input, "/"))
+	name, args, _ = strings.Cut(input, " ")
+	return name, strings.TrimSpace(args)
+}
+
 // Registry holds all registered slash commands
 type Registry struct {
 	commands map[string]Command
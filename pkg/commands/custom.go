@@ -0,0 +1,288 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ScopedCommand is implemented by prompt commands that limit the tools or
+// change the model of the turn they start. The agent restores both when the
+// turn ends.
+type ScopedCommand interface {
+	Command
+
+	// AllowedTools returns the tools the turn may use; empty means all
+	AllowedTools() []string
+
+	// Model returns the model ID or alias for the turn; empty keeps the current one
+	Model() string
+}
+
+// ArgsCommand is implemented by prompt commands that use the text typed after
+// the command name. The agent calls ExecuteArgs in place of Execute for these.
+type ArgsCommand interface {
+	Command
+
+	ExecuteArgs(args string) (commandMessage string, instructions string, err error)
+}
+
+// CustomCommand is a prompt defined in a markdown file under .john/commands
+// (project) or ~/.config/john-code/commands (user). The file name is the
+// command name and $ARGUMENTS in the body is replaced with the typed arguments.
+// Frontmatter may declare named arguments, which fill $name placeholders,
+// the tools the command may use and a model to run it with.
+type CustomCommand struct {
+	name         string
+	description  string
+	body         string
+	source       string // "project" or "user"
+	args         []commandArg
+	allowedTools []string
+	model        string
+}
+
+// commandArg is an argument declared in a custom command's frontmatter:
+// "name" is required, "name?" optional and "name=value" has a default
+type commandArg struct {
+	name     string
+	optional bool
+	value    string // Default
+}
+
+var argName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseCommandArgs reads the comma-separated arguments declaration
+func parseCommandArgs(decl string) ([]commandArg, error) {
+	var args []commandArg
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(decl, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var arg commandArg
+		if name, value, ok := strings.Cut(field, "="); ok {
+			arg = commandArg{name: strings.TrimSpace(name), optional: true, value: strings.TrimSpace(value)}
+		} else if name, ok := strings.CutSuffix(field, "?"); ok {
+			arg = commandArg{name: name, optional: true}
+		} else {
+			arg = commandArg{name: field}
+		}
+		if !argName.MatchString(arg.name) || arg.name == "ARGUMENTS" {
+			return nil, fmt.Errorf("invalid argument name %q", arg.name)
+		}
+		if seen[arg.name] {
+			return nil, fmt.Errorf("argument %q declared twice", arg.name)
+		}
+		if len(args) > 0 && args[len(args)-1].optional && !arg.optional {
+			return nil, fmt.Errorf("required argument %q follows an optional one", arg.name)
+		}
+		seen[arg.name] = true
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// SplitArgs splits typed command arguments at spaces, keeping text in single
+// or double quotes together
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// Name returns the command name
+func (c *CustomCommand) Name() string {
+	return c.name
+}
+
+// Description returns a short description shown in the command picker
+func (c *CustomCommand) Description() string {
+	if len(c.args) > 0 {
+		return fmt.Sprintf("%s: %s (%s)", c.Usage(), c.description, c.source)
+	}
+	return fmt.Sprintf("%s (%s)", c.description, c.source)
+}
+
+// Usage returns the command with its declared arguments, e.g. "/review <file> [focus]"
+func (c *CustomCommand) Usage() string {
+	usage := "/" + c.name
+	for _, arg := range c.args {
+		if arg.optional {
+			usage += " [" + arg.name + "]"
+		} else {
+			usage += " <" + arg.name + ">"
+		}
+	}
+	return usage
+}
+
+// AllowedTools returns the tools declared in allowed-tools
+func (c *CustomCommand) AllowedTools() []string {
+	return c.allowedTools
+}
+
+// Model returns the model declared in the frontmatter
+func (c *CustomCommand) Model() string {
+	return c.model
+}
+
+// Execute runs the command without arguments
+func (c *CustomCommand) Execute() (commandMessage string, instructions string, err error) {
+	return c.ExecuteArgs("")
+}
+
+// placeholder matches $name and ${name} in a command body
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ExecuteArgs returns the command body with $ARGUMENTS and the declared
+// arguments filled in. Missing required arguments and extra ones are errors.
+func (c *CustomCommand) ExecuteArgs(args string) (commandMessage string, instructions string, err error) {
+	values := map[string]string{"ARGUMENTS": args}
+	if len(c.args) > 0 {
+		typed, err := SplitArgs(args)
+		if err != nil {
+			return "", "", fmt.Errorf("%v; usage: %s", err, c.Usage())
+		}
+		if len(typed) > len(c.args) {
+			return "", "", fmt.Errorf("too many arguments; usage: %s (quote values with spaces)", c.Usage())
+		}
+		for i, arg := range c.args {
+			switch {
+			case i < len(typed):
+				values[arg.name] = typed[i]
+			case !arg.optional:
+				return "", "", fmt.Errorf("missing argument %s; usage: %s", arg.name, c.Usage())
+			default:
+				values[arg.name] = arg.value
+			}
+		}
+	}
+
+	commandMessage = fmt.Sprintf("<command-message>%s is running…</command-message>\n<command-name>/%s</command-name>", c.name, c.name)
+	if args != "" {
+		commandMessage += fmt.Sprintf("\n<command-args>%s</command-args>", args)
+	}
+	// Other $words, like shell variables in examples, are left alone
+	instructions = placeholder.ReplaceAllStringFunc(c.body, func(m string) string {
+		sub := placeholder.FindStringSubmatch(m)
+		name := sub[1] + sub[2]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return m
+	})
+	return commandMessage, instructions, nil
+}
+
+// LoadCustomCommands reads every *.md file in dir. A missing directory yields
+// no commands.
+func LoadCustomCommands(dir string, source string) ([]*CustomCommand, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cmds []*CustomCommand
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read command %s: %w", path, err)
+		}
+		meta, body := ParseFrontmatter(string(data))
+
+		description := meta["description"]
+		if description == "" {
+			description = firstLine(body)
+		}
+		args, err := parseCommandArgs(meta["arguments"])
+		if err != nil {
+			return nil, fmt.Errorf("command %s: %w", path, err)
+		}
+		var allowedTools []string
+		for _, name := range strings.Split(meta["allowed-tools"], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				allowedTools = append(allowedTools, name)
+			}
+		}
+		cmds = append(cmds, &CustomCommand{
+			name:         strings.TrimSuffix(filepath.Base(path), ".md"),
+			description:  description,
+			body:         strings.TrimSpace(body),
+			source:       source,
+			args:         args,
+			allowedTools: allowedTools,
+			model:        meta["model"],
+		})
+	}
+	return cmds, nil
+}
+
+// ParseFrontmatter splits an optional "---" delimited header of "key: value"
+// lines from the rest of a markdown file.
+func ParseFrontmatter(content string) (map[string]string, string) {
+	meta := make(map[string]string)
+	content = strings.TrimPrefix(content, "\ufeff")
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return meta, content
+	}
+
+	rest := content[strings.Index(content, "\n")+1:]
+	end := strings.Index(rest, "\n---")
+	if end == -1 {
+		return meta, content
+	}
+	for _, line := range strings.Split(rest[:end], "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			meta[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+
+	body := rest[end+len("\n---"):]
+	if i := strings.Index(body, "\n"); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+	return meta, body
+}
+
+// firstLine returns the first non-empty line without markdown heading marks
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "# ")); line != "" {
+			return line
+		}
+	}
+	return "Custom command"
+}
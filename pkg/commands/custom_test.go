@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCustomCommands(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "review.md"), []byte("---\ndescription: Review changes\n---\nReview $ARGUMENTS carefully.\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "plain.md"), []byte("# Explain the build\nExplain how the build works.\n"), 0644)
+
+	cmds, err := LoadCustomCommands(dir, "project")
+	if err != nil {
+		t.Fatalf("LoadCustomCommands failed: %v", err)
+	}
+	if len(cmds) != 2 {
+		t.Fatalf("Expected 2 commands, got %d", len(cmds))
+	}
+
+	byName := map[string]*CustomCommand{}
+	for _, c := range cmds {
+		byName[c.Name()] = c
+	}
+
+	review := byName["review"]
+	if review == nil || review.Description() != "Review changes (project)" {
+		t.Fatalf("Unexpected review command: %+v", review)
+	}
+	msg, instructions, _ := review.ExecuteArgs("main.go")
+	if instructions != "Review main.go carefully." || !strings.Contains(msg, "/review") {
+		t.Errorf("Unexpected expansion: %q / %q", msg, instructions)
+	}
+
+	if plain := byName["plain"]; plain == nil || plain.Description() != "Explain the build (project)" {
+		t.Errorf("Expected description from first line, got %+v", plain)
+	}
+
+	if cmds, err := LoadCustomCommands(filepath.Join(dir, "missing"), "user"); err != nil || len(cmds) != 0 {
+		t.Errorf("Expected no commands for missing dir, got %v (%v)", cmds, err)
+	}
+}
+
+func TestCustomCommandArguments(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "fix.md"), []byte("---\narguments: issue, file?, focus=correctness\n---\nFix $issue in ${file} with a focus on $focus. Keep $HOME and $ARGUMENTS.\n"), 0644)
+	cmds, err := LoadCustomCommands(dir, "user")
+	if err != nil || len(cmds) != 1 {
+		t.Fatalf("LoadCustomCommands: %v, %v", cmds, err)
+	}
+	fix := cmds[0]
+	if !strings.HasPrefix(fix.Description(), "/fix <issue> [file] [focus]: ") {
+		t.Errorf("Description = %q", fix.Description())
+	}
+
+	_, instructions, err := fix.ExecuteArgs(`42 "cmd/my app.go"`)
+	if err != nil || instructions != `Fix 42 in cmd/my app.go with a focus on correctness. Keep $HOME and 42 "cmd/my app.go".` {
+		t.Errorf("expansion %q, %v", instructions, err)
+	}
+	for args, want := range map[string]string{
+		"":              "missing argument issue; usage: /fix <issue> [file] [focus]",
+		"1 a b c":       "too many arguments",
+		`1 "unfinished`: "unterminated",
+	} {
+		if _, _, err := fix.ExecuteArgs(args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ExecuteArgs(%q) err = %v, want %q", args, err, want)
+		}
+	}
+
+	for _, decl := range []string{"file?, issue", "a, a", "bad-name"} {
+		os.WriteFile(filepath.Join(dir, "fix.md"), []byte("---\narguments: "+decl+"\n---\nx\n"), 0644)
+		if _, err := LoadCustomCommands(dir, "user"); err == nil {
+			t.Errorf("arguments %q accepted", decl)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package commands
 
+import "github.com/jbdamask/john-code/pkg/llm"
+
 // InitCommand creates an AGENTS.md file following the agents.md standard
 type InitCommand struct{}
 
@@ -42,6 +44,12 @@ This file provides guidance to John Code (and other AI coding agents) when worki
 	return commandMessage, instructions, nil
 }
 
+// ToolChoice makes the first response a tool call, so the analysis starts
+// from the repository instead of from what the model guesses is in it
+func (c *InitCommand) ToolChoice() llm.ToolChoice {
+	return llm.ToolChoice{Mode: llm.ToolChoiceAny}
+}
+
 // NewInitCommand creates a new InitCommand
 func NewInitCommand() *InitCommand {
 	return &InitCommand{}
@@ -45,8 +45,15 @@ func (c *MCPCommand) Execute() (commandMessage string, instructions string, err
 
 	for _, server := range servers {
 		status := "❌ disconnected"
-		if server.Connected {
+		switch server.State {
+		case "connected":
 			status = fmt.Sprintf("✓ connected (%d tools)", server.ToolCount)
+		case "connecting":
+			status = "… connecting"
+		case "lazy":
+			status = fmt.Sprintf("◌ starts on first use (%d tools)", server.ToolCount)
+		case "failed":
+			status = "❌ failed: " + server.Error
 		}
 		sb.WriteString(fmt.Sprintf("- **%s**: %s\n", server.Name, status))
 	}
@@ -55,6 +62,7 @@ func (c *MCPCommand) Execute() (commandMessage string, instructions string, err
 	sb.WriteString("- `john mcp add <name> <command> [args...]` - Add a server\n")
 	sb.WriteString("- `john mcp remove <name>` - Remove a server\n")
 	sb.WriteString("- `john mcp list` - List all servers\n")
+	sb.WriteString("\nTo inspect, disable or try out individual tools, use `/mcp tools`.\n")
 
 	return "<command-message>Showing MCP server status</command-message>",
 		sb.String(),
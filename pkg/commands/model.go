@@ -25,7 +25,7 @@ func (c *ModelCommand) Name() string {
 
 // Description returns a short description shown in the command picker
 func (c *ModelCommand) Description() string {
-	return "Switch LLM model"
+	return "Switch LLM model (or /model <name>: sonnet, opus, haiku, default, fast...)"
 }
 
 // Execute is not used for model command - it uses interactive picker instead
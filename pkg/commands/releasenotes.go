@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReleaseNotesCommand drafts categorized release notes from the commits and
+// changes between two git refs
+type ReleaseNotesCommand struct {
+	gather func(from, to string) (string, error)
+}
+
+// NewReleaseNotesCommand creates a new ReleaseNotesCommand. gather returns
+// the commit log and notable diffs of from..to.
+func NewReleaseNotesCommand(gather func(from, to string) (string, error)) *ReleaseNotesCommand {
+	return &ReleaseNotesCommand{gather: gather}
+}
+
+// Name returns the command name
+func (c *ReleaseNotesCommand) Name() string {
+	return "release-notes"
+}
+
+// Description returns a short description shown in the command picker
+func (c *ReleaseNotesCommand) Description() string {
+	return "Draft release notes for <from>..<to> (--write adds them to CHANGELOG.md)"
+}
+
+// Execute runs the command without arguments
+func (c *ReleaseNotesCommand) Execute() (commandMessage string, instructions string, err error) {
+	return c.ExecuteArgs("")
+}
+
+// ExecuteArgs gathers the changes of the typed range and asks for notes
+func (c *ReleaseNotesCommand) ExecuteArgs(args string) (commandMessage string, instructions string, err error) {
+	from, to, write, err := parseReleaseRange(args)
+	if err != nil {
+		return "", "", err
+	}
+	changes, err := c.gather(from, to)
+	if err != nil {
+		return "", "", err
+	}
+
+	commandMessage = fmt.Sprintf("<command-message>release-notes is summarizing %s..%s…</command-message>\n<command-name>/release-notes</command-name>\n<command-args>%s</command-args>", from, to, args)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Write release notes for the changes from %s to %s, from the commits and diffs below.\n\n", from, to))
+	sb.WriteString(`Group the entries under these headings, leaving out empty ones:
+- Breaking changes (anything that needs users to change code, configuration or commands)
+- Features
+- Fixes
+- Performance
+- Documentation
+- Internal (refactoring, tests, build and CI)
+
+Each entry is one line written for users of the project, not a copy of the commit subject, ending with the short commit hashes it covers in parentheses. Merge commits that belong to the same change. Use the diffs to check what a commit really changed when its message is vague, and do not invent changes that are not shown.
+`)
+	if write {
+		sb.WriteString(fmt.Sprintf(`
+Then add the notes to CHANGELOG.md under a "## %s" heading, above the previous release and below any title or preamble, matching the format of the existing entries. Use Edit if the file exists and Write if it does not, so the change goes through the usual approval. Do not change other entries.
+`, to))
+	} else {
+		sb.WriteString("\nShow the notes as Markdown under a \"## " + to + "\" heading. Do not change any files.\n")
+	}
+	sb.WriteString("\n" + changes)
+	return commandMessage, sb.String(), nil
+}
+
+// parseReleaseRange reads "<from>..<to> [--write]". A missing <to> is HEAD.
+func parseReleaseRange(args string) (from, to string, write bool, err error) {
+	var spec string
+	for _, field := range strings.Fields(args) {
+		switch {
+		case field == "--write":
+			write = true
+		case strings.HasPrefix(field, "-"):
+			return "", "", false, fmt.Errorf("unknown option %s", field)
+		case spec != "":
+			return "", "", false, fmt.Errorf("usage: /release-notes <from>..<to> [--write]")
+		default:
+			spec = field
+		}
+	}
+	from, to, _ = strings.Cut(spec, "..")
+	to = strings.TrimPrefix(to, ".") // Three dots mean the same here
+	if from == "" {
+		return "", "", false, fmt.Errorf("usage: /release-notes <from>..<to> [--write], e.g. /release-notes v1.2.0..v1.3.0")
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	return from, to, write, nil
+}
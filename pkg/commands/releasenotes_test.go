@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseNotesCommand(t *testing.T) {
+	var gathered []string
+	cmd := NewReleaseNotesCommand(func(from, to string) (string, error) {
+		gathered = append(gathered, from+" "+to)
+		return "<commits>\ncommit abc123\n\nFix crash on empty input\n</commits>\n", nil
+	})
+
+	_, instructions, err := cmd.ExecuteArgs("v1.2.0..v1.3.0")
+	if err != nil {
+		t.Fatalf("ExecuteArgs: %v", err)
+	}
+	if !strings.Contains(instructions, "## v1.3.0") || !strings.Contains(instructions, "commit abc123") || strings.Contains(instructions, "CHANGELOG.md") {
+		t.Errorf("unexpected instructions:\n%s", instructions)
+	}
+
+	_, instructions, _ = cmd.ExecuteArgs("--write v1.2.0...")
+	if !strings.Contains(instructions, "add the notes to CHANGELOG.md") || !strings.Contains(instructions, "## HEAD") {
+		t.Errorf("--write instructions:\n%s", instructions)
+	}
+	if want := []string{"v1.2.0 v1.3.0", "v1.2.0 HEAD"}; strings.Join(gathered, ",") != strings.Join(want, ",") {
+		t.Errorf("gathered %q, want %q", gathered, want)
+	}
+
+	for _, args := range []string{"", "..v1.3.0", "a..b c..d", "v1..v2 --force"} {
+		if _, _, err := cmd.ExecuteArgs(args); err == nil {
+			t.Errorf("ExecuteArgs(%q) succeeded", args)
+		}
+	}
+}
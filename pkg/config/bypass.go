@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SkipPermissionsAcceptEnv records the acceptance of
+// --dangerously-skip-permissions without asking, for CI images whose home
+// directory starts empty on every run
+const SkipPermissionsAcceptEnv = "JOHN_ACCEPT_SKIP_PERMISSIONS"
+
+// AllowRootEnv lets --dangerously-skip-permissions run as root outside a
+// container
+const AllowRootEnv = "JOHN_ALLOW_ROOT"
+
+// skipPermissionsFile returns ~/.config/john-code/skip-permissions-accepted
+func skipPermissionsFile() (string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "skip-permissions-accepted"), nil
+}
+
+// SkipPermissionsAccepted reports whether the user has confirmed once that
+// they understand what --dangerously-skip-permissions turns off
+func SkipPermissionsAccepted() bool {
+	path, err := skipPermissionsFile()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// AcceptSkipPermissions records that confirmation, with its time
+func AcceptSkipPermissions() error {
+	path, err := skipPermissionsFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644)
+}
+
+// InContainer reports whether john runs inside a Docker, Podman or
+// Kubernetes container, where a root user cannot harm the host
+func InContainer() bool {
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	if os.Getenv("container") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod", "lxc"} {
+		if strings.Contains(string(data), runtime) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,20 +6,72 @@ import (
 )
 
 type Config struct {
-    APIKey  string
-    BaseURL string
+    APIKey   string
+    BaseURL  string
+    Settings *Settings
+
+    // Deterministic pins sampling and hashes each request so CI runs are as
+    // reproducible as providers allow. Prompt injections that vary between
+    // runs, such as timestamps or git status, must be skipped when it is set.
+    Deterministic bool
+
+    // Ephemeral keeps the session off disk, like privacy.ephemeral (--ephemeral)
+    Ephemeral bool
+
+    // SafeMode starts with default settings and without project commands,
+    // agents, memory, exec tools or MCP servers (--safe-mode), so a broken
+    // file cannot keep john from starting
+    SafeMode bool
+
+    // SkipPermissions runs every tool call that deny rules and the policy do
+    // not block, without asking (--dangerously-skip-permissions)
+    SkipPermissions bool
 }
 
+// ErrNoAPIKey is returned by Load when ANTHROPIC_API_KEY is not set and
+// there is no saved Anthropic login
+var ErrNoAPIKey = fmt.Errorf("ANTHROPIC_API_KEY environment variable is not set; set it or run john login anthropic")
+
 func Load() (*Config, error) {
+    return load(LoadSettings)
+}
+
+// LoadSafe is Load for --safe-mode: only the managed settings file is read,
+// as an organization's policy applies in safe mode too
+func LoadSafe() (*Config, error) {
+    cfg, err := load(LoadManagedSettings)
+    if err != nil {
+        return nil, err
+    }
+    cfg.SafeMode = true
+    cfg.Ephemeral = true
+    return cfg, nil
+}
+
+func load(loadSettings func() (*Settings, error)) (*Config, error) {
     apiKey := os.Getenv("ANTHROPIC_API_KEY")
     if apiKey == "" {
-        return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable is not set")
+        if _, ok := LoadCredential("anthropic"); !ok {
+            return nil, ErrNoAPIKey
+        }
     }
     
     baseURL := os.Getenv("ANTHROPIC_BASE_URL")
 
+    settings, err := loadSettings()
+    if err != nil {
+        return nil, err
+    }
+    if err := ConfigureHTTP(settings); err != nil {
+        return nil, fmt.Errorf("invalid network settings: %w", err)
+    }
+    if settings.Network.InsecureSkipVerify {
+        fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled (network.insecureSkipVerify)")
+    }
+
 	return &Config{
-        APIKey:  apiKey,
-        BaseURL: baseURL,
+        APIKey:   apiKey,
+        BaseURL:  baseURL,
+        Settings: settings,
     }, nil
 }
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Credential is an OAuth token set saved by john login for a provider
+type Credential struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // Zero when the token does not expire
+	Scope        string    `json:"scope,omitempty"`
+}
+
+// Expired reports whether the access token expires within margin
+func (c Credential) Expired(margin time.Duration) bool {
+	return !c.Expiry.IsZero() && time.Now().Add(margin).After(c.Expiry)
+}
+
+// CredentialsFilePath returns ~/.config/john-code/credentials.json. It is
+// written readable by the user only, as it holds refresh tokens.
+func CredentialsFilePath() (string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "credentials.json"), nil
+}
+
+func loadCredentials() (map[string]Credential, string, error) {
+	path, err := CredentialsFilePath()
+	if err != nil {
+		return nil, "", err
+	}
+	creds := make(map[string]Credential)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return creds, path, nil
+		}
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return creds, path, nil
+}
+
+// LoadCredential returns the saved credential for a provider, if any
+func LoadCredential(provider string) (Credential, bool) {
+	creds, _, err := loadCredentials()
+	if err != nil {
+		return Credential{}, false
+	}
+	cred, ok := creds[provider]
+	return cred, ok && cred.AccessToken != ""
+}
+
+// SaveCredential stores a provider's credential, or removes it when cred is
+// nil
+func SaveCredential(provider string, cred *Credential) error {
+	path, err := CredentialsFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return WithFileLock(path, func() error {
+		creds, path, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		if cred == nil {
+			delete(creds, provider)
+		} else {
+			creds[provider] = *cred
+		}
+		data, err := json.MarshalIndent(creds, "", "  ")
+		if err != nil {
+			return err
+		}
+		return WriteFileAtomic(path, data, 0600)
+	})
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	transportMu sync.RWMutex
+	transport   = http.DefaultTransport.(*http.Transport)
+	providers   map[string]ProviderSettings
+	policy      PolicySettings
+)
+
+// ConfigureHTTP builds the transport shared by every outbound client (LLM
+// providers, WebFetch, WebSearch) from the network settings and records the
+// per-provider limits. It must be called before clients are created; until
+// then they use http.DefaultTransport and the default provider limits.
+func ConfigureHTTP(settings *Settings) error {
+	t, err := newTransport(settings.Network)
+	if err != nil {
+		return err
+	}
+	transportMu.Lock()
+	transport = t
+	providers = settings.Providers
+	policy = settings.Policy
+	transportMu.Unlock()
+	return nil
+}
+
+// NewHTTPClient returns a client using the configured proxy and TLS settings.
+// A zero timeout means no timeout, as with http.Client.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return &http.Client{Transport: withPolicy(transport), Timeout: timeout}
+}
+
+// NewProviderHTTPClient returns a client for an LLM provider, with the
+// provider's timeouts, connection pool and size limits applied on top of the
+// proxy and TLS settings.
+func NewProviderHTTPClient(provider string) *http.Client {
+	transportMu.RLock()
+	limits := providers[provider].withDefaults()
+	t := transport.Clone()
+	blocked := withPolicy(t)
+	transportMu.RUnlock()
+
+	dialer := &net.Dialer{Timeout: time.Duration(limits.ConnectTimeout), KeepAlive: 30 * time.Second}
+	t.DialContext = dialer.DialContext
+	t.TLSHandshakeTimeout = time.Duration(limits.ConnectTimeout)
+	t.ResponseHeaderTimeout = time.Duration(limits.ResponseHeaderTimeout)
+	t.MaxIdleConns = limits.MaxIdleConns
+	t.MaxIdleConnsPerHost = limits.MaxIdleConns
+	t.IdleConnTimeout = time.Duration(limits.IdleConnTimeout)
+
+	return &http.Client{
+		Transport: &limitTransport{base: blocked, provider: provider, limits: limits},
+		Timeout:   time.Duration(limits.Timeout),
+	}
+}
+
+// withPolicy wraps base to refuse the hosts the policy blocks. The caller
+// holds transportMu.
+func withPolicy(base http.RoundTripper) http.RoundTripper {
+	if len(policy.BlockedDomains) == 0 {
+		return base
+	}
+	return &policyTransport{base: base, policy: policy}
+}
+
+// policyTransport refuses requests to the domains a policy blocks, for every
+// client john makes: providers, WebFetch, WebSearch, webhooks and Slack
+type policyTransport struct {
+	base   http.RoundTripper
+	policy PolicySettings
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.policy.BlocksHost(req.URL.Hostname()) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("requests to %s are blocked by your organization's policy", req.URL.Hostname())
+	}
+	return t.base.RoundTrip(req)
+}
+
+// limitTransport rejects oversized requests and cuts off oversized responses.
+type limitTransport struct {
+	base     http.RoundTripper
+	provider string
+	limits   ProviderSettings
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.ContentLength > t.limits.MaxRequestBytes {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%s request is %d bytes, over the %d byte limit (providers.%s.maxRequestBytes in settings)",
+			t.provider, req.ContentLength, t.limits.MaxRequestBytes, t.provider)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.limits.MaxResponseBytes, transport: t}
+	return resp, nil
+}
+
+// limitedBody fails the read that crosses the response size limit
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	transport *limitTransport
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// A body of exactly the limit is fine; only fail if more data follows
+		var probe [1]byte
+		if n, err := b.ReadCloser.Read(probe[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%s response exceeded the %d byte limit (providers.%s.maxResponseBytes in settings)",
+			b.transport.provider, b.transport.limits.MaxResponseBytes, b.transport.provider)
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func newTransport(n NetworkSettings) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc(n)
+
+	if n.CACertFile != "" || n.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: n.InsecureSkipVerify}
+		if n.CACertFile != "" {
+			pem, err := os.ReadFile(n.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA bundle %s", n.CACertFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+	return t, nil
+}
+
+// proxyFunc resolves the proxy for a request. Settings take precedence over
+// the environment, field by field.
+func proxyFunc(n NetworkSettings) func(*http.Request) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if n.HTTPProxy != "" {
+		cfg.HTTPProxy = n.HTTPProxy
+	}
+	if n.HTTPSProxy != "" {
+		cfg.HTTPSProxy = n.HTTPSProxy
+	}
+	if n.NoProxy != "" {
+		cfg.NoProxy = n.NoProxy
+	}
+	resolve := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return resolve(req.URL)
+	}
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyFuncSettingsOverrideEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:8080")
+	t.Setenv("NO_PROXY", "")
+
+	proxy := proxyFunc(NetworkSettings{HTTPSProxy: "http://settings-proxy:3128", NoProxy: "internal.example.com"})
+
+	req, _ := http.NewRequest("GET", "https://api.anthropic.com/v1/messages", nil)
+	u, err := proxy(req)
+	if err != nil || u == nil || u.Host != "settings-proxy:3128" {
+		t.Errorf("Expected settings proxy, got %v (%v)", u, err)
+	}
+
+	req, _ = http.NewRequest("GET", "https://internal.example.com/", nil)
+	if u, _ := proxy(req); u != nil {
+		t.Errorf("Expected NO_PROXY host to bypass the proxy, got %v", u)
+	}
+}
+
+func TestNewTransportCABundle(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	os.WriteFile(bad, []byte("not a certificate"), 0644)
+
+	if _, err := newTransport(NetworkSettings{CACertFile: bad}); err == nil || !strings.Contains(err.Error(), "no certificates") {
+		t.Errorf("Expected error for invalid CA bundle, got %v", err)
+	}
+
+	tr, err := newTransport(NetworkSettings{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Error("Expected InsecureSkipVerify to be set")
+	}
+}
+
+func TestLoadSettingsProjectOverridesUser(t *testing.T) {
+	home := t.TempDir()
+	project := t.TempDir()
+	t.Setenv("HOME", home)
+	wd, _ := os.Getwd()
+	os.Chdir(project)
+	defer os.Chdir(wd)
+
+	os.MkdirAll(filepath.Join(home, ".config", "john-code"), 0755)
+	os.WriteFile(filepath.Join(home, ".config", "john-code", "settings.json"),
+		[]byte(`{"network": {"httpsProxy": "http://user:1", "caCertFile": "/etc/ca.pem"}}`), 0644)
+	os.MkdirAll(filepath.Join(project, ".john"), 0755)
+	os.WriteFile(filepath.Join(project, ".john", "settings.json"),
+		[]byte(`{"network": {"httpsProxy": "http://project:2"}}`), 0644)
+
+	// Project settings are ignored until the workspace is trusted
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if s.Network.HTTPSProxy != "http://user:1" {
+		t.Errorf("Expected project settings to be skipped, got %+v", s.Network)
+	}
+
+	if err := SetWorkspaceTrust(project, true); err != nil {
+		t.Fatalf("SetWorkspaceTrust failed: %v", err)
+	}
+	s, err = LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if s.Network.HTTPSProxy != "http://project:2" || s.Network.CACertFile != "/etc/ca.pem" {
+		t.Errorf("Unexpected merged settings: %+v", s.Network)
+	}
+}
+
+func TestProviderHTTPClientLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	if err := ConfigureHTTP(&Settings{Providers: map[string]ProviderSettings{
+		"test": {MaxRequestBytes: 10, MaxResponseBytes: 50},
+	}}); err != nil {
+		t.Fatalf("ConfigureHTTP failed: %v", err)
+	}
+	defer ConfigureHTTP(&Settings{})
+	client := NewProviderHTTPClient("test")
+
+	if client.Timeout != time.Duration(DefaultProviderSettings.Timeout) {
+		t.Errorf("Expected default timeout, got %v", client.Timeout)
+	}
+
+	_, err := client.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("y", 20)))
+	if err == nil || !strings.Contains(err.Error(), "maxRequestBytes") {
+		t.Errorf("Expected request size error, got %v", err)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil || !strings.Contains(err.Error(), "maxResponseBytes") {
+		t.Errorf("Expected response size error, got %v", err)
+	}
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	var p ProviderSettings
+	if err := json.Unmarshal([]byte(`{"connectTimeout": "15s", "timeout": 90}`), &p); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if time.Duration(p.ConnectTimeout) != 15*time.Second || time.Duration(p.Timeout) != 90*time.Second {
+		t.Errorf("Unexpected durations: %+v", p)
+	}
+	if err := json.Unmarshal([]byte(`{"timeout": "soon"}`), &p); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+}
+
+func TestSaveProjectSettingsKeepsOtherKeys(t *testing.T) {
+	home := t.TempDir()
+	project := t.TempDir()
+	t.Setenv("HOME", home)
+	wd, _ := os.Getwd()
+	os.Chdir(project)
+	defer os.Chdir(wd)
+
+	os.MkdirAll(filepath.Join(project, ".john"), 0755)
+	os.WriteFile(filepath.Join(project, ".john", "settings.json"),
+		[]byte(`{"permissions": {"deny": ["Read(.env)"]}}`), 0644)
+	SetWorkspaceTrust(project, true)
+
+	if _, err := SaveProjectSettings(ProjectSettings{BuildCommand: "make", TestCommand: "make test"}); err != nil {
+		t.Fatalf("SaveProjectSettings failed: %v", err)
+	}
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if s.Project.BuildCommand != "make" || s.Project.TestCommand != "make test" {
+		t.Errorf("Unexpected project settings: %+v", s.Project)
+	}
+	if len(s.Permissions.Deny) != 1 {
+		t.Errorf("Expected existing permissions to be kept, got %+v", s.Permissions)
+	}
+}
+
+func TestURLFetchAllows(t *testing.T) {
+	u := URLFetchSettings{Domains: []string{"go.dev", "*.python.org"}}
+	for host, want := range map[string]bool{
+		"go.dev":          true,
+		"pkg.go.dev":      true,
+		"notgo.dev":       false,
+		"docs.python.org": true,
+		"python.org":      true,
+		"example.com":     false,
+	} {
+		if got := u.Allows(host); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if !(URLFetchSettings{Domains: []string{"*"}}).Allows("example.com") {
+		t.Error(`Expected "*" to allow any host`)
+	}
+	if (URLFetchSettings{}).Allows("example.com") {
+		t.Error("Expected an empty list to allow nothing")
+	}
+}
+
+func TestHyperlinkTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		settings HyperlinkSettings
+		want     string
+		wantErr  bool
+	}{
+		{HyperlinkSettings{}, "file://{path}", false},
+		{HyperlinkSettings{Scheme: "none"}, "", false},
+		{HyperlinkSettings{Scheme: "cursor"}, "cursor://file{path}:{line}:{column}", false},
+		{HyperlinkSettings{Scheme: "vscode", URL: "myeditor://{path}#{line}"}, "myeditor://{path}#{line}", false},
+		{HyperlinkSettings{URL: "myeditor://open"}, "", true},
+		{HyperlinkSettings{Scheme: "notepad"}, "", true},
+	} {
+		got, err := tc.settings.Template()
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("Template(%+v) = %q, %v; want %q (error %v)", tc.settings, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestManagedSettingsPolicy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".config", "john-code"), 0755)
+	os.WriteFile(filepath.Join(home, ".config", "john-code", "settings.json"),
+		[]byte(`{"language": "es", "policy": {"permissionMode": ""}, "permissions": {"allow": ["Bash(curl:*)"]}}`), 0644)
+	managed := filepath.Join(t.TempDir(), "managed-settings.json")
+	os.WriteFile(managed, []byte(`{
+		"language": "fr",
+		"permissions": {"deny": ["Bash(curl:*)"]},
+		"policy": {
+			"disallowedTools": ["WebSearch", "mcp__*"],
+			"blockedDomains": ["example.com"],
+			"permissionMode": "readOnly",
+			"allowedProviders": ["anthropic"]
+		}
+	}`), 0644)
+	defer func(path string) { managedSettingsPath = path }(managedSettingsPath)
+	managedSettingsPath = managed
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if s.Language != "fr" {
+		t.Errorf("Expected the managed language to win, got %q", s.Language)
+	}
+	if s.Policy.PermissionMode != PermissionModeReadOnly {
+		t.Errorf("Expected the managed permission mode, got %q", s.Policy.PermissionMode)
+	}
+	if d, _ := s.Permissions.Check("Bash", map[string]interface{}{"command": "curl x"}); d != DecisionDeny {
+		t.Errorf("Expected the managed deny rule to beat the user allow rule, got %v", d)
+	}
+	if s.Policy.AllowsTool("WebSearch") || s.Policy.AllowsTool("mcp__github__list") || !s.Policy.AllowsTool("Read") {
+		t.Errorf("Unexpected tool policy: %+v", s.Policy.DisallowedTools)
+	}
+	if s.Policy.AllowsProvider("openai") || !s.Policy.AllowsProvider("anthropic") {
+		t.Errorf("Unexpected provider policy: %+v", s.Policy.AllowedProviders)
+	}
+
+	// Only the managed file sets a policy
+	os.WriteFile(managed, []byte(`{}`), 0644)
+	os.WriteFile(filepath.Join(home, ".config", "john-code", "settings.json"),
+		[]byte(`{"policy": {"permissionMode": "readOnly"}}`), 0644)
+	if s, _ := LoadSettings(); s.Policy.PermissionMode != "" {
+		t.Errorf("Expected a policy in user settings to be ignored, got %+v", s.Policy)
+	}
+}
+
+func TestPolicyBlocksDomains(t *testing.T) {
+	if err := ConfigureHTTP(&Settings{Policy: PolicySettings{BlockedDomains: []string{"example.com"}}}); err != nil {
+		t.Fatalf("ConfigureHTTP failed: %v", err)
+	}
+	defer ConfigureHTTP(&Settings{})
+
+	for _, client := range []*http.Client{NewHTTPClient(time.Second), NewProviderHTTPClient("test")} {
+		_, err := client.Get("https://api.example.com/v1")
+		if err == nil || !strings.Contains(err.Error(), "blocked by your organization's policy") {
+			t.Errorf("Expected a policy error, got %v", err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	resp, err := NewHTTPClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected other hosts to be allowed, got %v", err)
+	}
+	resp.Body.Close()
+}
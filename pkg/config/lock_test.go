@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithFileLockSerializesUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	os.WriteFile(path, []byte("0"), 0644)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := WithFileLock(path, func() error {
+				data, _ := os.ReadFile(path)
+				n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+				return WriteFileAtomic(path, []byte(fmt.Sprint(n+1)), 0644)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "20" {
+		t.Errorf("Expected 20 serialized increments, got %s", data)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected the lock file to be removed")
+	}
+}
+
+func TestWithFileLockBreaksStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	os.WriteFile(path+".lock", []byte("99999\n"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path+".lock", old, old)
+
+	ran := false
+	if err := WithFileLock(path, func() error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("Expected a stale lock to be broken, got %v", err)
+	}
+}
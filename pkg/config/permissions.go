@@ -0,0 +1,138 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Permissions lists rules that decide whether a tool call may run. A rule is
+// a tool name ("WebSearch") or a tool name with a specifier:
+//
+//	Bash(npm test)        the exact command
+//	Bash(git push:*)      any command starting with "git push"
+//	Read(.env)            a file path, relative to the working directory
+//	Edit(src/**)          any file under src/
+//	WebFetch(domain:example.com)
+//
+// Deny wins over ask, and ask over allow. Calls that match no rule are allowed.
+//
+// Commands that publish or deploy, such as npm publish or terraform apply,
+// are confirmed even when an allow rule matches them. Publish changes that:
+// "allow" runs them like any other command, for CI, and "deny" refuses them.
+type Permissions struct {
+	Allow   []string `json:"allow,omitempty"`
+	Ask     []string `json:"ask,omitempty"`
+	Deny    []string `json:"deny,omitempty"`
+	Publish string   `json:"publish,omitempty"` // "ask" (default), "allow" or "deny"
+}
+
+// Decision is the outcome of checking a tool call against the rules
+type Decision int
+
+const (
+	DecisionAllow Decision = iota
+	DecisionAsk
+	DecisionDeny
+)
+
+// Check returns the decision for a tool call and the rule that produced it
+// ("" when no rule matched).
+func (p Permissions) Check(tool string, args map[string]interface{}) (Decision, string) {
+	for _, group := range []struct {
+		rules    []string
+		decision Decision
+	}{{p.Deny, DecisionDeny}, {p.Ask, DecisionAsk}, {p.Allow, DecisionAllow}} {
+		for _, rule := range group.rules {
+			if ruleMatches(rule, tool, args) {
+				return group.decision, rule
+			}
+		}
+	}
+	return DecisionAllow, ""
+}
+
+// PublishDecision is the decision for a command that publishes or deploys
+func (p Permissions) PublishDecision() Decision {
+	switch p.Publish {
+	case "allow":
+		return DecisionAllow
+	case "deny":
+		return DecisionDeny
+	}
+	return DecisionAsk
+}
+
+// fileArgs names the argument holding the path for file tools
+var fileArgs = map[string]string{
+	"Read":         "file_path",
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+func ruleMatches(rule, tool string, args map[string]interface{}) bool {
+	name, spec, hasSpec := strings.Cut(rule, "(")
+	if name != tool {
+		return false
+	}
+	if !hasSpec {
+		return true
+	}
+	spec = strings.TrimSuffix(spec, ")")
+
+	switch {
+	case tool == "Bash":
+		command, _ := args["command"].(string)
+		command = strings.TrimSpace(command)
+		if prefix, ok := strings.CutSuffix(spec, ":*"); ok {
+			return command == prefix || strings.HasPrefix(command, prefix+" ")
+		}
+		return command == spec
+	case fileArgs[tool] != "":
+		path, _ := args[fileArgs[tool]].(string)
+		return pathMatches(spec, path)
+	case tool == "WebFetch":
+		rawURL, _ := args["url"].(string)
+		domain, ok := strings.CutPrefix(spec, "domain:")
+		if !ok {
+			return false
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return false
+		}
+		host := u.Hostname()
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+	return false
+}
+
+// pathMatches matches a path against a glob relative to the working
+// directory. A trailing "/**" matches everything below a directory.
+func pathMatches(pattern, path string) bool {
+	if path == "" {
+		return false
+	}
+	if cwd, err := os.Getwd(); err == nil && filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	pattern = strings.TrimPrefix(pattern, "./")
+
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return path == dir || strings.HasPrefix(path, dir+"/")
+	}
+	if ok, _ := filepath.Match(pattern, path); ok {
+		return true
+	}
+	// Patterns without a directory also match by file name anywhere
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, filepath.Base(path))
+		return ok
+	}
+	return false
+}
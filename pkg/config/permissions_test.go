@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestPermissionsCheck(t *testing.T) {
+	p := Permissions{
+		Allow: []string{"Bash(npm test)", "WebFetch(domain:go.dev)"},
+		Ask:   []string{"Bash(git push:*)", "Edit(src/**)"},
+		Deny:  []string{"Read(.env)", "Bash(git push --force:*)"},
+	}
+
+	tests := []struct {
+		tool string
+		args map[string]interface{}
+		want Decision
+	}{
+		{"Bash", map[string]interface{}{"command": "npm test"}, DecisionAllow},
+		{"Bash", map[string]interface{}{"command": "git push origin main"}, DecisionAsk},
+		{"Bash", map[string]interface{}{"command": "git push --force origin main"}, DecisionDeny},
+		{"Bash", map[string]interface{}{"command": "git pushx"}, DecisionAllow},
+		{"Read", map[string]interface{}{"file_path": "config/.env"}, DecisionDeny},
+		{"Read", map[string]interface{}{"file_path": "README.md"}, DecisionAllow},
+		{"Edit", map[string]interface{}{"file_path": "src/app/main.go"}, DecisionAsk},
+		{"Edit", map[string]interface{}{"file_path": "docs/src/x.md"}, DecisionAllow},
+		{"WebFetch", map[string]interface{}{"url": "https://pkg.go.dev/net/http"}, DecisionAllow},
+	}
+
+	for _, tt := range tests {
+		if got, rule := p.Check(tt.tool, tt.args); got != tt.want {
+			t.Errorf("Check(%s, %v) = %v (rule %q), want %v", tt.tool, tt.args, got, rule, tt.want)
+		}
+	}
+}
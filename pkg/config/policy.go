@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// managedSettingsPath replaces the location of the managed settings file in
+// tests. Nothing a user controls can move it, or the policy could be escaped.
+var managedSettingsPath string
+
+// ManagedSettingsPath returns the machine-wide settings file an organization
+// installs for everyone on the machine. It is read after the user and project
+// files, so the values it sets win, and only it can set a policy.
+func ManagedSettingsPath() string {
+	if managedSettingsPath != "" {
+		return managedSettingsPath
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return "/Library/Application Support/JohnCode/managed-settings.json"
+	case "windows":
+		dir := os.Getenv("ProgramData")
+		if dir == "" {
+			dir = `C:\ProgramData`
+		}
+		return filepath.Join(dir, "JohnCode", "managed-settings.json")
+	}
+	return "/etc/john-code/managed-settings.json"
+}
+
+// LoadManagedSettings reads only the managed settings file, for safe mode
+func LoadManagedSettings() (*Settings, error) {
+	settings := &Settings{}
+	if err := mergeSettingsFile(settings, ManagedSettingsPath()); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Permission modes a policy can force
+const (
+	PermissionModeReadOnly = "readOnly" // Every workspace is read-only, trusted or not
+	PermissionModeAsk      = "ask"      // Every tool that can change something asks first
+)
+
+// PolicySettings are an organization's restrictions, read only from the
+// managed settings file so that user and project settings cannot loosen them
+type PolicySettings struct {
+	DisallowedTools  []string `json:"disallowedTools,omitempty"`  // Tool names or patterns such as "mcp__*"; hidden from the model
+	BlockedDomains   []string `json:"blockedDomains,omitempty"`   // No HTTP requests to these hosts or their subdomains
+	PermissionMode   string   `json:"permissionMode,omitempty"`   // "readOnly" or "ask"; empty leaves it to trust and permission rules
+	AllowedProviders []string `json:"allowedProviders,omitempty"` // "anthropic", "openai", "google"; empty allows all
+
+	DisableSkipPermissions bool `json:"disableSkipPermissions,omitempty"` // Refuse --dangerously-skip-permissions
+}
+
+// AllowsTool reports whether the policy lets the model use a tool
+func (p PolicySettings) AllowsTool(name string) bool {
+	for _, pattern := range p.DisallowedTools {
+		if ok, _ := path.Match(pattern, name); ok || pattern == name {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsProvider reports whether the policy allows a provider's models
+func (p PolicySettings) AllowsProvider(provider string) bool {
+	if len(p.AllowedProviders) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedProviders {
+		if allowed == "gemini" {
+			allowed = "google"
+		}
+		if strings.EqualFold(allowed, provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlocksHost reports whether the policy blocks requests to host
+func (p PolicySettings) BlocksHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range p.BlockedDomains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "*."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,646 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Settings holds user preferences read from settings.json. The user file is
+// read first and the project file is applied on top of it, so a project only
+// needs to list the values it overrides.
+type Settings struct {
+	Network   NetworkSettings             `json:"network,omitempty"`
+	Providers map[string]ProviderSettings `json:"providers,omitempty"` // Keyed by "anthropic", "openai", "gemini"
+
+	ServerTools ServerToolSettings          `json:"serverTools,omitempty"`
+	Permissions Permissions                 `json:"permissions,omitempty"`
+	GitHooks    GitHookSettings             `json:"gitHooks,omitempty"`
+	Models      ModelSettings               `json:"models,omitempty"`
+	Watchdog    WatchdogSettings            `json:"watchdog,omitempty"`
+	Project     ProjectSettings             `json:"project,omitempty"`
+	URLFetch    URLFetchSettings            `json:"urlFetch,omitempty"`
+	ToolResults ToolResultSettings          `json:"toolResults,omitempty"`
+	Files       FileSettings                `json:"files,omitempty"`
+	ExecTools   map[string]ExecToolSettings `json:"execTools,omitempty"` // Keyed by tool name
+	Webhooks    []WebhookSettings           `json:"webhooks,omitempty"`
+	Slack       SlackSettings               `json:"slack,omitempty"`
+	Hyperlinks  HyperlinkSettings           `json:"hyperlinks,omitempty"`
+	Privacy     PrivacySettings             `json:"privacy,omitempty"`
+	Retention   RetentionSettings           `json:"retention,omitempty"`
+	OAuth       map[string]OAuthSettings    `json:"oauth,omitempty"` // Keyed by provider, for john login
+	Target      TargetSettings              `json:"target,omitempty"`
+	Policy      PolicySettings              `json:"policy,omitempty"`   // Only from the managed settings file
+	Language    string                      `json:"language,omitempty"` // Language to answer and show messages in, e.g. "es" or "Japanese"
+	Time        TimeSettings                `json:"time,omitempty"`
+	Keys        map[string]string           `json:"keys,omitempty"` // Prompt action to key, e.g. {"palette": "ctrl+p"}; see /keys
+	Offline     OfflineSettings             `json:"offline,omitempty"`
+}
+
+// TimeSettings control how times are shown in /timeline, exports and the
+// session lists, and which zone days are counted in for digests and stats.
+// Session files always store UTC.
+type TimeSettings struct {
+	Zone   string `json:"zone,omitempty"`   // IANA name like "Europe/Berlin", or "UTC"; default the system's
+	Format string `json:"format,omitempty"` // "iso", "us", "eu", "uk" or a Go layout; default by language
+}
+
+// OfflineSettings control what happens to prompts while the provider cannot
+// be reached. They wait and are retried with backoff, or, with OllamaModel,
+// go to a local Ollama model until the connection is back.
+type OfflineSettings struct {
+	OllamaModel string   `json:"ollamaModel,omitempty"` // Local model to fall back to, e.g. "qwen2.5-coder"; none by default
+	OllamaURL   string   `json:"ollamaUrl,omitempty"`   // Default $OLLAMA_HOST or http://localhost:11434
+	MaxWait     Duration `json:"maxWait,omitempty"`     // How long to retry a queued prompt (default 10m)
+}
+
+// PrivacySettings controls what john keeps on disk about conversations.
+// JOHN_DATA_DIR moves everything it keeps from ~/.johncode.
+type PrivacySettings struct {
+	Ephemeral bool `json:"ephemeral,omitempty"` // Don't save sessions, tool stats or debug logs
+	// EncryptSessions encrypts session files with a key derived from a
+	// passphrase, read from $JOHN_SESSION_PASSPHRASE or asked for at start
+	EncryptSessions bool `json:"encryptSessions,omitempty"`
+}
+
+// RetentionSettings bound the session history kept under ~/.johncode.
+// Sessions are only removed under the limits set here; zero means no limit.
+// Favorite sessions and those of running instances are never removed.
+type RetentionSettings struct {
+	MaxSessions       int  `json:"maxSessions,omitempty"` // Per project
+	MaxAgeDays        int  `json:"maxAgeDays,omitempty"`
+	MaxTotalMB        int  `json:"maxTotalMB,omitempty"`        // All projects together
+	CompressAfterDays int  `json:"compressAfterDays,omitempty"` // Gzip older sessions and drop their tool-result files; default 14, -1 never
+	Manual            bool `json:"manual,omitempty"`            // Only prune with john sessions prune, not once a day at start
+}
+
+// OAuthSettings describe a provider's OAuth client for john login. With a
+// device authorization endpoint the device-code flow is used; otherwise the
+// browser opens the authorization URL and john listens on localhost for the
+// redirect.
+type OAuthSettings struct {
+	ClientID               string   `json:"clientId,omitempty"`
+	AuthorizationURL       string   `json:"authorizationUrl,omitempty"`
+	DeviceAuthorizationURL string   `json:"deviceAuthorizationUrl,omitempty"`
+	TokenURL               string   `json:"tokenUrl,omitempty"`
+	Scopes                 []string `json:"scopes,omitempty"`
+}
+
+// TargetSettings sets where Bash and the file tools work: on this machine,
+// in a running devcontainer, or on an SSH host. It belongs in the project's
+// settings, since it names that project's container or server.
+type TargetSettings struct {
+	Type      string `json:"type,omitempty"`      // "local" (default), "devcontainer" or "ssh"
+	Container string `json:"container,omitempty"` // devcontainer: name or ID; default the one started for this folder
+	Host      string `json:"host,omitempty"`      // ssh: host or [user@]host, as given to ssh
+	Dir       string `json:"dir,omitempty"`       // The project on the target; devcontainer default /workspaces/<folder>
+
+	Port         int    `json:"port,omitempty"`         // ssh: default 22 or the port in ~/.ssh/config
+	IdentityFile string `json:"identityFile,omitempty"` // ssh: private key; default the ssh agent and ~/.ssh keys
+}
+
+// HyperlinkSettings controls the links put on path:line references in the
+// terminal, so clicking one opens the file at that line
+type HyperlinkSettings struct {
+	Scheme string `json:"scheme,omitempty"` // file (default), vscode, vscode-insiders, cursor, windsurf, zed, idea or none
+	URL    string `json:"url,omitempty"`    // Template with {path}, {line} and {column}; overrides scheme
+}
+
+// hyperlinkSchemes are the URL templates of the editors Scheme can name
+var hyperlinkSchemes = map[string]string{
+	"file":            "file://{path}",
+	"vscode":          "vscode://file{path}:{line}:{column}",
+	"vscode-insiders": "vscode-insiders://file{path}:{line}:{column}",
+	"cursor":          "cursor://file{path}:{line}:{column}",
+	"windsurf":        "windsurf://file{path}:{line}:{column}",
+	"zed":             "zed://file{path}:{line}:{column}",
+	"idea":            "idea://open?file={path}&line={line}&column={column}",
+}
+
+// Template returns the URL template for links, or "" when they are off
+func (h HyperlinkSettings) Template() (string, error) {
+	if h.URL != "" {
+		if !strings.Contains(h.URL, "{path}") {
+			return "", fmt.Errorf("hyperlinks.url must contain {path}")
+		}
+		return h.URL, nil
+	}
+	switch h.Scheme {
+	case "":
+		return hyperlinkSchemes["file"], nil
+	case "none":
+		return "", nil
+	}
+	if t, ok := hyperlinkSchemes[h.Scheme]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown hyperlinks.scheme %q", h.Scheme)
+}
+
+// SlackSettings configures john slack, which answers messages in Slack
+// through a Socket Mode app. Each thread is its own session.
+type SlackSettings struct {
+	AppToken string   `json:"appToken,omitempty"` // xapp- token with connections:write; default $SLACK_APP_TOKEN
+	BotToken string   `json:"botToken,omitempty"` // xoxb- token with chat:write; default $SLACK_BOT_TOKEN
+	Channels []string `json:"channels,omitempty"` // Channel IDs to answer in
+	Users    []string `json:"users,omitempty"`    // User IDs to answer; channels or users must be set
+	Tools    []string `json:"tools,omitempty"`    // Tools the model may use (default read-only tools)
+	Model    string   `json:"model,omitempty"`    // Model ID or alias; default models.default
+}
+
+// DefaultSlackTools can look at the repository and the web but change nothing
+var DefaultSlackTools = []string{"Read", "Glob", "Grep", "WebFetch", "WebSearch"}
+
+// WithDefaults fills zero fields from the environment and DefaultSlackTools.
+// $VARS in the tokens are expanded.
+func (s SlackSettings) WithDefaults() SlackSettings {
+	if s.AppToken == "" {
+		s.AppToken = "$SLACK_APP_TOKEN"
+	}
+	if s.BotToken == "" {
+		s.BotToken = "$SLACK_BOT_TOKEN"
+	}
+	s.AppToken = os.ExpandEnv(s.AppToken)
+	s.BotToken = os.ExpandEnv(s.BotToken)
+	if len(s.Tools) == 0 {
+		s.Tools = DefaultSlackTools
+	}
+	return s
+}
+
+// Restricted reports whether channels or users limit who john slack
+// answers; it refuses to start otherwise
+func (s SlackSettings) Restricted() bool {
+	return len(s.Channels) > 0 || len(s.Users) > 0
+}
+
+// Allows reports whether john slack answers a user in a channel: both must
+// be listed, where a list is set. With neither list set it answers no one.
+func (s SlackSettings) Allows(channel, user string) bool {
+	if !s.Restricted() {
+		return false
+	}
+	return (len(s.Channels) == 0 || slices.Contains(s.Channels, channel)) &&
+		(len(s.Users) == 0 || slices.Contains(s.Users, user))
+}
+
+// Webhook events
+const (
+	WebhookSessionEnd = "sessionEnd" // The interactive session exits
+	WebhookIdle       = "idle"       // No prompt for IdleAfter
+	WebhookScheduled  = "scheduled"  // Every Every, if anything happened since the last report
+)
+
+// WebhookSettings posts a summary of the session (changes, cost, duration)
+// to a URL, so a team can follow what its agents are doing
+type WebhookSettings struct {
+	URL       string            `json:"url"`
+	Format    string            `json:"format,omitempty"`    // "json" (default) or "slack" for incoming webhooks
+	Events    []string          `json:"events,omitempty"`    // Default ["sessionEnd"]
+	Every     Duration          `json:"every,omitempty"`     // Interval of "scheduled" reports (default 1h)
+	IdleAfter Duration          `json:"idleAfter,omitempty"` // Quiet time before an "idle" report (default 30m)
+	Headers   map[string]string `json:"headers,omitempty"`   // $VARS are expanded, so tokens can stay out of the file
+}
+
+// WithDefaults fills zero fields of a webhook
+func (w WebhookSettings) WithDefaults() WebhookSettings {
+	if w.Format == "" {
+		w.Format = "json"
+	}
+	if len(w.Events) == 0 {
+		w.Events = []string{WebhookSessionEnd}
+	}
+	if w.Every == 0 {
+		w.Every = Duration(time.Hour)
+	}
+	if w.IdleAfter == 0 {
+		w.IdleAfter = Duration(30 * time.Minute)
+	}
+	return w
+}
+
+// On reports whether the webhook wants event
+func (w WebhookSettings) On(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// URLFetchSettings controls fetching links found in prompts. Each linked
+// page whose domain is allowed is fetched before the prompt is sent and
+// attached to it, summarized if long.
+type URLFetchSettings struct {
+	Enabled  bool     `json:"enabled,omitempty"`
+	Domains  []string `json:"domains,omitempty"`  // Allowed hosts, subdomains included; "*" allows any
+	MaxChars int      `json:"maxChars,omitempty"` // Longer pages are summarized (default 6000)
+	MaxURLs  int      `json:"maxUrls,omitempty"`  // Links fetched per prompt (default 3)
+}
+
+// DefaultURLFetchSettings keeps attached pages to a few thousand tokens
+var DefaultURLFetchSettings = URLFetchSettings{
+	MaxChars: 6000,
+	MaxURLs:  3,
+}
+
+// WithDefaults fills zero fields from DefaultURLFetchSettings
+func (u URLFetchSettings) WithDefaults() URLFetchSettings {
+	if u.MaxChars == 0 {
+		u.MaxChars = DefaultURLFetchSettings.MaxChars
+	}
+	if u.MaxURLs == 0 {
+		u.MaxURLs = DefaultURLFetchSettings.MaxURLs
+	}
+	return u
+}
+
+// Allows reports whether links to host may be fetched
+func (u URLFetchSettings) Allows(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range u.Domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "*."))
+		if domain == "*" || host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecToolSettings declares a tool backed by a command. The model's
+// arguments arrive on stdin as a JSON object and whatever the command prints
+// on stdout is the result; a non-zero exit is reported as a tool error.
+type ExecToolSettings struct {
+	Description string                 `json:"description"`
+	Command     string                 `json:"command"`           // Run with bash -c in the working directory
+	Schema      map[string]interface{} `json:"schema,omitempty"`  // JSON Schema of the arguments; default no arguments
+	Timeout     Duration               `json:"timeout,omitempty"` // Default 2m
+}
+
+// FileSettings controls how Write and Edit treat the files they write
+type FileSettings struct {
+	// EditorConfig is "fix" (default) to apply the project's .editorconfig to
+	// written files and flag what cannot be fixed, "check" to only flag it,
+	// or "off" to write files exactly as the model sent them
+	EditorConfig string `json:"editorConfig,omitempty"`
+}
+
+// ToolResultSettings controls summarizing large tool results. A result over
+// its tool's threshold is condensed by the summary model before it enters
+// the conversation, and the full output is saved where the model can Read it.
+type ToolResultSettings struct {
+	// Summarize maps tool names, or prefixes ending in "*" such as
+	// "mcp__*", to a threshold in bytes. A threshold of 0 turns a default off.
+	Summarize map[string]int `json:"summarize,omitempty"`
+	Model     string         `json:"model,omitempty"` // Model ID or alias, default "fast"
+}
+
+// DefaultToolResultSummaries summarizes fetched pages and docs, Grep's matching lines
+// and MCP tool results. Bash output is left alone because exact error text
+// matters there.
+var DefaultToolResultSummaries = map[string]int{
+	"WebFetch": 12000,
+	"Docs":     12000,
+	"Grep":     20000,
+	"mcp__*":   20000,
+}
+
+// Threshold returns the size above which a tool's results are summarized,
+// or 0 if they never are. Settings override the defaults; an exact name
+// wins over a pattern, and a longer pattern over a shorter one.
+func (t ToolResultSettings) Threshold(tool string) int {
+	merged := make(map[string]int)
+	for name, limit := range DefaultToolResultSummaries {
+		merged[name] = limit
+	}
+	for name, limit := range t.Summarize {
+		merged[name] = limit
+	}
+	if limit, ok := merged[tool]; ok {
+		return max(limit, 0)
+	}
+	best, limit := -1, 0
+	for name, l := range merged {
+		prefix, ok := strings.CutSuffix(name, "*")
+		if ok && strings.HasPrefix(tool, prefix) && len(prefix) > best {
+			best, limit = len(prefix), l
+		}
+	}
+	return max(limit, 0)
+}
+
+// ProjectSettings records how to build and test the project. john detects
+// the commands on first start in a trusted workspace; /build and /test run
+// them.
+type ProjectSettings struct {
+	BuildCommand string `json:"buildCommand,omitempty"`
+	TestCommand  string `json:"testCommand,omitempty"`
+}
+
+// WatchdogSettings bounds a single turn, the chain of model calls and tool
+// runs that answers one prompt. Past a limit john pauses and asks whether to
+// go on. Zero values use the defaults in DefaultWatchdogSettings.
+type WatchdogSettings struct {
+	MaxDuration Duration `json:"maxDuration,omitempty"` // Wall-clock time
+	MaxTokens   int      `json:"maxTokens,omitempty"`   // Input plus output tokens over all model calls
+	MaxCost     float64  `json:"maxCost,omitempty"`     // Estimated spend in USD
+	Disabled    bool     `json:"disabled,omitempty"`
+}
+
+// DefaultWatchdogSettings catches runaway turns well before they get expensive
+var DefaultWatchdogSettings = WatchdogSettings{
+	MaxDuration: Duration(15 * time.Minute),
+	MaxTokens:   2000000,
+	MaxCost:     5,
+}
+
+// WithDefaults fills zero fields from DefaultWatchdogSettings
+func (w WatchdogSettings) WithDefaults() WatchdogSettings {
+	d := DefaultWatchdogSettings
+	if w.MaxDuration == 0 {
+		w.MaxDuration = d.MaxDuration
+	}
+	if w.MaxTokens == 0 {
+		w.MaxTokens = d.MaxTokens
+	}
+	if w.MaxCost == 0 {
+		w.MaxCost = d.MaxCost
+	}
+	return w
+}
+
+// ModelSettings names the models "/model default" and "/model fast" switch
+// to, plus any other aliases. Values are model IDs or names /model accepts.
+type ModelSettings struct {
+	Default string            `json:"default,omitempty"` // Also the model john starts with
+	Fast    string            `json:"fast,omitempty"`
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// GitHookSettings configures the checks run by hooks from john hooks install
+type GitHookSettings struct {
+	Model     string        `json:"model,omitempty"` // Model ID or alias, default claude-haiku-4.5
+	PreCommit GitHookConfig `json:"preCommit,omitempty"`
+	PrePush   GitHookConfig `json:"prePush,omitempty"`
+}
+
+// GitHookConfig is the check for one git hook
+type GitHookConfig struct {
+	Prompt  string `json:"prompt,omitempty"`  // What to check the diff for
+	Policy  string `json:"policy,omitempty"`  // "block" (default) fails the git command, "warn" only reports
+	OnError string `json:"onError,omitempty"` // "allow" (default) or "block" when the check cannot run
+}
+
+// ServerToolSettings enables tools that Anthropic runs on its side. They are
+// only used with Anthropic models; other providers keep the local tools.
+type ServerToolSettings struct {
+	WebSearch        bool `json:"webSearch,omitempty"`        // Replaces the local WebSearch tool
+	WebSearchMaxUses int  `json:"webSearchMaxUses,omitempty"` // Searches allowed per request (default 5)
+	CodeExecution    bool `json:"codeExecution,omitempty"`    // Sandboxed Python on Anthropic's servers
+}
+
+// NetworkSettings controls how outbound HTTP requests are made. Empty proxy
+// fields fall back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+type NetworkSettings struct {
+	HTTPProxy          string `json:"httpProxy,omitempty"`
+	HTTPSProxy         string `json:"httpsProxy,omitempty"`
+	NoProxy            string `json:"noProxy,omitempty"`
+	CACertFile         string `json:"caCertFile,omitempty"`         // PEM bundle trusted in addition to the system roots
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // Disable TLS verification (debugging only)
+}
+
+// ProviderSettings limits the HTTP traffic to one LLM provider. Zero values
+// use the defaults in DefaultProviderSettings.
+type ProviderSettings struct {
+	ConnectTimeout        Duration `json:"connectTimeout,omitempty"`        // TCP connect and TLS handshake
+	ResponseHeaderTimeout Duration `json:"responseHeaderTimeout,omitempty"` // Wait for the provider to start responding
+	Timeout               Duration `json:"timeout,omitempty"`               // Whole request, including a streamed body
+	MaxRequestBytes       int64    `json:"maxRequestBytes,omitempty"`
+	MaxResponseBytes      int64    `json:"maxResponseBytes,omitempty"`
+	MaxIdleConns          int      `json:"maxIdleConns,omitempty"` // Keep-alive pool size
+	IdleConnTimeout       Duration `json:"idleConnTimeout,omitempty"`
+}
+
+// DefaultProviderSettings keeps a stalled provider from hanging a turn forever
+// while leaving room for long streamed responses.
+var DefaultProviderSettings = ProviderSettings{
+	ConnectTimeout:        Duration(30 * time.Second),
+	ResponseHeaderTimeout: Duration(5 * time.Minute),
+	Timeout:               Duration(20 * time.Minute),
+	MaxRequestBytes:       32 << 20,
+	MaxResponseBytes:      64 << 20,
+	MaxIdleConns:          10,
+	IdleConnTimeout:       Duration(90 * time.Second),
+}
+
+// withDefaults fills zero fields from DefaultProviderSettings
+func (p ProviderSettings) withDefaults() ProviderSettings {
+	d := DefaultProviderSettings
+	if p.ConnectTimeout == 0 {
+		p.ConnectTimeout = d.ConnectTimeout
+	}
+	if p.ResponseHeaderTimeout == 0 {
+		p.ResponseHeaderTimeout = d.ResponseHeaderTimeout
+	}
+	if p.Timeout == 0 {
+		p.Timeout = d.Timeout
+	}
+	if p.MaxRequestBytes == 0 {
+		p.MaxRequestBytes = d.MaxRequestBytes
+	}
+	if p.MaxResponseBytes == 0 {
+		p.MaxResponseBytes = d.MaxResponseBytes
+	}
+	if p.MaxIdleConns == 0 {
+		p.MaxIdleConns = d.MaxIdleConns
+	}
+	if p.IdleConnTimeout == 0 {
+		p.IdleConnTimeout = d.IdleConnTimeout
+	}
+	return p
+}
+
+// Duration is a time.Duration written in settings as a string such as "30s"
+// or "5m", or as a number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(val * float64(time.Second)))
+	case string:
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", val, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UserDir returns ~/.config/john-code, which holds user-wide settings,
+// commands and agents
+func UserDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "john-code"), nil
+}
+
+// ProjectDir returns .john in the working directory, which holds the
+// project's settings, commands and agents
+func ProjectDir() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return filepath.Join(cwd, ".john"), nil
+}
+
+// UserSettingsPath returns ~/.config/john-code/settings.json
+func UserSettingsPath() (string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "settings.json"), nil
+}
+
+// ProjectSettingsPath returns .john/settings.json in the working directory
+func ProjectSettingsPath() (string, error) {
+	dir, err := ProjectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "settings.json"), nil
+}
+
+// LoadSettings reads the user and project settings files, then the managed
+// settings file, whose values take precedence. Missing files are not an
+// error. The project file is skipped until the workspace is trusted.
+func LoadSettings() (*Settings, error) {
+	return LoadSettingsWith("")
+}
+
+// LoadSettingsWith is LoadSettings with the file at extra, when not empty,
+// read after the project file, for trying out other settings. Unlike the
+// usual files it must exist.
+func LoadSettingsWith(extra string) (*Settings, error) {
+	settings := &Settings{}
+	paths := []func() (string, error){UserSettingsPath}
+	if ProjectTrusted() {
+		paths = append(paths, ProjectSettingsPath)
+	}
+	for _, pathFn := range paths {
+		path, err := pathFn()
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeSettingsFile(settings, path); err != nil {
+			return nil, err
+		}
+	}
+	if extra != "" {
+		if _, err := os.Stat(extra); err != nil {
+			return nil, err
+		}
+		if err := mergeSettingsFile(settings, extra); err != nil {
+			return nil, err
+		}
+	}
+	settings.Policy = PolicySettings{}
+	if err := mergeSettingsFile(settings, ManagedSettingsPath()); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// mergeSettingsFile decodes path over settings, overriding only the fields
+// present in the file. Permission rules accumulate instead of replacing, and
+// the publish policy is kept unless the file sets one.
+func mergeSettingsFile(settings *Settings, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	prev := settings.Permissions
+	settings.Permissions = Permissions{}
+	if err := json.Unmarshal(data, settings); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	settings.Permissions = Permissions{
+		Allow: append(prev.Allow, settings.Permissions.Allow...),
+		Ask:   append(prev.Ask, settings.Permissions.Ask...),
+		Deny:  append(prev.Deny, settings.Permissions.Deny...),
+	}
+	if settings.Permissions.Publish == "" {
+		settings.Permissions.Publish = prev.Publish
+	}
+	return nil
+}
+
+// SaveProjectSettings writes the project section of .john/settings.json,
+// keeping the rest of the file
+func SaveProjectSettings(project ProjectSettings) (string, error) {
+	return saveProjectSection("project", project)
+}
+
+// SaveProjectTarget writes the target section of .john/settings.json, or
+// removes it when target is nil, keeping the rest of the file
+func SaveProjectTarget(target *TargetSettings) (string, error) {
+	return saveProjectSection("target", target)
+}
+
+// saveProjectSection replaces one top-level section of the project's
+// settings file. A nil value removes the section.
+func saveProjectSection(name string, value interface{}) (string, error) {
+	path, err := ProjectSettingsPath()
+	if err != nil {
+		return "", err
+	}
+	err = WithFileLock(path, func() error {
+		raw := make(map[string]json.RawMessage)
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, &raw); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		section, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if string(section) == "null" {
+			delete(raw, name)
+		} else {
+			raw[name] = section
+		}
+		data, err := json.MarshalIndent(raw, "", "  ")
+		if err != nil {
+			return err
+		}
+		return WriteFileAtomic(path, append(data, '\n'), 0644)
+	})
+	return path, err
+}
@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestToolResultThreshold(t *testing.T) {
+	settings := ToolResultSettings{Summarize: map[string]int{
+		"WebFetch":        0,
+		"Bash":            50000,
+		"mcp__github__*":  4000,
+		"mcp__github__ls": 0,
+	}}
+
+	tests := []struct {
+		tool string
+		want int
+	}{
+		{"Grep", 20000},
+		{"WebFetch", 0},
+		{"Bash", 50000},
+		{"Read", 0},
+		{"mcp__slack__search", 20000},
+		{"mcp__github__search_code", 4000},
+		{"mcp__github__ls", 0},
+	}
+	for _, tt := range tests {
+		if got := settings.Threshold(tt.tool); got != tt.want {
+			t.Errorf("Threshold(%q) = %d, want %d", tt.tool, got, tt.want)
+		}
+	}
+	if got := (ToolResultSettings{}).Threshold("Grep"); got != DefaultToolResultSummaries["Grep"] {
+		t.Errorf("default Grep threshold = %d", got)
+	}
+}
+
+func TestSlackAllows(t *testing.T) {
+	if (SlackSettings{}).Allows("C1", "U1") {
+		t.Error("no allowlist answers everyone")
+	}
+	channels := SlackSettings{Channels: []string{"C1"}}
+	if !channels.Allows("C1", "U9") || channels.Allows("D1", "U9") {
+		t.Error("channel allowlist")
+	}
+	both := SlackSettings{Channels: []string{"C1"}, Users: []string{"U1"}}
+	if !both.Allows("C1", "U1") || both.Allows("C1", "U2") || both.Allows("D1", "U1") {
+		t.Error("channel and user allowlist")
+	}
+}
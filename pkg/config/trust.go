@@ -0,0 +1,103 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// trustStore records the user's trust decisions, keyed by absolute directory.
+// A decision applies to the directory and everything below it; the nearest
+// decision wins.
+type trustStore struct {
+	Workspaces map[string]bool `json:"workspaces"`
+}
+
+// TrustFilePath returns ~/.config/john-code/trusted-workspaces.json
+func TrustFilePath() (string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trusted-workspaces.json"), nil
+}
+
+func loadTrustStore() (*trustStore, string, error) {
+	path, err := TrustFilePath()
+	if err != nil {
+		return nil, "", err
+	}
+	store := &trustStore{Workspaces: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, path, nil
+		}
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if store.Workspaces == nil {
+		store.Workspaces = make(map[string]bool)
+	}
+	return store, path, nil
+}
+
+// WorkspaceTrust reports whether dir is trusted, and whether the user has
+// decided at all. Undecided workspaces are not trusted.
+func WorkspaceTrust(dir string) (trusted bool, decided bool) {
+	store, _, err := loadTrustStore()
+	if err != nil {
+		return false, false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, false
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if t, ok := store.Workspaces[d]; ok {
+			return t, true
+		}
+		if filepath.Dir(d) == d {
+			return false, false
+		}
+	}
+}
+
+// SetWorkspaceTrust records the user's decision for dir
+func SetWorkspaceTrust(dir string, trusted bool) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	path, err := TrustFilePath()
+	if err != nil {
+		return err
+	}
+	return WithFileLock(path, func() error {
+		store, path, err := loadTrustStore()
+		if err != nil {
+			return err
+		}
+		store.Workspaces[abs] = trusted
+
+		data, err := json.MarshalIndent(store, "", "  ")
+		if err != nil {
+			return err
+		}
+		return WriteFileAtomic(path, data, 0644)
+	})
+}
+
+// ProjectTrusted reports whether the working directory is trusted. Project
+// settings, commands, agents and MCP servers are only used when it is.
+func ProjectTrusted() bool {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	trusted, _ := WorkspaceTrust(cwd)
+	return trusted
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceTrust(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	sub := filepath.Join(root, "services", "api")
+
+	if trusted, decided := WorkspaceTrust(sub); trusted || decided {
+		t.Errorf("Expected an undecided workspace, got trusted=%v decided=%v", trusted, decided)
+	}
+
+	if err := SetWorkspaceTrust(root, true); err != nil {
+		t.Fatalf("SetWorkspaceTrust failed: %v", err)
+	}
+	if trusted, decided := WorkspaceTrust(sub); !trusted || !decided {
+		t.Error("Expected trust to apply to subdirectories")
+	}
+
+	// The nearest decision wins
+	if err := SetWorkspaceTrust(filepath.Join(root, "services"), false); err != nil {
+		t.Fatalf("SetWorkspaceTrust failed: %v", err)
+	}
+	if trusted, decided := WorkspaceTrust(sub); trusted || !decided {
+		t.Error("Expected the closer distrust decision to win")
+	}
+	if trusted, _ := WorkspaceTrust(root); !trusted {
+		t.Error("Expected the root to stay trusted")
+	}
+}
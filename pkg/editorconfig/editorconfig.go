@@ -0,0 +1,302 @@
+// Package editorconfig reads the .editorconfig files that apply to a file,
+// following https://spec.editorconfig.org: files are searched from the
+// file's directory up to the one marked root = true, and closer files and
+// later sections override earlier ones.
+package editorconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the files Resolve reads
+const FileName = ".editorconfig"
+
+// Properties are the settings that apply to one file, with names and values
+// lowercased. "unset" removes a property a less specific section set.
+type Properties map[string]string
+
+// IndentStyle returns "tab", "space" or "" when not set
+func (p Properties) IndentStyle() string {
+	switch s := p["indent_style"]; s {
+	case "tab", "space":
+		return s
+	}
+	return ""
+}
+
+// IndentSize returns the columns of one indentation level, or 0 when not set.
+// indent_size = tab means tab_width.
+func (p Properties) IndentSize() int {
+	if p["indent_size"] == "tab" {
+		return p.TabWidth()
+	}
+	n, _ := strconv.Atoi(p["indent_size"])
+	if n <= 0 && p.IndentStyle() == "tab" {
+		return p.TabWidth()
+	}
+	return n
+}
+
+// TabWidth returns the columns of a tab: tab_width, else indent_size, else 0
+func (p Properties) TabWidth() int {
+	if n, err := strconv.Atoi(p["tab_width"]); err == nil && n > 0 {
+		return n
+	}
+	n, _ := strconv.Atoi(p["indent_size"])
+	return n
+}
+
+// EndOfLine returns "lf", "crlf", "cr" or "" when not set
+func (p Properties) EndOfLine() string {
+	switch s := p["end_of_line"]; s {
+	case "lf", "crlf", "cr":
+		return s
+	}
+	return ""
+}
+
+// TrimTrailingWhitespace reports whether trailing whitespace is removed
+func (p Properties) TrimTrailingWhitespace() bool {
+	return p["trim_trailing_whitespace"] == "true"
+}
+
+// InsertFinalNewline reports whether files end with a newline, and whether
+// the property is set at all: false asks for files without one
+func (p Properties) InsertFinalNewline() (value, set bool) {
+	switch p["insert_final_newline"] {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// MaxLineLength returns max_line_length, or 0 when not set or "off"
+func (p Properties) MaxLineLength() int {
+	n, _ := strconv.Atoi(p["max_line_length"])
+	return n
+}
+
+// ReadFunc reads a file; Resolve uses it to reach files on a remote target
+type ReadFunc func(name string) ([]byte, error)
+
+// Resolve returns the properties for file, an absolute path, reading the
+// .editorconfig files above it with read (os.ReadFile when nil). A file
+// that is missing is skipped; one that cannot be parsed is an error.
+func Resolve(file string, read ReadFunc) (Properties, error) {
+	if read == nil {
+		read = os.ReadFile
+	}
+	var configs []*config
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		data, err := read(filepath.Join(dir, FileName))
+		if err == nil {
+			c, err := parse(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Join(dir, FileName), err)
+			}
+			c.dir = dir
+			configs = append(configs, c)
+			if c.root {
+				break
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	props := Properties{}
+	for i := len(configs) - 1; i >= 0; i-- {
+		c := configs[i]
+		rel, err := filepath.Rel(c.dir, file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range c.sections {
+			if !s.match(rel) {
+				continue
+			}
+			for _, kv := range s.props {
+				if kv[1] == "unset" {
+					delete(props, kv[0])
+				} else {
+					props[kv[0]] = kv[1]
+				}
+			}
+		}
+	}
+	return props, nil
+}
+
+type config struct {
+	dir      string
+	root     bool
+	sections []section
+}
+
+type section struct {
+	pattern *regexp.Regexp
+	ranges  [][2]int // {n1..n2} ranges, in the order of their groups
+	props   [][2]string
+}
+
+// match reports whether the section applies to rel, a slash-separated path
+// relative to the directory of its .editorconfig
+func (s section) match(rel string) bool {
+	m := s.pattern.FindStringSubmatch(rel)
+	if m == nil {
+		return false
+	}
+	for i, r := range s.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func parse(data []byte) (*config, error) {
+	c := &config{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: section header without ]", lineNum)
+			}
+			pattern, ranges, err := compileGlob(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			c.sections = append(c.sections, section{pattern: pattern, ranges: ranges})
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNum)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len(c.sections) == 0 {
+			if key == "root" {
+				c.root = value == "true"
+			}
+			continue
+		}
+		current := &c.sections[len(c.sections)-1]
+		current.props = append(current.props, [2]string{key, value})
+	}
+	return c, scanner.Err()
+}
+
+var numRange = regexp.MustCompile(`^\{([+-]?\d+)\.\.([+-]?\d+)\}`)
+
+// compileGlob turns a section name into a regular expression over paths
+// relative to the .editorconfig's directory. A glob without a slash
+// matches the file name in any directory.
+func compileGlob(glob string) (*regexp.Regexp, [][2]int, error) {
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	} else {
+		glob = strings.TrimPrefix(glob, "/")
+	}
+
+	var re strings.Builder
+	var ranges [][2]int
+	braces := 0
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		ch := glob[i]
+		switch ch {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// **/ also matches no directory at all
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			i += end + 1
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+		case '{':
+			if m := numRange.FindStringSubmatch(glob[i:]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				ranges = append(ranges, [2]int{lo, hi})
+				re.WriteString(`([+-]?\d+)`)
+				i += len(m[0]) - 1
+				continue
+			}
+			if !strings.Contains(glob[i:], "}") {
+				re.WriteString(`\{`)
+				continue
+			}
+			braces++
+			re.WriteString("(?:")
+		case '}':
+			if braces == 0 {
+				re.WriteString(`\}`)
+				continue
+			}
+			braces--
+			re.WriteString(")")
+		case ',':
+			if braces > 0 {
+				re.WriteString("|")
+			} else {
+				re.WriteString(",")
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString("$")
+	pattern, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad section name: %w", err)
+	}
+	return pattern, ranges, nil
+}
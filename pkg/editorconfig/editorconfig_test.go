@@ -0,0 +1,88 @@
+package editorconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveMergesFilesAndSections(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, ".editorconfig"), `root = true
+
+[*]
+indent_style = space
+indent_size = 4
+insert_final_newline = true
+trim_trailing_whitespace = true
+
+[*.{go,mod}]
+indent_style = tab
+
+[Makefile]
+indent_style = tab
+
+[docs/**.md]
+trim_trailing_whitespace = false
+
+[file{1..3}.txt]
+indent_size = 2
+`)
+	write(filepath.Join(root, "web", ".editorconfig"), `[*.js]
+indent_size = 2
+insert_final_newline = unset
+`)
+
+	tests := []struct {
+		file string
+		want Properties
+	}{
+		{"main.go", Properties{"indent_style": "tab", "indent_size": "4", "insert_final_newline": "true", "trim_trailing_whitespace": "true"}},
+		{"cmd/x/go.mod", Properties{"indent_style": "tab", "indent_size": "4", "insert_final_newline": "true", "trim_trailing_whitespace": "true"}},
+		{"sub/Makefile", Properties{"indent_style": "tab", "indent_size": "4", "insert_final_newline": "true", "trim_trailing_whitespace": "true"}},
+		{"docs/guide/intro.md", Properties{"indent_style": "space", "indent_size": "4", "insert_final_newline": "true", "trim_trailing_whitespace": "false"}},
+		{"web/app.js", Properties{"indent_style": "space", "indent_size": "2", "trim_trailing_whitespace": "true"}},
+		{"file2.txt", Properties{"indent_style": "space", "indent_size": "2", "insert_final_newline": "true", "trim_trailing_whitespace": "true"}},
+		{"file4.txt", Properties{"indent_style": "space", "indent_size": "4", "insert_final_newline": "true", "trim_trailing_whitespace": "true"}},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(filepath.Join(root, tt.file), nil)
+		if err != nil {
+			t.Fatalf("Resolve(%s): %v", tt.file, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Resolve(%s) = %v, want %v", tt.file, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("Resolve(%s)[%s] = %q, want %q", tt.file, k, got[k], v)
+			}
+		}
+	}
+
+	props, _ := Resolve(filepath.Join(root, "main.go"), nil)
+	if props.IndentStyle() != "tab" || props.IndentSize() != 4 || props.TabWidth() != 4 {
+		t.Errorf("main.go: style %q size %d tab %d", props.IndentStyle(), props.IndentSize(), props.TabWidth())
+	}
+	if v, set := props.InsertFinalNewline(); !v || !set {
+		t.Errorf("InsertFinalNewline = %v, %v", v, set)
+	}
+}
+
+func TestResolveReportsBrokenFiles(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".editorconfig"), []byte("root = true\n[*.go\nindent_style = tab\n"), 0644)
+	if _, err := Resolve(filepath.Join(root, "main.go"), nil); err == nil {
+		t.Error("Resolve accepted a section header without ]")
+	}
+}
@@ -0,0 +1,170 @@
+package history
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PassphraseEnv is the environment variable read for the session passphrase
+// before prompting for it
+const PassphraseEnv = "JOHN_SESSION_PASSPHRASE"
+
+// keyFileName holds the salt the session key is derived with and a value
+// sealed with it to check passphrases. The key itself is never stored.
+const keyFileName = "session-key.json"
+
+const (
+	kdfIterations = 600000
+	keyCheck      = "john-code session key"
+)
+
+// ErrWrongPassphrase is returned by UnlockSessions for a passphrase that
+// does not match the one the sessions were encrypted with
+var ErrWrongPassphrase = errors.New("wrong passphrase for encrypted sessions")
+
+type keyFile struct {
+	Salt       string `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Check      string `json:"check"`
+}
+
+// encryptedLine is how an event is stored while sessions are encrypted
+type encryptedLine struct {
+	Encrypted string `json:"encrypted"` // Base64 of nonce and AES-GCM ciphertext
+}
+
+// sessionCipher encrypts events as they are written and decrypts them as
+// they are read; nil leaves them in plain text
+var sessionCipher cipher.AEAD
+
+// UnlockSessions derives the key for the sessions under root from
+// passphrase, so that events are written encrypted and encrypted sessions
+// can be read. The first call for a root sets its passphrase.
+func UnlockSessions(root, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("an empty passphrase cannot encrypt sessions")
+	}
+	path := filepath.Join(root, keyFileName)
+	var kf keyFile
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &kf); err != nil {
+			return fmt.Errorf("invalid %s: %w", path, err)
+		}
+	case os.IsNotExist(err):
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		kf = keyFile{Salt: base64.StdEncoding.EncodeToString(salt), Iterations: kdfIterations}
+	default:
+		return err
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(kf.Salt)
+	if err != nil || kf.Iterations <= 0 {
+		return fmt.Errorf("invalid %s", path)
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kf.Iterations, 32)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	if kf.Check == "" {
+		check, err := seal(aead, []byte(keyCheck))
+		if err != nil {
+			return err
+		}
+		kf.Check = check
+		data, _ := json.MarshalIndent(kf, "", "  ")
+		if err := os.MkdirAll(root, 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+	} else if plain, err := open(aead, kf.Check); err != nil || string(plain) != keyCheck {
+		return ErrWrongPassphrase
+	}
+	sessionCipher = aead
+	return nil
+}
+
+// LockSessions forgets the session key: events are written in plain text
+// again and encrypted ones are skipped when reading
+func LockSessions() {
+	sessionCipher = nil
+}
+
+// SessionsEncrypted reports whether new events are written encrypted
+func SessionsEncrypted() bool {
+	return sessionCipher != nil
+}
+
+// encodeLine encrypts an event's JSON when sessions are unlocked
+func encodeLine(line []byte) ([]byte, error) {
+	if sessionCipher == nil {
+		return line, nil
+	}
+	sealed, err := seal(sessionCipher, line)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedLine{Encrypted: sealed})
+}
+
+// decodeLine returns an event's JSON, decrypting it if needed. It returns
+// nil for an encrypted line that cannot be decrypted, which readers skip
+// like any other unreadable line.
+func decodeLine(line []byte) []byte {
+	if !bytes.HasPrefix(line, []byte(`{"encrypted":`)) {
+		return line
+	}
+	var enc encryptedLine
+	if sessionCipher == nil || json.Unmarshal(line, &enc) != nil {
+		return nil
+	}
+	plain, err := open(sessionCipher, enc.Encrypted)
+	if err != nil {
+		return nil
+	}
+	return plain
+}
+
+func seal(aead cipher.AEAD, plain []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+func open(aead cipher.AEAD, sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
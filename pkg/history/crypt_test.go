@@ -0,0 +1,77 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+func TestEncryptedSessionRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	defer LockSessions()
+
+	if err := UnlockSessions(root, "correct horse"); err != nil {
+		t.Fatalf("UnlockSessions failed: %v", err)
+	}
+	sm, err := NewSessionManagerInRoot(root, "/work/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Append(llm.RoleUser, llm.Message{Role: llm.RoleUser, Content: "the launch codes are 1234"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := sm.AppendTodos([]map[string]string{{"content": "Rotate keys", "status": "pending"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(sm.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "launch codes") || strings.Contains(string(data), "Rotate keys") {
+		t.Errorf("session file holds plain text:\n%s", data)
+	}
+
+	transcript, err := LoadTranscript(sm.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcript.Messages) != 1 || transcript.Messages[0].Content != "the launch codes are 1234" || transcript.Todos == nil {
+		t.Errorf("transcript = %+v", transcript)
+	}
+	sessions, _ := ListSessions(root, "/work/secret")
+	if len(sessions) != 1 || sessions[0].FirstPrompt != "the launch codes are 1234" {
+		t.Errorf("sessions = %+v", sessions)
+	}
+
+	// Without the key the events are unreadable and skipped
+	LockSessions()
+	if transcript, _ := LoadTranscript(sm.FilePath); len(transcript.Messages) != 0 {
+		t.Errorf("locked transcript = %+v", transcript.Messages)
+	}
+
+	if err := UnlockSessions(root, "wrong"); err != ErrWrongPassphrase {
+		t.Errorf("wrong passphrase: err = %v", err)
+	}
+	if err := UnlockSessions(root, "correct horse"); err != nil {
+		t.Errorf("unlocking again failed: %v", err)
+	}
+}
+
+func TestDefaultRootFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DataDirEnv, dir)
+	root, err := DefaultRoot()
+	if err != nil || root != dir {
+		t.Errorf("DefaultRoot() = %q, %v; want %q", root, err, dir)
+	}
+
+	t.Setenv(DataDirEnv, "")
+	t.Setenv("HOME", dir)
+	if root, _ := DefaultRoot(); root != filepath.Join(dir, ".johncode") {
+		t.Errorf("DefaultRoot() = %q without %s", root, DataDirEnv)
+	}
+}
@@ -0,0 +1,208 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProjectDigest is the work done in one project over a time range
+type ProjectDigest struct {
+	CWD          string
+	Sessions     int
+	Tasks        []string // What the user asked for, in order
+	FilesChanged []string // Files written or edited, sorted
+	Completed    []string // Todos completed in the range
+	Open         []string // Todos still pending or in progress at the end of it
+	ToolCalls    int
+	First        time.Time
+	Last         time.Time
+}
+
+// fileChangingTools maps the tools that modify files to their path argument
+var fileChangingTools = map[string]string{
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"RenameSymbol": "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// BuildDigest scans every session under root for events between since and
+// until and summarizes them by project, most recently active first. Todos
+// already completed before since are not reported again.
+func BuildDigest(root string, since, until time.Time) ([]ProjectDigest, error) {
+	files, err := filepath.Glob(filepath.Join(root, "projects", "*", "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	compressed, _ := filepath.Glob(filepath.Join(root, "projects", "*", "*"+compressedExt))
+	files = append(files, compressed...)
+
+	projects := map[string]*ProjectDigest{}
+	for _, path := range files {
+		// A session last written before the range has nothing in it
+		if info, err := os.Stat(path); err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		if err := digestSession(path, since, until, projects); err != nil {
+			return nil, err
+		}
+	}
+
+	var digests []ProjectDigest
+	for _, p := range projects {
+		sort.Strings(p.FilesChanged)
+		digests = append(digests, *p)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		return digests[i].Last.After(digests[j].Last)
+	})
+	return digests, nil
+}
+
+func digestSession(path string, since, until time.Time, projects map[string]*ProjectDigest) error {
+	f, err := openSession(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var project *ProjectDigest
+	files := map[string]bool{}
+	doneBefore := map[string]bool{} // Todos completed before the range
+	var todos json.RawMessage       // Latest snapshot in the range
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event storedEvent
+		if err := json.Unmarshal(decodeLine(scanner.Bytes()), &event); err != nil {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, event.Timestamp)
+		if err != nil || !ts.Before(until) {
+			continue
+		}
+		if ts.Before(since) {
+			if event.Type == EventTypeTodos {
+				doneBefore = map[string]bool{}
+				for _, content := range todosWithStatus(event.Todos, "completed") {
+					doneBefore[content] = true
+				}
+			}
+			continue
+		}
+
+		if project == nil {
+			project = projects[event.CWD]
+			if project == nil {
+				project = &ProjectDigest{CWD: event.CWD, First: ts}
+				projects[event.CWD] = project
+			}
+			project.Sessions++
+			for _, file := range project.FilesChanged {
+				files[file] = true
+			}
+		}
+		if ts.Before(project.First) {
+			project.First = ts
+		}
+		if ts.After(project.Last) {
+			project.Last = ts
+		}
+
+		switch event.Type {
+		case EventTypeTodos:
+			todos = event.Todos
+		case EventTypeUser:
+			if task := typedPrompt(event.Message); task != "" {
+				project.Tasks = append(project.Tasks, task)
+			}
+		case EventTypeAssistant:
+			var msg storedMessage
+			var blocks []storedBlock
+			if json.Unmarshal(event.Message, &msg) != nil || json.Unmarshal(msg.Content, &blocks) != nil {
+				continue
+			}
+			for _, block := range blocks {
+				if block.Type != "tool_use" {
+					continue
+				}
+				project.ToolCalls++
+				if arg, ok := fileChangingTools[block.Name]; ok {
+					if file, _ := block.Input[arg].(string); file != "" {
+						file = relativeTo(event.CWD, file)
+						if !files[file] {
+							files[file] = true
+							project.FilesChanged = append(project.FilesChanged, file)
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if project != nil && todos != nil {
+		for _, content := range todosWithStatus(todos, "completed") {
+			if !doneBefore[content] {
+				project.Completed = append(project.Completed, content)
+			}
+		}
+		project.Open = append(project.Open, todosWithStatus(todos, "in_progress")...)
+		project.Open = append(project.Open, todosWithStatus(todos, "pending")...)
+	}
+	return nil
+}
+
+// typedPrompt returns the first line of a prompt the user typed, or "" for
+// tool results and other messages sent on the user's behalf
+func typedPrompt(raw json.RawMessage) string {
+	var msg storedMessage
+	var text string
+	if json.Unmarshal(raw, &msg) != nil || json.Unmarshal(msg.Content, &text) != nil {
+		return ""
+	}
+	if i := strings.Index(text, "<system-reminder>"); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSpace(text)
+	if i := strings.Index(text, "\n"); i >= 0 {
+		text = strings.TrimSpace(text[:i])
+	}
+	if len(text) > 120 {
+		text = text[:120] + "..."
+	}
+	return text
+}
+
+func todosWithStatus(raw json.RawMessage, status string) []string {
+	var todos []struct {
+		Content string `json:"content"`
+		Status  string `json:"status"`
+	}
+	if json.Unmarshal(raw, &todos) != nil {
+		return nil
+	}
+	var contents []string
+	for _, todo := range todos {
+		if todo.Status == status {
+			contents = append(contents, todo.Content)
+		}
+	}
+	return contents
+}
+
+// relativeTo shortens path to be relative to cwd when it is inside it
+func relativeTo(cwd, path string) string {
+	if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,106 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDigest(t *testing.T) {
+	root := t.TempDir()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	writeSession := func(cwd, id string, events ...SessionEvent) {
+		dir := ProjectDir(root, cwd)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		for _, event := range events {
+			event.SessionID = id
+			event.CWD = cwd
+			data, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, string(data))
+		}
+		if err := os.WriteFile(filepath.Join(dir, id+".jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := func(hours float64) string {
+		return day.Add(time.Duration(hours * float64(time.Hour))).Format(time.RFC3339Nano)
+	}
+	prompt := func(hours float64, text string) SessionEvent {
+		return SessionEvent{Type: EventTypeUser, Timestamp: at(hours), Message: map[string]interface{}{"role": "user", "content": text}}
+	}
+	toolUse := func(hours float64, name, arg, value string) SessionEvent {
+		return SessionEvent{Type: EventTypeAssistant, Timestamp: at(hours), Message: map[string]interface{}{
+			"role":    "assistant",
+			"content": []map[string]interface{}{{"type": "tool_use", "id": "t", "name": name, "input": map[string]string{arg: value}}},
+		}}
+	}
+	todos := func(hours float64, items ...[2]string) SessionEvent {
+		var list []map[string]string
+		for _, item := range items {
+			list = append(list, map[string]string{"content": item[0], "status": item[1]})
+		}
+		return SessionEvent{Type: EventTypeTodos, Timestamp: at(hours), Todos: list}
+	}
+
+	writeSession("/work/api", "s1",
+		todos(-5, [2]string{"Set up CI", "completed"}),
+		prompt(9, "Add rate limiting\n\nUse a token bucket<system-reminder>ignored</system-reminder>"),
+		toolUse(9.1, "Edit", "file_path", "/work/api/server.go"),
+		toolUse(9.2, "Write", "file_path", "/work/api/limit.go"),
+		toolUse(9.3, "Bash", "command", "go test ./..."),
+		todos(9.4, [2]string{"Set up CI", "completed"}, [2]string{"Add limiter", "completed"}, [2]string{"Document it", "pending"}),
+		prompt(30, "Tomorrow's work"),
+	)
+	writeSession("/work/api", "s2",
+		prompt(11, "Fix the flaky test"),
+		toolUse(11.1, "Edit", "file_path", "/work/api/server.go"),
+	)
+	writeSession("/work/web", "s3",
+		prompt(8, "Update the landing page"),
+		toolUse(8.5, "NotebookEdit", "notebook_path", "/elsewhere/analysis.ipynb"),
+	)
+	writeSession("/work/old", "s4", prompt(-30, "Last week"))
+
+	digests, err := BuildDigest(root, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("BuildDigest failed: %v", err)
+	}
+	if len(digests) != 2 {
+		t.Fatalf("got %d projects, want 2: %+v", len(digests), digests)
+	}
+
+	api := digests[0]
+	if api.CWD != "/work/api" || api.Sessions != 2 || api.ToolCalls != 4 {
+		t.Errorf("api digest = %+v", api)
+	}
+	if strings.Join(api.Tasks, "|") != "Add rate limiting|Fix the flaky test" {
+		t.Errorf("Tasks = %q", api.Tasks)
+	}
+	if strings.Join(api.FilesChanged, ",") != "limit.go,server.go" {
+		t.Errorf("FilesChanged = %q", api.FilesChanged)
+	}
+	if len(api.Completed) != 1 || api.Completed[0] != "Add limiter" {
+		t.Errorf("Completed = %q, want only the task finished in range", api.Completed)
+	}
+	if len(api.Open) != 1 || api.Open[0] != "Document it" {
+		t.Errorf("Open = %q", api.Open)
+	}
+	if !api.Last.Equal(day.Add(11*time.Hour + 6*time.Minute)) {
+		t.Errorf("Last = %v", api.Last)
+	}
+
+	web := digests[1]
+	if web.CWD != "/work/web" || len(web.FilesChanged) != 1 || web.FilesChanged[0] != "/elsewhere/analysis.ipynb" {
+		t.Errorf("web digest = %+v", web)
+	}
+}
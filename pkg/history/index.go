@@ -0,0 +1,96 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// SessionMeta is what the user says about a session with /rename and /tag
+type SessionMeta struct {
+	Name     string   `json:"name,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Favorite bool     `json:"favorite,omitempty"`
+}
+
+// HasTag reports whether the session is tagged tag, ignoring case
+func (m SessionMeta) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFile holds the metadata of a project's sessions, by session ID
+const indexFile = "sessions-index.json"
+
+func indexPath(root, cwd string) string {
+	return filepath.Join(ProjectDir(root, cwd), indexFile)
+}
+
+// LoadSessionIndex returns the metadata of the sessions stored for cwd, by
+// session ID
+func LoadSessionIndex(root, cwd string) (map[string]SessionMeta, error) {
+	data, err := os.ReadFile(indexPath(root, cwd))
+	if os.IsNotExist(err) {
+		return map[string]SessionMeta{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]SessionMeta{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// UpdateSessionMeta changes the metadata of session id and returns the result.
+// Tags are kept sorted and without duplicates.
+func UpdateSessionMeta(root, cwd, id string, update func(meta *SessionMeta)) (SessionMeta, error) {
+	path := indexPath(root, cwd)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return SessionMeta{}, err
+	}
+	var meta SessionMeta
+	err := config.WithFileLock(path, func() error {
+		index, err := LoadSessionIndex(root, cwd)
+		if err != nil {
+			// A broken index only loses names and tags; start over
+			index = map[string]SessionMeta{}
+		}
+		meta = index[id]
+		update(&meta)
+		meta.Tags = uniqueTags(meta.Tags)
+		if meta.Name == "" && len(meta.Tags) == 0 && !meta.Favorite {
+			delete(index, id)
+		} else {
+			index[id] = meta
+		}
+		data, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return err
+		}
+		return config.WriteFileAtomic(path, data, 0644)
+	})
+	return meta, err
+}
+
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" && !seen[strings.ToLower(t)] {
+			seen[strings.ToLower(t)] = true
+			unique = append(unique, t)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+func TestSessionIndexNamesAndTags(t *testing.T) {
+	root := t.TempDir()
+	cwd := "/work/project"
+	sm, err := NewSessionManagerInRoot(root, cwd)
+	if err != nil {
+		t.Fatalf("NewSessionManagerInRoot failed: %v", err)
+	}
+	if err := sm.Append(llm.RoleUser, llm.Message{Role: llm.RoleUser, Content: "Refactor auth"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	meta, err := UpdateSessionMeta(root, cwd, sm.SessionID, func(m *SessionMeta) {
+		m.Name = "auth cleanup"
+		m.Tags = append(m.Tags, "refactor", "auth", "Refactor")
+		m.Favorite = true
+	})
+	if err != nil {
+		t.Fatalf("UpdateSessionMeta failed: %v", err)
+	}
+	if len(meta.Tags) != 2 || meta.Tags[0] != "auth" || meta.Tags[1] != "refactor" {
+		t.Errorf("tags = %q", meta.Tags)
+	}
+
+	info, err := FindSession(root, cwd, "Auth Cleanup")
+	if err != nil {
+		t.Fatalf("FindSession by name failed: %v", err)
+	}
+	if info.ID != sm.SessionID || !info.Meta.HasTag("REFACTOR") {
+		t.Errorf("info = %+v", info)
+	}
+	if got := info.Title(); got != "★ auth cleanup #auth #refactor" {
+		t.Errorf("Title() = %q", got)
+	}
+
+	// Clearing everything drops the entry
+	if _, err := UpdateSessionMeta(root, cwd, sm.SessionID, func(m *SessionMeta) { *m = SessionMeta{} }); err != nil {
+		t.Fatalf("UpdateSessionMeta failed: %v", err)
+	}
+	if index, err := LoadSessionIndex(root, cwd); err != nil || len(index) != 0 {
+		t.Errorf("index = %v, %v", index, err)
+	}
+	if _, err := os.Stat(indexPath(root, cwd)); err != nil {
+		t.Errorf("index file: %v", err)
+	}
+}
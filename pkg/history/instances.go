@@ -0,0 +1,80 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Instance is a running john process registered in a workspace
+type Instance struct {
+	PID       int       `json:"pid"`
+	SessionID string    `json:"sessionId"`
+	Started   time.Time `json:"started"`
+}
+
+func instancesDir(root, cwd string) string {
+	return filepath.Join(ProjectDir(root, cwd), "instances")
+}
+
+// RegisterInstance records this process as active in cwd and returns the
+// other live instances there. Entries of processes that are gone are removed.
+// Call release on exit.
+func RegisterInstance(root, cwd, sessionID string) (others []Instance, release func(), err error) {
+	dir := instancesDir(root, cwd)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create instances dir: %w", err)
+	}
+
+	others = ActiveInstances(root, cwd)
+
+	self := Instance{PID: os.Getpid(), SessionID: sessionID, Started: time.Now()}
+	data, _ := json.Marshal(self)
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", self.PID))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to register instance: %w", err)
+	}
+	return others, func() { os.Remove(path) }, nil
+}
+
+// ActiveInstances lists the other live john processes registered in cwd,
+// oldest first
+func ActiveInstances(root, cwd string) []Instance {
+	paths, _ := filepath.Glob(filepath.Join(instancesDir(root, cwd), "*.json"))
+	var active []Instance
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var inst Instance
+		if err := json.Unmarshal(data, &inst); err != nil || !processAlive(inst.PID) {
+			os.Remove(path)
+			continue
+		}
+		if inst.PID != os.Getpid() {
+			active = append(active, inst)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Started.Before(active[j].Started) })
+	return active
+}
+
+// processAlive reports whether pid is a running process. Where signal 0 is
+// not supported the process is treated as gone.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
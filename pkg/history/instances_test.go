@@ -0,0 +1,51 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegisterInstance(t *testing.T) {
+	root := t.TempDir()
+	cwd := "/work/project"
+
+	// A live process (the test's parent) and one that has exited
+	dir := instancesDir(root, cwd)
+	os.MkdirAll(dir, 0755)
+	for _, inst := range []Instance{
+		{PID: os.Getppid(), SessionID: "live", Started: time.Now()},
+		{PID: 1 << 30, SessionID: "gone", Started: time.Now()},
+	} {
+		data, _ := json.Marshal(inst)
+		os.WriteFile(filepath.Join(dir, inst.SessionID+".json"), data, 0644)
+	}
+
+	others, release, err := RegisterInstance(root, cwd, "mine")
+	if err != nil {
+		t.Fatalf("RegisterInstance failed: %v", err)
+	}
+	if len(others) != 1 || others[0].SessionID != "live" {
+		t.Errorf("Expected only the live instance, got %+v", others)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.json")); !os.IsNotExist(err) {
+		t.Error("Expected the stale entry to be removed")
+	}
+
+	// This process is not reported to itself
+	if active := ActiveInstances(root, cwd); len(active) != 1 {
+		t.Errorf("Expected 1 other instance, got %+v", active)
+	}
+
+	self := filepath.Join(dir, fmt.Sprintf("%d.json", os.Getpid()))
+	if _, err := os.Stat(self); err != nil {
+		t.Errorf("Expected this instance to be registered: %v", err)
+	}
+	release()
+	if _, err := os.Stat(self); !os.IsNotExist(err) {
+		t.Error("Expected release to remove the registration")
+	}
+}
@@ -0,0 +1,447 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+// SessionInfo describes a stored session for listing and picking
+type SessionInfo struct {
+	ID          string
+	FilePath    string
+	ModTime     time.Time
+	FirstPrompt string
+	Meta        SessionMeta // Name, tags and favorite from the session index
+}
+
+// Title describes the session in lists: its name if it has one, otherwise
+// its first prompt, with a star for favorites and its tags
+func (s SessionInfo) Title() string {
+	title := s.Meta.Name
+	if title == "" {
+		title = s.FirstPrompt
+	}
+	if title == "" {
+		title = "(no prompt)"
+	}
+	if s.Meta.Favorite {
+		title = "★ " + title
+	}
+	for _, tag := range s.Meta.Tags {
+		title += " #" + tag
+	}
+	return title
+}
+
+// Transcript is the state reconstructed from a session file
+type Transcript struct {
+	Messages []llm.Message
+	Todos    json.RawMessage // Latest todo list snapshot, nil if none was recorded
+	Pins     json.RawMessage // Latest pinned content, nil if none was recorded
+	Model    string          // API model of the last assistant message
+	LastUUID string
+}
+
+// Compaction records that the conversation so far, except its Kept most
+// recent messages, was replaced by a summary prompt and the reply to it
+type Compaction struct {
+	Summary string `json:"summary"`
+	Reply   string `json:"reply"`
+	Kept    int    `json:"kept"`
+}
+
+// AppendTodos records a snapshot of the todo list. Snapshots are side events:
+// they do not become the parent of the next message.
+func (sm *SessionManager) AppendTodos(todos interface{}) error {
+	return sm.writeEvent(sm.sideEvent(EventTypeTodos, func(e *SessionEvent) { e.Todos = todos }))
+}
+
+// AppendError records a provider or tool error as a side event, for
+// troubleshooting after the fact
+func (sm *SessionManager) AppendError(record interface{}) error {
+	return sm.writeEvent(sm.sideEvent(EventTypeError, func(e *SessionEvent) { e.Error = record }))
+}
+
+// AppendPins records the pinned content after it changed. Like todos, pins
+// are a side event.
+func (sm *SessionManager) AppendPins(pins interface{}) error {
+	return sm.writeEvent(sm.sideEvent(EventTypePins, func(e *SessionEvent) { e.Pins = pins }))
+}
+
+// AppendCompaction records a compaction, with the pins it carried over, so
+// resuming replays it instead of the messages it replaced
+func (sm *SessionManager) AppendCompaction(c Compaction, pins interface{}) error {
+	return sm.writeEvent(sm.sideEvent(EventTypeCompaction, func(e *SessionEvent) {
+		e.Compaction = &c
+		e.Pins = pins
+	}))
+}
+
+// sideEvent returns an event, filled in by set, that does not become the
+// parent of the next message
+func (sm *SessionManager) sideEvent(eventType string, set func(e *SessionEvent)) SessionEvent {
+	event := SessionEvent{
+		Type:       eventType,
+		UUID:       uuid.New().String(),
+		ParentUUID: sm.CurrentUUID,
+		SessionID:  sm.SessionID,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339Nano),
+		CWD:        sm.CWD,
+	}
+	set(&event)
+	return event
+}
+
+func (sm *SessionManager) writeEvent(event SessionEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if line, err = encodeLine(line); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(sm.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// ListSessions returns the sessions stored for cwd, newest first
+func ListSessions(root string, cwd string) ([]SessionInfo, error) {
+	entries, err := os.ReadDir(ProjectDir(root, cwd))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	// Sessions list without their names rather than not at all
+	index, _ := LoadSessionIndex(root, cwd)
+
+	var sessions []SessionInfo
+	for _, entry := range entries {
+		id, ok := sessionFileID(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(ProjectDir(root, cwd), entry.Name())
+		sessions = append(sessions, SessionInfo{
+			ID:          id,
+			FilePath:    path,
+			ModTime:     info.ModTime(),
+			FirstPrompt: firstPrompt(path),
+			Meta:        index[id],
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ModTime.After(sessions[j].ModTime)
+	})
+	return sessions, nil
+}
+
+// FindSession locates a session for cwd by ID, unique ID prefix or name
+func FindSession(root string, cwd string, id string) (*SessionInfo, error) {
+	sessions, err := ListSessions(root, cwd)
+	if err != nil {
+		return nil, err
+	}
+	var match *SessionInfo
+	for i := range sessions {
+		if sessions[i].ID == id {
+			return &sessions[i], nil
+		}
+	}
+	for i := range sessions {
+		if sessions[i].Meta.Name != "" && strings.EqualFold(sessions[i].Meta.Name, id) {
+			return &sessions[i], nil
+		}
+	}
+	for i := range sessions {
+		if strings.HasPrefix(sessions[i].ID, id) {
+			if match != nil {
+				return nil, fmt.Errorf("session ID %q is ambiguous", id)
+			}
+			match = &sessions[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("session %q not found", id)
+	}
+	return match, nil
+}
+
+// ResumeSession reopens a session file so new messages are appended to it,
+// and returns the conversation stored so far. New messages are logged with
+// the session's last model until SetModel changes it. A session compressed
+// by retention is unpacked first.
+func ResumeSession(info SessionInfo, cwd string) (*SessionManager, *Transcript, error) {
+	transcript, err := LoadTranscript(info.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := restoreSession(info.FilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unpack session: %w", err)
+	}
+
+	sm := &SessionManager{
+		SessionID:    info.ID,
+		CurrentUUID:  transcript.LastUUID,
+		FilePath:     path,
+		CWD:          cwd,
+		CurrentModel: transcript.Model,
+	}
+	return sm, transcript, nil
+}
+
+// storedEvent mirrors SessionEvent with raw payloads for decoding
+type storedEvent struct {
+	Type       string          `json:"type"`
+	UUID       string          `json:"uuid"`
+	SessionID  string          `json:"sessionId"`
+	Timestamp  string          `json:"timestamp"`
+	CWD        string          `json:"cwd"`
+	Message    json.RawMessage `json:"message"`
+	Todos      json.RawMessage `json:"todos"`
+	Pins       json.RawMessage `json:"pins"`
+	Compaction *Compaction     `json:"compaction"`
+}
+
+type storedMessage struct {
+	Role        string          `json:"role"`
+	Model       string          `json:"model"`
+	Content     json.RawMessage `json:"content"`
+	RequestID   string          `json:"requestId"`
+	RequestHash string          `json:"requestHash"`
+	Interrupted bool            `json:"interrupted"`
+}
+
+type storedBlock struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text"`
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Input     map[string]interface{} `json:"input"`
+	ToolUseID string                 `json:"tool_use_id"`
+	Content   json.RawMessage        `json:"content"` // String for tool_result, structured for server tool results
+	IsError   bool                   `json:"is_error"`
+	MediaType string                 `json:"media_type"` // artifact
+	Path      string                 `json:"path"`       // artifact
+	FileID    string                 `json:"file_id"`    // artifact
+	Source    map[string]string      `json:"source"`
+	raw       json.RawMessage
+}
+
+// LoadTranscript parses a session JSONL file back into messages
+func LoadTranscript(path string) (*Transcript, error) {
+	f, err := openSession(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+	defer f.Close()
+
+	transcript := &Transcript{}
+	toolNames := make(map[string]string) // tool_use id -> tool name
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event storedEvent
+		if err := json.Unmarshal(decodeLine(scanner.Bytes()), &event); err != nil {
+			continue // Skip corrupt lines rather than losing the whole session
+		}
+
+		if event.Type == EventTypeTodos {
+			transcript.Todos = event.Todos
+			continue
+		}
+		if event.Type == EventTypeError {
+			continue
+		}
+		if event.Type == EventTypePins || event.Type == EventTypeCompaction {
+			transcript.Pins = event.Pins
+			if c := event.Compaction; c != nil {
+				kept := transcript.Messages[max(0, len(transcript.Messages)-c.Kept):]
+				transcript.Messages = append([]llm.Message{
+					{Role: llm.RoleUser, Content: c.Summary},
+					{Role: llm.RoleAssistant, Content: c.Reply},
+				}, kept...)
+			}
+			continue
+		}
+
+		var msg storedMessage
+		if err := json.Unmarshal(event.Message, &msg); err != nil {
+			continue
+		}
+
+		// Plain string content is a simple user message
+		var text string
+		if err := json.Unmarshal(msg.Content, &text); err == nil {
+			transcript.Messages = append(transcript.Messages, llm.Message{Role: llm.Role(msg.Role), Content: text})
+			transcript.LastUUID = event.UUID
+			continue
+		}
+
+		var rawBlocks []json.RawMessage
+		if err := json.Unmarshal(msg.Content, &rawBlocks); err != nil {
+			continue
+		}
+		blocks := make([]storedBlock, 0, len(rawBlocks))
+		for _, raw := range rawBlocks {
+			var b storedBlock
+			if err := json.Unmarshal(raw, &b); err == nil {
+				b.raw = raw
+				blocks = append(blocks, b)
+			}
+		}
+
+		switch event.Type {
+		case EventTypeAssistant:
+			if msg.Model != "" {
+				transcript.Model = msg.Model
+			}
+			out := llm.Message{Role: llm.RoleAssistant, RequestID: msg.RequestID, RequestHash: msg.RequestHash, Interrupted: msg.Interrupted}
+			// Text and server tool blocks in order, kept when a server
+			// tool was used
+			var ordered []json.RawMessage
+			usedServerTools := false
+			for _, b := range blocks {
+				switch b.Type {
+				case "text":
+					out.Content += b.Text
+					ordered = append(ordered, b.raw)
+				case "tool_use":
+					toolNames[b.ID] = b.Name
+					out.ToolCalls = append(out.ToolCalls, llm.ToolCall{ID: b.ID, Name: b.Name, Args: b.Input})
+				case "artifact":
+					out.Artifacts = append(out.Artifacts, llm.Artifact{Name: b.Name, MediaType: b.MediaType, Path: b.Path, FileID: b.FileID})
+				default:
+					if b.Type == "server_tool_use" || strings.HasSuffix(b.Type, "_tool_result") {
+						ordered = append(ordered, b.raw)
+						usedServerTools = true
+					}
+				}
+			}
+			if usedServerTools {
+				out.ServerBlocks = ordered
+			}
+			transcript.Messages = append(transcript.Messages, out)
+		case EventTypeUser:
+			if len(blocks) > 0 && blocks[0].Type == "tool_result" {
+				for _, b := range blocks {
+					var content string
+					json.Unmarshal(b.Content, &content)
+					transcript.Messages = append(transcript.Messages, llm.Message{
+						Role: llm.RoleTool,
+						ToolResult: &llm.ToolResult{
+							ToolCallID: b.ToolUseID,
+							ToolName:   toolNames[b.ToolUseID],
+							Content:    content,
+							IsError:    b.IsError,
+						},
+					})
+				}
+				break
+			}
+			out := llm.Message{Role: llm.RoleUser}
+			for _, b := range blocks {
+				switch b.Type {
+				case "text":
+					out.Content += b.Text
+				case "image":
+					if path := imagePathFromSource(b.Source); path != "" {
+						out.Images = append(out.Images, path)
+					}
+				}
+			}
+			transcript.Messages = append(transcript.Messages, out)
+		}
+		transcript.LastUUID = event.UUID
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	return transcript, nil
+}
+
+// imagePathFromSource recovers the image path from the placeholder data
+// written by Append.
+func imagePathFromSource(source map[string]string) string {
+	data := source["data"]
+	const prefix = "...image path: "
+	if !strings.HasPrefix(data, prefix) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(data, prefix), "...")
+}
+
+// ShortID returns the first 8 characters of a session ID, as sessions are
+// listed, or all of an ID that is shorter
+func ShortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// firstPrompt returns the first user prompt of a session, for display
+func firstPrompt(path string) string {
+	f, err := openSession(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event storedEvent
+		if err := json.Unmarshal(decodeLine(scanner.Bytes()), &event); err != nil || event.Type != EventTypeUser {
+			continue
+		}
+		var msg storedMessage
+		if err := json.Unmarshal(event.Message, &msg); err != nil {
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(msg.Content, &text); err != nil {
+			var blocks []storedBlock
+			if err := json.Unmarshal(msg.Content, &blocks); err != nil || len(blocks) == 0 || blocks[0].Type != "text" {
+				continue
+			}
+			text = blocks[0].Text
+		}
+		// Drop injected reminders so the listing shows what the user typed
+		if i := strings.Index(text, "<system-reminder>"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(strings.ReplaceAll(text, "\n", " "))
+		if len(text) > 80 {
+			text = text[:80] + "..."
+		}
+		return text
+	}
+	return ""
+}
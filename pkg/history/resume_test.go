@@ -0,0 +1,105 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jbdamask/john-code/pkg/llm"
+)
+
+func TestResumeSessionRoundTrip(t *testing.T) {
+	root, err := os.MkdirTemp("", "history-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	cwd := "/work/project"
+	sm, err := NewSessionManagerInRoot(root, cwd)
+	if err != nil {
+		t.Fatalf("NewSessionManagerInRoot failed: %v", err)
+	}
+
+	sm.SetModel("claude-haiku-4-5-20251001")
+	messages := []llm.Message{
+		{Role: llm.RoleUser, Content: "List files"},
+		{Role: llm.RoleAssistant, Content: "Listing.", RequestID: "req_1", ToolCalls: []llm.ToolCall{{ID: "t1", Name: "Bash", Args: map[string]interface{}{"command": "ls"}}}},
+		{Role: llm.RoleTool, ToolResult: &llm.ToolResult{ToolCallID: "t1", ToolName: "Bash", Content: "a.go", IsError: true}},
+		{Role: llm.RoleAssistant, Content: "Searching. Found a.go", ServerBlocks: []json.RawMessage{
+			json.RawMessage(`{"type":"text","text":"Searching. "}`),
+			json.RawMessage(`{"type":"web_search_tool_result","tool_use_id":"s1","content":[{"type":"web_search_result","url":"https://go.dev"}]}`),
+			json.RawMessage(`{"type":"text","text":"Found a.go","citations":[{"type":"web_search_result_location","url":"https://go.dev"}]}`),
+		}},
+	}
+	for _, msg := range messages {
+		if err := sm.Append(msg.Role, msg); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := sm.AppendTodos([]map[string]string{{"content": "Task", "status": "pending"}}); err != nil {
+		t.Fatalf("AppendTodos failed: %v", err)
+	}
+	if err := sm.AppendError(map[string]interface{}{"source": "provider", "statusCode": 529}); err != nil {
+		t.Fatalf("AppendError failed: %v", err)
+	}
+
+	sessions, err := ListSessions(root, cwd)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %d (%v)", len(sessions), err)
+	}
+	if sessions[0].FirstPrompt != "List files" {
+		t.Errorf("Unexpected first prompt %q", sessions[0].FirstPrompt)
+	}
+
+	info, err := FindSession(root, cwd, sm.SessionID[:8])
+	if err != nil {
+		t.Fatalf("FindSession failed: %v", err)
+	}
+
+	resumed, transcript, err := ResumeSession(*info, cwd)
+	if err != nil {
+		t.Fatalf("ResumeSession failed: %v", err)
+	}
+	if resumed.CurrentUUID != sm.CurrentUUID {
+		t.Errorf("Resumed parent %q, want %q", resumed.CurrentUUID, sm.CurrentUUID)
+	}
+	if resumed.CurrentModel != "claude-haiku-4-5-20251001" {
+		t.Errorf("Resumed model %q, want the session's last model", resumed.CurrentModel)
+	}
+	if len(transcript.Messages) != len(messages) {
+		t.Fatalf("Expected %d messages, got %d", len(messages), len(transcript.Messages))
+	}
+	if tc := transcript.Messages[1].ToolCalls; len(tc) != 1 || tc[0].Name != "Bash" || tc[0].Args["command"] != "ls" {
+		t.Errorf("Tool call not restored: %+v", tc)
+	}
+	if id := transcript.Messages[1].RequestID; id != "req_1" {
+		t.Errorf("Request ID not restored: %q", id)
+	}
+	if tr := transcript.Messages[2].ToolResult; tr == nil || tr.ToolName != "Bash" || tr.Content != "a.go" || !tr.IsError {
+		t.Errorf("Tool result not restored: %+v", tr)
+	}
+	if msg := transcript.Messages[3]; msg.Content != "Searching. Found a.go" || len(msg.ServerBlocks) != 3 ||
+		!strings.Contains(string(msg.ServerBlocks[1]), "web_search_tool_result") || !strings.Contains(string(msg.ServerBlocks[2]), "citations") {
+		t.Errorf("Server blocks not restored in order: %s", msg.ServerBlocks)
+	}
+
+	var todos []map[string]string
+	if err := json.Unmarshal(transcript.Todos, &todos); err != nil || len(todos) != 1 {
+		t.Errorf("Todos not restored: %s", transcript.Todos)
+	}
+}
+
+func TestShortID(t *testing.T) {
+	for id, want := range map[string]string{
+		"0123456789abcdef": "01234567",
+		"01234567":         "01234567",
+		"abc":              "abc",
+		"":                 "",
+	} {
+		if got := ShortID(id); got != want {
+			t.Errorf("ShortID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
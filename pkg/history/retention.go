@@ -0,0 +1,389 @@
+package history
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// compressedExt marks a session file gzipped by retention. It is read like
+// any other and unpacked again when resumed.
+const compressedExt = ".jsonl.gz"
+
+// pruneMarker records when the daily automatic prune last ran
+const pruneMarker = "last-prune"
+
+// RetentionPolicy bounds the session history kept under a data root. A zero
+// field does not limit.
+type RetentionPolicy struct {
+	MaxSessions   int // Per project
+	MaxAge        time.Duration
+	MaxTotalBytes int64         // All projects together
+	CompressAfter time.Duration // Older sessions are gzipped and their tool-result files removed
+}
+
+// defaultCompressAfterDays is when sessions are gzipped unless the settings
+// say otherwise
+const defaultCompressAfterDays = 14
+
+// RetentionFromSettings turns the retention settings into a policy. Sessions
+// are only removed under limits the user set; compression is on by default.
+func RetentionFromSettings(s config.RetentionSettings) RetentionPolicy {
+	limit := func(value int) int { return max(value, 0) }
+	compressAfter := s.CompressAfterDays
+	switch {
+	case compressAfter < 0:
+		compressAfter = 0
+	case compressAfter == 0:
+		compressAfter = defaultCompressAfterDays
+	}
+	day := 24 * time.Hour
+	return RetentionPolicy{
+		MaxSessions:   limit(s.MaxSessions),
+		MaxAge:        time.Duration(limit(s.MaxAgeDays)) * day,
+		MaxTotalBytes: int64(limit(s.MaxTotalMB)) << 20,
+		CompressAfter: time.Duration(compressAfter) * day,
+	}
+}
+
+// PruneReport says what PruneSessions removed and compressed, or would
+// have in a dry run
+type PruneReport struct {
+	Removed    []string // Session files
+	Compressed []string
+	Crumbs     int   // Tool-result directories of sessions that no longer exist
+	Freed      int64 // Bytes; in a dry run, not counting compression
+	Kept       int
+	KeptBytes  int64
+}
+
+// storedSession is a session file found while pruning
+type storedSession struct {
+	dir       string // Project directory
+	id        string
+	path      string
+	modTime   time.Time
+	size      int64 // File plus its tool-result directory
+	protected bool  // Favorite, or open in a running instance
+}
+
+func (s *storedSession) crumbs() string {
+	return filepath.Join(s.dir, s.id)
+}
+
+// sessionFileID returns the session ID of a session file name
+func sessionFileID(name string) (string, bool) {
+	for _, ext := range []string{compressedExt, ".jsonl"} {
+		if id, ok := strings.CutSuffix(name, ext); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// openSession opens a session file for reading, compressed or not
+func openSession(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, compressedExt) {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+// PruneSessions applies policy to every project under root. Per project,
+// sessions beyond the newest MaxSessions and those older than MaxAge are
+// removed with their tool-result files; sessions older than CompressAfter
+// are gzipped. Then the oldest sessions of any project go until the rest fit
+// in MaxTotalBytes. Favorites and sessions open in a running instance are
+// kept. With dryRun nothing is changed.
+func PruneSessions(root string, policy RetentionPolicy, now time.Time, dryRun bool) (*PruneReport, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "projects", "*"))
+	if err != nil {
+		return nil, err
+	}
+	report := &PruneReport{}
+	var kept []*storedSession
+	for _, dir := range dirs {
+		sessions, err := projectSessions(dir, report, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		var removed []string
+		count := 0
+		for _, s := range sessions {
+			tooMany := policy.MaxSessions > 0 && count >= policy.MaxSessions
+			tooOld := policy.MaxAge > 0 && now.Sub(s.modTime) > policy.MaxAge
+			if !s.protected && (tooMany || tooOld) {
+				if err := removeSession(s, report, dryRun); err != nil {
+					return nil, err
+				}
+				removed = append(removed, s.id)
+				continue
+			}
+			count++
+			if policy.CompressAfter > 0 && now.Sub(s.modTime) > policy.CompressAfter && !s.protected && !strings.HasSuffix(s.path, compressedExt) {
+				if err := compressSession(s, report, dryRun); err != nil {
+					return nil, err
+				}
+			}
+			kept = append(kept, s)
+		}
+		if !dryRun {
+			if err := forgetSessions(dir, removed); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Oldest first, whichever project they belong to
+	sort.Slice(kept, func(i, j int) bool { return kept[i].modTime.Before(kept[j].modTime) })
+	var total int64
+	for _, s := range kept {
+		total += s.size
+	}
+	removed := make(map[string][]string)
+	for i := 0; i < len(kept) && policy.MaxTotalBytes > 0 && total > policy.MaxTotalBytes; {
+		s := kept[i]
+		if s.protected {
+			i++
+			continue
+		}
+		if err := removeSession(s, report, dryRun); err != nil {
+			return nil, err
+		}
+		total -= s.size
+		removed[s.dir] = append(removed[s.dir], s.id)
+		kept = append(kept[:i], kept[i+1:]...)
+	}
+	if !dryRun {
+		for dir, ids := range removed {
+			if err := forgetSessions(dir, ids); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report.Kept, report.KeptBytes = len(kept), total
+	return report, nil
+}
+
+// projectSessions lists the sessions of a project directory, newest first,
+// and removes tool-result directories left by sessions already gone
+func projectSessions(dir string, report *PruneReport, dryRun bool) ([]*storedSession, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]SessionMeta{}
+	if data, err := os.ReadFile(filepath.Join(dir, indexFile)); err == nil {
+		json.Unmarshal(data, &index)
+	}
+	live := liveSessions(dir)
+
+	files := make(map[string]bool)
+	var sessions []*storedSession
+	for _, entry := range entries {
+		id, ok := sessionFileID(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[id] = true
+		s := &storedSession{dir: dir, id: id, path: filepath.Join(dir, entry.Name()), modTime: info.ModTime(), size: info.Size(),
+			protected: index[id].Favorite || live[id]}
+		s.size += dirSize(s.crumbs())
+		sessions = append(sessions, s)
+	}
+	for _, entry := range entries {
+		// Only directories named like a session are crumbs; instances/ is not
+		if _, err := uuid.Parse(entry.Name()); err != nil || !entry.IsDir() || files[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		report.Crumbs++
+		report.Freed += dirSize(path)
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].modTime.After(sessions[j].modTime) })
+	return sessions, nil
+}
+
+// liveSessions returns the sessions open in running instances of a project
+func liveSessions(dir string) map[string]bool {
+	live := make(map[string]bool)
+	paths, _ := filepath.Glob(filepath.Join(dir, "instances", "*.json"))
+	for _, path := range paths {
+		var inst Instance
+		if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &inst) == nil && processAlive(inst.PID) {
+			live[inst.SessionID] = true
+		}
+	}
+	return live
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+func removeSession(s *storedSession, report *PruneReport, dryRun bool) error {
+	report.Removed = append(report.Removed, s.path)
+	report.Freed += s.size
+	if dryRun {
+		return nil
+	}
+	if err := os.RemoveAll(s.crumbs()); err != nil {
+		return err
+	}
+	return os.Remove(s.path)
+}
+
+// compressSession gzips a session file, keeping its modification time so
+// its age still counts, and removes its tool-result files
+func compressSession(s *storedSession, report *PruneReport, dryRun bool) error {
+	report.Compressed = append(report.Compressed, s.path)
+	if dryRun {
+		return nil
+	}
+	in, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	target := strings.TrimSuffix(s.path, ".jsonl") + compressedExt
+	tmp := target + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, s.modTime, s.modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compress %s: %w", s.path, err)
+	}
+	os.Remove(s.path)
+	// Artifacts the model produced are kept; the directory goes if empty
+	os.RemoveAll(filepath.Join(s.crumbs(), "tool-results"))
+	os.Remove(s.crumbs())
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	report.Freed += s.size - info.Size()
+	s.path, s.size = target, info.Size()
+	return nil
+}
+
+// forgetSessions drops removed sessions from the project's index
+func forgetSessions(dir string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	path := filepath.Join(dir, indexFile)
+	return config.WithFileLock(path, func() error {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		index := map[string]SessionMeta{}
+		if err != nil || json.Unmarshal(data, &index) != nil {
+			return nil
+		}
+		for _, id := range ids {
+			delete(index, id)
+		}
+		data, err = json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return err
+		}
+		return config.WriteFileAtomic(path, data, 0644)
+	})
+}
+
+// restoreSession unpacks a compressed session so it can be appended to,
+// and returns the path of the plain file
+func restoreSession(path string) (string, error) {
+	if !strings.HasSuffix(path, compressedExt) {
+		return path, nil
+	}
+	in, err := openSession(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	target := strings.TrimSuffix(path, compressedExt) + ".jsonl"
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(target)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return target, os.Remove(path)
+}
+
+// PruneDue reports whether the daily automatic prune should run, and if so
+// marks it as run
+func PruneDue(root string, now time.Time) bool {
+	path := filepath.Join(root, pruneMarker)
+	if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) < 24*time.Hour {
+		return false
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return false
+	}
+	return os.WriteFile(path, []byte(now.Format(time.RFC3339)+"\n"), 0644) == nil
+}
@@ -0,0 +1,125 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+func TestPruneSessions(t *testing.T) {
+	root := t.TempDir()
+	cwd := "/work/project"
+	dir := ProjectDir(root, cwd)
+	os.MkdirAll(filepath.Join(dir, "instances"), 0755)
+	now := time.Now()
+
+	// Sessions 0 to 5, each a day older than the one before
+	ids := make([]string, 6)
+	for i := range ids {
+		ids[i] = uuid.New().String()
+		path := filepath.Join(dir, ids[i]+".jsonl")
+		line, _ := json.Marshal(SessionEvent{Type: EventTypeUser, SessionID: ids[i], Message: map[string]string{"role": "user", "content": "prompt " + ids[i][:4]}})
+		os.WriteFile(path, append(line, '\n'), 0644)
+		os.MkdirAll(filepath.Join(dir, ids[i], "tool-results"), 0755)
+		os.WriteFile(filepath.Join(dir, ids[i], "tool-results", "out.txt"), []byte(strings.Repeat("x", 1000)), 0644)
+		age := now.Add(-time.Duration(i) * 24 * time.Hour)
+		os.Chtimes(path, age, age)
+	}
+	UpdateSessionMeta(root, cwd, ids[5], func(m *SessionMeta) { m.Favorite = true })
+	UpdateSessionMeta(root, cwd, ids[4], func(m *SessionMeta) { m.Name = "old" })
+	orphan := uuid.New().String()
+	os.MkdirAll(filepath.Join(dir, orphan, "tool-results"), 0755)
+
+	policy := RetentionPolicy{MaxSessions: 4, CompressAfter: 36 * time.Hour}
+	dry, err := PruneSessions(root, policy, now, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(dry.Removed) != 1 || len(dry.Compressed) != 2 || dry.Crumbs != 1 {
+		t.Fatalf("dry run report %+v", dry)
+	}
+	if _, err := os.Stat(dry.Removed[0]); err != nil {
+		t.Fatal("dry run removed a file")
+	}
+
+	report, err := PruneSessions(root, policy, now, false)
+	if err != nil {
+		t.Fatalf("PruneSessions: %v", err)
+	}
+	// The fifth newest goes; the favorite stays though it is the oldest
+	if len(report.Removed) != 1 || !strings.Contains(report.Removed[0], ids[4]) || report.Kept != 5 {
+		t.Errorf("report %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ids[4])); !os.IsNotExist(err) {
+		t.Error("tool results of a removed session kept")
+	}
+	if _, err := os.Stat(filepath.Join(dir, orphan)); !os.IsNotExist(err) {
+		t.Error("orphaned tool results kept")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "instances")); err != nil {
+		t.Error("instances directory removed")
+	}
+	if index, _ := LoadSessionIndex(root, cwd); index[ids[4]].Name != "" || !index[ids[5]].Favorite {
+		t.Errorf("index %+v", index)
+	}
+
+	// Sessions 2 and 3 are compressed, still listed, and unpacked on resume
+	sessions, _ := ListSessions(root, cwd)
+	if len(sessions) != 5 || !strings.HasSuffix(sessions[2].FilePath, compressedExt) || sessions[2].FirstPrompt != "prompt "+ids[2][:4] {
+		t.Fatalf("sessions after pruning: %+v", sessions)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ids[2])); !os.IsNotExist(err) {
+		t.Error("tool results of a compressed session kept")
+	}
+	sm, transcript, err := ResumeSession(sessions[2], cwd)
+	if err != nil || len(transcript.Messages) != 1 || sm.FilePath != filepath.Join(dir, ids[2]+".jsonl") {
+		t.Fatalf("ResumeSession: %v, %+v", err, sm)
+	}
+	if info, err := os.Stat(sm.FilePath); err != nil || info.Size() == 0 {
+		t.Errorf("unpacked session: %v", err)
+	}
+
+	// Over the size limit the oldest unprotected sessions go first
+	report, err = PruneSessions(root, RetentionPolicy{MaxTotalBytes: 2500}, now, false)
+	if err != nil {
+		t.Fatalf("PruneSessions: %v", err)
+	}
+	if report.KeptBytes > 2500 {
+		t.Errorf("kept %d bytes", report.KeptBytes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ids[0]+".jsonl")); err != nil {
+		t.Error("newest session removed before older ones")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ids[5]+".jsonl")); err != nil {
+		t.Error("favorite removed")
+	}
+}
+
+func TestPruneDue(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	if !PruneDue(root, now) || PruneDue(root, now.Add(time.Hour)) {
+		t.Error("prune not due once a day")
+	}
+	os.Chtimes(filepath.Join(root, pruneMarker), now.Add(-25*time.Hour), now.Add(-25*time.Hour))
+	if !PruneDue(root, now) {
+		t.Error("prune not due after a day")
+	}
+}
+
+func TestRetentionOnlyRemovesSessionsUnderLimitsTheUserSet(t *testing.T) {
+	day := 24 * time.Hour
+	if got := RetentionFromSettings(config.RetentionSettings{}); got != (RetentionPolicy{CompressAfter: 14 * day}) {
+		t.Errorf("default policy = %+v", got)
+	}
+	got := RetentionFromSettings(config.RetentionSettings{MaxSessions: 50, MaxAgeDays: 30, MaxTotalMB: 1, CompressAfterDays: -1})
+	if got != (RetentionPolicy{MaxSessions: 50, MaxAge: 30 * day, MaxTotalBytes: 1 << 20}) {
+		t.Errorf("policy = %+v", got)
+	}
+}
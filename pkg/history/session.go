@@ -14,8 +14,12 @@ import (
 
 // EventType definitions
 const (
-	EventTypeUser      = "user"
-	EventTypeAssistant = "assistant"
+	EventTypeUser       = "user"
+	EventTypeAssistant  = "assistant"
+	EventTypeTodos      = "todos"
+	EventTypeError      = "error"
+	EventTypePins       = "pins"
+	EventTypeCompaction = "compaction"
 )
 
 // SessionEvent represents a line in the JSONL file
@@ -27,6 +31,10 @@ type SessionEvent struct {
 	Timestamp  string      `json:"timestamp"`
 	CWD        string      `json:"cwd"`
 	Message    interface{} `json:"message,omitempty"`
+	Todos      interface{} `json:"todos,omitempty"`
+	Error      interface{} `json:"error,omitempty"`
+	Pins       interface{} `json:"pins,omitempty"`
+	Compaction *Compaction `json:"compaction,omitempty"`
 }
 
 type SessionManager struct {
@@ -38,23 +46,36 @@ type SessionManager struct {
 }
 
 func NewSessionManager(cwd string) (*SessionManager, error) {
+	root, err := DefaultRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSessionManagerInRoot(root, cwd)
+}
+
+// DataDirEnv overrides the directory session data is stored under
+const DataDirEnv = "JOHN_DATA_DIR"
+
+// DefaultRoot returns the directory all session data is stored under:
+// $JOHN_DATA_DIR, or ~/.johncode.
+func DefaultRoot() (string, error) {
+	if dir := os.Getenv(DataDirEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home dir: %w", err)
+		return "", fmt.Errorf("failed to get home dir: %w", err)
 	}
+	return filepath.Join(homeDir, ".johncode"), nil
+}
 
+// NewSessionManagerInRoot creates a session manager that stores its project
+// directories under root instead of ~/.johncode.
+func NewSessionManagerInRoot(root string, cwd string) (*SessionManager, error) {
 	sessionID := uuid.New().String()
-	
-	// Sanitize CWD for path
-	// Replace / with - and remove leading - if any?
-	// Claude format: -Users-name-path
-	sanitized := strings.ReplaceAll(cwd, string(os.PathSeparator), "-")
-    // Ensure it starts with - if it was absolute
-    if !strings.HasPrefix(sanitized, "-") {
-        sanitized = "-" + sanitized
-    }
 
-	projectDir := filepath.Join(homeDir, ".johncode", "projects", sanitized)
+	projectDir := ProjectDir(root, cwd)
 	if err := os.MkdirAll(projectDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create project dir: %w", err)
 	}
@@ -70,6 +91,19 @@ func NewSessionManager(cwd string) (*SessionManager, error) {
 	}, nil
 }
 
+// ProjectDir returns the directory under root holding the sessions for cwd.
+func ProjectDir(root string, cwd string) string {
+	// Sanitize CWD for path
+	// Replace / with - and remove leading - if any?
+	// Claude format: -Users-name-path
+	sanitized := strings.ReplaceAll(cwd, string(os.PathSeparator), "-")
+    // Ensure it starts with - if it was absolute
+    if !strings.HasPrefix(sanitized, "-") {
+        sanitized = "-" + sanitized
+    }
+	return filepath.Join(root, "projects", sanitized)
+}
+
 // SetModel updates the current model for logging
 func (sm *SessionManager) SetModel(model string) {
 	sm.CurrentModel = model
@@ -99,6 +133,7 @@ func (sm *SessionManager) Append(role llm.Role, msg llm.Message) error {
                          "type": "tool_result",
                          "tool_use_id": msg.ToolResult.ToolCallID,
                          "content": msg.ToolResult.Content,
+                         "is_error": msg.ToolResult.IsError,
                      },
                  },
              }
@@ -145,7 +180,16 @@ func (sm *SessionManager) Append(role llm.Role, msg llm.Message) error {
         // Content can be text or tool_use blocks
         content := []map[string]interface{}{}
         
-        if msg.Content != "" {
+        // Server tool calls and results (e.g. web_search), with the text
+        // around them, are stored as-is and in order
+        for _, raw := range msg.ServerBlocks {
+            var block map[string]interface{}
+            if err := json.Unmarshal(raw, &block); err == nil {
+                content = append(content, block)
+            }
+        }
+        
+        if msg.Content != "" && !msg.ServerBlocksHaveText() {
             content = append(content, map[string]interface{}{
                 "type": "text",
                 "text": msg.Content,
@@ -160,12 +204,33 @@ func (sm *SessionManager) Append(role llm.Role, msg llm.Message) error {
                 "input": tc.Args,
             })
         }
+
+        // Images and files the model produced, by where they were saved
+        for _, art := range msg.Artifacts {
+            block := map[string]interface{}{"type": "artifact", "media_type": art.MediaType}
+            for key, value := range map[string]string{"name": art.Name, "path": art.Path, "file_id": art.FileID} {
+                if value != "" {
+                    block[key] = value
+                }
+            }
+            content = append(content, block)
+        }
         
-		messageObj = map[string]interface{}{
+		assistant := map[string]interface{}{
 			"role":    "assistant",
 			"content": content,
 			"model":   sm.CurrentModel,
 		}
+		if msg.RequestID != "" {
+			assistant["requestId"] = msg.RequestID
+		}
+		if msg.RequestHash != "" {
+			assistant["requestHash"] = msg.RequestHash
+		}
+		if msg.Interrupted {
+			assistant["interrupted"] = true
+		}
+		messageObj = assistant
 	} else if role == llm.RoleSystem {
         // We generally don't store system prompt as an event in the linked list in the same way?
         // Or maybe we do?
@@ -185,14 +250,7 @@ func (sm *SessionManager) Append(role llm.Role, msg llm.Message) error {
 	}
 
 	// Append to file
-	f, err := os.OpenFile(sm.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	encoder := json.NewEncoder(f)
-	if err := encoder.Encode(event); err != nil {
+	if err := sm.writeEvent(event); err != nil {
 		return err
 	}
 
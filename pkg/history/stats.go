@@ -0,0 +1,203 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/i18n"
+)
+
+// ToolStats accumulates the outcomes of one tool across all sessions
+type ToolStats struct {
+	Calls     int           `json:"calls"`
+	Failures  int           `json:"failures"`
+	Total     time.Duration `json:"totalDuration"`
+	Max       time.Duration `json:"maxDuration"`
+	LastError string        `json:"lastError,omitempty"`
+	LastUsed  time.Time     `json:"lastUsed"`
+	// Daily counts calls per day in the configured time zone, keyed
+	// 2006-01-02, for the last dailyDays days
+	Daily map[string]int `json:"daily,omitempty"`
+}
+
+// dailyDays is how many days of ToolStats.Daily are kept
+const dailyDays = 30
+
+// On returns the calls made on t's day in the configured time zone
+func (s *ToolStats) On(t time.Time) int {
+	return s.Daily[i18n.DayKey(t)]
+}
+
+// FailureRate returns the share of calls that failed, from 0 to 1
+func (s *ToolStats) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
+
+// Average returns the mean duration of a call
+func (s *ToolStats) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// ModelStats accumulates the latency of one model's requests across all
+// sessions
+type ModelStats struct {
+	Calls     int           `json:"calls"`
+	Failures  int           `json:"failures"`
+	Total     time.Duration `json:"totalDuration"`
+	Max       time.Duration `json:"maxDuration"`
+	Streamed  int           `json:"streamed"` // Calls that streamed text, so had a first token
+	TotalTTFT time.Duration `json:"totalTimeToFirstToken"`
+	LastUsed  time.Time     `json:"lastUsed"`
+}
+
+// Average returns the mean duration of a request
+func (s *ModelStats) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// AverageTTFT returns the mean time to the first streamed token, or 0 if no
+// request streamed text
+func (s *ModelStats) AverageTTFT() time.Duration {
+	if s.Streamed == 0 {
+		return 0
+	}
+	return s.TotalTTFT / time.Duration(s.Streamed)
+}
+
+// Stats is the usage data kept in <root>/stats.json
+type Stats struct {
+	Tools  map[string]*ToolStats  `json:"tools"`
+	Models map[string]*ModelStats `json:"models,omitempty"`
+}
+
+func statsPath(root string) string {
+	return filepath.Join(root, "stats.json")
+}
+
+// LoadStats reads the stats under root. A missing file gives empty stats.
+func LoadStats(root string) (*Stats, error) {
+	stats := &Stats{Tools: make(map[string]*ToolStats)}
+	data, err := os.ReadFile(statsPath(root))
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", statsPath(root), err)
+	}
+	if stats.Tools == nil {
+		stats.Tools = make(map[string]*ToolStats)
+	}
+	if stats.Models == nil {
+		stats.Models = make(map[string]*ModelStats)
+	}
+	return stats, nil
+}
+
+// updateStats applies update to the stats under root. The file is updated
+// under a lock, as every running instance records into it.
+func updateStats(root string, update func(stats *Stats)) error {
+	path := statsPath(root)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	return config.WithFileLock(path, func() error {
+		stats, err := LoadStats(root)
+		if err != nil {
+			// Start over rather than stop recording for good
+			stats = &Stats{Tools: make(map[string]*ToolStats), Models: make(map[string]*ModelStats)}
+		}
+		update(stats)
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		return config.WriteFileAtomic(path, data, 0644)
+	})
+}
+
+// RecordToolCall adds one call of tool to the stats under root. errMsg is
+// kept as the tool's last error when failed is set.
+func RecordToolCall(root, tool string, duration time.Duration, failed bool, errMsg string) error {
+	return updateStats(root, func(stats *Stats) {
+		s := stats.Tools[tool]
+		if s == nil {
+			s = &ToolStats{}
+			stats.Tools[tool] = s
+		}
+		s.Calls++
+		s.Total += duration
+		if duration > s.Max {
+			s.Max = duration
+		}
+		if failed {
+			s.Failures++
+			s.LastError = errMsg
+		}
+		s.LastUsed = time.Now()
+		if s.Daily == nil {
+			s.Daily = make(map[string]int)
+		}
+		s.Daily[i18n.DayKey(s.LastUsed)]++
+		oldest := i18n.DayKey(i18n.Day(s.LastUsed).AddDate(0, 0, -dailyDays+1))
+		for day := range s.Daily {
+			if day < oldest {
+				delete(s.Daily, day)
+			}
+		}
+	})
+}
+
+// RecordModelCall adds one request to model to the stats under root. ttft
+// is the time to the first streamed token, 0 when no text streamed.
+func RecordModelCall(root, model string, ttft, duration time.Duration, failed bool) error {
+	return updateStats(root, func(stats *Stats) {
+		s := stats.Models[model]
+		if s == nil {
+			s = &ModelStats{}
+			stats.Models[model] = s
+		}
+		s.Calls++
+		s.Total += duration
+		if duration > s.Max {
+			s.Max = duration
+		}
+		if ttft > 0 {
+			s.Streamed++
+			s.TotalTTFT += ttft
+		}
+		if failed {
+			s.Failures++
+		}
+		s.LastUsed = time.Now()
+	})
+}
+
+// ResetToolStats clears the tool stats under root
+func ResetToolStats(root string) error {
+	return updateStats(root, func(stats *Stats) {
+		stats.Tools = make(map[string]*ToolStats)
+	})
+}
+
+// ResetModelStats clears the model latency stats under root
+func ResetModelStats(root string) error {
+	return updateStats(root, func(stats *Stats) {
+		stats.Models = make(map[string]*ModelStats)
+	})
+}
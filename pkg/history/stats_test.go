@@ -0,0 +1,87 @@
+package history
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecordToolCall(t *testing.T) {
+	root := t.TempDir()
+
+	RecordToolCall(root, "Edit", 2*time.Second, false, "")
+	RecordToolCall(root, "Edit", 4*time.Second, true, "old_string not found")
+	RecordToolCall(root, "Read", time.Second, false, "")
+
+	stats, err := LoadStats(root)
+	if err != nil {
+		t.Fatalf("LoadStats failed: %v", err)
+	}
+	edit := stats.Tools["Edit"]
+	if edit == nil || edit.Calls != 2 || edit.Failures != 1 {
+		t.Fatalf("Unexpected Edit stats: %+v", edit)
+	}
+	if edit.FailureRate() != 0.5 || edit.Average() != 3*time.Second || edit.Max != 4*time.Second {
+		t.Errorf("Unexpected Edit aggregates: rate %v, avg %v, max %v", edit.FailureRate(), edit.Average(), edit.Max)
+	}
+	if edit.LastError != "old_string not found" {
+		t.Errorf("Expected the last error to be kept, got %q", edit.LastError)
+	}
+	if edit.On(time.Now()) != 2 || edit.On(time.Now().AddDate(0, 0, -1)) != 0 {
+		t.Errorf("Unexpected daily counts: %v", edit.Daily)
+	}
+
+	if err := ResetToolStats(root); err != nil {
+		t.Fatalf("ResetToolStats failed: %v", err)
+	}
+	if stats, _ := LoadStats(root); len(stats.Tools) != 0 {
+		t.Errorf("Expected no stats after reset, got %+v", stats.Tools)
+	}
+}
+
+func TestRecordToolCallConcurrent(t *testing.T) {
+	root := t.TempDir()
+
+	// Separate instances record into the same file
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RecordToolCall(root, "Bash", time.Millisecond, false, "")
+		}()
+	}
+	wg.Wait()
+
+	stats, _ := LoadStats(root)
+	if got := stats.Tools["Bash"].Calls; got != 20 {
+		t.Errorf("Expected 20 calls, got %d", got)
+	}
+}
+
+func TestRecordModelCall(t *testing.T) {
+	root := t.TempDir()
+
+	RecordToolCall(root, "Read", time.Second, false, "")
+	RecordModelCall(root, "claude-sonnet-4-5", time.Second, 4*time.Second, false)
+	RecordModelCall(root, "claude-sonnet-4-5", 0, 2*time.Second, true) // Tool calls only, nothing streamed
+
+	stats, err := LoadStats(root)
+	if err != nil {
+		t.Fatalf("LoadStats failed: %v", err)
+	}
+	m := stats.Models["claude-sonnet-4-5"]
+	if m == nil || m.Calls != 2 || m.Failures != 1 || m.Streamed != 1 {
+		t.Fatalf("Unexpected model stats: %+v", m)
+	}
+	if m.Average() != 3*time.Second || m.AverageTTFT() != time.Second || m.Max != 4*time.Second {
+		t.Errorf("Unexpected model aggregates: avg %v, ttft %v, max %v", m.Average(), m.AverageTTFT(), m.Max)
+	}
+
+	if err := ResetModelStats(root); err != nil {
+		t.Fatalf("ResetModelStats failed: %v", err)
+	}
+	if stats, _ := LoadStats(root); len(stats.Models) != 0 || stats.Tools["Read"] == nil {
+		t.Errorf("Expected only the model stats to be cleared, got %+v", stats)
+	}
+}
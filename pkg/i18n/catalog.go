@@ -0,0 +1,133 @@
+package i18n
+
+// catalogs maps language codes to translations of john's messages, keyed by
+// the English text. Format verbs must match the English message.
+var catalogs = map[string]map[string]string{
+	"en": {},
+	"es": {
+		"John Code - AI Coding Assistant": "John Code - Asistente de programación con IA",
+		"Usage:":                          "Uso:",
+		"MCP Commands:":                   "Comandos MCP:",
+		"Examples:":                       "Ejemplos:",
+		"Type 'exit' or 'quit' to stop.":  "Escribe 'exit' o 'quit' para salir.",
+		"Type your message...":            "Escribe tu mensaje...",
+		"Input is hidden":                 "La entrada está oculta",
+		"Session ID: %s":                  "ID de sesión: %s",
+		"Switched to %s":                  "Cambiado a %s",
+		"Running tool: %s":                "Ejecutando herramienta: %s",
+		"Error: %v":                       "Error: %v",
+		"Unknown command: /%s":            "Comando desconocido: /%s",
+		"Error executing command: %v":     "Error al ejecutar el comando: %v",
+		"No commands available":           "No hay comandos disponibles",
+		"Allow %s (rule %q)? [y/N] ":      "¿Permitir %s (regla %q)? [s/N] ",
+		"Do you trust the files in %s?":   "¿Confías en los archivos de %s?",
+		"Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers.": "Si confías, john podrá editar archivos, ejecutar comandos y usar la configuración, los comandos, los agentes y los servidores MCP del proyecto.",
+		"Trust this workspace? [y/N] ": "¿Confiar en este espacio de trabajo? [s/N] ",
+		"This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that.": "Este espacio de trabajo no es de confianza: modo de solo lectura, sin comandos, hooks ni servidores MCP del proyecto. Usa /trust para cambiarlo.",
+	},
+	"fr": {
+		"John Code - AI Coding Assistant": "John Code - Assistant de programmation IA",
+		"Usage:":                          "Utilisation :",
+		"MCP Commands:":                   "Commandes MCP :",
+		"Examples:":                       "Exemples :",
+		"Type 'exit' or 'quit' to stop.":  "Tapez 'exit' ou 'quit' pour quitter.",
+		"Type your message...":            "Tapez votre message...",
+		"Input is hidden":                 "La saisie est masquée",
+		"Session ID: %s":                  "ID de session : %s",
+		"Switched to %s":                  "Passé à %s",
+		"Running tool: %s":                "Exécution de l'outil : %s",
+		"Error: %v":                       "Erreur : %v",
+		"Unknown command: /%s":            "Commande inconnue : /%s",
+		"Error executing command: %v":     "Erreur lors de l'exécution de la commande : %v",
+		"No commands available":           "Aucune commande disponible",
+		"Allow %s (rule %q)? [y/N] ":      "Autoriser %s (règle %q) ? [o/N] ",
+		"Do you trust the files in %s?":   "Faites-vous confiance aux fichiers de %s ?",
+		"Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers.": "La confiance permet à john de modifier des fichiers, d'exécuter des commandes et d'utiliser les paramètres, commandes, agents et serveurs MCP du projet.",
+		"Trust this workspace? [y/N] ": "Faire confiance à cet espace de travail ? [o/N] ",
+		"This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that.": "Cet espace de travail n'est pas approuvé : mode lecture seule, sans les commandes, hooks et serveurs MCP du projet. Utilisez /trust pour changer cela.",
+	},
+	"de": {
+		"John Code - AI Coding Assistant": "John Code - KI-Programmierassistent",
+		"Usage:":                          "Verwendung:",
+		"MCP Commands:":                   "MCP-Befehle:",
+		"Examples:":                       "Beispiele:",
+		"Type 'exit' or 'quit' to stop.":  "Mit 'exit' oder 'quit' beenden.",
+		"Type your message...":            "Nachricht eingeben...",
+		"Input is hidden":                 "Eingabe ist verborgen",
+		"Session ID: %s":                  "Sitzungs-ID: %s",
+		"Switched to %s":                  "Gewechselt zu %s",
+		"Running tool: %s":                "Werkzeug wird ausgeführt: %s",
+		"Error: %v":                       "Fehler: %v",
+		"Unknown command: /%s":            "Unbekannter Befehl: /%s",
+		"Error executing command: %v":     "Fehler beim Ausführen des Befehls: %v",
+		"No commands available":           "Keine Befehle verfügbar",
+		"Allow %s (rule %q)? [y/N] ":      "%s erlauben (Regel %q)? [j/N] ",
+		"Do you trust the files in %s?":   "Vertrauen Sie den Dateien in %s?",
+		"Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers.": "Mit Vertrauen darf john Dateien bearbeiten, Befehle ausführen und die Einstellungen, Befehle, Agenten und MCP-Server des Projekts verwenden.",
+		"Trust this workspace? [y/N] ": "Diesem Arbeitsbereich vertrauen? [j/N] ",
+		"This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that.": "Diesem Arbeitsbereich wird nicht vertraut: Nur-Lese-Modus, Projektbefehle, Hooks und MCP-Server sind deaktiviert. Mit /trust ändern.",
+	},
+	"pt": {
+		"John Code - AI Coding Assistant": "John Code - Assistente de programação com IA",
+		"Usage:":                          "Uso:",
+		"MCP Commands:":                   "Comandos MCP:",
+		"Examples:":                       "Exemplos:",
+		"Type 'exit' or 'quit' to stop.":  "Digite 'exit' ou 'quit' para sair.",
+		"Type your message...":            "Digite sua mensagem...",
+		"Input is hidden":                 "A entrada está oculta",
+		"Session ID: %s":                  "ID da sessão: %s",
+		"Switched to %s":                  "Alterado para %s",
+		"Running tool: %s":                "Executando ferramenta: %s",
+		"Error: %v":                       "Erro: %v",
+		"Unknown command: /%s":            "Comando desconhecido: /%s",
+		"Error executing command: %v":     "Erro ao executar o comando: %v",
+		"No commands available":           "Nenhum comando disponível",
+		"Allow %s (rule %q)? [y/N] ":      "Permitir %s (regra %q)? [s/N] ",
+		"Do you trust the files in %s?":   "Você confia nos arquivos em %s?",
+		"Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers.": "Confiar permite que o john edite arquivos, execute comandos e use as configurações, comandos, agentes e servidores MCP do projeto.",
+		"Trust this workspace? [y/N] ": "Confiar neste espaço de trabalho? [s/N] ",
+		"This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that.": "Este espaço de trabalho não é confiável: modo somente leitura, sem comandos, hooks e servidores MCP do projeto. Use /trust para mudar isso.",
+	},
+	"ja": {
+		"John Code - AI Coding Assistant": "John Code - AI コーディングアシスタント",
+		"Usage:":                          "使い方:",
+		"MCP Commands:":                   "MCP コマンド:",
+		"Examples:":                       "例:",
+		"Type 'exit' or 'quit' to stop.":  "終了するには 'exit' または 'quit' と入力してください。",
+		"Type your message...":            "メッセージを入力...",
+		"Input is hidden":                 "入力は表示されません",
+		"Session ID: %s":                  "セッション ID: %s",
+		"Switched to %s":                  "%s に切り替えました",
+		"Running tool: %s":                "ツールを実行中: %s",
+		"Error: %v":                       "エラー: %v",
+		"Unknown command: /%s":            "不明なコマンド: /%s",
+		"Error executing command: %v":     "コマンドの実行に失敗しました: %v",
+		"No commands available":           "使用できるコマンドはありません",
+		"Allow %s (rule %q)? [y/N] ":      "%s を許可しますか (ルール %q)? [y/N] ",
+		"Do you trust the files in %s?":   "%s のファイルを信頼しますか?",
+		"Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers.": "信頼すると、john はファイルの編集、コマンドの実行、プロジェクトの設定・コマンド・エージェント・MCP サーバーの使用ができるようになります。",
+		"Trust this workspace? [y/N] ": "このワークスペースを信頼しますか? [y/N] ",
+		"This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that.": "このワークスペースは信頼されていません: 読み取り専用モードで、プロジェクトのコマンド・フック・MCP サーバーは無効です。変更するには /trust を使ってください。",
+	},
+	"zh": {
+		"John Code - AI Coding Assistant": "John Code - AI 编程助手",
+		"Usage:":                          "用法:",
+		"MCP Commands:":                   "MCP 命令:",
+		"Examples:":                       "示例:",
+		"Type 'exit' or 'quit' to stop.":  "输入 'exit' 或 'quit' 退出。",
+		"Type your message...":            "输入消息...",
+		"Input is hidden":                 "输入已隐藏",
+		"Session ID: %s":                  "会话 ID: %s",
+		"Switched to %s":                  "已切换到 %s",
+		"Running tool: %s":                "正在运行工具: %s",
+		"Error: %v":                       "错误: %v",
+		"Unknown command: /%s":            "未知命令: /%s",
+		"Error executing command: %v":     "执行命令出错: %v",
+		"No commands available":           "没有可用的命令",
+		"Allow %s (rule %q)? [y/N] ":      "允许 %s (规则 %q)? [y/N] ",
+		"Do you trust the files in %s?":   "是否信任 %s 中的文件?",
+		"Trusting lets john edit files, run commands, and use the project's settings, commands, agents and MCP servers.": "信任后，john 可以编辑文件、运行命令，并使用项目的设置、命令、代理和 MCP 服务器。",
+		"Trust this workspace? [y/N] ": "是否信任此工作区? [y/N] ",
+		"This workspace is not trusted: read-only mode, with project commands, hooks and MCP servers disabled. Use /trust to change that.": "此工作区未受信任: 处于只读模式，项目命令、钩子和 MCP 服务器已禁用。使用 /trust 更改。",
+	},
+}
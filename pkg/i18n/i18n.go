@@ -0,0 +1,98 @@
+// Package i18n translates john's own messages (prompts, notices, help
+// headings) into the language chosen in settings. Messages are looked up by
+// their English text, so untranslated ones fall back to English as written.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Language is a language john can be set to
+type Language struct {
+	Code   string // ISO 639-1, e.g. "es"
+	Name   string // English name, e.g. "Spanish"
+	Native string // Name in the language itself, e.g. "español"
+	Yes    []string
+}
+
+// Languages are the languages with a message catalog. Any other language
+// can still be set; the model answers in it and messages stay English.
+var Languages = []Language{
+	{Code: "en", Name: "English", Native: "English"},
+	{Code: "es", Name: "Spanish", Native: "español", Yes: []string{"s", "si", "sí"}},
+	{Code: "fr", Name: "French", Native: "français", Yes: []string{"o", "oui"}},
+	{Code: "de", Name: "German", Native: "Deutsch", Yes: []string{"j", "ja"}},
+	{Code: "pt", Name: "Portuguese", Native: "português", Yes: []string{"s", "sim"}},
+	{Code: "ja", Name: "Japanese", Native: "日本語", Yes: []string{"はい"}},
+	{Code: "zh", Name: "Chinese", Native: "中文", Yes: []string{"是"}},
+}
+
+var (
+	mu      sync.RWMutex
+	current *Language
+)
+
+// Lookup finds a language by code ("pt", "pt-BR", "pt_BR"), English name or
+// native name, ignoring case
+func Lookup(name string) (Language, bool) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if i := strings.IndexAny(key, "-_"); i > 0 {
+		key = key[:i]
+	}
+	for _, l := range Languages {
+		if key == l.Code || key == strings.ToLower(l.Name) || key == strings.ToLower(l.Native) {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// SetLanguage selects the language of messages. "" and languages without a
+// catalog select English.
+func SetLanguage(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = nil
+	if l, ok := Lookup(name); ok && catalogs[l.Code] != nil {
+		current = &l
+	}
+}
+
+// T returns the translation of an English message
+func T(msg string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return msg
+	}
+	if translated, ok := catalogs[current.Code][msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+// Tf translates an English format string and formats it
+func Tf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// IsYes reports whether answer accepts a [y/N] prompt. English y and yes
+// always do.
+func IsYes(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "y" || answer == "yes" {
+		return true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if current != nil {
+		for _, yes := range current.Yes {
+			if answer == yes {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"pt", "pt-BR", "pt_br", "Portuguese", "PORTUGUÊS"} {
+		if l, ok := Lookup(name); !ok || l.Code != "pt" {
+			t.Errorf("Lookup(%q) = %+v, %v; want pt", name, l, ok)
+		}
+	}
+	if _, ok := Lookup("Klingon"); ok {
+		t.Error("Expected no match for a language without a catalog")
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	defer SetLanguage("")
+
+	SetLanguage("")
+	if got := T("Usage:"); got != "Usage:" {
+		t.Errorf("English T = %q", got)
+	}
+
+	SetLanguage("de")
+	if got := Tf("Session ID: %s", "abc"); got != "Sitzungs-ID: abc" {
+		t.Errorf("Tf = %q", got)
+	}
+	if got := T("Not in any catalog"); got != "Not in any catalog" {
+		t.Errorf("Expected untranslated messages to stay English, got %q", got)
+	}
+	if !IsYes("J") || !IsYes("yes") || IsYes("n") || IsYes("") {
+		t.Error("IsYes should accept ja/j and English yes in German")
+	}
+
+	// A language without a catalog leaves messages in English
+	SetLanguage("Klingon")
+	if got := T("Usage:"); got != "Usage:" {
+		t.Errorf("T = %q", got)
+	}
+	if IsYes("j") {
+		t.Error("German yes accepted after switching language")
+	}
+}
+
+// Translations must keep the English message's format verbs, in order
+func TestCatalogVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for code, catalog := range catalogs {
+		for msg, translated := range catalog {
+			want := verbs.FindAllString(msg, -1)
+			got := verbs.FindAllString(translated, -1)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q has verbs %v, English has %v", code, translated, got, want)
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("%s: %q has verbs %v, English has %v", code, translated, got, want)
+					break
+				}
+			}
+		}
+	}
+}
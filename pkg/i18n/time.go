@@ -0,0 +1,124 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeFormats are the named layouts the time.format setting can pick
+var timeFormats = map[string]string{
+	"iso": "2006-01-02 15:04",
+	"us":  "Jan 2, 2006 3:04 PM",
+	"eu":  "02.01.2006 15:04",
+	"uk":  "02/01/2006 15:04",
+}
+
+// defaultTimeFormats are the layouts used per language when time.format is
+// not set
+var defaultTimeFormats = map[string]string{
+	"en": "2006-01-02 15:04",
+	"de": "02.01.2006 15:04",
+	"fr": "02/01/2006 15:04",
+	"es": "02/01/2006 15:04",
+	"pt": "02/01/2006 15:04",
+	"ja": "2006/01/02 15:04",
+	"zh": "2006-01-02 15:04",
+}
+
+var (
+	location   = time.Local
+	timeFormat string // Layout from settings; "" follows the language
+)
+
+// SetTime selects the time zone ("" or "Local" for the system's, "UTC", or
+// an IANA name like "Europe/Berlin") and the layout times are shown in: a
+// name from timeFormats or a Go layout. Session files keep UTC either way.
+func SetTime(zone, format string) error {
+	loc := time.Local
+	if zone = strings.TrimSpace(zone); zone != "" {
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return fmt.Errorf("unknown time zone %q", zone)
+		}
+	}
+	format = strings.TrimSpace(format)
+	if named, ok := timeFormats[strings.ToLower(format)]; ok {
+		format = named
+	} else if format != "" && !strings.ContainsAny(format, "0123456789") {
+		return fmt.Errorf("time format %q is not iso, us, eu, uk or a Go layout like 2006-01-02 15:04", format)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	location, timeFormat = loc, format
+	return nil
+}
+
+// Location returns the time zone times are shown and days are counted in
+func Location() *time.Location {
+	mu.RLock()
+	defer mu.RUnlock()
+	return location
+}
+
+// FormatTime shows a date and time in the configured zone and layout
+func FormatTime(t time.Time) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	layout := timeFormat
+	if layout == "" {
+		layout = defaultTimeFormats["en"]
+		if current != nil && defaultTimeFormats[current.Code] != "" {
+			layout = defaultTimeFormats[current.Code]
+		}
+	}
+	return t.In(location).Format(layout)
+}
+
+// FormatDate shows the date part of FormatTime
+func FormatDate(t time.Time) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	layout := "2006-01-02"
+	if current != nil && defaultTimeFormats[current.Code] != "" {
+		layout, _, _ = strings.Cut(defaultTimeFormats[current.Code], " ")
+	}
+	if timeFormat != "" {
+		layout = dateOf(timeFormat)
+	}
+	return t.In(location).Format(layout)
+}
+
+// dateOf cuts the time of day from a layout, leaving its date
+func dateOf(layout string) string {
+	for _, clock := range []string{" 15:04:05", " 15:04", " 3:04:05 PM", " 3:04 PM", " 03:04 PM", "T15:04:05Z07:00", "T15:04:05"} {
+		if i := strings.Index(layout, clock); i >= 0 {
+			return strings.TrimRight(layout[:i], ", ") + layout[i+len(clock):]
+		}
+	}
+	return layout
+}
+
+// FormatClock shows the time of day with seconds in the configured zone,
+// in 12-hour form when the layout uses it
+func FormatClock(t time.Time) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if strings.Contains(timeFormat, "PM") {
+		return t.In(location).Format("3:04:05 PM")
+	}
+	return t.In(location).Format("15:04:05")
+}
+
+// Day returns the start of t's day in the configured zone
+func Day(t time.Time) time.Time {
+	loc := Location()
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// DayKey names t's day in the configured zone, as 2006-01-02, for
+// bucketing by day
+func DayKey(t time.Time) string {
+	return t.In(Location()).Format("2006-01-02")
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	defer SetTime("", "")
+	defer SetLanguage("")
+	// 23:30 UTC is already the next day in Tokyo
+	at := time.Date(2025, 3, 10, 23, 30, 5, 0, time.UTC)
+
+	if err := SetTime("Asia/Tokyo", ""); err != nil {
+		t.Fatalf("SetTime: %v", err)
+	}
+	if got := FormatTime(at); got != "2025-03-11 08:30" {
+		t.Errorf("FormatTime = %q", got)
+	}
+	if got := DayKey(at); got != "2025-03-11" {
+		t.Errorf("DayKey = %q", got)
+	}
+	if day := Day(at); day.Hour() != 0 || day.Day() != 11 || day.Location().String() != "Asia/Tokyo" {
+		t.Errorf("Day = %v", day)
+	}
+
+	SetLanguage("de")
+	if got := FormatTime(at); got != "11.03.2025 08:30" {
+		t.Errorf("German FormatTime = %q", got)
+	}
+	if got := FormatDate(at); got != "11.03.2025" {
+		t.Errorf("German FormatDate = %q", got)
+	}
+
+	SetTime("UTC", "us")
+	if got, clock := FormatTime(at), FormatClock(at); got != "Mar 10, 2025 11:30 PM" || clock != "11:30:05 PM" {
+		t.Errorf("us format: %q, %q", got, clock)
+	}
+	if got := FormatDate(at); got != "Mar 10, 2025" {
+		t.Errorf("us FormatDate = %q", got)
+	}
+	SetTime("UTC", "Mon 2 Jan 15:04")
+	if got := FormatTime(at); got != "Mon 10 Mar 23:30" {
+		t.Errorf("layout FormatTime = %q", got)
+	}
+
+	if err := SetTime("Mars/Olympus", ""); err == nil {
+		t.Error("unknown zone accepted")
+	}
+	if err := SetTime("", "fancy"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
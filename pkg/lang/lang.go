@@ -0,0 +1,223 @@
+// Package lang tells what language a file is written in, from its name,
+// its shebang line or its content, for the tools, the viewer and the
+// provider clients to share one answer.
+package lang
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Language is a programming, markup or data language
+type Language struct {
+	ID     string // Markdown code fence tag, e.g. "go" or "python"
+	Name   string // For people, e.g. "Go"
+	Source bool   // Program source with definitions, as opposed to scripts, markup and data
+
+	exts         []string
+	names        []string // Whole file names, such as Makefile
+	interpreters []string // Shebang interpreters
+}
+
+var languages = []Language{
+	{ID: "go", Name: "Go", Source: true, exts: []string{".go"}},
+	{ID: "python", Name: "Python", Source: true, exts: []string{".py", ".pyw", ".pyi"}, interpreters: []string{"python"}},
+	{ID: "javascript", Name: "JavaScript", Source: true, exts: []string{".js", ".jsx", ".mjs", ".cjs"}, interpreters: []string{"node", "nodejs"}},
+	{ID: "typescript", Name: "TypeScript", Source: true, exts: []string{".ts", ".tsx", ".mts", ".cts"}, interpreters: []string{"deno", "ts-node", "tsx", "bun"}},
+	{ID: "rust", Name: "Rust", Source: true, exts: []string{".rs"}},
+	{ID: "java", Name: "Java", Source: true, exts: []string{".java"}},
+	{ID: "kotlin", Name: "Kotlin", Source: true, exts: []string{".kt", ".kts"}},
+	{ID: "scala", Name: "Scala", Source: true, exts: []string{".scala", ".sc"}},
+	{ID: "ruby", Name: "Ruby", Source: true, exts: []string{".rb"}, names: []string{"Gemfile", "Rakefile"}, interpreters: []string{"ruby"}},
+	{ID: "php", Name: "PHP", Source: true, exts: []string{".php"}, interpreters: []string{"php"}},
+	{ID: "c", Name: "C", Source: true, exts: []string{".c", ".h"}},
+	{ID: "cpp", Name: "C++", Source: true, exts: []string{".cc", ".cpp", ".cxx", ".hpp", ".hh", ".hxx"}},
+	{ID: "csharp", Name: "C#", Source: true, exts: []string{".cs"}},
+	{ID: "swift", Name: "Swift", Source: true, exts: []string{".swift"}},
+	{ID: "shell", Name: "Shell", exts: []string{".sh", ".bash", ".zsh"},
+		names:        []string{".bashrc", ".bash_profile", ".zshrc", ".profile"},
+		interpreters: []string{"sh", "bash", "zsh", "dash", "ksh"}},
+	{ID: "perl", Name: "Perl", exts: []string{".pl", ".pm"}, interpreters: []string{"perl"}},
+	{ID: "lua", Name: "Lua", exts: []string{".lua"}, interpreters: []string{"lua"}},
+	{ID: "sql", Name: "SQL", exts: []string{".sql"}},
+	{ID: "yaml", Name: "YAML", exts: []string{".yaml", ".yml"}},
+	{ID: "toml", Name: "TOML", exts: []string{".toml"}},
+	{ID: "json", Name: "JSON", exts: []string{".json"}},
+	{ID: "markdown", Name: "Markdown", exts: []string{".md", ".markdown"}},
+	{ID: "html", Name: "HTML", exts: []string{".html", ".htm"}},
+	{ID: "css", Name: "CSS", exts: []string{".css"}},
+	{ID: "xml", Name: "XML", exts: []string{".xml"}},
+	{ID: "protobuf", Name: "Protocol Buffers", exts: []string{".proto"}},
+	{ID: "makefile", Name: "Makefile", exts: []string{".mk"}, names: []string{"Makefile", "GNUmakefile", "makefile"}},
+	{ID: "dockerfile", Name: "Dockerfile", names: []string{"Dockerfile", "Containerfile"}},
+}
+
+var (
+	byExt         = make(map[string]*Language)
+	byName        = make(map[string]*Language)
+	byInterpreter = make(map[string]*Language)
+	byID          = make(map[string]*Language)
+)
+
+func init() {
+	for i := range languages {
+		l := &languages[i]
+		byID[l.ID] = l
+		for _, ext := range l.exts {
+			byExt[ext] = l
+		}
+		for _, name := range l.names {
+			byName[name] = l
+		}
+		for _, interp := range l.interpreters {
+			byInterpreter[interp] = l
+		}
+	}
+}
+
+// ByID returns the language with a fence tag, or nil
+func ByID(id string) *Language {
+	return byID[id]
+}
+
+// FromPath detects a file's language from its name alone, or returns nil
+func FromPath(path string) *Language {
+	return Detect(path, nil)
+}
+
+// Detect returns the language of a file from its extension or name, then
+// from the shebang and content of head, its first bytes, or nil when
+// nothing fits
+func Detect(path string, head []byte) *Language {
+	base := filepath.Base(path)
+	if l := byName[base]; l != nil {
+		return l
+	}
+	if l := byExt[strings.ToLower(filepath.Ext(base))]; l != nil {
+		return l
+	}
+	// Dockerfile.dev, Makefile.local
+	for name, l := range byName {
+		if !strings.HasPrefix(name, ".") && strings.HasPrefix(base, name+".") {
+			return l
+		}
+	}
+	if l := fromShebang(head); l != nil {
+		return l
+	}
+	return fromContent(head)
+}
+
+// fromShebang reads the interpreter of a #! line: #!/bin/bash,
+// #!/usr/bin/env python3, #!/usr/bin/env -S node --flag
+func fromShebang(head []byte) *Language {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return nil
+	}
+	line, _, _ := bytes.Cut(head[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return nil
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = f
+				break
+			}
+		}
+	}
+	// python3.12 is python
+	interp = strings.TrimRight(interp, "0123456789.")
+	return byInterpreter[interp]
+}
+
+var (
+	goPackage     = regexp.MustCompile(`(?m)^package [a-z_][a-z0-9_]*\s*$`)
+	goFunc        = regexp.MustCompile(`(?m)^(func|import|type) `)
+	pythonDef     = regexp.MustCompile(`(?m)^(def|class) \w+.*:\s*$|^from [\w.]+ import |^import \w+\s*$`)
+	cInclude      = regexp.MustCompile(`(?m)^#include [<"]`)
+	markdownTitle = regexp.MustCompile(`(?m)^#{1,3} \S`)
+)
+
+// fromContent guesses from telltale content, for files without a
+// recognized name or shebang
+func fromContent(head []byte) *Language {
+	text := bytes.TrimSpace(head)
+	if len(text) == 0 {
+		return nil
+	}
+	switch {
+	case bytes.HasPrefix(text, []byte("<?php")):
+		return byID["php"]
+	case bytes.HasPrefix(text, []byte("<?xml")):
+		return byID["xml"]
+	case hasPrefixFold(text, "<!doctype html") || hasPrefixFold(text, "<html"):
+		return byID["html"]
+	case (text[0] == '{' || text[0] == '[') && json.Valid(text):
+		return byID["json"]
+	case goPackage.Match(head) && goFunc.Match(head):
+		return byID["go"]
+	case cInclude.Match(head):
+		return byID["c"]
+	case pythonDef.Match(head):
+		return byID["python"]
+	case markdownTitle.Match(head):
+		return byID["markdown"]
+	}
+	return nil
+}
+
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
+
+// FenceTag returns the Markdown code fence tag for a file, or "" when its
+// language is unknown
+func FenceTag(path string, content []byte) string {
+	if l := Detect(path, content); l != nil {
+		return l.ID
+	}
+	return ""
+}
+
+// Fence wraps content in a Markdown code block tagged with its language,
+// with a fence longer than any run of backticks inside it
+func Fence(path string, content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence + FenceTag(path, []byte(content)) + "\n" + strings.TrimRight(content, "\n") + "\n" + fence
+}
+
+// imageTypes are the image formats the providers accept
+var imageTypes = map[string]string{
+	".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".gif": "image/gif", ".webp": "image/webp",
+}
+
+// IsImage reports whether a file is an image the providers accept, by name
+func IsImage(path string) bool {
+	return imageTypes[strings.ToLower(filepath.Ext(path))] != ""
+}
+
+// ImageType returns the media type of an image to send to a provider: by
+// extension, else by sniffing data, else JPEG
+func ImageType(path string, data []byte) string {
+	if t := imageTypes[strings.ToLower(filepath.Ext(path))]; t != "" {
+		return t
+	}
+	sniffed := http.DetectContentType(data)
+	for _, t := range imageTypes {
+		if sniffed == t {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
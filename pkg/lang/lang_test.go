@@ -0,0 +1,66 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		path, head string
+		want       string // "" for unknown
+	}{
+		{"main.go", "", "go"},
+		{"src/App.TSX", "", "typescript"},
+		{"Makefile", "", "makefile"},
+		{"Dockerfile.dev", "", "dockerfile"},
+		{".bashrc", "", "shell"},
+		{"bin/deploy", "#!/bin/bash\nset -e\n", "shell"},
+		{"bin/tool", "#!/usr/bin/env python3.12\nprint(1)\n", "python"},
+		{"bin/serve", "#!/usr/bin/env -S node --no-warnings\n", "javascript"},
+		{"config", `{"a": [1, 2]}`, "json"},
+		{"index", "<!DOCTYPE html>\n<html></html>", "html"},
+		{"gen", "package main\n\nfunc main() {}\n", "go"},
+		{"script", "import os\n\ndef run():\n    pass\n", "python"},
+		{"notes", "just some words\n", ""},
+		{"notes.txt", "package main\n\nfunc main() {}\n", "go"},
+		// The name wins over content
+		{"data.yaml", `{"a": 1}`, "yaml"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if l := Detect(tt.path, []byte(tt.head)); l != nil {
+			got = l.ID
+		}
+		if got != tt.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", tt.path, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestFence(t *testing.T) {
+	if got := Fence("a.py", "x = 1\n"); got != "```python\nx = 1\n```" {
+		t.Errorf("Fence = %q", got)
+	}
+	// Content with a fence of its own gets a longer one
+	got := Fence("README.md", "```go\nx\n```\n")
+	if !strings.HasPrefix(got, "````markdown\n") || !strings.HasSuffix(got, "\n````") {
+		t.Errorf("nested Fence = %q", got)
+	}
+}
+
+func TestImageType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if got := ImageType("shot.PNG", nil); got != "image/png" {
+		t.Errorf("by extension = %q", got)
+	}
+	if got := ImageType("/tmp/clipboard", png); got != "image/png" {
+		t.Errorf("sniffed = %q", got)
+	}
+	if got := ImageType("/tmp/clipboard", []byte("??")); got != "image/jpeg" {
+		t.Errorf("fallback = %q", got)
+	}
+	if !IsImage("a.webp") || IsImage("a.svg") {
+		t.Error("IsImage")
+	}
+}
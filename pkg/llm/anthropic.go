@@ -9,18 +9,24 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/lang"
 )
 
 const DefaultAnthropicEndpoint = "https://api.anthropic.com/v1/messages"
 
 type AnthropicClient struct {
-	apiKey   string
-	endpoint string
-	model    string
-	client   *http.Client
+	apiKey      string
+	endpoint    string
+	model       string
+	client      *http.Client
+	serverTools config.ServerToolSettings
+	sampling    Sampling
+	tokens      TokenFunc // Set for OAuth logins, instead of apiKey
 }
 
 func NewAnthropicClient(apiKey string, baseURL string, model string) *AnthropicClient {
@@ -50,10 +56,54 @@ func NewAnthropicClient(apiKey string, baseURL string, model string) *AnthropicC
 		apiKey:   apiKey,
 		endpoint: endpoint,
 		model:    model,
-		client:   &http.Client{},
+		client:   config.NewProviderHTTPClient("anthropic"),
 	}
 }
 
+// UseOAuth authenticates with tokens from john login instead of an API key
+func (c *AnthropicClient) UseOAuth(tokens TokenFunc) {
+	c.tokens = tokens
+}
+
+// EnableServerTools turns on Anthropic's server-side tools for this client.
+func (c *AnthropicClient) EnableServerTools(settings config.ServerToolSettings) {
+	c.serverTools = settings
+}
+
+// SetSampling pins the sampling parameters. Claude models accept temperature
+// but not alongside top_p, and take no seed, so only temperature is sent.
+func (c *AnthropicClient) SetSampling(s Sampling) {
+	c.sampling = s
+}
+
+// ReplacesTool reports whether a server tool stands in for the named local tool
+func (c *AnthropicClient) ReplacesTool(name string) bool {
+	return name == "WebSearch" && c.serverTools.WebSearch
+}
+
+// serverToolDefinitions returns the server tool entries for the tools array
+func (c *AnthropicClient) serverToolDefinitions() []interface{} {
+	var defs []interface{}
+	if c.serverTools.WebSearch {
+		maxUses := c.serverTools.WebSearchMaxUses
+		if maxUses <= 0 {
+			maxUses = 5
+		}
+		defs = append(defs, map[string]interface{}{
+			"type":     "web_search_20250305",
+			"name":     "web_search",
+			"max_uses": maxUses,
+		})
+	}
+	if c.serverTools.CodeExecution {
+		defs = append(defs, map[string]interface{}{
+			"type": "code_execution_20250522",
+			"name": "code_execution",
+		})
+	}
+	return defs
+}
+
 // API Request Structures
 
 type apiRequest struct {
@@ -63,6 +113,9 @@ type apiRequest struct {
 	Tools     []interface{}  `json:"tools,omitempty"`
 	System    string         `json:"system,omitempty"`
 	Stream    bool           `json:"stream,omitempty"`
+
+	ToolChoice  interface{} `json:"tool_choice,omitempty"`
+	Temperature *float64    `json:"temperature,omitempty"`
 }
 
 type apiMessage struct {
@@ -78,6 +131,7 @@ type apiContentBlock struct {
 	Input     interface{} `json:"input,omitempty"` // map[string]interface{}
     ToolUseID string      `json:"tool_use_id,omitempty"`
     Content   string      `json:"content,omitempty"` // For tool_result
+    IsError   bool        `json:"is_error,omitempty"` // For tool_result
     Source    *apiImageSource `json:"source,omitempty"` // For image
 }
 
@@ -91,15 +145,36 @@ type apiImageSource struct {
 type sseEvent struct {
     Type         string          `json:"type"`
     Delta        *sseDelta       `json:"delta,omitempty"`
-    ContentBlock *apiContentBlock `json:"content_block,omitempty"`
+    ContentBlock json.RawMessage `json:"content_block,omitempty"` // Decoded by block type
+
     Index        int             `json:"index,omitempty"`
     Error        *apiError       `json:"error,omitempty"`
+    Message      *struct {
+        Usage *sseUsage `json:"usage,omitempty"`
+    } `json:"message,omitempty"` // message_start
+    Usage        *sseUsage       `json:"usage,omitempty"` // message_delta
+}
+
+type sseUsage struct {
+    InputTokens              int `json:"input_tokens"`
+    CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+    CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+    OutputTokens             int `json:"output_tokens"`
 }
 
 type sseDelta struct {
-    Type        string `json:"type"`
-    Text        string `json:"text,omitempty"`
-    PartialJSON string `json:"partial_json,omitempty"`
+    Type        string          `json:"type"`
+    Text        string          `json:"text,omitempty"`
+    PartialJSON string          `json:"partial_json,omitempty"`
+    Citation    json.RawMessage `json:"citation,omitempty"` // citations_delta
+}
+
+// apiTextBlock is a text block of a response as kept with its server tool
+// blocks, citations included
+type apiTextBlock struct {
+    Type      string            `json:"type"`
+    Text      string            `json:"text"`
+    Citations []json.RawMessage `json:"citations,omitempty"`
 }
 
 type apiError struct {
@@ -125,7 +200,7 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
         // Skip empty messages - Anthropic API requires non-empty content for all messages
         // except the optional final assistant message (used for prefill)
         isLastMessage := i == len(messages)-1
-        isEmpty := msg.Content == "" && len(msg.ToolCalls) == 0 && len(msg.Images) == 0 && msg.ToolResult == nil
+        isEmpty := msg.Content == "" && len(msg.ToolCalls) == 0 && len(msg.Images) == 0 && msg.ToolResult == nil && len(msg.ServerBlocks) == 0
         if isEmpty && !(isLastMessage && msg.Role == RoleAssistant) {
             continue
         }
@@ -155,22 +230,7 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
                          continue
                     }
                     
-                    // Detect mime type
-                    ext := strings.ToLower(filepath.Ext(imgPath))
-                    var mediaType string
-                    switch ext {
-                    case ".jpg", ".jpeg":
-                        mediaType = "image/jpeg"
-                    case ".png":
-                        mediaType = "image/png"
-                    case ".gif":
-                        mediaType = "image/gif"
-                    case ".webp":
-                        mediaType = "image/webp"
-                    default:
-                        // Default or skip?
-                        mediaType = "image/jpeg"
-                    }
+                    mediaType := lang.ImageType(imgPath, data)
                     
                     encoded := base64.StdEncoding.EncodeToString(data)
                     
@@ -194,11 +254,22 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
                 apiMsg.Content = msg.Content
             }
         } else if msg.Role == RoleAssistant {
-             var blocks []apiContentBlock
-             if msg.Content != "" {
+             var blocks []interface{}
+             // Server tool calls, their results and the text citing them go
+             // back as they came
+             for _, raw := range msg.ServerBlocks {
+                 blocks = append(blocks, raw)
+             }
+             if msg.Content != "" && !msg.ServerBlocksHaveText() {
+                 text := msg.SentText()
+                 // An interrupted answer left last is a prefill the model
+                 // continues, which must not end in whitespace
+                 if msg.Interrupted && isLastMessage {
+                     text = strings.TrimRight(msg.Content, " \t\r\n")
+                 }
                  blocks = append(blocks, apiContentBlock{
                      Type: "text",
-                     Text: msg.Content,
+                     Text: text,
                  })
              }
              for _, tc := range msg.ToolCalls {
@@ -224,6 +295,7 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
                     Type: "tool_result",
                     ToolUseID: msg.ToolResult.ToolCallID,
                     Content: msg.ToolResult.Content,
+                    IsError: msg.ToolResult.IsError,
                 },
             }
              apiMsg.Content = blocks
@@ -236,9 +308,14 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
 		Model:     c.model,
 		MaxTokens: 8192,
 		Messages:  apiMessages,
-		Tools:     tools,
+		Tools:     append(tools, c.serverToolDefinitions()...),
 		System:    systemPrompt,
 		Stream:    true,
+
+		Temperature: c.sampling.Temperature,
+	}
+	if len(reqBody.Tools) > 0 {
+		reqBody.ToolChoice = ToolChoiceFrom(ctx).anthropic()
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -252,8 +329,13 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	var betas []string
+	if c.serverTools.CodeExecution {
+		betas = append(betas, "code-execution-2025-05-22")
+	}
+	if err := c.authorize(ctx, req, betas...); err != nil {
+		return nil, err
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -262,14 +344,15 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
 	defer resp.Body.Close()
     
     if resp.StatusCode != http.StatusOK {
-        bodyBytes, _ := io.ReadAll(resp.Body)
-        return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+        return nil, newAPIError("anthropic", resp)
     }
 
     // Accumulators for final message
     finalMsg := &Message{
         Role: RoleAssistant,
         ToolCalls: []ToolCall{},
+        RequestHash: c.sampling.requestHash(jsonData),
+        RequestID: responseRequestID(resp),
     }
     
     // We need to track tool calls being built
@@ -278,8 +361,14 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
         ID string
         Name string
         JSONBuffer string
+        Server bool // server_tool_use, executed by Anthropic
     }
     toolBuilders := make(map[int]*toolBuilder)
+    // Text and server tool blocks in the order they came, kept as
+    // ServerBlocks when a server tool was used
+    textBuilders := make(map[int]*apiTextBlock)
+    var ordered []json.RawMessage
+    usedServerTools := false
     
     reader := bufio.NewReader(resp.Body)
     for {
@@ -310,26 +399,61 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
         switch event.Type {
         case "error":
             if event.Error != nil {
-                return nil, fmt.Errorf("API stream error: %s", event.Error.Message)
+                return nil, &APIError{Provider: "anthropic", Type: event.Error.Type, RequestID: responseRequestID(resp), Message: event.Error.Message}
+            }
+        case "message_start":
+            if event.Message != nil && event.Message.Usage != nil {
+                u := event.Message.Usage
+                finalMsg.Usage = &Usage{
+                    InputTokens:  u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+                    OutputTokens: u.OutputTokens,
+                }
+            }
+        case "message_delta":
+            // Output tokens are cumulative
+            if event.Usage != nil {
+                if finalMsg.Usage == nil {
+                    finalMsg.Usage = &Usage{}
+                }
+                finalMsg.Usage.OutputTokens = event.Usage.OutputTokens
             }
         case "content_block_start":
             if event.ContentBlock != nil {
-                if event.ContentBlock.Type == "tool_use" {
+                var block apiContentBlock
+                json.Unmarshal(event.ContentBlock, &block)
+                switch {
+                case block.Type == "tool_use" || block.Type == "server_tool_use":
                     toolBuilders[event.Index] = &toolBuilder{
-                        ID: event.ContentBlock.ID,
-                        Name: event.ContentBlock.Name,
+                        ID: block.ID,
+                        Name: block.Name,
+                        Server: block.Type == "server_tool_use",
+                    }
+                case strings.HasSuffix(block.Type, "_tool_result"):
+                    // Server tool results arrive complete; keep them verbatim
+                    ordered = append(ordered, event.ContentBlock)
+                    usedServerTools = true
+                    for _, id := range resultFileIDs(event.ContentBlock) {
+                        finalMsg.Artifacts = append(finalMsg.Artifacts, Artifact{FileID: id})
                     }
+                case block.Type == "text":
+                    textBuilders[event.Index] = &apiTextBlock{Type: "text"}
                 }
-                // If text, nothing special needed, handled in deltas
             }
         case "content_block_delta":
             if event.Delta != nil {
                 if event.Delta.Type == "text_delta" {
                     text := event.Delta.Text
                     finalMsg.Content += text
+                    if tb, ok := textBuilders[event.Index]; ok {
+                        tb.Text += text
+                    }
                     if outputChan != nil {
                         outputChan <- text
                     }
+                } else if event.Delta.Type == "citations_delta" {
+                    if tb, ok := textBuilders[event.Index]; ok && event.Delta.Citation != nil {
+                        tb.Citations = append(tb.Citations, event.Delta.Citation)
+                    }
                 } else if event.Delta.Type == "input_json_delta" {
                     if tb, ok := toolBuilders[event.Index]; ok {
                         tb.JSONBuffer += event.Delta.PartialJSON
@@ -337,20 +461,30 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
                 }
             }
         case "content_block_stop":
+            if tb, ok := textBuilders[event.Index]; ok {
+                // The API rejects empty text blocks
+                if tb.Text != "" {
+                    block, _ := json.Marshal(tb)
+                    ordered = append(ordered, block)
+                }
+                delete(textBuilders, event.Index)
+            }
             if tb, ok := toolBuilders[event.Index]; ok {
                 // Finish tool call
-                var args map[string]interface{}
-                if err := json.Unmarshal([]byte(tb.JSONBuffer), &args); err != nil {
-                    // If unmarshal fails, maybe it's empty string or partial?
-                    // For MVP, we ignore error or create empty map
-                    args = make(map[string]interface{})
+                tc := newToolCall(tb.ID, tb.Name, tb.JSONBuffer)
+
+                if tb.Server {
+                    block, _ := json.Marshal(apiContentBlock{Type: "server_tool_use", ID: tb.ID, Name: tb.Name, Input: tc.Args})
+                    ordered = append(ordered, block)
+                    usedServerTools = true
+                    if outputChan != nil {
+                        outputChan <- describeServerToolUse(tb.Name, tc.Args)
+                    }
+                    delete(toolBuilders, event.Index)
+                    break
                 }
                 
-                finalMsg.ToolCalls = append(finalMsg.ToolCalls, ToolCall{
-                    ID: tb.ID,
-                    Name: tb.Name,
-                    Args: args,
-                })
+                finalMsg.ToolCalls = append(finalMsg.ToolCalls, tc)
                 delete(toolBuilders, event.Index)
             }
         case "message_stop":
@@ -358,5 +492,103 @@ func (c *AnthropicClient) GenerateStream(ctx context.Context, messages []Message
         }
     }
 
+    if usedServerTools {
+        finalMsg.ServerBlocks = ordered
+    }
 	return finalMsg, nil
 }
+
+// authorize sets the version and credential headers of a request, with
+// the beta features it needs
+func (c *AnthropicClient) authorize(ctx context.Context, req *http.Request, betas ...string) error {
+	req.Header.Set("anthropic-version", "2023-06-01")
+	if c.tokens != nil {
+		token, err := c.tokens(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		betas = append(betas, "oauth-2025-04-20")
+	} else {
+		req.Header.Set("x-api-key", c.apiKey)
+	}
+	if len(betas) > 0 {
+		req.Header.Set("anthropic-beta", strings.Join(betas, ","))
+	}
+	return nil
+}
+
+// resultFileIDs returns the files a server tool result says it created,
+// such as the outputs of code execution
+func resultFileIDs(block json.RawMessage) []string {
+	var result struct {
+		Content struct {
+			Content []struct {
+				FileID string `json:"file_id"`
+			} `json:"content"`
+		} `json:"content"`
+	}
+	if json.Unmarshal(block, &result) != nil {
+		return nil
+	}
+	var ids []string
+	for _, out := range result.Content.Content {
+		if out.FileID != "" {
+			ids = append(ids, out.FileID)
+		}
+	}
+	return ids
+}
+
+// DownloadFile fetches a file created by a server tool from the Files API,
+// with its name and media type
+func (c *AnthropicClient) DownloadFile(ctx context.Context, fileID string) (string, string, []byte, error) {
+	base := strings.TrimSuffix(c.endpoint, "/messages") + "/files/" + url.PathEscape(fileID)
+	get := func(endpoint string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.authorize(ctx, req, "files-api-2025-04-14"); err != nil {
+			return nil, err
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, newAPIError("anthropic", resp)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	var meta struct {
+		Filename string `json:"filename"`
+		MimeType string `json:"mime_type"`
+	}
+	data, err := get(base)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return "", "", nil, fmt.Errorf("invalid file metadata: %w", err)
+	}
+	if data, err = get(base + "/content"); err != nil {
+		return "", "", nil, err
+	}
+	return meta.Filename, meta.MimeType, data, nil
+}
+
+// describeServerToolUse is shown in the stream while Anthropic runs a server tool
+func describeServerToolUse(name string, args map[string]interface{}) string {
+	switch name {
+	case "web_search":
+		query, _ := args["query"].(string)
+		return fmt.Sprintf("\n[Searching the web: %s]\n", query)
+	case "code_execution":
+		return "\n[Running code on Anthropic's sandbox]\n"
+	default:
+		return fmt.Sprintf("\n[Running server tool: %s]\n", name)
+	}
+}
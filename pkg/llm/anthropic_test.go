@@ -1,7 +1,15 @@
 package llm
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/jbdamask/john-code/pkg/config"
 )
 
 func TestNewAnthropicClientEndpoint(t *testing.T) {
@@ -23,3 +31,245 @@ func TestNewAnthropicClientEndpoint(t *testing.T) {
 		}
 	}
 }
+
+func TestAnthropicServerTools(t *testing.T) {
+	var request map[string]interface{}
+	var beta string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		beta = r.Header.Get("anthropic-beta")
+		events := []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":120,"cache_read_input_tokens":30,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check. "}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"go 1.24\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[{"type":"web_search_result","url":"https://go.dev","title":"Go"}]}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"content_block_start","index":3,"content_block":{"type":"text","text":"","citations":[]}}`,
+			`{"type":"content_block_delta","index":3,"delta":{"type":"citations_delta","citation":{"type":"web_search_result_location","url":"https://go.dev","cited_text":"Go 1.24 is released"}}}`,
+			`{"type":"content_block_delta","index":3,"delta":{"type":"text_delta","text":"Go 1.24 is out."}}`,
+			`{"type":"content_block_stop","index":3}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	client.EnableServerTools(config.ServerToolSettings{WebSearch: true, CodeExecution: true})
+	if !client.ReplacesTool("WebSearch") || client.ReplacesTool("WebFetch") {
+		t.Error("Expected the server web_search to replace only WebSearch")
+	}
+
+	out := make(chan string, 10)
+	msg, err := client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "news?"}}, nil, out)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+
+	tools, _ := request["tools"].([]interface{})
+	if len(tools) != 2 || tools[0].(map[string]interface{})["type"] != "web_search_20250305" {
+		t.Errorf("Expected server tools in request, got %v", request["tools"])
+	}
+	if beta == "" {
+		t.Error("Expected anthropic-beta header for code execution")
+	}
+	if msg.Content != "Let me check. Go 1.24 is out." || len(msg.ToolCalls) != 0 {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if msg.Usage == nil || msg.Usage.InputTokens != 150 || msg.Usage.OutputTokens != 42 {
+		t.Errorf("Unexpected usage: %+v", msg.Usage)
+	}
+	if len(msg.ServerBlocks) != 4 || !strings.Contains(string(msg.ServerBlocks[1]), `"query":"go 1.24"`) ||
+		!strings.Contains(string(msg.ServerBlocks[2]), "web_search_tool_result") ||
+		!strings.Contains(string(msg.ServerBlocks[3]), `"cited_text":"Go 1.24 is released"`) {
+		t.Errorf("Unexpected server blocks: %s", msg.ServerBlocks)
+	}
+	if text, note := <-out, <-out; text != "Let me check. " || !strings.Contains(note, "Searching the web: go 1.24") {
+		t.Errorf("Expected search note in stream, got %q", note)
+	}
+
+	// The blocks are sent back in their order, citations included
+	_, err = client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "news?"}, *msg, {Role: RoleUser, Content: "thanks"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	messages := request["messages"].([]interface{})
+	content := messages[1].(map[string]interface{})["content"].([]interface{})
+	var types []string
+	for _, block := range content {
+		types = append(types, block.(map[string]interface{})["type"].(string))
+	}
+	if strings.Join(types, " ") != "text server_tool_use web_search_tool_result text" {
+		t.Errorf("Unexpected assistant content: %v", content)
+	}
+	if citations, _ := content[3].(map[string]interface{})["citations"].([]interface{}); len(citations) != 1 {
+		t.Errorf("Expected the citation sent back, got %v", content[3])
+	}
+}
+
+func TestAnthropicMalformedToolArgs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events := []string{
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"Read","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"file_path\": \"/tmp/a"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_2","name":"TodoRead","input":{}}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	msg, err := client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "read it"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if len(msg.ToolCalls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %+v", msg.ToolCalls)
+	}
+	if tc := msg.ToolCalls[0]; tc.ArgsError == "" || tc.RawArgs != `{"file_path": "/tmp/a` {
+		t.Errorf("Expected truncated arguments to be flagged, got %+v", tc)
+	}
+	if tc := msg.ToolCalls[1]; tc.ArgsError != "" || tc.Args == nil {
+		t.Errorf("A call without arguments is valid, got %+v", tc)
+	}
+}
+
+func TestAnthropicAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req_123")
+		w.WriteHeader(529)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	_, err := client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}, nil, nil)
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("Expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != 529 || apiErr.RequestID != "req_123" || apiErr.Provider != "anthropic" {
+		t.Errorf("Unexpected error fields: %+v", apiErr)
+	}
+	if !strings.Contains(err.Error(), "status 529") || !strings.Contains(err.Error(), "req_123") {
+		t.Errorf("Unexpected message: %v", err)
+	}
+}
+
+func TestAnthropicDeterministicSampling(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Header().Set("request-id", "req_ok")
+		fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	client.SetSampling(DeterministicSampling())
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+	first, err := client.GenerateStream(context.Background(), messages, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if first.RequestID != "req_ok" {
+		t.Errorf("Expected the request ID on the response, got %q", first.RequestID)
+	}
+	second, _ := client.GenerateStream(context.Background(), messages, nil, nil)
+
+	if temp, ok := bodies[0]["temperature"]; !ok || temp != 0.0 {
+		t.Errorf("Expected temperature 0 in request, got %v", bodies[0])
+	}
+	if _, ok := bodies[0]["top_p"]; ok {
+		t.Errorf("top_p must not be sent alongside temperature")
+	}
+	if !strings.HasPrefix(first.RequestHash, "sha256:") || first.RequestHash != second.RequestHash {
+		t.Errorf("Expected equal request hashes, got %q and %q", first.RequestHash, second.RequestHash)
+	}
+}
+
+func TestAnthropicToolResultError(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	messages := []Message{
+		{Role: RoleUser, Content: "read both"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "t1", Name: "Read"}, {ID: "t2", Name: "Read"}}},
+		{Role: RoleTool, ToolResult: &ToolResult{ToolCallID: "t1", ToolName: "Read", Content: "package main"}},
+		{Role: RoleTool, ToolResult: &ToolResult{ToolCallID: "t2", ToolName: "Read", Content: "Error executing tool: no such file", IsError: true}},
+	}
+	if _, err := client.GenerateStream(context.Background(), messages, nil, nil); err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if len(body.Messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(body.Messages))
+	}
+	if ok := string(body.Messages[2].Content); strings.Contains(ok, "is_error") {
+		t.Errorf("Successful result flagged: %s", ok)
+	}
+	if failed := string(body.Messages[3].Content); !strings.Contains(failed, `"is_error":true`) {
+		t.Errorf("Expected is_error on the failed result, got %s", failed)
+	}
+}
+
+func TestAnthropicCodeExecutionFiles(t *testing.T) {
+	var betas []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/messages":
+			for _, e := range []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"","return_code":0,"content":[{"type":"code_execution_output","file_id":"file_abc"}]}}}`,
+				`{"type":"message_stop"}`,
+			} {
+				fmt.Fprintf(w, "data: %s\n\n", e)
+			}
+		case "/v1/files/file_abc":
+			betas = append(betas, r.Header.Get("anthropic-beta"))
+			fmt.Fprint(w, `{"id":"file_abc","filename":"chart.png","mime_type":"image/png"}`)
+		case "/v1/files/file_abc/content":
+			fmt.Fprint(w, "PNGDATA")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	msg, err := client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "plot"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if len(msg.Artifacts) != 1 || msg.Artifacts[0].FileID != "file_abc" || len(msg.ServerBlocks) != 1 {
+		t.Fatalf("Expected the output file as an artifact, got %+v", msg.Artifacts)
+	}
+	name, mediaType, data, err := client.DownloadFile(context.Background(), "file_abc")
+	if err != nil || name != "chart.png" || mediaType != "image/png" || string(data) != "PNGDATA" {
+		t.Errorf("DownloadFile = %q, %q, %q, %v", name, mediaType, data, err)
+	}
+	if len(betas) != 1 || !strings.Contains(betas[0], "files-api") {
+		t.Errorf("Expected the Files API beta, got %v", betas)
+	}
+}
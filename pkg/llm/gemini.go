@@ -10,8 +10,10 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/lang"
 )
 
 const GeminiAPIBase = "https://generativelanguage.googleapis.com/v1beta/models"
@@ -20,6 +22,7 @@ type GeminiClient struct {
 	apiKey   string
 	model    string
 	client   *http.Client
+	sampling Sampling
 }
 
 func NewGeminiClient(apiKey string, model string) *GeminiClient {
@@ -30,10 +33,15 @@ func NewGeminiClient(apiKey string, model string) *GeminiClient {
 	return &GeminiClient{
 		apiKey: apiKey,
 		model:  model,
-		client: &http.Client{},
+		client: config.NewProviderHTTPClient("gemini"),
 	}
 }
 
+// SetSampling pins the sampling parameters; Gemini accepts all of them
+func (c *GeminiClient) SetSampling(s Sampling) {
+	c.sampling = s
+}
+
 // Gemini API structures
 type geminiRequest struct {
 	Contents          []geminiContent         `json:"contents"`
@@ -48,7 +56,8 @@ type geminiToolConfig struct {
 }
 
 type geminiFunctionCallingConfig struct {
-	Mode string `json:"mode"` // AUTO, ANY, NONE, VALIDATED
+	Mode                 string   `json:"mode"` // AUTO, ANY, NONE, VALIDATED
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
 }
 
 type geminiContent struct {
@@ -78,6 +87,15 @@ type geminiFunctionResponse struct {
 	Response map[string]interface{} `json:"response"`
 }
 
+// geminiToolResponse puts a tool result under "result", or under "error"
+// when it failed, as Gemini expects
+func geminiToolResponse(r *ToolResult) map[string]interface{} {
+	if r.IsError {
+		return map[string]interface{}{"error": r.Content}
+	}
+	return map[string]interface{}{"result": r.Content}
+}
+
 type geminiTool struct {
 	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
 }
@@ -89,7 +107,10 @@ type geminiFunctionDeclaration struct {
 }
 
 type geminiGenerationConfig struct {
-	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	Seed            *int64   `json:"seed,omitempty"`
 }
 
 // Response structures
@@ -105,7 +126,11 @@ type geminiCandidate struct {
 
 // Streaming structures
 type geminiStreamChunk struct {
-	Candidates []geminiCandidate `json:"candidates"`
+	Candidates    []geminiCandidate `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata,omitempty"`
 }
 
 func (c *GeminiClient) Generate(ctx context.Context, messages []Message, tools []interface{}) (*Message, error) {
@@ -138,20 +163,7 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 				if err != nil {
 					continue
 				}
-				ext := strings.ToLower(filepath.Ext(imgPath))
-				var mimeType string
-				switch ext {
-				case ".jpg", ".jpeg":
-					mimeType = "image/jpeg"
-				case ".png":
-					mimeType = "image/png"
-				case ".gif":
-					mimeType = "image/gif"
-				case ".webp":
-					mimeType = "image/webp"
-				default:
-					mimeType = "image/jpeg"
-				}
+				mimeType := lang.ImageType(imgPath, data)
 				encoded := base64.StdEncoding.EncodeToString(data)
 				content.Parts = append(content.Parts, geminiPart{
 					InlineData: &geminiInlineData{
@@ -170,7 +182,7 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 			}
 
 			if msg.Content != "" {
-				content.Parts = append(content.Parts, geminiPart{Text: msg.Content})
+				content.Parts = append(content.Parts, geminiPart{Text: msg.SentText()})
 			}
 
 			for _, tc := range msg.ToolCalls {
@@ -192,9 +204,7 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 					{
 						FunctionResponse: &geminiFunctionResponse{
 							Name: msg.ToolResult.ToolName,
-							Response: map[string]interface{}{
-								"result": msg.ToolResult.Content,
-							},
+							Response: geminiToolResponse(msg.ToolResult),
 						},
 					},
 				},
@@ -253,15 +263,17 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 		SystemInstruction: systemInstruction,
 		GenerationConfig: &geminiGenerationConfig{
 			MaxOutputTokens: 8192,
+			Temperature:     c.sampling.Temperature,
+			TopP:            c.sampling.TopP,
+			Seed:            c.sampling.Seed,
 		},
 	}
 
-	// Add toolConfig if we have tools - use AUTO mode for flexibility
+	// Add toolConfig if we have tools: AUTO unless the request forces or
+	// forbids tool calls
 	if len(geminiTools) > 0 {
 		reqBody.ToolConfig = &geminiToolConfig{
-			FunctionCallingConfig: &geminiFunctionCallingConfig{
-				Mode: "AUTO",
-			},
+			FunctionCallingConfig: ToolChoiceFrom(ctx).gemini(),
 		}
 	}
 
@@ -310,12 +322,14 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 				debugFile.Close()
 			}
 		}
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		return nil, &APIError{Provider: "gemini", StatusCode: resp.StatusCode, RequestID: responseRequestID(resp), Message: string(bodyBytes)}
 	}
 
 	finalMsg := &Message{
-		Role:      RoleAssistant,
-		ToolCalls: []ToolCall{},
+		Role:        RoleAssistant,
+		ToolCalls:   []ToolCall{},
+		RequestHash: c.sampling.requestHash(jsonData),
+		RequestID:   responseRequestID(resp),
 	}
 
 	reader := bufio.NewReader(resp.Body)
@@ -354,6 +368,11 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 			continue
 		}
 
+		// Usage is cumulative; the last chunk has the totals
+		if u := chunk.UsageMetadata; u != nil {
+			finalMsg.Usage = &Usage{InputTokens: u.PromptTokenCount, OutputTokens: u.CandidatesTokenCount}
+		}
+
 		for _, candidate := range chunk.Candidates {
 			// Check for malformed function call error
 			if candidate.FinishReason == "MALFORMED_FUNCTION_CALL" {
@@ -375,6 +394,13 @@ func (c *GeminiClient) GenerateStream(ctx context.Context, messages []Message, t
 					}
 				}
 
+				if part.InlineData != nil {
+					// Image output, as from image generation models
+					if data, err := base64.StdEncoding.DecodeString(part.InlineData.Data); err == nil {
+						finalMsg.Artifacts = append(finalMsg.Artifacts, Artifact{MediaType: part.InlineData.MimeType, Data: data})
+					}
+				}
+
 				if part.FunctionCall != nil {
 					finalMsg.ToolCalls = append(finalMsg.ToolCalls, ToolCall{
 						ID:   fmt.Sprintf("call_%d", toolCallIndex),
@@ -145,3 +145,17 @@ func TestSanitizeSchemaForGemini(t *testing.T) {
 		})
 	}
 }
+
+func TestGeminiToolResponse(t *testing.T) {
+	ok := geminiToolResponse(&ToolResult{Content: "done"})
+	failed := geminiToolResponse(&ToolResult{Content: "Error: no such file", IsError: true})
+	if !reflect.DeepEqual(ok, map[string]interface{}{"result": "done"}) {
+		t.Errorf("Unexpected response %v", ok)
+	}
+	if !reflect.DeepEqual(failed, map[string]interface{}{"error": "Error: no such file"}) {
+		t.Errorf("Unexpected error response %v", failed)
+	}
+	if got := (&ToolResult{Content: "Permission denied", IsError: true}).ErrorText(); got != "Error: Permission denied" {
+		t.Errorf("ErrorText = %q", got)
+	}
+}
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Probe is the outcome of a health probe: one cheap authenticated request
+// to the provider's models endpoint, timed by phase so a slow request can be
+// put down to the network or to the provider
+type Probe struct {
+	Provider Provider
+	Endpoint string // Without credentials
+	Status   int    // HTTP status; 0 when no response arrived
+	Err      error  // Transport failure or error status
+	Reused   bool   // An open connection was used, so DNS, Connect and TLS are 0
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	Server   time.Duration // From sending the request to the first response byte
+	Total    time.Duration
+}
+
+// Network is the time spent reaching the provider before it could answer
+func (p *Probe) Network() time.Duration {
+	return p.DNS + p.Connect + p.TLS
+}
+
+// OK reports whether the provider answered the probe successfully
+func (p *Probe) OK() bool {
+	return p.Err == nil
+}
+
+// HealthClient is implemented by clients that can probe their provider
+type HealthClient interface {
+	Probe(ctx context.Context) *Probe
+}
+
+// runProbe sends req with client and times its phases
+func runProbe(client *http.Client, req *http.Request, provider Provider) *Probe {
+	p := &Probe{Provider: provider, Endpoint: redactURL(req.URL)}
+	var dnsStart, connectStart, tlsStart, wrote time.Time
+	var firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotConn:  func(info httptrace.GotConnInfo) { p.Reused = info.Reused },
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			if !dnsStart.IsZero() {
+				p.DNS = time.Since(dnsStart)
+			}
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			if !connectStart.IsZero() {
+				p.Connect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if !tlsStart.IsZero() {
+				p.TLS = time.Since(tlsStart)
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wrote = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		p.Total = time.Since(start)
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = p.Endpoint
+		}
+		p.Err = err
+		return p
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	p.Total = time.Since(start)
+	p.Status = resp.StatusCode
+	if !wrote.IsZero() && !firstByte.IsZero() {
+		p.Server = firstByte.Sub(wrote)
+	}
+	if resp.StatusCode != http.StatusOK {
+		p.Err = fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return p
+}
+
+// redactURL drops query parameters that carry keys
+func redactURL(u *url.URL) string {
+	clean := *u
+	q := clean.Query()
+	if q.Has("key") {
+		q.Set("key", "REDACTED")
+		clean.RawQuery = q.Encode()
+	}
+	return clean.String()
+}
+
+// failedProbe reports a probe that could not be sent
+func failedProbe(provider Provider, err error) *Probe {
+	return &Probe{Provider: provider, Err: err}
+}
+
+// Probe lists one model, which needs a valid key but costs nothing
+func (c *AnthropicClient) Probe(ctx context.Context) *Probe {
+	endpoint := strings.TrimSuffix(c.endpoint, "/messages") + "/models?limit=1"
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err == nil {
+		err = c.authorize(ctx, req)
+	}
+	if err != nil {
+		return failedProbe(ProviderAnthropic, err)
+	}
+	return runProbe(c.client, req, ProviderAnthropic)
+}
+
+// Probe lists the models, which needs a valid key but costs nothing
+func (c *OpenAIClient) Probe(ctx context.Context) *Probe {
+	endpoint := strings.TrimSuffix(c.endpoint, "/responses") + "/models"
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return failedProbe(ProviderOpenAI, err)
+	}
+	apiKey := c.apiKey
+	if c.tokens != nil {
+		if apiKey, err = c.tokens(ctx); err != nil {
+			return failedProbe(ProviderOpenAI, err)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	return runProbe(c.client, req, ProviderOpenAI)
+}
+
+// Probe lists one model, which needs a valid key but costs nothing
+func (c *GeminiClient) Probe(ctx context.Context) *Probe {
+	endpoint := GeminiAPIBase + "?pageSize=1&key=" + url.QueryEscape(c.apiKey)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return failedProbe(ProviderGoogle, err)
+	}
+	return runProbe(c.client, req, ProviderGoogle)
+}
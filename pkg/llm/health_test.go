@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicProbe(t *testing.T) {
+	var path, key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("x-api-key")
+		if key != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropicClient("good", server.URL, "").Probe(context.Background())
+	if !p.OK() || p.Status != 200 || path != "/v1/models" || p.Provider != ProviderAnthropic {
+		t.Fatalf("probe = %+v, path %q", p, path)
+	}
+	if p.Total <= 0 || p.Connect <= 0 || p.Reused {
+		t.Errorf("phases not timed: %+v", p)
+	}
+
+	p = NewAnthropicClient("bad", server.URL, "").Probe(context.Background())
+	if p.OK() || p.Status != 401 {
+		t.Errorf("refused key probe = %+v", p)
+	}
+}
+
+func TestProbeRedactsKey(t *testing.T) {
+	c := NewGeminiClient("secret-key", "")
+	c.client = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, context.DeadlineExceeded
+	})}
+	p := c.Probe(context.Background())
+	if p.OK() || strings.Contains(p.Endpoint, "secret-key") || strings.Contains(p.Err.Error(), "secret-key") {
+		t.Errorf("key leaked: %q, %v", p.Endpoint, p.Err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
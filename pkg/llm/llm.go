@@ -1,6 +1,13 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
 
 type Role string
 
@@ -15,12 +22,51 @@ type ToolCall struct {
 	ID   string                 `json:"id"`
 	Name string                 `json:"name"`
 	Args map[string]interface{} `json:"args"`
+	// ArgsError is set when the streamed arguments were not valid JSON. Args
+	// is then empty and RawArgs holds what was received.
+	ArgsError string `json:"args_error,omitempty"`
+	RawArgs   string `json:"raw_args,omitempty"`
+}
+
+// newToolCall builds a tool call from streamed arguments, recording rather
+// than hiding arguments that don't parse
+func newToolCall(id, name, rawArgs string) ToolCall {
+	tc := ToolCall{ID: id, Name: name, Args: make(map[string]interface{})}
+	if strings.TrimSpace(rawArgs) == "" {
+		// Tools without parameters stream no arguments
+		return tc
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(rawArgs), &args); err != nil {
+		tc.ArgsError = err.Error()
+		tc.RawArgs = rawArgs
+		return tc
+	}
+	if args == nil {
+		tc.ArgsError = "arguments are not a JSON object"
+		tc.RawArgs = rawArgs
+		return tc
+	}
+	tc.Args = args
+	return tc
 }
 
 type ToolResult struct {
 	ToolCallID string `json:"tool_use_id"`
 	ToolName   string `json:"tool_name"` // Needed for Gemini function responses
 	Content    string `json:"content"`
+	// IsError marks a failed or refused call. Providers with an error flag
+	// get it there; the others see it in the content.
+	IsError bool `json:"is_error,omitempty"`
+}
+
+// ErrorText returns the content for providers without an error flag: a
+// failed result that does not already say so starts with "Error: "
+func (r *ToolResult) ErrorText() string {
+	if !r.IsError || strings.HasPrefix(r.Content, "Error") {
+		return r.Content
+	}
+	return "Error: " + r.Content
 }
 
 type Message struct {
@@ -29,6 +75,92 @@ type Message struct {
     Images     []string    `json:"images,omitempty"` // Paths to images
     ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
     ToolResult *ToolResult `json:"tool_result,omitempty"`
+    // ServerBlocks are the content blocks of a response that used
+    // provider-executed tools (e.g. Anthropic web_search): the tool calls,
+    // their results and the text blocks around them with their citations,
+    // in the order given and kept verbatim so they can be sent back
+    // unchanged. Content still holds all of the text.
+    ServerBlocks []json.RawMessage `json:"server_blocks,omitempty"`
+    // Usage is the token count the provider reported for an assistant message
+    Usage *Usage `json:"usage,omitempty"`
+    // RequestHash identifies the exact request that produced an assistant
+    // message, when the client's Sampling asks for it
+    RequestHash string `json:"request_hash,omitempty"`
+    // RequestID is the provider's ID for the request that produced an
+    // assistant message, for escalating problems with the provider
+    RequestID string `json:"request_id,omitempty"`
+    // Interrupted marks an assistant message whose generation was cancelled
+    // or failed partway; Content is the text streamed before it stopped
+    Interrupted bool `json:"interrupted,omitempty"`
+    // Artifacts are the images and files an assistant message produced,
+    // such as generated images or files written by server-side code
+    Artifacts []Artifact `json:"artifacts,omitempty"`
+}
+
+// Artifact is non-text output of the model. Clients fill in Data, or FileID
+// for files kept by the provider; the agent saves it and sets Path.
+type Artifact struct {
+	Name      string `json:"name,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Path      string `json:"path,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+	Data      []byte `json:"-"`
+}
+
+// FileClient is implemented by clients whose server tools create files that
+// must be downloaded separately, such as Anthropic code execution
+type FileClient interface {
+	DownloadFile(ctx context.Context, fileID string) (name, mediaType string, data []byte, err error)
+}
+
+// Usage counts the tokens of one model request. InputTokens includes cached
+// prompt tokens.
+type Usage struct {
+    InputTokens  int `json:"input_tokens"`
+    OutputTokens int `json:"output_tokens"`
+}
+
+// APIError is a request an LLM provider rejected or failed
+type APIError struct {
+    Provider   string
+    StatusCode int    // 0 for errors reported inside a stream
+    Type       string // Provider error type, e.g. overloaded_error
+    RequestID  string // For support requests to the provider
+    Message    string
+}
+
+func (e *APIError) Error() string {
+    var msg string
+    if e.StatusCode == 0 {
+        msg = fmt.Sprintf("API stream error: %s", e.Message)
+    } else {
+        msg = fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+    }
+    if e.RequestID != "" {
+        msg += fmt.Sprintf(" (request id %s)", e.RequestID)
+    }
+    return msg
+}
+
+// newAPIError builds the error for a non-200 response
+func newAPIError(provider string, resp *http.Response) *APIError {
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+    return &APIError{
+        Provider:   provider,
+        StatusCode: resp.StatusCode,
+        RequestID:  responseRequestID(resp),
+        Message:    string(body),
+    }
+}
+
+// responseRequestID returns the provider's ID for a request, if it sent one
+func responseRequestID(resp *http.Response) string {
+    for _, h := range []string{"request-id", "anthropic-request-id", "x-request-id", "x-goog-request-id"} {
+        if id := resp.Header.Get(h); id != "" {
+            return id
+        }
+    }
+    return ""
 }
 
 type Client interface {
@@ -36,6 +168,16 @@ type Client interface {
     GenerateStream(ctx context.Context, messages []Message, tools []interface{}, outputChan chan<- string) (*Message, error)
 }
 
+// TokenFunc returns a current access token, for clients authenticated by an
+// OAuth login rather than an API key
+type TokenFunc func(ctx context.Context) (string, error)
+
+// ServerToolClient is implemented by clients that run some tools on the
+// provider's side. Local tools they replace are not offered to the model.
+type ServerToolClient interface {
+    ReplacesTool(name string) bool
+}
+
 type MockClient struct{}
 
 func NewMockClient() *MockClient {
@@ -60,3 +202,64 @@ func (m *MockClient) GenerateStream(ctx context.Context, messages []Message, too
         Content: response,
     }, nil
 }
+
+
+// ScriptStep produces the next assistant response from the conversation so far.
+type ScriptStep func(messages []Message) *Message
+
+// ScriptedClient replays a fixed sequence of responses, one per request. It
+// lets the agent loop be driven end-to-end without calling a real provider.
+type ScriptedClient struct {
+    steps   []ScriptStep
+    calls   int
+    choices []ToolChoice
+}
+
+// NewScriptedClient creates a client that returns the given responses in order.
+func NewScriptedClient(responses ...Message) *ScriptedClient {
+    steps := make([]ScriptStep, len(responses))
+    for i := range responses {
+        resp := responses[i]
+        steps[i] = func([]Message) *Message { return &resp }
+    }
+    return &ScriptedClient{steps: steps}
+}
+
+// NewScriptedClientFromSteps creates a client whose responses are computed
+// lazily, so later steps can depend on earlier tool results.
+func NewScriptedClientFromSteps(steps ...ScriptStep) *ScriptedClient {
+    return &ScriptedClient{steps: steps}
+}
+
+// Calls returns the number of requests the client has served.
+func (c *ScriptedClient) Calls() int {
+    return c.calls
+}
+
+// ToolChoices returns the tool choice of each request, in order
+func (c *ScriptedClient) ToolChoices() []ToolChoice {
+    return c.choices
+}
+
+func (c *ScriptedClient) Generate(ctx context.Context, messages []Message, tools []interface{}) (*Message, error) {
+    return c.GenerateStream(ctx, messages, tools, nil)
+}
+
+func (c *ScriptedClient) GenerateStream(ctx context.Context, messages []Message, tools []interface{}, outputChan chan<- string) (*Message, error) {
+    if c.calls >= len(c.steps) {
+        return nil, fmt.Errorf("scripted client exhausted after %d responses", len(c.steps))
+    }
+    resp := c.steps[c.calls](messages)
+    c.calls++
+    c.choices = append(c.choices, ToolChoiceFrom(ctx))
+    if resp == nil {
+        return nil, nil
+    }
+
+    out := *resp
+    out.Role = RoleAssistant
+    if outputChan != nil && out.Content != "" {
+        outputChan <- out.Content
+    }
+    return &out, nil
+}
@@ -1,5 +1,10 @@
 package llm
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Provider represents an LLM provider
 type Provider string
 
@@ -7,6 +12,7 @@ const (
 	ProviderAnthropic Provider = "anthropic"
 	ProviderOpenAI    Provider = "openai"
 	ProviderGoogle    Provider = "google"
+	ProviderOllama    Provider = "ollama" // Local models, only as the offline fallback
 )
 
 // ModelInfo contains information about a supported model
@@ -16,6 +22,9 @@ type ModelInfo struct {
 	Provider    Provider // Provider (anthropic, openai, google)
 	APIModel    string   // Model name to send to API
 	Description string   // Short description
+	InputPrice  float64  // USD per million input tokens
+	OutputPrice float64  // USD per million output tokens
+	Context     int      // Context window in tokens
 }
 
 // SupportedModels lists all models supported by John Code
@@ -27,6 +36,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderAnthropic,
 		APIModel:    "claude-sonnet-4-5-20250929",
 		Description: "Balanced performance and speed (default)",
+		InputPrice:  3,
+		OutputPrice: 15,
+		Context:     200000,
 	},
 	{
 		ID:          "claude-opus-4.5",
@@ -34,6 +46,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderAnthropic,
 		APIModel:    "claude-opus-4-5-20251101",
 		Description: "Most capable, best for complex tasks",
+		InputPrice:  5,
+		OutputPrice: 25,
+		Context:     200000,
 	},
 	{
 		ID:          "claude-haiku-4.5",
@@ -41,6 +56,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderAnthropic,
 		APIModel:    "claude-haiku-4-5-20251001",
 		Description: "Fastest, best for simple tasks",
+		InputPrice:  1,
+		OutputPrice: 5,
+		Context:     200000,
 	},
 
 	// OpenAI GPT models
@@ -50,6 +68,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderOpenAI,
 		APIModel:    "gpt-5",
 		Description: "OpenAI's most capable model",
+		InputPrice:  1.25,
+		OutputPrice: 10,
+		Context:     400000,
 	},
 	{
 		ID:          "gpt-5-mini",
@@ -57,6 +78,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderOpenAI,
 		APIModel:    "gpt-5-mini",
 		Description: "Balanced performance and cost",
+		InputPrice:  0.25,
+		OutputPrice: 2,
+		Context:     400000,
 	},
 	{
 		ID:          "gpt-5-nano",
@@ -64,6 +88,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderOpenAI,
 		APIModel:    "gpt-5-nano",
 		Description: "Fastest and most affordable",
+		InputPrice:  0.05,
+		OutputPrice: 0.4,
+		Context:     400000,
 	},
 
 	// Google Gemini models
@@ -73,6 +100,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderGoogle,
 		APIModel:    "gemini-2.5-pro",
 		Description: "Google's most capable model",
+		InputPrice:  1.25,
+		OutputPrice: 10,
+		Context:     1048576,
 	},
 	{
 		ID:          "gemini-2.5-flash",
@@ -80,6 +110,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderGoogle,
 		APIModel:    "gemini-2.5-flash",
 		Description: "Fast and efficient",
+		InputPrice:  0.3,
+		OutputPrice: 2.5,
+		Context:     1048576,
 	},
 	{
 		ID:          "gemini-2.5-flash-lite",
@@ -87,6 +120,9 @@ var SupportedModels = []ModelInfo{
 		Provider:    ProviderGoogle,
 		APIModel:    "gemini-2.5-flash-lite",
 		Description: "Lightweight and quick",
+		InputPrice:  0.1,
+		OutputPrice: 0.4,
+		Context:     1048576,
 	},
 }
 
@@ -103,6 +139,11 @@ func GetModelByID(id string) *ModelInfo {
 	return nil
 }
 
+// Cost estimates the price in USD of the given token usage
+func (m ModelInfo) Cost(u Usage) float64 {
+	return (float64(u.InputTokens)*m.InputPrice + float64(u.OutputTokens)*m.OutputPrice) / 1e6
+}
+
 // GetModelsByProvider returns all models for a given provider
 func GetModelsByProvider(provider Provider) []ModelInfo {
 	var models []ModelInfo
@@ -113,3 +154,76 @@ func GetModelsByProvider(provider Provider) []ModelInfo {
 	}
 	return models
 }
+
+// ResolveModel finds the model meant by a name typed by the user. It accepts
+// an ID or API model name, a key of aliases (whose value is resolved in turn),
+// or any part of an ID ignoring case and punctuation, so "sonnet", "gpt5-mini"
+// and "flash" all work. When a fragment matches several models, the one whose
+// ID ends with it wins ("flash" is gemini-2.5-flash, not flash-lite);
+// otherwise the name is ambiguous.
+func ResolveModel(name string, aliases map[string]string) (*ModelInfo, error) {
+	return resolveModel(name, aliases, 0)
+}
+
+func resolveModel(name string, aliases map[string]string, depth int) (*ModelInfo, error) {
+	query := strings.ToLower(strings.TrimSpace(name))
+	if query == "" {
+		return nil, fmt.Errorf("no model given")
+	}
+	for _, m := range SupportedModels {
+		if strings.ToLower(m.ID) == query || strings.ToLower(m.APIModel) == query {
+			return GetModelByID(m.ID), nil
+		}
+	}
+	for alias, target := range aliases {
+		if strings.ToLower(alias) == query && target != "" {
+			if depth > 3 {
+				return nil, fmt.Errorf("model alias %q refers to itself", name)
+			}
+			m, err := resolveModel(target, aliases, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("model alias %q: %w", name, err)
+			}
+			return m, nil
+		}
+	}
+
+	fragment := normalizeModelName(query)
+	var matches, suffixMatches []ModelInfo
+	for _, m := range SupportedModels {
+		id := normalizeModelName(m.ID)
+		if strings.Contains(id, fragment) {
+			matches = append(matches, m)
+			if strings.HasSuffix(id, fragment) {
+				suffixMatches = append(suffixMatches, m)
+			}
+		}
+	}
+	best := matches
+	if len(matches) > 1 && len(suffixMatches) == 1 {
+		best = suffixMatches
+	}
+	switch len(best) {
+	case 0:
+		return nil, fmt.Errorf("unknown model %q", name)
+	case 1:
+		return GetModelByID(best[0].ID), nil
+	default:
+		ids := make([]string, len(best))
+		for i, m := range best {
+			ids[i] = m.ID
+		}
+		return nil, fmt.Errorf("model %q is ambiguous: %s", name, strings.Join(ids, ", "))
+	}
+}
+
+// normalizeModelName drops everything but letters and digits
+func normalizeModelName(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveModel(t *testing.T) {
+	aliases := map[string]string{
+		"default": "opus",
+		"fast":    "claude-haiku-4.5",
+		"cheap":   "fast",
+		"loop":    "loop",
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"claude-sonnet-4.5", "claude-sonnet-4.5"},
+		{"claude-opus-4-5-20251101", "claude-opus-4.5"},
+		{"Sonnet", "claude-sonnet-4.5"},
+		{"opus", "claude-opus-4.5"},
+		{"haiku", "claude-haiku-4.5"},
+		{"gpt5-mini", "gpt-5-mini"},
+		{"gpt5", "gpt-5"},
+		{"flash", "gemini-2.5-flash"},
+		{"flash-lite", "gemini-2.5-flash-lite"},
+		{"default", "claude-opus-4.5"},
+		{"cheap", "claude-haiku-4.5"},
+	}
+	for _, tt := range tests {
+		m, err := ResolveModel(tt.name, aliases)
+		if err != nil {
+			t.Errorf("ResolveModel(%q) failed: %v", tt.name, err)
+			continue
+		}
+		if m.ID != tt.want {
+			t.Errorf("ResolveModel(%q) = %s; want %s", tt.name, m.ID, tt.want)
+		}
+	}
+
+	for _, name := range []string{"", "llama", "loop"} {
+		if _, err := ResolveModel(name, aliases); err == nil {
+			t.Errorf("ResolveModel(%q) should fail", name)
+		}
+	}
+	if _, err := ResolveModel("claude", nil); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected \"claude\" to be ambiguous, got %v", err)
+	}
+}
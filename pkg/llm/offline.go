@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// IsOffline reports whether a request failed because the provider could not
+// be reached at all: its name did not resolve, or no connection to it or the
+// proxy could be made. Errors the provider answered with, slow answers and
+// cancelled requests do not count.
+func IsOffline(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+}
+
+// OfflineReason says in a few words why a request found the network down
+func OfflineReason(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "cannot resolve " + dnsErr.Name
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return "cannot connect to the proxy"
+	}
+	if errors.As(err, &opErr) && opErr.Addr != nil {
+		return "cannot connect to " + opErr.Addr.String()
+	}
+	return "cannot connect"
+}
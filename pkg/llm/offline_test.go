@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestIsOffline(t *testing.T) {
+	dns := fmt.Errorf("failed to send request: %w", &url.Error{Op: "Post", URL: "https://api.example.com",
+		Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "api.example.com", Err: "no such host", IsNotFound: true}}})
+	if !IsOffline(dns) || OfflineReason(dns) != "cannot resolve api.example.com" {
+		t.Errorf("DNS failure: offline %v, reason %q", IsOffline(dns), OfflineReason(dns))
+	}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	if !IsOffline(refused) {
+		t.Error("refused connection not offline")
+	}
+	for _, err := range []error{
+		&APIError{StatusCode: 503, Message: "overloaded"},
+		&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")},
+		fmt.Errorf("failed: %w", context.Canceled),
+	} {
+		if IsOffline(err) {
+			t.Errorf("IsOffline(%v)", err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// DefaultOllamaURL is where a local Ollama server listens unless
+// $OLLAMA_HOST says otherwise
+const DefaultOllamaURL = "http://localhost:11434"
+
+// OllamaClient talks to a local Ollama server through its native chat API.
+// It needs no network beyond the machine, so john can fall back to it
+// while the cloud providers cannot be reached.
+type OllamaClient struct {
+	baseURL  string
+	model    string
+	client   *http.Client
+	sampling Sampling
+}
+
+// NewOllamaClient creates a client for a model pulled into Ollama, such as
+// "qwen2.5-coder". An empty baseURL uses $OLLAMA_HOST or DefaultOllamaURL.
+func NewOllamaClient(baseURL, model string) *OllamaClient {
+	if baseURL == "" {
+		baseURL = os.Getenv("OLLAMA_HOST")
+	}
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	if !strings.Contains(baseURL, "://") {
+		// OLLAMA_HOST is often host:port
+		baseURL = "http://" + baseURL
+	}
+	return &OllamaClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		client:  config.NewProviderHTTPClient("ollama"),
+	}
+}
+
+// Model returns the Ollama model name
+func (c *OllamaClient) Model() string {
+	return c.model
+}
+
+// SetSampling pins the sampling parameters; Ollama takes all three
+func (c *OllamaClient) SetSampling(s Sampling) {
+	c.sampling = s
+}
+
+type ollamaRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Tools    []ollamaTool           `json:"tools,omitempty"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"` // Base64
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description,omitempty"`
+		Parameters  interface{} `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// ollamaChunk is one line of a streamed chat response
+type ollamaChunk struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (c *OllamaClient) Generate(ctx context.Context, messages []Message, tools []interface{}) (*Message, error) {
+	return c.GenerateStream(ctx, messages, tools, nil)
+}
+
+func (c *OllamaClient) GenerateStream(ctx context.Context, messages []Message, tools []interface{}, outputChan chan<- string) (*Message, error) {
+	reqBody := ollamaRequest{Model: c.model, Messages: ollamaMessages(messages), Tools: ollamaTools(tools), Stream: true}
+	options := make(map[string]interface{})
+	if c.sampling.Temperature != nil {
+		options["temperature"] = *c.sampling.Temperature
+	}
+	if c.sampling.TopP != nil {
+		options["top_p"] = *c.sampling.TopP
+	}
+	if c.sampling.Seed != nil {
+		options["seed"] = *c.sampling.Seed
+	}
+	if len(options) > 0 {
+		reqBody.Options = options
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("ollama", resp)
+	}
+
+	finalMsg := &Message{Role: RoleAssistant, ToolCalls: []ToolCall{}, RequestHash: c.sampling.requestHash(jsonData)}
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			return nil, &APIError{Provider: "ollama", Message: chunk.Error}
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if outputChan != nil {
+				outputChan <- chunk.Message.Content
+			}
+		}
+		for _, tc := range chunk.Message.ToolCalls {
+			args := tc.Function.Arguments
+			if args == nil {
+				args = make(map[string]interface{})
+			}
+			// Ollama does not number its tool calls
+			id := fmt.Sprintf("call_%d", len(finalMsg.ToolCalls)+1)
+			finalMsg.ToolCalls = append(finalMsg.ToolCalls, ToolCall{ID: id, Name: tc.Function.Name, Args: args})
+		}
+		if chunk.Done {
+			finalMsg.Usage = &Usage{InputTokens: chunk.PromptEvalCount, OutputTokens: chunk.EvalCount}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	finalMsg.Content = content.String()
+	return finalMsg, nil
+}
+
+// ollamaMessages converts the conversation. Tool calls are sent without
+// IDs, which Ollama does not use; results carry the tool's name instead.
+func ollamaMessages(messages []Message) []ollamaMessage {
+	out := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			out = append(out, ollamaMessage{Role: "system", Content: msg.Content})
+		case RoleUser:
+			m := ollamaMessage{Role: "user", Content: msg.Content}
+			for _, path := range msg.Images {
+				if data, err := os.ReadFile(path); err == nil {
+					m.Images = append(m.Images, base64.StdEncoding.EncodeToString(data))
+				}
+			}
+			out = append(out, m)
+		case RoleAssistant:
+			m := ollamaMessage{Role: "assistant", Content: msg.SentText()}
+			for _, tc := range msg.ToolCalls {
+				var call ollamaToolCall
+				call.Function.Name = tc.Name
+				call.Function.Arguments = tc.Args
+				m.ToolCalls = append(m.ToolCalls, call)
+			}
+			out = append(out, m)
+		case RoleTool:
+			out = append(out, ollamaMessage{Role: "tool", Content: msg.ToolResult.ErrorText(), ToolName: msg.ToolResult.ToolName})
+		}
+	}
+	return out
+}
+
+// ollamaTools converts tool definitions to Ollama's function format
+func ollamaTools(tools []interface{}) []ollamaTool {
+	var out []ollamaTool
+	for _, t := range tools {
+		toolMap, ok := t.(map[string]interface{})
+		if !ok {
+			data, err := json.Marshal(t)
+			if err != nil || json.Unmarshal(data, &toolMap) != nil {
+				continue
+			}
+		}
+		name, _ := toolMap["name"].(string)
+		if name == "" {
+			continue
+		}
+		var tool ollamaTool
+		tool.Type = "function"
+		tool.Function.Name = name
+		tool.Function.Description, _ = toolMap["description"].(string)
+		tool.Function.Parameters = toolMap["input_schema"]
+		out = append(out, tool)
+	}
+	return out
+}
+
+// Probe lists the local models, which tells whether Ollama is running
+func (c *OllamaClient) Probe(ctx context.Context) *Probe {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return failedProbe(ProviderOllama, err)
+	}
+	return runProbe(c.client, req, ProviderOllama)
+}
@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaStreamsTextAndToolCalls(t *testing.T) {
+	var got ollamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Let me "}}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"look."}}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"Read","arguments":{"file_path":"a.go"}}}]}}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":12,"eval_count":7}`)
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "qwen2.5-coder")
+	history := []Message{
+		{Role: RoleSystem, Content: "You are john."},
+		{Role: RoleUser, Content: "read a.go"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Name: "Glob", Args: map[string]interface{}{"pattern": "*.go"}}}},
+		{Role: RoleTool, ToolResult: &ToolResult{ToolCallID: "call_1", ToolName: "Glob", Content: "a.go"}},
+	}
+	tools := []interface{}{map[string]interface{}{"name": "Read", "description": "Read a file", "input_schema": map[string]interface{}{"type": "object"}}}
+	msg, err := client.GenerateStream(context.Background(), history, tools, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "Let me look." || len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Name != "Read" || msg.ToolCalls[0].Args["file_path"] != "a.go" {
+		t.Errorf("message = %+v", msg)
+	}
+	if msg.Usage == nil || msg.Usage.InputTokens != 12 || msg.Usage.OutputTokens != 7 {
+		t.Errorf("usage = %+v", msg.Usage)
+	}
+	if got.Model != "qwen2.5-coder" || len(got.Messages) != 4 || got.Messages[3].ToolName != "Glob" || len(got.Tools) != 1 || got.Tools[0].Function.Name != "Read" {
+		t.Errorf("request = %+v", got)
+	}
+}
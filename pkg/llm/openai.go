@@ -10,8 +10,10 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/lang"
 )
 
 const DefaultOpenAIEndpoint = "https://api.openai.com/v1/responses"
@@ -21,6 +23,8 @@ type OpenAIClient struct {
 	endpoint string
 	model    string
 	client   *http.Client
+	sampling Sampling
+	tokens   TokenFunc // Set for OAuth logins, instead of apiKey
 }
 
 func NewOpenAIClient(apiKey string, model string) *OpenAIClient {
@@ -32,10 +36,22 @@ func NewOpenAIClient(apiKey string, model string) *OpenAIClient {
 		apiKey:   apiKey,
 		endpoint: DefaultOpenAIEndpoint,
 		model:    model,
-		client:   &http.Client{},
+		client:   config.NewProviderHTTPClient("openai"),
 	}
 }
 
+// UseOAuth authenticates with tokens from john login instead of an API key
+func (c *OpenAIClient) UseOAuth(tokens TokenFunc) {
+	c.tokens = tokens
+}
+
+// SetSampling pins the sampling parameters. The Responses API takes no seed,
+// and reasoning models reject temperature and top_p, so for them nothing
+// is sent.
+func (c *OpenAIClient) SetSampling(s Sampling) {
+	c.sampling = s
+}
+
 // OpenAI Responses API structures
 type openAIRequest struct {
 	Model           string              `json:"model"`
@@ -44,6 +60,9 @@ type openAIRequest struct {
 	MaxOutputTokens int                 `json:"max_output_tokens,omitempty"`
 	Stream          bool                `json:"stream,omitempty"`
 	Instructions    string              `json:"instructions,omitempty"`
+	Temperature     *float64            `json:"temperature,omitempty"`
+	TopP            *float64            `json:"top_p,omitempty"`
+	ToolChoice      interface{}         `json:"tool_choice,omitempty"`
 }
 
 type openAIInputItem struct {
@@ -75,13 +94,20 @@ type openAITool struct {
 
 // Streaming event structures for Responses API
 type openAIStreamEvent struct {
-	Type        string `json:"type"`
-	ItemID      string `json:"item_id,omitempty"`
-	OutputIndex int    `json:"output_index,omitempty"`
-	Delta       string `json:"delta,omitempty"`
-	Name        string `json:"name,omitempty"`
-	CallID      string `json:"call_id,omitempty"`
-	Arguments   string `json:"arguments,omitempty"`
+	Type        string            `json:"type"`
+	ItemID      string            `json:"item_id,omitempty"`
+	OutputIndex int               `json:"output_index,omitempty"`
+	Delta       string            `json:"delta,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	CallID      string            `json:"call_id,omitempty"`
+	Arguments   string            `json:"arguments,omitempty"`
+	Item        *openAIOutputItem `json:"item,omitempty"` // output_item.added and .done
+	Response    *struct {
+		Usage *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage,omitempty"`
+	} `json:"response,omitempty"`
 }
 
 // Response object structure
@@ -92,12 +118,14 @@ type openAIResponse struct {
 }
 
 type openAIOutputItem struct {
-	Type      string `json:"type"`
-	ID        string `json:"id,omitempty"`
-	CallID    string `json:"call_id,omitempty"`
-	Name      string `json:"name,omitempty"`
-	Arguments string `json:"arguments,omitempty"`
-	Content   []struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	CallID       string `json:"call_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Arguments    string `json:"arguments,omitempty"`
+	Result       string `json:"result,omitempty"`        // image_generation_call: base64 image
+	OutputFormat string `json:"output_format,omitempty"` // image_generation_call: png, jpeg or webp
+	Content      []struct {
 		Type string `json:"type"`
 		Text string `json:"text,omitempty"`
 	} `json:"content,omitempty"`
@@ -134,20 +162,7 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 					if err != nil {
 						continue
 					}
-					ext := strings.ToLower(filepath.Ext(imgPath))
-					var mediaType string
-					switch ext {
-					case ".jpg", ".jpeg":
-						mediaType = "image/jpeg"
-					case ".png":
-						mediaType = "image/png"
-					case ".gif":
-						mediaType = "image/gif"
-					case ".webp":
-						mediaType = "image/webp"
-					default:
-						mediaType = "image/jpeg"
-					}
+					mediaType := lang.ImageType(imgPath, data)
 					encoded := base64.StdEncoding.EncodeToString(data)
 					parts = append(parts, openAIContentPart{
 						Type: "input_image",
@@ -178,7 +193,7 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 				// Regular assistant text message
 				inputItems = append(inputItems, openAIInputItem{
 					Role:    "assistant",
-					Content: msg.Content,
+					Content: msg.SentText(),
 				})
 			}
 
@@ -187,7 +202,7 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 			inputItems = append(inputItems, openAIInputItem{
 				Type:   "function_call_output",
 				CallID: msg.ToolResult.ToolCallID,
-				Output: msg.ToolResult.Content,
+				Output: msg.ToolResult.ErrorText(),
 			})
 		}
 	}
@@ -235,6 +250,13 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 		Stream:          true,
 		Instructions:    systemInstruction,
 	}
+	if len(openAITools) > 0 {
+		reqBody.ToolChoice = ToolChoiceFrom(ctx).openAI()
+	}
+	if !isReasoningModel(c.model) {
+		reqBody.Temperature = c.sampling.Temperature
+		reqBody.TopP = c.sampling.TopP
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -258,7 +280,13 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	apiKey := c.apiKey
+	if c.tokens != nil {
+		if apiKey, err = c.tokens(ctx); err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -277,21 +305,42 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 				debugFile.Close()
 			}
 		}
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		return nil, &APIError{Provider: "openai", StatusCode: resp.StatusCode, RequestID: responseRequestID(resp), Message: string(bodyBytes)}
 	}
 
 	finalMsg := &Message{
-		Role:      RoleAssistant,
-		ToolCalls: []ToolCall{},
+		Role:        RoleAssistant,
+		ToolCalls:   []ToolCall{},
+		RequestHash: c.sampling.requestHash(jsonData),
+		RequestID:   responseRequestID(resp),
 	}
 
-	// Track function calls being built
+	// Track function calls being built. Argument events refer to the output
+	// item ID and may omit the name and call ID, which only output_item.added
+	// and output_item.done are sure to carry, so calls are keyed by item ID.
 	type funcCallBuilder struct {
 		CallID     string
 		Name       string
 		ArgsBuffer string
 	}
 	funcCallBuilders := make(map[string]*funcCallBuilder)
+	var funcCallOrder []string
+	builderFor := func(itemID, callID string) *funcCallBuilder {
+		key := itemID
+		if key == "" {
+			key = callID
+		}
+		b, ok := funcCallBuilders[key]
+		if !ok {
+			b = &funcCallBuilder{}
+			funcCallBuilders[key] = b
+			funcCallOrder = append(funcCallOrder, key)
+		}
+		if callID != "" {
+			b.CallID = callID
+		}
+		return b
+	}
 
 	reader := bufio.NewReader(resp.Body)
 	for {
@@ -337,54 +386,66 @@ func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []Message, t
 				}
 			}
 
-		case "response.function_call_arguments.delta":
-			// Function call arguments streaming
-			if event.CallID != "" {
-				if _, exists := funcCallBuilders[event.CallID]; !exists {
-					funcCallBuilders[event.CallID] = &funcCallBuilder{
-						CallID: event.CallID,
+		case "response.output_item.added", "response.output_item.done":
+			// Function call items carry the name and call ID; .done also has
+			// the complete arguments
+			if item := event.Item; item != nil && item.Type == "image_generation_call" && event.Type == "response.output_item.done" {
+				if data, err := base64.StdEncoding.DecodeString(item.Result); err == nil && len(data) > 0 {
+					format := item.OutputFormat
+					if format == "" {
+						format = "png"
 					}
+					finalMsg.Artifacts = append(finalMsg.Artifacts, Artifact{MediaType: "image/" + format, Data: data})
+				}
+			}
+			if item := event.Item; item != nil && item.Type == "function_call" {
+				builder := builderFor(item.ID, item.CallID)
+				if item.Name != "" {
+					builder.Name = item.Name
+				}
+				if item.Arguments != "" {
+					builder.ArgsBuffer = item.Arguments
 				}
-				funcCallBuilders[event.CallID].ArgsBuffer += event.Delta
+			}
+
+		case "response.function_call_arguments.delta":
+			if event.ItemID != "" || event.CallID != "" {
+				builder := builderFor(event.ItemID, event.CallID)
+				builder.ArgsBuffer += event.Delta
 			}
 
 		case "response.function_call_arguments.done":
-			// Function call complete
-			if event.CallID != "" {
-				if builder, exists := funcCallBuilders[event.CallID]; exists {
+			if event.ItemID != "" || event.CallID != "" {
+				builder := builderFor(event.ItemID, event.CallID)
+				if event.Name != "" {
 					builder.Name = event.Name
-					if event.Arguments != "" {
-						builder.ArgsBuffer = event.Arguments
-					}
-				} else {
-					funcCallBuilders[event.CallID] = &funcCallBuilder{
-						CallID:     event.CallID,
-						Name:       event.Name,
-						ArgsBuffer: event.Arguments,
-					}
+				}
+				if event.Arguments != "" {
+					builder.ArgsBuffer = event.Arguments
 				}
 			}
 
-		case "response.output_item.added":
-			// New output item - might be a function call
-			// The name comes in this event for function calls
-
 		case "response.completed", "response.done":
 			// Response complete - finalize
+			if event.Response != nil && event.Response.Usage != nil {
+				finalMsg.Usage = &Usage{
+					InputTokens:  event.Response.Usage.InputTokens,
+					OutputTokens: event.Response.Usage.OutputTokens,
+				}
+			}
 		}
 	}
 
 	// Finalize function calls
-	for _, builder := range funcCallBuilders {
-		var args map[string]interface{}
-		if err := json.Unmarshal([]byte(builder.ArgsBuffer), &args); err != nil {
-			args = make(map[string]interface{})
+	for _, key := range funcCallOrder {
+		builder := funcCallBuilders[key]
+		if builder.CallID == "" {
+			builder.CallID = key
+		}
+		if builder.Name == "" {
+			return nil, fmt.Errorf("stream ended without a name for function call %s", builder.CallID)
 		}
-		finalMsg.ToolCalls = append(finalMsg.ToolCalls, ToolCall{
-			ID:   builder.CallID,
-			Name: builder.Name,
-			Args: args,
-		})
+		finalMsg.ToolCalls = append(finalMsg.ToolCalls, newToolCall(builder.CallID, builder.Name, builder.ArgsBuffer))
 	}
 
 	return finalMsg, nil
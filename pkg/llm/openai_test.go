@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func streamOpenAIEvents(t *testing.T, events []string) *Message {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("dummy", "gpt-4o")
+	client.endpoint = server.URL
+	msg, err := client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "go"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	return msg
+}
+
+func TestOpenAIToolCallNamesFromItemAdded(t *testing.T) {
+	// Captured stream: argument events carry only the item ID, and
+	// arguments.done has no name
+	msg := streamOpenAIEvents(t, []string{
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"function_call","id":"fc_1","call_id":"call_a","name":"Bash","arguments":""}}`,
+		`{"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","id":"fc_2","call_id":"call_b","name":"Read","arguments":""}}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","output_index":0,"delta":"{\"command\":"}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_2","output_index":1,"delta":"{\"file_path\":\"a.go\"}"}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","output_index":0,"delta":"\"ls\"}"}`,
+		`{"type":"response.function_call_arguments.done","item_id":"fc_1","output_index":0,"arguments":"{\"command\":\"ls\"}"}`,
+		`{"type":"response.function_call_arguments.done","item_id":"fc_2","output_index":1,"arguments":"{\"file_path\":\"a.go\"}"}`,
+		`{"type":"response.completed","response":{"usage":{"input_tokens":10,"output_tokens":5}}}`,
+	})
+
+	if len(msg.ToolCalls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %+v", msg.ToolCalls)
+	}
+	if tc := msg.ToolCalls[0]; tc.ID != "call_a" || tc.Name != "Bash" || tc.Args["command"] != "ls" {
+		t.Errorf("Unexpected first call: %+v", tc)
+	}
+	if tc := msg.ToolCalls[1]; tc.ID != "call_b" || tc.Name != "Read" || tc.Args["file_path"] != "a.go" {
+		t.Errorf("Unexpected second call: %+v", tc)
+	}
+	if msg.Usage == nil || msg.Usage.InputTokens != 10 || msg.Usage.OutputTokens != 5 {
+		t.Errorf("Unexpected usage: %+v", msg.Usage)
+	}
+}
+
+func TestOpenAIToolCallNameFromItemDone(t *testing.T) {
+	// No item_added; the name only arrives with output_item.done
+	msg := streamOpenAIEvents(t, []string{
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"{\"pattern\":\"*.go\"}"}`,
+		`{"type":"response.function_call_arguments.done","item_id":"fc_1","arguments":"{\"pattern\":\"*.go\"}"}`,
+		`{"type":"response.output_item.done","item":{"type":"function_call","id":"fc_1","call_id":"call_a","name":"Glob","arguments":"{\"pattern\":\"*.go\"}"}}`,
+	})
+
+	if len(msg.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %+v", msg.ToolCalls)
+	}
+	if tc := msg.ToolCalls[0]; tc.ID != "call_a" || tc.Name != "Glob" || tc.Args["pattern"] != "*.go" {
+		t.Errorf("Unexpected call: %+v", tc)
+	}
+}
+
+func TestOpenAINamelessToolCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"type\":\"response.function_call_arguments.done\",\"item_id\":\"fc_1\",\"arguments\":\"{}\"}\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient("dummy", "gpt-4o")
+	client.endpoint = server.URL
+	if _, err := client.GenerateStream(context.Background(), []Message{{Role: RoleUser, Content: "go"}}, nil, nil); err == nil {
+		t.Error("Expected an error for a function call without a name")
+	}
+}
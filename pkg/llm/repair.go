@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// InterruptedToolResult stands in for the result of a tool call that never
+// returned, such as one running when a session was killed
+const InterruptedToolResult = "Error: this tool call was interrupted before it returned a result. " +
+	"It may or may not have taken effect; check before running it again."
+
+// InterruptedNote ends the text of an interrupted assistant message when it
+// is sent back, so the model does not take it for a finished answer
+const InterruptedNote = "\n\n[This response was interrupted before it finished.]"
+
+// SentText returns the text of an assistant message as providers receive it
+func (m Message) SentText() string {
+	if m.Interrupted && m.Content != "" {
+		return strings.TrimRight(m.Content, " \t\r\n") + InterruptedNote
+	}
+	return m.Content
+}
+
+// ServerBlocksHaveText reports whether ServerBlocks holds the text blocks of
+// the message too. Messages saved before the text was kept with them have
+// only the server tool blocks, which went ahead of Content.
+func (m Message) ServerBlocksHaveText() bool {
+	for _, raw := range m.ServerBlocks {
+		var block struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(raw, &block) == nil && block.Type == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+// RepairToolPairs makes a conversation valid for providers, which reject a
+// tool call without a result right after it, and a result without its call.
+// Calls missing a result get InterruptedToolResult, results that answer no
+// call in the assistant message before them are dropped, and calls without
+// an ID, which nothing can answer, are removed. It returns the repaired
+// conversation and the number of changes made.
+func RepairToolPairs(messages []Message) ([]Message, int) {
+	repaired := make([]Message, 0, len(messages))
+	fixes := 0
+
+	var pending []ToolCall        // Calls of the latest assistant message, in order
+	answered := map[string]bool{} // Their IDs that have a result
+	flush := func() {
+		for _, tc := range pending {
+			if !answered[tc.ID] {
+				repaired = append(repaired, Message{
+					Role:       RoleTool,
+					ToolResult: &ToolResult{ToolCallID: tc.ID, ToolName: tc.Name, Content: InterruptedToolResult, IsError: true},
+				})
+				fixes++
+			}
+		}
+		pending = nil
+		answered = map[string]bool{}
+	}
+
+	for _, msg := range messages {
+		if msg.Role == RoleTool {
+			if msg.ToolResult == nil || answered[msg.ToolResult.ToolCallID] || !hasCall(pending, msg.ToolResult.ToolCallID) {
+				fixes++
+				continue
+			}
+			answered[msg.ToolResult.ToolCallID] = true
+			repaired = append(repaired, msg)
+			continue
+		}
+
+		flush()
+		if msg.Role == RoleAssistant && len(msg.ToolCalls) > 0 {
+			var calls []ToolCall
+			for _, tc := range msg.ToolCalls {
+				if tc.ID == "" {
+					fixes++
+					continue
+				}
+				calls = append(calls, tc)
+			}
+			msg.ToolCalls = calls
+			if len(calls) == 0 && msg.Content == "" && len(msg.ServerBlocks) == 0 {
+				continue
+			}
+			pending = calls
+		}
+		repaired = append(repaired, msg)
+	}
+	flush()
+	return repaired, fixes
+}
+
+func hasCall(calls []ToolCall, id string) bool {
+	for _, tc := range calls {
+		if tc.ID == id {
+			return true
+		}
+	}
+	return false
+}
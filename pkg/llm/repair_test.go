@@ -0,0 +1,70 @@
+package llm
+
+import "testing"
+
+func TestRepairToolPairs(t *testing.T) {
+	result := func(id, content string) Message {
+		return Message{Role: RoleTool, ToolResult: &ToolResult{ToolCallID: id, ToolName: "Bash", Content: content}}
+	}
+	messages := []Message{
+		{Role: RoleSystem, Content: "system"},
+		{Role: RoleUser, Content: "build it"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "a", Name: "Bash"}, {ID: "b", Name: "Read"}}},
+		result("a", "ok"),
+		// The session was killed while b ran; the user then typed again
+		{Role: RoleUser, Content: "are you there?"},
+		result("b", "late"),
+		{Role: RoleAssistant, Content: "Yes.", ToolCalls: []ToolCall{{Name: "Bash"}}},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "Bash"}}},
+		{Role: RoleUser, Content: "again"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "c", Name: "Bash"}}},
+		result("c", "done"),
+		result("c", "duplicate"),
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "d", Name: "Grep"}}},
+	}
+
+	repaired, fixes := RepairToolPairs(messages)
+	if fixes != 6 {
+		t.Errorf("fixes = %d, want 6", fixes)
+	}
+	want := []struct {
+		role    Role
+		content string // Text, or the result content for tool messages
+		calls   int
+	}{
+		{RoleSystem, "system", 0},
+		{RoleUser, "build it", 0},
+		{RoleAssistant, "", 2},
+		{RoleTool, "ok", 0},
+		{RoleTool, InterruptedToolResult, 0},
+		{RoleUser, "are you there?", 0},
+		{RoleAssistant, "Yes.", 0},
+		{RoleUser, "again", 0},
+		{RoleAssistant, "", 1},
+		{RoleTool, "done", 0},
+		{RoleAssistant, "", 1},
+		{RoleTool, InterruptedToolResult, 0},
+	}
+	if len(repaired) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(repaired), len(want), repaired)
+	}
+	for i, w := range want {
+		m := repaired[i]
+		content := m.Content
+		if m.ToolResult != nil {
+			content = m.ToolResult.Content
+		}
+		if m.Role != w.role || content != w.content || len(m.ToolCalls) != w.calls {
+			t.Errorf("message %d = %+v, want %+v", i, m, w)
+		}
+	}
+	if r := repaired[4].ToolResult; r.ToolCallID != "b" || r.ToolName != "Read" {
+		t.Errorf("synthesized result = %+v", r)
+	}
+
+	// A valid conversation is left alone
+	again, fixes := RepairToolPairs(repaired)
+	if fixes != 0 || len(again) != len(repaired) {
+		t.Errorf("repairing twice changed %d things", fixes)
+	}
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Sampling overrides a provider's default sampling parameters. Nil fields are
+// left to the provider.
+type Sampling struct {
+	Temperature *float64
+	TopP        *float64
+	Seed        *int64
+	// HashRequests sets Message.RequestHash on every response so runs can be
+	// compared request by request
+	HashRequests bool
+}
+
+// DeterministicSeed is the seed sent to providers that accept one
+const DeterministicSeed int64 = 42
+
+// DeterministicSampling pins temperature 0, top_p 1 and a fixed seed, and
+// hashes every request. Each client sends only what its provider accepts.
+func DeterministicSampling() Sampling {
+	temperature, topP, seed := 0.0, 1.0, DeterministicSeed
+	return Sampling{Temperature: &temperature, TopP: &topP, Seed: &seed, HashRequests: true}
+}
+
+// SamplingClient is implemented by clients whose sampling can be pinned
+type SamplingClient interface {
+	SetSampling(s Sampling)
+}
+
+// requestHash returns the SHA-256 of a request body when hashing is enabled
+func (s Sampling) requestHash(body []byte) string {
+	if !s.HashRequests {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// isReasoningModel reports whether an OpenAI model rejects temperature and
+// top_p, as the GPT-5 and o-series reasoning models do
+func isReasoningModel(model string) bool {
+	return strings.HasPrefix(model, "gpt-5") || (len(model) > 1 && model[0] == 'o' && model[1] >= '0' && model[1] <= '9')
+}
@@ -0,0 +1,79 @@
+package llm
+
+import "context"
+
+// ToolChoice constrains the tool calls of one request. The zero value leaves
+// the choice to the model.
+type ToolChoice struct {
+	Mode ToolChoiceMode
+	Name string // The tool to call, for ToolChoiceTool
+}
+
+// ToolChoiceMode is how a request constrains tool calls
+type ToolChoiceMode string
+
+const (
+	ToolChoiceAuto ToolChoiceMode = ""     // The model decides
+	ToolChoiceNone ToolChoiceMode = "none" // No tool calls, text only
+	ToolChoiceAny  ToolChoiceMode = "any"  // At least one tool call, of any tool
+	ToolChoiceTool ToolChoiceMode = "tool" // A call of the named tool
+)
+
+// ForceTool requires the model to call the named tool
+func ForceTool(name string) ToolChoice {
+	return ToolChoice{Mode: ToolChoiceTool, Name: name}
+}
+
+type toolChoiceKey struct{}
+
+// WithToolChoice returns a context whose requests use choice. Clients read
+// it when building a request and translate it for their provider, so it
+// works the same whatever model the agent is using.
+func WithToolChoice(ctx context.Context, choice ToolChoice) context.Context {
+	return context.WithValue(ctx, toolChoiceKey{}, choice)
+}
+
+// ToolChoiceFrom returns the tool choice set on ctx, or the zero value
+func ToolChoiceFrom(ctx context.Context) ToolChoice {
+	choice, _ := ctx.Value(toolChoiceKey{}).(ToolChoice)
+	return choice
+}
+
+// anthropic returns Anthropic's tool_choice, or nil for the default
+func (c ToolChoice) anthropic() interface{} {
+	switch c.Mode {
+	case ToolChoiceNone:
+		return map[string]string{"type": "none"}
+	case ToolChoiceAny:
+		return map[string]string{"type": "any"}
+	case ToolChoiceTool:
+		return map[string]string{"type": "tool", "name": c.Name}
+	}
+	return nil
+}
+
+// openAI returns the Responses API's tool_choice, or nil for the default
+func (c ToolChoice) openAI() interface{} {
+	switch c.Mode {
+	case ToolChoiceNone:
+		return "none"
+	case ToolChoiceAny:
+		return "required"
+	case ToolChoiceTool:
+		return map[string]string{"type": "function", "name": c.Name}
+	}
+	return nil
+}
+
+// gemini returns Gemini's function calling config
+func (c ToolChoice) gemini() *geminiFunctionCallingConfig {
+	switch c.Mode {
+	case ToolChoiceNone:
+		return &geminiFunctionCallingConfig{Mode: "NONE"}
+	case ToolChoiceAny:
+		return &geminiFunctionCallingConfig{Mode: "ANY"}
+	case ToolChoiceTool:
+		return &geminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{c.Name}}
+	}
+	return &geminiFunctionCallingConfig{Mode: "AUTO"}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestToolChoiceInRequests(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, "data: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	anthropic := NewAnthropicClient("dummy", server.URL+"/v1/messages", "")
+	openai := NewOpenAIClient("dummy", "gpt-4o")
+	openai.endpoint = server.URL
+	tools := []interface{}{map[string]interface{}{"name": "Read", "description": "d", "input_schema": map[string]interface{}{"type": "object"}}}
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+
+	cases := []struct {
+		choice          ToolChoice
+		anthropic, open interface{}
+	}{
+		{ToolChoice{}, nil, nil},
+		{ToolChoice{Mode: ToolChoiceNone}, map[string]interface{}{"type": "none"}, "none"},
+		{ToolChoice{Mode: ToolChoiceAny}, map[string]interface{}{"type": "any"}, "required"},
+		{ForceTool("Read"), map[string]interface{}{"type": "tool", "name": "Read"}, map[string]interface{}{"type": "function", "name": "Read"}},
+	}
+	for _, c := range cases {
+		ctx := WithToolChoice(context.Background(), c.choice)
+		anthropic.GenerateStream(ctx, messages, tools, nil)
+		if got := body["tool_choice"]; !reflect.DeepEqual(got, c.anthropic) {
+			t.Errorf("Anthropic tool_choice for %+v = %v, want %v", c.choice, got, c.anthropic)
+		}
+		openai.GenerateStream(ctx, messages, tools, nil)
+		if got := body["tool_choice"]; !reflect.DeepEqual(got, c.open) {
+			t.Errorf("OpenAI tool_choice for %+v = %v, want %v", c.choice, got, c.open)
+		}
+	}
+
+	// Without tools there is nothing to choose, and providers reject a choice
+	anthropic.GenerateStream(WithToolChoice(context.Background(), ToolChoice{Mode: ToolChoiceAny}), messages, nil, nil)
+	if _, ok := body["tool_choice"]; ok {
+		t.Errorf("tool_choice sent without tools: %v", body)
+	}
+
+	if got := ForceTool("Read").gemini(); got.Mode != "ANY" || !reflect.DeepEqual(got.AllowedFunctionNames, []string{"Read"}) {
+		t.Errorf("Gemini config for a forced tool = %+v", got)
+	}
+	if got := (ToolChoice{}).gemini(); got.Mode != "AUTO" {
+		t.Errorf("Gemini default mode = %q", got.Mode)
+	}
+}
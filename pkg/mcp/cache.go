@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/history"
+)
+
+// toolCache is the tool list a server reported the last time it connected,
+// used to offer a lazy server's tools without starting it
+type toolCache struct {
+	Fingerprint string `json:"fingerprint"`
+	Tools       []Tool `json:"tools"`
+}
+
+// toolCachePath returns ~/.johncode/mcp-tools/<server>.json, under
+// $JOHN_DATA_DIR instead when it is set
+func toolCachePath(name string) (string, error) {
+	root, err := history.DefaultRoot()
+	if err != nil {
+		return "", err
+	}
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	return filepath.Join(root, "mcp-tools", name+".json"), nil
+}
+
+// fingerprint identifies the command a server runs, so a cache written for
+// a different command is ignored
+func fingerprint(cfg ServerConfig) string {
+	data, _ := json.Marshal(struct {
+		Command string
+		Args    []string
+		Env     map[string]string
+	}{cfg.Command, cfg.Args, cfg.Env})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func loadToolCache(name string, cfg ServerConfig) ([]Tool, bool) {
+	path, err := toolCachePath(name)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var cache toolCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Fingerprint != fingerprint(cfg) {
+		return nil, false
+	}
+	return cache.Tools, true
+}
+
+// saveToolCache records a server's tools. Failures only cost a startup
+// connection next time, so they are ignored.
+func saveToolCache(name string, cfg ServerConfig, tools []Tool) {
+	path, err := toolCachePath(name)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(toolCache{Fingerprint: fingerprint(cfg), Tools: tools})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	_ = config.WriteFileAtomic(path, data, 0600)
+}
@@ -10,6 +10,8 @@ import (
 	"os/exec"
 	"sync"
 	"sync/atomic"
+
+	"github.com/jbdamask/john-code/pkg/telemetry"
 )
 
 // JSON-RPC message types
@@ -112,6 +114,12 @@ type Client struct {
 
 // NewClient creates a new MCP client for a server
 func NewClient(name string, config ServerConfig) (*Client, error) {
+	return newClient(name, config, nil)
+}
+
+// newClient creates a client whose server also gets extraEnv, NAME=value
+// pairs taken as is. Variables from the server's config take precedence.
+func newClient(name string, config ServerConfig, extraEnv []string) (*Client, error) {
 	// Expand environment variables in command and args
 	command := os.ExpandEnv(config.Command)
 	args := make([]string, len(config.Args))
@@ -122,7 +130,7 @@ func NewClient(name string, config ServerConfig) (*Client, error) {
 	cmd := exec.Command(command, args...)
 
 	// Set environment variables
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), extraEnv...)
 	for k, v := range config.Env {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, os.ExpandEnv(v)))
 	}
@@ -279,7 +287,21 @@ func (c *Client) Close() error {
 	return c.cmd.Wait()
 }
 
+// sendRequest sends a request and waits for its response, traced as a span
 func (c *Client) sendRequest(ctx context.Context, method string, params interface{}) (*JSONRPCResponse, error) {
+	name := "mcp " + method
+	attrs := []telemetry.Attr{telemetry.String("mcp.method.name", method), telemetry.String("mcp.server.name", c.name)}
+	if call, ok := params.(CallToolParams); ok {
+		name += " " + call.Name
+		attrs = append(attrs, telemetry.String("gen_ai.tool.name", call.Name))
+	}
+	ctx, span := telemetry.Start(ctx, name, telemetry.KindClient, attrs...)
+	resp, err := c.send(ctx, method, params)
+	span.End(err)
+	return resp, err
+}
+
+func (c *Client) send(ctx context.Context, method string, params interface{}) (*JSONRPCResponse, error) {
 	id := atomic.AddInt64(&c.requestID, 1)
 
 	req := JSONRPCRequest{
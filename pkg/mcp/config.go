@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/jbdamask/john-code/pkg/config"
 )
 
 // ServerConfig represents the configuration for a single MCP server
@@ -12,6 +14,14 @@ type ServerConfig struct {
 	Command string            `json:"command"`
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
+
+	// Lazy defers starting the server until one of its tools is first
+	// called. Its tools are offered from the list cached the last time it
+	// connected.
+	Lazy bool `json:"lazy,omitempty"`
+	// ConnectTimeout bounds starting and initializing the server
+	// (default 30s)
+	ConnectTimeout config.Duration `json:"connectTimeout,omitempty"`
 }
 
 // MCPConfig represents the full MCP configuration file
@@ -71,19 +81,19 @@ func LoadConfig(path string) (*MCPConfig, error) {
 }
 
 // SaveConfig saves MCP configuration to a file
-func SaveConfig(path string, config *MCPConfig) error {
+func SaveConfig(path string, cfg *MCPConfig) error {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := config.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -91,12 +101,18 @@ func SaveConfig(path string, config *MCPConfig) error {
 }
 
 // LoadAllConfigs loads and merges MCP configs from all scopes
-// Precedence: local > project > user
+// Precedence: local > project > user. Project servers are only started once
+// the workspace is trusted.
 func LoadAllConfigs() (*MCPConfig, error) {
 	merged := &MCPConfig{MCPServers: make(map[string]ServerConfig)}
 
+	scopes := []Scope{ScopeUser}
+	if config.ProjectTrusted() {
+		scopes = append(scopes, ScopeProject)
+	}
+
 	// Load in order of lowest to highest precedence
-	for _, scope := range []Scope{ScopeUser, ScopeProject} {
+	for _, scope := range scopes {
 		path, err := GetConfigPath(scope)
 		if err != nil {
 			continue
@@ -123,13 +139,16 @@ func AddServer(name string, server ServerConfig, scope Scope) error {
 		return err
 	}
 
-	config, err := LoadConfig(path)
-	if err != nil {
-		return err
-	}
+	// Locked so concurrent instances don't drop each other's changes
+	return config.WithFileLock(path, func() error {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
 
-	config.MCPServers[name] = server
-	return SaveConfig(path, config)
+		cfg.MCPServers[name] = server
+		return SaveConfig(path, cfg)
+	})
 }
 
 // RemoveServer removes a server from the config at the specified scope
@@ -139,15 +158,17 @@ func RemoveServer(name string, scope Scope) error {
 		return err
 	}
 
-	config, err := LoadConfig(path)
-	if err != nil {
-		return err
-	}
+	return config.WithFileLock(path, func() error {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
 
-	if _, exists := config.MCPServers[name]; !exists {
-		return fmt.Errorf("server %q not found in %s config", name, scope)
-	}
+		if _, exists := cfg.MCPServers[name]; !exists {
+			return fmt.Errorf("server %q not found in %s config", name, scope)
+		}
 
-	delete(config.MCPServers, name)
-	return SaveConfig(path, config)
+		delete(cfg.MCPServers, name)
+		return SaveConfig(path, cfg)
+	})
 }
@@ -4,62 +4,243 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 )
 
+// DefaultConnectTimeout bounds connecting to a server whose config does not
+// set connectTimeout
+const DefaultConnectTimeout = 30 * time.Second
+
+// StartupBudget is how long LoadAndConnect waits for servers before
+// returning. Slower servers keep connecting in the background.
+var StartupBudget = 5 * time.Second
+
 // Manager handles multiple MCP server connections
 type Manager struct {
-	clients map[string]*Client
-	mu      sync.RWMutex
+	clients    map[string]*Client
+	configs    map[string]ServerConfig  // Servers from the last LoadAndConnect
+	connecting map[string]chan struct{} // Closed when the attempt finishes
+	failed     map[string]error
+	cached     map[string][]Tool // Tool lists of lazy servers not yet started
+	closed     bool
+	stop       context.CancelFunc // Cancels connections still in progress
+	stopped    context.Context
+	mu         sync.RWMutex
+
+	// OnConnect, if set, is called from the connecting goroutine each time a
+	// server comes online, so its tools can be registered
+	OnConnect func(name string)
+	// ExtraEnv, if set, returns NAME=value pairs added to the environment of
+	// every server started from then on
+	ExtraEnv func() []string
 }
 
 // NewManager creates a new MCP manager
 func NewManager() *Manager {
+	stopped, stop := context.WithCancel(context.Background())
 	return &Manager{
-		clients: make(map[string]*Client),
+		stop:       stop,
+		stopped:    stopped,
+		clients:    make(map[string]*Client),
+		configs:    make(map[string]ServerConfig),
+		connecting: make(map[string]chan struct{}),
+		failed:     make(map[string]error),
+		cached:     make(map[string][]Tool),
 	}
 }
 
-// LoadAndConnect loads all configured servers and connects to them
+// LoadAndConnect loads all configured servers and connects to them in
+// parallel. It returns once every server is up or has failed, or after
+// StartupBudget, whichever comes first; servers still starting by then
+// finish in the background and are announced through OnConnect. Lazy
+// servers with a cached tool list are not started until first used.
 func (m *Manager) LoadAndConnect(ctx context.Context) error {
 	config, err := LoadAllConfigs()
 	if err != nil {
 		return fmt.Errorf("failed to load MCP configs: %w", err)
 	}
 
+	waits := make(map[string]chan struct{})
 	for name, serverConfig := range config.MCPServers {
-		if err := m.ConnectServer(ctx, name, serverConfig); err != nil {
-			// Log error but continue with other servers
-			fmt.Printf("Warning: failed to connect to MCP server %q: %v\n", name, err)
+		m.mu.Lock()
+		old, known := m.configs[name]
+		m.configs[name] = serverConfig
+		_, running := m.clients[name]
+		_, busy := m.connecting[name]
+		m.mu.Unlock()
+
+		// Servers loaded earlier with the same config are left alone
+		if known && reflect.DeepEqual(old, serverConfig) && (running || busy) {
+			continue
+		}
+
+		if serverConfig.Lazy {
+			if tools, ok := loadToolCache(name, serverConfig); ok {
+				m.mu.Lock()
+				m.cached[name] = tools
+				m.mu.Unlock()
+				continue
+			}
+			// Never connected before: start it once to learn its tools
 		}
+
+		waits[name] = m.startConnect(ctx, name, serverConfig)
 	}
 
+	timer := time.NewTimer(StartupBudget)
+	defer timer.Stop()
+	for _, done := range waits {
+		select {
+		case <-done:
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	// Report failures that happened within the budget
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for name := range waits {
+		if err := m.failed[name]; err != nil {
+			fmt.Printf("Warning: failed to connect to MCP server %q: %v\n", name, err)
+		}
+	}
 	return nil
 }
 
-// ConnectServer connects to a specific MCP server
-func (m *Manager) ConnectServer(ctx context.Context, name string, config ServerConfig) error {
+// startConnect connects to a server in the background. The returned
+// channel is closed when the attempt succeeds or fails.
+func (m *Manager) startConnect(ctx context.Context, name string, config ServerConfig) chan struct{} {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	if done, ok := m.connecting[name]; ok {
+		m.mu.Unlock()
+		return done
+	}
+	done := make(chan struct{})
+	m.connecting[name] = done
+	delete(m.failed, name)
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		// Close abandons the attempt
+		defer context.AfterFunc(m.stopped, cancel)()
+		err := m.ConnectServer(ctx, name, config)
+
+		m.mu.Lock()
+		delete(m.connecting, name)
+		if err != nil {
+			m.failed[name] = err
+		}
+		m.mu.Unlock()
+	}()
+	return done
+}
 
-	// Close existing connection if any
-	if existing, ok := m.clients[name]; ok {
-		existing.Close()
+// Pending returns the servers that are still connecting
+func (m *Manager) Pending() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var names []string
+	for name := range m.connecting {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	client, err := NewClient(name, config)
+// ConnectServer connects to a specific MCP server, giving up after the
+// server's connect timeout
+func (m *Manager) ConnectServer(ctx context.Context, name string, config ServerConfig) error {
+	timeout := time.Duration(config.ConnectTimeout)
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var extraEnv []string
+	if m.ExtraEnv != nil {
+		extraEnv = m.ExtraEnv()
+	}
+	client, err := newClient(name, config, extraEnv)
 	if err != nil {
 		return err
 	}
 
+	// Connect without holding the lock so other servers and tool calls are
+	// not held up by a slow one
 	if err := client.Connect(ctx); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("no response within %s", timeout)
+		}
 		return err
 	}
 
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		client.Close()
+		return fmt.Errorf("MCP manager closed")
+	}
+	// Close existing connection if any
+	if existing, ok := m.clients[name]; ok {
+		existing.Close()
+	}
 	m.clients[name] = client
+	delete(m.cached, name)
+	onConnect := m.OnConnect
+	m.mu.Unlock()
+
+	saveToolCache(name, config, client.Tools())
+	if onConnect != nil {
+		onConnect(name)
+	}
 	return nil
 }
 
+// ensureConnected starts a lazy server, or waits for one that is still
+// connecting
+func (m *Manager) ensureConnected(ctx context.Context, name string) (*Client, error) {
+	m.mu.RLock()
+	client, ok := m.clients[name]
+	done, busy := m.connecting[name]
+	config, known := m.configs[name]
+	m.mu.RUnlock()
+
+	if ok {
+		return client, nil
+	}
+	if !busy {
+		if !known || !config.Lazy {
+			return nil, fmt.Errorf("server %q not connected", name)
+		}
+		done = m.startConnect(context.Background(), name, config)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if client, ok := m.clients[name]; ok {
+		return client, nil
+	}
+	if err := m.failed[name]; err != nil {
+		return nil, fmt.Errorf("server %q failed to connect: %w", name, err)
+	}
+	return nil, fmt.Errorf("server %q not connected", name)
+}
+
 // DisconnectServer disconnects from a specific server
 func (m *Manager) DisconnectServer(name string) error {
 	m.mu.Lock()
@@ -91,29 +272,42 @@ func (m *Manager) ListServers() []ServerStatus {
 	config, _ := LoadAllConfigs()
 
 	statuses := make([]ServerStatus, 0)
-	
+
 	// Add connected servers
 	for name, client := range m.clients {
-		statuses = append(statuses, ServerStatus{
+		status := ServerStatus{
 			Name:      name,
 			Connected: client.Connected(),
 			ToolCount: len(client.Tools()),
-		})
+			State:     "connected",
+		}
+		if !status.Connected {
+			status.State = "disconnected"
+		}
+		statuses = append(statuses, status)
 	}
 
 	// Add configured but not connected servers
 	if config != nil {
 		for name := range config.MCPServers {
-			if _, connected := m.clients[name]; !connected {
-				statuses = append(statuses, ServerStatus{
-					Name:      name,
-					Connected: false,
-					ToolCount: 0,
-				})
+			if _, connected := m.clients[name]; connected {
+				continue
+			}
+			status := ServerStatus{Name: name, State: "disconnected"}
+			if _, ok := m.connecting[name]; ok {
+				status.State = "connecting"
+			} else if tools, ok := m.cached[name]; ok {
+				status.State = "lazy"
+				status.ToolCount = len(tools)
+			} else if err := m.failed[name]; err != nil {
+				status.State = "failed"
+				status.Error = err.Error()
 			}
+			statuses = append(statuses, status)
 		}
 	}
 
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
 	return statuses
 }
 
@@ -122,6 +316,8 @@ type ServerStatus struct {
 	Name      string
 	Connected bool
 	ToolCount int
+	State     string // connected, connecting, lazy, failed or disconnected
+	Error     string `json:",omitempty"`
 }
 
 // GetAllTools returns all tools from all connected servers
@@ -131,20 +327,26 @@ func (m *Manager) GetAllTools() []MCPToolDefinition {
 	defer m.mu.RUnlock()
 
 	var tools []MCPToolDefinition
-	for serverName, client := range m.clients {
-		if !client.Connected() {
-			continue
-		}
-		for _, tool := range client.Tools() {
+	add := func(serverName string, serverTools []Tool) {
+		for _, tool := range serverTools {
 			tools = append(tools, MCPToolDefinition{
-				ServerName:  serverName,
-				Name:        fmt.Sprintf("mcp__%s__%s", serverName, tool.Name),
+				ServerName:   serverName,
+				Name:         fmt.Sprintf("mcp__%s__%s", serverName, tool.Name),
 				OriginalName: tool.Name,
-				Description: tool.Description,
-				InputSchema: tool.InputSchema,
+				Description:  tool.Description,
+				InputSchema:  tool.InputSchema,
 			})
 		}
 	}
+	for serverName, client := range m.clients {
+		if client.Connected() {
+			add(serverName, client.Tools())
+		}
+	}
+	// Lazy servers are started when one of these is called
+	for serverName, cached := range m.cached {
+		add(serverName, cached)
+	}
 	return tools
 }
 
@@ -159,12 +361,9 @@ type MCPToolDefinition struct {
 
 // CallTool calls a tool on the appropriate server
 func (m *Manager) CallTool(ctx context.Context, serverName, toolName string, arguments json.RawMessage) (string, error) {
-	m.mu.RLock()
-	client, ok := m.clients[serverName]
-	m.mu.RUnlock()
-
-	if !ok {
-		return "", fmt.Errorf("server %q not connected", serverName)
+	client, err := m.ensureConnected(ctx, serverName)
+	if err != nil {
+		return "", err
 	}
 
 	result, err := client.CallTool(ctx, toolName, arguments)
@@ -196,4 +395,6 @@ func (m *Manager) Close() {
 		client.Close()
 	}
 	m.clients = make(map[string]*Client)
+	m.closed = true
+	m.stop()
 }
@@ -0,0 +1,224 @@
+// Package project inspects a repository for the package managers it uses,
+// so the model follows the project's conventions and does not, say, run
+// npm install in a pnpm repository, and for its size, so the model searches
+// rather than reads its way around a monorepo.
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Manager is a package manager a project uses
+type Manager struct {
+	Name      string // e.g. "pnpm", "poetry", "go modules"
+	Command   string // Its executable's key in managers, e.g. "go"
+	Ecosystem string // "node", "python", "go", "rust" or "ruby"
+	Evidence  string // The file it was detected from
+	Install   string // Installs the locked dependencies
+	Add       string // Adds a dependency
+}
+
+// Tooling is what Detect found, at most one manager per ecosystem
+type Tooling struct {
+	Managers []Manager
+}
+
+// managers describes each package manager. Ecosystems with one manager are
+// only reported; in node and python, using another ecosystem manager than
+// the project's is flagged by Check.
+var managers = map[string]Manager{
+	"npm":     {Name: "npm", Ecosystem: "node", Install: "npm ci", Add: "npm install <pkg>"},
+	"yarn":    {Name: "yarn", Ecosystem: "node", Install: "yarn install", Add: "yarn add <pkg>"},
+	"pnpm":    {Name: "pnpm", Ecosystem: "node", Install: "pnpm install", Add: "pnpm add <pkg>"},
+	"bun":     {Name: "bun", Ecosystem: "node", Install: "bun install", Add: "bun add <pkg>"},
+	"pip":     {Name: "pip", Ecosystem: "python", Install: "pip install -r requirements.txt", Add: "add it to requirements.txt and pip install -r requirements.txt"},
+	"poetry":  {Name: "poetry", Ecosystem: "python", Install: "poetry install", Add: "poetry add <pkg>"},
+	"uv":      {Name: "uv", Ecosystem: "python", Install: "uv sync", Add: "uv add <pkg>"},
+	"pipenv":  {Name: "pipenv", Ecosystem: "python", Install: "pipenv install", Add: "pipenv install <pkg>"},
+	"pdm":     {Name: "pdm", Ecosystem: "python", Install: "pdm install", Add: "pdm add <pkg>"},
+	"go":      {Name: "go modules", Ecosystem: "go", Install: "go mod download", Add: "go get <module>"},
+	"cargo":   {Name: "cargo", Ecosystem: "rust", Install: "cargo fetch", Add: "cargo add <crate>"},
+	"bundler": {Name: "bundler", Ecosystem: "ruby", Install: "bundle install", Add: "bundle add <gem>"},
+}
+
+// mutating lists, per command, the subcommands that change dependencies or
+// lock files. "" stands for the bare command (yarn installs).
+var mutating = map[string][]string{
+	"npm":    {"install", "i", "ci", "add", "uninstall", "remove", "rm", "un", "update", "up", "upgrade"},
+	"yarn":   {"", "install", "add", "remove", "upgrade", "up"},
+	"pnpm":   {"install", "i", "add", "remove", "rm", "un", "uninstall", "update", "up"},
+	"bun":    {"install", "i", "add", "a", "remove", "rm", "update"},
+	"pip":    {"install", "uninstall"},
+	"poetry": {"add", "install", "remove", "lock", "update"},
+	"uv":     {"add", "remove", "sync", "lock"},
+	"pipenv": {"install", "uninstall", "lock", "sync"},
+	"pdm":    {"add", "install", "remove", "sync", "lock", "update"},
+}
+
+// Detect looks at the files in dir for the package managers in use. Lock
+// files decide; package.json's packageManager field wins over them.
+func Detect(dir string) Tooling {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	var t Tooling
+	add := func(name, evidence string) {
+		m := managers[name]
+		m.Command = name
+		m.Evidence = evidence
+		t.Managers = append(t.Managers, m)
+	}
+
+	if exists("package.json") {
+		if name := packageManagerField(filepath.Join(dir, "package.json")); name != "" {
+			add(name, "package.json packageManager")
+		} else {
+			found := false
+			for _, lock := range []struct{ file, name string }{
+				{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lockb", "bun"}, {"bun.lock", "bun"}, {"package-lock.json", "npm"},
+			} {
+				if exists(lock.file) {
+					add(lock.name, lock.file)
+					found = true
+					break
+				}
+			}
+			if !found {
+				add("npm", "package.json")
+			}
+		}
+	}
+
+	pyproject, _ := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	switch {
+	case exists("poetry.lock"):
+		add("poetry", "poetry.lock")
+	case exists("uv.lock"):
+		add("uv", "uv.lock")
+	case exists("pdm.lock"):
+		add("pdm", "pdm.lock")
+	case exists("Pipfile"):
+		add("pipenv", "Pipfile")
+	case strings.Contains(string(pyproject), "[tool.poetry]"):
+		add("poetry", "pyproject.toml")
+	case strings.Contains(string(pyproject), "[tool.uv]"):
+		add("uv", "pyproject.toml")
+	case exists("requirements.txt"):
+		add("pip", "requirements.txt")
+	}
+
+	if exists("go.mod") {
+		add("go", "go.mod")
+	}
+	if exists("Cargo.toml") {
+		add("cargo", "Cargo.toml")
+	}
+	if exists("Gemfile") {
+		add("bundler", "Gemfile")
+	}
+	return t
+}
+
+// packageManagerField reads the corepack packageManager field, e.g.
+// "pnpm@9.1.0", from package.json
+func packageManagerField(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return ""
+	}
+	name, _, _ := strings.Cut(pkg.PackageManager, "@")
+	if m, ok := managers[name]; ok && m.Ecosystem == "node" {
+		return name
+	}
+	return ""
+}
+
+// Summary describes the tooling for the system prompt, or "" if nothing was
+// found
+func (t Tooling) Summary() string {
+	var lines []string
+	for _, m := range t.Managers {
+		lines = append(lines, fmt.Sprintf("%s (from %s): install with `%s`, add dependencies with `%s`", m.Name, m.Evidence, m.Install, m.Add))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commandSeparators split a shell command line into simple commands
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|\n]`)
+
+// Check returns a warning when a shell command would use a package manager
+// other than the project's to change dependencies, or "" when it is fine
+func (t Tooling) Check(command string) string {
+	for _, part := range commandSeparators.Split(command, -1) {
+		name, sub := packageCommand(part)
+		if name == "" || !isMutating(name, sub) {
+			continue
+		}
+		used := managers[name]
+		for _, m := range t.Managers {
+			if m.Ecosystem != used.Ecosystem || m.Command == name {
+				continue
+			}
+			return fmt.Sprintf("This project uses %s (%s), but `%s` runs %s, which would install different versions and create or change the wrong lock file. Use `%s` to install dependencies or `%s` to add one.",
+				m.Name, m.Evidence, strings.TrimSpace(part), name, m.Install, m.Add)
+		}
+	}
+	return ""
+}
+
+// packageCommand finds the package manager a simple command runs, and its
+// subcommand. Environment assignments, sudo, and python -m pip are looked
+// through.
+func packageCommand(part string) (name, sub string) {
+	fields := strings.Fields(part)
+	for len(fields) > 0 && (strings.Contains(fields[0], "=") || fields[0] == "sudo" || fields[0] == "command" || fields[0] == "exec") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return "", ""
+	}
+	name = filepath.Base(fields[0])
+	args := fields[1:]
+	switch {
+	case strings.HasPrefix(name, "python") && len(args) >= 2 && args[0] == "-m" && strings.HasPrefix(args[1], "pip"):
+		name, args = "pip", args[2:]
+	case name == "pip3":
+		name = "pip"
+	}
+	if _, ok := mutating[name]; !ok {
+		return "", ""
+	}
+	// The first argument that is not a flag is the subcommand. Global
+	// installs don't touch the project.
+	sub = ""
+	for _, arg := range args {
+		if arg == "-g" || arg == "--global" {
+			return "", ""
+		}
+		if sub == "" && !strings.HasPrefix(arg, "-") {
+			sub = arg
+		}
+	}
+	return name, sub
+}
+
+func isMutating(name, sub string) bool {
+	for _, s := range mutating[name] {
+		if s == sub {
+			return true
+		}
+	}
+	return false
+}
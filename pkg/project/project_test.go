@@ -0,0 +1,82 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func names(t Tooling) string {
+	var n []string
+	for _, m := range t.Managers {
+		n = append(n, m.Command+"@"+m.Evidence)
+	}
+	return strings.Join(n, ",")
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		files map[string]string
+		want  string
+	}{
+		{map[string]string{"package.json": "{}", "pnpm-lock.yaml": ""}, "pnpm@pnpm-lock.yaml"},
+		{map[string]string{"package.json": "{}", "yarn.lock": "", "package-lock.json": ""}, "yarn@yarn.lock"},
+		{map[string]string{"package.json": `{"packageManager": "pnpm@9.1.0"}`, "package-lock.json": ""}, "pnpm@package.json packageManager"},
+		{map[string]string{"package.json": "{}"}, "npm@package.json"},
+		{map[string]string{"pyproject.toml": "[tool.poetry]\nname = \"x\"", "requirements.txt": ""}, "poetry@pyproject.toml"},
+		{map[string]string{"uv.lock": "", "pyproject.toml": ""}, "uv@uv.lock"},
+		{map[string]string{"requirements.txt": ""}, "pip@requirements.txt"},
+		{map[string]string{"go.mod": "module x", "package.json": "{}", "bun.lockb": ""}, "bun@bun.lockb,go@go.mod"},
+		{map[string]string{"README.md": ""}, ""},
+	} {
+		if got := names(Detect(writeFiles(t, tc.files))); got != tc.want {
+			t.Errorf("Detect(%v) = %q, want %q", tc.files, got, tc.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	pnpmPoetry := Detect(writeFiles(t, map[string]string{
+		"package.json": "{}", "pnpm-lock.yaml": "", "poetry.lock": "",
+	}))
+	for command, wantWarning := range map[string]bool{
+		"npm install":                         true,
+		"cd web && npm i lodash":              true,
+		"CI=1 yarn":                           true,
+		"sudo pip install requests":           true,
+		"python3 -m pip install -r reqs.txt":  true,
+		"pnpm install && pnpm test":           false,
+		"npm run build":                       false,
+		"npm test":                            false,
+		"npm install -g typescript":           false,
+		"npx prettier --write .":              false,
+		"poetry add httpx":                    false,
+		"pip list":                            false,
+		"echo npm install":                    false,
+		"go get example.com/x && cargo build": false,
+	} {
+		warning := pnpmPoetry.Check(command)
+		if (warning != "") != wantWarning {
+			t.Errorf("Check(%q) = %q, want warning %v", command, warning, wantWarning)
+		}
+	}
+
+	warning := pnpmPoetry.Check("npm install")
+	if !strings.Contains(warning, "pnpm install") || !strings.Contains(warning, "pnpm-lock.yaml") {
+		t.Errorf("Expected the warning to name pnpm and its lock file, got %q", warning)
+	}
+	if (Tooling{}).Check("npm install") != "" {
+		t.Error("Expected no warning without detected tooling")
+	}
+}
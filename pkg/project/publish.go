@@ -0,0 +1,110 @@
+package project
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// publishCommands lists, per program, the subcommands that publish a
+// package, push an image, create a release or change deployed
+// infrastructure. A subcommand of several words matches the leading
+// arguments that are not flags; "" matches a program run with none.
+var publishCommands = map[string][]string{
+	"npm":        {"publish"},
+	"pnpm":       {"publish"},
+	"yarn":       {"publish", "npm publish"},
+	"bun":        {"publish"},
+	"cargo":      {"publish"},
+	"gem":        {"push"},
+	"twine":      {"upload"},
+	"poetry":     {"publish"},
+	"uv":         {"publish"},
+	"flit":       {"publish"},
+	"hatch":      {"publish"},
+	"dotnet":     {"nuget push"},
+	"goreleaser": {"", "release"},
+	"docker":     {"push", "image push", "manifest push"},
+	"podman":     {"push", "image push", "manifest push"},
+	"terraform":  {"apply", "destroy", "import"},
+	"tofu":       {"apply", "destroy", "import"},
+	"pulumi":     {"up", "destroy"},
+	"cdk":        {"deploy", "destroy"},
+	"sam":        {"deploy", "delete"},
+	"serverless": {"deploy", "remove"},
+	"sls":        {"deploy", "remove"},
+	"helm":       {"install", "upgrade", "uninstall", "rollback", "push"},
+	"kubectl":    {"apply", "create", "delete", "replace", "patch", "rollout"},
+	"gh":         {"release create", "release upload", "release edit", "release delete"},
+	"vercel":     {"", "deploy", "promote"},
+	"netlify":    {"deploy"},
+	"fly":        {"deploy"},
+	"flyctl":     {"deploy"},
+	"firebase":   {"deploy"},
+	"wrangler":   {"deploy", "publish"},
+	"gcloud":     {"app deploy", "run deploy", "functions deploy"},
+	"aws":        {"cloudformation deploy", "s3 sync", "lambda update-function-code"},
+}
+
+// goalCommands take a list of goals, any of which may publish
+var goalCommands = map[string][]string{
+	"mvn":     {"deploy", "release:perform"},
+	"mvnw":    {"deploy", "release:perform"},
+	"gradle":  {"publish"},
+	"gradlew": {"publish"},
+}
+
+// PublishCommand returns the part of a shell command line that publishes or
+// deploys something other people depend on, such as `npm publish`,
+// `docker push`, `terraform apply` or `gh release create`, or "" if none
+// does. Such commands are hard to take back, so they need confirming even
+// when other commands run without asking.
+func PublishCommand(command string) string {
+	for _, part := range commandSeparators.Split(command, -1) {
+		fields := strings.Fields(part)
+		for len(fields) > 0 && (strings.Contains(fields[0], "=") || fields[0] == "sudo" || fields[0] == "command" || fields[0] == "exec" ||
+			fields[0] == "npx" || fields[0] == "bunx" || fields[0] == "time") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		name := filepath.Base(fields[0])
+		// The first word may be the value of a flag before the subcommand,
+		// as in kubectl -n prod apply, so matching can also start after it
+		var words []string
+		valueFirst := false
+		for i, arg := range fields[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				if len(words) == 0 && i > 0 && strings.HasPrefix(fields[i], "-") && !strings.Contains(fields[i], "=") {
+					valueFirst = true
+				}
+				words = append(words, arg)
+			}
+		}
+
+		for _, goal := range goalCommands[name] {
+			for _, word := range words {
+				if word == goal {
+					return strings.TrimSpace(part)
+				}
+			}
+		}
+		for _, sub := range publishCommands[name] {
+			if sub == "" {
+				if len(words) == 0 {
+					return strings.TrimSpace(part)
+				}
+				continue
+			}
+			if startsWith(words, sub) || (valueFirst && startsWith(words[1:], sub)) {
+				return strings.TrimSpace(part)
+			}
+		}
+	}
+	return ""
+}
+
+func startsWith(words []string, sub string) bool {
+	subWords := strings.Fields(sub)
+	return len(words) >= len(subWords) && strings.Join(words[:len(subWords)], " ") == sub
+}
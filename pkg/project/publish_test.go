@@ -0,0 +1,31 @@
+package project
+
+import "testing"
+
+func TestPublishCommand(t *testing.T) {
+	cases := map[string]string{
+		"npm publish":                                "npm publish",
+		"npm test && npm publish --tag next":         "npm publish --tag next",
+		"NPM_TOKEN=x npx vercel --prod":              "NPM_TOKEN=x npx vercel --prod",
+		"docker build -t app . && docker push app":   "docker push app",
+		"terraform -chdir=infra apply -auto-approve": "terraform -chdir=infra apply -auto-approve",
+		"kubectl -n prod apply -f deploy.yaml":       "kubectl -n prod apply -f deploy.yaml",
+		"gh release create v1.2.0 --notes x":         "gh release create v1.2.0 --notes x",
+		"./mvnw clean deploy":                        "./mvnw clean deploy",
+		"sudo gem push pkg.gem":                      "sudo gem push pkg.gem",
+		"npm test":                                   "",
+		"npm run publish-docs":                       "",
+		"docker pull app":                            "",
+		"terraform plan":                             "",
+		"gh release list":                            "",
+		"kubectl get pods":                           "",
+		"vercel ls":                                  "",
+		"echo npm publish":                           "",
+		"./gradlew build":                            "",
+	}
+	for command, want := range cases {
+		if got := PublishCommand(command); got != want {
+			t.Errorf("PublishCommand(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Thresholds above which a repository counts as large
+const (
+	largeRepoFiles = 5000
+	largeRepoBytes = 500 * 1024 * 1024
+	maxScanStats   = 300000 // Files whose size is looked up
+)
+
+// vendorDirs are directory names that hold third-party or generated code
+var vendorDirs = map[string]bool{
+	"vendor": true, "node_modules": true, "third_party": true, "third-party": true, "thirdparty": true,
+	"external": true, "extern": true, "bower_components": true, "Pods": true, "Carthage": true,
+	"dist": true, "generated": true, "__generated__": true, ".yarn": true,
+}
+
+// binaryExts are extensions of files that are not worth reading as text
+var binaryExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".ico": true, ".webp": true, ".bmp": true, ".tiff": true,
+	".pdf": true, ".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".7z": true, ".rar": true, ".tar": true,
+	".jar": true, ".war": true, ".class": true, ".so": true, ".dylib": true, ".dll": true, ".exe": true, ".a": true, ".o": true,
+	".wasm": true, ".bin": true, ".dat": true, ".db": true, ".sqlite": true, ".pyc": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp3": true, ".mp4": true, ".mov": true, ".wav": true, ".avi": true, ".webm": true,
+	".pt": true, ".onnx": true, ".safetensors": true, ".ckpt": true, ".h5": true, ".pkl": true, ".npy": true, ".parquet": true,
+}
+
+// DirStats counts the files under a directory
+type DirStats struct {
+	Path  string
+	Files int
+	Bytes int64
+}
+
+// Scan is the size and makeup of a repository's files
+type Scan struct {
+	Files       int
+	Bytes       int64
+	BinaryFiles int
+	BinaryBytes int64
+	TopDirs     []DirStats // Largest top-level directories by file count
+	Vendored    []DirStats // Third-party and generated code, largest first
+}
+
+// ScanFiles measures the files, given as slash-separated paths relative to
+// the current directory. Sizes are looked up for the first maxScanStats
+// files only, so huge repositories still scan quickly.
+func ScanFiles(files []string) Scan {
+	s := Scan{Files: len(files)}
+	top := make(map[string]*DirStats)
+	vendored := make(map[string]*DirStats)
+	for i, f := range files {
+		var size int64
+		if i < maxScanStats {
+			if info, err := os.Lstat(f); err == nil {
+				size = info.Size()
+			}
+		}
+		s.Bytes += size
+
+		if binaryExts[strings.ToLower(path.Ext(f))] {
+			s.BinaryFiles++
+			s.BinaryBytes += size
+		}
+
+		dir := "."
+		if first, _, ok := strings.Cut(f, "/"); ok {
+			dir = first
+		}
+		addToDir(top, dir, size)
+
+		segments := strings.Split(f, "/")
+		for j, seg := range segments[:len(segments)-1] {
+			if vendorDirs[seg] {
+				addToDir(vendored, strings.Join(segments[:j+1], "/"), size)
+				break
+			}
+		}
+	}
+	s.TopDirs = largest(top, 5)
+	s.Vendored = largest(vendored, 10)
+	return s
+}
+
+func addToDir(dirs map[string]*DirStats, dir string, size int64) {
+	d := dirs[dir]
+	if d == nil {
+		d = &DirStats{Path: dir}
+		dirs[dir] = d
+	}
+	d.Files++
+	d.Bytes += size
+}
+
+// largest returns the n directories with the most files
+func largest(dirs map[string]*DirStats, n int) []DirStats {
+	var list []DirStats
+	for _, d := range dirs {
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Files != list[j].Files {
+			return list[i].Files > list[j].Files
+		}
+		return list[i].Path < list[j].Path
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Large reports whether the repository is big enough that reading broadly
+// would waste the context window
+func (s Scan) Large() bool {
+	return s.Files > largeRepoFiles || s.Bytes > largeRepoBytes
+}
+
+// IgnorePatterns returns globs for the vendored directories, which searches
+// should skip unless asked to look inside them
+func (s Scan) IgnorePatterns() []string {
+	var patterns []string
+	for _, d := range s.Vendored {
+		patterns = append(patterns, d.Path+"/**")
+	}
+	return patterns
+}
+
+// Summary describes the scan for the system prompt
+func (s Scan) Summary() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d files, %s", s.Files, formatSize(s.Bytes))
+	if s.BinaryFiles > 0 {
+		fmt.Fprintf(&sb, " (%d binary files, %s)", s.BinaryFiles, formatSize(s.BinaryBytes))
+	}
+	sb.WriteString("\nLargest top-level directories:")
+	for _, d := range s.TopDirs {
+		fmt.Fprintf(&sb, "\n  %s: %d files, %s", d.Path, d.Files, formatSize(d.Bytes))
+	}
+	if len(s.Vendored) > 0 {
+		sb.WriteString("\nThird-party or generated code:")
+		for _, d := range s.Vendored {
+			fmt.Fprintf(&sb, "\n  %s: %d files", d.Path, d.Files)
+		}
+	}
+	return sb.String()
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,62 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanFiles(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	write := func(name string, size int) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, make([]byte, size), 0644)
+		files = append(files, filepath.ToSlash(path))
+	}
+	for i := 0; i < 3; i++ {
+		write(fmt.Sprintf("src/app/file%d.go", i), 100)
+	}
+	for i := 0; i < 5; i++ {
+		write(fmt.Sprintf("services/api/vendor/lib/f%d.go", i), 10)
+	}
+	write("assets/logo.png", 1000)
+
+	scan := ScanFiles(files)
+	if scan.Files != 9 || scan.Bytes != 1350 {
+		t.Errorf("Files = %d, Bytes = %d", scan.Files, scan.Bytes)
+	}
+	if scan.BinaryFiles != 1 || scan.BinaryBytes != 1000 {
+		t.Errorf("BinaryFiles = %d, BinaryBytes = %d", scan.BinaryFiles, scan.BinaryBytes)
+	}
+	vendor := filepath.ToSlash(filepath.Join(dir, "services/api/vendor"))
+	if len(scan.Vendored) != 1 || scan.Vendored[0].Path != vendor || scan.Vendored[0].Files != 5 {
+		t.Errorf("Vendored = %+v", scan.Vendored)
+	}
+	if got := scan.IgnorePatterns(); len(got) != 1 || got[0] != vendor+"/**" {
+		t.Errorf("IgnorePatterns = %v", got)
+	}
+	if scan.Large() {
+		t.Error("9 files is not a large repository")
+	}
+	if summary := scan.Summary(); !strings.Contains(summary, "9 files") || !strings.Contains(summary, "Third-party or generated code") {
+		t.Errorf("Summary = %q", summary)
+	}
+}
+
+func TestScanLarge(t *testing.T) {
+	files := make([]string, largeRepoFiles+1)
+	for i := range files {
+		files[i] = fmt.Sprintf("pkg%d/missing.go", i%7)
+	}
+	scan := ScanFiles(files)
+	if !scan.Large() {
+		t.Error("expected a large repository")
+	}
+	if len(scan.TopDirs) != 5 {
+		t.Errorf("TopDirs = %+v", scan.TopDirs)
+	}
+}
@@ -0,0 +1,186 @@
+package review
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Tool describes the program that produced the findings, for SARIF
+type Tool struct {
+	Name    string
+	Version string
+	URI     string
+}
+
+// Write writes findings in the given format
+func Write(w io.Writer, format string, findings []Finding, tool Tool) error {
+	switch format {
+	case "", "text":
+		return WriteText(w, findings)
+	case "sarif":
+		return WriteSARIF(w, findings, tool)
+	case "checkstyle":
+		return WriteCheckstyle(w, findings)
+	}
+	return fmt.Errorf("unknown format %q (use text, sarif or checkstyle)", format)
+}
+
+// WriteText writes one finding per line, as compilers do
+func WriteText(w io.Writer, findings []Finding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No problems found")
+		return err
+	}
+	for _, f := range findings {
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s [%s] %s\n", location, f.Severity, f.Rule, f.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SARIF 2.1.0, as much of it as code scanning needs
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes findings as a SARIF 2.1.0 log, which GitHub code
+// scanning and most CI dashboards import
+func WriteSARIF(w io.Writer, findings []Finding, tool Tool) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: tool.Name, Version: tool.Version, InformationURI: tool.URI, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+	rules := make(map[string]bool)
+	for _, f := range findings {
+		if !rules[f.Rule] {
+			rules[f.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: f.Rule, ShortDescription: sarifMessage{Text: f.Rule}})
+		}
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = f.File
+		if f.Line > 0 {
+			loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     f.Severity,
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool { return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+type checkstyleLog struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// WriteCheckstyle writes findings as checkstyle XML, grouped by file in the
+// order the files first appear
+func WriteCheckstyle(w io.Writer, findings []Finding) error {
+	log := checkstyleLog{Version: "4.3"}
+	index := make(map[string]int)
+	for _, f := range findings {
+		i, ok := index[f.File]
+		if !ok {
+			i = len(log.Files)
+			index[f.File] = i
+			log.Files = append(log.Files, checkstyleFile{Name: f.File})
+		}
+		severity := f.Severity
+		if severity == SeverityNote {
+			severity = "info" // Checkstyle's name for it
+		}
+		log.Files[i].Errors = append(log.Files[i].Errors, checkstyleError{
+			Line: f.Line, Severity: severity, Message: f.Message, Source: "john." + f.Rule,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
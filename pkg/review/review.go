@@ -0,0 +1,120 @@
+// Package review holds the findings of john review and writes them as text,
+// SARIF for GitHub code scanning, or checkstyle XML for other CI systems.
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severities of findings, as SARIF names them
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityNote    = "note"
+)
+
+// Finding is one problem the review found
+type Finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"` // 1-based line in the new version; 0 if unknown
+	Severity string `json:"severity"`       // error, warning or note
+	Rule     string `json:"rule"`           // Short category such as "bug" or "secret"
+	Message  string `json:"message"`
+}
+
+// jsonArray finds the outermost JSON array in a model reply, which may wrap
+// it in a code fence or a sentence
+var jsonArray = regexp.MustCompile(`(?s)\[.*\]`)
+
+// Parse reads the findings from a model reply holding a JSON array of
+// them. Findings without a file or message are dropped, and severities and
+// rules are normalized.
+func Parse(reply string) ([]Finding, error) {
+	raw := jsonArray.FindString(reply)
+	if raw == "" {
+		if strings.TrimSpace(reply) == "" {
+			return nil, fmt.Errorf("empty reply")
+		}
+		return nil, fmt.Errorf("no JSON array of findings in the reply")
+	}
+	var parsed []Finding
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid findings: %w", err)
+	}
+
+	findings := []Finding{}
+	for _, f := range parsed {
+		f.File = strings.TrimPrefix(strings.TrimSpace(f.File), "b/")
+		f.Message = strings.TrimSpace(f.Message)
+		if f.File == "" || f.Message == "" {
+			continue
+		}
+		if f.Line < 0 {
+			f.Line = 0
+		}
+		switch strings.ToLower(f.Severity) {
+		case "error", "critical", "high":
+			f.Severity = SeverityError
+		case "note", "info", "low", "suggestion":
+			f.Severity = SeverityNote
+		default:
+			f.Severity = SeverityWarning
+		}
+		f.Rule = strings.Trim(strings.ToLower(strings.Join(strings.Fields(f.Rule), "-")), "-")
+		if f.Rule == "" {
+			f.Rule = "general"
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// Count returns how many findings are at least as severe as severity
+func Count(findings []Finding, severity string) int {
+	rank := map[string]int{SeverityNote: 0, SeverityWarning: 1, SeverityError: 2}
+	n := 0
+	for _, f := range findings {
+		if rank[f.Severity] >= rank[severity] {
+			n++
+		}
+	}
+	return n
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// NumberDiff prefixes the lines of a unified diff with their line numbers in
+// the new version of the file, so a model can cite them. Removed lines get
+// no number.
+func NumberDiff(diff string) string {
+	var sb strings.Builder
+	line := 0
+	inHunk := false
+	for _, l := range strings.Split(diff, "\n") {
+		if m := hunkHeader.FindStringSubmatch(l); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			inHunk = true
+			sb.WriteString(l + "\n")
+			continue
+		}
+		if strings.HasPrefix(l, "diff --git ") {
+			inHunk = false
+		}
+		switch {
+		case !inHunk:
+			sb.WriteString(l + "\n")
+		case strings.HasPrefix(l, "-"):
+			fmt.Fprintf(&sb, "%6s %s\n", "", l)
+		case strings.HasPrefix(l, "+"), strings.HasPrefix(l, " "):
+			fmt.Fprintf(&sb, "%6d %s\n", line, l)
+			line++
+		default:
+			sb.WriteString(l + "\n") // "\ No newline at end of file"
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
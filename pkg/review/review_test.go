@@ -0,0 +1,142 @@
+package review
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	reply := "Here are the findings:\n```json\n" + `[
+  {"file": "b/main.go", "line": 12, "severity": "critical", "rule": "Nil Dereference", "message": "p can be nil"},
+  {"file": "util.go", "severity": "info", "message": "unused helper"},
+  {"file": "", "line": 3, "severity": "error", "message": "no file"}
+]` + "\n```"
+	findings, err := Parse(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{File: "main.go", Line: 12, Severity: SeverityError, Rule: "nil-dereference", Message: "p can be nil"},
+		{File: "util.go", Severity: SeverityNote, Rule: "general", Message: "unused helper"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+
+	if findings, err := Parse("[]"); err != nil || len(findings) != 0 {
+		t.Errorf("Parse([]) = %v, %v", findings, err)
+	}
+	if _, err := Parse("Looks good to me!"); err == nil {
+		t.Error("expected an error for a reply without JSON")
+	}
+}
+
+func TestCount(t *testing.T) {
+	findings := []Finding{{Severity: SeverityError}, {Severity: SeverityWarning}, {Severity: SeverityNote}}
+	for severity, want := range map[string]int{SeverityError: 1, SeverityWarning: 2, SeverityNote: 3} {
+		if got := Count(findings, severity); got != want {
+			t.Errorf("Count(%s) = %d, want %d", severity, got, want)
+		}
+	}
+}
+
+func TestNumberDiff(t *testing.T) {
+	diff := `diff --git a/x.go b/x.go
+--- a/x.go
++++ b/x.go
+@@ -10,3 +10,3 @@ func f() {
+ 	a := 1
+-	b := 2
++	b := 3
+ 	return`
+	got := NumberDiff(diff)
+	for _, want := range []string{"--- a/x.go", "    10  \ta := 1", "       -\tb := 2", "    11 +\tb := 3", "    12  \treturn"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("numbered diff missing %q:\n%s", want, got)
+		}
+	}
+}
+
+var sample = []Finding{
+	{File: "main.go", Line: 12, Severity: SeverityError, Rule: "bug", Message: "p can be nil"},
+	{File: "main.go", Severity: SeverityNote, Rule: "style", Message: `use "errors.Is"`},
+	{File: "db/query.go", Line: 4, Severity: SeverityWarning, Rule: "bug", Message: "SQL built from input"},
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, sample, Tool{Name: "john-code", Version: "0.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "bug" {
+		t.Errorf("rules = %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("got %d results", len(run.Results))
+	}
+	first := run.Results[0]
+	if first.Level != "error" || first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "main.go" ||
+		first.Locations[0].PhysicalLocation.Region.StartLine != 12 {
+		t.Errorf("first result = %+v", first)
+	}
+	if run.Results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Error("a finding without a line should have no region")
+	}
+
+	// No findings is still a valid log with an empty results array
+	buf.Reset()
+	WriteSARIF(&buf, nil, Tool{Name: "john-code"})
+	if !strings.Contains(buf.String(), `"results": []`) {
+		t.Errorf("empty log should have empty results:\n%s", buf.String())
+	}
+}
+
+func TestWriteCheckstyle(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCheckstyle(&buf, sample); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "<?xml") {
+		t.Errorf("missing XML header:\n%s", out)
+	}
+	var log checkstyleLog
+	if err := xml.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+	if len(log.Files) != 2 || log.Files[0].Name != "main.go" || len(log.Files[0].Errors) != 2 {
+		t.Fatalf("files = %+v", log.Files)
+	}
+	if e := log.Files[0].Errors[1]; e.Severity != "info" || e.Message != `use "errors.Is"` || e.Source != "john.style" {
+		t.Errorf("note = %+v", e)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "text", sample[:1], Tool{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "main.go:12: error [bug] p can be nil\n" {
+		t.Errorf("text = %q", got)
+	}
+	if err := Write(&buf, "junit", sample, Tool{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,116 @@
+// Package slack is a small client for the parts of the Slack API john slack
+// needs: Socket Mode to receive messages and chat.postMessage/chat.update to
+// answer them.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// DefaultAPIURL is the Slack Web API
+const DefaultAPIURL = "https://slack.com/api/"
+
+// MaxMessageLength is the longest text john puts in one message. Slack
+// accepts more but truncates long messages in the UI.
+const MaxMessageLength = 3500
+
+// Client talks to Slack with an app-level token (for Socket Mode) and a bot
+// token (for the Web API)
+type Client struct {
+	APIURL   string // Defaults to DefaultAPIURL; tests point it elsewhere
+	appToken string
+	botToken string
+	http     *http.Client
+}
+
+// NewClient returns a client for the given tokens
+func NewClient(appToken, botToken string) *Client {
+	return &Client{
+		APIURL:   DefaultAPIURL,
+		appToken: appToken,
+		botToken: botToken,
+		http:     config.NewHTTPClient(30 * time.Second),
+	}
+}
+
+// call posts params to a Web API method and decodes the reply into out.
+// Slack reports failures as "ok": false with an error code.
+func (c *Client) call(ctx context.Context, token, method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.APIURL+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s: rate limited, retry after %ss", method, resp.Header.Get("Retry-After"))
+	}
+
+	var reply struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	raw := new(bytes.Buffer)
+	if _, err := raw.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if err := json.Unmarshal(raw.Bytes(), &reply); err != nil {
+		return fmt.Errorf("%s: %s: %w", method, resp.Status, err)
+	}
+	if !reply.OK {
+		return fmt.Errorf("%s: %s", method, reply.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(raw.Bytes(), out)
+	}
+	return nil
+}
+
+// AuthTest returns the bot's own user ID, which mentions of it contain
+func (c *Client) AuthTest(ctx context.Context) (string, error) {
+	var reply struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.call(ctx, c.botToken, "auth.test", struct{}{}, &reply); err != nil {
+		return "", err
+	}
+	return reply.UserID, nil
+}
+
+// PostMessage posts text to a channel, as a reply in a thread when threadTS
+// is set, and returns the new message's timestamp
+func (c *Client) PostMessage(ctx context.Context, channel, threadTS, text string) (string, error) {
+	params := map[string]string{"channel": channel, "text": text}
+	if threadTS != "" {
+		params["thread_ts"] = threadTS
+	}
+	var reply struct {
+		TS string `json:"ts"`
+	}
+	if err := c.call(ctx, c.botToken, "chat.postMessage", params, &reply); err != nil {
+		return "", err
+	}
+	return reply.TS, nil
+}
+
+// UpdateMessage replaces the text of a message the bot posted
+func (c *Client) UpdateMessage(ctx context.Context, channel, ts, text string) error {
+	return c.call(ctx, c.botToken, "chat.update", map[string]string{"channel": channel, "ts": ts, "text": text}, nil)
+}
@@ -0,0 +1,169 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// fakeSlack serves the Web API methods the client uses and a Socket Mode
+// endpoint that sends the given envelopes and records the acks
+type fakeSlack struct {
+	t         *testing.T
+	server    *httptest.Server
+	envelopes []string
+
+	mu    sync.Mutex
+	calls []string
+	acks  []string
+}
+
+func newFakeSlack(t *testing.T, envelopes ...string) *fakeSlack {
+	f := &fakeSlack{t: t, envelopes: envelopes}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", f.api)
+	mux.Handle("/socket", websocket.Handler(f.socket))
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeSlack) client() *Client {
+	c := NewClient("xapp-test", "xoxb-test")
+	c.APIURL = f.server.URL + "/api/"
+	return c
+}
+
+func (f *fakeSlack) api(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	var params map[string]string
+	json.NewDecoder(r.Body).Decode(&params)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, method+" "+r.Header.Get("Authorization")+" "+params["text"])
+	f.mu.Unlock()
+
+	switch method {
+	case "auth.test":
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "user_id": "UBOT"})
+	case "apps.connections.open":
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "url": "ws" + strings.TrimPrefix(f.server.URL, "http") + "/socket"})
+	case "chat.postMessage":
+		if params["channel"] == "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "channel_not_found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "ts": "1.0002"})
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+	}
+}
+
+func (f *fakeSlack) socket(ws *websocket.Conn) {
+	websocket.Message.Send(ws, `{"type":"hello"}`)
+	for _, env := range f.envelopes {
+		websocket.Message.Send(ws, env)
+		var ack map[string]string
+		if err := websocket.JSON.Receive(ws, &ack); err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.acks = append(f.acks, ack["envelope_id"])
+		f.mu.Unlock()
+	}
+	// Hold the connection until the client goes away
+	var ignored string
+	websocket.Message.Receive(ws, &ignored)
+}
+
+func TestWebAPI(t *testing.T) {
+	f := newFakeSlack(t)
+	c := f.client()
+	ctx := context.Background()
+
+	user, err := c.AuthTest(ctx)
+	if err != nil || user != "UBOT" {
+		t.Fatalf("AuthTest = %q, %v", user, err)
+	}
+	ts, err := c.PostMessage(ctx, "C1", "1.0001", "hello")
+	if err != nil || ts != "1.0002" {
+		t.Fatalf("PostMessage = %q, %v", ts, err)
+	}
+	if err := c.UpdateMessage(ctx, "C1", ts, "hello again"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if _, err := c.PostMessage(ctx, "", "", "x"); err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Errorf("expected the Slack error code, got %v", err)
+	}
+
+	want := []string{
+		"auth.test Bearer xoxb-test ",
+		"chat.postMessage Bearer xoxb-test hello",
+		"chat.update Bearer xoxb-test hello again",
+	}
+	for i, w := range want {
+		if f.calls[i] != w {
+			t.Errorf("call %d = %q, want %q", i, f.calls[i], w)
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	f := newFakeSlack(t,
+		`{"envelope_id":"e1","type":"events_api","payload":{"event":{"type":"app_mention","channel":"C1","user":"U1","text":"<@UBOT> hi","ts":"1.1"}}}`,
+		`{"envelope_id":"e2","type":"events_api","payload":{"event":{"type":"message","channel":"D1","channel_type":"im","user":"U1","text":"yo","ts":"1.2","thread_ts":"1.1"}}}`,
+	)
+	c := f.client()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []Event
+	err := c.Listen(ctx, func(e Event) {
+		events = append(events, e)
+		if len(events) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Type != "app_mention" || events[0].Text != "<@UBOT> hi" || events[0].Channel != "C1" {
+		t.Errorf("unexpected first event %+v", events[0])
+	}
+	if events[1].ChannelType != "im" || events[1].ThreadTS != "1.1" {
+		t.Errorf("unexpected second event %+v", events[1])
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.acks) < 1 || f.acks[0] != "e1" {
+		t.Errorf("events were not acknowledged: %v", f.acks)
+	}
+	if !strings.HasPrefix(f.calls[0], "apps.connections.open Bearer xapp-test") {
+		t.Errorf("Socket Mode must use the app token, got %q", f.calls[0])
+	}
+}
+
+func TestListenBadToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+	}))
+	defer server.Close()
+	c := NewClient("bad", "bad")
+	c.APIURL = server.URL + "/"
+
+	err := c.Listen(context.Background(), func(Event) {})
+	if err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("expected invalid_auth, got %v", err)
+	}
+}
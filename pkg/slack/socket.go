@@ -0,0 +1,113 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Event is a message event delivered over Socket Mode: an app_mention, or a
+// message in a channel or DM the app is subscribed to
+type Event struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"` // "im" for direct messages
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Text        string `json:"text"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"` // Empty unless the message is in a thread
+}
+
+// envelope wraps everything Socket Mode sends
+type envelope struct {
+	EnvelopeID string `json:"envelope_id"`
+	Type       string `json:"type"` // hello, events_api, disconnect, ...
+	Reason     string `json:"reason"`
+	Payload    struct {
+		Event Event `json:"event"`
+	} `json:"payload"`
+}
+
+// reconnectDelay is the pause before reconnecting after a dropped connection
+var reconnectDelay = 5 * time.Second
+
+// openConnection asks Slack for a Socket Mode WebSocket URL
+func (c *Client) openConnection(ctx context.Context) (string, error) {
+	var reply struct {
+		URL string `json:"url"`
+	}
+	if err := c.call(ctx, c.appToken, "apps.connections.open", struct{}{}, &reply); err != nil {
+		return "", err
+	}
+	return reply.URL, nil
+}
+
+// Listen receives events over Socket Mode and calls handle for each one,
+// reconnecting when Slack asks to or the connection drops, until ctx is
+// done. Events are acknowledged before handle runs; handle must not block,
+// as the events behind it wait. Failing to connect the first time, as with
+// a bad token, is returned as an error.
+func (c *Client) Listen(ctx context.Context, handle func(Event)) error {
+	url, err := c.openConnection(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		if err == nil {
+			err = c.listenOnce(ctx, url, handle)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Warning: Slack connection lost: %v; reconnecting in %s\n", err, reconnectDelay)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(reconnectDelay):
+			}
+		}
+		url, err = c.openConnection(ctx)
+	}
+}
+
+// listenOnce handles one WebSocket connection. It returns nil when Slack
+// asked to reconnect.
+func (c *Client) listenOnce(ctx context.Context, url string, handle func(Event)) error {
+	wsConfig, err := websocket.NewConfig(url, "https://slack.com")
+	if err != nil {
+		return err
+	}
+	conn, err := wsConfig.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		var env envelope
+		if err := websocket.JSON.Receive(conn, &env); err != nil {
+			return err
+		}
+		if env.EnvelopeID != "" {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": env.EnvelopeID})
+			if err := websocket.Message.Send(conn, string(ack)); err != nil {
+				return err
+			}
+		}
+		switch env.Type {
+		case "disconnect":
+			// Sent before Slack rotates the connection; open a new one
+			return nil
+		case "events_api":
+			handle(env.Payload.Event)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// batchLatency is the round trip above which an SSH target fetches the
+// files of several Read calls in one sftp session instead of one each
+const batchLatency = 50 * time.Millisecond
+
+// Connector is implemented by targets that check they can be reached
+// before the tools use them
+type Connector interface {
+	// Connect opens the connection and returns its round-trip time
+	Connect(ctx context.Context) (time.Duration, error)
+}
+
+// Prefetcher is implemented by targets where fetching several files at once
+// is cheaper than one at a time. The agent prefetches the files of the Read
+// calls in a response before running them.
+type Prefetcher interface {
+	// Prefetch fetches paths for the ReadFile calls that follow, until
+	// release is called. Files that cannot be fetched are left to ReadFile.
+	Prefetch(ctx context.Context, paths []string) (release func())
+}
+
+// SSH is a target on an SSH host. Commands run through the ssh command, so
+// ~/.ssh/config, agents and keys work as they do in a terminal, and files
+// move over sftp when it is installed. All of them share one master
+// connection, so each costs a round trip rather than a new handshake.
+type SSH struct {
+	*remote
+	settings config.TargetSettings
+	sftp     bool // The sftp command is installed
+
+	mu         sync.Mutex
+	latency    time.Duration     // Round trip measured by Connect
+	prefetched map[string][]byte // Files fetched by Prefetch, until released
+}
+
+// NewSSH returns a target on the host in s, working in s.Dir
+func NewSSH(s config.TargetSettings, localDir string) *SSH {
+	t := &SSH{settings: s}
+	_, err := exec.LookPath("sftp")
+	t.sftp = err == nil
+	t.remote = &remote{
+		name:     "ssh " + s.Host,
+		dir:      s.Dir,
+		localDir: localDir,
+		command:  t.command,
+	}
+	return t
+}
+
+// options are the ssh and sftp options: no prompts, a shared connection,
+// and the port and key from the settings
+func (t *SSH) options() []string {
+	opts := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(os.TempDir(), "john-ssh-%C"),
+		"-o", "ControlPersist=10m",
+	}
+	if t.settings.Port != 0 {
+		opts = append(opts, "-o", "Port="+strconv.Itoa(t.settings.Port))
+	}
+	if key := t.settings.IdentityFile; key != "" {
+		if rest, ok := strings.CutPrefix(key, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				key = filepath.Join(home, rest)
+			}
+		}
+		opts = append(opts, "-o", "IdentitiesOnly=yes", "-i", key)
+	}
+	return opts
+}
+
+func (t *SSH) command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	var prefix strings.Builder
+	for _, pair := range env {
+		name, value, _ := strings.Cut(pair, "=")
+		prefix.WriteString("export " + name + "=" + Quote(value) + "; ")
+	}
+	remoteScript := prefix.String() + "cd " + quotePath(dir) + " && " + script
+	args := append(t.options(), t.settings.Host, "bash -c "+Quote(remoteScript))
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+// Connect checks the host can be reached without prompting and that the
+// project directory exists, and measures the round trip over the shared
+// connection
+func (t *SSH) Connect(ctx context.Context) (time.Duration, error) {
+	if out, err := t.Command(ctx, "true", "", nil).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return 0, fmt.Errorf("cannot use %s:%s: %s", t.settings.Host, t.dir, lastLine(msg))
+		}
+		return 0, fmt.Errorf("cannot use %s:%s: %w", t.settings.Host, t.dir, err)
+	}
+	start := time.Now()
+	if err := t.Command(ctx, "true", "", nil).Run(); err != nil {
+		return 0, fmt.Errorf("cannot use %s: %w", t.settings.Host, err)
+	}
+	latency := time.Since(start)
+	t.mu.Lock()
+	t.latency = latency
+	t.mu.Unlock()
+	return latency, nil
+}
+
+// ReadFile reads a prefetched copy if there is one, else fetches the file
+func (t *SSH) ReadFile(ctx context.Context, p string) ([]byte, error) {
+	t.mu.Lock()
+	data, ok := t.prefetched[p]
+	t.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	if !t.sftp {
+		return t.remote.ReadFile(ctx, p)
+	}
+	files, err := t.get(ctx, []string{p}, false)
+	if err != nil {
+		return nil, t.fileError("read", p, err, err.Error())
+	}
+	return files[p], nil
+}
+
+// WriteFile uploads data to p over sftp
+func (t *SSH) WriteFile(ctx context.Context, p string, data []byte) error {
+	t.mu.Lock()
+	delete(t.prefetched, p)
+	t.mu.Unlock()
+	if !t.sftp {
+		return t.remote.WriteFile(ctx, p, data)
+	}
+
+	tmp, err := os.CreateTemp("", "john-put-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// sftp gives new files the local file's mode
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := t.runSFTP(ctx, "put "+sftpQuote(tmp.Name())+" "+sftpQuote(p)+"\n"); err != nil {
+		return t.fileError("write", p, err, err.Error())
+	}
+	return nil
+}
+
+// Prefetch fetches the files of several Read calls in one sftp session when
+// the host is far enough away for that to matter
+func (t *SSH) Prefetch(ctx context.Context, paths []string) func() {
+	t.mu.Lock()
+	worthIt := t.sftp && len(paths) > 1 && t.latency >= batchLatency
+	t.mu.Unlock()
+	if !worthIt {
+		return func() {}
+	}
+	files, err := t.get(ctx, paths, true)
+	if err != nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.prefetched = files
+	t.mu.Unlock()
+	return func() {
+		t.mu.Lock()
+		t.prefetched = nil
+		t.mu.Unlock()
+	}
+}
+
+// get downloads paths in one sftp session. With skipMissing, files that
+// cannot be fetched are left out instead of failing the batch.
+func (t *SSH) get(ctx context.Context, paths []string, skipMissing bool) (map[string][]byte, error) {
+	dir, err := os.MkdirTemp("", "john-get-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var batch strings.Builder
+	for i, p := range paths {
+		if skipMissing {
+			batch.WriteString("-") // sftp goes on after a failed command prefixed with -
+		}
+		batch.WriteString("get " + sftpQuote(p) + " " + sftpQuote(filepath.Join(dir, strconv.Itoa(i))) + "\n")
+	}
+	if err := t.runSFTP(ctx, batch.String()); err != nil && !skipMissing {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for i, p := range paths {
+		if data, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(i))); err == nil {
+			files[p] = data
+		}
+	}
+	return files, nil
+}
+
+func (t *SSH) runSFTP(ctx context.Context, batch string) error {
+	args := append(t.options(), "-b", "-", t.settings.Host)
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(batch)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s", lastLine(msg))
+		}
+		return err
+	}
+	return nil
+}
+
+// ParseURL reads a target written as a URL, for john target add:
+//
+//	ssh://[user@]host[:port]/path    (ssh://user@host:/path works too)
+//	ssh://host/~/path                a path in the remote home directory
+//	devcontainer[://container[/path]]
+//	local
+func ParseURL(raw string) (config.TargetSettings, error) {
+	switch raw {
+	case "local":
+		return config.TargetSettings{Type: "local"}, nil
+	case "devcontainer":
+		return config.TargetSettings{Type: "devcontainer"}, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return config.TargetSettings{}, fmt.Errorf("invalid target %q: %w", raw, err)
+	}
+	dir := u.Path
+	if strings.HasPrefix(dir, "/~") {
+		dir = dir[1:]
+	}
+	switch u.Scheme {
+	case "ssh":
+		if u.Hostname() == "" || dir == "" || dir == "/" {
+			return config.TargetSettings{}, fmt.Errorf("invalid target %q: want ssh://[user@]host[:port]/path", raw)
+		}
+		s := config.TargetSettings{Type: "ssh", Host: u.Hostname(), Dir: dir}
+		if u.User != nil {
+			s.Host = u.User.Username() + "@" + s.Host
+		}
+		if port := u.Port(); port != "" {
+			if s.Port, err = strconv.Atoi(port); err != nil {
+				return config.TargetSettings{}, fmt.Errorf("invalid port in %q", raw)
+			}
+		}
+		return s, nil
+	case "devcontainer":
+		return config.TargetSettings{Type: "devcontainer", Container: u.Host, Dir: strings.TrimSuffix(dir, "/")}, nil
+	}
+	return config.TargetSettings{}, fmt.Errorf("invalid target %q: want ssh://..., devcontainer or local", raw)
+}
+
+// quoteDir quotes a directory for the remote shell, leaving a leading ~/
+// for it to expand
+func quotePath(dir string) string {
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		return "~/" + Quote(rest)
+	}
+	return Quote(dir)
+}
+
+// sftpQuote quotes a path for an sftp batch command. Sessions start in the
+// home directory, so ~/ paths become relative.
+func sftpQuote(p string) string {
+	p = strings.TrimPrefix(p, "~/")
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p) + `"`
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
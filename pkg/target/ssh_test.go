@@ -0,0 +1,116 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+func TestParseURL(t *testing.T) {
+	cases := map[string]config.TargetSettings{
+		"ssh://deploy@build.example.com:/srv/app":   {Type: "ssh", Host: "deploy@build.example.com", Dir: "/srv/app"},
+		"ssh://deploy@build.example.com:2222/srv/a": {Type: "ssh", Host: "deploy@build.example.com", Dir: "/srv/a", Port: 2222},
+		"ssh://box/~/src/app":                       {Type: "ssh", Host: "box", Dir: "~/src/app"},
+		"devcontainer":                              {Type: "devcontainer"},
+		"devcontainer://web/workspace/app/":         {Type: "devcontainer", Container: "web", Dir: "/workspace/app"},
+		"local":                                     {Type: "local"},
+	}
+	for raw, want := range cases {
+		if got, err := ParseURL(raw); err != nil || got != want {
+			t.Errorf("ParseURL(%q) = %+v, %v; want %+v", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"ssh://host", "ssh://host:x/srv", "ftp://host/srv", "build:/srv"} {
+		if _, err := ParseURL(raw); err == nil {
+			t.Errorf("ParseURL(%q) succeeded", raw)
+		}
+	}
+}
+
+// fakeSSH puts ssh and sftp commands on PATH that act on this machine: ssh
+// runs its last argument, and sftp runs get and put as copies
+func fakeSSH(t *testing.T) {
+	bin := t.TempDir()
+	scripts := map[string]string{
+		"ssh": `#!/bin/bash
+for last; do :; done
+eval "$last"
+`,
+		"sftp": `#!/bin/bash
+status=0
+while IFS= read -r line; do
+	ignore=false
+	[[ $line == -* ]] && ignore=true && line=${line#-}
+	eval "set -- $line"
+	echo "$@" >> "$SFTP_LOG"
+	if ! cp "$2" "$3" 2>/dev/null; then
+		echo "remote open(\"$2\"): No such file or directory" >&2
+		$ignore || exit 1
+	fi
+done
+exit $status
+`,
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("SFTP_LOG", filepath.Join(bin, "sftp.log"))
+}
+
+func TestSSHTarget(t *testing.T) {
+	fakeSSH(t)
+	dir := t.TempDir()
+	s := NewSSH(config.TargetSettings{Type: "ssh", Host: "box", Dir: dir, Port: 2222}, "/home/me/project")
+	ctx := context.Background()
+
+	if _, err := s.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	out, err := s.Command(ctx, "pwd; echo $NAME", "", []string{"NAME=it's"}).Output()
+	if err != nil || string(out) != dir+"\nit's\n" {
+		t.Errorf("Command output = %q, %v", out, err)
+	}
+
+	a, b := s.Path("/home/me/project/a.txt"), s.Path("b.txt")
+	for _, p := range []string{a, b} {
+		if err := s.WriteFile(ctx, p, []byte("content of "+filepath.Base(p))); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	if data, err := s.ReadFile(ctx, a); err != nil || string(data) != "content of a.txt" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if _, err := s.ReadFile(ctx, s.Path("missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadFile of a missing file: err = %v", err)
+	}
+
+	// A near host fetches files one at a time; a far one in one session,
+	// skipping files that are missing
+	os.Remove(os.Getenv("SFTP_LOG"))
+	s.Prefetch(ctx, []string{a, b})()
+	if _, err := os.Stat(os.Getenv("SFTP_LOG")); err == nil {
+		t.Error("prefetched from a near host")
+	}
+	s.latency = batchLatency
+	release := s.Prefetch(ctx, []string{a, s.Path("missing"), b})
+	log, _ := os.ReadFile(os.Getenv("SFTP_LOG"))
+	if n := strings.Count(string(log), "get "); n != 3 {
+		t.Errorf("prefetch ran %d gets:\n%s", n, log)
+	}
+	os.WriteFile(b, []byte("changed"), 0644)
+	if data, _ := s.ReadFile(ctx, b); string(data) != "content of b.txt" {
+		t.Errorf("ReadFile did not use the prefetched copy: %q", data)
+	}
+	release()
+	if data, _ := s.ReadFile(ctx, b); string(data) != "changed" {
+		t.Errorf("ReadFile after release = %q", data)
+	}
+}
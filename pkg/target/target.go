@@ -0,0 +1,192 @@
+// Package target runs the tools' commands and file access on the machine
+// the code lives on: this one, a running devcontainer, or an SSH host.
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// Target is where Bash runs and where the file tools read and write
+type Target interface {
+	// Name describes the target for the user and the model
+	Name() string
+	// Remote reports whether the target is not this machine
+	Remote() bool
+	// Dir is the project's directory on the target
+	Dir() string
+	// Path maps a path the model gave to the target: relative paths are
+	// taken from Dir, and paths in the local project to the same file in Dir
+	Path(p string) string
+	// Command returns a command running script with bash in dir (Dir when
+	// empty), with env (NAME=value) added to the environment
+	Command(ctx context.Context, script, dir string, env []string) *exec.Cmd
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+	WriteFile(ctx context.Context, path string, data []byte) error
+}
+
+// Local is this machine
+type Local struct{}
+
+func (Local) Name() string         { return "local" }
+func (Local) Remote() bool         { return false }
+func (Local) Path(p string) string { return p }
+
+func (Local) Dir() string {
+	dir, _ := os.Getwd()
+	return dir
+}
+
+func (Local) Command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "bash", "-c", script)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+func (Local) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (Local) WriteFile(ctx context.Context, path string, data []byte) error {
+	return os.WriteFile(path, data, 0644)
+}
+
+// remote implements the file access of remote targets with commands, so a
+// target only has to say how to run one
+type remote struct {
+	name     string
+	dir      string // Project directory on the target
+	localDir string // Project directory here
+	command  func(ctx context.Context, script, dir string, env []string) *exec.Cmd
+}
+
+func (r *remote) Name() string { return r.name }
+func (r *remote) Remote() bool { return true }
+func (r *remote) Dir() string  { return r.dir }
+
+func (r *remote) Path(p string) string {
+	if p == "" {
+		return r.dir
+	}
+	if r.localDir != "" {
+		if rel, err := filepath.Rel(r.localDir, p); err == nil && filepath.IsAbs(p) && !strings.HasPrefix(rel, "..") {
+			return path.Join(r.dir, filepath.ToSlash(rel))
+		}
+	}
+	if !path.IsAbs(p) {
+		return path.Join(r.dir, p)
+	}
+	return p
+}
+
+func (r *remote) Command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	if dir == "" {
+		dir = r.dir
+	}
+	return r.command(ctx, script, dir, env)
+}
+
+func (r *remote) ReadFile(ctx context.Context, p string) ([]byte, error) {
+	cmd := r.Command(ctx, "cat -- "+quotePath(p), "", nil)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, r.fileError("read", p, err, stderr.String())
+	}
+	return out, nil
+}
+
+func (r *remote) WriteFile(ctx context.Context, p string, data []byte) error {
+	cmd := r.Command(ctx, "cat > "+quotePath(p), "", nil)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return r.fileError("write", p, err, stderr.String())
+	}
+	return nil
+}
+
+func (r *remote) fileError(op, p string, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		if strings.Contains(msg, "No such file") {
+			return fmt.Errorf("%s %s on %s: %w", op, p, r.name, os.ErrNotExist)
+		}
+		return fmt.Errorf("%s %s on %s: %s", op, p, r.name, msg)
+	}
+	return fmt.Errorf("%s %s on %s: %w", op, p, r.name, err)
+}
+
+// NewDevcontainer returns a target running in container, through docker exec
+func NewDevcontainer(container, dir, localDir string) Target {
+	return &remote{
+		name:     "devcontainer " + container,
+		dir:      dir,
+		localDir: localDir,
+		command: func(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+			args := []string{"exec", "-i", "-w", dir}
+			for _, pair := range env {
+				args = append(args, "-e", pair)
+			}
+			args = append(args, container, "bash", "-c", script)
+			return exec.CommandContext(ctx, "docker", args...)
+		},
+	}
+}
+
+// FromSettings returns the target the settings configure for the project in
+// localDir
+func FromSettings(s config.TargetSettings, localDir string) (Target, error) {
+	switch s.Type {
+	case "", "local":
+		return Local{}, nil
+	case "devcontainer":
+		container := s.Container
+		if container == "" {
+			var err error
+			if container, err = findDevcontainer(localDir); err != nil {
+				return nil, err
+			}
+		}
+		dir := s.Dir
+		if dir == "" {
+			dir = "/workspaces/" + filepath.Base(localDir) // The devcontainer CLI's default
+		}
+		return NewDevcontainer(container, dir, localDir), nil
+	case "ssh":
+		if s.Host == "" || s.Dir == "" {
+			return nil, fmt.Errorf("target: ssh needs host and dir")
+		}
+		return NewSSH(s, localDir), nil
+	}
+	return nil, fmt.Errorf("target: unknown type %q (want local, devcontainer or ssh)", s.Type)
+}
+
+// findDevcontainer returns the running container the devcontainer CLI (or
+// an editor using it) started for localDir
+func findDevcontainer(localDir string) (string, error) {
+	out, err := exec.Command("docker", "ps", "-q", "--filter", "label=devcontainer.local_folder="+localDir).Output()
+	if err != nil {
+		return "", fmt.Errorf("target: docker ps failed: %w", err)
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return "", fmt.Errorf("target: no running devcontainer for %s; start it with `devcontainer up --workspace-folder .` or set target.container", localDir)
+	}
+	return ids[0], nil
+}
+
+// Quote quotes s for a POSIX shell
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
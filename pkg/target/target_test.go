@@ -0,0 +1,84 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// fakeRemote is a remote target whose commands run here, with the project
+// directory standing in for the one on the other machine
+func fakeRemote(dir string) *remote {
+	return &remote{
+		name:     "fake",
+		dir:      dir,
+		localDir: "/home/me/project",
+		command: func(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+			cmd := exec.CommandContext(ctx, "bash", "-c", script)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), env...)
+			return cmd
+		},
+	}
+}
+
+func TestRemoteFiles(t *testing.T) {
+	dir := t.TempDir()
+	r := fakeRemote(dir)
+
+	path := r.Path("/home/me/project/it's here.txt")
+	if want := filepath.Join(dir, "it's here.txt"); path != want {
+		t.Fatalf("Path = %q, want %q", path, want)
+	}
+	if got := r.Path("src/main.go"); got != filepath.Join(dir, "src/main.go") {
+		t.Errorf("relative Path = %q", got)
+	}
+	if got := r.Path("/etc/hosts"); got != "/etc/hosts" {
+		t.Errorf("Path outside the project = %q", got)
+	}
+
+	if err := r.WriteFile(context.Background(), path, []byte("one\ntwo\n")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := r.ReadFile(context.Background(), path)
+	if err != nil || string(data) != "one\ntwo\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if _, err := r.ReadFile(context.Background(), r.Path("missing.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadFile of a missing file: err = %v", err)
+	}
+
+	out, err := r.Command(context.Background(), "pwd; echo $TOKEN", "", []string{"TOKEN=a b"}).Output()
+	if err != nil || strings.TrimSpace(string(out)) != dir+"\na b" {
+		t.Errorf("Command output = %q, %v", out, err)
+	}
+}
+
+func TestFromSettings(t *testing.T) {
+	if tg, err := FromSettings(config.TargetSettings{}, "/p"); err != nil || tg.Remote() {
+		t.Errorf("default target = %v, %v", tg, err)
+	}
+	if _, err := FromSettings(config.TargetSettings{Type: "ssh", Host: "build"}, "/p"); err == nil {
+		t.Error("ssh without dir was accepted")
+	}
+	tg, err := FromSettings(config.TargetSettings{Type: "devcontainer", Container: "abc"}, "/src/app")
+	if err != nil || tg.Dir() != "/workspaces/app" || tg.Name() != "devcontainer abc" {
+		t.Errorf("devcontainer target = %v, %v", tg, err)
+	}
+	if _, err := FromSettings(config.TargetSettings{Type: "vm"}, "/p"); err == nil {
+		t.Error("unknown type was accepted")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	out, err := exec.Command("bash", "-c", "printf %s "+Quote(`it's $HOME "x"`)).Output()
+	if err != nil || string(out) != `it's $HOME "x"` {
+		t.Errorf("quoted string came back as %q, %v", out, err)
+	}
+}
@@ -0,0 +1,432 @@
+// Package telemetry exports OpenTelemetry traces of model requests, tool
+// calls and MCP calls over OTLP/HTTP with JSON encoding. It is configured by
+// the standard OTEL_* environment variables and does nothing unless an OTLP
+// endpoint or OTEL_TRACES_EXPORTER=otlp is set.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// Defaults of the batch span processor and exporter, as in the OTEL spec
+const (
+	defaultEndpoint = "http://localhost:4318"
+	defaultDelay    = 5 * time.Second
+	defaultTimeout  = 10 * time.Second
+	maxBatch        = 512
+	maxQueue        = 2048
+)
+
+// Kind is the OTLP span kind
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindClient   Kind = 3
+)
+
+// Attr is a span attribute
+type Attr struct {
+	Key   string
+	Value interface{} // string, int, int64, float64 or bool
+}
+
+func String(key, value string) Attr    { return Attr{key, value} }
+func Int(key string, value int) Attr   { return Attr{key, value} }
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+func Float(key string, v float64) Attr { return Attr{key, v} }
+func Duration(key string, d time.Duration) Attr {
+	return Attr{key, d.Seconds()}
+}
+
+// Span is one timed operation. A nil Span, which Start returns when tracing
+// is off, ignores every call.
+type Span struct {
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     Kind
+	start    time.Time
+	end      time.Time
+	attrs    []Attr
+	err      string
+	ended    bool
+	mu       sync.Mutex
+	exporter *exporter
+}
+
+type spanKey struct{}
+
+// Start begins a span as a child of the span in ctx, or of TRACEPARENT when
+// there is none, so john's spans nest under the CI job that ran it. End the
+// span when the operation finishes.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	e := current.Load()
+	if e == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now(), attrs: attrs, spanID: newID(8), exporter: e}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else if e.parentTrace != "" {
+		s.traceID, s.parentID = e.parentTrace, e.parentSpan
+	} else {
+		s.traceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// End finishes the span, marking it failed when err is not nil, and queues
+// it for export
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.mu.Unlock()
+	s.exporter.enqueue(s)
+}
+
+// current is the exporter set up by Init, nil when tracing is off
+var current atomic.Pointer[exporter]
+
+// Enabled reports whether spans are being exported
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// exporter batches ended spans and posts them to the OTLP endpoint
+type exporter struct {
+	endpoint    string
+	headers     map[string]string
+	timeout     time.Duration
+	delay       time.Duration
+	resource    []Attr
+	scope       string
+	version     string
+	parentTrace string // From TRACEPARENT
+	parentSpan  string
+
+	mu      sync.Mutex
+	queue   []*Span
+	lastErr error
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Init starts exporting spans when the environment asks for it and returns
+// a function that flushes the remaining spans and stops. The returned error
+// describes a configuration or export problem worth showing the user; the
+// shutdown function is never nil.
+func Init(service, version string) (shutdown func() error, err error) {
+	noop := func() error { return nil }
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return noop, nil
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	switch exporterName := os.Getenv("OTEL_TRACES_EXPORTER"); exporterName {
+	case "none":
+		return noop, nil
+	case "otlp":
+		if endpoint == "" {
+			endpoint = defaultEndpoint + "/v1/traces"
+		}
+	case "":
+		if endpoint == "" {
+			return noop, nil // Tracing is opt-in
+		}
+	default:
+		return noop, fmt.Errorf("OTEL_TRACES_EXPORTER=%s is not supported; use otlp or none", exporterName)
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return noop, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+
+	var warning error
+	protocol := firstEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL")
+	if protocol != "" && protocol != "http/json" {
+		warning = fmt.Errorf("OTLP protocol %s is not supported; sending http/json, which OTLP/HTTP collectors also accept", protocol)
+	}
+
+	e := &exporter{
+		endpoint: endpoint,
+		headers:  parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS") + "," + os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")),
+		timeout:  envMillis(defaultTimeout, "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"),
+		delay:    envMillis(defaultDelay, "OTEL_BSP_SCHEDULE_DELAY"),
+		scope:    service,
+		version:  version,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	e.resource = resourceAttrs(service, version)
+	e.parentTrace, e.parentSpan = parseTraceparent(os.Getenv("TRACEPARENT"))
+
+	go e.run()
+	current.Store(e)
+	return e.shutdown, warning
+}
+
+func (e *exporter) enqueue(s *Span) {
+	e.mu.Lock()
+	if len(e.queue) < maxQueue {
+		e.queue = append(e.queue, s)
+	}
+	full := len(e.queue) >= maxBatch
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.delay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.wake:
+		}
+		e.flush()
+	}
+}
+
+// flush exports the queued spans, keeping the last error for shutdown
+func (e *exporter) flush() {
+	e.mu.Lock()
+	batch := e.queue
+	e.queue = nil
+	e.mu.Unlock()
+	for len(batch) > 0 {
+		n := min(len(batch), maxBatch)
+		if err := e.export(batch[:n]); err != nil {
+			e.mu.Lock()
+			e.lastErr = err
+			e.mu.Unlock()
+		}
+		batch = batch[n:]
+	}
+}
+
+func (e *exporter) shutdown() error {
+	if !current.CompareAndSwap(e, nil) {
+		return nil
+	}
+	close(e.stop)
+	<-e.stopped
+	e.flush()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lastErr != nil {
+		return fmt.Errorf("exporting traces to %s failed: %w", e.endpoint, e.lastErr)
+	}
+	return nil
+}
+
+func (e *exporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := config.NewHTTPClient(e.timeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// payload builds an OTLP ExportTraceServiceRequest in its JSON encoding
+func (e *exporter) payload(spans []*Span) map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              int(s.kind),
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        encodeAttrs(s.attrs),
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		if s.err != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.err} // STATUS_CODE_ERROR
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": encodeAttrs(e.resource)},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": e.scope, "version": e.version},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func encodeAttrs(attrs []Attr) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]interface{}
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)} // int64 is a string in OTLP JSON
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]interface{}{"key": a.Key, "value": value})
+	}
+	return out
+}
+
+// resourceAttrs describes this process: the service from OTEL_SERVICE_NAME
+// or service, and anything in OTEL_RESOURCE_ATTRIBUTES
+func resourceAttrs(service, version string) []Attr {
+	name := service
+	var attrs []Attr
+	for key, value := range parseHeaders(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		if key == "service.name" {
+			name = value
+			continue
+		}
+		attrs = append(attrs, String(key, value))
+	}
+	if env := os.Getenv("OTEL_SERVICE_NAME"); env != "" {
+		name = env
+	}
+	host, _ := os.Hostname()
+	return append([]Attr{
+		String("service.name", name),
+		String("service.version", version),
+		String("host.name", host),
+		Int("process.pid", os.Getpid()),
+	}, attrs...)
+}
+
+// parseHeaders reads the key=value,key=value lists of OTEL_EXPORTER_OTLP_HEADERS
+// and OTEL_RESOURCE_ATTRIBUTES, whose values may be percent-encoded
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// parseTraceparent reads a W3C traceparent, returning empty IDs if invalid
+func parseTraceparent(s string) (traceID, spanID string) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// envMillis reads a duration in milliseconds from the first of names set
+func envMillis(def time.Duration, names ...string) time.Duration {
+	if v := firstEnv(names...); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return def
+}
+
+func newID(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,123 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string
+				Value map[string]interface{}
+			}
+		}
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string
+				Kind         int
+				Status       *struct {
+					Code    int
+					Message string
+				}
+			}
+		}
+	}
+}
+
+func TestExportSpans(t *testing.T) {
+	var got otlpRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("invalid OTLP JSON: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("OTEL_SERVICE_NAME", "john-ci")
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	shutdown, err := Init("john-code", "0.1.0")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	ctx, turn := Start(context.Background(), "invoke_agent john", KindInternal)
+	_, tool := Start(ctx, "execute_tool Bash", KindInternal, String("gen_ai.tool.name", "Bash"))
+	tool.End(errors.New("exit status 1"))
+	turn.End(nil)
+	if err := shutdown(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if auth != "Bearer token" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+	var service string
+	for _, a := range got.ResourceSpans[0].Resource.Attributes {
+		if a.Key == "service.name" {
+			service, _ = a.Value["stringValue"].(string)
+		}
+	}
+	if service != "john-ci" {
+		t.Errorf("service.name = %q", service)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	toolSpan, turnSpan := spans[0], spans[1]
+	if turnSpan.TraceID != "0af7651916cd43dd8448eb211c80319c" || turnSpan.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("turn span not under TRACEPARENT: %+v", turnSpan)
+	}
+	if toolSpan.TraceID != turnSpan.TraceID || toolSpan.ParentSpanID != turnSpan.SpanID {
+		t.Errorf("tool span not under the turn: %+v", toolSpan)
+	}
+	if toolSpan.Status == nil || toolSpan.Status.Code != 2 || toolSpan.Status.Message != "exit status 1" || turnSpan.Status != nil {
+		t.Errorf("statuses: tool %+v, turn %+v", toolSpan.Status, turnSpan.Status)
+	}
+}
+
+func TestTracingOff(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	shutdown, err := Init("john-code", "0.1.0")
+	if err != nil || Enabled() {
+		t.Fatalf("Init without an endpoint: enabled %v, %v", Enabled(), err)
+	}
+	ctx := context.Background()
+	if got, span := Start(ctx, "noop", KindInternal); got != ctx || span != nil {
+		t.Error("Start made a span with tracing off")
+	}
+	var span *Span
+	span.SetAttributes(Int("n", 1))
+	span.End(nil)
+	if err := shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+	if _, err := Init("john-code", "0.1.0"); err == nil {
+		t.Error("expected an error for an unsupported exporter")
+	}
+}
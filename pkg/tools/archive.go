@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	maxArchiveEntries = 1000
+	maxMemberSize     = 100 << 20 // Larger members are refused rather than filling the disk
+)
+
+// archiveKind returns "zip", "tar", "tar.gz" or "tar.bz2" for an archive
+// Read can open, from its name or contents, or "" for other files
+func archiveKind(name string, data []byte) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"), strings.HasSuffix(lower, ".crate"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		return "tar.bz2"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	for _, ext := range []string{".zip", ".jar", ".war", ".ear", ".whl", ".nupkg", ".vsix", ".aar"} {
+		if strings.HasSuffix(lower, ext) {
+			return "zip"
+		}
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return "zip"
+	}
+	return ""
+}
+
+// archiveEntry is one member of an archive
+type archiveEntry struct {
+	name string
+	size int64
+	dir  bool
+	open func() (io.Reader, error)
+}
+
+// walkArchive calls fn for each member until it returns false
+func walkArchive(kind string, data []byte, fn func(e archiveEntry) bool) error {
+	if kind == "zip" {
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("not a readable zip archive: %w", err)
+		}
+		for _, f := range r.File {
+			entry := archiveEntry{
+				name: f.Name,
+				size: int64(f.UncompressedSize64),
+				dir:  f.FileInfo().IsDir(),
+				open: func() (io.Reader, error) { return f.Open() },
+			}
+			if !fn(entry) {
+				break
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	switch kind {
+	case "tar.gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("not a readable gzip file: %w", err)
+		}
+		r = gz
+	case "tar.bz2":
+		r = bzip2.NewReader(r)
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a readable tar archive: %w", err)
+		}
+		entry := archiveEntry{
+			name: h.Name,
+			size: h.Size,
+			dir:  h.Typeflag == tar.TypeDir,
+			open: func() (io.Reader, error) { return tr, nil },
+		}
+		if h.Typeflag == tar.TypeSymlink {
+			entry.name += " -> " + h.Linkname
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+}
+
+// listArchive describes the members of an archive, one per line
+func listArchive(filePath, kind string, data []byte) (string, error) {
+	var sb strings.Builder
+	count, files := 0, 0
+	var total int64
+	err := walkArchive(kind, data, func(e archiveEntry) bool {
+		count++
+		if !e.dir {
+			files++
+			total += e.size
+		}
+		if count <= maxArchiveEntries {
+			if e.dir {
+				sb.WriteString(fmt.Sprintf("%10s  %s\n", "dir", e.name))
+			} else {
+				sb.WriteString(fmt.Sprintf("%10d  %s\n", e.size, e.name))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	if count > maxArchiveEntries {
+		sb.WriteString(fmt.Sprintf("...[%d more entries]...\n", count-maxArchiveEntries))
+	}
+	header := fmt.Sprintf("%s is a %s archive with %d files (%d bytes uncompressed). Pass member to extract one and read it.\n\n", filePath, kind, files, total)
+	return header + sb.String(), nil
+}
+
+// extractMember copies one member of an archive to a new temp directory and
+// returns its path and contents
+func extractMember(kind string, data []byte, member string) (string, []byte, error) {
+	want := strings.TrimPrefix(path.Clean("/"+member), "/")
+	var content []byte
+	found := false
+	var openErr error
+	err := walkArchive(kind, data, func(e archiveEntry) bool {
+		if e.dir || strings.TrimPrefix(path.Clean("/"+e.name), "/") != want {
+			return true
+		}
+		found = true
+		if e.size > maxMemberSize {
+			openErr = fmt.Errorf("member %s is %d bytes, more than the %d Read extracts", member, e.size, maxMemberSize)
+			return false
+		}
+		r, err := e.open()
+		if err != nil {
+			openErr = err
+			return false
+		}
+		content, openErr = io.ReadAll(io.LimitReader(r, maxMemberSize+1))
+		if openErr == nil && int64(len(content)) > maxMemberSize {
+			openErr = fmt.Errorf("member %s is more than the %d bytes Read extracts", member, maxMemberSize)
+		}
+		return false
+	})
+	if err == nil {
+		err = openErr
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		return "", nil, fmt.Errorf("no member %q in the archive; read the archive without member to list them", member)
+	}
+
+	dir, err := os.MkdirTemp("", "john-archive-")
+	if err != nil {
+		return "", nil, err
+	}
+	out := filepath.Join(dir, path.Base(want))
+	if err := os.WriteFile(out, content, 0644); err != nil {
+		return "", nil, err
+	}
+	return out, content, nil
+}
+
+// looksBinary reports whether data is not text worth numbering by line
+func looksBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
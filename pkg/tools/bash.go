@@ -93,7 +93,7 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]interface{}) (st
 
     // Handle explicit CD commands to update internal state
     // This is a heuristic to simulate persistent CWD
-    if strings.HasPrefix(strings.TrimSpace(cmdStr), "cd ") {
+    if strings.HasPrefix(strings.TrimSpace(cmdStr), "cd ") && !Target.Remote() {
         path := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(cmdStr), "cd "))
         // clean up quotes
         path = strings.Trim(path, "\"'")
@@ -106,9 +106,18 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]interface{}) (st
         }
     }
 
-	// Create command
-	cmd := exec.CommandContext(ctx, "bash", "-c", cmdStr)
-	cmd.Dir = t.cwd
+	// Create command; a remote target runs it in the project's directory there
+	var cmd *exec.Cmd
+	if Target.Remote() {
+		cmd = Target.Command(ctx, cmdStr, "", SessionEnv.Pairs())
+	} else {
+		cmd = exec.CommandContext(ctx, "bash", "-c", cmdStr)
+		cmd.Dir = t.cwd
+		cmd.Env = SessionEnv.Environ(os.Environ())
+	}
+	// Cancelling kills the whole process group, not just bash, so children
+	// holding the output pipe open cannot stall the call
+	setProcessGroup(cmd)
     
     if runInBackground {
         id := GlobalShellManager.Start(cmd)
@@ -0,0 +1,573 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// Limits that keep a single expression from eating memory
+const (
+	maxCalcExponent = 10000
+	maxCalcBits     = 1 << 20
+)
+
+// CalcTool evaluates arithmetic exactly, so sizes, offsets and durations are
+// computed rather than guessed
+type CalcTool struct{}
+
+func (t *CalcTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name: "Calc",
+		Description: `Evaluates an arithmetic expression exactly, with arbitrarily large numbers.
+- Use it instead of doing arithmetic in your head: byte sizes, chunk counts, line and byte offsets, time conversions, percentages
+- Operators: + - * / % ^ (or **), << >>, parentheses; functions: abs, ceil, floor, round, sqrt, log2
+- Numbers: 42, 3.5, 1e6, 1_000_000, 0xff, 0o755, 0b1010
+- Units: B, KB, MB, GB, TB (powers of 1000), KiB, MiB, GiB, TiB (powers of 1024); ns, us, ms, s, min, h, d, w
+- Convert with "to" or "in", e.g. "1.5 GiB / 64 MiB", "3 GiB to MB", "90 min + 45 s to h", "ceil(10_000_000 B / 4 KiB)"`,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"expression": map[string]interface{}{
+					"type":        "string",
+					"description": "The expression to evaluate.",
+				},
+			},
+			"required": []string{"expression"},
+		},
+	}
+}
+
+func (t *CalcTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	expr, ok := args["expression"].(string)
+	if !ok || strings.TrimSpace(expr) == "" {
+		return "", fmt.Errorf("expression required")
+	}
+	return Calc(expr)
+}
+
+// dimension is what a quantity measures
+type dimension int
+
+const (
+	dimNone dimension = iota
+	dimBytes
+	dimSeconds
+)
+
+// calcUnit is a unit a number can carry, in bytes or seconds
+type calcUnit struct {
+	dim    dimension
+	factor *big.Rat
+	name   string // How results in this unit are labelled
+}
+
+var calcUnits = map[string]calcUnit{}
+
+func init() {
+	pow := func(base, exp int64) *big.Rat {
+		return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(base), big.NewInt(exp), nil))
+	}
+	add := func(dim dimension, factor *big.Rat, name string, aliases ...string) {
+		for _, alias := range append(aliases, name) {
+			calcUnits[strings.ToLower(alias)] = calcUnit{dim, factor, name}
+		}
+	}
+	add(dimBytes, pow(1, 0), "B", "byte", "bytes")
+	for i, prefix := range []string{"K", "M", "G", "T", "P"} {
+		add(dimBytes, pow(1000, int64(i+1)), prefix+"B")
+		add(dimBytes, pow(1024, int64(i+1)), prefix+"iB")
+	}
+	add(dimSeconds, big.NewRat(1, 1000000000), "ns")
+	add(dimSeconds, big.NewRat(1, 1000000), "us", "µs")
+	add(dimSeconds, big.NewRat(1, 1000), "ms")
+	add(dimSeconds, pow(1, 0), "s", "sec", "secs", "second", "seconds")
+	add(dimSeconds, pow(60, 1), "min", "mins", "minute", "minutes")
+	add(dimSeconds, pow(3600, 1), "h", "hr", "hrs", "hour", "hours")
+	add(dimSeconds, big.NewRat(86400, 1), "d", "day", "days")
+	add(dimSeconds, big.NewRat(604800, 1), "w", "wk", "week", "weeks")
+}
+
+// quantity is an exact number with a dimension
+type quantity struct {
+	val *big.Rat
+	dim dimension
+}
+
+// Calc evaluates an expression and formats the result
+func Calc(expr string) (string, error) {
+	tokens, err := tokenizeCalc(expr)
+	if err != nil {
+		return "", err
+	}
+	p := &calcParser{tokens: tokens}
+	q, err := p.expr()
+	if err != nil {
+		return "", err
+	}
+
+	var target *calcUnit
+	if tok := p.peek(); tok == "to" || tok == "in" {
+		p.pos++
+		name := p.next()
+		u, ok := calcUnits[strings.ToLower(name)]
+		if !ok {
+			return "", fmt.Errorf("unknown unit %q", name)
+		}
+		if u.dim != q.dim {
+			return "", fmt.Errorf("cannot convert %s to %s", dimName(q.dim), u.name)
+		}
+		target = &u
+	}
+	if p.pos < len(p.tokens) {
+		return "", fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+
+	if target != nil {
+		return formatRat(new(big.Rat).Quo(q.val, target.factor)) + " " + target.name, nil
+	}
+	switch q.dim {
+	case dimBytes:
+		return formatBytesQuantity(q.val), nil
+	case dimSeconds:
+		return formatSecondsQuantity(q.val), nil
+	}
+	out := formatRat(q.val)
+	if q.val.IsInt() && q.val.Sign() >= 0 && q.val.Num().Cmp(big.NewInt(256)) >= 0 {
+		out += fmt.Sprintf(" (0x%x)", q.val.Num())
+	}
+	return out, nil
+}
+
+// tokenizeCalc splits an expression into numbers, words and operators
+func tokenizeCalc(expr string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			if r == '0' && i+1 < len(runes) && strings.ContainsRune("xXoObB", runes[i+1]) {
+				i += 2
+				for i < len(runes) && (isHexDigit(runes[i]) || runes[i] == '_') {
+					i++
+				}
+			} else {
+				for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+					i++
+				}
+				// An exponent, but not a unit starting with e
+				if i+1 < len(runes) && (runes[i] == 'e' || runes[i] == 'E') &&
+					(unicode.IsDigit(runes[i+1]) || (strings.ContainsRune("+-", runes[i+1]) && i+2 < len(runes) && unicode.IsDigit(runes[i+2]))) {
+					i += 2
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			if two == "**" || two == "<<" || two == ">>" {
+				tokens = append(tokens, two)
+				i += 2
+			} else if strings.ContainsRune("+-*/%^()", r) {
+				tokens = append(tokens, string(r))
+				i++
+			} else {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func isHexDigit(r rune) bool {
+	return unicode.IsDigit(r) || strings.ContainsRune("abcdefABCDEF", r)
+}
+
+type calcParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *calcParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *calcParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+// expr parses shifts, the loosest binding operators
+func (p *calcParser) expr() (quantity, error) {
+	left, err := p.sum()
+	if err != nil {
+		return left, err
+	}
+	for op := p.peek(); op == "<<" || op == ">>"; op = p.peek() {
+		p.pos++
+		right, err := p.sum()
+		if err != nil {
+			return left, err
+		}
+		if left.dim != dimNone || right.dim != dimNone || !left.val.IsInt() || !right.val.IsInt() {
+			return left, fmt.Errorf("%s needs whole numbers without units", op)
+		}
+		n := right.val.Num()
+		if n.Sign() < 0 || n.Cmp(big.NewInt(maxCalcExponent)) > 0 {
+			return left, fmt.Errorf("shift amount must be between 0 and %d", maxCalcExponent)
+		}
+		v := new(big.Int)
+		if op == "<<" {
+			v.Lsh(left.val.Num(), uint(n.Uint64()))
+		} else {
+			v.Rsh(left.val.Num(), uint(n.Uint64()))
+		}
+		left = quantity{new(big.Rat).SetInt(v), dimNone}
+	}
+	return left, nil
+}
+
+func (p *calcParser) sum() (quantity, error) {
+	left, err := p.product()
+	if err != nil {
+		return left, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		right, err := p.product()
+		if err != nil {
+			return left, err
+		}
+		if left.dim != right.dim {
+			return left, fmt.Errorf("cannot %s %s and %s", map[string]string{"+": "add", "-": "subtract"}[op], dimName(left.dim), dimName(right.dim))
+		}
+		v := new(big.Rat)
+		if op == "+" {
+			v.Add(left.val, right.val)
+		} else {
+			v.Sub(left.val, right.val)
+		}
+		left = quantity{v, left.dim}
+	}
+	return left, nil
+}
+
+func (p *calcParser) product() (quantity, error) {
+	left, err := p.unary()
+	if err != nil {
+		return left, err
+	}
+	for op := p.peek(); op == "*" || op == "/" || op == "%"; op = p.peek() {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return left, err
+		}
+		switch op {
+		case "*":
+			if left.dim != dimNone && right.dim != dimNone {
+				return left, fmt.Errorf("cannot multiply %s by %s", dimName(left.dim), dimName(right.dim))
+			}
+			left = quantity{new(big.Rat).Mul(left.val, right.val), max(left.dim, right.dim)}
+		case "/", "%":
+			if right.val.Sign() == 0 {
+				return left, fmt.Errorf("division by zero")
+			}
+			if right.dim != dimNone && right.dim != left.dim {
+				return left, fmt.Errorf("cannot divide %s by %s", dimName(left.dim), dimName(right.dim))
+			}
+			if op == "/" {
+				dim := left.dim
+				if right.dim == left.dim {
+					dim = dimNone
+				}
+				left = quantity{new(big.Rat).Quo(left.val, right.val), dim}
+				continue
+			}
+			// The remainder of a floored division keeps the left side's unit
+			quo := new(big.Rat).Quo(left.val, right.val)
+			floor := new(big.Rat).SetInt(floorRat(quo))
+			left = quantity{new(big.Rat).Sub(left.val, floor.Mul(floor, right.val)), left.dim}
+		}
+	}
+	return left, nil
+}
+
+func (p *calcParser) unary() (quantity, error) {
+	switch p.peek() {
+	case "-":
+		p.pos++
+		q, err := p.unary()
+		return quantity{new(big.Rat).Neg(q.val), q.dim}, err
+	case "+":
+		p.pos++
+		return p.unary()
+	}
+	return p.withUnit()
+}
+
+// withUnit parses a power followed by an optional unit, so 2^30 B is a size
+func (p *calcParser) withUnit() (quantity, error) {
+	q, err := p.power()
+	if err != nil {
+		return q, err
+	}
+	if u, ok := calcUnits[strings.ToLower(p.peek())]; ok {
+		if q.dim != dimNone {
+			return q, fmt.Errorf("%q already has a unit", p.peek())
+		}
+		p.pos++
+		q = quantity{new(big.Rat).Mul(q.val, u.factor), u.dim}
+	}
+	return q, nil
+}
+
+// exponent parses the signed right-hand side of ^
+func (p *calcParser) exponent() (quantity, error) {
+	switch p.peek() {
+	case "-":
+		p.pos++
+		q, err := p.exponent()
+		return quantity{new(big.Rat).Neg(q.val), q.dim}, err
+	case "+":
+		p.pos++
+		return p.exponent()
+	}
+	return p.power()
+}
+
+func (p *calcParser) power() (quantity, error) {
+	base, err := p.primary()
+	if err != nil {
+		return base, err
+	}
+	if op := p.peek(); op != "^" && op != "**" {
+		return base, nil
+	}
+	p.pos++
+	exp, err := p.exponent() // Right associative, and 2^-1 works
+	if err != nil {
+		return base, err
+	}
+	if base.dim != dimNone || exp.dim != dimNone {
+		return base, fmt.Errorf("powers need numbers without units")
+	}
+	if !exp.val.IsInt() {
+		return base, fmt.Errorf("exponents must be whole numbers; use sqrt for square roots")
+	}
+	n := exp.val.Num()
+	if n.CmpAbs(big.NewInt(maxCalcExponent)) > 0 {
+		return base, fmt.Errorf("exponent too large (limit %d)", maxCalcExponent)
+	}
+	e := n.Int64()
+	if e < 0 && base.val.Sign() == 0 {
+		return base, fmt.Errorf("division by zero")
+	}
+	abs := e
+	if abs < 0 {
+		abs = -abs
+	}
+	if bits := int64(max(base.val.Num().BitLen(), base.val.Denom().BitLen())) * abs; bits > maxCalcBits {
+		return base, fmt.Errorf("result too large")
+	}
+	num := new(big.Int).Exp(base.val.Num(), big.NewInt(abs), nil)
+	den := new(big.Int).Exp(base.val.Denom(), big.NewInt(abs), nil)
+	if e < 0 {
+		num, den = den, num
+	}
+	return quantity{new(big.Rat).SetFrac(num, den), dimNone}, nil
+}
+
+func (p *calcParser) primary() (quantity, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return quantity{}, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		q, err := p.expr()
+		if err != nil {
+			return q, err
+		}
+		if p.next() != ")" {
+			return q, fmt.Errorf("missing )")
+		}
+		return q, nil
+	case unicode.IsDigit([]rune(tok)[0]) || tok[0] == '.':
+		v, err := parseCalcNumber(tok)
+		return quantity{v, dimNone}, err
+	case unicode.IsLetter([]rune(tok)[0]) && p.peek() == "(":
+		p.pos++
+		arg, err := p.expr()
+		if err != nil {
+			return arg, err
+		}
+		if p.next() != ")" {
+			return arg, fmt.Errorf("missing ) after %s(", tok)
+		}
+		return applyCalcFunc(strings.ToLower(tok), arg)
+	}
+	return quantity{}, fmt.Errorf("unexpected %q", tok)
+}
+
+func parseCalcNumber(tok string) (*big.Rat, error) {
+	tok = strings.ReplaceAll(tok, "_", "")
+	if len(tok) > 2 && tok[0] == '0' && strings.ContainsRune("xXoObB", rune(tok[1])) {
+		n, ok := new(big.Int).SetString(tok, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q", tok)
+		}
+		return new(big.Rat).SetInt(n), nil
+	}
+	v, ok := new(big.Rat).SetString(tok)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", tok)
+	}
+	return v, nil
+}
+
+func applyCalcFunc(name string, q quantity) (quantity, error) {
+	switch name {
+	case "abs":
+		return quantity{new(big.Rat).Abs(q.val), q.dim}, nil
+	case "floor":
+		return quantity{new(big.Rat).SetInt(floorRat(q.val)), q.dim}, nil
+	case "ceil":
+		return quantity{new(big.Rat).SetInt(new(big.Int).Neg(floorRat(new(big.Rat).Neg(q.val)))), q.dim}, nil
+	case "round":
+		half := new(big.Rat).Add(new(big.Rat).Abs(q.val), big.NewRat(1, 2))
+		v := new(big.Rat).SetInt(floorRat(half))
+		if q.val.Sign() < 0 {
+			v.Neg(v)
+		}
+		return quantity{v, q.dim}, nil
+	case "sqrt", "log2":
+		if q.dim != dimNone {
+			return q, fmt.Errorf("%s needs a number without units", name)
+		}
+		if q.val.Sign() < 0 || (name == "log2" && q.val.Sign() == 0) {
+			return q, fmt.Errorf("%s of %s is undefined", name, formatRat(q.val))
+		}
+		if name == "log2" {
+			return quantity{log2Rat(q.val), dimNone}, nil
+		}
+		f := new(big.Float).SetPrec(256).SetRat(q.val)
+		r, _ := f.Sqrt(f).Rat(nil)
+		return quantity{r, dimNone}, nil
+	}
+	return q, fmt.Errorf("unknown function %s", name)
+}
+
+// floorRat rounds toward negative infinity. Euclidean division, which
+// DivMod does, floors when the divisor is positive, as a Rat's denominator is.
+func floorRat(r *big.Rat) *big.Int {
+	q, _ := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	return q
+}
+
+// log2Rat is exact for powers of two and accurate to a float64 otherwise
+func log2Rat(r *big.Rat) *big.Rat {
+	if r.IsInt() {
+		n := r.Num()
+		if new(big.Int).Lsh(big.NewInt(1), uint(n.BitLen()-1)).Cmp(n) == 0 {
+			return new(big.Rat).SetInt64(int64(n.BitLen() - 1))
+		}
+	}
+	// r = mant × 2^exp with mant in [0.5, 1), so huge values stay in range
+	mant := new(big.Float)
+	exp := new(big.Float).SetPrec(256).SetRat(r).MantExp(mant)
+	m, _ := mant.Float64()
+	return new(big.Rat).SetFloat64(float64(exp) + math.Log2(m))
+}
+
+func dimName(d dimension) string {
+	switch d {
+	case dimBytes:
+		return "a size"
+	case dimSeconds:
+		return "a duration"
+	}
+	return "a plain number"
+}
+
+// formatRat prints an integer exactly, and a fraction as a decimal marked ≈
+// when it does not terminate within 15 places
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(15), "0")
+	s = strings.TrimSuffix(s, ".")
+	if back, ok := new(big.Rat).SetString(s); !ok || back.Cmp(r) != 0 {
+		return "≈" + s
+	}
+	return s
+}
+
+func formatBytesQuantity(v *big.Rat) string {
+	out := formatRat(v) + " B"
+	abs := new(big.Rat).Abs(v)
+	for i := len(binaryUnitNames) - 1; i >= 0; i-- {
+		factor := calcUnits[strings.ToLower(binaryUnitNames[i])].factor
+		if abs.Cmp(factor) >= 0 {
+			return out + " (" + roundRat(new(big.Rat).Quo(v, factor), 3) + " " + binaryUnitNames[i] + ")"
+		}
+	}
+	return out
+}
+
+var binaryUnitNames = []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+func formatSecondsQuantity(v *big.Rat) string {
+	out := formatRat(v) + " s"
+	abs := new(big.Rat).Abs(v)
+	if abs.Cmp(big.NewRat(60, 1)) < 0 {
+		return out
+	}
+	total := floorRat(abs).Int64()
+	var parts []string
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}} {
+		if n := total / unit.size; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, unit.name))
+			total %= unit.size
+		}
+	}
+	sign := ""
+	if v.Sign() < 0 {
+		sign = "-"
+	}
+	return out + " (" + sign + strings.Join(parts, " ") + ")"
+}
+
+// roundRat prints r with at most places decimals
+func roundRat(r *big.Rat, places int) string {
+	s := r.FloatString(places)
+	if strings.Contains(s, ".") {
+		s = strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCalc(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"2 ^ 3 ^ 2", "512 (0x200)"},
+		{"2 ** -2", "0.25"},
+		{"-3 ^ 2", "-9"},
+		{"7 / 2", "3.5"},
+		{"1 / 3", "≈0.333333333333333"},
+		{"-7 % 3", "2"},
+		{"2 ^ 100", "1267650600228229401496703205376 (0x10000000000000000000000000)"},
+		{"0xff + 0b1 + 0o7 + 1_000", "1263 (0x4ef)"},
+		{"1e3 + .5", "1000.5"},
+		{"1 << 20", "1048576 (0x100000)"},
+		{"0x1000 >> 4", "256 (0x100)"},
+		{"ceil(10_000_000 B / 4 KiB)", "2442 (0x98a)"},
+		{"floor(-2.5)", "-3"},
+		{"round(2.5) + round(-2.5)", "0"},
+		{"sqrt(16)", "4"},
+		{"log2(1 GiB / 1 B)", "30"},
+		{"1.5 GiB / 64 MiB", "24"},
+		{"3 GiB to MB", "3221.225472 MB"},
+		{"2^30 B", "1073741824 B (1 GiB)"},
+		{"1500 B", "1500 B (1.465 KiB)"},
+		{"4 * 512 B", "2048 B (2 KiB)"},
+		{"90 min + 45 s to h", "1.5125 h"},
+		{"90 min + 45 s", "5445 s (1h 30m 45s)"},
+		{"250 ms * 8", "2 s"},
+		{"1 day in min", "1440 min"},
+	}
+	for _, tt := range tests {
+		got, err := Calc(tt.expr)
+		if err != nil {
+			t.Errorf("Calc(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Calc(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCalcErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 GiB + 1 s", "cannot add a size and a duration"},
+		{"1 KiB * 2 KiB", "cannot multiply"},
+		{"3 h to MB", "cannot convert a duration to MB"},
+		{"2 ^ 0.5", "whole numbers"},
+		{"10 ^ 100000", "exponent too large"},
+		{"1 +", "unexpected end"},
+		{"(1 + 2", "missing )"},
+		{"1 parsec", "unexpected"},
+		{"2 $ 3", "unexpected character"},
+		{"cbrt(8)", "unknown function"},
+		{"1 to furlongs", "unknown unit"},
+	}
+	for _, tt := range tests {
+		_, err := Calc(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Calc(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestCalcTool(t *testing.T) {
+	out, err := (&CalcTool{}).Execute(context.Background(), map[string]interface{}{"expression": "64 KiB / 4 KiB"})
+	if err != nil || out != "16" {
+		t.Errorf("Execute = %q, %v", out, err)
+	}
+	if _, err := (&CalcTool{}).Execute(context.Background(), map[string]interface{}{}); err == nil {
+		t.Error("expected an error without an expression")
+	}
+}
@@ -0,0 +1,597 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+// maxVulnDetails caps the advisories DepsAudit looks up for summaries and
+// fixed versions; the rest are listed by ID only
+const maxVulnDetails = 30
+
+// DepsAuditTool checks a project's dependencies against their registries
+// and OSV: which are behind, deprecated, vulnerable or not pinned
+type DepsAuditTool struct {
+	client  *http.Client
+	goProxy string
+	npmURL  string
+	pypiURL string
+	osvURL  string
+}
+
+func NewDepsAuditTool() *DepsAuditTool {
+	return &DepsAuditTool{
+		client:  config.NewHTTPClient(20 * time.Second),
+		goProxy: "https://proxy.golang.org",
+		npmURL:  "https://registry.npmjs.org",
+		pypiURL: "https://pypi.org/pypi",
+		osvURL:  "https://api.osv.dev/v1",
+	}
+}
+
+// NewDepsAuditToolWithEndpoints creates a DepsAuditTool that queries mirrors
+// or test servers instead of the public registries and OSV
+func NewDepsAuditToolWithEndpoints(goProxy, npmURL, pypiURL, osvURL string) *DepsAuditTool {
+	tool := NewDepsAuditTool()
+	tool.goProxy, tool.npmURL, tool.pypiURL, tool.osvURL = goProxy, npmURL, pypiURL, osvURL
+	return tool
+}
+
+func (t *DepsAuditTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name: "DepsAudit",
+		Description: `Audits a project's dependencies from go.mod, package.json and requirements.txt.
+- Reports, per dependency, the version in use, the latest release, whether it is a major upgrade, deprecation notices, unpinned versions, and known vulnerabilities from OSV with the versions that fix them
+- Use it first for "update dependencies" or security tasks, then change the manifests and run the build and tests
+- Read-only: it changes nothing`,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory holding the manifests. Defaults to the working directory.",
+				},
+				"include_indirect": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also audit Go dependencies marked // indirect. Default false.",
+				},
+			},
+		},
+	}
+}
+
+// dependency is one entry of the DepsAudit report
+type dependency struct {
+	Ecosystem    string          `json:"ecosystem"`
+	Name         string          `json:"name"`
+	Manifest     string          `json:"manifest"`
+	Spec         string          `json:"spec,omitempty"`    // As written in the manifest, when it is not a plain version
+	Current      string          `json:"current,omitempty"` // Version in use, or the lowest the spec allows
+	Latest       string          `json:"latest,omitempty"`
+	Outdated     bool            `json:"outdated,omitempty"`
+	Major        bool            `json:"majorUpgrade,omitempty"`
+	Unpinned     bool            `json:"unpinned,omitempty"`
+	Deprecated   string          `json:"deprecated,omitempty"`
+	Vulns        []vulnerability `json:"vulnerabilities,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Indirect     bool            `json:"indirect,omitempty"`
+	osvName      string
+	osvEcosystem string
+}
+
+type vulnerability struct {
+	ID      string   `json:"id"`
+	Summary string   `json:"summary,omitempty"`
+	Fixed   []string `json:"fixedIn,omitempty"`
+}
+
+func (t *DepsAuditTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	dir, _ := args["path"].(string)
+	if dir == "" {
+		dir = "."
+	}
+	indirect, _ := args["include_indirect"].(bool)
+
+	var deps []*dependency
+	var manifests []string
+	for _, parse := range []struct {
+		file  string
+		parse func(data []byte, manifest string, indirect bool) ([]*dependency, error)
+	}{
+		{"go.mod", parseGoMod},
+		{"package.json", parsePackageJSON},
+		{"requirements.txt", parseRequirements},
+	} {
+		path := filepath.Join(dir, parse.file)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		found, err := parse.parse(data, path, indirect)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		manifests = append(manifests, path)
+		deps = append(deps, found...)
+	}
+	if len(manifests) == 0 {
+		return "", fmt.Errorf("no go.mod, package.json or requirements.txt in %s", dir)
+	}
+
+	t.lookupLatest(ctx, deps)
+	t.lookupVulns(ctx, deps)
+
+	var outdated, major, deprecated, vulnerable, unpinned, failed int
+	for _, d := range deps {
+		if d.Outdated {
+			outdated++
+		}
+		if d.Major {
+			major++
+		}
+		if d.Deprecated != "" {
+			deprecated++
+		}
+		if len(d.Vulns) > 0 {
+			vulnerable++
+		}
+		if d.Unpinned {
+			unpinned++
+		}
+		if d.Error != "" {
+			failed++
+		}
+	}
+	// Most urgent first: vulnerable, deprecated, major upgrades, then the rest
+	rank := func(d *dependency) int {
+		switch {
+		case len(d.Vulns) > 0:
+			return 0
+		case d.Deprecated != "":
+			return 1
+		case d.Major:
+			return 2
+		case d.Outdated:
+			return 3
+		case d.Unpinned || d.Error != "":
+			return 4
+		}
+		return 5
+	}
+	sort.SliceStable(deps, func(i, j int) bool { return rank(deps[i]) < rank(deps[j]) })
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Audited %s in %s: %d with known vulnerabilities, %d deprecated, %d outdated (%d major), %d unpinned",
+		pluralize(len(deps), "dependency", "dependencies"), strings.Join(manifests, ", "), vulnerable, deprecated, outdated, major, unpinned))
+	if failed > 0 {
+		sb.WriteString(fmt.Sprintf(", %d could not be checked", failed))
+	}
+	sb.WriteString(".\n")
+	for _, d := range deps {
+		line, _ := json.Marshal(d)
+		sb.Write(line)
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func pluralize(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// parseGoMod reads the require directives of a go.mod
+func parseGoMod(data []byte, manifest string, indirect bool) ([]*dependency, error) {
+	var deps []*dependency
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		isIndirect := strings.Contains(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inBlock:
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (isIndirect && !indirect) {
+			continue
+		}
+		deps = append(deps, &dependency{Ecosystem: "go", Name: fields[0], Current: fields[1], Manifest: manifest, Indirect: isIndirect,
+			osvName: fields[0], osvEcosystem: "Go"})
+	}
+	return deps, scanner.Err()
+}
+
+// npmVersion is the lowest version an npm range allows, for ranges simple
+// enough to have one: 1.2.3, ^1.2.3, ~1.2.3, >=1.2.3
+var npmVersion = regexp.MustCompile(`^(\^|~|>=|=)?v?(\d+\.\d+\.\d+[-+.\w]*)$`)
+
+// parsePackageJSON reads dependencies and devDependencies
+func parsePackageJSON(data []byte, manifest string, _ bool) ([]*dependency, error) {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	var deps []*dependency
+	for _, group := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		names := make([]string, 0, len(group))
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			spec := strings.TrimSpace(group[name])
+			d := &dependency{Ecosystem: "npm", Name: name, Manifest: manifest, osvName: name, osvEcosystem: "npm"}
+			m := npmVersion.FindStringSubmatch(spec)
+			switch {
+			case m == nil:
+				d.Spec, d.Unpinned = spec, true
+			case m[1] == "" || m[1] == "=":
+				d.Current = m[2]
+			default:
+				d.Spec, d.Current, d.Unpinned = spec, m[2], true
+			}
+			deps = append(deps, d)
+		}
+	}
+	return deps, nil
+}
+
+// requirementLine splits a requirements.txt line into the name and its
+// version specifier
+var requirementLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*(.*)$`)
+
+// parseRequirements reads a requirements.txt. Options, includes, URLs and
+// environment markers are skipped.
+func parseRequirements(data []byte, manifest string, _ bool) ([]*dependency, error) {
+	var deps []*dependency
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+			continue
+		}
+		m := requirementLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d := &dependency{Ecosystem: "pypi", Name: m[1], Manifest: manifest, osvName: m[1], osvEcosystem: "PyPI"}
+		spec := strings.ReplaceAll(m[3], " ", "")
+		switch {
+		case strings.HasPrefix(spec, "==") && !strings.ContainsAny(spec[2:], ",*"):
+			d.Current = spec[2:]
+		case strings.HasPrefix(spec, ">="):
+			d.Spec, d.Unpinned = spec, true
+			d.Current, _, _ = strings.Cut(spec[2:], ",")
+		default:
+			d.Spec, d.Unpinned = spec, true
+		}
+		if d.Spec == "" && d.Unpinned {
+			d.Spec = "(any version)"
+		}
+		deps = append(deps, d)
+	}
+	return deps, scanner.Err()
+}
+
+// lookupLatest asks each dependency's registry for its latest version and
+// deprecation notice, a few at a time
+func (t *DepsAuditTool) lookupLatest(ctx context.Context, deps []*dependency) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for _, d := range deps {
+		wg.Add(1)
+		go func(d *dependency) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var err error
+			switch d.Ecosystem {
+			case "go":
+				err = t.latestGo(ctx, d)
+			case "npm":
+				err = t.latestNpm(ctx, d)
+			case "pypi":
+				err = t.latestPyPI(ctx, d)
+			}
+			if err != nil {
+				d.Error = err.Error()
+				return
+			}
+			if d.Current != "" && d.Latest != "" && compareVersions(d.Current, d.Latest) < 0 {
+				d.Outdated = true
+				d.Major = majorVersion(d.Current) != majorVersion(d.Latest)
+			}
+		}(d)
+	}
+	wg.Wait()
+}
+
+// escapeModulePath writes a module path for the Go module proxy, which
+// spells capital letters as ! and the lower-case letter
+func escapeModulePath(path string) string {
+	var sb strings.Builder
+	for _, r := range path {
+		if r >= 'A' && r <= 'Z' {
+			sb.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (t *DepsAuditTool) latestGo(ctx context.Context, d *dependency) error {
+	base := t.goProxy + "/" + escapeModulePath(d.Name) + "/@"
+	body, err := httpGet(ctx, t.client, base+"latest", "")
+	if err != nil {
+		return err
+	}
+	var info struct{ Version string }
+	if err := json.Unmarshal(body, &info); err != nil {
+		return fmt.Errorf("unexpected answer from the module proxy: %w", err)
+	}
+	d.Latest = info.Version
+	// A module is deprecated by a comment on the module line of its latest go.mod
+	if mod, err := httpGet(ctx, t.client, base+"v/"+info.Version+".mod", ""); err == nil {
+		for _, line := range strings.Split(string(mod), "\n") {
+			if i := strings.Index(line, "Deprecated:"); i >= 0 {
+				d.Deprecated = strings.TrimSpace(line[i+len("Deprecated:"):])
+				break
+			}
+			if strings.HasPrefix(strings.TrimSpace(line), "module ") {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (t *DepsAuditTool) latestNpm(ctx context.Context, d *dependency) error {
+	// The abbreviated document has what is needed without every README
+	body, err := httpGet(ctx, t.client, t.npmURL+"/"+url.PathEscape(d.Name), "application/vnd.npm.install-v1+json")
+	if err != nil {
+		return err
+	}
+	var pkg struct {
+		DistTags map[string]string `json:"dist-tags"`
+		Versions map[string]struct {
+			Deprecated string `json:"deprecated"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(body, &pkg); err != nil {
+		return fmt.Errorf("unexpected answer from the npm registry: %w", err)
+	}
+	d.Latest = pkg.DistTags["latest"]
+	version := d.Current
+	if version == "" {
+		version = d.Latest
+	}
+	d.Deprecated = pkg.Versions[version].Deprecated
+	return nil
+}
+
+func (t *DepsAuditTool) latestPyPI(ctx context.Context, d *dependency) error {
+	body, err := httpGet(ctx, t.client, t.pypiURL+"/"+url.PathEscape(d.Name)+"/json", "")
+	if err != nil {
+		return err
+	}
+	var project struct {
+		Info struct {
+			Version     string   `json:"version"`
+			Classifiers []string `json:"classifiers"`
+		} `json:"info"`
+		Releases map[string][]struct {
+			Yanked       bool   `json:"yanked"`
+			YankedReason string `json:"yanked_reason"`
+		} `json:"releases"`
+	}
+	if err := json.Unmarshal(body, &project); err != nil {
+		return fmt.Errorf("unexpected answer from PyPI: %w", err)
+	}
+	d.Latest = project.Info.Version
+	for _, c := range project.Info.Classifiers {
+		if c == "Development Status :: 7 - Inactive" {
+			d.Deprecated = "marked inactive on PyPI"
+		}
+	}
+	if files := project.Releases[d.Current]; len(files) > 0 && files[0].Yanked {
+		d.Deprecated = strings.TrimSpace("version " + d.Current + " was yanked " + files[0].YankedReason)
+	}
+	return nil
+}
+
+// lookupVulns asks OSV about every dependency with a known version, in one
+// batch, then fetches the summaries and fixed versions of what it found
+func (t *DepsAuditTool) lookupVulns(ctx context.Context, deps []*dependency) {
+	type query struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+	var queries []query
+	var queried []*dependency
+	for _, d := range deps {
+		if d.Current == "" {
+			continue
+		}
+		var q query
+		q.Package.Name, q.Package.Ecosystem, q.Version = d.osvName, d.osvEcosystem, d.Current
+		queries = append(queries, q)
+		queried = append(queried, d)
+	}
+	if len(queries) == 0 {
+		return
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"queries": queries})
+	req, err := http.NewRequestWithContext(ctx, "POST", t.osvURL+"/querybatch", bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var batch struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+		} `json:"results"`
+	}
+	resp, err := t.client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+	}
+	if err != nil || len(batch.Results) != len(queried) {
+		for _, d := range queried {
+			if d.Error == "" {
+				d.Error = "OSV vulnerability lookup failed"
+			}
+		}
+		return
+	}
+
+	details := make(map[string]*vulnerability)
+	for i, result := range batch.Results {
+		d := queried[i]
+		for _, v := range result.Vulns {
+			if details[v.ID] == nil && len(details) < maxVulnDetails {
+				details[v.ID] = t.vulnDetails(ctx, v.ID, d)
+			}
+			if full := details[v.ID]; full != nil {
+				d.Vulns = append(d.Vulns, *full)
+			} else {
+				d.Vulns = append(d.Vulns, vulnerability{ID: v.ID})
+			}
+		}
+	}
+}
+
+// vulnDetails reads an OSV advisory's summary and the versions of d's
+// package that fix it
+func (t *DepsAuditTool) vulnDetails(ctx context.Context, id string, d *dependency) *vulnerability {
+	v := &vulnerability{ID: id}
+	body, err := httpGet(ctx, t.client, t.osvURL+"/vulns/"+url.PathEscape(id), "")
+	if err != nil {
+		return v
+	}
+	var advisory struct {
+		Summary  string `json:"summary"`
+		Affected []struct {
+			Package struct {
+				Name string `json:"name"`
+			} `json:"package"`
+			Ranges []struct {
+				Events []map[string]string `json:"events"`
+			} `json:"ranges"`
+		} `json:"affected"`
+	}
+	if json.Unmarshal(body, &advisory) != nil {
+		return v
+	}
+	v.Summary = advisory.Summary
+	for _, affected := range advisory.Affected {
+		if affected.Package.Name != d.osvName {
+			continue
+		}
+		for _, r := range affected.Ranges {
+			for _, e := range r.Events {
+				if fixed := e["fixed"]; fixed != "" {
+					v.Fixed = append(v.Fixed, fixed)
+				}
+			}
+		}
+	}
+	return v
+}
+
+// versionParts splits a version into its numeric release parts, ignoring a
+// leading v, pre-release and build suffixes
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareVersions orders two versions by their release parts
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// majorVersion is the part of a version whose change breaks callers: the
+// first, or for 0.x versions the second
+func majorVersion(v string) string {
+	parts := versionParts(v)
+	switch {
+	case len(parts) == 0:
+		return v
+	case parts[0] == 0 && len(parts) > 1:
+		return fmt.Sprintf("0.%d", parts[1])
+	}
+	return strconv.Itoa(parts[0])
+}
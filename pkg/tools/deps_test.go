@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDepsAuditReportsUpdatesDeprecationsAndVulnerabilities(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte(`module example.com/app
+
+go 1.24
+
+require github.com/Acme/lib v1.2.0
+
+require (
+	golang.org/x/text v0.14.0
+	golang.org/x/sys v0.1.0 // indirect
+)
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"dependencies":{"left-pad":"^1.1.0"},"devDependencies":{"jest":"*"}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("requests==2.31.0  # http\n-r other.txt\nflask\n"), 0644)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/go/github.com/!acme/lib/@latest":
+			w.Write([]byte(`{"Version":"v2.0.0"}`))
+		case "/go/github.com/!acme/lib/@v/v2.0.0.mod":
+			w.Write([]byte("// Deprecated: use example.com/newlib.\nmodule github.com/Acme/lib\n"))
+		case "/go/golang.org/x/text/@latest":
+			w.Write([]byte(`{"Version":"v0.14.0"}`))
+		case "/npm/left-pad":
+			w.Write([]byte(`{"dist-tags":{"latest":"1.3.0"},"versions":{"1.1.0":{"deprecated":"use String.prototype.padStart()"}}}`))
+		case "/npm/jest":
+			w.Write([]byte(`{"dist-tags":{"latest":"29.7.0"}}`))
+		case "/pypi/requests/json":
+			w.Write([]byte(`{"info":{"version":"2.32.3"}}`))
+		case "/osv/querybatch":
+			var batch struct {
+				Queries []struct {
+					Package struct{ Name string }
+				}
+			}
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &batch)
+			var results []map[string]interface{}
+			for _, q := range batch.Queries {
+				if q.Package.Name == "requests" {
+					results = append(results, map[string]interface{}{"vulns": []map[string]string{{"id": "GHSA-9wx4"}}})
+				} else {
+					results = append(results, map[string]interface{}{})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+		case "/osv/vulns/GHSA-9wx4":
+			w.Write([]byte(`{"summary":"Proxy-Authorization header leaked","affected":[{"package":{"name":"requests"},"ranges":[{"events":[{"introduced":"0"},{"fixed":"2.32.0"}]}]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tool := NewDepsAuditToolWithEndpoints(server.URL+"/go", server.URL+"/npm", server.URL+"/pypi", server.URL+"/osv")
+	out, err := tool.Execute(context.Background(), map[string]interface{}{"path": dir})
+	if err != nil {
+		t.Fatalf("DepsAudit: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if !strings.HasPrefix(lines[0], "Audited 6 dependencies") || !strings.Contains(lines[0], "1 with known vulnerabilities, 2 deprecated, 3 outdated (1 major), 3 unpinned, 1 could not be checked") {
+		t.Errorf("summary: %s", lines[0])
+	}
+	deps := make(map[string]dependency)
+	var order []string
+	for _, line := range lines[1:] {
+		var d dependency
+		if err := json.Unmarshal([]byte(line), &d); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		deps[d.Name] = d
+		order = append(order, d.Name)
+	}
+	if order[0] != "requests" {
+		t.Errorf("vulnerable dependency not first: %v", order)
+	}
+	if v := deps["requests"].Vulns; len(v) != 1 || v[0].Summary == "" || len(v[0].Fixed) != 1 || v[0].Fixed[0] != "2.32.0" {
+		t.Errorf("requests vulnerabilities: %+v", v)
+	}
+	if d := deps["github.com/Acme/lib"]; !d.Major || d.Latest != "v2.0.0" || d.Deprecated != "use example.com/newlib." {
+		t.Errorf("go module: %+v", d)
+	}
+	if d := deps["golang.org/x/text"]; d.Outdated {
+		t.Errorf("up-to-date module reported outdated: %+v", d)
+	}
+	if _, ok := deps["golang.org/x/sys"]; ok {
+		t.Error("indirect dependency audited without include_indirect")
+	}
+	if d := deps["left-pad"]; !d.Unpinned || d.Current != "1.1.0" || d.Major || !d.Outdated || d.Deprecated == "" {
+		t.Errorf("left-pad: %+v", d)
+	}
+	if d := deps["jest"]; !d.Unpinned || d.Current != "" || d.Latest != "29.7.0" {
+		t.Errorf("jest: %+v", d)
+	}
+	if d := deps["flask"]; !d.Unpinned || d.Error == "" {
+		t.Errorf("flask: %+v", d)
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"path": t.TempDir()}); err == nil {
+		t.Error("no error for a directory without manifests")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.0", "v1.10.0", -1},
+		{"2.32.3", "2.32.3", 0},
+		{"v0.14.0-rc.1", "v0.13.9", 1},
+		{"1.0", "1.0.0", 0},
+	} {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+	if majorVersion("0.14.2") == majorVersion("0.15.0") || majorVersion("1.2.0") != majorVersion("1.9.0") {
+		t.Error("majorVersion")
+	}
+}
@@ -0,0 +1,389 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/jbdamask/john-code/pkg/config"
+)
+
+const (
+	// docsCacheTTL is how long fetched documentation is reused
+	docsCacheTTL = 24 * time.Hour
+	// maxDocsLength caps what Docs returns, like WebFetch's page limit
+	maxDocsLength = 20000
+)
+
+// DocsTool fetches the documentation of a dependency: go doc or pkg.go.dev
+// for Go, the npm registry README for JavaScript and the PyPI description
+// for Python. Results are cached on disk when a cache directory is set.
+type DocsTool struct {
+	client   *http.Client
+	goDocURL string
+	npmURL   string
+	pypiURL  string
+	goDoc    bool // Try the local go doc before pkg.go.dev
+	cacheDir string
+}
+
+func NewDocsTool() *DocsTool {
+	return &DocsTool{
+		client:   config.NewHTTPClient(15 * time.Second),
+		goDocURL: "https://pkg.go.dev",
+		npmURL:   "https://registry.npmjs.org",
+		pypiURL:  "https://pypi.org/pypi",
+		goDoc:    true,
+	}
+}
+
+// NewDocsToolWithEndpoints creates a DocsTool that reads from mirrors or
+// test servers instead of the public registries, without the local go doc
+func NewDocsToolWithEndpoints(goDocURL, npmURL, pypiURL string) *DocsTool {
+	tool := NewDocsTool()
+	tool.goDocURL, tool.npmURL, tool.pypiURL = goDocURL, npmURL, pypiURL
+	tool.goDoc = false
+	return tool
+}
+
+// SetCacheDir sets where fetched documentation is kept; "" turns the cache
+// off
+func (t *DocsTool) SetCacheDir(dir string) {
+	t.cacheDir = dir
+}
+
+func (t *DocsTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name: "Docs",
+		Description: `Fetches the documentation of a dependency, condensed.
+- Go: go doc for modules in the build, otherwise pkg.go.dev; npm: the registry README; PyPI: the project description
+- Use before calling an API you are unsure of, instead of guessing signatures
+- Pass symbol to keep only the sections about one function, type or option
+- The ecosystem is guessed from the name and the project's files when omitted`,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"package": map[string]interface{}{
+					"type":        "string",
+					"description": `The package: a Go import path (e.g. "net/http", "github.com/spf13/cobra"), an npm name (e.g. "zod", "@tanstack/react-query") or a PyPI name (e.g. "httpx").`,
+				},
+				"ecosystem": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"go", "npm", "pypi"},
+					"description": "Where the package comes from.",
+				},
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional version, e.g. v1.8.0 or 3.22.4. Defaults to the latest.",
+				},
+				"symbol": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional function, type, method or option to focus on, e.g. Client.Do or safeParse.",
+				},
+			},
+			"required": []string{"package"},
+		},
+	}
+}
+
+func (t *DocsTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	name, _ := args["package"].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("package required")
+	}
+	ecosystem, _ := args["ecosystem"].(string)
+	version, _ := args["version"].(string)
+	symbol, _ := args["symbol"].(string)
+	if ecosystem == "" {
+		ecosystem = guessEcosystem(name)
+		if ecosystem == "" {
+			return "", fmt.Errorf("cannot tell whether %s is a Go, npm or PyPI package; pass ecosystem", name)
+		}
+	}
+
+	key := strings.Join([]string{ecosystem, name, version, symbol}, "\x00")
+	if cached, ok := t.cached(key); ok {
+		return cached, nil
+	}
+
+	var source, text string
+	var err error
+	switch ecosystem {
+	case "go":
+		source, text, err = t.goDocs(ctx, name, version, symbol)
+	case "npm":
+		source, text, err = t.npmDocs(ctx, name, version)
+	case "pypi":
+		source, text, err = t.pypiDocs(ctx, name, version)
+	default:
+		return "", fmt.Errorf("unknown ecosystem %q; use go, npm or pypi", ecosystem)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("Documentation of %s (%s):\n\n%s", name, source, condenseDocs(text, symbol, maxDocsLength))
+	t.store(key, result)
+	return result, nil
+}
+
+// guessEcosystem tells Go import paths and scoped npm names apart by their
+// shape, and otherwise goes by the project's manifest
+func guessEcosystem(name string) string {
+	first, _, _ := strings.Cut(name, "/")
+	switch {
+	case strings.HasPrefix(name, "@"):
+		return "npm"
+	case strings.Contains(first, "."):
+		return "go"
+	}
+	for _, manifest := range []struct{ file, ecosystem string }{
+		{"go.mod", "go"},
+		{"package.json", "npm"},
+		{"pyproject.toml", "pypi"},
+		{"requirements.txt", "pypi"},
+		{"setup.py", "pypi"},
+	} {
+		if _, err := os.Stat(manifest.file); err == nil {
+			return manifest.ecosystem
+		}
+	}
+	return ""
+}
+
+// goDocs runs go doc, which knows the exact versions in go.mod and the
+// standard library, and falls back to pkg.go.dev for other modules
+func (t *DocsTool) goDocs(ctx context.Context, name, version, symbol string) (string, string, error) {
+	if t.goDoc && version == "" {
+		if _, err := exec.LookPath("go"); err == nil {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			args := []string{"doc"}
+			if symbol == "" {
+				args = append(args, "-all", name)
+			} else {
+				args = append(args, name, symbol)
+			}
+			out, err := exec.CommandContext(ctx, "go", args...).Output()
+			if err == nil && len(strings.TrimSpace(string(out))) > 0 {
+				return "go doc", string(out), nil
+			}
+		}
+	}
+
+	page := t.goDocURL + "/" + name
+	if version != "" {
+		page += "@" + version
+	}
+	body, err := httpGet(ctx, t.client, page, "")
+	if err != nil {
+		return "", "", err
+	}
+	text, err := md.NewConverter("", true, nil).ConvertString(string(body))
+	if err != nil {
+		return "", "", fmt.Errorf("html parsing failed: %w", err)
+	}
+	// Skip the site's header and overview boxes
+	if i := strings.Index(text, "Documentation"); i >= 0 {
+		if j := strings.Index(text[i:], "\n"); j >= 0 {
+			text = text[i+j:]
+		}
+	}
+	return page, text, nil
+}
+
+// npmDocs reads a package's README from the registry
+func (t *DocsTool) npmDocs(ctx context.Context, name, version string) (string, string, error) {
+	body, err := httpGet(ctx, t.client, t.npmURL+"/"+url.PathEscape(name), "")
+	if err != nil {
+		return "", "", err
+	}
+	var pkg struct {
+		Description string            `json:"description"`
+		Readme      string            `json:"readme"`
+		DistTags    map[string]string `json:"dist-tags"`
+		Versions    map[string]struct {
+			Readme string `json:"readme"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(body, &pkg); err != nil {
+		return "", "", fmt.Errorf("unexpected answer from the npm registry: %w", err)
+	}
+	if version == "" {
+		version = pkg.DistTags["latest"]
+	} else if _, ok := pkg.Versions[version]; !ok {
+		return "", "", fmt.Errorf("npm has no version %s of %s", version, name)
+	}
+	readme := pkg.Readme
+	if v := pkg.Versions[version].Readme; v != "" {
+		readme = v
+	}
+	if strings.TrimSpace(readme) == "" {
+		readme = pkg.Description
+	}
+	return "npm " + version, readme, nil
+}
+
+// pypiDocs reads a project's description from PyPI
+func (t *DocsTool) pypiDocs(ctx context.Context, name, version string) (string, string, error) {
+	endpoint := t.pypiURL + "/" + url.PathEscape(name)
+	if version != "" {
+		endpoint += "/" + url.PathEscape(version)
+	}
+	body, err := httpGet(ctx, t.client, endpoint+"/json", "")
+	if err != nil {
+		return "", "", err
+	}
+	var project struct {
+		Info struct {
+			Version     string `json:"version"`
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(body, &project); err != nil {
+		return "", "", fmt.Errorf("unexpected answer from PyPI: %w", err)
+	}
+	text := project.Info.Description
+	if strings.TrimSpace(text) == "" {
+		text = project.Info.Summary
+	}
+	return "PyPI " + project.Info.Version, text, nil
+}
+
+// httpGet fetches endpoint for a registry lookup, asking for accept when it
+// is set, and turns a 404 into an error that points at the package name
+func httpGet(ctx context.Context, client *http.Client, endpoint, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "JohnCode/1.0")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("%s not found; check the package name and version", endpoint)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch of %s failed: %s", endpoint, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+}
+
+var (
+	docsNoise      = regexp.MustCompile(`(?m)^\s*(\[!\[.*|!\[.*|<img .*|<p align.*|</?p>|</?div.*|<!--.*-->)\s*$`)
+	docsBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// condenseDocs drops badges, images and layout HTML, keeps only the sections
+// mentioning symbol when one is given, and cuts the rest at max bytes
+func condenseDocs(text, symbol string, max int) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = docsNoise.ReplaceAllString(text, "")
+	text = strings.TrimSpace(docsBlankLines.ReplaceAllString(text, "\n\n"))
+
+	if symbol != "" {
+		short := symbol[strings.LastIndex(symbol, ".")+1:]
+		var kept []string
+		for _, section := range docsSections(text) {
+			if strings.Contains(section, short) {
+				kept = append(kept, section)
+			}
+		}
+		if len(kept) > 0 {
+			text = strings.Join(kept, "\n\n")
+		} else {
+			text = fmt.Sprintf("(%s is not mentioned; showing all of it)\n\n%s", symbol, text)
+		}
+	}
+
+	if len(text) > max {
+		text = text[:max] + "\n...[Truncated; pass symbol to see the part you need]..."
+	}
+	return text
+}
+
+// docsSections splits Markdown at its headings, or plain text such as go doc
+// output at blank lines between unindented declarations
+func docsSections(text string) []string {
+	var sections []string
+	var current strings.Builder
+	inCode := false
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+		}
+		starts := !inCode && (strings.HasPrefix(line, "#") ||
+			(i > 0 && lines[i-1] == "" && line != "" && line[0] != ' ' && line[0] != '\t' && declaration(line)))
+		if starts && current.Len() > 0 {
+			sections = append(sections, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+		current.WriteString(line + "\n")
+	}
+	if current.Len() > 0 {
+		sections = append(sections, strings.TrimSpace(current.String()))
+	}
+	return sections
+}
+
+// declaration reports whether a go doc line starts a declaration
+func declaration(line string) bool {
+	for _, prefix := range []string{"func ", "type ", "var ", "const "} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cached returns documentation fetched for key within docsCacheTTL
+func (t *DocsTool) cached(key string) (string, bool) {
+	if t.cacheDir == "" {
+		return "", false
+	}
+	path := t.cachePath(key)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > docsCacheTTL {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func (t *DocsTool) store(key, result string) {
+	if t.cacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(t.cacheDir, 0755); err == nil {
+		os.WriteFile(t.cachePath(key), []byte(result), 0644)
+	}
+}
+
+func (t *DocsTool) cachePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(t.cacheDir, hex.EncodeToString(sum[:])+".md")
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDocsCondensesAndCachesRegistryReadmes(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.EscapedPath() {
+		case "/npm/@acme%2Fwidgets":
+			w.Write([]byte(`{"dist-tags":{"latest":"2.1.0"},"readme":"# Widgets\n[![build](https://ci/badge.svg)](https://ci)\n\n## Install\n\nnpm i @acme/widgets\n\n## render(el, opts)\n\nRenders into el.\n\n## dispose()\n\nFrees it."}`))
+		case "/pypi/httpx/json":
+			w.Write([]byte(`{"info":{"version":"0.27.0","description":"HTTPX is a client."}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tool := NewDocsToolWithEndpoints(server.URL+"/go", server.URL+"/npm", server.URL+"/pypi")
+	tool.SetCacheDir(t.TempDir())
+	args := map[string]interface{}{"package": "@acme/widgets", "symbol": "render"}
+	out, err := tool.Execute(context.Background(), args)
+	if err != nil {
+		t.Fatalf("Docs: %v", err)
+	}
+	if !strings.Contains(out, "(npm 2.1.0)") || !strings.Contains(out, "Renders into el.") {
+		t.Errorf("output lacks the render section:\n%s", out)
+	}
+	if strings.Contains(out, "badge.svg") || strings.Contains(out, "Frees it.") || strings.Contains(out, "npm i") {
+		t.Errorf("output keeps badges or unrelated sections:\n%s", out)
+	}
+
+	again, err := tool.Execute(context.Background(), args)
+	if err != nil || again != out || requests != 1 {
+		t.Errorf("second call made %d requests, err %v", requests, err)
+	}
+
+	out, err = tool.Execute(context.Background(), map[string]interface{}{"package": "httpx", "ecosystem": "pypi"})
+	if err != nil || !strings.Contains(out, "(PyPI 0.27.0)") || !strings.Contains(out, "HTTPX is a client.") {
+		t.Errorf("pypi: %v\n%s", err, out)
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"package": "missing", "ecosystem": "npm"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing package: err = %v", err)
+	}
+}
+
+func TestDocsUsesGoDoc(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	out, err := NewDocsTool().Execute(context.Background(), map[string]interface{}{"package": "strings", "ecosystem": "go", "symbol": "Cut"})
+	if err != nil {
+		t.Fatalf("Docs: %v", err)
+	}
+	if !strings.Contains(out, "(go doc)") || !strings.Contains(out, "func Cut(s, sep string) (before, after string, found bool)") {
+		t.Errorf("output:\n%s", out)
+	}
+}
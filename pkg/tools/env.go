@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	envNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	secretPlaceholder = regexp.MustCompile(`\{\{secret:([A-Za-z_][A-Za-z0-9_]*)\}\}`)
+)
+
+// minRedactedLength is the shortest value Redact replaces; shorter ones
+// would mangle ordinary text
+const minRedactedLength = 4
+
+// EnvStore holds environment variables set for the current session only.
+// They are added to the environment of Bash commands and MCP servers
+// started after they are set, and are never written to disk. Secrets are
+// kept out of that environment: they are only filled in where a tool call
+// names them with {{secret:NAME}}, once the user confirms it.
+type EnvStore struct {
+	mu      sync.RWMutex
+	vars    map[string]string
+	secrets map[string]bool
+}
+
+// SessionEnv is the variables set with /env
+var SessionEnv = &EnvStore{vars: make(map[string]string)}
+
+// Set sets a variable, replacing any earlier value
+func (e *EnvStore) Set(name, value string) error {
+	return e.set(name, value, false)
+}
+
+// SetSecret sets a variable that is not exported to commands, replacing
+// any earlier value
+func (e *EnvStore) SetSecret(name, value string) error {
+	return e.set(name, value, true)
+}
+
+func (e *EnvStore) set(name, value string, secret bool) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.vars[name] = value
+	if e.secrets == nil {
+		e.secrets = make(map[string]bool)
+	}
+	if secret {
+		e.secrets[name] = true
+	} else {
+		delete(e.secrets, name)
+	}
+	return nil
+}
+
+// Unset removes a variable and reports whether it was set
+func (e *EnvStore) Unset(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.vars[name]
+	delete(e.vars, name)
+	delete(e.secrets, name)
+	return ok
+}
+
+// Secret reports whether a variable was set as a secret
+func (e *EnvStore) Secret(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.secrets[name]
+}
+
+// Get returns a variable's value
+func (e *EnvStore) Get(name string) (string, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+// Names returns the names of the variables set, sorted
+func (e *EnvStore) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, 0, len(e.vars))
+	for name := range e.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pairs returns the variables other than secrets as NAME=value entries
+func (e *EnvStore) Pairs() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	pairs := make([]string, 0, len(e.vars))
+	for name, value := range e.vars {
+		if !e.secrets[name] {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// Environ returns base with the session variables added. The later entries
+// win, so they override inherited values. It returns nil, meaning the
+// process environment as is, when no variables are set.
+func (e *EnvStore) Environ(base []string) []string {
+	pairs := e.Pairs()
+	if len(pairs) == 0 {
+		return nil
+	}
+	return append(append([]string{}, base...), pairs...)
+}
+
+// Expand returns a copy of tool arguments with each {{secret:NAME}} in a
+// string replaced by the session variable NAME, so a tool can use a secret
+// the model never saw. Unknown names are left as they are.
+func (e *EnvStore) Expand(args map[string]interface{}) map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.vars) == 0 {
+		return args
+	}
+	return e.expand(args).(map[string]interface{})
+}
+
+// Placeholders returns the session variables that {{secret:NAME}}
+// placeholders in tool arguments refer to, sorted
+func (e *EnvStore) Placeholders(args map[string]interface{}) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	found := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			for _, m := range secretPlaceholder.FindAllStringSubmatch(v, -1) {
+				if _, ok := e.vars[m[1]]; ok {
+					found[m[1]] = true
+				}
+			}
+		case map[string]interface{}:
+			for _, val := range v {
+				walk(val)
+			}
+		case []interface{}:
+			for _, val := range v {
+				walk(val)
+			}
+		}
+	}
+	walk(args)
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *EnvStore) expand(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return secretPlaceholder.ReplaceAllStringFunc(v, func(m string) string {
+			if value, ok := e.vars[secretPlaceholder.FindStringSubmatch(m)[1]]; ok {
+				return value
+			}
+			return m
+		})
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = e.expand(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = e.expand(val)
+		}
+		return out
+	}
+	return v
+}
+
+// Redact replaces every session variable value in s with a marker naming
+// the variable, so tool output that echoes a secret does not carry it into
+// the conversation or the session log
+func (e *EnvStore) Redact(s string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, name := range e.namesByLength() {
+		s = strings.ReplaceAll(s, e.vars[name], "[redacted $"+name+"]")
+	}
+	return s
+}
+
+// namesByLength orders the variables with redactable values longest value
+// first, so a secret containing another is replaced whole
+func (e *EnvStore) namesByLength() []string {
+	var names []string
+	for name, value := range e.vars {
+		if len(value) >= minRedactedLength {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(e.vars[names[i]]) != len(e.vars[names[j]]) {
+			return len(e.vars[names[i]]) > len(e.vars[names[j]])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
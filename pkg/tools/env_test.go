@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSessionEnvReachesBash(t *testing.T) {
+	defer func() { SessionEnv = &EnvStore{vars: make(map[string]string)} }()
+
+	if err := SessionEnv.Set("JOHN_TEST_TOKEN", "s3cret"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := SessionEnv.Set("BAD-NAME", "x"); err == nil {
+		t.Error("Expected an invalid name to be rejected")
+	}
+
+	out, err := NewBashTool().Execute(context.Background(), map[string]interface{}{"command": "echo $JOHN_TEST_TOKEN"})
+	if err != nil || strings.TrimSpace(out) != "s3cret" {
+		t.Fatalf("Expected the session variable in Bash, got %q (%v)", out, err)
+	}
+
+	// Secrets are only filled in where a tool call names them
+	SessionEnv.SetSecret("JOHN_TEST_SECRET", "hunter22")
+	out, _ = NewBashTool().Execute(context.Background(), map[string]interface{}{"command": "echo \"[$JOHN_TEST_SECRET]\""})
+	if strings.TrimSpace(out) != "[]" || !SessionEnv.Secret("JOHN_TEST_SECRET") {
+		t.Errorf("Expected the secret kept out of Bash, got %q", out)
+	}
+	if SessionEnv.Set("JOHN_TEST_SECRET", "plain"); SessionEnv.Secret("JOHN_TEST_SECRET") {
+		t.Error("Expected /env set to replace the secret with a plain variable")
+	}
+
+	if !SessionEnv.Unset("JOHN_TEST_TOKEN") || SessionEnv.Unset("JOHN_TEST_TOKEN") {
+		t.Error("Expected Unset to report whether the variable was set")
+	}
+	out, _ = NewBashTool().Execute(context.Background(), map[string]interface{}{"command": "echo \"[$JOHN_TEST_TOKEN]\""})
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("Expected the variable to be gone, got %q", out)
+	}
+}
+
+func TestSessionEnvExpandAndRedact(t *testing.T) {
+	defer func() { SessionEnv = &EnvStore{vars: make(map[string]string)} }()
+	SessionEnv.Set("API_TOKEN", "tok-123456")
+
+	args := map[string]interface{}{
+		"content": "token = \"{{secret:API_TOKEN}}\" # {{secret:UNKNOWN}}",
+		"edits":   []interface{}{map[string]interface{}{"new_string": "{{secret:API_TOKEN}}"}},
+	}
+	expanded := SessionEnv.Expand(args)
+	if got := expanded["content"]; got != "token = \"tok-123456\" # {{secret:UNKNOWN}}" {
+		t.Errorf("Unexpected expansion: %q", got)
+	}
+	if got := expanded["edits"].([]interface{})[0].(map[string]interface{})["new_string"]; got != "tok-123456" {
+		t.Errorf("Expected nested arguments to be expanded, got %q", got)
+	}
+	if args["content"] != "token = \"{{secret:API_TOKEN}}\" # {{secret:UNKNOWN}}" {
+		t.Error("Expand must not modify the original arguments")
+	}
+
+	if got := SessionEnv.Redact("auth: tok-123456\n"); got != "auth: [redacted $API_TOKEN]\n" {
+		t.Errorf("Unexpected redaction: %q", got)
+	}
+}
+
+func TestAskUserQuestionSecret(t *testing.T) {
+	defer func() { SessionEnv = &EnvStore{vars: make(map[string]string)} }()
+
+	tool := NewAskUserQuestionTool(&secretUI{MockUI: MockUI{}, secret: "hunter22"})
+	out, err := tool.Execute(context.Background(), map[string]interface{}{
+		"question": "GitHub token?", "secret": true, "secret_name": "GITHUB_TOKEN",
+	})
+	if err != nil {
+		t.Fatalf("Secret question failed: %v", err)
+	}
+	if strings.Contains(out, "hunter22") {
+		t.Errorf("The secret leaked into the tool result: %q", out)
+	}
+	if v, _ := SessionEnv.Get("GITHUB_TOKEN"); v != "hunter22" || !SessionEnv.Secret("GITHUB_TOKEN") {
+		t.Errorf("Expected the secret in the session env, got %q", v)
+	}
+
+	// A prompter that cannot mask input is not used for secrets
+	if _, err := NewAskUserQuestionTool(&MockUI{PromptMockResponse: "oops"}).Execute(context.Background(), map[string]interface{}{
+		"question": "Password?", "secret": true, "secret_name": "PASSWORD",
+	}); err == nil {
+		t.Error("Expected an error without masked input")
+	}
+}
+
+type secretUI struct {
+	MockUI
+	secret string
+}
+
+func (s *secretUI) PromptSecret(string) string {
+	return s.secret
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultExecToolTimeout bounds a run of an exec tool without a timeout
+const defaultExecToolTimeout = 2 * time.Minute
+
+// ExecTool is a tool declared in settings and backed by a command, so a
+// script can become a tool without recompiling john or writing an MCP
+// server. The arguments are written to the command's stdin as a JSON object
+// and its stdout is the result.
+type ExecTool struct {
+	def     ToolDefinition
+	command string
+	timeout time.Duration
+}
+
+// NewExecTool creates a tool that runs command with bash -c. A nil schema
+// takes no arguments, and a zero timeout uses defaultExecToolTimeout.
+func NewExecTool(name, description, command string, schema map[string]interface{}, timeout time.Duration) *ExecTool {
+	if schema == nil {
+		schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if timeout <= 0 {
+		timeout = defaultExecToolTimeout
+	}
+	return &ExecTool{
+		def:     ToolDefinition{Name: name, Description: description, Schema: schema},
+		command: command,
+		timeout: timeout,
+	}
+}
+
+func (t *ExecTool) Definition() ToolDefinition {
+	return t.def
+}
+
+// Command returns the command the tool runs
+func (t *ExecTool) Command() string {
+	return t.command
+}
+
+func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode arguments: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "bash", "-c", t.command)
+	cmd.Env = append(SessionEnv.Environ(os.Environ()), "JOHN_TOOL_NAME="+t.def.Name)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+
+	err = cmd.Run()
+	output := stdout.String()
+	if len(output) > 30000 {
+		output = output[:30000] + "\n...[Output Truncated]..."
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s", t.def.Name, t.timeout)
+	}
+	if err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" {
+			detail = strings.TrimSpace(output)
+		}
+		if len(detail) > 2000 {
+			detail = detail[len(detail)-2000:]
+		}
+		return "", fmt.Errorf("%s failed (%v): %s", t.def.Name, err, detail)
+	}
+	return output, nil
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecTool(t *testing.T) {
+	ctx := context.Background()
+
+	echo := NewExecTool("Echo", "Echoes its arguments", `cat; echo " from $JOHN_TOOL_NAME"`, nil, 0)
+	if schema := echo.Definition().Schema.(map[string]interface{}); schema["type"] != "object" {
+		t.Errorf("default schema = %v", schema)
+	}
+	out, err := echo.Execute(ctx, map[string]interface{}{"ticket": "ENG-42"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if strings.TrimSpace(out) != `{"ticket":"ENG-42"} from Echo` {
+		t.Errorf("output = %q", out)
+	}
+
+	failing := NewExecTool("Lookup", "", `echo partial; echo "no such ticket" >&2; exit 3`, nil, 0)
+	if _, err := failing.Execute(ctx, nil); err == nil || !strings.Contains(err.Error(), "no such ticket") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("err = %v, want the exit status and stderr", err)
+	}
+
+	slow := NewExecTool("Slow", "", "sleep 10", nil, 200*time.Millisecond)
+	start := time.Now()
+	if _, err := slow.Execute(ctx, nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout took %s", elapsed)
+	}
+}
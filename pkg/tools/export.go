@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Where exported tools come from
+const (
+	SourceBuiltin = "builtin"
+	SourceExec    = "exec" // execTools in settings
+	SourceMCP     = "mcp"
+)
+
+// ExportedTool is a tool definition as john tools export writes it
+type ExportedTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	InputSchema interface{} `json:"input_schema"` // JSON Schema of the arguments
+	Source      string      `json:"source"`
+	Server      string      `json:"server,omitempty"` // MCP server name
+}
+
+// Bundle is the JSON export: the tool contract of one john version
+type Bundle struct {
+	Generator string         `json:"generator"`
+	Version   string         `json:"version"`
+	Tools     []ExportedTool `json:"tools"`
+}
+
+// Export lists the definitions of the registered tools by name, with
+// where each comes from
+func Export(r *Registry) []ExportedTool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	exported := make([]ExportedTool, 0, len(r.tools))
+	for _, t := range r.tools {
+		def := t.Definition()
+		e := ExportedTool{Name: def.Name, Description: def.Description, InputSchema: def.Schema, Source: SourceBuiltin}
+		switch t := t.(type) {
+		case *MCPTool:
+			e.Source, e.Server = SourceMCP, t.serverName
+		case *ExecTool:
+			e.Source = SourceExec
+		}
+		if e.InputSchema == nil {
+			e.InputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		exported = append(exported, e)
+	}
+	sort.Slice(exported, func(i, j int) bool { return exported[i].Name < exported[j].Name })
+	return exported
+}
+
+// schemaName makes a tool name usable as an OpenAPI component name
+var schemaName = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// WriteBundle writes tools as "json", a Bundle, or "openapi", an OpenAPI
+// 3.1 document with one POST /tools/{name} operation per tool taking its
+// arguments as the request body
+func WriteBundle(w io.Writer, format, version string, tools []ExportedTool) error {
+	var doc interface{}
+	switch format {
+	case "", "json":
+		doc = Bundle{Generator: "john-code", Version: version, Tools: tools}
+	case "openapi":
+		doc = openAPIDocument(version, tools)
+	default:
+		return fmt.Errorf("unknown format %q: use json or openapi", format)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+type openAPIDoc struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       map[string]string      `json:"info"`
+	Paths      map[string]interface{} `json:"paths"`
+	Components map[string]interface{} `json:"components"`
+}
+
+func openAPIDocument(version string, tools []ExportedTool) openAPIDoc {
+	paths := make(map[string]interface{})
+	schemas := make(map[string]interface{})
+	for _, t := range tools {
+		name := schemaName.ReplaceAllString(t.Name, "_")
+		schemas[name+"Input"] = t.InputSchema
+		summary, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+		op := map[string]interface{}{
+			"operationId": name,
+			"summary":     summary,
+			"description": t.Description,
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]string{"$ref": "#/components/schemas/" + name + "Input"},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "The tool's output, as the model sees it",
+					"content": map[string]interface{}{
+						"text/plain": map[string]interface{}{"schema": map[string]string{"type": "string"}},
+					},
+				},
+			},
+			"x-john-source": t.Source,
+		}
+		if t.Server != "" {
+			op["x-john-mcp-server"] = t.Server
+		}
+		paths["/tools/"+url.PathEscape(t.Name)] = map[string]interface{}{"post": op}
+	}
+	return openAPIDoc{
+		OpenAPI: "3.1.0",
+		Info: map[string]string{
+			"title":       "john-code tools",
+			"version":     version,
+			"description": "The tools john-code offers models. Each operation takes the tool's arguments as JSON and returns its text output.",
+		},
+		Paths:      paths,
+		Components: map[string]interface{}{"schemas": schemas},
+	}
+}
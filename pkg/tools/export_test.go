@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestExportBundle(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&ReadTool{})
+	r.Register(NewExecTool("lint", "Run the linter", "make lint", nil, 0))
+	exported := Export(r)
+	if len(exported) != 2 || exported[0].Name != "Read" || exported[1].Source != SourceExec {
+		t.Fatalf("exported %+v", exported)
+	}
+	if exported[0].Source != SourceBuiltin || exported[1].InputSchema == nil {
+		t.Errorf("exported %+v", exported)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, "json", "1.2.3", exported); err != nil {
+		t.Fatal(err)
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil || bundle.Version != "1.2.3" || len(bundle.Tools) != 2 {
+		t.Errorf("json bundle %s: %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := WriteBundle(&buf, "openapi", "1.2.3", exported); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Source      string `json:"x-john-source"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if op := doc.Paths["/tools/Read"]["post"]; doc.OpenAPI != "3.1.0" || op.OperationID != "Read" || op.Source != SourceBuiltin {
+		t.Errorf("openapi %s", buf.String())
+	}
+	if doc.Components.Schemas["lintInput"] == nil {
+		t.Errorf("schemas %v", doc.Components.Schemas)
+	}
+
+	if err := WriteBundle(&buf, "yaml", "1.2.3", exported); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
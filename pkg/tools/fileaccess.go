@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileAccess hands out advisory per-file locks to the tools that change
+// files. Tool calls of parallel sub-agents, or of Slack threads answered at
+// the same time, would otherwise interleave their read-modify-write cycles
+// and lose each other's changes. A tool holds a file's lock from reading it
+// to writing it back, so a later writer reads the updated content.
+type FileAccess struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+type fileLock struct {
+	held chan struct{} // Has an element while the lock is held
+	refs int           // Holders and waiters, to drop unused locks
+}
+
+// Files coordinates the file changes of every agent in this process
+var Files = &FileAccess{locks: make(map[string]*fileLock)}
+
+// Lock blocks until it holds the locks of all paths, or ctx is done, and
+// returns the function that releases them. Paths naming the same file share
+// a lock, and locks are taken in a fixed order so that callers locking
+// several files cannot deadlock.
+func (f *FileAccess) Lock(ctx context.Context, paths ...string) (func(), error) {
+	keys := make([]string, 0, len(paths))
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		key := canonicalPath(path)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var held []string
+	unlock := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			f.release(held[i], true)
+		}
+	}
+	for _, key := range keys {
+		f.mu.Lock()
+		l := f.locks[key]
+		if l == nil {
+			l = &fileLock{held: make(chan struct{}, 1)}
+			f.locks[key] = l
+		}
+		l.refs++
+		f.mu.Unlock()
+
+		select {
+		case l.held <- struct{}{}:
+			held = append(held, key)
+		case <-ctx.Done():
+			f.release(key, false)
+			unlock()
+			return nil, ctx.Err()
+		}
+	}
+	return unlock, nil
+}
+
+func (f *FileAccess) release(key string, held bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l := f.locks[key]
+	if held {
+		<-l.held
+	}
+	if l.refs--; l.refs == 0 {
+		delete(f.locks, key)
+	}
+}
+
+// canonicalPath makes paths that name the same file equal: absolute, with
+// symlinks in existing parts resolved
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
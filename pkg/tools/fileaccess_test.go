@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentEditsSerialize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("item %d: todo", i))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the lock, edits that read the file before another one wrote
+	// it would write back stale content and lose that change
+	var wg sync.WaitGroup
+	errs := make(chan error, len(lines))
+	for i := range lines {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := (&EditTool{}).Execute(context.Background(), map[string]interface{}{
+				"file_path":  path,
+				"old_string": fmt.Sprintf("item %d: todo\n", i),
+				"new_string": fmt.Sprintf("item %d: done\n", i),
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Edit failed: %v", err)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), ": done"); n != len(lines) {
+		t.Errorf("%d of %d edits landed:\n%s", n, len(lines), data)
+	}
+}
+
+func TestFileAccessLock(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+
+	unlock, err := Files.Lock(context.Background(), b, a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another path to the same file waits for the lock, until it gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := Files.Lock(ctx, filepath.Join(dir, ".", "a.go")); err != context.DeadlineExceeded {
+		t.Errorf("Lock of a held file: err = %v", err)
+	}
+
+	unlock()
+	again, err := Files.Lock(context.Background(), a)
+	if err != nil {
+		t.Fatalf("Lock after release: %v", err)
+	}
+	again()
+
+	Files.mu.Lock()
+	defer Files.mu.Unlock()
+	if len(Files.locks) != 0 {
+		t.Errorf("%d locks left behind", len(Files.locks))
+	}
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// FinalAnswerTool lets a sub-agent or headless run declare that it is done and
+// hand back its result explicitly, instead of relying on whatever text happens
+// to be last in the conversation.
+type FinalAnswerTool struct {
+	Answer string
+	Done   bool
+}
+
+func NewFinalAnswerTool() *FinalAnswerTool {
+	return &FinalAnswerTool{}
+}
+
+func (t *FinalAnswerTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name: "FinalAnswer",
+		Description: `Report the final result of your task and finish.
+- Call this exactly once, when the task is complete
+- The answer is returned verbatim to whoever delegated the task, so make it self-contained
+- Include file paths, findings and anything the caller needs; they cannot see your intermediate work
+- No further tool calls are run after this one, including later calls in the same response`,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"answer": map[string]interface{}{
+					"type":        "string",
+					"description": "The complete final answer for the task",
+				},
+			},
+			"required": []string{"answer"},
+		},
+	}
+}
+
+func (t *FinalAnswerTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	answer, ok := args["answer"].(string)
+	if !ok {
+		return "", fmt.Errorf("answer required")
+	}
+	t.Answer = answer
+	t.Done = true
+	return "Final answer recorded.", nil
+}
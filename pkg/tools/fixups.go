@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/editorconfig"
+)
+
+// EditorConfig modes, from files.editorConfig in settings
+const (
+	EditorConfigFix   = "fix"   // Fix what can be fixed and flag the rest
+	EditorConfigCheck = "check" // Write as sent and flag violations
+	EditorConfigOff   = "off"   // Ignore .editorconfig
+)
+
+// EditorConfigMode sets how Write and Edit hold what they write to the
+// project's .editorconfig: final newline, trailing whitespace, leading tabs
+// or spaces, line endings and line length. The agent sets it from
+// settings; the zero value is off.
+var EditorConfigMode string
+
+// fixupFile applies the .editorconfig rules for path to content. Line rules
+// only touch the lines overlapping content[start:end], the part the model
+// wrote, so an edit does not reformat the rest of the file. It returns the
+// new content and a note of what changed and what still breaks the rules,
+// empty when there is nothing to say.
+func fixupFile(ctx context.Context, path, content string, start, end int) (string, string) {
+	if EditorConfigMode != EditorConfigFix && EditorConfigMode != EditorConfigCheck {
+		return content, ""
+	}
+	props, err := editorconfig.Resolve(path, func(name string) ([]byte, error) { return Target.ReadFile(ctx, name) })
+	if err != nil {
+		return content, fmt.Sprintf(" (.editorconfig not applied: %v)", err)
+	}
+	if len(props) == 0 {
+		return content, ""
+	}
+
+	// Fixes keep the lines where they are, so the check can find them by number
+	first := strings.Count(content[:start], "\n") + 1
+	last := first + strings.Count(content[start:end], "\n")
+	var note string
+	if EditorConfigMode == EditorConfigFix {
+		content, note = fixRegion(content, start, end, props)
+	}
+	if violations := checkLines(content, first, last, props); len(violations) > 0 {
+		note += "\nThis breaks the project's .editorconfig: " + strings.Join(violations, "; ") + ". Fix it unless the surrounding code does the same."
+	}
+	return content, note
+}
+
+// region returns the bounds of the whole lines overlapping content[start:end]
+func region(content string, start, end int) (lineStart, lineEnd int) {
+	lineStart = strings.LastIndex(content[:start], "\n") + 1
+	lineEnd = len(content)
+	if i := strings.Index(content[end:], "\n"); i >= 0 {
+		lineEnd = end + i
+	}
+	return lineStart, lineEnd
+}
+
+// fixRegion fixes indentation, trailing whitespace and line endings in the
+// lines overlapping content[start:end], and the final newline of the file
+func fixRegion(content string, start, end int, props editorconfig.Properties) (string, string) {
+	eol := map[string]string{"lf": "\n", "crlf": "\r\n"}[props.EndOfLine()]
+	lineStart, lineEnd := region(content, start, end)
+
+	var indents, trims, endings int
+	lines := strings.Split(content[lineStart:lineEnd], "\n")
+	for i, line := range lines {
+		body, cr := strings.CutSuffix(line, "\r")
+		if fixed := fixIndent(body, props); fixed != body {
+			body = fixed
+			indents++
+		}
+		if props.TrimTrailingWhitespace() {
+			if trimmed := strings.TrimRight(body, " \t"); trimmed != body {
+				body = trimmed
+				trims++
+			}
+		}
+		// The last line's ending is outside the region, if it has one
+		hasEnding := i < len(lines)-1 || lineEnd < len(content)
+		if eol != "" && hasEnding && cr != (eol == "\r\n") {
+			cr = eol == "\r\n"
+			endings++
+		}
+		if cr {
+			body += "\r"
+		}
+		lines[i] = body
+	}
+	content = content[:lineStart] + strings.Join(lines, "\n") + content[lineEnd:]
+
+	var notes []string
+	if indents > 0 {
+		notes = append(notes, fmt.Sprintf("%s indentation on %s", props.IndentStyle(), plural(indents, "line")))
+	}
+	if trims > 0 {
+		notes = append(notes, fmt.Sprintf("trimmed trailing whitespace on %s", plural(trims, "line")))
+	}
+	if endings > 0 {
+		notes = append(notes, fmt.Sprintf("%s line endings on %s", props.EndOfLine(), plural(endings, "line")))
+	}
+	if want, set := props.InsertFinalNewline(); set && content != "" {
+		has := strings.HasSuffix(content, "\n")
+		switch {
+		case want && !has:
+			if eol == "" {
+				eol = "\n"
+			}
+			content += eol
+			notes = append(notes, "added final newline")
+		case !want && has:
+			content = strings.TrimRight(content, "\r\n")
+			notes = append(notes, "removed final newline")
+		}
+	}
+	if len(notes) == 0 {
+		return content, ""
+	}
+	return content, " (.editorconfig: " + strings.Join(notes, ", ") + ")"
+}
+
+// checkLines describes how lines first to last (from 1) of content, and the
+// end of the file, break the rules in props
+func checkLines(content string, first, last int, props editorconfig.Properties) []string {
+	tabWidth := props.TabWidth()
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
+
+	var indent, trailing, endings, long []int
+	lines := strings.Split(content, "\n")
+	for n := first; n <= last && n <= len(lines); n++ {
+		body, cr := strings.CutSuffix(lines[n-1], "\r")
+		if fixIndent(body, props) != body {
+			indent = append(indent, n)
+		}
+		if props.TrimTrailingWhitespace() && strings.TrimRight(body, " \t") != body {
+			trailing = append(trailing, n)
+		}
+		// The last piece has no line ending
+		if n < len(lines) && (props.EndOfLine() == "lf" && cr || props.EndOfLine() == "crlf" && !cr) {
+			endings = append(endings, n)
+		}
+		if max := props.MaxLineLength(); max > 0 && lineWidth(body, tabWidth) > max {
+			long = append(long, n)
+		}
+	}
+
+	var violations []string
+	if len(indent) > 0 {
+		violations = append(violations, fmt.Sprintf("indent_style is %s but %s %s not", props.IndentStyle(), lineList(indent), isAre(indent)))
+	}
+	if len(trailing) > 0 {
+		violations = append(violations, "trailing whitespace on "+lineList(trailing))
+	}
+	if len(endings) > 0 {
+		violations = append(violations, fmt.Sprintf("end_of_line is %s but not on %s", props.EndOfLine(), lineList(endings)))
+	}
+	if len(long) > 0 {
+		violations = append(violations, fmt.Sprintf("%s %s longer than max_line_length %d", lineList(long), isAre(long), props.MaxLineLength()))
+	}
+	if want, set := props.InsertFinalNewline(); set && content != "" && want != strings.HasSuffix(content, "\n") {
+		if want {
+			violations = append(violations, "the file does not end with a newline")
+		} else {
+			violations = append(violations, "the file ends with a newline, which insert_final_newline = false forbids")
+		}
+	}
+	return violations
+}
+
+// lineList names up to five line numbers
+func lineList(lines []int) string {
+	if len(lines) == 1 {
+		return fmt.Sprintf("line %d", lines[0])
+	}
+	names := make([]string, 0, 5)
+	for _, n := range lines {
+		if len(names) == 5 {
+			return fmt.Sprintf("lines %s and %d more", strings.Join(names, ", "), len(lines)-5)
+		}
+		names = append(names, fmt.Sprint(n))
+	}
+	return "lines " + strings.Join(names, ", ")
+}
+
+// isAre is the verb for a list of lines
+func isAre(lines []int) string {
+	if len(lines) == 1 {
+		return "is"
+	}
+	return "are"
+}
+
+// lineWidth is the number of columns line takes, with tabs to the next stop
+func lineWidth(line string, tabWidth int) int {
+	width := 0
+	for _, ch := range line {
+		if ch == '\t' {
+			width += tabWidth - width%tabWidth
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// fixIndent rewrites the leading whitespace of line in the indent style of
+// props, keeping its width. Spaces short of a full indent are kept, as
+// they align rather than indent.
+func fixIndent(line string, props editorconfig.Properties) string {
+	style, size, tabWidth := props.IndentStyle(), props.IndentSize(), props.TabWidth()
+	if style == "" || size <= 0 {
+		return line
+	}
+	if tabWidth <= 0 {
+		tabWidth = size
+	}
+	rest := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(rest)]
+	if rest == "" || style == "tab" && !strings.Contains(indent, " ") || style == "space" && !strings.Contains(indent, "\t") {
+		return line
+	}
+
+	width := lineWidth(indent, tabWidth)
+	if style == "space" {
+		return strings.Repeat(" ", width) + rest
+	}
+	return strings.Repeat("\t", width/tabWidth) + strings.Repeat(" ", width%tabWidth) + rest
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteAndEditApplyEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n\n[*]\ninsert_final_newline = true\ntrim_trailing_whitespace = true\n\n[*.go]\nindent_style = tab\nindent_size = 4\n"), 0644)
+	EditorConfigMode = EditorConfigFix
+	defer func() { EditorConfigMode = "" }()
+	ctx := context.Background()
+
+	path := filepath.Join(dir, "main.go")
+	out, err := (&WriteTool{}).Execute(ctx, map[string]interface{}{
+		"file_path": path,
+		"content":   "package main  \n\nfunc main() {\n    println(1)\n}",
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.Contains(out, "tab indentation on 1 line") || !strings.Contains(out, "added final newline") {
+		t.Errorf("Write output = %q", out)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "package main\n\nfunc main() {\n\tprintln(1)\n}\n" {
+		t.Errorf("written = %q", data)
+	}
+
+	// Edit only touches the lines it changed
+	os.WriteFile(path, []byte("package main\n\nvar a = 1   \n\nfunc main() {\n}\n"), 0644)
+	if _, err := (&EditTool{}).Execute(ctx, map[string]interface{}{
+		"file_path":  path,
+		"old_string": "func main() {\n}",
+		"new_string": "func main() {\n        println(a)  \n}",
+	}); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "package main\n\nvar a = 1   \n\nfunc main() {\n\t\tprintln(a)\n}\n" {
+		t.Errorf("edited = %q", data)
+	}
+
+	// Files no section covers are written as sent
+	txt := filepath.Join(t.TempDir(), "notes.txt")
+	if out, err := (&WriteTool{}).Execute(ctx, map[string]interface{}{"file_path": txt, "content": "a  "}); err != nil || strings.Contains(out, "editorconfig") {
+		t.Errorf("Write outside the project = %q, %v", out, err)
+	}
+}
+
+func TestCheckModeFlagsEditorConfigViolations(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n\n[*.py]\nindent_style = space\nindent_size = 4\nmax_line_length = 20\ninsert_final_newline = true\n"), 0644)
+	EditorConfigMode = EditorConfigCheck
+	defer func() { EditorConfigMode = "" }()
+
+	path := filepath.Join(dir, "app.py")
+	content := "def f():\n\treturn 'a long string here'"
+	out, err := (&WriteTool{}).Execute(context.Background(), map[string]interface{}{"file_path": path, "content": content})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, want := range []string{"indent_style is space but line 2 is not", "line 2 is longer than max_line_length 20", "does not end with a newline"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Write output missing %q: %q", want, out)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("check mode changed the file: %q", data)
+	}
+}
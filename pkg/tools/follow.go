@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxFollow        = 5 * time.Minute
+	defaultFollow    = 30 * time.Second // When only a pattern to wait for is given
+	followPoll       = 200 * time.Millisecond
+	maxFollowedBytes = 100 << 10 // Older lines beyond this are dropped
+)
+
+// FollowSource yields what a file or shell wrote since the last call, and
+// reports when nothing more can come
+type FollowSource func() (text string, done bool, err error)
+
+// FollowFile returns a source of what is appended to a local file from now
+// on, like tail -f. A file that shrinks was truncated or rotated, and is
+// followed again from its start.
+func FollowFile(path string) (FollowSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	offset := info.Size()
+	return func() (string, bool, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", false, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return "", false, err
+		}
+		if info.Size() < offset {
+			offset = 0
+		}
+		if info.Size() == offset {
+			return "", false, nil
+		}
+		data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+		offset += int64(len(data))
+		return string(data), false, err
+	}, nil
+}
+
+// FollowShell returns a source of the output of a background shell after
+// the first skip bytes, done once the shell has exited
+func FollowShell(id string, skip int) FollowSource {
+	return func() (string, bool, error) {
+		out, done, _ := GlobalShellManager.GetOutput(id)
+		if skip > len(out) {
+			skip = len(out)
+		}
+		text := out[skip:]
+		skip = len(out)
+		return text, done, nil
+	}
+}
+
+// Followed is what Follow saw
+type Followed struct {
+	Lines   []string
+	Dropped int    // Earlier lines left out to keep the result small
+	Matched string // The line that matched the pattern, if one did
+	Stopped string // Why following stopped
+	Elapsed time.Duration
+}
+
+// Follow polls source for up to d, or until a new line matches until, the
+// source is done or ctx is cancelled. Each poll's complete lines go to
+// onBatch, when set, as they arrive.
+func Follow(ctx context.Context, source FollowSource, d time.Duration, until *regexp.Regexp, onBatch func(lines []string)) (Followed, error) {
+	var f Followed
+	start := time.Now()
+	deadline := time.NewTimer(d)
+	defer deadline.Stop()
+	ticker := time.NewTicker(followPoll)
+	defer ticker.Stop()
+
+	pending, size := "", 0
+	add := func(lines []string) bool {
+		if len(lines) == 0 {
+			return false
+		}
+		if onBatch != nil {
+			onBatch(lines)
+		}
+		for _, line := range lines {
+			f.Lines = append(f.Lines, line)
+			size += len(line) + 1
+			for size > maxFollowedBytes && len(f.Lines) > 1 {
+				size -= len(f.Lines[0]) + 1
+				f.Lines = f.Lines[1:]
+				f.Dropped++
+			}
+			if until != nil && until.MatchString(line) {
+				f.Matched = line
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		text, done, err := source()
+		if err != nil {
+			return f, err
+		}
+		pending += text
+		lines := strings.Split(pending, "\n")
+		pending = lines[len(lines)-1]
+		lines = lines[:len(lines)-1]
+		if done && pending != "" {
+			lines = append(lines, pending)
+			pending = ""
+		}
+		matched := add(lines)
+		f.Elapsed = time.Since(start)
+		switch {
+		case matched:
+			f.Stopped = fmt.Sprintf("a line matched %q", until.String())
+			return f, nil
+		case done:
+			f.Stopped = "the shell exited"
+			return f, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			// A partial last line counts once time is up
+			var last []string
+			if pending != "" {
+				last = []string{pending}
+			}
+			if add(last) {
+				f.Stopped = fmt.Sprintf("a line matched %q", until.String())
+			} else if until != nil {
+				f.Stopped = fmt.Sprintf("no line matched %q within %s", until.String(), d)
+			} else {
+				f.Stopped = fmt.Sprintf("followed for %s", d)
+			}
+			f.Elapsed = time.Since(start)
+			return f, nil
+		case <-ctx.Done():
+			f.Stopped = "following was cancelled"
+			return f, nil
+		}
+	}
+}
+
+// followArgs reads the follow and until parameters of Read and BashOutput.
+// follow is 0 when the call does not follow.
+func followArgs(args map[string]interface{}) (time.Duration, *regexp.Regexp, error) {
+	var d time.Duration
+	if v, ok := args["follow"].(float64); ok && v > 0 {
+		d = time.Duration(v * float64(time.Second))
+	}
+	var until *regexp.Regexp
+	if pattern, _ := args["until"].(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return 0, nil, fmt.Errorf("until is not a valid regular expression: %w", err)
+		}
+		until = re
+		if d == 0 {
+			d = defaultFollow
+		}
+	}
+	return min(d, maxFollow), until, nil
+}
+
+// Summary says how following ended and how many lines it saw
+func (f Followed) Summary() string {
+	return fmt.Sprintf("stopped after %.1fs: %s; saw %s", f.Elapsed.Seconds(), f.Stopped, plural(len(f.Lines)+f.Dropped, "line"))
+}
+
+// describeFollowed reports what Follow saw, numbering lines from first when
+// it is positive
+func describeFollowed(f Followed, first int) string {
+	var sb strings.Builder
+	sb.WriteString("Followed the file and " + f.Summary() + ".\n")
+	if f.Dropped > 0 {
+		sb.WriteString(fmt.Sprintf("...[%d earlier lines dropped]...\n", f.Dropped))
+	}
+	for i, line := range f.Lines {
+		if len(line) > maxReadLineLength {
+			line = line[:maxReadLineLength] + "...[line truncated]"
+		}
+		if first > 0 {
+			sb.WriteString(fmt.Sprintf("%6d\t%s\n", first+f.Dropped+i, line))
+		} else {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
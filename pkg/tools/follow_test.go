@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFollowsAppendedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	os.WriteFile(path, []byte("old 1\nold 2\n"), 0644)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		f.WriteString("starting\nlistening on :8080\nnever seen\n")
+		f.Close()
+	}()
+
+	out, err := (&ReadTool{}).Execute(context.Background(), map[string]interface{}{
+		"file_path": path, "follow": float64(5), "until": "listening on",
+	})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	for _, want := range []string{`a line matched "listening on"; saw 2 lines`, "     3\tstarting\n     4\tlistening on :8080\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "old 1") || strings.Contains(out, "never seen") {
+		t.Errorf("output has lines from before or after following:\n%s", out)
+	}
+}
+
+func TestBashOutputWaitsForReadyLine(t *testing.T) {
+	GlobalShellManager.processes = make(map[string]*BackgroundProcess)
+	out, err := NewBashTool().Execute(context.Background(), map[string]interface{}{
+		"command": "echo booting; sleep 0.3; echo ready; sleep 5", "run_in_background": true,
+	})
+	if err != nil {
+		t.Fatalf("Bash: %v", err)
+	}
+	id := strings.Split(strings.Split(out, "ID ")[1], ".")[0]
+	defer GlobalShellManager.Kill(id)
+
+	start := time.Now()
+	out, err = (&BashOutputTool{}).Execute(context.Background(), map[string]interface{}{"shell_id": id, "until": "^ready$"})
+	if err != nil {
+		t.Fatalf("BashOutput: %v", err)
+	}
+	if !strings.Contains(out, `a line matched "^ready$"`) || !strings.Contains(out, "booting\nready\n") {
+		t.Errorf("output:\n%s", out)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("waited %s for a line printed after 0.3s", elapsed)
+	}
+
+	// Another call finds the line already printed at once
+	start = time.Now()
+	out, _ = (&BashOutputTool{}).Execute(context.Background(), map[string]interface{}{"shell_id": id, "until": "^ready$"})
+	if time.Since(start) > time.Second || !strings.Contains(out, "matched") {
+		t.Errorf("second wait took %s:\n%s", time.Since(start), out)
+	}
+}
@@ -1,12 +1,16 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // ReadTool
@@ -20,9 +24,11 @@ func (t *ReadTool) Definition() ToolDefinition {
 - Reads up to 2000 lines by default from beginning
 - Use offset to skip lines from the start
 - Use limit to control how many lines to read
-- Use tail to read from the END of the file (useful for logs/large files)
+- Use tail to read from the END of the file (useful for logs/large files); large files are read a line at a time, so this is cheap
 - Lines longer than 2000 chars are truncated
 - Can read images (PNG, JPG), PDFs, and Jupyter notebooks
+- Use follow (seconds) and/or until (a regex) to watch a log like tail -f, getting the lines appended meanwhile
+- Lists the contents of zip, jar, wheel and tar(.gz/.bz2) archives; pass member to extract one to a temp file and read it
 - Cannot read directories (use ls via Bash for that)
 - Call multiple Read operations in parallel when useful
 - If file exists but is empty, receive a warning
@@ -46,6 +52,18 @@ func (t *ReadTool) Definition() ToolDefinition {
 					"type":        "integer",
 					"description": "Read the last N lines of the file (overrides offset/limit). Useful for logs and large files.",
 				},
+				"member": map[string]interface{}{
+					"type":        "string",
+					"description": "For an archive, the path of the member to extract and read, as listed by reading the archive",
+				},
+				"follow": map[string]interface{}{
+					"type":        "number",
+					"description": "Seconds to watch the file for appended lines, like tail -f (at most 300). Returns only the new lines.",
+				},
+				"until": map[string]interface{}{
+					"type":        "string",
+					"description": "Regular expression: stop watching as soon as an appended line matches it. Watches for 30 seconds unless follow is given.",
+				},
 			},
 			"required": []string{"file_path"},
 		},
@@ -72,45 +90,180 @@ func (t *ReadTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		tail = int(v)
 	}
 
-	content, err := ioutil.ReadFile(path)
+	member, _ := args["member"].(string)
+	follow, until, err := followArgs(args)
 	if err != nil {
 		return "", err
 	}
+	if follow > 0 {
+		if Target.Remote() || member != "" {
+			return "", fmt.Errorf("follow watches files on this machine; run tail -f in a background Bash shell and follow that instead")
+		}
+		return followFile(ctx, path, follow, until)
+	}
 
-	lines := strings.Split(string(content), "\n")
-	totalLines := len(lines)
+	var content []byte
+	if Target.Remote() {
+		data, err := Target.ReadFile(ctx, Target.Path(path))
+		if err != nil {
+			return "", err
+		}
+		content = data
+		if member == "" {
+			Versions.Record(Target.Path(path), string(data))
+		}
+	} else {
+		// Local files are read a line at a time, so the start or end of a
+		// huge log does not load all of it; only archives are read whole
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("%s is a directory; list it with ls via Bash", path)
+		}
+		r := bufio.NewReaderSize(f, 64<<10)
+		head, _ := r.Peek(4)
+		if archiveKind(path, head) == "" && member == "" {
+			out, err := numberLinesFrom(r, offset, limit, tail)
+			if err == nil && info.Size() > largeFileSize {
+				out += fmt.Sprintf("[%s is %d MB: read its end with tail, a window with offset and limit, or search it with Grep rather than paging through it]\n", path, info.Size()>>20)
+			}
+			if err == nil && info.Size() <= maxVersionSize {
+				if data, err := os.ReadFile(path); err == nil {
+					Versions.Record(path, string(data))
+				}
+			}
+			return out, err
+		}
+		if info.Size() > maxWholeReadSize {
+			return "", fmt.Errorf("%s is %d MB, more than the %d MB Read loads to open an archive; list it with unzip -l or tar -tvf via Bash",
+				path, info.Size()>>20, maxWholeReadSize>>20)
+		}
+		if content, err = io.ReadAll(r); err != nil {
+			return "", err
+		}
+	}
+
+	if kind := archiveKind(path, content); kind != "" {
+		if member == "" {
+			return listArchive(path, kind, content)
+		}
+		extracted, data, err := extractMember(kind, content, member)
+		if err != nil {
+			return "", err
+		}
+		note := fmt.Sprintf("Extracted %s from %s to %s (%d bytes).\n", member, path, extracted, len(data))
+		if looksBinary(data) || archiveKind(member, data) != "" {
+			return note + "It is binary; Read that path to list it if it is an archive, or inspect it with Bash.\n", nil
+		}
+		return note + "\n" + numberLines(data, offset, limit, tail), nil
+	} else if member != "" {
+		return "", fmt.Errorf("%s is not a zip or tar archive, so it has no member %q", path, member)
+	}
+	return numberLines(content, offset, limit, tail), nil
+}
+
+// followFile reads the lines appended to path while it is followed
+func followFile(ctx context.Context, path string, d time.Duration, until *regexp.Regexp) (string, error) {
+	source, err := FollowFile(path)
+	if err != nil {
+		return "", err
+	}
+	// New text continues the last line, whose number is the line count
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	first, err := (&lineReader{r: bufio.NewReaderSize(f, 64<<10)}).count()
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	followed, err := Follow(ctx, source, d, until, nil)
+	if err != nil {
+		return "", err
+	}
+	return describeFollowed(followed, first), nil
+}
+
+const (
+	maxReadLineLength = 2000      // Longer lines are cut
+	maxWholeReadSize  = 256 << 20 // Archives larger than this are not loaded
+	largeFileSize     = 64 << 20  // Reads of larger files get a hint on reading less
+)
+
+// numberLines formats the selected lines of a file with their line numbers
+func numberLines(content []byte, offset, limit, tail int) string {
+	out, _ := numberLinesFrom(bytes.NewReader(content), offset, limit, tail)
+	return out
+}
+
+// numberLinesFrom formats the selected lines read from r with their line
+// numbers. It keeps only the lines it returns, and counts the rest without
+// holding them, so memory stays small however large the file is.
+func numberLinesFrom(r io.Reader, offset, limit, tail int) (string, error) {
+	lines := &lineReader{r: bufio.NewReaderSize(r, 64<<10)}
 
 	var selectedLines []string
-	var startLineNum int
+	var startLineNum, totalLines int
 	truncatedStart := false
 	truncatedEnd := false
 
 	if tail > 0 {
-		// Read from end of file
-		if tail >= totalLines {
-			selectedLines = lines
-			startLineNum = 1
-		} else {
-			startLineNum = totalLines - tail + 1
-			selectedLines = lines[totalLines-tail:]
-			truncatedStart = true
+		// Read from end of file, keeping the last tail lines in a ring
+		ring := make([]string, 0, min(tail, 4096))
+		next := 0
+		for {
+			line, ok, err := lines.next()
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				break
+			}
+			totalLines++
+			if len(ring) < tail {
+				ring = append(ring, line)
+			} else {
+				ring[next] = line
+				next = (next + 1) % tail
+			}
 		}
+		selectedLines = append(ring[next:], ring[:next]...)
+		startLineNum = totalLines - len(selectedLines) + 1
+		truncatedStart = startLineNum > 1
 	} else {
 		// Read from beginning with offset/limit
+		for totalLines < offset+limit {
+			line, ok, err := lines.next()
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				break
+			}
+			totalLines++
+			if totalLines > offset {
+				selectedLines = append(selectedLines, line)
+			}
+		}
+		rest, err := lines.count()
+		if err != nil {
+			return "", err
+		}
+		totalLines += rest
 		if offset >= totalLines {
 			return fmt.Sprintf("File has %d lines, offset %d is beyond end of file", totalLines, offset), nil
 		}
 		startLineNum = offset + 1
-		endIdx := offset + limit
-		if endIdx > totalLines {
-			endIdx = totalLines
-		} else {
-			truncatedEnd = true
-		}
-		if offset > 0 {
-			truncatedStart = true
-		}
-		selectedLines = lines[offset:endIdx]
+		truncatedStart = offset > 0
+		truncatedEnd = rest > 0
 	}
 
 	var sb strings.Builder
@@ -118,26 +271,82 @@ func (t *ReadTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		sb.WriteString(fmt.Sprintf("...[Skipped %d lines]...\n", startLineNum-1))
 	}
 	for i, line := range selectedLines {
-		lineNum := startLineNum + i
-		// Truncate very long lines
-		if len(line) > 2000 {
-			line = line[:2000] + "...[line truncated]"
-		}
-		sb.WriteString(fmt.Sprintf("%6d\t%s\n", lineNum, line))
+		sb.WriteString(fmt.Sprintf("%6d\t%s\n", startLineNum+i, line))
 	}
 	if truncatedEnd {
 		remaining := totalLines - (startLineNum - 1 + len(selectedLines))
-		if remaining > 0 {
-			sb.WriteString(fmt.Sprintf("...[%d more lines, use offset=%d to continue]...\n", remaining, startLineNum-1+len(selectedLines)))
-		}
+		sb.WriteString(fmt.Sprintf("...[%d more lines, use offset=%d to continue]...\n", remaining, startLineNum-1+len(selectedLines)))
 	}
 	sb.WriteString(fmt.Sprintf("\n[Total: %d lines in file]\n", totalLines))
 
 	return sb.String(), nil
 }
 
+// lineReader splits a file into lines the way strings.Split(content, "\n")
+// does, so a file ending in a newline has an empty last line, and keeps at
+// most maxReadLineLength bytes of each
+type lineReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+// next returns the next line, or false at the end of the file
+func (lr *lineReader) next() (string, bool, error) {
+	if lr.done {
+		return "", false, nil
+	}
+	var line []byte
+	size := 0
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+		if room := maxReadLineLength - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		size += len(chunk)
+		switch err {
+		case nil:
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			lr.done = true
+		default:
+			return "", false, err
+		}
+		if size > maxReadLineLength {
+			return string(line) + "...[line truncated]", true, nil
+		}
+		return string(line), true, nil
+	}
+}
+
+// count returns the number of lines left without keeping them
+func (lr *lineReader) count() (int, error) {
+	if lr.done {
+		return 0, nil
+	}
+	// The piece after the last newline is a line too
+	n := 1
+	buf := make([]byte, 64<<10)
+	for {
+		read, err := lr.r.Read(buf)
+		n += bytes.Count(buf[:read], []byte("\n"))
+		if err == io.EOF {
+			lr.done = true
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
 // WriteTool
-type WriteTool struct{}
+type WriteTool struct {
+	parts partWrites // Files being written in several parts
+}
 
 func (t *WriteTool) Definition() ToolDefinition {
 	return ToolDefinition{
@@ -148,7 +357,8 @@ func (t *WriteTool) Definition() ToolDefinition {
 - ALWAYS prefer editing existing files over creating new ones
 - NEVER proactively create documentation files (*.md) or READMEs unless explicitly requested
 - Only use emojis if user explicitly requests it
-- Must use absolute paths, not relative`,
+- Must use absolute paths, not relative
+- For very large files (more than about 500 lines), write in parts: send the file in order with part 1, 2, 3... and set final: true on the last part. Nothing is written until the final part; the assembled file is then checked and written at once. Sending part 1 again starts over`,
 		Schema: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -160,6 +370,14 @@ func (t *WriteTool) Definition() ToolDefinition {
 					"type": "string",
                     "description": "The content to write to the file",
 				},
+				"part": map[string]interface{}{
+					"type":        "integer",
+					"description": "For a file written in parts: this part's number, starting at 1. Content is appended to the earlier parts exactly as given.",
+				},
+				"final": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Set on the last part to write the assembled file.",
+				},
 			},
 			"required": []string{"file_path", "content"},
 		},
@@ -175,12 +393,24 @@ func (t *WriteTool) Execute(ctx context.Context, args map[string]interface{}) (s
 	if !ok {
 		return "", fmt.Errorf("content required")
 	}
+	path = Target.Path(path)
+	unlock, err := Files.Lock(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if part, ok := args["part"].(float64); ok {
+		final, _ := args["final"].(bool)
+		return t.parts.writePart(ctx, path, int(part), final, content)
+	}
 
-	err := ioutil.WriteFile(path, []byte(content), 0644)
+	content, note := fixupFile(ctx, path, content, 0, len(content))
+	err = Target.WriteFile(ctx, path, []byte(content))
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Successfully wrote to %s", path), nil
+	Versions.Record(path, content)
+	return fmt.Sprintf("Successfully wrote to %s%s", path, note), nil
 }
 
 // GlobTool
@@ -220,6 +450,16 @@ func (t *GlobTool) Execute(ctx context.Context, args map[string]interface{}) (st
     // For MVP, I'll stick to filepath.Glob if user doesn't use **.
     // If they use **, I'll do a simple walk.
     
+    // A remote target's shell expands the pattern, ** included
+    if Target.Remote() {
+        script := "shopt -s globstar nullglob; for f in " + globPattern(pattern) + "; do printf '%s\\n' \"$f\"; done"
+        out, err := Target.Command(ctx, script, "", nil).Output()
+        if err != nil {
+            return "", fmt.Errorf("glob on %s failed: %w", Target.Name(), err)
+        }
+        return strings.TrimRight(string(out), "\n"), nil
+    }
+
     var matches []string
     if strings.Contains(pattern, "**") {
         // Simplistic recursive search
@@ -250,7 +490,11 @@ func (t *GlobTool) Execute(ctx context.Context, args map[string]interface{}) (st
 }
 
 // EditTool
-type EditTool struct{}
+type EditTool struct {
+    // Resolve asks the user what to do when the file changed on disk near
+    // the edit since the model read it; nil keeps the file on disk
+    Resolve func(ctx context.Context, c *EditConflict) ConflictChoice
+}
 
 func (t *EditTool) Definition() ToolDefinition {
     return ToolDefinition{
@@ -292,12 +536,42 @@ func (t *EditTool) Execute(ctx context.Context, args map[string]interface{}) (st
     newStr, ok := args["new_string"].(string)
     if !ok { return "", fmt.Errorf("new_string required") }
 
-    contentBytes, err := ioutil.ReadFile(path)
+    // Held until the edit is written, so concurrent edits apply in turn
+    path = Target.Path(path)
+    unlock, err := Files.Lock(ctx, path)
+    if err != nil {
+        return "", err
+    }
+    defer unlock()
+
+    contentBytes, err := Target.ReadFile(ctx, path)
     if err != nil {
         return "", err
     }
     content := string(contentBytes)
 
+    // The model edits the file it last saw; if it changed on disk since,
+    // the edit may need merging
+    if seen, ok := Versions.Seen(path); ok && seen != content && strings.Count(seen, oldStr) == 1 {
+        c := &EditConflict{Path: path, Seen: seen, Disk: content, Expected: strings.Replace(seen, oldStr, newStr, 1)}
+        at := -1
+        if strings.Count(content, oldStr) == 1 {
+            at = strings.Index(content, oldStr)
+            c.Applied = content[:at] + newStr + content[at+len(oldStr):]
+        }
+        merged, conflictNote, err := t.resolveConflict(ctx, c, at, len(oldStr))
+        if err != nil {
+            return "", err
+        }
+        start := max(strings.Index(merged, newStr), 0)
+        merged, note := fixupFile(ctx, path, merged, start, start+len(newStr))
+        if err := Target.WriteFile(ctx, path, []byte(merged)); err != nil {
+            return "", err
+        }
+        Versions.Record(path, merged)
+        return fmt.Sprintf("Successfully edited %s%s%s", path, conflictNote, note), nil
+    }
+
     if !strings.Contains(content, oldStr) {
         return "", fmt.Errorf("old_string not found in file")
     }
@@ -307,11 +581,14 @@ func (t *EditTool) Execute(ctx context.Context, args map[string]interface{}) (st
         return "", fmt.Errorf("old_string is not unique in file")
     }
 
-    newContent := strings.Replace(content, oldStr, newStr, 1)
-    err = ioutil.WriteFile(path, []byte(newContent), 0644)
+    at := strings.Index(content, oldStr)
+    newContent := content[:at] + newStr + content[at+len(oldStr):]
+    newContent, note := fixupFile(ctx, path, newContent, at, at+len(newStr))
+    err = Target.WriteFile(ctx, path, []byte(newContent))
     if err != nil {
         return "", err
     }
+    Versions.Record(path, newContent)
 
-    return fmt.Sprintf("Successfully edited %s", path), nil
+    return fmt.Sprintf("Successfully edited %s%s", path, note), nil
 }
@@ -1,9 +1,14 @@
 package tools
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"os"
 	"path/filepath"
+	"strconv"
     "strings"
 	"testing"
 )
@@ -99,3 +104,152 @@ func TestFSTools(t *testing.T) {
          t.Errorf("Glob found ignore.txt but shouldn't have. Got: %s", globOut)
     }
 }
+
+func TestReadListsAndExtractsArchives(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, _ := zw.Create("pkg/README.md")
+	w.Write([]byte("# Package\nsecond line\n"))
+	zw.Close()
+	zipPath := filepath.Join(dir, "dist.whl")
+	os.WriteFile(zipPath, zipBuf.Bytes(), 0644)
+
+	var tarBuf bytes.Buffer
+	gz := gzip.NewWriter(&tarBuf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "package/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "package/index.js", Size: 13, Mode: 0644})
+	tw.Write([]byte("module.export"))
+	tw.Close()
+	gz.Close()
+	tarPath := filepath.Join(dir, "dep-1.0.0.tgz")
+	os.WriteFile(tarPath, tarBuf.Bytes(), 0644)
+
+	read := &ReadTool{}
+	out, err := read.Execute(ctx, map[string]interface{}{"file_path": zipPath})
+	if err != nil || !strings.Contains(out, "zip archive with 1 files") || !strings.Contains(out, "pkg/README.md") {
+		t.Errorf("zip listing = %q, %v", out, err)
+	}
+	out, err = read.Execute(ctx, map[string]interface{}{"file_path": zipPath, "member": "pkg/README.md"})
+	if err != nil || !strings.Contains(out, "     2\tsecond line") {
+		t.Errorf("zip member = %q, %v", out, err)
+	}
+
+	out, err = read.Execute(ctx, map[string]interface{}{"file_path": tarPath})
+	if err != nil || !strings.Contains(out, "tar.gz archive") || !strings.Contains(out, "dir  package/") {
+		t.Errorf("tar listing = %q, %v", out, err)
+	}
+	out, err = read.Execute(ctx, map[string]interface{}{"file_path": tarPath, "member": "./package/index.js"})
+	if err != nil || !strings.Contains(out, "     1\tmodule.export") {
+		t.Errorf("tar member = %q, %v", out, err)
+	}
+	if _, err := read.Execute(ctx, map[string]interface{}{"file_path": tarPath, "member": "missing.js"}); err == nil {
+		t.Error("extracting a missing member succeeded")
+	}
+}
+
+func TestReadStreamsLinesOfLargeFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var sb strings.Builder
+	for i := 1; i <= 100000; i++ {
+		sb.WriteString("entry " + strconv.Itoa(i) + "\n")
+	}
+	sb.WriteString(strings.Repeat("x", 70000)) // A last line longer than the read buffer
+	os.WriteFile(path, []byte(sb.String()), 0644)
+
+	read := func(args map[string]interface{}) string {
+		t.Helper()
+		args["file_path"] = path
+		out, err := (&ReadTool{}).Execute(context.Background(), args)
+		if err != nil {
+			t.Fatalf("Read %v: %v", args, err)
+		}
+		return out
+	}
+
+	out := read(map[string]interface{}{"offset": float64(10), "limit": float64(2)})
+	want := "...[Skipped 10 lines]...\n    11\tentry 11\n    12\tentry 12\n...[99989 more lines, use offset=12 to continue]...\n\n[Total: 100001 lines in file]\n"
+	if out != want {
+		t.Errorf("offset/limit read:\n%s\nwant:\n%s", out, want)
+	}
+
+	out = read(map[string]interface{}{"tail": float64(2)})
+	if !strings.HasPrefix(out, "...[Skipped 99999 lines]...\n100000\tentry 100000\n100001\t"+strings.Repeat("x", 2000)+"...[line truncated]\n") {
+		t.Errorf("tail read starts %q", out[:min(len(out), 200)])
+	}
+
+	if out := read(map[string]interface{}{"offset": float64(200000)}); out != "File has 100001 lines, offset 200000 is beyond end of file" {
+		t.Errorf("read past the end = %q", out)
+	}
+}
+
+func TestEditConflicts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app.py")
+	original := "import os\n\ndef main():\n    print('hi')\n\ndef helper():\n    return 1\n"
+	reset := func() {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (&ReadTool{}).Execute(ctx, map[string]interface{}{"file_path": path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit := func(tool *EditTool) (string, error) {
+		return tool.Execute(ctx, map[string]interface{}{
+			"file_path": path, "old_string": "    print('hi')\n", "new_string": "    print('hello')\n",
+		})
+	}
+	disk := func() string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	// A change away from the edit merges without asking
+	reset()
+	os.WriteFile(path, []byte(strings.Replace(original, "return 1", "return 2", 1)), 0644)
+	asked := false
+	out, err := edit(&EditTool{Resolve: func(context.Context, *EditConflict) ConflictChoice { asked = true; return ConflictKeep }})
+	if err != nil || asked || !strings.Contains(out, "away from this edit") {
+		t.Fatalf("distant change: asked %v, %q, %v", asked, out, err)
+	}
+	if got := disk(); !strings.Contains(got, "hello") || !strings.Contains(got, "return 2") {
+		t.Errorf("merged file:\n%s", got)
+	}
+
+	// A change next to the edit is the user's call
+	near := strings.Replace(original, "def main():", "def main(argv):", 1)
+	var conflict *EditConflict
+	for _, c := range []struct {
+		choice ConflictChoice
+		want   string
+	}{
+		{ConflictApply, "def main(argv):\n    print('hello')"},
+		{ConflictOverwrite, "def main():\n    print('hello')"},
+		{ConflictKeep, near},
+	} {
+		reset()
+		os.WriteFile(path, []byte(near), 0644)
+		_, err := edit(&EditTool{Resolve: func(_ context.Context, ec *EditConflict) ConflictChoice { conflict = ec; return c.choice }})
+		if (err != nil) != (c.choice == ConflictKeep) {
+			t.Errorf("choice %d: err = %v", c.choice, err)
+		}
+		if got := disk(); !strings.Contains(got, c.want) {
+			t.Errorf("choice %d left:\n%s", c.choice, got)
+		}
+	}
+	if conflict == nil || !strings.Contains(conflict.DiskChanges(), "+def main(argv):") || !strings.Contains(conflict.Proposed(), "+    print('hello')") {
+		t.Errorf("conflict views: %+v", conflict)
+	}
+
+	// Without anyone to ask the file is kept, and the model sees what changed
+	reset()
+	os.WriteFile(path, []byte(near), 0644)
+	if _, err := edit(&EditTool{}); err == nil || !strings.Contains(err.Error(), "+def main(argv):") {
+		t.Errorf("unattended conflict: %v", err)
+	}
+}
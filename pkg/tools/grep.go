@@ -3,11 +3,20 @@ package tools
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/target"
 )
 
 type GrepTool struct{}
 
+// SearchIgnores are globs of directories Grep skips unless its path points
+// inside them, set from the pre-flight scan of large repositories
+var SearchIgnores []string
+
 func (t *GrepTool) Definition() ToolDefinition {
 	return ToolDefinition{
 		Name:        "Grep",
@@ -60,7 +69,7 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
 
 	// Check if rg exists
 	_, err := exec.LookPath("rg")
-    if err != nil {
+    if err != nil && !Target.Remote() {
         // Fallback to grep? Or error?
         // Let's try standard grep if rg is missing, but rg features are requested...
         // For now, just error saying ripgrep is required
@@ -74,12 +83,38 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
     if globArg != "" {
         cmdArgs = append(cmdArgs, "-g", globArg)
     }
+    cwd, cwdErr := os.Getwd()
+    if Target.Remote() {
+        pathArg, cwd, cwdErr = Target.Path(pathArg), Target.Dir(), nil
+    }
+    searchPath := pathArg
+    if cwdErr == nil && filepath.IsAbs(searchPath) {
+        if rel, err := filepath.Rel(cwd, searchPath); err == nil {
+            searchPath = rel
+        }
+    }
+    searchPath = filepath.ToSlash(filepath.Clean(searchPath))
+    for _, ignore := range SearchIgnores {
+        dir := strings.TrimSuffix(ignore, "/**")
+        if searchPath != dir && !strings.HasPrefix(searchPath, dir+"/") {
+            cmdArgs = append(cmdArgs, "-g", "!"+ignore)
+        }
+    }
     
     cmdArgs = append(cmdArgs, "--line-number", "--no-heading")
     cmdArgs = append(cmdArgs, pattern)
     cmdArgs = append(cmdArgs, pathArg)
 
-    cmd := exec.CommandContext(ctx, "rg", cmdArgs...)
+    var cmd *exec.Cmd
+    if Target.Remote() {
+        quoted := make([]string, len(cmdArgs))
+        for i, arg := range cmdArgs {
+            quoted[i] = target.Quote(arg)
+        }
+        cmd = Target.Command(ctx, "rg "+strings.Join(quoted, " "), "", nil)
+    } else {
+        cmd = exec.CommandContext(ctx, "rg", cmdArgs...)
+    }
     out, err := cmd.CombinedOutput()
     
     // grep returns exit code 1 if no matches, which is not an error for us
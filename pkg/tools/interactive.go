@@ -10,6 +10,12 @@ type UserPrompter interface {
     Prompt(string) string
 }
 
+// SecretPrompter is implemented by prompters that can read input without
+// echoing it
+type SecretPrompter interface {
+    PromptSecret(string) string
+}
+
 // AskUserQuestionTool
 type AskUserQuestionTool struct {
     ui UserPrompter
@@ -24,7 +30,8 @@ func (t *AskUserQuestionTool) Definition() ToolDefinition {
 		Name:        "AskUserQuestion",
 		Description: `Ask user questions during execution.
 - Use to gather preferences/requirements, clarify ambiguous instructions, get decisions on implementation choices
-- Users can always select "Other" for custom text input`,
+- Users can always select "Other" for custom text input
+- To ask for a password, API token or other credential, set secret to true and name it with secret_name. The input is masked and never shown to you; it becomes the session variable secret_name. Use it as "$NAME" in Bash commands, or write {{secret:NAME}} in Write, Edit and exec tool arguments to have it substituted when the tool runs, after the user confirms. Other tools, such as WebFetch and MCP tools, never get the value. Never try to print it`,
 		Schema: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -32,6 +39,14 @@ func (t *AskUserQuestionTool) Definition() ToolDefinition {
 					"type":        "string",
 					"description": "The question to ask the user.",
 				},
+				"secret": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Mask the input and keep the answer out of the conversation.",
+				},
+				"secret_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Environment variable name to store a secret answer under, e.g. GITHUB_TOKEN.",
+				},
 			},
 			"required": []string{"question"},
 		},
@@ -44,6 +59,10 @@ func (t *AskUserQuestionTool) Execute(ctx context.Context, args map[string]inter
 		return "", fmt.Errorf("question required")
 	}
 
+    if secret, _ := args["secret"].(bool); secret {
+        return t.askSecret(question, args)
+    }
+
     // Use the UI to prompt the user
     // We need a way to interrupt the stream/display a specific prompt.
     // The UI.Prompt method is synchronous and waits for input, which is what we want.
@@ -53,3 +72,29 @@ func (t *AskUserQuestionTool) Execute(ctx context.Context, args map[string]inter
     
     return answer, nil
 }
+
+// askSecret reads a masked answer and stores it in SessionEnv as a secret.
+// The model only learns the variable's name.
+func (t *AskUserQuestionTool) askSecret(question string, args map[string]interface{}) (string, error) {
+    name, _ := args["secret_name"].(string)
+    if name == "" {
+        return "", fmt.Errorf("secret_name is required when secret is true")
+    }
+    if !envNamePattern.MatchString(name) {
+        return "", fmt.Errorf("secret_name %q is not a valid environment variable name", name)
+    }
+    sp, ok := t.ui.(SecretPrompter)
+    if !ok {
+        return "", fmt.Errorf("this session cannot read masked input; ask the user to run /env set %s=...", name)
+    }
+
+    t.ui.Print(fmt.Sprintf("\n[Secret] %s (input hidden, kept as the secret %s for this session)", question, name))
+    value := sp.PromptSecret("> ")
+    if value == "" {
+        return fmt.Sprintf("The user did not enter a value for %s.", name), nil
+    }
+    if err := SessionEnv.SetSecret(name, value); err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("The user entered the secret. Write {{secret:%s}} where it is needed in Bash, Write, Edit and exec tool arguments; the user confirms each such call. It is not in the environment as $%s. Do not print or echo it.", name, name), nil
+}
@@ -86,6 +86,12 @@ func (t *NotebookEditTool) Execute(ctx context.Context, args map[string]interfac
     cellType, _ := args["cell_type"].(string)
     if cellType == "" { cellType = "code" }
 
+    unlock, err := Files.Lock(ctx, path)
+    if err != nil {
+        return "", err
+    }
+    defer unlock()
+
     content, err := ioutil.ReadFile(path)
     if err != nil {
         return "", err
@@ -142,6 +148,7 @@ func (t *NotebookEditTool) Execute(ctx context.Context, args map[string]interfac
     if err := ioutil.WriteFile(path, newContent, 0644); err != nil {
         return "", err
     }
+    Versions.Record(path, string(newContent))
 
     return "Notebook updated successfully.", nil
 }
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package tools
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd as the leader of a new process group, so the
+// children it spawns (npm spawning node, say) can be signalled together
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd, true)
+	}
+}
+
+// killProcessGroup signals every process in cmd's group: SIGTERM to let
+// them clean up, or SIGKILL when force is set
+func killProcessGroup(cmd *exec.Cmd, force bool) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return signalProcessGroup(cmd.Process.Pid, force)
+}
+
+// signalProcessGroup signals the process group led by pid
+func signalProcessGroup(pid int, force bool) error {
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+	// A negative pid addresses the whole group
+	err := syscall.Kill(-pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil // Already gone
+	}
+	return err
+}
+
+// processGroupAlive reports whether any process of the group led by pid
+// is left
+func processGroupAlive(pid int) bool {
+	err := syscall.Kill(-pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// processAlive reports whether a process with this pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
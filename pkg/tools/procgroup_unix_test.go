@@ -0,0 +1,145 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alive reports whether pid is running. Orphans reparented to an init that
+// does not reap them linger as zombies, which count as gone.
+func alive(pid int) bool {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		p, err := os.FindProcess(pid)
+		return err == nil && p.Signal(nil) == nil
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) > 2 && fields[2] != "Z"
+}
+
+func TestKillShellStopsChildren(t *testing.T) {
+	GlobalShellManager.processes = make(map[string]*BackgroundProcess)
+
+	out, err := NewBashTool().Execute(context.Background(), map[string]interface{}{
+		"command":           "sleep 60 & echo child=$!; wait",
+		"run_in_background": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSuffix(strings.Fields(strings.SplitN(out, "ID ", 2)[1])[0], ".")
+
+	var pid int
+	for i := 0; i < 50 && pid == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		output, _, _ := GlobalShellManager.GetOutput(id)
+		if _, after, ok := strings.Cut(output, "child="); ok {
+			pid, _ = strconv.Atoi(strings.TrimSpace(after))
+		}
+	}
+	if pid == 0 {
+		t.Fatal("background shell did not report its child")
+	}
+
+	if _, err := (&KillShellTool{}).Execute(context.Background(), map[string]interface{}{"shell_id": id}); err != nil {
+		t.Fatalf("KillShell failed: %v", err)
+	}
+	for i := 0; i < 50 && alive(pid); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if alive(pid) {
+		t.Errorf("child %d of the killed shell is still running", pid)
+	}
+}
+
+func TestKillShellGivesChildrenTimeToCleanUp(t *testing.T) {
+	GlobalShellManager.processes = make(map[string]*BackgroundProcess)
+	cleaned := filepath.Join(t.TempDir(), "cleaned")
+
+	// The shell exits on SIGTERM at once; its child, writing elsewhere, takes
+	// a moment to clean up
+	command := `sh -c 'trap "sleep 0.3; touch ` + cleaned + `; exit 0" TERM; while :; do sleep 0.05; done' >/dev/null 2>&1 & sleep 0.2; echo ready; wait`
+	id := GlobalShellManager.Start(exec.CommandContext(context.Background(), "bash", "-c", command))
+	for i := 0; i < 50; i++ {
+		if output, _, _ := GlobalShellManager.GetOutput(id); strings.Contains(output, "ready") {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := GlobalShellManager.Kill(id); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if _, err := os.Stat(cleaned); err != nil {
+		t.Errorf("child was killed before it cleaned up: %v", err)
+	}
+}
+
+func TestServersListPortsAndStaleShells(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	GlobalShellManager.processes = make(map[string]*BackgroundProcess)
+	dir := t.TempDir()
+	if err := GlobalShellManager.SetStateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { GlobalShellManager.stateFile = "" }()
+
+	command := `python3 -c "import socket,time; s=socket.socket(); s.bind(('127.0.0.1', 0)); s.listen(); print('port=%d' % s.getsockname()[1], flush=True); time.sleep(60)"`
+	id := GlobalShellManager.Start(exec.CommandContext(context.Background(), "bash", "-c", command))
+	defer GlobalShellManager.Kill(id)
+
+	var port int
+	for i := 0; i < 100 && port == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		output, _, _ := GlobalShellManager.GetOutput(id)
+		if _, after, ok := strings.Cut(output, "port="); ok {
+			port, _ = strconv.Atoi(strings.TrimSpace(after))
+		}
+	}
+	if port == 0 {
+		output, _, err := GlobalShellManager.GetOutput(id)
+		t.Fatalf("server did not report its port: %q %v", output, err)
+	}
+
+	servers := GlobalShellManager.Servers()
+	if len(servers) != 1 || servers[0].Command != command || !servers[0].Running {
+		t.Fatalf("unexpected servers: %+v", servers)
+	}
+	if len(servers[0].Ports) != 1 || servers[0].Ports[0] != port {
+		t.Errorf("expected port %d, got %v", port, servers[0].Ports)
+	}
+
+	// The state file of this process is not stale; the same record left by
+	// a john that has exited is
+	own, err := os.ReadFile(GlobalShellManager.stateFile)
+	if err != nil {
+		t.Fatalf("running shell not recorded: %v", err)
+	}
+	if stale := StaleServers(dir); len(stale) != 0 {
+		t.Errorf("shells of a running john are not stale: %+v", stale)
+	}
+	os.WriteFile(filepath.Join(dir, "999999999.json"), own, 0644)
+	stale := StaleServers(dir)
+	if len(stale) != 1 || stale[0].ID != "s1" || stale[0].PID != servers[0].PID {
+		t.Fatalf("expected the shell as stale, got %+v", stale)
+	}
+	if err := KillServer(stale[0]); err != nil {
+		t.Fatal(err)
+	}
+	if stale := StaleServers(dir); len(stale) != 0 {
+		t.Errorf("killed server still listed: %+v", stale)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "999999999.json")); !os.IsNotExist(err) {
+		t.Error("state file with nothing running should be removed")
+	}
+}
@@ -0,0 +1,62 @@
+//go:build windows
+
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a new process group, so the processes it
+// spawns can be stopped together
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd, true)
+	}
+}
+
+// killProcessGroup ends cmd and all its descendants. Console programs
+// started without a window cannot be asked to close, so the tree is always
+// terminated forcefully and force is ignored.
+func killProcessGroup(cmd *exec.Cmd, force bool) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	pid := fmt.Sprint(cmd.Process.Pid)
+	if err := exec.Command("taskkill", "/F", "/T", "/PID", pid).Run(); err != nil {
+		// taskkill also fails when the process has already exited
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+	}
+	return nil
+}
+
+// signalProcessGroup ends the process tree rooted at pid
+func signalProcessGroup(pid int, force bool) error {
+	exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(pid)).Run()
+	return nil
+}
+
+// processGroupAlive reports whether any process of the tree rooted at pid
+// is left. killProcessGroup always ends the tree at once, so none is.
+func processGroupAlive(pid int) bool {
+	return false
+}
+
+// processAlive reports whether a process with this pid exists
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
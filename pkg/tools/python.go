@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// pythonDriver is the bootstrap script run inside the persistent interpreter.
+// It reads one JSON request per line from a private copy of the original
+// stdin and answers with one JSON line on a private copy of the original
+// stdout, so that anything the user's code prints (including output from
+// child processes) cannot corrupt the protocol. Stdin is /dev/null for the
+// user's code, so input() fails at once instead of eating requests.
+const pythonDriver = `
+import sys, os, io, json, ast, traceback, contextlib
+
+requests = os.fdopen(os.dup(0), "r")
+null = os.open(os.devnull, os.O_RDONLY)
+os.dup2(null, 0)
+os.close(null)
+sys.stdin = open(os.devnull)
+
+proto = os.fdopen(os.dup(1), "w")
+os.dup2(2, 1)
+sys.stdout = io.TextIOWrapper(os.fdopen(1, "wb", 0), write_through=True)
+
+ns = {"__name__": "__main__"}
+
+def short_repr(v, limit=200):
+    try:
+        r = repr(v)
+    except Exception as e:
+        r = "<repr failed: %s>" % e
+    return r if len(r) <= limit else r[:limit] + "..."
+
+def execute(code):
+    out, err = io.StringIO(), io.StringIO()
+    result, error = None, None
+    with contextlib.redirect_stdout(out), contextlib.redirect_stderr(err):
+        try:
+            tree = ast.parse(code, "<cell>", "exec")
+            last = None
+            if tree.body and isinstance(tree.body[-1], ast.Expr):
+                last = ast.Expression(tree.body.pop().value)
+            exec(compile(tree, "<cell>", "exec"), ns)
+            if last is not None:
+                value = eval(compile(last, "<cell>", "eval"), ns)
+                if value is not None:
+                    result = short_repr(value, 10000)
+        except BaseException:
+            error = traceback.format_exc()
+    return {"stdout": out.getvalue(), "stderr": err.getvalue(), "result": result, "error": error}
+
+def inspect(name):
+    if name:
+        if name not in ns:
+            return {"error": "name %r is not defined" % name}
+        v = ns[name]
+        doc = (getattr(v, "__doc__", None) or "").strip()
+        return {"result": "%s: %s = %s%s" % (name, type(v).__name__, short_repr(v, 2000), ("\n\n" + doc[:1000]) if doc and callable(v) else "")}
+    lines = []
+    for k, v in ns.items():
+        if k.startswith("_") or type(v).__name__ == "module":
+            continue
+        lines.append("%s: %s = %s" % (k, type(v).__name__, short_repr(v)))
+    return {"result": "\n".join(lines) if lines else "(no user-defined variables)"}
+
+for line in requests:
+    try:
+        req = json.loads(line)
+    except ValueError:
+        continue
+    op = req.get("op")
+    if op == "execute":
+        resp = execute(req.get("code", ""))
+    elif op == "inspect":
+        resp = inspect(req.get("name", ""))
+    else:
+        resp = {"error": "unknown op %r" % op}
+    proto.write(json.dumps(resp) + "\n")
+    proto.flush()
+`
+
+// PythonTool runs code in a persistent Python interpreter. Like BashTool keeps
+// its working directory, this tool keeps the interpreter's namespace alive
+// across calls so data loaded in one call can be explored in the next.
+type PythonTool struct {
+	mu      sync.Mutex
+	python  string
+	session *pythonSession
+}
+
+type pythonSession struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *ThreadSafeBuffer
+	seen   int // bytes of stderr already reported
+}
+
+type pythonResponse struct {
+	Stdout string  `json:"stdout"`
+	Stderr string  `json:"stderr"`
+	Result *string `json:"result"`
+	Error  *string `json:"error"`
+}
+
+func NewPythonTool() *PythonTool {
+	python := "python3"
+	if _, err := exec.LookPath(python); err != nil {
+		python = "python"
+	}
+	return &PythonTool{python: python}
+}
+
+func (t *PythonTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name: "Python",
+		Description: `Executes Python code in a persistent interpreter session, similar to a Jupyter kernel.
+- Variables, imports and loaded data persist across calls until the session is reset
+- Use for data exploration, quick calculations and iterative analysis instead of one-shot scripts
+- The value of a trailing expression is returned, like a notebook cell
+- action=execute (default) runs code, action=inspect lists variables (or describes one with name), action=reset restarts the interpreter
+- Code runs in the current working directory with the user's Python installation
+- If a call times out the interpreter is restarted and all state is lost`,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"execute", "inspect", "reset"},
+					"description": "The operation to perform (default: execute)",
+				},
+				"code": map[string]interface{}{
+					"type":        "string",
+					"description": "The Python code to execute (required for execute)",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Variable to describe when action=inspect. Omit to list all variables.",
+				},
+				"timeout": map[string]interface{}{
+					"type":        "integer",
+					"description": "Timeout in milliseconds (default 120000).",
+				},
+			},
+		},
+	}
+}
+
+func (t *PythonTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	action, _ := args["action"].(string)
+	if action == "" {
+		action = "execute"
+	}
+
+	timeout := 120 * time.Second
+	if v, ok := args["timeout"].(float64); ok && v > 0 {
+		timeout = time.Duration(v) * time.Millisecond
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch action {
+	case "reset":
+		t.closeSession()
+		return "Python session reset. All variables have been cleared.", nil
+	case "inspect":
+		name, _ := args["name"].(string)
+		resp, err := t.send(ctx, map[string]string{"op": "inspect", "name": name}, timeout)
+		if err != nil {
+			return "", err
+		}
+		return formatPythonResponse(resp, ""), nil
+	case "execute":
+		code, ok := args["code"].(string)
+		if !ok {
+			return "", fmt.Errorf("code required")
+		}
+		resp, err := t.send(ctx, map[string]string{"op": "execute", "code": code}, timeout)
+		if err != nil {
+			return "", err
+		}
+		return formatPythonResponse(resp, t.drainStderr()), nil
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+// Close terminates the interpreter, if one is running.
+func (t *PythonTool) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeSession()
+}
+
+func (t *PythonTool) start() error {
+	cmd := exec.Command(t.python, "-u", "-c", pythonDriver)
+	cmd.Dir, _ = os.Getwd()
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr := &ThreadSafeBuffer{}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", t.python, err)
+	}
+
+	t.session = &pythonSession{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: stderr,
+	}
+	return nil
+}
+
+func (t *PythonTool) closeSession() {
+	if t.session == nil {
+		return
+	}
+	t.session.stdin.Close()
+	if t.session.cmd.Process != nil {
+		t.session.cmd.Process.Kill()
+	}
+	t.session.cmd.Wait()
+	t.session = nil
+}
+
+// send writes a request to the interpreter and waits for its reply. The
+// interpreter is started on first use and restarted if it has died.
+func (t *PythonTool) send(ctx context.Context, req map[string]string, timeout time.Duration) (*pythonResponse, error) {
+	if t.session == nil {
+		if err := t.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if _, err := fmt.Fprintf(t.session.stdin, "%s\n", data); err != nil {
+		t.closeSession()
+		return nil, fmt.Errorf("python session is not running: %w", err)
+	}
+
+	type readResult struct {
+		line string
+		err  error
+	}
+	reader := t.session.stdout
+	readCh := make(chan readResult, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		readCh <- readResult{line, err}
+	}()
+
+	select {
+	case r := <-readCh:
+		if r.err != nil {
+			stderr := t.session.stderr.String()
+			t.closeSession()
+			return nil, fmt.Errorf("python session exited unexpectedly: %v\n%s", r.err, stderr)
+		}
+		var resp pythonResponse
+		if err := json.Unmarshal([]byte(r.line), &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse python response: %w", err)
+		}
+		return &resp, nil
+	case <-time.After(timeout):
+		t.closeSession()
+		return nil, fmt.Errorf("python execution timed out after %v; the session was restarted and all state was lost", timeout)
+	case <-ctx.Done():
+		t.closeSession()
+		return nil, ctx.Err()
+	}
+}
+
+// drainStderr returns output written directly to the process's stdout/stderr
+// (e.g. by subprocesses or C extensions) since the last call.
+func (t *PythonTool) drainStderr() string {
+	if t.session == nil {
+		return ""
+	}
+	all := t.session.stderr.String()
+	out := all[t.session.seen:]
+	t.session.seen = len(all)
+	return out
+}
+
+func formatPythonResponse(resp *pythonResponse, processOutput string) string {
+	var sb strings.Builder
+	if resp.Stdout != "" {
+		sb.WriteString(resp.Stdout)
+		if !strings.HasSuffix(resp.Stdout, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	if stderr := resp.Stderr + processOutput; stderr != "" {
+		sb.WriteString("[stderr]\n")
+		sb.WriteString(stderr)
+		if !strings.HasSuffix(stderr, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	if resp.Result != nil {
+		sb.WriteString(*resp.Result)
+		sb.WriteString("\n")
+	}
+	if resp.Error != nil {
+		sb.WriteString("Error:\n")
+		sb.WriteString(*resp.Error)
+	}
+
+	output := sb.String()
+	if output == "" {
+		output = "(no output)"
+	}
+	if len(output) > 30000 {
+		output = cutAtRune(output, 30000) + "\n...[Output Truncated]..."
+	}
+	return output
+}
+
+// cutAtRune returns at most the first n bytes of s, without splitting a
+// UTF-8 sequence
+func cutAtRune(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
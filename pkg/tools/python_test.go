@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPythonTool(t *testing.T) {
+	tool := NewPythonTool()
+	if _, err := exec.LookPath(tool.python); err != nil {
+		t.Skip("python not found in PATH, skipping Python tool test")
+	}
+	defer tool.Close()
+
+	ctx := context.Background()
+
+	// State persists across calls
+	if _, err := tool.Execute(ctx, map[string]interface{}{"code": "x = 40\nprint('set x')"}); err != nil {
+		t.Fatalf("Python execute failed: %v", err)
+	}
+	output, err := tool.Execute(ctx, map[string]interface{}{"code": "x + 2"})
+	if err != nil {
+		t.Fatalf("Python execute failed: %v", err)
+	}
+	if strings.TrimSpace(output) != "42" {
+		t.Errorf("Expected '42', got '%s'", output)
+	}
+
+	// Inspect lists user variables
+	output, err = tool.Execute(ctx, map[string]interface{}{"action": "inspect"})
+	if err != nil {
+		t.Fatalf("Python inspect failed: %v", err)
+	}
+	if !strings.Contains(output, "x: int = 40") {
+		t.Errorf("Expected x in inspect output, got '%s'", output)
+	}
+
+	// Exceptions are reported without killing the session
+	output, err = tool.Execute(ctx, map[string]interface{}{"code": "1/0"})
+	if err != nil {
+		t.Fatalf("Python execute failed: %v", err)
+	}
+	if !strings.Contains(output, "ZeroDivisionError") {
+		t.Errorf("Expected ZeroDivisionError, got '%s'", output)
+	}
+
+	// Reading stdin gets nothing instead of the next requests
+	output, err = tool.Execute(ctx, map[string]interface{}{"code": "import sys, subprocess\nprint(repr(sys.stdin.read()), subprocess.run(['cat'], capture_output=True).stdout)\ninput()"})
+	if err != nil || !strings.Contains(output, "'' b''") || !strings.Contains(output, "EOFError") {
+		t.Errorf("Expected empty stdin and EOFError, got '%s' (%v)", output, err)
+	}
+	if output, _ = tool.Execute(ctx, map[string]interface{}{"code": "x + 2"}); strings.TrimSpace(output) != "42" {
+		t.Errorf("Expected the session to keep working after reading stdin, got '%s'", output)
+	}
+
+	// Reset clears state
+	if _, err := tool.Execute(ctx, map[string]interface{}{"action": "reset"}); err != nil {
+		t.Fatalf("Python reset failed: %v", err)
+	}
+	output, _ = tool.Execute(ctx, map[string]interface{}{"code": "x"})
+	if !strings.Contains(output, "NameError") {
+		t.Errorf("Expected NameError after reset, got '%s'", output)
+	}
+}
+
+func TestCutAtRune(t *testing.T) {
+	if got := cutAtRune("héllo", 2); got != "h" {
+		t.Errorf("cutAtRune split a rune: %q", got)
+	}
+	if got := cutAtRune("héllo", 3); got != "hé" {
+		t.Errorf("cutAtRune(3) = %q", got)
+	}
+	if got := cutAtRune("hi", 10); got != "hi" {
+		t.Errorf("cutAtRune(10) = %q", got)
+	}
+}
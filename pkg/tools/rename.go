@@ -0,0 +1,635 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxRenameDiff bounds the diff returned to the model
+const maxRenameDiff = 30000
+
+// renameTimeout bounds a language server run
+const renameTimeout = 60 * time.Second
+
+var identifierPattern = regexp.MustCompile(`^[\p{L}_$][\p{L}\p{N}_$]*$`)
+
+// tsExtensions are renamed through tsserver when it is installed
+var tsExtensions = map[string]bool{".ts": true, ".tsx": true, ".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".mts": true, ".cts": true}
+
+// skipRenameDirs are never searched by the text-based fallback
+var skipRenameDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, ".john": true}
+
+// RenameSymbolTool renames an identifier across a project. Go goes through
+// gopls and TypeScript/JavaScript through tsserver when they are installed;
+// anything else falls back to a whole-word text replacement, which must be
+// previewed before it can be applied. Edits are written all or nothing.
+type RenameSymbolTool struct {
+	previewed map[string]bool // Fingerprints of text-based renames the model has seen
+}
+
+func NewRenameSymbolTool() *RenameSymbolTool {
+	return &RenameSymbolTool{previewed: make(map[string]bool)}
+}
+
+func (t *RenameSymbolTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name: "RenameSymbol",
+		Description: `Renames a symbol (variable, function, type, field, ...) everywhere it is used across the project.
+- Prefer this over many Edit calls when renaming
+- Give the file and line of any occurrence (ideally the declaration) and the current name
+- Without apply, returns a diff preview and changes nothing; call again with apply: true to write all files at once
+- Uses gopls for Go and tsserver for TypeScript/JavaScript, so only real references are renamed
+- Other languages use a whole-word text match limited to files of the same type under path; it can hit comments, strings and unrelated symbols with the same name, so review the preview before applying`,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"file_path": map[string]interface{}{
+					"type":        "string",
+					"description": "The absolute path to a file containing the symbol",
+				},
+				"line": map[string]interface{}{
+					"type":        "integer",
+					"description": "1-based line in file_path where the symbol appears",
+				},
+				"symbol": map[string]interface{}{
+					"type":        "string",
+					"description": "The current name of the symbol",
+				},
+				"new_name": map[string]interface{}{
+					"type":        "string",
+					"description": "The new name",
+				},
+				"apply": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Write the changes (default false: preview only)",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory searched by the text-based fallback (default: current directory)",
+				},
+				"glob": map[string]interface{}{
+					"type":        "string",
+					"description": "Files searched by the text-based fallback, e.g. *.py (default: same extension as file_path)",
+				},
+			},
+			"required": []string{"file_path", "line", "symbol", "new_name"},
+		},
+	}
+}
+
+// renamePlan is the new content of every file a rename changes
+type renamePlan struct {
+	backend     string
+	original    map[string]string
+	updated     map[string]string
+	occurrences int
+}
+
+func (t *RenameSymbolTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, _ := args["file_path"].(string)
+	symbol, _ := args["symbol"].(string)
+	newName, _ := args["new_name"].(string)
+	lineArg, _ := args["line"].(float64)
+	apply, _ := args["apply"].(bool)
+	if path == "" || symbol == "" || newName == "" || lineArg < 1 {
+		return "", fmt.Errorf("file_path, line, symbol and new_name are required")
+	}
+	if !identifierPattern.MatchString(newName) {
+		return "", fmt.Errorf("new_name %q is not a valid identifier", newName)
+	}
+	if newName == symbol {
+		return "", fmt.Errorf("new_name is the same as symbol")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	line := int(lineArg)
+	col, err := symbolColumn(path, line, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, renameTimeout)
+	defer cancel()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".go" {
+		if _, err := exec.LookPath("gopls"); err == nil {
+			return renameWithGopls(ctx, path, line, col, symbol, newName, apply)
+		}
+	}
+
+	var plan *renamePlan
+	if _, lookErr := exec.LookPath("tsserver"); lookErr == nil && tsExtensions[ext] {
+		plan, err = planWithTsserver(ctx, path, line, col, newName)
+	} else {
+		root, _ := args["path"].(string)
+		glob, _ := args["glob"].(string)
+		plan, err = planWithText(root, glob, ext, symbol, newName)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(plan.updated) == 0 {
+		return "", fmt.Errorf("no occurrences of %s found", symbol)
+	}
+
+	diff := plan.diff()
+	summary := fmt.Sprintf("%d occurrence(s) of %s in %d file(s) via %s", plan.occurrences, symbol, len(plan.updated), plan.backend)
+	textBased := plan.backend == "text match"
+	key := plan.fingerprint()
+
+	if apply && textBased && !t.previewed[key] {
+		t.previewed[key] = true
+		return fmt.Sprintf("Not applied: a text-based rename must be previewed first. Check that every change below "+
+			"is a real reference to %s, then call again with apply: true.\n\nPreview of %s:\n%s", symbol, summary, diff), nil
+	}
+	if !apply {
+		if textBased {
+			t.previewed[key] = true
+		}
+		return fmt.Sprintf("Preview of %s (nothing written yet; call again with apply: true):\n%s", summary, diff), nil
+	}
+
+	unlock, err := Files.Lock(ctx, plan.paths()...)
+	if err != nil {
+		return "", err
+	}
+	err = plan.apply()
+	unlock()
+	if err != nil {
+		return "", err
+	}
+	delete(t.previewed, key)
+	return fmt.Sprintf("Renamed %s to %s: %s\n%s", symbol, newName, summary, diff), nil
+}
+
+// symbolColumn returns the 0-based byte column of symbol as a whole word on
+// the given line
+func symbolColumn(path string, line int, symbol string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return 0, fmt.Errorf("%s has only %d lines", path, len(lines))
+	}
+	loc := wordPattern(symbol).FindStringSubmatchIndex(lines[line-1])
+	if loc == nil {
+		return 0, fmt.Errorf("%s not found on line %d of %s", symbol, line, path)
+	}
+	return loc[4], nil
+}
+
+// wordPattern matches name as a whole identifier
+func wordPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^\p{L}\p{N}_$])(` + regexp.QuoteMeta(name) + `)($|[^\p{L}\p{N}_$])`)
+}
+
+// renameWithGopls previews with gopls rename -d, and applies with -w after
+// saving the files it will touch so a failure can be rolled back
+func renameWithGopls(ctx context.Context, path string, line, col int, symbol, newName string, apply bool) (string, error) {
+	pos := fmt.Sprintf("%s:%d:%d", path, line, col+1)
+	run := func(flag string) (string, error) {
+		cmd := exec.CommandContext(ctx, "gopls", "rename", flag, pos, newName)
+		cmd.Dir = filepath.Dir(path)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("gopls rename failed: %s", strings.TrimSpace(stderr.String()+" "+err.Error()))
+		}
+		return string(out), nil
+	}
+
+	diff, err := run("-d")
+	if err != nil {
+		return "", err
+	}
+	files := strings.Count(diff, "\n--- ")
+	if strings.HasPrefix(diff, "--- ") {
+		files++
+	}
+	diff = truncateDiff(diff)
+	if !apply {
+		return fmt.Sprintf("Preview of renaming %s in %d file(s) via gopls (nothing written yet; call again with apply: true):\n%s", symbol, files, diff), nil
+	}
+
+	listed, err := run("-l")
+	if err != nil {
+		return "", err
+	}
+	unlock, err := Files.Lock(ctx, strings.Fields(listed)...)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	saved := make(map[string][]byte)
+	for _, file := range strings.Fields(listed) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		saved[file] = data
+	}
+	if _, err := run("-w"); err != nil {
+		for file, data := range saved {
+			os.WriteFile(file, data, 0644)
+		}
+		return "", fmt.Errorf("%v (files restored)", err)
+	}
+	return fmt.Sprintf("Renamed %s to %s in %d file(s) via gopls\n%s", symbol, newName, len(saved), diff), nil
+}
+
+// tsserverLocation is a position in tsserver's protocol: 1-based line and
+// 1-based UTF-16 offset
+type tsserverLocation struct {
+	Line   int `json:"line"`
+	Offset int `json:"offset"`
+}
+
+type tsserverRenameResponse struct {
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	Body       struct {
+		Info struct {
+			CanRename    bool   `json:"canRename"`
+			ErrorMessage string `json:"localizedErrorMessage"`
+		} `json:"info"`
+		Locs []struct {
+			File string `json:"file"`
+			Locs []struct {
+				Start      tsserverLocation `json:"start"`
+				End        tsserverLocation `json:"end"`
+				PrefixText string           `json:"prefixText"`
+				SuffixText string           `json:"suffixText"`
+			} `json:"locs"`
+		} `json:"locs"`
+	} `json:"body"`
+}
+
+// planWithTsserver asks tsserver for every reference to rename
+func planWithTsserver(ctx context.Context, path string, line, col int, newName string) (*renamePlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lineText := strings.Split(string(data), "\n")[line-1]
+
+	cmd := exec.CommandContext(ctx, "tsserver")
+	cmd.Dir = filepath.Dir(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start tsserver: %w", err)
+	}
+	defer cmd.Process.Kill()
+
+	requests := []map[string]interface{}{
+		{"seq": 1, "type": "request", "command": "open", "arguments": map[string]interface{}{"file": path}},
+		{"seq": 2, "type": "request", "command": "rename", "arguments": map[string]interface{}{
+			"file": path, "line": line, "offset": utf16Offset(lineText, col) + 1,
+			"findInComments": false, "findInStrings": false,
+		}},
+	}
+	for _, req := range requests {
+		msg, _ := json.Marshal(req)
+		if _, err := stdin.Write(append(msg, '\n')); err != nil {
+			return nil, fmt.Errorf("tsserver: %w", err)
+		}
+	}
+
+	// Responses and events are framed with Content-Length headers
+	reader := bufio.NewReader(stdout)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("tsserver exited without answering: %w", err)
+		}
+		if !strings.HasPrefix(header, "Content-Length:") {
+			continue
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+		if err != nil {
+			continue
+		}
+		reader.ReadString('\n') // Blank line after the header
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, fmt.Errorf("tsserver: %w", err)
+		}
+
+		var resp tsserverRenameResponse
+		if json.Unmarshal(body, &resp) != nil || resp.RequestSeq != 2 {
+			continue
+		}
+		if !resp.Success {
+			return nil, fmt.Errorf("tsserver rename failed: %s", resp.Message)
+		}
+		if !resp.Body.Info.CanRename {
+			return nil, fmt.Errorf("tsserver cannot rename this symbol: %s", resp.Body.Info.ErrorMessage)
+		}
+
+		plan := &renamePlan{backend: "tsserver", original: map[string]string{}, updated: map[string]string{}}
+		for _, file := range resp.Body.Locs {
+			content, err := os.ReadFile(file.File)
+			if err != nil {
+				return nil, err
+			}
+			text := string(content)
+			lines := strings.SplitAfter(text, "\n")
+			offsetOf := func(loc tsserverLocation) int {
+				start := 0
+				for i := 0; i < loc.Line-1 && i < len(lines); i++ {
+					start += len(lines[i])
+				}
+				if loc.Line-1 >= len(lines) {
+					return start
+				}
+				return start + byteOffset(lines[loc.Line-1], loc.Offset-1)
+			}
+
+			// Replace from the end so earlier offsets stay valid
+			locs := file.Locs
+			sort.Slice(locs, func(i, j int) bool { return offsetOf(locs[i].Start) > offsetOf(locs[j].Start) })
+			updated := text
+			for _, loc := range locs {
+				start, end := offsetOf(loc.Start), offsetOf(loc.End)
+				updated = updated[:start] + loc.PrefixText + newName + loc.SuffixText + updated[end:]
+			}
+			plan.original[file.File] = text
+			plan.updated[file.File] = updated
+			plan.occurrences += len(locs)
+		}
+		return plan, nil
+	}
+}
+
+// utf16Offset converts a byte column into UTF-16 code units
+func utf16Offset(line string, col int) int {
+	n := 0
+	for _, r := range line[:col] {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
+
+// byteOffset converts a UTF-16 column into a byte offset
+func byteOffset(line string, units int) int {
+	for i, r := range line {
+		if units <= 0 {
+			return i
+		}
+		units -= len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+// planWithText replaces symbol as a whole word in the files under root that
+// match glob, or that share ext when no glob is given
+func planWithText(root, glob, ext, symbol, newName string) (*renamePlan, error) {
+	if root == "" {
+		root = "."
+	}
+	pattern := wordPattern(symbol)
+	plan := &renamePlan{backend: "text match", original: map[string]string{}, updated: map[string]string{}}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && (skipRenameDirs[info.Name()] || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Size() > 1<<20 || !renameCandidate(root, path, glob, ext) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+			return nil
+		}
+
+		text := string(data)
+		count := 0
+		// The pattern consumes the characters around a match, so repeat
+		// until adjacent occurrences like "a+a" are all replaced
+		updated := text
+		for {
+			matches := len(pattern.FindAllStringIndex(updated, -1))
+			if matches == 0 {
+				break
+			}
+			count += matches
+			updated = pattern.ReplaceAllString(updated, "${1}"+newName+"${3}")
+		}
+		if count > 0 {
+			abs, _ := filepath.Abs(path)
+			plan.original[abs] = text
+			plan.updated[abs] = updated
+			plan.occurrences += count
+		}
+		return nil
+	})
+	return plan, err
+}
+
+// renameCandidate reports whether the text fallback should search path
+func renameCandidate(root, path, glob, ext string) bool {
+	if glob == "" {
+		return ext != "" && strings.EqualFold(filepath.Ext(path), ext)
+	}
+	glob = strings.TrimPrefix(glob, "**/")
+	if !strings.Contains(glob, "/") {
+		ok, _ := filepath.Match(glob, filepath.Base(path))
+		return ok
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	ok, _ := filepath.Match(glob, filepath.ToSlash(rel))
+	return ok
+}
+
+// fingerprint identifies a plan, so a preview can be matched to the apply
+// call that follows it
+func (p *renamePlan) fingerprint() string {
+	h := sha256.New()
+	for _, path := range p.paths() {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", path, p.original[path], p.updated[path])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *renamePlan) paths() []string {
+	paths := make([]string, 0, len(p.updated))
+	for path := range p.updated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (p *renamePlan) diff() string {
+	var b strings.Builder
+	for _, path := range p.paths() {
+		b.WriteString(lineDiff(displayPath(path), p.original[path], p.updated[path]))
+	}
+	return truncateDiff(b.String())
+}
+
+// apply writes every file or none: new contents go to temporary files first,
+// and files already replaced are restored if a later rename fails
+func (p *renamePlan) apply() error {
+	temps := make(map[string]string)
+	cleanup := func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}
+
+	for _, path := range p.paths() {
+		current, err := os.ReadFile(path)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		if string(current) != p.original[path] {
+			cleanup()
+			return fmt.Errorf("%s changed since the rename was planned; run it again", path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".rename-*")
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temps[path] = tmp.Name()
+		_, err = tmp.WriteString(p.updated[path])
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), info.Mode().Perm())
+		}
+		if err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	var done []string
+	for _, path := range p.paths() {
+		if err := os.Rename(temps[path], path); err != nil {
+			for _, restored := range done {
+				os.WriteFile(restored, []byte(p.original[restored]), 0644)
+			}
+			cleanup()
+			return fmt.Errorf("failed to write %s, rename rolled back: %w", path, err)
+		}
+		delete(temps, path)
+		done = append(done, path)
+	}
+	for _, path := range done {
+		Versions.Record(path, p.updated[path])
+	}
+	return nil
+}
+
+// lineDiff renders the changed lines of a file as unified diff hunks
+// without context lines
+func lineDiff(name, before, after string) string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	if len(a) != len(b) {
+		// Lines were added or removed: show the differing middle as one hunk
+		prefix := 0
+		for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+			prefix++
+		}
+		suffix := 0
+		for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+			suffix++
+		}
+		writeHunk(&out, prefix, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+		return out.String()
+	}
+
+	for i := 0; i < len(a); {
+		if a[i] == b[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(a) && a[j] != b[j] {
+			j++
+		}
+		writeHunk(&out, i, a[i:j], b[i:j])
+		i = j
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, start int, removed, added []string) {
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", start+1, len(removed), start+1, len(added))
+	for _, l := range removed {
+		out.WriteString("-" + l + "\n")
+	}
+	for _, l := range added {
+		out.WriteString("+" + l + "\n")
+	}
+}
+
+func truncateDiff(diff string) string {
+	if len(diff) > maxRenameDiff {
+		return diff[:maxRenameDiff] + "\n... (diff truncated)\n"
+	}
+	return diff
+}
+
+// displayPath shows a path relative to the working directory when it is
+// inside it
+func displayPath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameSymbolTextFallback(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	main := write("main.py", "def load(x):\n    return x\n\nload(load(1))\n")
+	util := write("util.py", "from main import load\nloaded = load\n")
+	notes := write("notes.txt", "call load here\n")
+
+	tool := NewRenameSymbolTool()
+	args := map[string]interface{}{
+		"file_path": main, "line": float64(1), "symbol": "load", "new_name": "read_config",
+		"path": dir, "apply": true,
+	}
+
+	// A text-based rename is never applied without a preview first
+	out, err := tool.Execute(context.Background(), args)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(out, "Not applied") || !strings.Contains(out, "5 occurrence(s)") {
+		t.Fatalf("Expected preview with 5 occurrences, got:\n%s", out)
+	}
+	if data, _ := os.ReadFile(main); strings.Contains(string(data), "read_config") {
+		t.Fatal("File changed before the rename was applied")
+	}
+
+	out, err = tool.Execute(context.Background(), args)
+	if err != nil || !strings.Contains(out, "Renamed load to read_config") {
+		t.Fatalf("Expected rename to apply, got %q, %v", out, err)
+	}
+	if data, _ := os.ReadFile(main); string(data) != "def read_config(x):\n    return x\n\nread_config(read_config(1))\n" {
+		t.Errorf("Unexpected main.py:\n%s", data)
+	}
+	if data, _ := os.ReadFile(util); string(data) != "from main import read_config\nloaded = read_config\n" {
+		t.Errorf("Unexpected util.py:\n%s", data)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "call load here\n" {
+		t.Errorf("Files of another type must not change, got:\n%s", data)
+	}
+}
+
+func TestRenameSymbolRejectsStalePlan(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.rb")
+	os.WriteFile(path, []byte("count = 1\nputs count\n"), 0644)
+
+	plan, err := planWithText(dir, "", ".rb", "count", "total")
+	if err != nil || plan.occurrences != 2 {
+		t.Fatalf("Expected 2 occurrences, got %+v, %v", plan, err)
+	}
+	os.WriteFile(path, []byte("count = 2\nputs count\n"), 0644)
+	if err := plan.apply(); err == nil {
+		t.Fatal("Expected apply to refuse a file changed since planning")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "count = 2\nputs count\n" {
+		t.Errorf("File must be left alone, got:\n%s", data)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	diff := lineDiff("a.txt", "one\ntwo\nthree\n", "one\nTWO\nthree\n")
+	want := "--- a/a.txt\n+++ b/a.txt\n@@ -2,1 +2,1 @@\n-two\n+TWO\n"
+	if diff != want {
+		t.Errorf("lineDiff = %q, want %q", diff, want)
+	}
+}
@@ -0,0 +1,272 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerInfo describes a background shell and the TCP ports its processes
+// listen on
+type ServerInfo struct {
+	ID      string
+	Command string
+	PID     int // Also the process group ID on Unix
+	Ports   []int
+	Running bool
+	Started time.Time
+	Stale   bool // Left running by an earlier john that did not clean up
+}
+
+// serverRecord is a running background shell as saved in a state file
+type serverRecord struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+	// ProcessStart is the start time ps reports, which tells the shell
+	// apart from a later process that reuses its PID
+	ProcessStart string `json:"processStart"`
+}
+
+// SetStateDir makes the manager record its running shells in dir, in a file
+// named after this process. If john is killed before it can stop them, a
+// later john finds them there with StaleServers.
+func (sm *ShellManager) SetStateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.stateFile = filepath.Join(dir, fmt.Sprintf("%d.json", os.Getpid()))
+	sm.saveStateLocked()
+	return nil
+}
+
+func (sm *ShellManager) saveStateLocked() {
+	if sm.stateFile == "" {
+		return
+	}
+	var records []serverRecord
+	for _, bp := range sm.processes {
+		if !bp.Done && bp.Cmd.Process != nil {
+			if bp.processStart == "" {
+				bp.processStart = processStartTime(bp.Cmd.Process.Pid)
+			}
+			records = append(records, serverRecord{PID: bp.Cmd.Process.Pid, Command: bp.Command, Started: bp.StartTime, ProcessStart: bp.processStart})
+		}
+	}
+	if len(records) == 0 {
+		os.Remove(sm.stateFile)
+		return
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err == nil {
+		os.WriteFile(sm.stateFile, data, 0644)
+	}
+}
+
+// Servers lists the background shells in the order they were started, with
+// the ports the running ones listen on
+func (sm *ShellManager) Servers() []ServerInfo {
+	sm.mu.Lock()
+	var list []ServerInfo
+	for _, bp := range sm.processes {
+		info := ServerInfo{ID: bp.ID, Command: bp.Command, Running: !bp.Done, Started: bp.StartTime}
+		if bp.Cmd.Process != nil {
+			info.PID = bp.Cmd.Process.Pid
+		}
+		list = append(list, info)
+	}
+	sm.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+	for i := range list {
+		if list[i].Running && list[i].PID != 0 {
+			list[i].Ports = ListeningPorts(list[i].PID)
+		}
+	}
+	return list
+}
+
+// StaleServers returns the background shells recorded in dir by john
+// processes that have exited without stopping them. They get the IDs s1, s2
+// and so on. State files that have nothing running left are removed.
+func StaleServers(dir string) []ServerInfo {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var stale []ServerInfo
+	for _, entry := range entries {
+		owner, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || owner == os.Getpid() || processAlive(owner) {
+			continue // Not a state file, or its john is still running
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var records []serverRecord
+		json.Unmarshal(data, &records)
+		alive := 0
+		for _, r := range records {
+			// The PID may have been reused by an unrelated process since
+			if r.ProcessStart == "" || !processAlive(r.PID) || processStartTime(r.PID) != r.ProcessStart {
+				continue
+			}
+			alive++
+			stale = append(stale, ServerInfo{Command: r.Command, PID: r.PID, Running: true, Started: r.Started, Stale: true, Ports: ListeningPorts(r.PID)})
+		}
+		if alive == 0 {
+			os.Remove(path)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Started.Before(stale[j].Started) })
+	for i := range stale {
+		stale[i].ID = fmt.Sprintf("s%d", i+1)
+	}
+	return stale
+}
+
+// KillServer stops a stale server's process group: SIGTERM first, then
+// SIGKILL if it is still there after killGracePeriod
+func KillServer(info ServerInfo) error {
+	if err := signalProcessGroup(info.PID, false); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(killGracePeriod); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if !processAlive(info.PID) {
+			return nil
+		}
+	}
+	return signalProcessGroup(info.PID, true)
+}
+
+// processStartTime returns when pid started, as ps prints it, or "" if
+// that is unknown
+func processStartTime(pid int) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ListeningPorts returns the TCP ports that processes in the process group
+// pgid listen on. It reads /proc on Linux and asks lsof elsewhere; on
+// Windows it finds nothing.
+func ListeningPorts(pgid int) []int {
+	var ports []int
+	switch runtime.GOOS {
+	case "windows":
+		return nil
+	case "linux":
+		ports = procListeningPorts(pgid)
+	default:
+		ports = lsofListeningPorts(pgid)
+	}
+	sort.Ints(ports)
+	unique := ports[:0]
+	for i, p := range ports {
+		if i == 0 || p != ports[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// procListeningPorts matches the socket inodes held by the group's processes
+// against the listening sockets in /proc/net/tcp and tcp6
+func procListeningPorts(pgid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	inodes := make(map[string]bool)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// Fields after the parenthesized command: state, ppid, pgrp, ...
+		i := strings.LastIndexByte(string(stat), ')')
+		fields := strings.Fields(string(stat[i+1:]))
+		if i < 0 || len(fields) < 3 || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, _ := os.ReadDir(fdDir)
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && strings.HasPrefix(link, "socket:[") {
+				inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	var ports []int
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // Header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != "0A" || !inodes[fields[9]] { // 0A is LISTEN
+				continue
+			}
+			_, portHex, _ := strings.Cut(fields[1], ":")
+			if port, err := strconv.ParseInt(portHex, 16, 32); err == nil {
+				ports = append(ports, int(port))
+			}
+		}
+		f.Close()
+	}
+	return ports
+}
+
+// lsofListeningPorts asks lsof for the group's listening TCP sockets
+func lsofListeningPorts(pgid int) []int {
+	out, err := exec.Command("lsof", "-nP", "-a", "-g", strconv.Itoa(pgid), "-iTCP", "-sTCP:LISTEN", "-Fn").Output()
+	if err != nil {
+		return nil
+	}
+	var ports []int
+	for _, line := range strings.Split(string(out), "\n") {
+		// Name lines look like n*:3000 or n[::1]:5173
+		if !strings.HasPrefix(line, "n") {
+			continue
+		}
+		if i := strings.LastIndexByte(line, ':'); i >= 0 {
+			if port, err := strconv.Atoi(line[i+1:]); err == nil {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
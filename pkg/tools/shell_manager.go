@@ -3,6 +3,7 @@ package tools
 import (
 	"fmt"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,17 +13,28 @@ type ShellManager struct {
 	mu        sync.Mutex
 	processes map[string]*BackgroundProcess
     nextID    int
+    stateFile string // Where running shells are recorded, see SetStateDir
 }
 
 type BackgroundProcess struct {
 	ID        string
 	Cmd       *exec.Cmd
+	Command   string // The shell command line
 	OutputBuf *ThreadSafeBuffer
     Done      bool
     Error     error
     StartTime time.Time
+    exited    chan struct{} // Closed once Wait returns
+    processStart string // Start time from ps, recorded in the state file
 }
 
+// killGracePeriod is how long a killed shell's process group gets to exit
+// after SIGTERM before it is sent SIGKILL
+const killGracePeriod = 3 * time.Second
+
+// killPollInterval is how often Kill checks whether the group has exited
+const killPollInterval = 50 * time.Millisecond
+
 var GlobalShellManager = &ShellManager{
 	processes: make(map[string]*BackgroundProcess),
     nextID: 1,
@@ -38,12 +50,20 @@ func (sm *ShellManager) Start(cmd *exec.Cmd) string {
     buf := &ThreadSafeBuffer{}
     cmd.Stdout = buf
     cmd.Stderr = buf
+    // Its own process group, so Kill reaches everything it spawns
+    setProcessGroup(cmd)
     
+    command := strings.Join(cmd.Args, " ")
+    if len(cmd.Args) == 3 && cmd.Args[1] == "-c" {
+        command = cmd.Args[2]
+    }
     bp := &BackgroundProcess{
         ID: id,
         Cmd: cmd,
+        Command: command,
         OutputBuf: buf,
         StartTime: time.Now(),
+        exited: make(chan struct{}),
     }
     
     sm.processes[id] = bp
@@ -51,15 +71,19 @@ func (sm *ShellManager) Start(cmd *exec.Cmd) string {
     if err := cmd.Start(); err != nil {
         bp.Done = true
         bp.Error = err
+        close(bp.exited)
     } else {
         go func() {
             err := cmd.Wait()
             sm.mu.Lock()
             bp.Done = true
             bp.Error = err
+            sm.saveStateLocked()
             sm.mu.Unlock()
+            close(bp.exited)
         }()
     }
+    sm.saveStateLocked()
     
     return id
 }
@@ -86,21 +110,58 @@ func (sm *ShellManager) GetOutput(id string) (string, bool, error) {
     return bp.OutputBuf.String(), bp.Done, bp.Error
 }
 
-func (sm *ShellManager) Kill(id string) error {
+// Has reports whether id is a background shell of this session
+func (sm *ShellManager) Has(id string) bool {
     sm.mu.Lock()
     defer sm.mu.Unlock()
-    
+    _, ok := sm.processes[id]
+    return ok
+}
+
+// Kill stops a background shell and every process it started. The group
+// gets SIGTERM first and SIGKILL if anything is left after killGracePeriod,
+// so children such as dev servers get to clean up even when the shell
+// itself exits at once.
+func (sm *ShellManager) Kill(id string) error {
+    sm.mu.Lock()
     bp, ok := sm.processes[id]
+    sm.mu.Unlock()
     if !ok {
         return fmt.Errorf("shell %s not found", id)
     }
-    
-    if bp.Done {
+
+    if bp.Cmd.Process == nil {
         return nil
     }
-    
-    if bp.Cmd.Process != nil {
-        return bp.Cmd.Process.Kill()
+    if err := killProcessGroup(bp.Cmd, false); err != nil {
+        return err
+    }
+    pgid := bp.Cmd.Process.Pid
+    for deadline := time.Now().Add(killGracePeriod); processGroupAlive(pgid) && time.Now().Before(deadline); {
+        time.Sleep(killPollInterval)
+    }
+    return killProcessGroup(bp.Cmd, true)
+}
+
+// KillAll stops every background shell that is still running, so none
+// outlive the session
+func (sm *ShellManager) KillAll() {
+    sm.mu.Lock()
+    var ids []string
+    for id, bp := range sm.processes {
+        if !bp.Done {
+            ids = append(ids, id)
+        }
+    }
+    sm.mu.Unlock()
+
+    var wg sync.WaitGroup
+    for _, id := range ids {
+        wg.Add(1)
+        go func(id string) {
+            defer wg.Done()
+            sm.Kill(id)
+        }(id)
     }
-    return nil
+    wg.Wait()
 }
@@ -17,7 +17,8 @@ func (t *BashOutputTool) Definition() ToolDefinition {
 - Returns stdout and stderr output along with shell status
 - Supports optional regex filtering to show only lines matching a pattern
 - Use this tool when you need to monitor or check the output of a long-running shell
-- Shell IDs can be found using the /tasks command`,
+- To confirm a server booted, pass until with a pattern for its ready line instead of sleeping and polling
+- Shell IDs can be found using the /servers command`,
         Schema: map[string]interface{}{
             "type": "object",
             "properties": map[string]interface{}{
@@ -25,6 +26,14 @@ func (t *BashOutputTool) Definition() ToolDefinition {
                     "type": "string",
                     "description": "The ID of the background shell to retrieve output from",
                 },
+                "follow": map[string]interface{}{
+                    "type": "number",
+                    "description": "Seconds to wait for more output before returning (at most 300), or until the shell exits",
+                },
+                "until": map[string]interface{}{
+                    "type": "string",
+                    "description": "Regular expression: return as soon as a line of output matches it, e.g. the line a server prints once it is listening. Waits up to 30 seconds unless follow is given.",
+                },
             },
             "required": []string{"shell_id"},
         },
@@ -37,6 +46,23 @@ func (t *BashOutputTool) Execute(ctx context.Context, args map[string]interface{
         return "", fmt.Errorf("shell_id required")
     }
 
+    follow, until, err := followArgs(args)
+    if err != nil {
+        return "", err
+    }
+    watch := ""
+    if follow > 0 {
+        if !GlobalShellManager.Has(id) {
+            return "", fmt.Errorf("shell %s not found", id)
+        }
+        // Output from before the call counts, so a ready line already printed is found
+        followed, err := Follow(ctx, FollowShell(id, 0), follow, until, nil)
+        if err != nil {
+            return "", err
+        }
+        watch = "Watched output: " + followed.Summary() + "\n"
+    }
+
     output, done, err := GlobalShellManager.GetOutput(id)
     
     status := "running"
@@ -47,7 +73,7 @@ func (t *BashOutputTool) Execute(ctx context.Context, args map[string]interface{
         status = fmt.Sprintf("error: %v", err)
     }
 
-    return fmt.Sprintf("Shell ID: %s\nStatus: %s\nOutput:\n%s", id, status, output), nil
+    return fmt.Sprintf("Shell ID: %s\nStatus: %s\n%sOutput:\n%s", id, status, watch, output), nil
 }
 
 // KillShellTool
@@ -60,7 +86,7 @@ func (t *KillShellTool) Definition() ToolDefinition {
 - Takes a shell_id parameter identifying the shell to kill
 - Returns a success or failure status 
 - Use this tool when you need to terminate a long-running shell
-- Shell IDs can be found using the /tasks command`,
+- Shell IDs can be found using the /servers command`,
         Schema: map[string]interface{}{
             "type": "object",
             "properties": map[string]interface{}{
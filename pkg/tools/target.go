@@ -0,0 +1,30 @@
+package tools
+
+import (
+	"strings"
+
+	"github.com/jbdamask/john-code/pkg/target"
+)
+
+// Target is where Bash, Read, Write, Edit, Grep and Glob work. The agent
+// sets it from the project's target settings; the other tools always work
+// on this machine.
+var Target target.Target = target.Local{}
+
+// LocalOnlyTools cannot work on a remote target
+var LocalOnlyTools = map[string]bool{"NotebookEdit": true, "RenameSymbol": true, "Python": true}
+
+// globPattern escapes everything in a glob pattern but its wildcards, so a
+// shell expands it as a pattern and nothing else
+func globPattern(pattern string) string {
+	var sb strings.Builder
+	for _, r := range pattern {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("*?[]/._-", r):
+			sb.WriteRune(r)
+		default:
+			sb.WriteString(`\` + string(r))
+		}
+	}
+	return sb.String()
+}
@@ -3,35 +3,75 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
 )
 
 // TaskRunner is a function that runs a sub-agent
 type TaskRunner func(ctx context.Context, task string) (string, error)
 
+type subAgentKey struct{}
+
+// SubAgentName returns the name of the specialized agent a TaskRunner was
+// called for, or "" for a general-purpose sub-agent
+func SubAgentName(ctx context.Context) string {
+	name, _ := ctx.Value(subAgentKey{}).(string)
+	return name
+}
+
+// SubAgent is a named, specialized sub-agent defined in .john/agents
+type SubAgent struct {
+    Name        string
+    Description string
+    Prompt      string // Instructions given to the sub-agent ahead of its task
+}
+
 type TaskTool struct {
     runner TaskRunner
+    agents []SubAgent
 }
 
 func NewTaskTool(runner TaskRunner) *TaskTool {
     return &TaskTool{runner: runner}
 }
 
+// SetAgents makes specialized sub-agents available through the agent argument
+func (t *TaskTool) SetAgents(agents []SubAgent) {
+    t.agents = agents
+}
+
 func (t *TaskTool) Definition() ToolDefinition {
-	return ToolDefinition{
-		Name:        "Task",
-		Description: `Delegate a complex task to a sub-agent.
+	description := `Delegate a complex task to a sub-agent.
 - Use when you need to perform complex multi-step tasks
 - Use when you need to run an operation that will produce a lot of output (tokens) that is not needed after the sub-agent's task completes
-- When the agent is done, it will return a single message back to you.`,
+- When the agent is done, it will return a single message back to you.`
+	properties := map[string]interface{}{
+		"task": map[string]interface{}{
+			"type":        "string",
+			"description": "The task description for the sub-agent.",
+		},
+	}
+
+	if len(t.agents) > 0 {
+		var names []string
+		description += "\n\nSpecialized agents (pass the name as agent):"
+		for _, a := range t.agents {
+			names = append(names, a.Name)
+			description += fmt.Sprintf("\n- %s: %s", a.Name, a.Description)
+		}
+		properties["agent"] = map[string]interface{}{
+			"type":        "string",
+			"enum":        names,
+			"description": "Optional specialized agent to run the task. Omit for a general-purpose sub-agent.",
+		}
+	}
+
+	return ToolDefinition{
+		Name:        "Task",
+		Description: description,
 		Schema: map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"task": map[string]interface{}{
-					"type":        "string",
-					"description": "The task description for the sub-agent.",
-				},
-			},
-			"required": []string{"task"},
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"task"},
 		},
 	}
 }
@@ -46,5 +86,16 @@ func (t *TaskTool) Execute(ctx context.Context, args map[string]interface{}) (st
         return "", fmt.Errorf("task runner not initialized")
     }
 
+    if name, _ := args["agent"].(string); name != "" {
+        var names []string
+        for _, a := range t.agents {
+            if a.Name == name {
+                return t.runner(context.WithValue(ctx, subAgentKey{}, name), a.Prompt+"\n\nTask: "+task)
+            }
+            names = append(names, a.Name)
+        }
+        return "", fmt.Errorf("unknown agent %q (available: %s)", name, strings.Join(names, ", "))
+    }
+
     return t.runner(ctx, task)
 }
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +29,45 @@ func TestTaskTool(t *testing.T) {
         t.Errorf("Expected 'Completed: Do something', got '%s'", output)
     }
 }
+
+func TestTaskToolSubAgents(t *testing.T) {
+    runner := func(ctx context.Context, task string) (string, error) {
+        return task, nil
+    }
+    tool := NewTaskTool(runner)
+    tool.SetAgents([]SubAgent{{Name: "reviewer", Description: "Reviews code", Prompt: "You review code."}})
+
+    if !strings.Contains(tool.Definition().Description, "reviewer: Reviews code") {
+        t.Error("Expected agent to be listed in the tool description")
+    }
+
+    output, err := tool.Execute(context.Background(), map[string]interface{}{"task": "Check main.go", "agent": "reviewer"})
+    if err != nil {
+        t.Fatalf("TaskTool failed: %v", err)
+    }
+    if output != "You review code.\n\nTask: Check main.go" {
+        t.Errorf("Unexpected sub-agent task: %q", output)
+    }
+
+    if _, err := tool.Execute(context.Background(), map[string]interface{}{"task": "x", "agent": "missing"}); err == nil {
+        t.Error("Expected error for unknown agent")
+    }
+}
+
+func TestFinalAnswerTool(t *testing.T) {
+    tool := NewFinalAnswerTool()
+
+    if _, err := tool.Execute(context.Background(), map[string]interface{}{}); err == nil {
+        t.Error("Expected error when answer is missing")
+    }
+    if tool.Done {
+        t.Error("Tool should not be done after a failed call")
+    }
+
+    if _, err := tool.Execute(context.Background(), map[string]interface{}{"answer": "42"}); err != nil {
+        t.Fatalf("FinalAnswerTool failed: %v", err)
+    }
+    if !tool.Done || tool.Answer != "42" {
+        t.Errorf("Expected recorded answer '42', got done=%v answer=%q", tool.Done, tool.Answer)
+    }
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type TodoStatus string
@@ -17,10 +18,16 @@ const (
 type TodoItem struct {
 	ID         string     `json:"id"`
 	Content    string     `json:"content"`
+	ActiveForm string     `json:"activeForm,omitempty"` // Present continuous form shown while in progress
 	Status     TodoStatus `json:"status"`
 	Priority   string     `json:"priority"` // high, medium, low
+	DependsOn  []string   `json:"dependsOn,omitempty"` // IDs that must be completed first
+	Due        string     `json:"due,omitempty"`       // Deadline as YYYY-MM-DD
 }
 
+// todoDueLayout is the date format accepted for deadlines
+const todoDueLayout = "2006-01-02"
+
 type TodoWriteTool struct {
 	Todos []TodoItem
 }
@@ -46,7 +53,9 @@ func (t *TodoWriteTool) Definition() ToolDefinition {
 - Update status in real-time
 - Mark complete IMMEDIATELY after finishing (don't batch)
 - Exactly ONE task must be in_progress at any time
-- Complete current tasks before starting new ones`,
+- Complete current tasks before starting new ones
+- Use dependsOn to record ordering between tasks; a task cannot start until its dependencies are completed
+- Invalid updates are rejected with an explanation and the previous list is kept`,
 		Schema: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -55,10 +64,17 @@ func (t *TodoWriteTool) Definition() ToolDefinition {
 					"items": map[string]interface{}{
 						"type": "object",
 						"properties": map[string]interface{}{
-							"id":       map[string]interface{}{"type": "string"},
-							"content":  map[string]interface{}{"type": "string"},
-							"status":   map[string]interface{}{"type": "string", "enum": []string{"pending", "in_progress", "completed"}},
-							"priority": map[string]interface{}{"type": "string"},
+							"id":         map[string]interface{}{"type": "string"},
+							"content":    map[string]interface{}{"type": "string", "description": "Imperative form, e.g. \"Run tests\""},
+							"activeForm": map[string]interface{}{"type": "string", "description": "Present continuous form shown while in progress, e.g. \"Running tests\""},
+							"status":     map[string]interface{}{"type": "string", "enum": []string{"pending", "in_progress", "completed"}},
+							"priority":   map[string]interface{}{"type": "string", "enum": []string{"high", "medium", "low"}},
+							"dependsOn": map[string]interface{}{
+								"type":        "array",
+								"items":       map[string]interface{}{"type": "string"},
+								"description": "IDs of todos that must be completed before this one can start",
+							},
+							"due": map[string]interface{}{"type": "string", "description": "Optional deadline as YYYY-MM-DD"},
 						},
 						"required": []string{"id", "content", "status"},
 					},
@@ -86,20 +102,41 @@ func (t *TodoWriteTool) Execute(ctx context.Context, args map[string]interface{}
         
         id, _ := itemMap["id"].(string)
         content, _ := itemMap["content"].(string)
+        activeForm, _ := itemMap["activeForm"].(string)
         statusStr, _ := itemMap["status"].(string)
         priority, _ := itemMap["priority"].(string)
+        due, _ := itemMap["due"].(string)
+
+        var dependsOn []string
+        if deps, ok := itemMap["dependsOn"].([]interface{}); ok {
+            for _, d := range deps {
+                if depID, ok := d.(string); ok {
+                    dependsOn = append(dependsOn, depID)
+                }
+            }
+        }
         
         newTodos = append(newTodos, TodoItem{
             ID: id,
             Content: content,
+            ActiveForm: activeForm,
             Status: TodoStatus(statusStr),
             Priority: priority,
+            DependsOn: dependsOn,
+            Due: due,
         })
     }
 
+    if err := validateTodos(newTodos); err != nil {
+        return "", fmt.Errorf("todo list rejected, previous list kept: %w", err)
+    }
+
     t.Todos = newTodos // Replace entire list as per tool behavior often seen
     
     // Format output
+    today := time.Now().Format(todoDueLayout)
+    inProgress := 0
+    pending := 0
     var sb strings.Builder
     sb.WriteString("Updated Todo List:\n")
     for _, todo := range t.Todos {
@@ -108,9 +145,226 @@ func (t *TodoWriteTool) Execute(ctx context.Context, args map[string]interface{}
             mark = "[x]"
         } else if todo.Status == TodoInProgress {
             mark = "[*]"
+            inProgress++
+        } else {
+            pending++
+        }
+        sb.WriteString(fmt.Sprintf("%s %s (%s) - %s", mark, todo.Content, todo.Priority, todo.Status))
+        if len(todo.DependsOn) > 0 {
+            sb.WriteString(fmt.Sprintf(" [after: %s]", strings.Join(todo.DependsOn, ", ")))
         }
-        sb.WriteString(fmt.Sprintf("%s %s (%s) - %s\n", mark, todo.Content, todo.Priority, todo.Status))
+        if todo.Due != "" {
+            // Dates in YYYY-MM-DD form compare correctly as strings
+            if todo.Status != TodoCompleted && todo.Due < today {
+                sb.WriteString(fmt.Sprintf(" (due %s, OVERDUE)", todo.Due))
+            } else {
+                sb.WriteString(fmt.Sprintf(" (due %s)", todo.Due))
+            }
+        }
+        sb.WriteString("\n")
+    }
+    if inProgress == 0 && pending > 0 {
+        sb.WriteString("\nNote: no task is in_progress. Mark the next task in_progress before you start working on it.\n")
     }
     
 	return sb.String(), nil
 }
+
+// validateTodos checks that a todo list is coherent. Errors explain how to fix
+// the list so the model can correct its next TodoWrite call.
+func validateTodos(todos []TodoItem) error {
+    byID := make(map[string]TodoItem, len(todos))
+    var inProgress []string
+    for i, todo := range todos {
+        if todo.ID == "" {
+            return fmt.Errorf("todo #%d has no id; give every todo a unique id", i+1)
+        }
+        if _, dup := byID[todo.ID]; dup {
+            return fmt.Errorf("duplicate todo id %q; ids must be unique", todo.ID)
+        }
+        if strings.TrimSpace(todo.Content) == "" {
+            return fmt.Errorf("todo %q has empty content", todo.ID)
+        }
+        switch todo.Status {
+        case TodoPending, TodoCompleted:
+        case TodoInProgress:
+            inProgress = append(inProgress, todo.ID)
+        default:
+            return fmt.Errorf("todo %q has invalid status %q; use pending, in_progress or completed", todo.ID, todo.Status)
+        }
+        switch todo.Priority {
+        case "", "high", "medium", "low":
+        default:
+            return fmt.Errorf("todo %q has invalid priority %q; use high, medium or low", todo.ID, todo.Priority)
+        }
+        if todo.Due != "" {
+            if _, err := time.Parse(todoDueLayout, todo.Due); err != nil {
+                return fmt.Errorf("todo %q has invalid due date %q; use YYYY-MM-DD", todo.ID, todo.Due)
+            }
+        }
+        byID[todo.ID] = todo
+    }
+
+    if len(inProgress) > 1 {
+        return fmt.Errorf("%d todos are in_progress (%s); exactly one task may be in_progress at a time, mark the others pending or completed",
+            len(inProgress), strings.Join(inProgress, ", "))
+    }
+
+    for _, todo := range todos {
+        for _, dep := range todo.DependsOn {
+            depTodo, ok := byID[dep]
+            if !ok {
+                return fmt.Errorf("todo %q depends on unknown id %q", todo.ID, dep)
+            }
+            if dep == todo.ID {
+                return fmt.Errorf("todo %q depends on itself", todo.ID)
+            }
+            if todo.Status != TodoPending && depTodo.Status != TodoCompleted {
+                return fmt.Errorf("todo %q is %s but its dependency %q is not completed; finish %q first or mark %q pending",
+                    todo.ID, todo.Status, dep, dep, todo.ID)
+            }
+        }
+    }
+
+    // Reject dependency cycles, which could never be completed
+    state := make(map[string]int) // 0 = unvisited, 1 = visiting, 2 = done
+    var visit func(id string, path []string) error
+    visit = func(id string, path []string) error {
+        switch state[id] {
+        case 1:
+            return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), id)
+        case 2:
+            return nil
+        }
+        state[id] = 1
+        for _, dep := range byID[id].DependsOn {
+            if err := visit(dep, append(path, id)); err != nil {
+                return err
+            }
+        }
+        state[id] = 2
+        return nil
+    }
+    for _, todo := range todos {
+        if err := visit(todo.ID, nil); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// MergeTodos combines the todo lists of two sessions. Todos with the same
+// content are one task: it keeps the furthest status, the higher priority
+// and the earlier due date. Colliding IDs of different tasks are renamed,
+// and the result is made valid again (one task in progress, nothing
+// started before its dependencies). The returned notes describe each
+// conflict and how it was resolved.
+func MergeTodos(a, b []TodoItem) ([]TodoItem, []string) {
+    progress := map[TodoStatus]int{TodoPending: 0, TodoInProgress: 1, TodoCompleted: 2}
+    rank := map[string]int{"low": 1, "medium": 2, "high": 3}
+    key := func(t TodoItem) string { return strings.ToLower(strings.Join(strings.Fields(t.Content), " ")) }
+
+    var notes []string
+    merged := make([]TodoItem, 0, len(a)+len(b))
+    byKey := make(map[string]int)
+    ids := make(map[string]string) // ID -> content
+    for _, t := range a {
+        t.DependsOn = append([]string(nil), t.DependsOn...)
+        byKey[key(t)] = len(merged)
+        ids[t.ID] = t.Content
+        merged = append(merged, t)
+    }
+
+    // First map every ID of b to its ID in the merged list, then add its todos
+    rename := make(map[string]string)
+    for _, t := range b {
+        if i, ok := byKey[key(t)]; ok {
+            rename[t.ID] = merged[i].ID
+            continue
+        }
+        id := t.ID
+        for n := 2; ids[id] != ""; n++ {
+            id = fmt.Sprintf("%s-%d", t.ID, n)
+        }
+        if id != t.ID {
+            notes = append(notes, fmt.Sprintf("todo %q of the second session renamed to %q: %q already has that id", t.ID, id, ids[t.ID]))
+        }
+        ids[id] = t.Content
+        rename[t.ID] = id
+    }
+    for _, t := range b {
+        deps := make([]string, 0, len(t.DependsOn))
+        for _, d := range t.DependsOn {
+            deps = append(deps, rename[d])
+        }
+        i, ok := byKey[key(t)]
+        if !ok {
+            t.ID = rename[t.ID]
+            t.DependsOn = deps
+            byKey[key(t)] = len(merged)
+            merged = append(merged, t)
+            continue
+        }
+
+        m := &merged[i]
+        if t.Status != m.Status {
+            if progress[t.Status] > progress[m.Status] {
+                m.Status = t.Status
+            }
+            notes = append(notes, fmt.Sprintf("%q is %s in one session and %s in the other: kept %s", m.Content, m.Status, t.Status, m.Status))
+        }
+        if rank[t.Priority] > rank[m.Priority] {
+            m.Priority = t.Priority
+        }
+        if t.Due != "" && (m.Due == "" || t.Due < m.Due) {
+            m.Due = t.Due
+        }
+        if m.ActiveForm == "" {
+            m.ActiveForm = t.ActiveForm
+        }
+        for _, d := range deps {
+            if d != m.ID && !containsString(m.DependsOn, d) {
+                m.DependsOn = append(m.DependsOn, d)
+            }
+        }
+    }
+
+    // Only one task may be in progress, and only once its dependencies are done
+    status := make(map[string]TodoStatus, len(merged))
+    for _, t := range merged {
+        status[t.ID] = t.Status
+    }
+    inProgress := false
+    for i := range merged {
+        t := &merged[i]
+        if t.Status == TodoPending {
+            continue
+        }
+        for _, d := range t.DependsOn {
+            if status[d] != TodoCompleted {
+                notes = append(notes, fmt.Sprintf("%q was %s before its dependency %q was done: set to pending", t.Content, t.Status, d))
+                t.Status = TodoPending
+                status[t.ID] = TodoPending
+                break
+            }
+        }
+        if t.Status == TodoInProgress {
+            if inProgress {
+                notes = append(notes, fmt.Sprintf("%q was also in progress: set to pending", t.Content))
+                t.Status = TodoPending
+            }
+            inProgress = true
+        }
+    }
+    return merged, notes
+}
+
+func containsString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
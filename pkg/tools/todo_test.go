@@ -46,3 +46,91 @@ func TestTodoWriteTool(t *testing.T) {
         t.Errorf("Expected 2 todos, got %d", len(tool.Todos))
     }
 }
+
+func TestTodoWriteToolValidation(t *testing.T) {
+	ctx := context.Background()
+	item := func(id, status string, deps ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{"id": id, "content": "Task " + id, "activeForm": "Doing " + id, "status": status}
+		if len(deps) > 0 {
+			m["dependsOn"] = deps
+		}
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		todos   []interface{}
+		wantErr string
+	}{
+		{"two in progress", []interface{}{item("1", "in_progress"), item("2", "in_progress")}, "exactly one task"},
+		{"unknown dependency", []interface{}{item("1", "pending", "9")}, "unknown id"},
+		{"dependency not done", []interface{}{item("1", "pending"), item("2", "in_progress", "1")}, "not completed"},
+		{"cycle", []interface{}{item("1", "pending", "2"), item("2", "pending", "1")}, "dependency cycle"},
+		{"bad due date", []interface{}{map[string]interface{}{"id": "1", "content": "x", "status": "pending", "due": "tomorrow"}}, "YYYY-MM-DD"},
+		{"valid", []interface{}{item("1", "completed"), item("2", "in_progress", "1"), item("3", "pending", "2")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := NewTodoWriteTool()
+			tool.Todos = []TodoItem{{ID: "old", Content: "Previous", Status: TodoPending}}
+
+			_, err := tool.Execute(ctx, map[string]interface{}{"todos": tt.todos})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if len(tool.Todos) != len(tt.todos) || tool.Todos[1].ActiveForm != "Doing 2" {
+					t.Errorf("Todos not updated: %+v", tool.Todos)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if len(tool.Todos) != 1 || tool.Todos[0].ID != "old" {
+				t.Errorf("Previous list should be kept on rejection, got %+v", tool.Todos)
+			}
+		})
+	}
+}
+
+func TestMergeTodos(t *testing.T) {
+	a := []TodoItem{
+		{ID: "1", Content: "Design the API", Status: TodoCompleted, Priority: "high"},
+		{ID: "2", Content: "Write handlers", Status: TodoInProgress, Priority: "medium", DependsOn: []string{"1"}},
+	}
+	b := []TodoItem{
+		{ID: "1", Content: "Add tests", Status: TodoInProgress, Priority: "low"},
+		{ID: "2", Content: "write  handlers", Status: TodoCompleted, Priority: "high", Due: "2030-01-01"},
+	}
+
+	merged, notes := MergeTodos(a, b)
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 todos, got %+v", merged)
+	}
+	if h := merged[1]; h.Status != TodoCompleted || h.Priority != "high" || h.Due != "2030-01-01" {
+		t.Errorf("same task should take the furthest status, higher priority and due date: %+v", h)
+	}
+	if added := merged[2]; added.ID != "1-2" || added.Status != TodoInProgress {
+		t.Errorf("colliding id should be renamed: %+v", added)
+	}
+	if err := validateTodos(merged); err != nil {
+		t.Errorf("merged list is invalid: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("expected notes for the rename and the status conflict, got %q", notes)
+	}
+
+	// Two tasks in progress: the second is set back to pending
+	merged, _ = MergeTodos(
+		[]TodoItem{{ID: "a", Content: "One", Status: TodoInProgress}},
+		[]TodoItem{{ID: "b", Content: "Two", Status: TodoInProgress}},
+	)
+	if merged[0].Status != TodoInProgress || merged[1].Status != TodoPending {
+		t.Errorf("only one task may stay in progress: %+v", merged)
+	}
+	if err := validateTodos(merged); err != nil {
+		t.Errorf("merged list is invalid: %v", err)
+	}
+}
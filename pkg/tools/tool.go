@@ -1,6 +1,9 @@
 package tools
 
-import "context"
+import (
+	"context"
+	"sync"
+)
 
 // ToolDefinition describes a tool's interface to the LLM
 type ToolDefinition struct {
@@ -15,9 +18,12 @@ type Tool interface {
 	Execute(ctx context.Context, args map[string]interface{}) (string, error)
 }
 
-// Registry manages the available tools
+// Registry manages the available tools. It is safe for concurrent use, as
+// MCP servers that finish connecting in the background register their tools
+// mid-session.
 type Registry struct {
 	tools map[string]Tool
+	mu    sync.RWMutex
 }
 
 func NewRegistry() *Registry {
@@ -27,15 +33,21 @@ func NewRegistry() *Registry {
 }
 
 func (r *Registry) Register(t Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.tools[t.Definition().Name] = t
 }
 
 func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	t, ok := r.tools[name]
 	return t, ok
 }
 
 func (r *Registry) List() []ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	defs := make([]ToolDefinition, 0, len(r.tools))
 	for _, t := range r.tools {
 		defs = append(defs, t.Definition())
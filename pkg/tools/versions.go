@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxVersionSize bounds the files whose content is remembered for conflict
+// checks; Edit does not check larger ones
+const maxVersionSize = 1 << 20
+
+// FileVersions remembers each file as the model last saw it, by reading or
+// writing it, so Edit can tell when the file changed on disk since: edited
+// by the user, a formatter or another program
+type FileVersions struct {
+	mu      sync.Mutex
+	content map[string]string
+}
+
+// Versions tracks the files of every agent in this process
+var Versions = &FileVersions{content: make(map[string]string)}
+
+// Record notes the content of path as the model has now seen it
+func (v *FileVersions) Record(path, content string) {
+	key := canonicalPath(path)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(content) > maxVersionSize {
+		delete(v.content, key)
+		return
+	}
+	v.content[key] = content
+}
+
+// Seen returns the content of path as the model last saw it
+func (v *FileVersions) Seen(path string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	content, ok := v.content[canonicalPath(path)]
+	return content, ok
+}
+
+// ConflictChoice is how an edit conflict is resolved
+type ConflictChoice int
+
+const (
+	// ConflictKeep leaves the file as it is on disk and fails the edit
+	ConflictKeep ConflictChoice = iota
+	// ConflictApply applies the edit to the file as it is on disk
+	ConflictApply
+	// ConflictOverwrite writes the file the model expected, with the edit,
+	// discarding the changes on disk
+	ConflictOverwrite
+)
+
+// EditConflict is an edit of a file that changed on disk, near the edited
+// text, since the model last read it
+type EditConflict struct {
+	Path     string
+	Seen     string // The file as the model last saw it
+	Disk     string // The file on disk now
+	Expected string // Seen with the edit
+	Applied  string // Disk with the edit; "" when old_string is not found once on disk
+}
+
+// DiskChanges shows what changed on disk since the model saw the file
+func (c *EditConflict) DiskChanges() string {
+	return truncateDiff(lineDiff(displayPath(c.Path), c.Seen, c.Disk))
+}
+
+// Proposed shows the model's edit of the file it saw
+func (c *EditConflict) Proposed() string {
+	return truncateDiff(lineDiff(displayPath(c.Path), c.Seen, c.Expected))
+}
+
+// changedSpan returns the byte range of after that differs from before
+func changedSpan(before, after string) (int, int) {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	return prefix, len(after) - suffix
+}
+
+// resolveConflict decides an edit of a file that changed since the model
+// saw it. Changes away from the edited lines merge by themselves; others go
+// to resolve, or are kept when there is no one to ask. It returns the
+// content to write, or "" and an error for the model.
+func (t *EditTool) resolveConflict(ctx context.Context, c *EditConflict, at, length int) (string, string, error) {
+	// As in a merge, changes on adjacent lines count as touching
+	start, end := changedSpan(c.Seen, c.Disk)
+	line := func(offset int) int { return strings.Count(c.Disk[:offset], "\n") }
+	if c.Applied != "" && (line(max(at+length-1, at)) < line(start)-1 || line(at) > line(max(end-1, start))+1) {
+		return c.Applied, " (the file had changed on disk since you last read it, away from this edit; your edit was applied to the current content)", nil
+	}
+	choice := ConflictKeep
+	if t.Resolve != nil {
+		choice = t.Resolve(ctx, c)
+	}
+	switch {
+	case choice == ConflictApply && c.Applied != "":
+		return c.Applied, " (the file had changed on disk since you last read it; the user had your edit applied to the current content. Read it again before further edits)", nil
+	case choice == ConflictOverwrite:
+		return c.Expected, " (the file had changed on disk since you last read it; the user chose your version, discarding the changes on disk)", nil
+	}
+	return "", "", fmt.Errorf("%s changed on disk since you last read it, and the edit was not applied. Read it again and redo the edit on the current content. Changes on disk:\n%s",
+		c.Path, strings.TrimRight(c.DiskChanges(), "\n"))
+}
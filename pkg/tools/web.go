@@ -12,6 +12,7 @@ import (
 	"time"
 
 	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/jbdamask/john-code/pkg/config"
 )
 
 // WebSearchTool
@@ -25,11 +26,20 @@ func NewWebSearchTool() *WebSearchTool {
     // Using Brave Search as the backend
     return &WebSearchTool{
         apiKey: os.Getenv("BRAVE_API_KEY"),
-        client: &http.Client{Timeout: 10 * time.Second},
+        client: config.NewHTTPClient(10 * time.Second),
         baseURL: "https://api.search.brave.com/res/v1/web/search",
     }
 }
 
+// NewWebSearchToolWithEndpoint creates a WebSearchTool that queries a
+// Brave-compatible endpoint other than the public API.
+func NewWebSearchToolWithEndpoint(apiKey string, baseURL string) *WebSearchTool {
+    tool := NewWebSearchTool()
+    tool.apiKey = apiKey
+    tool.baseURL = baseURL
+    return tool
+}
+
 func (t *WebSearchTool) Definition() ToolDefinition {
 	return ToolDefinition{
 		Name:        "WebSearch",
@@ -112,7 +122,7 @@ type WebFetchTool struct {
 
 func NewWebFetchTool() *WebFetchTool {
     return &WebFetchTool{
-        client: &http.Client{Timeout: 15 * time.Second},
+        client: config.NewHTTPClient(15 * time.Second),
     }
 }
 
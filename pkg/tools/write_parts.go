@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jbdamask/john-code/pkg/config"
+	"github.com/jbdamask/john-code/pkg/lang"
+)
+
+// stagedWrite collects the parts of a file written over several Write calls.
+// Nothing touches the target until the final part arrives.
+type stagedWrite struct {
+	parts   int
+	content bytes.Buffer
+}
+
+// partWrites holds the staged writes of a WriteTool, keyed by absolute path
+type partWrites struct {
+	mu     sync.Mutex
+	staged map[string]*stagedWrite
+}
+
+// writePart handles a Write call that carries part of a file. Parts are
+// numbered from 1 and must arrive in order; part 1 discards anything staged
+// earlier for the path, so a cut-off sequence can be restarted. When final
+// is set the whole file is checked and written atomically.
+func (p *partWrites) writePart(ctx context.Context, path string, part int, final bool, content string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.staged == nil {
+		p.staged = make(map[string]*stagedWrite)
+	}
+
+	s := p.staged[abs]
+	switch {
+	case part == 1:
+		s = &stagedWrite{}
+		p.staged[abs] = s
+	case s == nil:
+		return "", fmt.Errorf("part %d of %s arrived without part 1; start again with part 1", part, path)
+	case part != s.parts+1:
+		return "", fmt.Errorf("expected part %d of %s, got part %d; parts 1-%d are staged, continue with part %d or restart with part 1",
+			s.parts+1, path, part, s.parts, s.parts+1)
+	}
+	s.content.WriteString(content)
+	s.parts++
+
+	if !final {
+		return fmt.Sprintf("Staged part %d of %s (%d lines, %d bytes so far). The file is written when you send the last part with final: true.",
+			s.parts, path, countLines(s.content.Bytes()), s.content.Len()), nil
+	}
+
+	delete(p.staged, abs)
+	assembled, note := fixupFile(ctx, path, s.content.String(), 0, s.content.Len())
+	data := []byte(assembled)
+	if err := checkSyntax(path, data); err != nil {
+		return "", fmt.Errorf("the %d assembled parts of %s do not form a valid file, so nothing was written: %v. Restart with part 1", s.parts, path, err)
+	}
+	if Target.Remote() {
+		err = Target.WriteFile(ctx, path, data)
+	} else {
+		err = config.WriteFileAtomic(path, data, 0644)
+	}
+	if err != nil {
+		return "", err
+	}
+	Versions.Record(path, string(data))
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("Successfully wrote %s from %d parts: %d lines, %d bytes, sha256 %s. Last line: %s%s",
+		path, s.parts, countLines(data), len(data), hex.EncodeToString(sum[:8]), lastLine(data), note), nil
+}
+
+// checkSyntax catches files that were cut off or assembled wrongly, for
+// formats that can be checked without external tools
+func checkSyntax(path string, data []byte) error {
+	l := lang.FromPath(path)
+	if l == nil {
+		return nil
+	}
+	switch l.ID {
+	case "json":
+		var v interface{}
+		return json.Unmarshal(data, &v)
+	case "go":
+		_, err := parser.ParseFile(token.NewFileSet(), path, data, parser.AllErrors)
+		return err
+	}
+	return nil
+}
+
+func countLines(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	n := bytes.Count(data, []byte("\n"))
+	if data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// lastLine returns the last non-empty line, so the model can confirm the
+// file ends where it meant it to
+func lastLine(data []byte) string {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	if len(line) > 80 {
+		line = line[:77] + "..."
+	}
+	return fmt.Sprintf("%q", line)
+}
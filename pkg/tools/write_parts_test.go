@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteInParts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	tool := &WriteTool{}
+	ctx := context.Background()
+
+	write := func(part int, final bool, content string) (string, error) {
+		return tool.Execute(ctx, map[string]interface{}{
+			"file_path": path, "content": content, "part": float64(part), "final": final,
+		})
+	}
+
+	if _, err := write(1, false, "package main\n\nfunc main() {\n"); err != nil {
+		t.Fatalf("Part 1 failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("The file must not be written before the final part")
+	}
+	if _, err := write(3, false, "}\n"); err == nil || !strings.Contains(err.Error(), "expected part 2") {
+		t.Errorf("Expected an out-of-order part to be rejected, got %v", err)
+	}
+	if _, err := write(2, false, "\tprintln(\"hi\")\n"); err != nil {
+		t.Fatalf("Part 2 failed: %v", err)
+	}
+	out, err := write(3, true, "}\n")
+	if err != nil {
+		t.Fatalf("Final part failed: %v", err)
+	}
+	if !strings.Contains(out, "from 3 parts: 5 lines") {
+		t.Errorf("Unexpected summary: %s", out)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n" {
+		t.Errorf("Unexpected content: %q", data)
+	}
+}
+
+func TestWriteInPartsRejectsTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(path, []byte(`{"old": true}`), 0644)
+	tool := &WriteTool{}
+
+	tool.Execute(context.Background(), map[string]interface{}{"file_path": path, "content": `{"items": [1, 2,`, "part": float64(1)})
+	_, err := tool.Execute(context.Background(), map[string]interface{}{"file_path": path, "content": ` 3`, "part": float64(2), "final": true})
+	if err == nil {
+		t.Fatal("Expected invalid JSON to be rejected")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"old": true}` {
+		t.Errorf("The original file must be left alone, got %q", data)
+	}
+
+	// Without part 1 there is nothing to continue
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"file_path": path, "content": "x", "part": float64(2)}); err == nil {
+		t.Error("Expected a continuation without part 1 to fail")
+	}
+}
@@ -11,6 +11,29 @@ import (
     "golang.org/x/term"
 )
 
+// Tip is a suggestion for the project shown in the banner. Pressing its
+// number at an empty prompt types Input.
+type Tip struct {
+	Text  string
+	Input string
+}
+
+// SetTips sets the tips the banner shows and the prompt acts on; nil ends
+// them
+func (u *UI) SetTips(tips []Tip) {
+	u.tips = tips
+}
+
+// AddTip adds a tip once the banner is drawn, printing it with its number
+func (u *UI) AddTip(tip Tip) {
+	u.tips = append(u.tips, tip)
+	u.Print(formatTip(len(u.tips), tip) + lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(fmt.Sprintf("  (press %d)", len(u.tips))))
+}
+
+func formatTip(n int, tip Tip) string {
+	return fmt.Sprintf("%d ▸ %s", n, tip.Text)
+}
+
 func (u *UI) DrawBanner(modelName string) {
     // Get terminal width
     width, _, err := term.GetSize(int(os.Stdout.Fd()))
@@ -88,9 +111,20 @@ func (u *UI) DrawBanner(modelName string) {
 	)
 
 	// Right Column (Tips & Activity)
-	tipsHeader := lipgloss.NewStyle().Foreground(borderColor).Render("Tips for getting started")
+	tipsHeader := lipgloss.NewStyle().Foreground(borderColor).Render("Tips for this project")
     // Wrap text for tips
-	tipsBody := lipgloss.NewStyle().Width(rightWidth).Render("Run /init to create a AGENTS.md file with project instructions.")
+	tipsText := "All set up. Type / for commands."
+	if key := u.KeyFor(KeyPalette); key != "" {
+		tipsText = fmt.Sprintf("All set up. Press %s for the action palette, or / for commands.", key)
+	}
+	if len(u.tips) > 0 {
+		lines := make([]string, len(u.tips))
+		for i, tip := range u.tips {
+			lines[i] = formatTip(i+1, tip)
+		}
+		tipsText = strings.Join(lines, "\n") + "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press a tip's number at an empty prompt to do it")
+	}
+	tipsBody := lipgloss.NewStyle().Width(rightWidth).Render(tipsText)
     
     activityHeader := lipgloss.NewStyle().Foreground(borderColor).MarginTop(1).Render("Recent activity")
     activityBody := lipgloss.NewStyle().Width(rightWidth).Render("No recent activity") // TODO: Pull from session history
@@ -0,0 +1,199 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// clipboardTimeout bounds a clipboard helper, so a hung one cannot freeze
+// the prompt
+const clipboardTimeout = 3 * time.Second
+
+// errNoClipboardTool means no helper that can read images is installed
+var errNoClipboardTool = errors.New("no clipboard tool found")
+
+// clipboardBackend reads a PNG image from the system clipboard with an
+// external helper program. It returns nil data when the clipboard holds no
+// image.
+type clipboardBackend struct {
+	tool    string // Program that must be in PATH
+	install string // Hint shown when no backend is available
+	read    func(ctx context.Context) ([]byte, error)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// clipboardBackends lists the helpers for the current platform in order of
+// preference
+func clipboardBackends() []clipboardBackend {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardBackend{
+			{tool: "pngpaste", read: func(ctx context.Context) ([]byte, error) {
+				return run(ctx, "pngpaste", "-")
+			}},
+			{tool: "osascript", read: readMacClipboard},
+		}
+	case "windows":
+		return []clipboardBackend{
+			{tool: "powershell", read: readWindowsClipboard("powershell")},
+		}
+	}
+
+	var backends []clipboardBackend
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		backends = append(backends, clipboardBackend{tool: "wl-paste", install: "wl-clipboard",
+			read: func(ctx context.Context) ([]byte, error) {
+				types, err := run(ctx, "wl-paste", "--list-types")
+				if err != nil || !bytes.Contains(types, []byte("image/png")) {
+					return nil, nil
+				}
+				return run(ctx, "wl-paste", "--no-newline", "--type", "image/png")
+			}})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		backends = append(backends, clipboardBackend{tool: "xclip", install: "xclip",
+			read: func(ctx context.Context) ([]byte, error) {
+				targets, err := run(ctx, "xclip", "-selection", "clipboard", "-t", "TARGETS", "-o")
+				if err != nil || !bytes.Contains(targets, []byte("image/png")) {
+					return nil, nil
+				}
+				return run(ctx, "xclip", "-selection", "clipboard", "-t", "image/png", "-o")
+			}})
+	}
+	// WSL can reach the Windows clipboard
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		backends = append(backends, clipboardBackend{tool: "powershell.exe",
+			read: readWindowsClipboard("powershell.exe")})
+	}
+	return backends
+}
+
+// readClipboardImage returns the PNG image on the clipboard, or nil if there
+// is none. errNoClipboardTool means the platform's helper is not installed;
+// headless sessions without a display have no backends at all.
+func readClipboardImage() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+
+	var missing []string
+	available := false
+	for _, b := range clipboardBackends() {
+		if _, err := exec.LookPath(b.tool); err != nil {
+			if b.install != "" {
+				missing = append(missing, b.install)
+			}
+			continue
+		}
+		available = true
+		data, err := b.read(ctx)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		if !bytes.HasPrefix(data, pngSignature) {
+			continue
+		}
+		return data, nil
+	}
+	if !available && len(missing) > 0 {
+		return nil, fmt.Errorf("%w: install %s to paste images", errNoClipboardTool, strings.Join(missing, " or "))
+	}
+	return nil, nil
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+var macPNGData = regexp.MustCompile(`«data PNGf([0-9A-Fa-f]+)»`)
+
+// readMacClipboard asks AppleScript for the clipboard as PNG, which it
+// prints as a hex literal
+func readMacClipboard(ctx context.Context) ([]byte, error) {
+	out, err := run(ctx, "osascript", "-e", "the clipboard as «class PNGf»")
+	if err != nil {
+		return nil, nil // No image on the clipboard
+	}
+	m := macPNGData.FindSubmatch(out)
+	if m == nil {
+		return nil, nil
+	}
+	return hex.DecodeString(string(m[1]))
+}
+
+// readWindowsClipboard has PowerShell encode the clipboard image as base64 PNG
+func readWindowsClipboard(shell string) func(ctx context.Context) ([]byte, error) {
+	const script = `Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; ` +
+		`$img = [System.Windows.Forms.Clipboard]::GetImage(); ` +
+		`if ($img) { $ms = New-Object System.IO.MemoryStream; ` +
+		`$img.Save($ms, [System.Drawing.Imaging.ImageFormat]::Png); [Convert]::ToBase64String($ms.ToArray()) }`
+	return func(ctx context.Context) ([]byte, error) {
+		out, err := run(ctx, shell, "-NoProfile", "-STA", "-Command", script)
+		if err != nil {
+			return nil, err
+		}
+		encoded := strings.TrimSpace(string(out))
+		if encoded == "" {
+			return nil, nil
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+}
+
+// clipboardWriters are the helpers that put text on the clipboard for the
+// current platform, in order of preference, each as a command reading stdin
+func clipboardWriters() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-Command",
+			"[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"}}
+	}
+	var writers [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		writers = append(writers, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		writers = append(writers, []string{"xclip", "-selection", "clipboard", "-in"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		writers = append(writers, []string{"clip.exe"})
+	}
+	return writers
+}
+
+// CopyToClipboard puts text on the system clipboard and returns how: the
+// helper's name, or "terminal" when no helper is installed and an OSC 52
+// sequence asks the terminal to do it, which works over SSH and in tmux
+// with set-clipboard on, but not in every terminal
+func (u *UI) CopyToClipboard(text string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+	for _, w := range clipboardWriters() {
+		if _, err := exec.LookPath(w[0]); err != nil {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, w[0], w[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s: %w", w[0], err)
+		}
+		return w[0], nil
+	}
+	if u.out != nil {
+		return "", fmt.Errorf("%w: no terminal to copy through", errNoClipboardTool)
+	}
+	fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return "terminal", nil
+}
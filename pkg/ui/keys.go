@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Actions of the prompt that settings can bind to other keys
+const (
+	KeyCancel     = "cancel"
+	KeyPalette    = "palette"
+	KeyPasteImage = "pasteImage"
+	KeyPlanMode   = "planMode"
+	KeyCopy       = "copy"
+)
+
+// KeyAction is a prompt action with its default key
+type KeyAction struct {
+	Name        string
+	Default     string
+	Description string
+}
+
+// KeyActions lists the bindable actions in the order /keys shows them
+var KeyActions = []KeyAction{
+	{KeyCancel, "esc", "Leave the prompt; at the main prompt this exits john"},
+	{KeyPalette, "ctrl+k", "Open the action palette"},
+	{KeyPasteImage, "ctrl+v", "Attach the image on the clipboard"},
+	{KeyPlanMode, "shift+tab", "Switch plan mode, where only read-only tools run, on or off"},
+	{KeyCopy, "ctrl+y", "Copy the last response to the clipboard"},
+}
+
+// unbound is the key that turns an action off
+const unbound = "none"
+
+// reservedKeys keep their meaning: submitting, editing the input, and
+// Ctrl+C, which always cancels and stops responses
+var reservedKeys = map[string]bool{
+	"enter": true, "ctrl+m": true, "ctrl+j": true, "ctrl+c": true, "backspace": true, "ctrl+h": true,
+	"delete": true, "left": true, "right": true, "home": true, "end": true, " ": true,
+}
+
+// keyNames are the keys bubbletea reports by name, like "ctrl+k" or "f5"
+var keyNames = func() map[string]bool {
+	names := make(map[string]bool)
+	for k := tea.KeyType(-100); k <= 127; k++ {
+		if s := k.String(); s != "" && s != "runes" {
+			names[s] = true
+		}
+	}
+	return names
+}()
+
+// validKey checks a key as bubbletea names it: a named key, or Alt with a
+// named key or a character. Plain characters would stop them being typed.
+func validKey(key string) error {
+	if reservedKeys[key] {
+		return fmt.Errorf("%s is reserved", key)
+	}
+	if keyNames[key] {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(key, "alt+"); ok && (keyNames[rest] || utf8.RuneCountInString(rest) == 1) {
+		return nil
+	}
+	return fmt.Errorf("unknown key %q; use names like ctrl+p, alt+k, shift+tab, esc or f2", key)
+}
+
+// SetKeys applies the key settings, a map of action to key, over the
+// defaults. Unknown actions, invalid keys and keys bound twice are reported
+// together and leave those actions on their defaults.
+func (u *UI) SetKeys(custom map[string]string) error {
+	byAction := make(map[string]string)
+	for _, a := range KeyActions {
+		byAction[a.Name] = a.Default
+	}
+	var errs []error
+	actions := make([]string, 0, len(custom))
+	for action := range custom {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		key := strings.ToLower(strings.TrimSpace(custom[action]))
+		if _, ok := byAction[action]; !ok {
+			errs = append(errs, fmt.Errorf("keys: unknown action %q", action))
+			continue
+		}
+		if key != unbound {
+			if err := validKey(key); err != nil {
+				errs = append(errs, fmt.Errorf("keys.%s: %w", action, err))
+				continue
+			}
+		}
+		byAction[action] = key
+	}
+
+	keys := make(map[string]string)
+	for _, a := range KeyActions {
+		key := byAction[a.Name]
+		if key == unbound {
+			continue
+		}
+		if other, taken := keys[key]; taken {
+			errs = append(errs, fmt.Errorf("keys.%s: %s is already bound to %s", a.Name, key, other))
+			if _, taken := keys[a.Default]; taken {
+				continue
+			}
+			key = a.Default
+		}
+		keys[key] = a.Name
+	}
+	u.keys = keys
+	return errors.Join(errs...)
+}
+
+// keyAction returns the action bound to a key press, or ""
+func (u *UI) keyAction(msg tea.KeyMsg) string {
+	key := msg.String()
+	if key == "ctrl+c" {
+		return KeyCancel
+	}
+	if u.keys == nil {
+		for _, a := range KeyActions {
+			if a.Default == key {
+				return a.Name
+			}
+		}
+		return ""
+	}
+	return u.keys[key]
+}
+
+// KeyFor returns the key bound to an action for display, like "Ctrl+K", or
+// "" when it is unbound
+func (u *UI) KeyFor(action string) string {
+	key := ""
+	if u.keys == nil {
+		for _, a := range KeyActions {
+			if a.Name == action {
+				key = a.Default
+			}
+		}
+	}
+	for k, a := range u.keys {
+		if a == action {
+			key = k
+		}
+	}
+	return displayKey(key)
+}
+
+// displayKey capitalizes a key name: ctrl+k is Ctrl+K, pgup is PgUp
+func displayKey(key string) string {
+	parts := strings.Split(key, "+")
+	for i, p := range parts {
+		switch {
+		case p == "":
+			// alt++
+		case p == "esc":
+			parts[i] = "Esc"
+		case p == "pgup":
+			parts[i] = "PgUp"
+		case p == "pgdown":
+			parts[i] = "PgDown"
+		case utf8.RuneCountInString(p) == 1:
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// pathLineRef matches path:line and path:line:column references to files
+// with an extension, like pkg/ui/ui.go:42 or /tmp/x.py:3:7
+var pathLineRef = regexp.MustCompile(`((?:[A-Za-z]:)?[\w./\\~+@-]*\.[A-Za-z0-9]+):(\d+)(?::(\d+))?`)
+
+// SetHyperlinks turns path:line references in output into OSC 8 links built
+// from template (see config.HyperlinkSettings), or turns them off for "".
+// Output that is not a terminal never gets links.
+func (u *UI) SetHyperlinks(template string) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) || os.Getenv("TERM") == "dumb" {
+		template = ""
+	}
+	u.linkTemplate = template
+}
+
+// linkify wraps the references in s to existing files in hyperlinks
+func (u *UI) linkify(s string) string {
+	if u.linkTemplate == "" || !strings.Contains(s, ":") {
+		return s
+	}
+	return pathLineRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := pathLineRef.FindStringSubmatch(ref)
+		path := m[1]
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return ref
+		}
+		if info, err := os.Stat(abs); err != nil || info.IsDir() {
+			return ref
+		}
+		column := m[3]
+		if column == "" {
+			column = "1"
+		}
+		return hyperlink(expandLinkTemplate(u.linkTemplate, abs, m[2], column), ref)
+	})
+}
+
+// expandLinkTemplate fills in a link template for a file location
+func expandLinkTemplate(template, abs, line, column string) string {
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows drive letters: /C:/src/x.go
+	}
+	escaped := (&url.URL{Path: p}).EscapedPath()
+	return strings.NewReplacer("{path}", escaped, "{line}", line, "{column}", column).Replace(template)
+}
+
+// hyperlink wraps text in an OSC 8 escape sequence. Terminals without
+// support show the text alone.
+func hyperlink(target, text string) string {
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
@@ -0,0 +1,45 @@
+package ui
+
+import "fmt"
+
+// MCPToolInfo describes an MCP tool for the tool browser
+type MCPToolInfo struct {
+	Name        string // Full name: mcp__<server>__<tool>
+	Server      string
+	Tool        string // Name on the server
+	Description string
+	Enabled     bool
+}
+
+// PickMCPTool lists MCP tools grouped by server and returns the full name of
+// the selected one, or "" if canceled. Tools should be sorted by server.
+func (u *UI) PickMCPTool(tools []MCPToolInfo) string {
+	items := make([]pickerItem, len(tools))
+	for i, t := range tools {
+		mark := "✓"
+		if !t.Enabled {
+			mark = "✗"
+		}
+		items[i] = pickerItem{
+			id:          t.Name,
+			title:       fmt.Sprintf("%s %s › %s", mark, t.Server, t.Tool),
+			description: t.Description,
+		}
+	}
+	return pick("MCP Tools (enter to inspect, esc to close)", items, 100, 20)
+}
+
+// PickMCPToolAction asks what to do with a tool and returns "schema",
+// "toggle" or "run", or "" to go back
+func (u *UI) PickMCPToolAction(tool MCPToolInfo) string {
+	toggle := pickerItem{id: "toggle", title: "Disable for this session", description: "Hide the tool from the model until john restarts"}
+	if !tool.Enabled {
+		toggle = pickerItem{id: "toggle", title: "Enable", description: "Offer the tool to the model again"}
+	}
+	items := []pickerItem{
+		{id: "schema", title: "Show schema", description: "Description and input schema"},
+		toggle,
+		{id: "run", title: "Run", description: "Call the tool with JSON arguments; the result is not sent to the model"},
+	}
+	return pick(fmt.Sprintf("%s › %s", tool.Server, tool.Tool), items, 100, 12)
+}
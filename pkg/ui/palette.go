@@ -0,0 +1,33 @@
+package ui
+
+import "strings"
+
+// ActionInfo is an entry of the Ctrl+K action palette
+type ActionInfo struct {
+	ID          string
+	Title       string
+	Description string
+}
+
+// PickAction shows the action palette, which fuzzy-filters as the user
+// types, and returns the ID of the chosen action, or "" if canceled. A
+// scripted UI picks the first action whose title contains the answer.
+func (u *UI) PickAction(actions []ActionInfo) string {
+	if u.in != nil {
+		answer, _ := u.scriptedAnswer("Action: ", true)
+		if answer == "" {
+			return ""
+		}
+		for _, a := range actions {
+			if strings.Contains(strings.ToLower(a.Title), strings.ToLower(answer)) {
+				return a.ID
+			}
+		}
+		return ""
+	}
+	items := make([]pickerItem, len(actions))
+	for i, a := range actions {
+		items[i] = pickerItem{id: a.ID, title: a.Title, description: a.Description}
+	}
+	return pickFiltering("Actions", items, 80, 16)
+}
@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pickerItem is a generic entry for list pickers that return an ID
+type pickerItem struct {
+	id          string
+	title       string
+	description string
+}
+
+func (i pickerItem) Title() string       { return i.title }
+func (i pickerItem) Description() string { return i.description }
+func (i pickerItem) FilterValue() string { return i.title + " " + i.description }
+
+type pickerModel struct {
+	list        list.Model
+	selected    string
+	canceled    bool
+	filterFirst bool // Typing filters at once, and Enter picks the top match
+}
+
+func newPickerModel(title string, items []pickerItem, width, height int) pickerModel {
+	listItems := make([]list.Item, len(items))
+	for i, item := range items {
+		listItems[i] = item
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color("62")).
+		Foreground(lipgloss.Color("170")).
+		Padding(0, 0, 0, 1)
+	delegate.Styles.SelectedDesc = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color("62")).
+		Foreground(lipgloss.Color("240")).
+		Padding(0, 0, 0, 1)
+
+	l := list.New(listItems, delegate, width, height)
+	l.Title = title
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true).
+		Padding(0, 1)
+
+	return pickerModel{list: l}
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// Let the list handle keys while the user is typing a filter
+		if m.list.FilterState() == list.Filtering {
+			if !m.filterFirst {
+				break
+			}
+			switch msg.Type {
+			case tea.KeyEnter:
+				if item, ok := m.list.SelectedItem().(pickerItem); ok {
+					m.selected = item.id
+				}
+				return m, tea.Quit
+			case tea.KeyCtrlC, tea.KeyEsc:
+				m.canceled = true
+				return m, tea.Quit
+			}
+			break
+		}
+		switch msg.Type {
+		case tea.KeyEnter:
+			if item, ok := m.list.SelectedItem().(pickerItem); ok {
+				m.selected = item.id
+			}
+			return m, tea.Quit
+		case tea.KeyCtrlC, tea.KeyEsc:
+			m.canceled = true
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m pickerModel) View() string {
+	return m.list.View()
+}
+
+// pick runs a picker and returns the selected ID, or "" if canceled
+func pick(title string, items []pickerItem, width, height int) string {
+	return runPicker(newPickerModel(title, items, width, height))
+}
+
+// pickFiltering runs a picker that fuzzy-filters as the user types
+func pickFiltering(title string, items []pickerItem, width, height int) string {
+	model := newPickerModel(title, items, width, height)
+	model.filterFirst = true
+	model.list.SetFilterText("")
+	model.list.SetFilterState(list.Filtering)
+	return runPicker(model)
+}
+
+func runPicker(model pickerModel) string {
+	p := tea.NewProgram(model)
+	m, err := p.Run()
+	if err != nil {
+		fmt.Printf("Error in picker: %v\n", err)
+		return ""
+	}
+
+	if model, ok := m.(pickerModel); ok && !model.canceled {
+		return model.selected
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/jbdamask/john-code/pkg/i18n"
+)
+
+// SessionInfo holds session info for the picker
+type SessionInfo struct {
+	ID          string
+	FirstPrompt string
+	ModTime     time.Time
+}
+
+// PickSession displays a session picker and returns the selected session ID
+// Returns empty string if canceled
+func (u *UI) PickSession(sessions []SessionInfo) string {
+	items := make([]pickerItem, len(sessions))
+	for i, s := range sessions {
+		title := s.FirstPrompt
+		if title == "" {
+			title = "(no prompt)"
+		}
+		items[i] = pickerItem{
+			id:          s.ID,
+			title:       title,
+			description: i18n.FormatTime(s.ModTime) + " · " + shortID(s.ID),
+		}
+	}
+	return pick("Resume Session", items, 80, 14)
+}
+
+// shortID returns the first 8 characters of a session ID, or all of a
+// shorter one
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
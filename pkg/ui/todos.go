@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TodoInfo holds a todo item for display
+type TodoInfo struct {
+	Content    string
+	ActiveForm string
+	Status     string // pending, in_progress, completed
+}
+
+// ShowTodos renders the todo panel. When the panel is collapsed only a
+// one-line progress summary is printed.
+func (u *UI) ShowTodos(todos []TodoInfo) {
+	if len(todos) == 0 {
+		return
+	}
+
+	completed := 0
+	current := ""
+	for _, t := range todos {
+		switch t.Status {
+		case "completed":
+			completed++
+		case "in_progress":
+			current = t.Content
+			if t.ActiveForm != "" {
+				current = t.ActiveForm
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("Todos %d/%d done", completed, len(todos))
+	if current != "" {
+		summary += " · " + current
+	}
+
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	if u.todosCollapsed {
+		fmt.Fprintln(u.writer(), dim.Render(summary+" (/todos to expand)"))
+		return
+	}
+
+	doneStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Strikethrough(true)
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true)
+
+	var lines []string
+	for _, t := range todos {
+		switch t.Status {
+		case "completed":
+			lines = append(lines, doneStyle.Render("☒ "+t.Content))
+		case "in_progress":
+			lines = append(lines, activeStyle.Render("◐ "+t.Content))
+		default:
+			lines = append(lines, "☐ "+t.Content)
+		}
+	}
+
+	panel := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(0, 1).
+		Render(dim.Render(summary) + "\n" + strings.Join(lines, "\n"))
+	fmt.Fprintln(u.writer(), panel)
+}
+
+// ToggleTodos switches the todo panel between expanded and collapsed and
+// reports whether it is now collapsed.
+func (u *UI) ToggleTodos() bool {
+	u.todosCollapsed = !u.todosCollapsed
+	return u.todosCollapsed
+}
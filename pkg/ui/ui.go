@@ -1,8 +1,11 @@
 package ui
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -11,17 +14,60 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"golang.design/x/clipboard"
+
+	"github.com/jbdamask/john-code/pkg/i18n"
 )
 
-type UI struct{}
+type UI struct {
+	todosCollapsed bool
+	linkTemplate   string            // URL template for path:line links; empty for none
+	out            io.Writer         // Where output goes; nil for stdout
+	in             *bufio.Reader     // Scripted answers for prompts; nil reads the terminal
+	tips           []Tip             // Numbered tips a digit at an empty prompt acts on
+	keys           map[string]string // Key to prompt action; nil for the defaults
+}
 
 func New() *UI {
 	return &UI{}
 }
 
+// NewScripted returns a UI that writes to out and answers prompts with the
+// lines of in instead of reading the terminal, for tests and other callers
+// without one. Prompts get "exit" once in runs out, like Ctrl+C would.
+func NewScripted(in io.Reader, out io.Writer) *UI {
+	return &UI{out: out, in: bufio.NewReader(in)}
+}
+
+func (u *UI) writer() io.Writer {
+	if u.out == nil {
+		return os.Stdout
+	}
+	return u.out
+}
+
 func (u *UI) Print(msg string) {
-	fmt.Println(msg)
+	fmt.Fprintln(u.writer(), u.linkify(msg))
+}
+
+// PrintDim prints msg greyed, for notes that are not part of a response
+func (u *UI) PrintDim(msg string) {
+	fmt.Fprintln(u.writer(), lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(msg))
+}
+
+// scriptedAnswer reads the next answer from the scripted input, echoing it
+// unless it is secret
+func (u *UI) scriptedAnswer(prompt string, echo bool) (string, bool) {
+	fmt.Fprint(u.writer(), prompt)
+	line, err := u.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(u.writer())
+		return "", false
+	}
+	if echo {
+		fmt.Fprint(u.writer(), strings.TrimRight(line, "\r\n"))
+	}
+	fmt.Fprintln(u.writer())
+	return strings.TrimSpace(line), true
 }
 
 // Input Handling
@@ -32,11 +78,27 @@ type inputModel struct {
 	output       string
 	canceled     bool
 	slashTrigger bool // Triggered when "/" is typed as first char
-}
+	notice       string
+	secret       bool   // Masked input: no image paste or command picker
+	action       string // Key action that ended the prompt: palette, planMode or copy
+	tips         []Tip
+	keyAction    func(tea.KeyMsg) string
+}
+
+// What Prompt returns when the user presses the key of the action palette,
+// plan mode or copy (see KeyActions)
+const (
+	PaletteInput  = "\x0b"
+	PlanModeInput = "\x00planMode"
+	CopyInput     = "\x00copy"
+)
+
+// clipboardHintShown limits the missing clipboard tool hint to once a session
+var clipboardHintShown bool
 
 func initialInputModel(prompt string) inputModel {
 	ti := textinput.New()
-	ti.Placeholder = "Type your message..."
+	ti.Placeholder = i18n.T("Type your message...")
 	ti.Focus()
 	ti.CharLimit = 0
 	ti.Width = 80
@@ -57,34 +119,58 @@ func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
+		if msg.Type == tea.KeyEnter {
 			m.output = m.textInput.Value()
 			return m, tea.Quit
-		case tea.KeyCtrlC, tea.KeyEsc:
+		}
+		switch action := m.keyAction(msg); action {
+		case KeyCancel:
 			m.canceled = true
 			return m, tea.Quit
-		case tea.KeyCtrlV:
-			// Check for image data in clipboard
-			err := clipboard.Init()
-			if err == nil {
-				imageBytes := clipboard.Read(clipboard.FmtImage)
-				if len(imageBytes) > 0 {
-					// Save to temp file
-					tmpDir := "/tmp" // Cross platform consideration needed? For MVP /tmp is ok
-					filename := fmt.Sprintf("john_clipboard_%d.png", time.Now().UnixNano())
-					path := filepath.Join(tmpDir, filename)
-
-					if err := ioutil.WriteFile(path, imageBytes, 0644); err == nil {
-						m.textInput.SetValue(m.textInput.Value() + fmt.Sprintf(" [Image: %s] ", path))
-						// Position cursor at end
-						m.textInput.SetCursor(len(m.textInput.Value()))
-					}
+		case KeyPalette, KeyPlanMode, KeyCopy:
+			if !m.secret {
+				m.action = action
+				return m, tea.Quit
+			}
+			// Not typed into the input either
+			return m, nil
+		case KeyPasteImage:
+			if m.secret {
+				return m, nil
+			}
+			// Attach an image on the clipboard; text is pasted by the input below
+			imageBytes, err := readClipboardImage()
+			if err != nil && !clipboardHintShown {
+				m.notice = err.Error()
+				clipboardHintShown = true
+			}
+			if len(imageBytes) > 0 {
+				// Save to temp file
+				tmpDir := os.TempDir() // The session's temp dir while the agent runs
+				filename := fmt.Sprintf("john_clipboard_%d.png", time.Now().UnixNano())
+				path := filepath.Join(tmpDir, filename)
+
+				if err := ioutil.WriteFile(path, imageBytes, 0644); err == nil {
+					m.textInput.SetValue(m.textInput.Value() + fmt.Sprintf(" [Image: %s] ", path))
+					// Position cursor at end
+					m.textInput.SetCursor(len(m.textInput.Value()))
 				}
 			}
+			// Text on the clipboard arrives as a bracketed paste instead
+			return m, nil
+		}
+		switch msg.Type {
 		case tea.KeyRunes:
+			// A tip's number on an empty prompt types its input
+			if len(msg.Runes) == 1 && m.textInput.Value() == "" && !m.secret {
+				if n := int(msg.Runes[0] - '1'); n >= 0 && n < len(m.tips) && n < 9 {
+					m.output = m.tips[n].Input
+					m.textInput.SetValue(m.output)
+					return m, tea.Quit
+				}
+			}
 			// Check if "/" is typed as first character (empty input)
-			if len(msg.Runes) == 1 && msg.Runes[0] == '/' && m.textInput.Value() == "" {
+			if len(msg.Runes) == 1 && msg.Runes[0] == '/' && m.textInput.Value() == "" && !m.secret {
 				m.slashTrigger = true
 				m.output = "/"
 				return m, tea.Quit
@@ -100,6 +186,9 @@ func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m inputModel) View() string {
+	if m.notice != "" {
+		return fmt.Sprintf("%s\n%s\n", m.textInput.View(), lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(m.notice))
+	}
 	return fmt.Sprintf(
 		"%s\n",
 		m.textInput.View(),
@@ -107,7 +196,16 @@ func (m inputModel) View() string {
 }
 
 func (u *UI) Prompt(prompt string) string {
-	p := tea.NewProgram(initialInputModel(prompt))
+	if u.in != nil {
+		if answer, ok := u.scriptedAnswer(prompt, true); ok {
+			return answer
+		}
+		return "exit"
+	}
+	model := initialInputModel(prompt)
+	model.tips = u.tips
+	model.keyAction = u.keyAction
+	p := tea.NewProgram(model)
 	m, err := p.Run()
 	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
@@ -117,74 +215,74 @@ func (u *UI) Prompt(prompt string) string {
 	if mModel, ok := m.(inputModel); ok {
         if mModel.canceled {
             return "exit"
+        }
+        switch mModel.action {
+        case KeyPalette:
+            return PaletteInput
+        case KeyPlanMode:
+            return PlanModeInput
+        case KeyCopy:
+            return CopyInput
         }
 		return strings.TrimSpace(mModel.output)
 	}
 	return ""
 }
 
-// Stream Handling
-
-type streamModel struct {
-	sub      <-chan string
-	content  string
-	showing  bool
-	finished bool
-}
-
-type tokenMsg string
-type finishMsg struct{}
-
-func waitForToken(sub <-chan string) tea.Cmd {
-	return func() tea.Msg {
-		token, ok := <-sub
-		if !ok {
-			return finishMsg{}
-		}
-		return tokenMsg(token)
+// PromptSecret reads a line without echoing it, for passwords and tokens.
+// It returns "" if the user cancels.
+func (u *UI) PromptSecret(prompt string) string {
+	if u.in != nil {
+		answer, _ := u.scriptedAnswer(prompt, false)
+		return answer
 	}
-}
-
-func (m streamModel) Init() tea.Cmd {
-	return waitForToken(m.sub)
-}
-
-func (m streamModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		if msg.String() == "ctrl+o" {
-			m.showing = !m.showing
-			return m, nil
-		}
-        if msg.Type == tea.KeyCtrlC {
-            return m, tea.Quit
-        }
-	case tokenMsg:
-		m.content += string(msg)
-		return m, waitForToken(m.sub)
-	case finishMsg:
-		m.finished = true
-        // Ensure we show the content at the end
-        m.showing = true
-		return m, tea.Quit
+	model := initialInputModel(prompt)
+	model.secret = true
+	model.keyAction = u.keyAction
+	model.textInput.EchoMode = textinput.EchoPassword
+	model.textInput.EchoCharacter = '•'
+	model.textInput.Placeholder = i18n.T("Input is hidden")
+
+	m, err := tea.NewProgram(model).Run()
+	if err != nil {
+		return ""
 	}
-	return m, nil
-}
-
-func (m streamModel) View() string {
-	if !m.showing {
-		return "Thinking... (Press Ctrl+O to show stream)"
+	if mModel, ok := m.(inputModel); ok && !mModel.canceled {
+		return strings.TrimSpace(mModel.output)
 	}
-	return m.content
+	return ""
 }
 
-func (u *UI) DisplayStream(outputChan <-chan string) {
-	// Simple streaming: just print tokens as they arrive
-	// This allows natural terminal scrolling and is more responsive
+// Stream Handling
+
+// DisplayStream prints tokens as they arrive and returns the text it printed,
+// so callers can tell what the user has already seen. Tokens go straight to
+// stdout rather than through a bubbletea program: a program re-renders its
+// final view on exit, which printed every response twice. With hyperlinks
+// on, the last partial word is held back until it is complete, so a
+// path:line split across tokens is still linked.
+func (u *UI) DisplayStream(outputChan <-chan string) string {
+	var sb strings.Builder
+	pending := ""
 	for token := range outputChan {
-		fmt.Print(token)
+		sb.WriteString(token)
+		if u.linkTemplate == "" {
+			fmt.Fprint(u.writer(), token)
+			continue
+		}
+		pending += token
+		if i := strings.LastIndexAny(pending, " \t\n"); i >= 0 {
+			fmt.Fprint(u.writer(), u.linkify(pending[:i+1]))
+			pending = pending[i+1:]
+		}
+	}
+	fmt.Fprint(u.writer(), u.linkify(pending))
+	// Finish the line so tool output doesn't run into streamed text, without
+	// adding blank lines for turns that only called tools
+	if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+		fmt.Fprintln(u.writer())
 	}
-	fmt.Println() // Newline at end
+	return sb.String()
 }
 
 // Command Picker for slash commands
@@ -0,0 +1,309 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jbdamask/john-code/pkg/lang"
+	"golang.org/x/term"
+)
+
+// syntax describes just enough of a language to color keywords, strings,
+// comments and numbers a line at a time
+type syntax struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockStart   string
+	blockEnd     string
+	quotes       string
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cLike = syntax{
+		keywords: words(`auto break case char const continue default do double else enum extern float for goto if
+			inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile
+			while bool true false class namespace template typename public private protected virtual new delete this
+			nullptr try catch throw using override final`),
+		lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`,
+	}
+
+	syntaxes = map[string]syntax{
+		"go": {
+			keywords: words(`break case chan const continue default defer else fallthrough for func go goto if import
+				interface map package range return select struct switch type var nil true false iota error string int
+				int64 int32 uint uint64 byte rune bool float64 any make new len cap append`),
+			lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"'`",
+		},
+		"py": {
+			keywords: words(`and as assert async await break class continue def del elif else except finally for from
+				global if import in is lambda nonlocal not or pass raise return try while with yield None True False self`),
+			lineComments: []string{"#"}, quotes: `"'`,
+		},
+		"js": {
+			keywords: words(`async await break case catch class const continue debugger default delete do else export
+				extends finally for function if import in instanceof let new of return static super switch this throw try
+				typeof var void while yield null undefined true false interface type enum implements readonly`),
+			lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"'`",
+		},
+		"rs": {
+			keywords: words(`as async await break const continue crate dyn else enum extern false fn for if impl in let
+				loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while
+				Some None Ok Err`),
+			lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"`,
+		},
+		"java": {
+			keywords: words(`abstract boolean break byte case catch char class const continue default do double else
+				enum extends final finally float for if implements import instanceof int interface long new null package
+				private protected public return short static super switch this throw throws try void while true false
+				val var fun`),
+			lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`,
+		},
+		"rb": {
+			keywords: words(`begin break case class def do else elsif end ensure false for if in module next nil not
+				or redo rescue retry return self super then true undef unless until when while yield require`),
+			lineComments: []string{"#"}, quotes: `"'`,
+		},
+		"sh": {
+			keywords: words(`if then else elif fi case esac for while until do done in function return local export
+				echo exit set unset readonly shift`),
+			lineComments: []string{"#"}, quotes: `"'`,
+		},
+		"sql": {
+			keywords: words(`select from where and or not insert into values update set delete create table drop alter
+				index join left right inner outer on group by order having limit as null is in like primary key
+				SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER INDEX JOIN
+				LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AS NULL IS IN LIKE PRIMARY KEY`),
+			lineComments: []string{"--"}, blockStart: "/*", blockEnd: "*/", quotes: `'"`,
+		},
+		"yaml": {keywords: words(`true false null yes no`), lineComments: []string{"#"}, quotes: `"'`},
+		"json": {keywords: words(`true false null`), quotes: `"`},
+		"c":    cLike,
+	}
+
+	// syntaxByLang maps detected languages onto the entries above
+	syntaxByLang = map[string]string{
+		"go": "go", "python": "py", "javascript": "js", "typescript": "js", "rust": "rs", "java": "java",
+		"kotlin": "java", "scala": "java", "ruby": "rb", "shell": "sh", "makefile": "sh", "dockerfile": "sh",
+		"sql": "sql", "yaml": "yaml", "toml": "yaml", "json": "json", "c": "c", "cpp": "c", "csharp": "c",
+		"swift": "c", "php": "c",
+	}
+
+	keywordStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true)
+	stringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("114"))
+	commentStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Italic(true)
+	numberStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("215"))
+	gutterStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	markStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("226")).Bold(true)
+)
+
+// syntaxFor picks the syntax for a file by its detected language; other
+// dotfiles are mostly shell-like settings
+func syntaxFor(path, content string) *syntax {
+	if l := lang.Detect(path, []byte(content)); l != nil {
+		if name, ok := syntaxByLang[l.ID]; ok {
+			s := syntaxes[name]
+			return &s
+		}
+		return nil
+	}
+	base := filepath.Base(path)
+	if strings.HasPrefix(base, ".") && !strings.Contains(base[1:], ".") {
+		s := syntaxes["sh"]
+		return &s
+	}
+	return nil
+}
+
+// highlightLine colors one line. inBlock carries an open block comment over
+// from the previous line and is returned updated.
+func (s *syntax) highlightLine(line string, inBlock bool) (string, bool) {
+	var b strings.Builder
+	i := 0
+	for i < len(line) {
+		if inBlock {
+			end := strings.Index(line[i:], s.blockEnd)
+			if end < 0 {
+				b.WriteString(commentStyle.Render(line[i:]))
+				return b.String(), true
+			}
+			b.WriteString(commentStyle.Render(line[i : i+end+len(s.blockEnd)]))
+			i += end + len(s.blockEnd)
+			inBlock = false
+			continue
+		}
+
+		rest := line[i:]
+		if s.blockStart != "" && strings.HasPrefix(rest, s.blockStart) {
+			inBlock = true
+			continue
+		}
+		isComment := false
+		for _, lc := range s.lineComments {
+			if strings.HasPrefix(rest, lc) {
+				isComment = true
+			}
+		}
+		if isComment {
+			b.WriteString(commentStyle.Render(rest))
+			break
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(s.quotes, c) >= 0:
+			j := i + 1
+			for j < len(line) && line[j] != c {
+				if line[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(line) {
+				j = len(line) - 1
+			}
+			b.WriteString(stringStyle.Render(line[i : j+1]))
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(line) && (isWordByte(line[j]) || line[j] == '.') {
+				j++
+			}
+			b.WriteString(numberStyle.Render(line[i:j]))
+			i = j
+		case isWordByte(c):
+			j := i
+			for j < len(line) && isWordByte(line[j]) {
+				j++
+			}
+			if s.keywords[line[i:j]] {
+				b.WriteString(keywordStyle.Render(line[i:j]))
+			} else {
+				b.WriteString(line[i:j])
+			}
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), inBlock
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// renderFile highlights content and prefixes each line with its number. The
+// line mark, if any, gets a marker in the gutter.
+func renderFile(path, content string, mark int) string {
+	content = strings.ReplaceAll(strings.TrimSuffix(content, "\n"), "\t", "    ")
+	lines := strings.Split(content, "\n")
+	width := len(fmt.Sprint(len(lines)))
+	s := syntaxFor(path, content)
+
+	var b strings.Builder
+	inBlock := false
+	for n, line := range lines {
+		gutter := gutterStyle.Render(fmt.Sprintf(" %*d │ ", width, n+1))
+		if n+1 == mark {
+			gutter = markStyle.Render(fmt.Sprintf(">%*d │ ", width, n+1))
+		}
+		b.WriteString(gutter)
+		if s != nil {
+			line, inBlock = s.highlightLine(line, inBlock)
+		}
+		b.WriteString(line)
+		if n < len(lines)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+type viewerModel struct {
+	viewport viewport.Model
+	title    string
+	lines    int
+}
+
+func (m viewerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m viewerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "g", "home":
+			m.viewport.GotoTop()
+			return m, nil
+		case "G", "end":
+			m.viewport.GotoBottom()
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		m.viewport.Width = msg.Width
+		m.viewport.Height = msg.Height - 2
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m viewerModel) View() string {
+	header := lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true).Render(m.title)
+	last := m.viewport.YOffset + m.viewport.Height
+	if last > m.lines {
+		last = m.lines
+	}
+	footer := gutterStyle.Render(fmt.Sprintf("lines %d-%d of %d (%3.f%%)  ↑/↓ pgup/pgdn g/G scroll · q quit",
+		m.viewport.YOffset+1, last, m.lines, m.viewport.ScrollPercent()*100))
+	return header + "\n" + m.viewport.View() + "\n" + footer
+}
+
+// ViewFile shows a file with syntax highlighting and line numbers in a
+// full-screen pager, starting at line (1-based, 0 for the top). A scripted
+// UI prints it instead.
+func (u *UI) ViewFile(path, content string, line int) error {
+	if u.in != nil {
+		fmt.Fprintln(u.writer(), path)
+		fmt.Fprintln(u.writer(), renderFile(path, content, line))
+		return nil
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	vp := viewport.New(width, height-2)
+	vp.SetContent(renderFile(path, content, line))
+	if line > 0 {
+		// Leave a few lines of context above the requested line
+		vp.SetYOffset(line - 1 - (height-2)/4)
+	}
+
+	m := viewerModel{
+		viewport: vp,
+		title:    path,
+		lines:    strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1,
+	}
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}